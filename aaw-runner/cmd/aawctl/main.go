@@ -0,0 +1,164 @@
+// Command aawctl drives a running aaw-runner through its control socket (--control-socket)
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/control"
+)
+
+const usage = `Usage: aawctl [--socket path] [--json] <command> [args]
+
+Commands:
+  status                          Connection, capacity and whether tasks are held
+  tasks                           List running and queued tasks
+  cancel <task-id>                Gracefully cancel a task
+  kill <task-id>                  Force-kill a task
+  drain [--wait] [--timeout 1h]   Stop accepting tasks, optionally waiting for the last one to finish
+  undrain                         Accept tasks again
+  pause                           Hold queued tasks; running tasks carry on
+  resume                          Start queued tasks again
+  reload                          Reopen the log file and re-probe the claude CLI (same as SIGHUP)
+
+Flags:
+`
+
+// waitInterval is how often drain --wait polls for remaining tasks
+const waitInterval = time.Second
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+// run executes one command and returns the exit status: 0 on success, 1 on failure, 2 on bad usage
+func run(args []string) int {
+	socket := os.Getenv("AAW_CONTROL_SOCKET")
+	if socket == "" {
+		socket = control.DefaultSocket
+	}
+
+	fs := flag.NewFlagSet("aawctl", flag.ContinueOnError)
+	fs.StringVar(&socket, "socket", socket, "control socket of the runner (env: AAW_CONTROL_SOCKET)")
+	asJSON := fs.Bool("json", false, "print the raw JSON response")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	op, rest := fs.Arg(0), fs.Args()[1:]
+	req := control.Request{Op: op}
+	wait, timeout := false, time.Hour
+	switch op {
+	case control.OpStatus, control.OpTasks, control.OpUndrain, control.OpPause, control.OpResume, control.OpReload:
+		if len(rest) != 0 {
+			return usageError("%s takes no arguments", op)
+		}
+	case control.OpCancel, control.OpKill:
+		if len(rest) != 1 {
+			return usageError("%s takes one task id", op)
+		}
+		id, err := strconv.ParseInt(rest[0], 10, 64)
+		if err != nil || id <= 0 {
+			return usageError("invalid task id %q", rest[0])
+		}
+		req.TaskID = id
+	case control.OpDrain:
+		drainFlags := flag.NewFlagSet("drain", flag.ContinueOnError)
+		drainFlags.BoolVar(&wait, "wait", false, "wait until no task is running or queued")
+		drainFlags.DurationVar(&timeout, "timeout", timeout, "how long --wait waits before giving up")
+		if err := drainFlags.Parse(rest); err != nil {
+			return 2
+		}
+		if drainFlags.NArg() != 0 {
+			return usageError("drain takes no arguments")
+		}
+	default:
+		return usageError("unknown command %q", op)
+	}
+
+	resp, err := control.Call(socket, req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "aawctl: %v\n", err)
+		return 1
+	}
+	if *asJSON {
+		out, _ := json.MarshalIndent(resp, "", "  ")
+		fmt.Println(string(out))
+	} else {
+		printResponse(op, req, resp)
+	}
+
+	if wait {
+		fmt.Println("Waiting for running and queued tasks to finish...")
+		if err := control.WaitIdle(socket, timeout, waitInterval); err != nil {
+			fmt.Fprintf(os.Stderr, "aawctl: %v\n", err)
+			return 1
+		}
+		fmt.Println("Drained")
+	}
+	return 0
+}
+
+// usageError reports bad usage and returns its exit status
+func usageError(format string, args ...interface{}) int {
+	fmt.Fprintf(os.Stderr, "aawctl: "+format+"\n", args...)
+	fmt.Fprintln(os.Stderr, "Run 'aawctl -h' for usage.")
+	return 2
+}
+
+// printResponse writes a human-readable form of resp
+func printResponse(op string, req control.Request, resp control.Response) {
+	switch op {
+	case control.OpStatus:
+		s := resp.Status
+		fmt.Printf("Version:    %s (commit %s, built %s)\n", s.Version, s.Commit, s.BuildDate)
+		fmt.Printf("Connected:  %t\n", s.Connected)
+		fmt.Printf("Draining:   %t\n", s.Draining)
+		fmt.Printf("Paused:     %t\n", s.Paused)
+		fmt.Printf("Capacity:   %d running, %d available of %d\n", s.RunningTasks, s.AvailableSlots, s.MaxParallel)
+	case control.OpTasks:
+		if len(resp.Tasks) == 0 {
+			fmt.Println("No running or queued tasks")
+			return
+		}
+		fmt.Printf("%-10s  %-10s  %10s  %10s\n", "TASK", "STATE", "QUEUED", "RUNNING")
+		for _, task := range resp.Tasks {
+			running := "-"
+			if task.StartedAt != nil {
+				running = seconds(task.RunningSeconds)
+			}
+			fmt.Printf("%-10d  %-10s  %10s  %10s\n", task.TaskID, task.State, seconds(task.QueuedSeconds), running)
+		}
+	case control.OpCancel:
+		fmt.Printf("Cancellation of task %d requested\n", req.TaskID)
+	case control.OpKill:
+		fmt.Printf("Task %d killed\n", req.TaskID)
+	case control.OpDrain:
+		fmt.Println("Draining: no new tasks will be accepted")
+	case control.OpUndrain:
+		fmt.Println("Accepting tasks again")
+	case control.OpPause:
+		fmt.Println("Paused: queued tasks are held")
+	case control.OpResume:
+		fmt.Println("Resumed: queued tasks will start")
+	case control.OpReload:
+		fmt.Println("Reloaded")
+	}
+}
+
+// seconds formats a duration in seconds, rounded to the second
+func seconds(s float64) string {
+	return time.Duration(s * float64(time.Second)).Round(time.Second).String()
+}
@@ -0,0 +1,257 @@
+// Package admin serves a localhost-only HTTP API for operators on the runner host, and a live log viewer
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/executor"
+	"github.com/berno/aaw-runner/internal/livelog"
+	"github.com/berno/aaw-runner/internal/version"
+)
+
+// Runner is the part of the runner the API inspects and drives
+type Runner interface {
+	Connected() bool
+	Capacity() (maxParallel, running, available int)
+	Draining() bool
+	Tasks() []executor.TaskSnapshot
+	CancelTask(taskID int64) error // Same path as CANCEL_TASK, acks included
+	KillTask(taskID int64) error   // Same path as KILL_TASK, acks included
+	Drain()
+	Undrain() error
+	DroppedLogLines() int64 // Task output lines dropped since startup because sending fell behind
+}
+
+// Task is one entry of GET /tasks
+type Task struct {
+	TaskID         int64             `json:"taskId"`
+	State          string            `json:"state"` // QUEUED, RUNNING or CANCELLING
+	SubmittedAt    time.Time         `json:"submittedAt"`
+	StartedAt      *time.Time        `json:"startedAt,omitempty"`
+	QueuedSeconds  float64           `json:"queuedSeconds"`            // Time between submission and start (or now, while queued)
+	RunningSeconds float64           `json:"runningSeconds,omitempty"` // Time since the task started
+	Metadata       map[string]string `json:"metadata,omitempty"`
+}
+
+// Status is the body of GET /status
+type Status struct {
+	Connected       bool   `json:"connected"`
+	Draining        bool   `json:"draining"`
+	MaxParallel     int    `json:"maxParallel"`
+	RunningTasks    int    `json:"runningTasks"`
+	AvailableSlots  int    `json:"availableSlots"`
+	DroppedLogLines int64  `json:"droppedLogLines"` // See config.LogBackpressure
+	Version         string `json:"version"`
+	Commit          string `json:"commit"`
+	BuildDate       string `json:"buildDate"`
+}
+
+// Result is the body of every POST, and of any error
+type Result struct {
+	Status string `json:"status"`          // "ok" or "error"
+	Error  string `json:"error,omitempty"` // Why the request failed
+}
+
+// Server answers the admin API
+type Server struct {
+	runner Runner
+	token  string // Bearer token required by mutations (empty disables them)
+	now    func() time.Time
+	http   *http.Server
+
+	logs    *livelog.Broadcaster // Running tasks' output for the log viewer (nil unless SetLiveLogs)
+	closing chan struct{}        // Closed on Shutdown to end open log streams
+}
+
+// NewServer creates an admin server for addr, which must be a loopback address (e.g. "127.0.0.1:8082")
+func NewServer(addr, token string, runner Runner) *Server {
+	s := &Server{runner: runner, token: token, now: time.Now, closing: make(chan struct{})}
+	s.http = &http.Server{Addr: addr, Handler: s.Handler(), ReadHeaderTimeout: 5 * time.Second}
+	s.http.RegisterOnShutdown(func() { close(s.closing) })
+	return s
+}
+
+// Handler returns the HTTP handler serving the API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", s.handleViewer)
+	mux.HandleFunc("GET /tasks/{id}/logs", s.handleLogs)
+	mux.HandleFunc("GET /tasks", s.handleTasks)
+	mux.HandleFunc("GET /status", s.handleStatus)
+	mux.HandleFunc("POST /tasks/{id}/cancel", s.authorized(s.handleCancel))
+	mux.HandleFunc("POST /tasks/{id}/kill", s.authorized(s.handleKill))
+	mux.HandleFunc("POST /drain", s.authorized(s.handleDrain))
+	mux.HandleFunc("POST /undrain", s.authorized(s.handleUndrain))
+	return mux
+}
+
+// Start begins serving in the background; bind errors and non-loopback addresses are reported here
+func (s *Server) Start() error {
+	if err := checkLoopback(s.http.Addr); err != nil {
+		return err
+	}
+	ln, err := net.Listen("tcp", s.http.Addr)
+	if err != nil {
+		return err
+	}
+	log.Printf("[ADMIN] Serving the admin API on %s", ln.Addr())
+	go func() {
+		if err := s.http.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("[ADMIN] Server error: %v", err)
+		}
+	}()
+	return nil
+}
+
+// Shutdown stops the server, waiting for in-flight requests until ctx expires
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.http.Shutdown(ctx)
+}
+
+// checkLoopback rejects listen addresses reachable from other hosts
+func checkLoopback(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid admin address %q: %w", addr, err)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("admin address %q is not a loopback address (use e.g. 127.0.0.1:8082)", addr)
+}
+
+// authorized wraps a mutation so it only runs with the configured bearer token
+func (s *Server) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.token == "" {
+			writeError(w, http.StatusForbidden, "mutations are disabled: no admin token configured")
+			return
+		}
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(s.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+			return
+		}
+		log.Printf("[ADMIN] %s %s", r.Method, r.URL.Path)
+		next(w, r)
+	}
+}
+
+// handleTasks lists running and queued tasks
+func (s *Server) handleTasks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, ListTasks(s.runner, s.now()))
+}
+
+// handleStatus reports connection, capacity and build information
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, CurrentStatus(s.runner))
+}
+
+// ListTasks describes the runner's running and queued tasks as of now
+// Shared with the control socket (internal/control), which answers with the same entries
+func ListTasks(runner Runner, now time.Time) []Task {
+	tasks := []Task{}
+	for _, snap := range runner.Tasks() {
+		task := Task{
+			TaskID:      snap.TaskID,
+			State:       snap.State.String(),
+			SubmittedAt: snap.SubmittedAt,
+			Metadata:    snap.Metadata,
+		}
+		if snap.StartedAt.IsZero() {
+			task.QueuedSeconds = seconds(now.Sub(snap.SubmittedAt))
+		} else {
+			startedAt := snap.StartedAt
+			task.StartedAt = &startedAt
+			task.QueuedSeconds = seconds(startedAt.Sub(snap.SubmittedAt))
+			task.RunningSeconds = seconds(now.Sub(startedAt))
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks
+}
+
+// CurrentStatus reports the runner's connection, capacity and build information
+func CurrentStatus(runner Runner) Status {
+	maxParallel, running, available := runner.Capacity()
+	return Status{
+		Connected:       runner.Connected(),
+		Draining:        runner.Draining(),
+		MaxParallel:     maxParallel,
+		RunningTasks:    running,
+		AvailableSlots:  available,
+		DroppedLogLines: runner.DroppedLogLines(),
+		Version:         version.Version,
+		Commit:          version.Commit,
+		BuildDate:       version.BuildDate,
+	}
+}
+
+// handleCancel gracefully cancels a task
+func (s *Server) handleCancel(w http.ResponseWriter, r *http.Request) {
+	s.taskAction(w, r, s.runner.CancelTask)
+}
+
+// handleKill force-kills a task; the response is sent once the task is verified dead
+func (s *Server) handleKill(w http.ResponseWriter, r *http.Request) {
+	s.taskAction(w, r, s.runner.KillTask)
+}
+
+// taskAction runs action on the task named in the path
+func (s *Server) taskAction(w http.ResponseWriter, r *http.Request, action func(taskID int64) error) {
+	taskID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || taskID <= 0 {
+		writeError(w, http.StatusBadRequest, "task id must be a positive integer")
+		return
+	}
+	if err := action(taskID); err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, Result{Status: "ok"})
+}
+
+// handleDrain stops the runner from accepting new tasks
+func (s *Server) handleDrain(w http.ResponseWriter, r *http.Request) {
+	s.runner.Drain()
+	writeJSON(w, http.StatusOK, Result{Status: "ok"})
+}
+
+// handleUndrain lets the runner accept new tasks again
+func (s *Server) handleUndrain(w http.ResponseWriter, r *http.Request) {
+	if err := s.runner.Undrain(); err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, Result{Status: "ok"})
+}
+
+// seconds converts a duration to seconds with millisecond precision
+func seconds(d time.Duration) float64 {
+	return d.Round(time.Millisecond).Seconds()
+}
+
+// writeError answers with an error Result
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, Result{Status: "error", Error: msg})
+}
+
+// writeJSON answers with v as JSON
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
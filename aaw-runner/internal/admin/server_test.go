@@ -0,0 +1,170 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/executor"
+	"github.com/berno/aaw-runner/internal/runner"
+	"github.com/berno/aaw-runner/internal/version"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRunner is a Runner that records the actions taken on it
+type fakeRunner struct {
+	tasks     []executor.TaskSnapshot
+	draining  bool
+	cancelled []int64
+	killed    []int64
+	actionErr error
+	undrain   error
+}
+
+func (f *fakeRunner) Connected() bool                { return true }
+func (f *fakeRunner) Capacity() (int, int, int)      { return 3, 1, 2 }
+func (f *fakeRunner) Draining() bool                 { return f.draining }
+func (f *fakeRunner) Tasks() []executor.TaskSnapshot { return f.tasks }
+func (f *fakeRunner) Drain()                         { f.draining = true }
+func (f *fakeRunner) DroppedLogLines() int64         { return 12 }
+func (f *fakeRunner) CancelTask(taskID int64) error {
+	f.cancelled = append(f.cancelled, taskID)
+	return f.actionErr
+}
+func (f *fakeRunner) KillTask(taskID int64) error {
+	f.killed = append(f.killed, taskID)
+	return f.actionErr
+}
+func (f *fakeRunner) Undrain() error {
+	if f.undrain == nil {
+		f.draining = false
+	}
+	return f.undrain
+}
+
+// do sends a request to the server's handler and decodes the JSON response into out
+func do(t *testing.T, s *Server, method, path, token string, out interface{}) int {
+	t.Helper()
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), out))
+	return rec.Code
+}
+
+// TestTasks_ListsStatesAndDurations verifies queued and running tasks are reported with their timings
+func TestTasks_ListsStatesAndDurations(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	f := &fakeRunner{tasks: []executor.TaskSnapshot{
+		{TaskID: 1, State: runner.TaskStateRunning, SubmittedAt: now.Add(-time.Minute), StartedAt: now.Add(-50 * time.Second),
+			Metadata: map[string]string{"jobId": "7"}},
+		{TaskID: 2, State: runner.TaskStateQueued, SubmittedAt: now.Add(-5 * time.Second)},
+	}}
+	s := NewServer("127.0.0.1:0", "", f)
+	s.now = func() time.Time { return now }
+
+	var tasks []Task
+	assert.Equal(t, http.StatusOK, do(t, s, http.MethodGet, "/tasks", "", &tasks))
+
+	assert.Len(t, tasks, 2)
+	assert.Equal(t, "RUNNING", tasks[0].State)
+	assert.Equal(t, 10.0, tasks[0].QueuedSeconds)
+	assert.Equal(t, 50.0, tasks[0].RunningSeconds)
+	assert.Equal(t, map[string]string{"jobId": "7"}, tasks[0].Metadata)
+	assert.Equal(t, "QUEUED", tasks[1].State)
+	assert.Nil(t, tasks[1].StartedAt)
+	assert.Equal(t, 5.0, tasks[1].QueuedSeconds)
+}
+
+// TestTasks_EmptyList verifies an idle runner reports an empty array rather than null
+func TestTasks_EmptyList(t *testing.T) {
+	s := NewServer("127.0.0.1:0", "", &fakeRunner{})
+
+	var tasks []Task
+	assert.Equal(t, http.StatusOK, do(t, s, http.MethodGet, "/tasks", "", &tasks))
+	assert.NotNil(t, tasks)
+	assert.Empty(t, tasks)
+}
+
+// TestStatus_ReportsCapacityAndVersion verifies GET /status
+func TestStatus_ReportsCapacityAndVersion(t *testing.T) {
+	s := NewServer("127.0.0.1:0", "", &fakeRunner{draining: true})
+
+	var status Status
+	assert.Equal(t, http.StatusOK, do(t, s, http.MethodGet, "/status", "", &status))
+
+	assert.Equal(t, Status{Connected: true, Draining: true, MaxParallel: 3, RunningTasks: 1, AvailableSlots: 2,
+		DroppedLogLines: 12, Version: version.Version, Commit: version.Commit, BuildDate: version.BuildDate}, status)
+}
+
+// TestMutations_RequireToken verifies POSTs are refused without the configured token
+func TestMutations_RequireToken(t *testing.T) {
+	f := &fakeRunner{}
+	var result Result
+
+	s := NewServer("127.0.0.1:0", "", f)
+	assert.Equal(t, http.StatusForbidden, do(t, s, http.MethodPost, "/drain", "anything", &result))
+	assert.Contains(t, result.Error, "no admin token configured")
+
+	s = NewServer("127.0.0.1:0", "s3cret", f)
+	assert.Equal(t, http.StatusUnauthorized, do(t, s, http.MethodPost, "/drain", "", &result))
+	assert.Equal(t, http.StatusUnauthorized, do(t, s, http.MethodPost, "/tasks/4/kill", "wrong", &result))
+	assert.Equal(t, "error", result.Status)
+
+	assert.False(t, f.draining)
+	assert.Empty(t, f.killed)
+}
+
+// TestTaskActions_CancelAndKill verifies cancel and kill reach the runner and surface its errors
+func TestTaskActions_CancelAndKill(t *testing.T) {
+	f := &fakeRunner{}
+	s := NewServer("127.0.0.1:0", "s3cret", f)
+	var result Result
+
+	assert.Equal(t, http.StatusOK, do(t, s, http.MethodPost, "/tasks/4/cancel", "s3cret", &result))
+	assert.Equal(t, Result{Status: "ok"}, result)
+	assert.Equal(t, http.StatusOK, do(t, s, http.MethodPost, "/tasks/5/kill", "s3cret", &result))
+	assert.Equal(t, []int64{4}, f.cancelled)
+	assert.Equal(t, []int64{5}, f.killed)
+
+	f.actionErr = errors.New("task 6 is not running")
+	assert.Equal(t, http.StatusConflict, do(t, s, http.MethodPost, "/tasks/6/cancel", "s3cret", &result))
+	assert.Equal(t, Result{Status: "error", Error: "task 6 is not running"}, result)
+
+	assert.Equal(t, http.StatusBadRequest, do(t, s, http.MethodPost, "/tasks/abc/kill", "s3cret", &result))
+	assert.Equal(t, []int64{5}, f.killed)
+}
+
+// TestDrainAndUndrain verifies the drain toggles and undrain errors
+func TestDrainAndUndrain(t *testing.T) {
+	f := &fakeRunner{}
+	s := NewServer("127.0.0.1:0", "s3cret", f)
+	var result Result
+
+	assert.Equal(t, http.StatusOK, do(t, s, http.MethodPost, "/drain", "s3cret", &result))
+	assert.True(t, f.draining)
+	assert.Equal(t, http.StatusOK, do(t, s, http.MethodPost, "/undrain", "s3cret", &result))
+	assert.False(t, f.draining)
+
+	f.undrain = errors.New("runner is shutting down")
+	assert.Equal(t, http.StatusConflict, do(t, s, http.MethodPost, "/undrain", "s3cret", &result))
+	assert.Equal(t, "runner is shutting down", result.Error)
+}
+
+// TestStart_RequiresLoopback verifies the API refuses to listen beyond localhost
+func TestStart_RequiresLoopback(t *testing.T) {
+	for _, addr := range []string{":8082", "0.0.0.0:8082", "192.0.2.1:8082", "example.com:8082", "8082"} {
+		assert.Error(t, NewServer(addr, "", &fakeRunner{}).Start(), addr)
+	}
+	for _, addr := range []string{"127.0.0.1:8082", "[::1]:8082", "localhost:8082"} {
+		assert.NoError(t, checkLoopback(addr), addr)
+	}
+}
@@ -0,0 +1,98 @@
+package admin
+
+import (
+	_ "embed"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/livelog"
+)
+
+// Live log viewer: a self-contained page at / listing the pool's tasks, and a Server-Sent Events
+// stream of each running task's output at /tasks/{id}/logs, teed from the lines sent to the backend
+
+//go:embed viewer.html
+var viewerPage []byte
+
+// keepAliveInterval is how often an idle log stream sends a comment so proxies and browsers keep it open
+var keepAliveInterval = 15 * time.Second
+
+// SetLiveLogs serves the running tasks' output from b; without it (or with nil) log streams answer 404
+// Must be called before Start.
+func (s *Server) SetLiveLogs(b *livelog.Broadcaster) {
+	s.logs = b
+}
+
+// handleViewer serves the embedded log viewer page
+func (s *Server) handleViewer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'unsafe-inline'; style-src 'unsafe-inline'")
+	w.Write(viewerPage)
+}
+
+// handleLogs streams a running task's output as Server-Sent Events
+// Each line is a message; the stream ends with an "end" event when the task finishes, or a "dropped"
+// event if the viewer fell too far behind.
+func (s *Server) handleLogs(w http.ResponseWriter, r *http.Request) {
+	taskID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || taskID <= 0 {
+		writeError(w, http.StatusBadRequest, "task id must be a positive integer")
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+	sub, ok := s.logs.Subscribe(taskID)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("task %d is not running", taskID))
+		return
+	}
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case line, open := <-sub.Lines():
+			if !open {
+				if sub.Dropped() {
+					fmt.Fprint(w, "event: dropped\ndata: viewer fell behind\n\n")
+				} else {
+					fmt.Fprint(w, "event: end\ndata: task finished\n\n")
+				}
+				flusher.Flush()
+				return
+			}
+			writeEvent(w, line)
+			// Send whatever else is already waiting before flushing
+			for pending := len(sub.Lines()); pending > 0; pending-- {
+				if line, open = <-sub.Lines(); open {
+					writeEvent(w, line)
+				}
+			}
+			flusher.Flush()
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-s.closing:
+			return
+		}
+	}
+}
+
+// writeEvent writes one line as an SSE message (carriage returns would split it)
+func writeEvent(w http.ResponseWriter, line string) {
+	fmt.Fprintf(w, "data: %s\n\n", strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ").Replace(line))
+}
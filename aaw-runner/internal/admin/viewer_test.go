@@ -0,0 +1,67 @@
+package admin
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/berno/aaw-runner/internal/livelog"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestViewer_ServesPage verifies the embedded viewer page is served at the root
+func TestViewer_ServesPage(t *testing.T) {
+	s := NewServer("127.0.0.1:0", "", &fakeRunner{})
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "EventSource")
+	assert.NotContains(t, rec.Body.String(), "https://", "The page must not load external assets")
+}
+
+// TestLogs_StreamsUntilFinished verifies a viewer gets the backlog, then live lines, then an end event
+func TestLogs_StreamsUntilFinished(t *testing.T) {
+	logs := livelog.New(10)
+	logs.Start(5)
+	logs.Publish(5, "hello")
+	s := NewServer("127.0.0.1:0", "", &fakeRunner{})
+	s.SetLiveLogs(logs)
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/tasks/5/logs")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	events := bufio.NewScanner(resp.Body)
+	next := func() string {
+		var lines []string
+		for events.Scan() && events.Text() != "" {
+			lines = append(lines, events.Text())
+		}
+		return strings.Join(lines, "\n")
+	}
+	assert.Equal(t, "data: hello", next())
+	logs.Publish(5, "multi\nline")
+	assert.Equal(t, "data: multi line", next())
+	logs.Finish(5)
+	assert.Equal(t, "event: end\ndata: task finished", next())
+}
+
+// TestLogs_NotStreamable verifies bad ids, tasks without a stream and a viewer without logs are refused
+func TestLogs_NotStreamable(t *testing.T) {
+	s := NewServer("127.0.0.1:0", "", &fakeRunner{})
+	var result Result
+	assert.Equal(t, http.StatusNotFound, do(t, s, http.MethodGet, "/tasks/5/logs", "", &result), "No live logs configured")
+
+	s.SetLiveLogs(livelog.New(10))
+	assert.Equal(t, http.StatusNotFound, do(t, s, http.MethodGet, "/tasks/5/logs", "", &result))
+	assert.Equal(t, "task 5 is not running", result.Error)
+	assert.Equal(t, http.StatusBadRequest, do(t, s, http.MethodGet, "/tasks/x/logs", "", &result))
+}
@@ -0,0 +1,256 @@
+// Package audit records every protocol message exchanged with the backend as JSON lines, so a
+// disputed task can be settled against what the runner actually sent and received
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/logfile"
+	"github.com/berno/aaw-runner/internal/matcher"
+	"github.com/berno/aaw-runner/internal/models"
+)
+
+// FileName is the audit log's name inside the state dir
+const FileName = "audit.jsonl"
+
+// Directions of a recorded message
+const (
+	Inbound  = "in"  // Backend to runner
+	Outbound = "out" // Runner to backend
+)
+
+// queueSize bounds the messages waiting to be written; beyond it messages are dropped
+const queueSize = 4096
+
+// Record is one line of the audit log
+type Record struct {
+	Time      time.Time       `json:"time"`
+	Direction string          `json:"direction"` // Inbound or Outbound
+	Type      string          `json:"type"`      // Message type ("" if the frame could not be read)
+	TaskID    int64           `json:"taskId,omitempty"`
+	Size      int             `json:"size"`    // Bytes on the wire, before masking
+	Payload   json.RawMessage `json:"payload"` // The message with secrets masked (a JSON string if the frame was not JSON)
+}
+
+// pending is a message waiting to be written
+type pending struct {
+	at        time.Time
+	direction string
+	data      []byte
+}
+
+// Writer appends records to a rotating audit file from a background goroutine
+// Recording never blocks: when the queue is full the message is dropped and counted.
+// A nil *Writer records nothing, so callers need not check whether auditing is enabled.
+type Writer struct {
+	file    *logfile.Writer
+	masker  *matcher.SecretMasker
+	queue   chan pending
+	done    chan struct{}
+	dropped atomic.Int64
+
+	mu     sync.RWMutex // Guards closed against concurrent sends on queue
+	closed bool
+}
+
+// Open starts an audit log at FileName in stateDir (created if missing), rotated like the main log file
+// Payloads are masked with masker; a nil masker records them unmasked.
+func Open(stateDir string, maxSizeMB, maxBackups int, masker *matcher.SecretMasker) (*Writer, error) {
+	if err := os.MkdirAll(stateDir, 0o700); err != nil {
+		return nil, err
+	}
+	file, err := logfile.Open(filepath.Join(stateDir, FileName), maxSizeMB, maxBackups)
+	if err != nil {
+		return nil, err
+	}
+	w := &Writer{
+		file:   file,
+		masker: masker,
+		queue:  make(chan pending, queueSize),
+		done:   make(chan struct{}),
+	}
+	go w.run()
+	return w, nil
+}
+
+// Received records an inbound frame; data must not be modified afterwards
+func (w *Writer) Received(data []byte) {
+	w.enqueue(Inbound, data)
+}
+
+// Sent records an outbound message as it was marshalled onto the wire
+func (w *Writer) Sent(v interface{}) {
+	if w == nil {
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	w.enqueue(Outbound, data)
+}
+
+// Dropped returns how many messages were not recorded because the writer fell behind
+func (w *Writer) Dropped() int64 {
+	if w == nil {
+		return 0
+	}
+	return w.dropped.Load()
+}
+
+// Reopen reopens the audit file, for logrotate-style external rotation (SIGHUP)
+func (w *Writer) Reopen() error {
+	if w == nil {
+		return nil
+	}
+	return w.file.Reopen()
+}
+
+// Close writes the messages still queued and closes the file
+func (w *Writer) Close() error {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.queue)
+	w.mu.Unlock()
+
+	<-w.done
+	if n := w.dropped.Load(); n > 0 {
+		log.Printf("[AUDIT] %d message(s) were dropped because the audit log fell behind", n)
+	}
+	return w.file.Close()
+}
+
+// enqueue hands a message to the background writer, dropping it if the queue is full
+func (w *Writer) enqueue(direction string, data []byte) {
+	if w == nil {
+		return
+	}
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return
+	}
+	select {
+	case w.queue <- pending{at: time.Now(), direction: direction, data: data}:
+	default:
+		if w.dropped.Add(1) == 1 {
+			log.Printf("[AUDIT] Audit log is falling behind; dropping messages")
+		}
+	}
+}
+
+// run writes queued messages until Close
+func (w *Writer) run() {
+	defer close(w.done)
+	var buf bytes.Buffer
+	for msg := range w.queue {
+		line, err := json.Marshal(w.record(msg))
+		if err != nil {
+			continue
+		}
+		buf.Reset()
+		buf.Write(line)
+		buf.WriteByte('\n')
+		w.file.Write(buf.Bytes())
+	}
+}
+
+// record builds the audit record of a message, masking its string values
+func (w *Writer) record(msg pending) Record {
+	rec := Record{Time: msg.at, Direction: msg.direction, Size: len(msg.data)}
+
+	decoder := json.NewDecoder(bytes.NewReader(msg.data))
+	decoder.UseNumber() // Keep task IDs exact
+	var payload interface{}
+	if err := decoder.Decode(&payload); err != nil {
+		// Not JSON: keep the frame as a (masked) string so the record still says what arrived
+		rec.Payload, _ = json.Marshal(w.mask(string(msg.data)))
+		return rec
+	}
+
+	if fields, ok := payload.(map[string]interface{}); ok {
+		rec.Type, _ = fields["type"].(string)
+		if id, ok := fields["taskId"].(json.Number); ok {
+			rec.TaskID, _ = id.Int64()
+		}
+		switch rec.Type {
+		case models.TypeExecute:
+			maskVariables(fields)
+			maskEnv(fields)
+		case models.TypeRecurringExecute:
+			if task, ok := fields["task"].(map[string]interface{}); ok {
+				maskEnv(task)
+			}
+		}
+	}
+	rec.Payload, _ = json.Marshal(w.maskValue(payload))
+	return rec
+}
+
+// maskVariables hides the values of an EXECUTE's variables, whether or not masking is enabled,
+// except those named in its publicVariables
+func maskVariables(execute map[string]interface{}) {
+	vars, ok := execute["variables"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	public := make(map[string]bool)
+	names, _ := execute["publicVariables"].([]interface{})
+	for _, name := range names {
+		if name, ok := name.(string); ok {
+			public[name] = true
+		}
+	}
+	for name := range vars {
+		if !public[name] {
+			vars[name] = matcher.MaskReplacement
+		}
+	}
+}
+
+// maskEnv hides the values of an EXECUTE's environment variables, whether or not masking is enabled
+func maskEnv(execute map[string]interface{}) {
+	env, _ := execute["env"].(map[string]interface{})
+	for name := range env {
+		env[name] = matcher.MaskReplacement
+	}
+}
+
+// maskValue masks every string inside a decoded JSON value
+func (w *Writer) maskValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		return w.mask(v)
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = w.maskValue(value)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = w.maskValue(value)
+		}
+	}
+	return v
+}
+
+// mask redacts secrets from s when masking is enabled
+func (w *Writer) mask(s string) string {
+	if w.masker == nil {
+		return s
+	}
+	return w.masker.Mask(s)
+}
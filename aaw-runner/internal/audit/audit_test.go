@@ -0,0 +1,176 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/matcher"
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// readFile reads back the audit log written to dir
+func readFile(t *testing.T, dir string) ([]Record, error) {
+	t.Helper()
+	f, err := os.Open(filepath.Join(dir, FileName))
+	if !assert.NoError(t, err) {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// TestWriter_RecordsBothDirections verifies messages are written in order with their type, task, size and masked payload
+func TestWriter_RecordsBothDirections(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "state")
+	w, err := Open(dir, 1, 1, matcher.NewSecretMasker())
+	assert.NoError(t, err)
+
+	execute := []byte(`{"type":"EXECUTE","taskId":9007199254740993,"scriptContent":"export API_KEY=abc123def"}`)
+	w.Received(execute)
+	logMsg := models.NewLogMessage(9007199254740993, "token: s3cr3tvalue", false)
+	w.Sent(&logMsg)
+	assert.NoError(t, w.Close())
+
+	records, err := readFile(t, dir)
+	assert.NoError(t, err)
+	if !assert.Len(t, records, 2) {
+		return
+	}
+
+	assert.Equal(t, Inbound, records[0].Direction)
+	assert.Equal(t, models.TypeExecute, records[0].Type)
+	assert.Equal(t, int64(9007199254740993), records[0].TaskID, "Task IDs beyond float64 precision are kept")
+	assert.Equal(t, len(execute), records[0].Size)
+	assert.Contains(t, string(records[0].Payload), "API_KEY=***")
+	assert.NotContains(t, string(records[0].Payload), "abc123def")
+
+	assert.Equal(t, Outbound, records[1].Direction)
+	assert.Equal(t, models.TypeLog, records[1].Type)
+	assert.Contains(t, string(records[1].Payload), "token: ***")
+	assert.False(t, records[1].Time.Before(records[0].Time))
+
+	info, err := os.Stat(dir)
+	if assert.NoError(t, err) {
+		assert.Equal(t, os.FileMode(0o700), info.Mode().Perm(), "State dir is created private")
+	}
+}
+
+// TestWriter_DropsWhenFull verifies a stalled writer drops and counts messages instead of blocking
+func TestWriter_DropsWhenFull(t *testing.T) {
+	// No background goroutine drains this queue, as if the disk had stalled
+	w := &Writer{queue: make(chan pending, 2)}
+
+	for i := 0; i < 5; i++ {
+		w.Received([]byte(`{"type":"CANCEL_TASK","taskId":1}`))
+	}
+	assert.Equal(t, int64(3), w.Dropped())
+
+	var disabled *Writer
+	disabled.Received([]byte(`{}`))
+	disabled.Sent(struct{}{})
+	assert.Zero(t, disabled.Dropped())
+	assert.NoError(t, disabled.Close())
+}
+
+// TestWriter_MalformedFrame verifies a frame that is not JSON is still recorded, as a string
+func TestWriter_MalformedFrame(t *testing.T) {
+	dir := t.TempDir()
+	w, err := Open(dir, 1, 1, nil)
+	assert.NoError(t, err)
+	w.Received([]byte("not json"))
+	assert.NoError(t, w.Close())
+
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"payload":"not json"`)
+
+	_, err = readFile(t, dir)
+	assert.ErrorContains(t, err, `line 1: unknown message type ""`)
+}
+
+// TestWriter_MasksVariables verifies EXECUTE variable values are hidden even without a masker, unless public
+func TestWriter_MasksVariables(t *testing.T) {
+	dir := t.TempDir()
+	w, err := Open(dir, 1, 1, nil)
+	assert.NoError(t, err)
+	w.Received([]byte(`{"type":"EXECUTE","taskId":1,"scriptContent":"{{repo}} {{pat}}","variables":{"repo":"berno/aaw","pat":"ghp_abc"},"publicVariables":["repo"]}`))
+	assert.NoError(t, w.Close())
+
+	records, err := readFile(t, dir)
+	assert.NoError(t, err)
+	if assert.Len(t, records, 1) {
+		assert.Contains(t, string(records[0].Payload), `"variables":{"pat":"***","repo":"berno/aaw"}`)
+	}
+}
+
+// TestWriter_MasksEnv verifies the values of an EXECUTE's environment variables are hidden even without a
+// masker, including in a recurring task, while their names are kept
+func TestWriter_MasksEnv(t *testing.T) {
+	dir := t.TempDir()
+	w, err := Open(dir, 1, 1, nil)
+	assert.NoError(t, err)
+	w.Received([]byte(`{"type":"EXECUTE","taskId":1,"scriptContent":"deploy","env":{"BRANCH":"main","API_TOKEN":"s3cret"}}`))
+	w.Received([]byte(`{"type":"RECURRING_EXECUTE","recurrenceId":"r1","intervalSeconds":60,"task":{"type":"EXECUTE","taskId":2,"scriptContent":"deploy","env":{"API_TOKEN":"s3cret"}}}`))
+	assert.NoError(t, w.Close())
+
+	records, err := readFile(t, dir)
+	assert.NoError(t, err)
+	if assert.Len(t, records, 2) {
+		assert.Contains(t, string(records[0].Payload), `"env":{"API_TOKEN":"***","BRANCH":"***"}`)
+		assert.Contains(t, string(records[1].Payload), `"env":{"API_TOKEN":"***"}`)
+	}
+}
+
+// TestRead_Validates verifies the reader rejects lines that are not records of known messages
+func TestRead_Validates(t *testing.T) {
+	valid := `{"time":"2026-10-15T10:00:00Z","direction":"out","type":"BYE","size":2,"payload":{"type":"BYE","drained":true}}`
+	cases := map[string]string{
+		`{"time":"2026-10-15T10:00:00Z","direction":"sideways","type":"BYE","payload":{}}`:             "invalid direction",
+		`{"direction":"out","type":"BYE","payload":{}}`:                                                "missing time",
+		`{"time":"2026-10-15T10:00:00Z","direction":"in","type":"REBOOT","payload":{}}`:                "unknown message type",
+		`{"time":"2026-10-15T10:00:00Z","direction":"in","type":"KILL_TASK","payload":{"taskId":"x"}}`: "KILL_TASK payload",
+		`garbage`: "invalid character",
+	}
+
+	records, err := Read(strings.NewReader(valid + "\n" + valid + "\n"))
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+
+	for line, want := range cases {
+		records, err := Read(strings.NewReader(valid + "\n" + line + "\n"))
+		assert.ErrorContains(t, err, "line 2: "+want, line)
+		assert.Len(t, records, 1)
+	}
+}
+
+// TestTerminationLog_Appends verifies attempts are appended across reopens in a private file, and a nil log records nothing
+func TestTerminationLog_Appends(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "state")
+	at := time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)
+
+	for _, by := range []string{"alice", models.RequestedByShutdown} {
+		l, err := OpenTerminationLog(dir)
+		assert.NoError(t, err)
+		assert.NoError(t, l.Record(Termination{TaskID: 7, Type: "CANCEL", RequestedBy: by, Outcome: OutcomeOK, RequestedAt: at, CompletedAt: at}))
+		assert.NoError(t, l.Close())
+	}
+
+	path := filepath.Join(dir, TerminationFileName)
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	data, _ := os.ReadFile(path)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if assert.Len(t, lines, 2) {
+		assert.JSONEq(t, `{"taskId":7,"type":"CANCEL","requestedBy":"alice","outcome":"OK","requestedAt":"2026-10-15T09:00:00Z","completedAt":"2026-10-15T09:00:00Z"}`, lines[0])
+		assert.Contains(t, lines[1], `"requestedBy":"runner:shutdown"`)
+	}
+
+	var disabled *TerminationLog
+	assert.NoError(t, disabled.Record(Termination{TaskID: 1}))
+	assert.NoError(t, disabled.Close())
+}
@@ -0,0 +1,65 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/berno/aaw-runner/internal/schema"
+)
+
+// maxLineBytes bounds one audit line when reading (EXECUTE payloads carry whole scripts)
+const maxLineBytes = 16 * 1024 * 1024
+
+// Read parses an audit log, checking that every line is a record of a known protocol message
+// The first invalid line is reported with its line number.
+func Read(r io.Reader) ([]Record, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineBytes)
+
+	var records []Record
+	for line := 1; scanner.Scan(); line++ {
+		rec, err := parseRecord(scanner.Bytes())
+		if err != nil {
+			return records, fmt.Errorf("line %d: %w", line, err)
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
+
+// parseRecord decodes one audit line and its payload
+func parseRecord(line []byte) (Record, error) {
+	var rec Record
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return rec, err
+	}
+	if rec.Direction != Inbound && rec.Direction != Outbound {
+		return rec, fmt.Errorf("invalid direction %q", rec.Direction)
+	}
+	if rec.Time.IsZero() {
+		return rec, fmt.Errorf("missing time")
+	}
+
+	msg, ok := knownMessage(rec.Type)
+	if !ok {
+		return rec, fmt.Errorf("unknown message type %q", rec.Type)
+	}
+	value := reflect.New(reflect.TypeOf(msg.Value)).Interface()
+	if err := json.Unmarshal(rec.Payload, value); err != nil {
+		return rec, fmt.Errorf("%s payload: %w", rec.Type, err)
+	}
+	return rec, nil
+}
+
+// knownMessage looks up a message type in the protocol's message list
+func knownMessage(msgType string) (schema.Message, bool) {
+	for _, msg := range schema.Messages {
+		if msg.Type == msgType {
+			return msg, true
+		}
+	}
+	return schema.Message{}, false
+}
@@ -0,0 +1,74 @@
+package audit
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// TerminationFileName is the termination audit trail's name inside the state dir
+const TerminationFileName = "terminations.jsonl"
+
+// Outcomes of a termination attempt
+const (
+	OutcomeOK     = "OK"
+	OutcomeFailed = "FAILED"
+)
+
+// Termination is one cancel or kill attempt, whoever asked for it
+type Termination struct {
+	TaskID      int64     `json:"taskId"`
+	Type        string    `json:"type"`        // "CANCEL" or "KILL"
+	RequestedBy string    `json:"requestedBy"` // Backend user or service, or a synthetic "runner:*" requester
+	Reason      string    `json:"reason,omitempty"`
+	Outcome     string    `json:"outcome"` // OutcomeOK or OutcomeFailed
+	Error       string    `json:"error,omitempty"`
+	RequestedAt time.Time `json:"requestedAt"`
+	CompletedAt time.Time `json:"completedAt"`
+}
+
+// TerminationLog is an append-only file of termination attempts, never rotated
+// Attempts are rare, so each is written synchronously. A nil *TerminationLog records nothing.
+type TerminationLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// OpenTerminationLog opens (or creates) the termination audit trail at TerminationFileName in stateDir
+func OpenTerminationLog(stateDir string) (*TerminationLog, error) {
+	if err := os.MkdirAll(stateDir, 0o700); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(filepath.Join(stateDir, TerminationFileName), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &TerminationLog{file: file}, nil
+}
+
+// Record appends one attempt as a JSON line
+func (l *TerminationLog) Record(t Termination) error {
+	if l == nil {
+		return nil
+	}
+	line, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.file.Write(append(line, '\n'))
+	return err
+}
+
+// Close closes the file
+func (l *TerminationLog) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
@@ -0,0 +1,203 @@
+// Package checkout prepares a git checkout as a task's workspace before the task runs
+// Each repository is fetched into a bare cache under the state dir, shared by every task using it,
+// and each task gets a detached worktree of the requested ref, removed when the task ends.
+package checkout
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DirName is the checkout directory inside the state dir, holding "cache" and "workspaces"
+const DirName = "git"
+
+// Timeout bounds fetching and checking out one task's ref
+const Timeout = 10 * time.Minute
+
+// Environment variables describing the checkout to the task
+const (
+	EnvPath   = "AAW_CHECKOUT_PATH"   // The worktree the task runs in
+	EnvCommit = "AAW_CHECKOUT_COMMIT" // The full SHA checked out
+)
+
+// Workspace is a task's checkout
+type Workspace struct {
+	Path   string // Worktree directory
+	Commit string // Commit SHA the ref resolved to
+
+	cache string // Bare repository the worktree belongs to
+}
+
+// Env returns the environment variables describing the workspace
+func (w Workspace) Env() []string {
+	return []string{EnvPath + "=" + w.Path, EnvCommit + "=" + w.Commit}
+}
+
+// Manager checks out repositories for tasks
+type Manager struct {
+	dir string
+	env []string // Added to git's environment: credentials and no prompting
+
+	mu         sync.Mutex
+	repos      map[string]*sync.Mutex // One fetch at a time per cache, since they share FETCH_HEAD
+	workspaces map[int64]Workspace
+}
+
+// NewManager returns a manager keeping caches and workspaces under dir
+// sshKey is the private key used for SSH repository URLs and credentialHelper the git credential
+// helper used for HTTPS ones, e.g. a script printing a token; either may be empty for git's defaults.
+func NewManager(dir, sshKey, credentialHelper string) *Manager {
+	env := []string{"GIT_TERMINAL_PROMPT=0"}
+	if sshKey != "" {
+		env = append(env, "GIT_SSH_COMMAND=ssh -i "+sshKey+" -o IdentitiesOnly=yes -o BatchMode=yes")
+	}
+	if credentialHelper != "" {
+		env = append(env, "GIT_CONFIG_COUNT=1", "GIT_CONFIG_KEY_0=credential.helper", "GIT_CONFIG_VALUE_0="+credentialHelper)
+	}
+	return &Manager{
+		dir:        dir,
+		env:        env,
+		repos:      make(map[string]*sync.Mutex),
+		workspaces: make(map[int64]Workspace),
+	}
+}
+
+// Checkout fetches ref of repo into its cache and checks it out in a new worktree for a task
+// ref may be a branch, a tag or a commit SHA, and defaults to the remote HEAD; depth > 0 makes
+// the fetch shallow.
+func (m *Manager) Checkout(ctx context.Context, taskID int64, repo, ref string, depth int) (Workspace, error) {
+	if ref == "" {
+		ref = "HEAD"
+	}
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	cache := m.cachePath(repo)
+	lock := m.repoLock(cache)
+	lock.Lock()
+	defer lock.Unlock()
+
+	for _, dir := range []string{filepath.Dir(cache), filepath.Dir(m.workspacePath(taskID))} {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return Workspace{}, err
+		}
+	}
+	if _, err := os.Stat(cache); errors.Is(err, os.ErrNotExist) {
+		if err := m.git(ctx, "", "init", "--quiet", "--bare", cache); err != nil {
+			return Workspace{}, err
+		}
+	}
+	fetch := []string{"fetch", "--quiet", "--no-tags"}
+	if depth > 0 {
+		fetch = append(fetch, "--depth", strconv.Itoa(depth))
+	}
+	if err := m.git(ctx, cache, append(fetch, "--", repo, ref)...); err != nil {
+		return Workspace{}, err
+	}
+	commit, err := m.output(ctx, cache, "rev-parse", "--verify", "FETCH_HEAD^{commit}")
+	if err != nil {
+		return Workspace{}, err
+	}
+
+	ws := Workspace{Path: m.workspacePath(taskID), Commit: commit, cache: cache}
+	os.RemoveAll(ws.Path) // Left over from a runner that stopped before cleaning up
+	if err := m.git(ctx, cache, "worktree", "prune"); err != nil {
+		return Workspace{}, err
+	}
+	if err := m.git(ctx, cache, "worktree", "add", "--quiet", "--detach", ws.Path, commit); err != nil {
+		return Workspace{}, err
+	}
+
+	m.mu.Lock()
+	m.workspaces[taskID] = ws
+	m.mu.Unlock()
+	return ws, nil
+}
+
+// Lookup returns a task's workspace, if it has one
+func (m *Manager) Lookup(taskID int64) (Workspace, bool) {
+	if m == nil {
+		return Workspace{}, false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ws, ok := m.workspaces[taskID]
+	return ws, ok
+}
+
+// Remove deletes a task's worktree; tasks without one are ignored
+func (m *Manager) Remove(taskID int64) error {
+	ws, ok := m.Lookup(taskID)
+	if !ok {
+		return nil
+	}
+	m.mu.Lock()
+	delete(m.workspaces, taskID)
+	m.mu.Unlock()
+
+	lock := m.repoLock(ws.cache)
+	lock.Lock()
+	defer lock.Unlock()
+	err := m.git(context.Background(), ws.cache, "worktree", "remove", "--force", ws.Path)
+	if rmErr := os.RemoveAll(ws.Path); err == nil {
+		err = rmErr
+	}
+	return err
+}
+
+// cachePath is the bare repository caching repo, named after a hash of its URL
+func (m *Manager) cachePath(repo string) string {
+	sum := sha256.Sum256([]byte(repo))
+	return filepath.Join(m.dir, "cache", hex.EncodeToString(sum[:8])+".git")
+}
+
+// workspacePath is the worktree of a task
+func (m *Manager) workspacePath(taskID int64) string {
+	return filepath.Join(m.dir, "workspaces", fmt.Sprintf("task-%d", taskID))
+}
+
+func (m *Manager) repoLock(cache string) *sync.Mutex {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	lock, ok := m.repos[cache]
+	if !ok {
+		lock = &sync.Mutex{}
+		m.repos[cache] = lock
+	}
+	return lock
+}
+
+// git runs a git command in dir (the current directory when empty)
+func (m *Manager) git(ctx context.Context, dir string, args ...string) error {
+	_, err := m.output(ctx, dir, args...)
+	return err
+}
+
+// output runs a git command in dir and returns its trimmed stdout
+// Failures carry git's stderr, which says what went wrong far better than the exit status.
+func (m *Manager) output(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), m.env...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,106 @@
+package checkout
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fixture creates a repository with two commits on main, the first tagged v1, and returns its path and commits
+func fixture(t *testing.T) (string, []string) {
+	t.Helper()
+	dir := t.TempDir()
+	run := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=aaw", "-c", "user.email=aaw@example.com"}, args...)...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		assert.NoError(t, err, string(out))
+		return strings.TrimSpace(string(out))
+	}
+	run("init", "--quiet", "--initial-branch", "main")
+	var commits []string
+	for _, content := range []string{"one", "two"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "file.txt"), []byte(content), 0o644))
+		run("add", "file.txt")
+		run("commit", "--quiet", "-m", content)
+		commits = append(commits, run("rev-parse", "HEAD"))
+	}
+	run("tag", "v1", commits[0])
+	return dir, commits
+}
+
+// TestCheckout_Refs verifies branches, tags, SHAs and the default HEAD are checked out detached in the task's worktree
+func TestCheckout_Refs(t *testing.T) {
+	repo, commits := fixture(t)
+	tests := []struct {
+		ref     string
+		depth   int
+		commit  string
+		content string
+	}{
+		{ref: "", commit: commits[1], content: "two"},
+		{ref: "main", commit: commits[1], content: "two"},
+		{ref: "v1", commit: commits[0], content: "one"},
+		{ref: commits[0], commit: commits[0], content: "one"},
+		{ref: "main", depth: 1, commit: commits[1], content: "two"},
+	}
+	m := NewManager(t.TempDir(), "", "")
+	for i, tt := range tests {
+		taskID := int64(i + 1)
+		ws, err := m.Checkout(context.Background(), taskID, repo, tt.ref, tt.depth)
+		if !assert.NoError(t, err, "ref %q", tt.ref) {
+			continue
+		}
+		assert.Equal(t, tt.commit, ws.Commit)
+		data, err := os.ReadFile(filepath.Join(ws.Path, "file.txt"))
+		assert.NoError(t, err)
+		assert.Equal(t, tt.content, string(data), "ref %q", tt.ref)
+		assert.Equal(t, []string{EnvPath + "=" + ws.Path, EnvCommit + "=" + tt.commit}, ws.Env())
+
+		found, ok := m.Lookup(taskID)
+		assert.True(t, ok)
+		assert.Equal(t, ws, found)
+	}
+
+	caches, err := os.ReadDir(filepath.Join(m.dir, "cache"))
+	assert.NoError(t, err)
+	assert.Len(t, caches, 1, "Every task shares the repository's cache")
+}
+
+// TestCheckout_Errors verifies unknown refs and unreachable repositories fail with git's explanation
+func TestCheckout_Errors(t *testing.T) {
+	repo, _ := fixture(t)
+	m := NewManager(t.TempDir(), "", "")
+
+	_, err := m.Checkout(context.Background(), 1, repo, "no-such-branch", 0)
+	assert.ErrorContains(t, err, "no-such-branch")
+	_, err = m.Checkout(context.Background(), 2, filepath.Join(t.TempDir(), "missing"), "", 0)
+	assert.ErrorContains(t, err, "git fetch:")
+
+	_, ok := m.Lookup(1)
+	assert.False(t, ok)
+	assert.NoError(t, m.Remove(1), "Tasks without a workspace have nothing to remove")
+}
+
+// TestRemove verifies the worktree is deleted and unregistered from the cache
+func TestRemove(t *testing.T) {
+	repo, _ := fixture(t)
+	m := NewManager(t.TempDir(), "", "")
+	ws, err := m.Checkout(context.Background(), 1, repo, "main", 0)
+	assert.NoError(t, err)
+
+	assert.NoError(t, m.Remove(1))
+	_, err = os.Stat(ws.Path)
+	assert.True(t, os.IsNotExist(err))
+	_, ok := m.Lookup(1)
+	assert.False(t, ok)
+
+	out, err := exec.Command("git", "-C", ws.cache, "worktree", "list").Output()
+	assert.NoError(t, err)
+	assert.NotContains(t, string(out), ws.Path)
+}
@@ -0,0 +1,177 @@
+// Package claudecli probes the claude CLI that dynamic tasks run
+package claudecli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// probeTimeout bounds "claude --version" so a hung binary cannot stall startup
+var probeTimeout = 3 * time.Second
+
+// versionPattern extracts the version from "claude --version" output (e.g. "1.0.35 (Claude Code)")
+var versionPattern = regexp.MustCompile(`\d+\.\d+(?:\.\d+)?(?:[-+][0-9A-Za-z.-]+)?`)
+
+// authEnv are the environment variables that give claude credentials or select a cloud provider
+var authEnv = []string{"ANTHROPIC_API_KEY", "ANTHROPIC_AUTH_TOKEN", "CLAUDE_CODE_OAUTH_TOKEN",
+	"CLAUDE_CODE_USE_BEDROCK", "CLAUDE_CODE_USE_VERTEX"}
+
+// Status is what the last probe found out about the claude CLI
+type Status struct {
+	Present   bool      // Binary resolved and "claude --version" succeeded
+	Path      string    // Resolved binary (empty when not found)
+	Version   string    // Parsed version (empty when it could not be parsed)
+	AuthOK    bool      // Credentials appear to be configured
+	Err       error     // Why the binary is unusable (nil when Present)
+	CheckedAt time.Time // Zero until the first probe
+}
+
+// Probed reports whether a probe has run yet
+func (s Status) Probed() bool {
+	return !s.CheckedAt.IsZero()
+}
+
+// Prober resolves and inspects the claude binary, remembering the latest result
+type Prober struct {
+	binary string
+
+	// System access, faked in tests
+	lookPath func(file string) (string, error)
+	command  func(ctx context.Context, path string, args ...string) ([]byte, error)
+	getenv   func(key string) string
+	stat     func(name string) (os.FileInfo, error)
+
+	mu     sync.RWMutex
+	status Status
+}
+
+// NewProber creates a prober for binary (a name looked up on PATH, or a path)
+func NewProber(binary string, getenv func(string) string) *Prober {
+	return &Prober{
+		binary:   binary,
+		lookPath: exec.LookPath,
+		command:  runCommand,
+		getenv:   getenv,
+		stat:     os.Stat,
+	}
+}
+
+// runCommand runs a command and returns its standard output
+func runCommand(ctx context.Context, path string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.WaitDelay = time.Second // Do not wait on children that inherited stdout after a timeout kill
+	return cmd.Output()
+}
+
+// Status returns the result of the latest probe (zero CheckedAt before the first)
+func (p *Prober) Status() Status {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.status
+}
+
+// Probe inspects the binary now, records the result and logs it when it changed
+func (p *Prober) Probe() Status {
+	status := p.inspect()
+
+	p.mu.Lock()
+	prev := p.status
+	p.status = status
+	p.mu.Unlock()
+
+	if prev.Probed() && prev.Present == status.Present && prev.Version == status.Version && prev.AuthOK == status.AuthOK {
+		return status
+	}
+	switch {
+	case !status.Present:
+		log.Printf("[CLAUDE] %s unavailable, dynamic tasks will be refused: %v", p.binary, status.Err)
+	case status.Version == "":
+		log.Printf("[CLAUDE] Found %s (version unknown), auth configured: %v", status.Path, status.AuthOK)
+	default:
+		log.Printf("[CLAUDE] Found %s version %s, auth configured: %v", status.Path, status.Version, status.AuthOK)
+	}
+	if status.Present && !status.AuthOK {
+		log.Printf("[CLAUDE] No credentials found (API key, OAuth token or login); tasks may fail to authenticate")
+	}
+	return status
+}
+
+// Watch re-probes every interval while the binary is unavailable, until stop is closed
+// A probe that finds it again lets dynamic tasks through without a restart
+func (p *Prober) Watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if status := p.Status(); status.Probed() && !status.Present {
+				p.Probe()
+			}
+		}
+	}
+}
+
+// inspect resolves the binary, asks it for its version and checks for credentials
+func (p *Prober) inspect() Status {
+	status := Status{CheckedAt: time.Now(), AuthOK: p.authConfigured()}
+
+	path, err := p.lookPath(p.binary)
+	if err != nil {
+		status.Err = fmt.Errorf("not found: %w", err)
+		return status
+	}
+	status.Path = path
+
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	out, err := p.command(ctx, path, "--version")
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		status.Err = fmt.Errorf("%s --version did not answer within %s", path, probeTimeout)
+		return status
+	}
+	if err != nil {
+		status.Err = fmt.Errorf("%s --version failed: %w", path, err)
+		return status
+	}
+
+	status.Present = true
+	status.Version = ParseVersion(string(out))
+	return status
+}
+
+// ParseVersion extracts the version number from "claude --version" output, or "" if there is none
+func ParseVersion(output string) string {
+	return versionPattern.FindString(strings.TrimSpace(output))
+}
+
+// authConfigured reports whether claude appears to have credentials: an API key, OAuth token or
+// cloud provider in the environment, or a login stored in its config directory
+func (p *Prober) authConfigured() bool {
+	for _, key := range authEnv {
+		if p.getenv(key) != "" {
+			return true
+		}
+	}
+
+	dir := p.getenv("CLAUDE_CONFIG_DIR")
+	if dir == "" {
+		home := p.getenv("HOME")
+		if home == "" {
+			return false
+		}
+		dir = filepath.Join(home, ".claude")
+	}
+	_, err := p.stat(filepath.Join(dir, ".credentials.json"))
+	return err == nil
+}
@@ -0,0 +1,129 @@
+package claudecli
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeProber builds a prober whose binary lookup, --version output and environment are fixed
+func fakeProber(found bool, version string, versionErr error, env map[string]string) *Prober {
+	p := NewProber("claude", func(key string) string { return env[key] })
+	p.lookPath = func(file string) (string, error) {
+		if !found {
+			return "", errors.New(`exec: "claude": executable file not found in $PATH`)
+		}
+		return "/usr/local/bin/" + file, nil
+	}
+	p.command = func(context.Context, string, ...string) ([]byte, error) { return []byte(version), versionErr }
+	p.stat = func(name string) (os.FileInfo, error) {
+		if name == "/home/runner/.claude/.credentials.json" {
+			return nil, nil
+		}
+		return nil, os.ErrNotExist
+	}
+	return p
+}
+
+// TestProbe_Present verifies a working binary reports its path and parsed version
+func TestProbe_Present(t *testing.T) {
+	p := fakeProber(true, "1.0.35 (Claude Code)\n", nil, map[string]string{"ANTHROPIC_API_KEY": "sk-test"})
+	assert.False(t, p.Status().Probed())
+
+	status := p.Probe()
+
+	assert.True(t, status.Present)
+	assert.NoError(t, status.Err)
+	assert.Equal(t, "/usr/local/bin/claude", status.Path)
+	assert.Equal(t, "1.0.35", status.Version)
+	assert.True(t, status.AuthOK)
+	assert.Equal(t, status, p.Status())
+}
+
+// TestProbe_Missing verifies a binary that cannot be resolved is reported unavailable
+func TestProbe_Missing(t *testing.T) {
+	status := fakeProber(false, "", nil, nil).Probe()
+
+	assert.True(t, status.Probed())
+	assert.False(t, status.Present)
+	assert.ErrorContains(t, status.Err, "executable file not found")
+}
+
+// TestProbe_VersionFails verifies a binary that cannot report its version is unavailable
+func TestProbe_VersionFails(t *testing.T) {
+	status := fakeProber(true, "", errors.New("exit status 1"), nil).Probe()
+
+	assert.False(t, status.Present)
+	assert.Equal(t, "/usr/local/bin/claude", status.Path)
+	assert.ErrorContains(t, status.Err, "--version failed: exit status 1")
+}
+
+// TestProbe_Timeout verifies a hung --version is cut off by the probe timeout
+func TestProbe_Timeout(t *testing.T) {
+	orig := probeTimeout
+	probeTimeout = 50 * time.Millisecond
+	defer func() { probeTimeout = orig }()
+	p := fakeProber(true, "", nil, nil)
+	p.command = func(ctx context.Context, _ string, _ ...string) ([]byte, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	status := p.Probe()
+
+	assert.False(t, status.Present)
+	assert.ErrorContains(t, status.Err, "did not answer within 50ms")
+}
+
+// TestProbe_Auth verifies credentials are detected from the environment or a stored login
+func TestProbe_Auth(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want bool
+	}{
+		{"none", map[string]string{"HOME": "/home/other"}, false},
+		{"api key", map[string]string{"ANTHROPIC_API_KEY": "sk-test"}, true},
+		{"oauth token", map[string]string{"CLAUDE_CODE_OAUTH_TOKEN": "token"}, true},
+		{"bedrock", map[string]string{"CLAUDE_CODE_USE_BEDROCK": "1"}, true},
+		{"stored login", map[string]string{"HOME": "/home/runner"}, true},
+		{"config dir override", map[string]string{"HOME": "/home/runner", "CLAUDE_CONFIG_DIR": "/etc/claude"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, fakeProber(true, "1.0.0", nil, tt.env).Probe().AuthOK)
+		})
+	}
+}
+
+// TestParseVersion verifies version extraction from --version output
+func TestParseVersion(t *testing.T) {
+	assert.Equal(t, "1.0.35", ParseVersion("1.0.35 (Claude Code)"))
+	assert.Equal(t, "2.1.0-beta.3", ParseVersion("claude 2.1.0-beta.3\n"))
+	assert.Equal(t, "", ParseVersion("Claude Code"))
+}
+
+// TestWatch_ReprobesWhileMissing verifies a missing binary is picked up once it appears
+func TestWatch_ReprobesWhileMissing(t *testing.T) {
+	var installed atomic.Bool
+	p := fakeProber(true, "1.0.35", nil, nil)
+	p.lookPath = func(file string) (string, error) {
+		if !installed.Load() {
+			return "", errors.New("not found")
+		}
+		return "/usr/local/bin/" + file, nil
+	}
+	assert.False(t, p.Probe().Present)
+	stop := make(chan struct{})
+	defer close(stop)
+	go p.Watch(10*time.Millisecond, stop)
+
+	installed.Store(true)
+
+	assert.Eventually(t, func() bool { return p.Status().Present }, 2*time.Second, 10*time.Millisecond)
+}
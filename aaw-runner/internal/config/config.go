@@ -0,0 +1,829 @@
+// Package config resolves the runner's settings from flags, environment variables, a YAML config file and defaults
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/runner"
+	"github.com/berno/aaw-runner/internal/syslog"
+)
+
+// Default values for settings without an obvious zero value
+const (
+	DefaultBackendURL         = "ws://localhost:8080/ws/logs"
+	DefaultLogLevel           = LogLevelDebug
+	DefaultRateLimitCooldown  = 30 * time.Second
+	DefaultUsageLimitCooldown = 1 * time.Hour
+	DefaultLogMaxSizeMB       = 100
+	DefaultLogMaxBackups      = 5
+	DefaultShutdownGraceSecs  = 30
+	DefaultClaudePath         = "claude"
+	DefaultStatsdPrefix       = "aaw.runner"
+	DefaultControlSocketMode  = 0660 // Owner and group may use the control socket
+	DefaultLogS3Endpoint      = "https://s3.amazonaws.com"
+	DefaultOrphanAfter        = 10 * time.Minute
+	DefaultSessionTTL         = 24 * time.Hour
+	DefaultStallThreshold     = 10 * time.Minute
+	DefaultProgressFlush      = time.Second
+	DefaultMaxTaskOutputBytes = 1 << 30
+	DefaultOfflineBufferLines = 10000
+	DefaultMaxMessageBytes    = 4 << 20
+	DefaultDeliveryJournalMax = 1000
+	DefaultSlowLogSampleEvery = 10
+	DefaultStderrTailLines    = 20
+	DefaultPTYCols            = 120
+	DefaultPTYRows            = 40
+
+	DefaultConnectTimeout       = 10 * time.Second
+	DefaultHeloAckTimeout       = 3 * time.Second
+	DefaultAuthChallengeTimeout = 3 * time.Second
+	DefaultReconnectBaseBackoff = 1 * time.Second
+	DefaultReconnectMaxBackoff  = 1 * time.Minute
+	DefaultPingInterval         = 30 * time.Second
+	DefaultPongTimeout          = 10 * time.Second
+	DefaultHeartbeatInterval    = 30 * time.Second
+	DefaultSlowWriteThreshold   = 2 * time.Second
+	DefaultWSCompressionLevel   = 1 // flate.BestSpeed: most of the saving on log text for little CPU
+	DefaultWSSubprotocols       = "aaw.v1"
+	DefaultAllowedInterpreters  = "bash,claude" // Those tasks ran under before EXECUTE could name one
+)
+
+// Log targets accepted by --log-target
+const (
+	LogTargetStderr = "stderr" // The console
+	LogTargetSyslog = "syslog" // The local syslog socket (journald included), falling back to stderr
+)
+
+// Orphan policies accepted by --orphan-policy: what happens to running tasks while the backend is unreachable
+const (
+	OrphanContinue    = "continue"     // Tasks keep running
+	OrphanCancelAfter = "cancel-after" // Tasks are cancelled after --orphan-after, their completions held until reconnected
+	OrphanPauseAfter  = "pause-after"  // Tasks are stopped (SIGSTOP) after --orphan-after until reconnected
+)
+
+// Backpressure policies accepted by --log-backpressure: what happens to task output lines once sending
+// falls behind and the output queue is full. Status updates and completions are never dropped.
+const (
+	BackpressureDropNewest = "drop-newest" // New lines are dropped until there is room again
+	BackpressureDropOldest = "drop-oldest" // The oldest queued lines give way to new ones
+	BackpressureBlock      = "block"       // Reading the task's output waits, which slows a chatty task down
+)
+
+// Catch-up policies accepted by --recurring-catch-up: what happens to recurring task ticks that fall
+// while the runner is disconnected, draining or stopped
+const (
+	CatchUpSkip    = "skip"     // Missed ticks are dropped; the next tick runs as scheduled
+	CatchUpRunOnce = "run-once" // One instance runs as soon as possible, however many ticks were missed
+)
+
+// Behaviours accepted by --template-missing-key for a {{name}} placeholder with no value in EXECUTE's variables
+const (
+	MissingKeyError = "error" // The task fails with INVALID_TASK
+	MissingKeyEmpty = "empty" // The placeholder expands to nothing
+)
+
+// Log levels accepted by --log-level
+const (
+	LogLevelDebug = "debug" // Standard log plus per-line [DEBUG] stream traces
+	LogLevelInfo  = "info"  // Standard log only
+)
+
+// Config is the fully resolved runner configuration
+type Config struct {
+	ConfigFile string // YAML file the settings below were read from (empty when none)
+
+	BackendURL  string // WebSocket endpoint of the backend
+	MaxParallel int    // Maximum number of concurrently running tasks
+	LogLevel    string // LogLevelDebug or LogLevelInfo
+	LogFile     string // Also write the log to this file, rotated by size (empty disables)
+	LogMaxSize  int    // Rotate the log file at this many MB
+	LogBackups  int    // Rotated log files to keep
+	LogStdout   bool   // Keep logging to the console when a log file is set
+	LogTarget   string // Where console logging goes: LogTargetStderr or LogTargetSyslog
+	TUI         bool   // Show a live status dashboard in the terminal instead of the console log
+	Workdir     string // Directory the runner works in (empty keeps the current directory)
+	StateDir    string // Directory for runner state that outlives a process (created on demand)
+	ClaudePath  string // claude binary that runs dynamic tasks (a name looked up on PATH, or a path)
+	HealthAddr  string // Listen address for /healthz and /readyz (empty disables the endpoint)
+	AdminAddr   string // Loopback listen address for the operator API (empty disables it)
+	AdminToken  string // Bearer token required by operator API mutations (empty disables them)
+
+	RunnerIDFile string // File keeping the runner's ID across restarts (empty: runner-id in StateDir)
+
+	ProxyURL string // http:// (CONNECT) or socks5:// proxy the backend is dialled through (empty: HTTPS_PROXY/HTTP_PROXY/NO_PROXY)
+
+	TLSCAFile     string // PEM file of extra root CAs trusted for a wss:// backend (empty: system roots only)
+	TLSInsecure   bool   // Skip verifying the backend's certificate (testing only)
+	TLSServerName string // Server name sent and verified instead of the backend URL's host (empty: the URL's host)
+	TLSClientCert string // PEM client certificate identifying the runner to a backend requiring mTLS (with TLSClientKey)
+	TLSClientKey  string // PEM private key of TLSClientCert
+
+	RunnerSecret         string        // Shared secret the runner signs the backend's AUTH_CHALLENGE with (empty: none)
+	RunnerSecretFile     string        // File holding the shared secret instead of RunnerSecret, read at startup
+	AuthChallengeTimeout time.Duration // How long Connect waits for AUTH_CHALLENGE, then AUTH_OK, when a secret is set
+
+	SSHHostsFile string // YAML file of the SSH hosts EXECUTE's "host" may name (empty disables remote execution)
+
+	TemplateMissingKey string // MissingKeyError or MissingKeyEmpty
+
+	GitSSHKey           string // Private key for fetching EXECUTE "repo" URLs over SSH (empty: ssh's defaults)
+	GitCredentialHelper string // git credential helper for HTTPS "repo" URLs, e.g. a token script (empty: git's defaults)
+
+	SyslogFacility   string // Facility of syslog messages (with LogTargetSyslog)
+	SyslogTaskOutput bool   // Also send log lines echoing task output to syslog
+
+	AuditLog        bool // Record every protocol message in audit.jsonl under StateDir
+	AuditMaxSize    int  // Rotate the audit log at this many MB
+	AuditMaxBackups int  // Rotated audit logs to keep
+
+	TerminationAudit bool // Record every cancel and kill attempt in terminations.jsonl under StateDir
+
+	DeliveryJournal    bool // Keep TASK_COMPLETED and final STATUS_UPDATE in delivery.jsonl under StateDir until ACKed
+	DeliveryJournalMax int  // Unacknowledged messages the journal keeps; the oldest gives way
+
+	LogS3Endpoint string // S3-compatible endpoint that task logs are uploaded to
+	LogS3Bucket   string // Bucket for task logs (empty disables keeping and uploading them)
+	LogS3Prefix   string // Key prefix of uploaded task logs
+	LogS3Gzip     bool   // Compress task logs before uploading
+
+	ControlSocket     string      // Unix socket serving the operator API to aawctl (empty disables it)
+	ControlSocketMode os.FileMode // Permissions of the control socket
+
+	StatusFile string // JSON status document rewritten every few seconds for file-based monitors (empty disables it)
+
+	OTelEndpoint string // OTLP/HTTP collector that receives task lifecycle spans (empty disables tracing)
+
+	CompletionWebhookURL    string // Receives a POST for every finished task (empty disables it)
+	CompletionWebhookSecret string // Key for the webhook's HMAC-SHA256 signature header (empty sends unsigned)
+
+	StatsdAddr   string // UDP address of a StatsD/DogStatsD agent that receives metrics (empty disables them)
+	StatsdPrefix string // Prepended to every metric name
+	StatsdTags   string // DogStatsD tags added to every metric ("key:value,...")
+
+	ShutdownGraceSeconds int // How long running tasks may finish after SIGTERM before they are cancelled
+
+	ConnectTimeout       time.Duration // Limit on dialling the backend and completing the websocket handshake, per attempt
+	HeloAckTimeout       time.Duration // How long Connect waits for HELO_ACK before treating the backend as legacy
+	ReconnectBaseBackoff time.Duration // Wait before the first redial once the connection drops; doubles with each failed attempt
+	ReconnectMaxBackoff  time.Duration // Longest wait between redials
+	MaxReconnectAttempts int           // Failed redials in a row before the runner gives up and exits (0 retries forever)
+	ConnectMaxRetries    int           // Retries of a failed first connect before the runner gives up and exits (0 retries forever)
+	PingInterval         time.Duration // How often the backend is pinged to check the connection is alive
+	PongTimeout          time.Duration // How late a pong may be before the connection is treated as dead
+	HeartbeatInterval    time.Duration // How often RUNNER_HEARTBEAT reports uptime, capacity and load while connected
+	WSCompression        bool          // Offer permessage-deflate to the backend
+	WSCompressionLevel   int           // flate level of compressed messages, -2 (Huffman only) to 9 (with WSCompression)
+	WSSubprotocols       string        // Comma-separated subprotocols requested on the upgrade, in order of preference (empty requests none)
+	AllowNoSubprotocol   bool          // Accept a backend that selects none of WSSubprotocols rather than refusing it
+
+	OrphanPolicy string        // OrphanContinue, OrphanCancelAfter or OrphanPauseAfter
+	OrphanAfter  time.Duration // How long the backend may be unreachable before the orphan policy acts
+
+	OfflineBufferLines int // Task output lines kept while the backend is unreachable and sent once it is back (0 keeps none)
+
+	LogBackpressure string // BackpressureDropNewest, BackpressureDropOldest or BackpressureBlock
+
+	SlowWriteThreshold time.Duration // p95 write latency from which the backend is treated as slow and LOG lines are sampled
+	SlowLogSampleEvery int           // While the backend is slow, one LOG line in this many is sent per task
+
+	RecurringCatchUp string // CatchUpSkip or CatchUpRunOnce
+
+	RealtimeStreaming      bool   // Character-level output streaming for lower latency
+	SecretMasking          bool   // Redact credentials from task output
+	FilterSpinners         bool   // Drop output lines made only of spinner glyphs and cursor-control escapes
+	SeverityClassification bool   // Tag streamed output lines with a severity
+	SeverityRulesFile      string // Optional JSON file with custom severity rules
+	MatcherPatternsFile    string // Optional JSON file extending/replacing detection patterns
+	StderrTailLines        int    // Last stderr lines of a failed task sent in TASK_COMPLETED's errorDetail (0 sends none)
+	MaxTaskOutputBytes     int    // Bytes of output lines a task may send before the rest is discarded (0 for no cap)
+	AllowedInterpreters    string // Comma-separated interpreters an EXECUTE may name, looked up in PATH (claude is ClaudePath)
+	AllowedModels          string // Comma-separated claude models an EXECUTE may name; empty allows any
+	ForcePTY               bool   // Run every local task on a pseudo-terminal, as if its EXECUTE set pty
+	PTYCols, PTYRows       int    // Window size of task pseudo-terminals
+
+	RateLimitCooldown  time.Duration // Global backoff after a rate limit detection
+	UsageLimitCooldown time.Duration // Backoff after a usage limit whose reset time is unknown
+	SessionTTL         time.Duration // How long the claude session of a PERSIST sessionKey is kept after its last task
+	StallThreshold     time.Duration // How long a task may print nothing before it is reported STALLED
+	ProgressFlush      time.Duration // Least time between LOG lines of one line a task redraws with carriage returns
+
+	ValidateOutgoing  bool // Validate outbound messages and log violations
+	RejectNewerSchema bool // Answer newer-schema messages with MESSAGE_ERROR
+	MaxErrorBytes     int  // Byte limit for outbound error strings
+	MaxLineBytes      int  // Byte limit for outbound LOG lines
+	MaxMessageBytes   int  // Byte limit for inbound messages; a larger one drops the connection
+}
+
+// Debug reports whether per-line debug traces should be printed
+func (c Config) Debug() bool {
+	return c.LogLevel == LogLevelDebug
+}
+
+// ShutdownGrace returns the shutdown grace period as a duration
+func (c Config) ShutdownGrace() time.Duration {
+	return time.Duration(c.ShutdownGraceSeconds) * time.Second
+}
+
+// RunnerIDPath returns the file the runner's ID is kept in
+func (c Config) RunnerIDPath() string {
+	if c.RunnerIDFile != "" {
+		return c.RunnerIDFile
+	}
+	return filepath.Join(c.StateDir, "runner-id")
+}
+
+// Subprotocols returns WSSubprotocols as a list, without blanks
+func (c Config) Subprotocols() []string {
+	return splitList(c.WSSubprotocols)
+}
+
+// Interpreters returns AllowedInterpreters as a list, without blanks
+func (c Config) Interpreters() []string {
+	return splitList(c.AllowedInterpreters)
+}
+
+// Models returns AllowedModels as a list, without blanks; empty allows any model
+func (c Config) Models() []string {
+	return splitList(c.AllowedModels)
+}
+
+// splitList splits a comma-separated setting, dropping blanks
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// FieldLimits returns the outbound free-text limits
+func (c Config) FieldLimits() models.FieldLimits {
+	return models.FieldLimits{Error: c.MaxErrorBytes, Line: c.MaxLineBytes}
+}
+
+// Default returns the configuration used when nothing is set
+func Default() Config {
+	stateDir := ".aaw-runner"
+	if home, err := os.UserHomeDir(); err == nil {
+		stateDir = filepath.Join(home, ".aaw-runner")
+	}
+
+	return Config{
+		BackendURL:             DefaultBackendURL,
+		MaxParallel:            runner.DefaultMaxParallel,
+		LogLevel:               DefaultLogLevel,
+		LogMaxSize:             DefaultLogMaxSizeMB,
+		LogBackups:             DefaultLogMaxBackups,
+		LogStdout:              true,
+		LogTarget:              LogTargetStderr,
+		SyslogFacility:         syslog.DefaultFacility,
+		StateDir:               stateDir,
+		ClaudePath:             DefaultClaudePath,
+		TemplateMissingKey:     MissingKeyError,
+		AuditMaxSize:           DefaultLogMaxSizeMB,
+		AuditMaxBackups:        DefaultLogMaxBackups,
+		TerminationAudit:       true,
+		DeliveryJournalMax:     DefaultDeliveryJournalMax,
+		StatsdPrefix:           DefaultStatsdPrefix,
+		ControlSocketMode:      DefaultControlSocketMode,
+		LogS3Endpoint:          DefaultLogS3Endpoint,
+		ShutdownGraceSeconds:   DefaultShutdownGraceSecs,
+		ConnectTimeout:         DefaultConnectTimeout,
+		HeloAckTimeout:         DefaultHeloAckTimeout,
+		AuthChallengeTimeout:   DefaultAuthChallengeTimeout,
+		ReconnectBaseBackoff:   DefaultReconnectBaseBackoff,
+		ReconnectMaxBackoff:    DefaultReconnectMaxBackoff,
+		PingInterval:           DefaultPingInterval,
+		PongTimeout:            DefaultPongTimeout,
+		HeartbeatInterval:      DefaultHeartbeatInterval,
+		WSCompressionLevel:     DefaultWSCompressionLevel,
+		WSSubprotocols:         DefaultWSSubprotocols,
+		OrphanPolicy:           OrphanContinue,
+		OrphanAfter:            DefaultOrphanAfter,
+		OfflineBufferLines:     DefaultOfflineBufferLines,
+		LogBackpressure:        BackpressureDropNewest,
+		SlowWriteThreshold:     DefaultSlowWriteThreshold,
+		SlowLogSampleEvery:     DefaultSlowLogSampleEvery,
+		RecurringCatchUp:       CatchUpSkip,
+		SecretMasking:          true,
+		FilterSpinners:         true,
+		SeverityClassification: true,
+		StderrTailLines:        DefaultStderrTailLines,
+		MaxTaskOutputBytes:     DefaultMaxTaskOutputBytes,
+		AllowedInterpreters:    DefaultAllowedInterpreters,
+		PTYCols:                DefaultPTYCols,
+		PTYRows:                DefaultPTYRows,
+		RateLimitCooldown:      DefaultRateLimitCooldown,
+		UsageLimitCooldown:     DefaultUsageLimitCooldown,
+		SessionTTL:             DefaultSessionTTL,
+		StallThreshold:         DefaultStallThreshold,
+		ProgressFlush:          DefaultProgressFlush,
+		MaxErrorBytes:          models.DefaultMaxErrorBytes,
+		MaxLineBytes:           models.DefaultMaxLineBytes,
+		MaxMessageBytes:        DefaultMaxMessageBytes,
+	}
+}
+
+// option binds one setting to its flag and environment variables
+type option struct {
+	flag  string
+	env   []string // Checked in order; the first non-empty one wins
+	usage string
+	value func(c *Config) flag.Value
+}
+
+// secret reports whether the setting is a secret, which has no flag
+func (o option) secret() bool {
+	_, ok := o.value(&Config{}).(*secretValue)
+	return ok
+}
+
+// options lists every setting, in --help order
+var options = []option{
+	{"config", []string{"AAW_CONFIG_FILE"}, "YAML config file; its keys are these flag names",
+		func(c *Config) flag.Value { return (*stringValue)(&c.ConfigFile) }},
+	{"backend-url", []string{"AAW_BACKEND_URL", "AAW_SERVER_URL"}, "WebSocket URL of the backend",
+		func(c *Config) flag.Value { return (*stringValue)(&c.BackendURL) }},
+	{"proxy-url", []string{"AAW_PROXY_URL"}, "http:// (CONNECT) or socks5:// proxy to reach the backend through, with user:password@ for proxy auth; overrides HTTPS_PROXY/HTTP_PROXY",
+		func(c *Config) flag.Value { return (*stringValue)(&c.ProxyURL) }},
+	{"tls-ca-file", []string{"AAW_TLS_CA_FILE"}, "PEM file of extra root CAs to trust for a wss:// backend, e.g. an internal CA",
+		func(c *Config) flag.Value { return (*stringValue)(&c.TLSCAFile) }},
+	{"tls-insecure", []string{"AAW_TLS_INSECURE"}, "skip verifying the backend's TLS certificate (testing only)",
+		func(c *Config) flag.Value { return (*boolValue)(&c.TLSInsecure) }},
+	{"tls-server-name", []string{"AAW_TLS_SERVER_NAME"}, "server name (SNI) to send and verify instead of the backend URL's host",
+		func(c *Config) flag.Value { return (*stringValue)(&c.TLSServerName) }},
+	{"tls-client-cert", []string{"AAW_TLS_CLIENT_CERT"}, "PEM client certificate presented to a backend requiring mTLS; re-read on every reconnect",
+		func(c *Config) flag.Value { return (*stringValue)(&c.TLSClientCert) }},
+	{"tls-client-key", []string{"AAW_TLS_CLIENT_KEY"}, "PEM private key of --tls-client-cert",
+		func(c *Config) flag.Value { return (*stringValue)(&c.TLSClientKey) }},
+	{"runner-secret", []string{"AAW_RUNNER_SECRET"}, "shared secret answering the backend's AUTH_CHALLENGE with an HMAC-SHA256 of its nonce",
+		func(c *Config) flag.Value { return (*secretValue)(&c.RunnerSecret) }},
+	{"runner-secret-file", []string{"AAW_RUNNER_SECRET_FILE"}, "file holding the shared secret, instead of AAW_RUNNER_SECRET (surrounding whitespace is ignored)",
+		func(c *Config) flag.Value { return (*stringValue)(&c.RunnerSecretFile) }},
+	{"auth-challenge-timeout", []string{"AAW_AUTH_CHALLENGE_TIMEOUT"}, "how long a runner with a secret waits after HELO for AUTH_CHALLENGE before carrying on unauthenticated, and then for AUTH_OK",
+		func(c *Config) flag.Value { return (*positiveDurationValue)(&c.AuthChallengeTimeout) }},
+	{"max-parallel", []string{"AAW_MAX_PARALLEL_TASKS"}, "maximum number of concurrently running tasks",
+		func(c *Config) flag.Value { return (*positiveIntValue)(&c.MaxParallel) }},
+	{"log-level", []string{"AAW_LOG_LEVEL"}, `"debug" (adds per-line stream traces) or "info"`,
+		func(c *Config) flag.Value { return (*logLevelValue)(&c.LogLevel) }},
+	{"log-file", []string{"AAW_LOG_FILE"}, "also write the log to this file, rotated by size; reopened on SIGHUP",
+		func(c *Config) flag.Value { return (*stringValue)(&c.LogFile) }},
+	{"log-max-size-mb", []string{"AAW_LOG_MAX_SIZE_MB"}, "rotate the log file when it reaches this many MB",
+		func(c *Config) flag.Value { return (*positiveIntValue)(&c.LogMaxSize) }},
+	{"log-max-backups", []string{"AAW_LOG_MAX_BACKUPS"}, "number of rotated log files to keep",
+		func(c *Config) flag.Value { return (*nonNegativeIntValue)(&c.LogBackups) }},
+	{"log-stdout", []string{"AAW_LOG_STDOUT"}, "keep logging to the console when --log-file is set",
+		func(c *Config) flag.Value { return (*boolValue)(&c.LogStdout) }},
+	{"log-target", []string{"AAW_LOG_TARGET"}, `console log destination: "stderr" or "syslog" (the local syslog/journald socket)`,
+		func(c *Config) flag.Value { return (*logTargetValue)(&c.LogTarget) }},
+	{"syslog-facility", []string{"AAW_SYSLOG_FACILITY"}, `syslog facility with --log-target=syslog, e.g. "daemon" or "local0"`,
+		func(c *Config) flag.Value { return (*syslogFacilityValue)(&c.SyslogFacility) }},
+	{"syslog-task-output", []string{"AAW_SYSLOG_TASK_OUTPUT"}, "also send log lines echoing task output to syslog",
+		func(c *Config) flag.Value { return (*boolValue)(&c.SyslogTaskOutput) }},
+	{"tui", []string{"AAW_TUI"}, "show a live status dashboard in the terminal (plain status lines when not a terminal)",
+		func(c *Config) flag.Value { return (*boolValue)(&c.TUI) }},
+	{"workdir", []string{"AAW_WORKDIR"}, "directory to run from (default: current directory)",
+		func(c *Config) flag.Value { return (*stringValue)(&c.Workdir) }},
+	{"state-dir", []string{"AAW_STATE_DIR"}, "directory for persisted runner state",
+		func(c *Config) flag.Value { return (*stringValue)(&c.StateDir) }},
+	{"runner-id-file", []string{"AAW_RUNNER_ID_FILE"}, "file the runner's ID is kept in across restarts (default: runner-id in the state dir)",
+		func(c *Config) flag.Value { return (*stringValue)(&c.RunnerIDFile) }},
+	{"claude-path", []string{"AAW_CLAUDE_PATH"}, "claude binary that runs dynamic tasks, looked up on PATH unless it contains a slash",
+		func(c *Config) flag.Value { return (*stringValue)(&c.ClaudePath) }},
+	{"ssh-hosts-file", []string{"AAW_SSH_HOSTS_FILE"}, `YAML file of SSH hosts that tasks may run on with EXECUTE "host" (default: off)`,
+		func(c *Config) flag.Value { return (*stringValue)(&c.SSHHostsFile) }},
+	{"template-missing-key", []string{"AAW_TEMPLATE_MISSING_KEY"}, `what a scriptContent {{name}} placeholder missing from EXECUTE "variables" does: "error" fails the task, "empty" expands to nothing`,
+		func(c *Config) flag.Value { return (*missingKeyValue)(&c.TemplateMissingKey) }},
+	{"git-ssh-key", []string{"AAW_GIT_SSH_KEY"}, `private key used to fetch EXECUTE "repo" URLs over SSH`,
+		func(c *Config) flag.Value { return (*stringValue)(&c.GitSSHKey) }},
+	{"git-credential-helper", []string{"AAW_GIT_CREDENTIAL_HELPER"}, `git credential helper used to fetch EXECUTE "repo" URLs over HTTPS, e.g. "!/etc/aaw/git-token.sh"`,
+		func(c *Config) flag.Value { return (*stringValue)(&c.GitCredentialHelper) }},
+	{"health-addr", []string{"AAW_HEALTH_ADDR"}, "address for the /healthz and /readyz HTTP endpoint, e.g. :8081 (default: off)",
+		func(c *Config) flag.Value { return (*stringValue)(&c.HealthAddr) }},
+	{"admin-addr", []string{"AAW_ADMIN_ADDR"}, "loopback address for the operator API, e.g. 127.0.0.1:8082 (default: off)",
+		func(c *Config) flag.Value { return (*stringValue)(&c.AdminAddr) }},
+	{"admin-token", []string{"AAW_ADMIN_TOKEN"}, "bearer token required by operator API mutations (default: mutations off)",
+		func(c *Config) flag.Value { return (*secretValue)(&c.AdminToken) }},
+	{"audit-log", []string{"AAW_AUDIT_LOG"}, "record every protocol message, secrets masked, in audit.jsonl under --state-dir",
+		func(c *Config) flag.Value { return (*boolValue)(&c.AuditLog) }},
+	{"audit-max-size-mb", []string{"AAW_AUDIT_MAX_SIZE_MB"}, "rotate the audit log when it reaches this many MB",
+		func(c *Config) flag.Value { return (*positiveIntValue)(&c.AuditMaxSize) }},
+	{"audit-max-backups", []string{"AAW_AUDIT_MAX_BACKUPS"}, "number of rotated audit logs to keep",
+		func(c *Config) flag.Value { return (*nonNegativeIntValue)(&c.AuditMaxBackups) }},
+	{"termination-audit", []string{"AAW_TERMINATION_AUDIT"}, "record every cancel and kill attempt, with who asked and why, in terminations.jsonl under --state-dir",
+		func(c *Config) flag.Value { return (*boolValue)(&c.TerminationAudit) }},
+	{"delivery-journal", []string{"AAW_DELIVERY_JOURNAL"}, "keep TASK_COMPLETED and final STATUS_UPDATE in delivery.jsonl under --state-dir and re-send them on every connect until the backend ACKs them",
+		func(c *Config) flag.Value { return (*boolValue)(&c.DeliveryJournal) }},
+	{"delivery-journal-max", []string{"AAW_DELIVERY_JOURNAL_MAX"}, "unacknowledged messages the delivery journal keeps before dropping the oldest",
+		func(c *Config) flag.Value { return (*positiveIntValue)(&c.DeliveryJournalMax) }},
+	{"log-s3-endpoint", []string{"AAW_LOG_S3_ENDPOINT"}, "S3-compatible endpoint for task log uploads, e.g. http://minio:9000",
+		func(c *Config) flag.Value { return (*stringValue)(&c.LogS3Endpoint) }},
+	{"log-s3-bucket", []string{"AAW_LOG_S3_BUCKET"}, "keep each task's output under --state-dir and upload it to this bucket when the task ends (default: off)",
+		func(c *Config) flag.Value { return (*stringValue)(&c.LogS3Bucket) }},
+	{"log-s3-prefix", []string{"AAW_LOG_S3_PREFIX"}, "key prefix for uploaded task logs (keys are prefix/<date>/<taskID>.log)",
+		func(c *Config) flag.Value { return (*stringValue)(&c.LogS3Prefix) }},
+	{"log-s3-gzip", []string{"AAW_LOG_S3_GZIP"}, "gzip task logs before uploading",
+		func(c *Config) flag.Value { return (*boolValue)(&c.LogS3Gzip) }},
+	{"control-socket", []string{"AAW_CONTROL_SOCKET"}, "Unix socket for aawctl, e.g. /run/aaw-runner.sock (default: off)",
+		func(c *Config) flag.Value { return (*stringValue)(&c.ControlSocket) }},
+	{"control-socket-mode", []string{"AAW_CONTROL_SOCKET_MODE"}, "octal permissions of the control socket",
+		func(c *Config) flag.Value { return (*fileModeValue)(&c.ControlSocketMode) }},
+	{"status-file", []string{"AAW_STATUS_FILE"}, "keep a JSON status document (connection, capacity, tasks, last error) at this path (default: off)",
+		func(c *Config) flag.Value { return (*stringValue)(&c.StatusFile) }},
+	{"otel-endpoint", []string{"AAW_OTEL_ENDPOINT"}, "OTLP/HTTP collector URL for task lifecycle traces, e.g. http://otel-collector:4318 (default: off)",
+		func(c *Config) flag.Value { return (*stringValue)(&c.OTelEndpoint) }},
+	{"completion-webhook-url", []string{"AAW_COMPLETION_WEBHOOK_URL"}, "URL that receives a JSON POST for every finished task (default: off)",
+		func(c *Config) flag.Value { return (*stringValue)(&c.CompletionWebhookURL) }},
+	{"completion-webhook-secret", []string{"AAW_COMPLETION_WEBHOOK_SECRET"}, "sign completion webhooks with an HMAC-SHA256 of the body in X-AAW-Signature",
+		func(c *Config) flag.Value { return (*secretValue)(&c.CompletionWebhookSecret) }},
+	{"statsd-addr", []string{"AAW_STATSD_ADDR"}, "UDP address of a StatsD/DogStatsD agent to push metrics to, e.g. 127.0.0.1:8125 (default: off)",
+		func(c *Config) flag.Value { return (*stringValue)(&c.StatsdAddr) }},
+	{"statsd-prefix", []string{"AAW_STATSD_PREFIX"}, "prefix for StatsD metric names",
+		func(c *Config) flag.Value { return (*stringValue)(&c.StatsdPrefix) }},
+	{"statsd-tags", []string{"AAW_STATSD_TAGS"}, `DogStatsD tags added to every metric, e.g. "env:prod,team:infra"`,
+		func(c *Config) flag.Value { return (*stringValue)(&c.StatsdTags) }},
+	{"shutdown-grace-seconds", []string{"AAW_SHUTDOWN_GRACE_SECONDS"}, "seconds running tasks may finish after SIGTERM before they are cancelled",
+		func(c *Config) flag.Value { return (*nonNegativeIntValue)(&c.ShutdownGraceSeconds) }},
+	{"connect-timeout", []string{"AAW_CONNECT_TIMEOUT"}, "limit on dialling the backend and completing the websocket handshake, for the first connect and each redial",
+		func(c *Config) flag.Value { return (*positiveDurationValue)(&c.ConnectTimeout) }},
+	{"helo-ack-timeout", []string{"AAW_HELO_ACK_TIMEOUT"}, "how long to wait for the backend's HELO_ACK after connecting; a backend that does not answer gets the legacy message format",
+		func(c *Config) flag.Value { return (*positiveDurationValue)(&c.HeloAckTimeout) }},
+	{"reconnect-base-backoff", []string{"AAW_RECONNECT_BASE_BACKOFF"}, "wait before redialling the backend after the connection drops, doubled (with jitter) after each failed attempt",
+		func(c *Config) flag.Value { return (*positiveDurationValue)(&c.ReconnectBaseBackoff) }},
+	{"reconnect-max-backoff", []string{"AAW_RECONNECT_MAX_BACKOFF"}, "longest wait between redials of the backend",
+		func(c *Config) flag.Value { return (*positiveDurationValue)(&c.ReconnectMaxBackoff) }},
+	{"max-reconnect-attempts", []string{"AAW_MAX_RECONNECT_ATTEMPTS"}, "failed redials in a row before the runner exits (0 retries forever)",
+		func(c *Config) flag.Value { return (*nonNegativeIntValue)(&c.MaxReconnectAttempts) }},
+	{"connect-max-retries", []string{"AAW_CONNECT_MAX_RETRIES"}, "retries of a failed connect to the backend on startup before the runner exits (0 retries forever)",
+		func(c *Config) flag.Value { return (*nonNegativeIntValue)(&c.ConnectMaxRetries) }},
+	{"ping-interval", []string{"AAW_PING_INTERVAL"}, "how often the backend is sent a websocket ping to check the connection is alive",
+		func(c *Config) flag.Value { return (*positiveDurationValue)(&c.PingInterval) }},
+	{"pong-timeout", []string{"AAW_PONG_TIMEOUT"}, "how long after a ping is due its pong may take before the connection is treated as dead and redialled",
+		func(c *Config) flag.Value { return (*positiveDurationValue)(&c.PongTimeout) }},
+	{"heartbeat-interval", []string{"AAW_HEARTBEAT_INTERVAL"}, "how often the backend is sent RUNNER_HEARTBEAT with the runner's uptime, capacity and host load",
+		func(c *Config) flag.Value { return (*positiveDurationValue)(&c.HeartbeatInterval) }},
+	{"ws-compression", []string{"AAW_WS_COMPRESSION"}, "offer permessage-deflate to the backend, compressing messages if it accepts",
+		func(c *Config) flag.Value { return (*boolValue)(&c.WSCompression) }},
+	{"ws-compression-level", []string{"AAW_WS_COMPRESSION_LEVEL"}, "flate level of compressed messages, from -2 (Huffman only) and 1 (fastest) to 9 (smallest)",
+		func(c *Config) flag.Value { return (*compressionLevelValue)(&c.WSCompressionLevel) }},
+	{"ws-subprotocols", []string{"AAW_WS_SUBPROTOCOLS"}, "comma-separated Sec-WebSocket-Protocol values to request, in order of preference; the backend must select one (empty requests none)",
+		func(c *Config) flag.Value { return (*stringValue)(&c.WSSubprotocols) }},
+	{"allow-no-subprotocol", []string{"AAW_ALLOW_NO_SUBPROTOCOL"}, "connect to a backend that selects no subprotocol instead of refusing it",
+		func(c *Config) flag.Value { return (*boolValue)(&c.AllowNoSubprotocol) }},
+	{"orphan-policy", []string{"AAW_ORPHAN_POLICY"}, `what happens to running tasks while the backend is unreachable: "continue", "cancel-after" or "pause-after" (--orphan-after)`,
+		func(c *Config) flag.Value { return (*orphanPolicyValue)(&c.OrphanPolicy) }},
+	{"orphan-after", []string{"AAW_ORPHAN_AFTER"}, "how long the backend may be unreachable before the orphan policy cancels or pauses running tasks",
+		func(c *Config) flag.Value { return (*positiveDurationValue)(&c.OrphanAfter) }},
+	{"offline-buffer-lines", []string{"AAW_OFFLINE_BUFFER_LINES"}, "task output lines kept while the backend is unreachable and sent after reconnecting; the oldest give way first (0 keeps none)",
+		func(c *Config) flag.Value { return (*nonNegativeIntValue)(&c.OfflineBufferLines) }},
+	{"log-backpressure", []string{"AAW_LOG_BACKPRESSURE"}, `what happens to task output lines when sending falls behind: "drop-newest", "drop-oldest" or "block" (status updates and completions are never dropped)`,
+		func(c *Config) flag.Value { return (*backpressureValue)(&c.LogBackpressure) }},
+	{"slow-write-threshold", []string{"AAW_SLOW_WRITE_THRESHOLD"}, "95th percentile write latency from which the backend is treated as slow, and task output is sampled until it drops below half of it",
+		func(c *Config) flag.Value { return (*positiveDurationValue)(&c.SlowWriteThreshold) }},
+	{"slow-log-sample-every", []string{"AAW_SLOW_LOG_SAMPLE_EVERY"}, "while the backend is slow, send one task output line in this many; the others are summarized and can be fetched with RESUME_LOGS",
+		func(c *Config) flag.Value { return (*positiveIntValue)(&c.SlowLogSampleEvery) }},
+	{"recurring-catch-up", []string{"AAW_RECURRING_CATCH_UP"}, `what happens to RECURRING_EXECUTE ticks missed while disconnected, draining or stopped: "skip" or "run-once"`,
+		func(c *Config) flag.Value { return (*catchUpValue)(&c.RecurringCatchUp) }},
+	{"realtime-streaming", []string{"AAW_REALTIME_STREAMING"}, "stream output character by character for lower latency",
+		func(c *Config) flag.Value { return (*boolValue)(&c.RealtimeStreaming) }},
+	{"secret-masking", []string{"AAW_SECRET_MASKING"}, "redact credentials from task output",
+		func(c *Config) flag.Value { return (*boolValue)(&c.SecretMasking) }},
+	{"severity-classification", []string{"AAW_SEVERITY_CLASSIFICATION"}, "tag output lines with a severity",
+		func(c *Config) flag.Value { return (*boolValue)(&c.SeverityClassification) }},
+	{"severity-rules-file", []string{"AAW_SEVERITY_RULES_FILE"}, "JSON file with custom severity rules",
+		func(c *Config) flag.Value { return (*stringValue)(&c.SeverityRulesFile) }},
+	{"matcher-patterns-file", []string{"AAW_MATCHER_PATTERNS_FILE"}, "JSON file extending/replacing detection patterns",
+		func(c *Config) flag.Value { return (*stringValue)(&c.MatcherPatternsFile) }},
+	{"stderr-tail-lines", []string{"AAW_STDERR_TAIL_LINES"}, "last stderr lines of a failed task sent with its completion as errorDetail (0 sends none)",
+		func(c *Config) flag.Value { return (*nonNegativeIntValue)(&c.StderrTailLines) }},
+	{"max-task-output-bytes", []string{"AAW_MAX_TASK_OUTPUT_BYTES"}, "bytes of output lines a task may send before the rest is discarded, though still read (0 for no cap; an EXECUTE's maxOutputBytes overrides it)",
+		func(c *Config) flag.Value { return (*nonNegativeIntValue)(&c.MaxTaskOutputBytes) }},
+	{"allowed-interpreters", []string{"AAW_ALLOWED_INTERPRETERS"}, "comma-separated interpreters an EXECUTE may name (e.g. bash,sh,zsh,python3,claude); tasks naming none run under bash or claude",
+		func(c *Config) flag.Value { return (*stringValue)(&c.AllowedInterpreters) }},
+	{"allowed-models", []string{"AAW_ALLOWED_MODELS"}, "comma-separated claude models an EXECUTE may name with model (e.g. haiku,sonnet,opus); empty allows any",
+		func(c *Config) flag.Value { return (*stringValue)(&c.AllowedModels) }},
+	{"force-pty", []string{"AAW_FORCE_PTY"}, "run every local task on a pseudo-terminal, as if its EXECUTE set pty (not those with stdinContent or interactive; Linux and macOS only)",
+		func(c *Config) flag.Value { return (*boolValue)(&c.ForcePTY) }},
+	{"pty-cols", []string{"AAW_PTY_COLS"}, "window width of task pseudo-terminals, in columns",
+		func(c *Config) flag.Value { return (*positiveIntValue)(&c.PTYCols) }},
+	{"pty-rows", []string{"AAW_PTY_ROWS"}, "window height of task pseudo-terminals, in rows",
+		func(c *Config) flag.Value { return (*positiveIntValue)(&c.PTYRows) }},
+	{"rate-limit-cooldown", []string{"AAW_RATE_LIMIT_COOLDOWN"}, "global backoff after a rate limit detection",
+		func(c *Config) flag.Value { return (*positiveDurationValue)(&c.RateLimitCooldown) }},
+	{"usage-limit-cooldown", []string{"AAW_USAGE_LIMIT_COOLDOWN"}, "backoff after a usage limit with no known reset time",
+		func(c *Config) flag.Value { return (*positiveDurationValue)(&c.UsageLimitCooldown) }},
+	{"session-ttl", []string{"AAW_SESSION_TTL"}, "how long the claude session PERSIST tasks continue is kept after its last task",
+		func(c *Config) flag.Value { return (*positiveDurationValue)(&c.SessionTTL) }},
+	{"stall-threshold", []string{"AAW_STALL_THRESHOLD"}, "how long a task may print nothing before it is reported STALLED (an EXECUTE's stallThresholdSeconds overrides it)",
+		func(c *Config) flag.Value { return (*positiveDurationValue)(&c.StallThreshold) }},
+	{"progress-flush-interval", []string{"AAW_PROGRESS_FLUSH_INTERVAL"}, "least time between LOG lines of a progress bar or other line a task redraws with carriage returns (the line's final state is always sent)",
+		func(c *Config) flag.Value { return (*positiveDurationValue)(&c.ProgressFlush) }},
+	{"filter-spinners", []string{"AAW_FILTER_SPINNERS"}, "drop output lines made only of braille spinner glyphs, cursor-control escapes and whitespace, with a periodic LOG line counting them",
+		func(c *Config) flag.Value { return (*boolValue)(&c.FilterSpinners) }},
+	{"validate-outgoing", []string{"AAW_VALIDATE_OUTGOING"}, "validate outbound messages and log violations",
+		func(c *Config) flag.Value { return (*boolValue)(&c.ValidateOutgoing) }},
+	{"reject-newer-schema", []string{"AAW_REJECT_NEWER_SCHEMA"}, "answer messages with a newer schemaVersion with MESSAGE_ERROR",
+		func(c *Config) flag.Value { return (*boolValue)(&c.RejectNewerSchema) }},
+	{"max-error-bytes", []string{"AAW_MAX_ERROR_BYTES"}, "byte limit for outbound error strings",
+		func(c *Config) flag.Value { return (*positiveIntValue)(&c.MaxErrorBytes) }},
+	{"max-line-bytes", []string{"AAW_MAX_LINE_BYTES"}, "byte limit for outbound log lines",
+		func(c *Config) flag.Value { return (*positiveIntValue)(&c.MaxLineBytes) }},
+	{"max-message-bytes", []string{"AAW_MAX_MESSAGE_BYTES"}, "byte limit for inbound messages; a larger one drops the connection",
+		func(c *Config) flag.Value { return (*positiveIntValue)(&c.MaxMessageBytes) }},
+}
+
+// Load resolves the configuration with precedence flags > environment > config file > defaults
+// The config file comes from --config (or AAW_CONFIG_FILE). Invalid environment values are logged and
+// ignored, as they always have been; invalid flags and config file entries are errors.
+// Returns flag.ErrHelp when --help was requested (usage has already been written to output)
+func Load(args []string, getenv func(string) string, output io.Writer) (Config, error) {
+	return LoadWithFlags(args, getenv, output, nil)
+}
+
+// LoadWithFlags is Load for subcommands with flags of their own: the flags defined on extra are parsed
+// along with the configuration flags (setting the variables they are bound to) and listed by --help
+func LoadWithFlags(args []string, getenv func(string) string, output io.Writer, extra *flag.FlagSet) (Config, error) {
+	// Parse flags on their own first: this finds --config and rejects bad flags before anything is read
+	flagged := Default()
+	fs := flag.NewFlagSet("aaw-runner", flag.ContinueOnError)
+	fs.SetOutput(output)
+	for _, opt := range options {
+		if !opt.secret() {
+			fs.Var(opt.value(&flagged), opt.flag, opt.usage)
+		}
+	}
+	fs.Usage = func() { PrintUsage(output) }
+	if extra != nil {
+		extra.VisitAll(func(f *flag.Flag) { fs.Var(f.Value, f.Name, f.Usage) })
+		fs.Usage = func() {
+			fmt.Fprintf(output, "Usage: aaw-runner %s [flags]\n\n", extra.Name())
+			extra.SetOutput(output)
+			extra.PrintDefaults()
+			fmt.Fprintf(output, "\nRunner settings:\n")
+			printOptions(output)
+		}
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+	if fs.NArg() > 0 {
+		return Config{}, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	cfg := Default()
+
+	path := getenv("AAW_CONFIG_FILE")
+	if set["config"] {
+		path = flagged.ConfigFile
+	}
+	if path != "" {
+		if err := loadFile(path, &cfg); err != nil {
+			return Config{}, err
+		}
+	}
+
+	for _, opt := range options {
+		for _, key := range opt.env {
+			envVal := getenv(key)
+			if envVal == "" {
+				continue
+			}
+			if err := opt.value(&cfg).Set(envVal); err != nil {
+				log.Printf("[CONFIG] Ignoring %s=%q: %v", key, envVal, err)
+			}
+			break
+		}
+	}
+
+	// Flag values were validated by the first parse, so re-applying them cannot fail
+	for _, opt := range options {
+		if set[opt.flag] {
+			opt.value(&cfg).Set(opt.value(&flagged).String())
+		}
+	}
+	return cfg, nil
+}
+
+// lookupOption finds a setting by its flag name (also its config file key)
+func lookupOption(name string) (option, bool) {
+	for _, opt := range options {
+		if opt.flag == name {
+			return opt, true
+		}
+	}
+	return option{}, false
+}
+
+// PrintUsage writes --help output documenting each flag with its environment variable and default
+func PrintUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: aaw-runner [flags]\n       aaw-runner run (--content text | --script file) [flags]\n       aaw-runner doctor [flags]\n       aaw-runner schema --out dir/\n       aaw-runner --version\n\n")
+	fmt.Fprintf(w, "Flags override environment variables, which override the config file, which overrides defaults.\n\n")
+	printOptions(w)
+}
+
+// printOptions documents each setting's flag with its environment variable and default
+func printOptions(w io.Writer) {
+	defaults := Default()
+	for _, opt := range options {
+		if opt.secret() {
+			fmt.Fprintf(w, "  %s (environment or config file only)\n", opt.flag)
+		} else {
+			fmt.Fprintf(w, "  --%s\n", opt.flag)
+		}
+		fmt.Fprintf(w, "        %s\n", opt.usage)
+		fmt.Fprintf(w, "        env: %s", opt.env[0])
+		for _, alias := range opt.env[1:] {
+			fmt.Fprintf(w, " (or %s)", alias)
+		}
+		if def := opt.value(&defaults).String(); def != "" {
+			fmt.Fprintf(w, ", default: %s", def)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// IsHelp reports whether Load stopped because --help was requested
+func IsHelp(err error) bool {
+	return errors.Is(err, flag.ErrHelp)
+}
+
+// flag.Value implementations bound directly to Config fields
+
+type stringValue string
+
+func (v *stringValue) Set(s string) error { *v = stringValue(s); return nil }
+func (v *stringValue) String() string     { return string(*v) }
+
+// secretValue is a string setting that is never a flag: command lines are visible to every user in ps
+// and /proc/*/cmdline, so secrets are read from the environment or the config file only
+type secretValue string
+
+func (v *secretValue) Set(s string) error { *v = secretValue(s); return nil }
+func (v *secretValue) String() string     { return string(*v) }
+
+type boolValue bool
+
+func (v *boolValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return fmt.Errorf("expected true or false")
+	}
+	*v = boolValue(b)
+	return nil
+}
+func (v *boolValue) String() string   { return strconv.FormatBool(bool(*v)) }
+func (v *boolValue) IsBoolFlag() bool { return true }
+
+type positiveIntValue int
+
+func (v *positiveIntValue) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return fmt.Errorf("expected a positive integer")
+	}
+	*v = positiveIntValue(n)
+	return nil
+}
+func (v *positiveIntValue) String() string { return strconv.Itoa(int(*v)) }
+
+type nonNegativeIntValue int
+
+func (v *nonNegativeIntValue) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return fmt.Errorf("expected a non-negative integer")
+	}
+	*v = nonNegativeIntValue(n)
+	return nil
+}
+func (v *nonNegativeIntValue) String() string { return strconv.Itoa(int(*v)) }
+
+type compressionLevelValue int
+
+func (v *compressionLevelValue) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < -2 || n > 9 {
+		return fmt.Errorf("expected a compression level from -2 to 9")
+	}
+	*v = compressionLevelValue(n)
+	return nil
+}
+func (v *compressionLevelValue) String() string { return strconv.Itoa(int(*v)) }
+
+type positiveDurationValue time.Duration
+
+func (v *positiveDurationValue) Set(s string) error {
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return fmt.Errorf("expected a positive duration (e.g. 30s, 1h)")
+	}
+	*v = positiveDurationValue(d)
+	return nil
+}
+func (v *positiveDurationValue) String() string { return time.Duration(*v).String() }
+
+type logLevelValue string
+
+func (v *logLevelValue) Set(s string) error {
+	if s != LogLevelDebug && s != LogLevelInfo {
+		return fmt.Errorf("expected %q or %q", LogLevelDebug, LogLevelInfo)
+	}
+	*v = logLevelValue(s)
+	return nil
+}
+func (v *logLevelValue) String() string { return string(*v) }
+
+type logTargetValue string
+
+func (v *logTargetValue) Set(s string) error {
+	if s != LogTargetStderr && s != LogTargetSyslog {
+		return fmt.Errorf("expected %q or %q", LogTargetStderr, LogTargetSyslog)
+	}
+	*v = logTargetValue(s)
+	return nil
+}
+func (v *logTargetValue) String() string { return string(*v) }
+
+type catchUpValue string
+
+func (v *catchUpValue) Set(s string) error {
+	if s != CatchUpSkip && s != CatchUpRunOnce {
+		return fmt.Errorf("expected %q or %q", CatchUpSkip, CatchUpRunOnce)
+	}
+	*v = catchUpValue(s)
+	return nil
+}
+
+func (v *catchUpValue) String() string { return string(*v) }
+
+type missingKeyValue string
+
+func (v *missingKeyValue) Set(s string) error {
+	if s != MissingKeyError && s != MissingKeyEmpty {
+		return fmt.Errorf("expected %q or %q", MissingKeyError, MissingKeyEmpty)
+	}
+	*v = missingKeyValue(s)
+	return nil
+}
+
+func (v *missingKeyValue) String() string { return string(*v) }
+
+type orphanPolicyValue string
+
+func (v *orphanPolicyValue) Set(s string) error {
+	if s != OrphanContinue && s != OrphanCancelAfter && s != OrphanPauseAfter {
+		return fmt.Errorf("expected %q, %q or %q", OrphanContinue, OrphanCancelAfter, OrphanPauseAfter)
+	}
+	*v = orphanPolicyValue(s)
+	return nil
+}
+func (v *orphanPolicyValue) String() string { return string(*v) }
+
+type backpressureValue string
+
+func (v *backpressureValue) Set(s string) error {
+	if s != BackpressureDropNewest && s != BackpressureDropOldest && s != BackpressureBlock {
+		return fmt.Errorf("expected %q, %q or %q", BackpressureDropNewest, BackpressureDropOldest, BackpressureBlock)
+	}
+	*v = backpressureValue(s)
+	return nil
+}
+func (v *backpressureValue) String() string { return string(*v) }
+
+type fileModeValue os.FileMode
+
+func (v *fileModeValue) Set(s string) error {
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mode > 0777 {
+		return fmt.Errorf("expected octal permissions (e.g. 0660)")
+	}
+	*v = fileModeValue(mode)
+	return nil
+}
+func (v *fileModeValue) String() string { return fmt.Sprintf("%04o", uint32(*v)) }
+
+type syslogFacilityValue string
+
+func (v *syslogFacilityValue) Set(s string) error {
+	if _, err := syslog.ParseFacility(s); err != nil {
+		return err
+	}
+	*v = syslogFacilityValue(s)
+	return nil
+}
+func (v *syslogFacilityValue) String() string { return string(*v) }
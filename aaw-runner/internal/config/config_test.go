@@ -0,0 +1,124 @@
+package config
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// envMap returns a getenv function backed by a map
+func envMap(env map[string]string) func(string) string {
+	return func(key string) string { return env[key] }
+}
+
+// TestLoad_Defaults verifies an empty environment and no flags yield the defaults
+func TestLoad_Defaults(t *testing.T) {
+	cfg, err := Load(nil, envMap(nil), io.Discard)
+
+	assert.NoError(t, err)
+	assert.Equal(t, Default(), cfg)
+	assert.Equal(t, DefaultBackendURL, cfg.BackendURL)
+	assert.True(t, cfg.SecretMasking)
+	assert.False(t, cfg.RealtimeStreaming)
+}
+
+// TestLoad_Precedence verifies flags override env vars, which override defaults
+func TestLoad_Precedence(t *testing.T) {
+	env := envMap(map[string]string{
+		"AAW_BACKEND_URL":         "ws://env:8080/ws",
+		"AAW_MAX_PARALLEL_TASKS":  "3",
+		"AAW_RATE_LIMIT_COOLDOWN": "45s",
+	})
+
+	cfg, err := Load([]string{"--max-parallel", "8", "--realtime-streaming", "--log-level=info"}, env, io.Discard)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "ws://env:8080/ws", cfg.BackendURL, "Env overrides the default")
+	assert.Equal(t, 8, cfg.MaxParallel, "Flag overrides env")
+	assert.Equal(t, 45*time.Second, cfg.RateLimitCooldown)
+	assert.True(t, cfg.RealtimeStreaming, "Bool flags need no value")
+	assert.False(t, cfg.Debug())
+}
+
+// TestLoad_LegacyServerURL verifies AAW_SERVER_URL is used only when AAW_BACKEND_URL is unset
+func TestLoad_LegacyServerURL(t *testing.T) {
+	cfg, err := Load(nil, envMap(map[string]string{"AAW_SERVER_URL": "ws://legacy/ws"}), io.Discard)
+	assert.NoError(t, err)
+	assert.Equal(t, "ws://legacy/ws", cfg.BackendURL)
+
+	cfg, err = Load(nil, envMap(map[string]string{"AAW_SERVER_URL": "ws://legacy/ws", "AAW_BACKEND_URL": "ws://new/ws"}), io.Discard)
+	assert.NoError(t, err)
+	assert.Equal(t, "ws://new/ws", cfg.BackendURL)
+}
+
+// TestLoad_InvalidEnvIgnored verifies bad env values keep the default instead of failing startup
+func TestLoad_InvalidEnvIgnored(t *testing.T) {
+	env := envMap(map[string]string{
+		"AAW_MAX_PARALLEL_TASKS":   "0",
+		"AAW_USAGE_LIMIT_COOLDOWN": "soon",
+		"AAW_SECRET_MASKING":       "maybe",
+	})
+
+	cfg, err := Load(nil, env, io.Discard)
+
+	assert.NoError(t, err)
+	assert.Equal(t, Default().MaxParallel, cfg.MaxParallel)
+	assert.Equal(t, DefaultUsageLimitCooldown, cfg.UsageLimitCooldown)
+	assert.True(t, cfg.SecretMasking)
+}
+
+// TestLoad_InvalidFlag verifies bad flag values are rejected
+func TestLoad_InvalidFlag(t *testing.T) {
+	for _, args := range [][]string{
+		{"--max-parallel", "-1"},
+		{"--log-level", "verbose"},
+		{"--log-target", "file"},
+		{"--syslog-facility", "local9"},
+		{"--audit-max-size-mb", "0"},
+		{"--control-socket-mode", "0999"},
+		{"--control-socket-mode", "01777"},
+		{"--rate-limit-cooldown", "0s"},
+		{"--ws-compression-level", "10"},
+		{"--ws-compression-level", "-3"},
+		{"--log-backpressure", "drop"},
+		{"--no-such-flag"},
+		{"stray"},
+	} {
+		_, err := Load(args, envMap(nil), io.Discard)
+		assert.Error(t, err, "%v", args)
+	}
+}
+
+// TestLoad_SecretsAreNotFlags verifies a secret cannot be passed on the command line, where every user
+// could read it, but is still taken from the environment
+func TestLoad_SecretsAreNotFlags(t *testing.T) {
+	_, err := Load([]string{"--admin-token", "s3cret"}, envMap(nil), io.Discard)
+	assert.Error(t, err)
+
+	cfg, err := Load(nil, envMap(map[string]string{"AAW_ADMIN_TOKEN": "s3cret"}), io.Discard)
+	assert.NoError(t, err)
+	assert.Equal(t, "s3cret", cfg.AdminToken)
+}
+
+// TestLoad_Help verifies --help documents each flag with its env var and default, and each secret
+// without a flag
+func TestLoad_Help(t *testing.T) {
+	var out bytes.Buffer
+
+	_, err := Load([]string{"--help"}, envMap(nil), &out)
+
+	assert.True(t, IsHelp(err))
+	for _, opt := range options {
+		if opt.secret() {
+			assert.Contains(t, out.String(), "  "+opt.flag+" (environment or config file only)")
+			assert.NotContains(t, out.String(), "--"+opt.flag+"\n")
+		} else {
+			assert.Contains(t, out.String(), "--"+opt.flag)
+		}
+		assert.Contains(t, out.String(), opt.env[0])
+	}
+	assert.Contains(t, out.String(), "default: 30s")
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadFile applies the settings in a YAML config file to cfg
+// Keys are the flag names (e.g. "max-parallel: 8"). Unknown keys are logged and skipped so a
+// typo is visible without stopping the runner; a bad value is an error naming the key and line.
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if len(root.Content) == 0 {
+		return nil // Empty file
+	}
+	doc := root.Content[0]
+	if doc.Kind != yaml.MappingNode {
+		return fmt.Errorf("%s:%d: expected a mapping of settings", path, doc.Line)
+	}
+
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		key, val := doc.Content[i], doc.Content[i+1]
+
+		opt, ok := lookupOption(key.Value)
+		if !ok || opt.flag == "config" {
+			log.Printf("[CONFIG] Warning: unknown key %q in %s (line %d), ignoring", key.Value, path, key.Line)
+			continue
+		}
+		if val.Kind != yaml.ScalarNode {
+			return fmt.Errorf("%s:%d: %s: expected a single value", path, val.Line, key.Value)
+		}
+		if err := opt.value(cfg).Set(val.Value); err != nil {
+			return fmt.Errorf("%s:%d: %s: %w", path, val.Line, key.Value, err)
+		}
+	}
+
+	cfg.ConfigFile = path
+	return nil
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files")
+
+// assertGolden compares the resolved config with testdata/<name>.golden.json
+func assertGolden(t *testing.T, name string, cfg Config) {
+	t.Helper()
+	got, err := json.MarshalIndent(cfg, "", "  ")
+	assert.NoError(t, err)
+	got = append(got, '\n')
+
+	path := filepath.Join("testdata", name+".golden.json")
+	if *update {
+		assert.NoError(t, os.WriteFile(path, got, 0o644))
+	}
+	want, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, string(want), string(got), "run go test ./internal/config -update to accept changes")
+}
+
+// TestLoadFile_Golden verifies every key in a full config file is applied
+func TestLoadFile_Golden(t *testing.T) {
+	t.Setenv("HOME", "/home/runner")
+
+	cfg, err := Load([]string{"--config", "testdata/runner.yaml"}, envMap(nil), io.Discard)
+
+	assert.NoError(t, err)
+	assertGolden(t, "runner", cfg)
+}
+
+// TestLoadFile_Precedence verifies flags > env > config file > defaults, field by field
+func TestLoadFile_Precedence(t *testing.T) {
+	t.Setenv("HOME", "/home/runner")
+	env := envMap(map[string]string{
+		"AAW_CONFIG_FILE":        "testdata/partial.yaml",
+		"AAW_MAX_PARALLEL_TASKS": "4",            // Overrides the file
+		"AAW_LOG_LEVEL":          "info",         // Not in the file
+		"AAW_BACKEND_URL":        "ws://env/ws",  // Overridden by the flag
+		"AAW_MAX_ERROR_BYTES":    "not-a-number", // Ignored, default kept
+	})
+
+	cfg, err := Load([]string{"--backend-url", "ws://flag/ws"}, env, io.Discard)
+
+	assert.NoError(t, err)
+	assertGolden(t, "precedence", cfg)
+}
+
+// TestLoadFile_InvalidValue verifies a bad value is rejected with its key and line
+func TestLoadFile_InvalidValue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "runner.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("log-level: info\nmax-parallel: lots\n"), 0o644))
+
+	_, err := Load([]string{"--config", path}, envMap(nil), io.Discard)
+
+	assert.EqualError(t, err, path+":2: max-parallel: expected a positive integer")
+}
+
+// TestLoadFile_NotAMapping verifies lists and nested values are rejected
+func TestLoadFile_NotAMapping(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "runner.yaml")
+
+	assert.NoError(t, os.WriteFile(path, []byte("- max-parallel: 3\n"), 0o644))
+	_, err := Load([]string{"--config", path}, envMap(nil), io.Discard)
+	assert.ErrorContains(t, err, "expected a mapping of settings")
+
+	assert.NoError(t, os.WriteFile(path, []byte("max-parallel:\n  value: 3\n"), 0o644))
+	_, err = Load([]string{"--config", path}, envMap(nil), io.Discard)
+	assert.ErrorContains(t, err, "max-parallel: expected a single value")
+}
+
+// TestLoadFile_UnknownKeyWarns verifies unknown keys are logged and skipped
+func TestLoadFile_UnknownKeyWarns(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	path := filepath.Join(t.TempDir(), "runner.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("max-paralel: 3\nmax-parallel: 2\n"), 0o644))
+
+	cfg, err := Load([]string{"--config", path}, envMap(nil), io.Discard)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, cfg.MaxParallel)
+	assert.Contains(t, logs.String(), `unknown key "max-paralel"`)
+	assert.Contains(t, logs.String(), "(line 1)")
+}
+
+// TestLoadFile_Missing verifies a missing config file is an error
+func TestLoadFile_Missing(t *testing.T) {
+	_, err := Load([]string{"--config", filepath.Join(t.TempDir(), "nope.yaml")}, envMap(nil), io.Discard)
+
+	assert.ErrorContains(t, err, "failed to read config file")
+}
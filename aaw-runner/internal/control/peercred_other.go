@@ -0,0 +1,10 @@
+//go:build !linux
+
+package control
+
+import "net"
+
+// peerCredentials is unavailable off Linux; the socket permissions are the only guard
+func peerCredentials(conn net.Conn) *Credentials {
+	return nil
+}
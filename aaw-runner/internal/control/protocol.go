@@ -0,0 +1,93 @@
+// Package control serves the admin operations over a Unix domain socket for scripting on the runner
+// host (see cmd/aawctl). Each connection carries one JSON request line and gets one JSON response.
+package control
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/admin"
+)
+
+// DefaultSocket is where aawctl looks for the socket unless told otherwise
+const DefaultSocket = "/run/aaw-runner.sock"
+
+// Operations understood by the server
+const (
+	OpStatus  = "status"  // Connection, capacity and build information
+	OpTasks   = "tasks"   // Running and queued tasks
+	OpCancel  = "cancel"  // Gracefully cancel TaskID, as CANCEL_TASK would
+	OpKill    = "kill"    // Force-kill TaskID, answering once it is verified dead
+	OpDrain   = "drain"   // Stop accepting new tasks
+	OpUndrain = "undrain" // Accept new tasks again
+	OpPause   = "pause"   // Hold queued tasks; running tasks carry on
+	OpResume  = "resume"  // Start queued tasks again
+	OpReload  = "reload"  // Same as SIGHUP: reopen the log file and re-probe the claude CLI
+)
+
+// callTimeout bounds a round trip; a kill may take up to its 10s verification before answering
+var callTimeout = 30 * time.Second
+
+// Request is the JSON line a client sends
+type Request struct {
+	Op     string `json:"op"`
+	TaskID int64  `json:"taskId,omitempty"` // For cancel and kill
+}
+
+// Status is the answer to OpStatus: the admin API's status plus whether tasks are held
+type Status struct {
+	admin.Status
+	Paused bool `json:"paused"`
+}
+
+// Response is the JSON the server answers with
+type Response struct {
+	OK     bool         `json:"ok"`
+	Error  string       `json:"error,omitempty"`
+	Status *Status      `json:"status,omitempty"` // For OpStatus
+	Tasks  []admin.Task `json:"tasks,omitempty"`  // For OpTasks
+}
+
+// Call sends req to the server listening on socket and returns its response
+// A response with OK unset is returned as an error carrying the server's message.
+func Call(socket string, req Request) (Response, error) {
+	conn, err := net.DialTimeout("unix", socket, 5*time.Second)
+	if err != nil {
+		return Response{}, fmt.Errorf("cannot reach the runner: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(callTimeout))
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return Response{}, fmt.Errorf("failed to send request: %w", err)
+	}
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return Response{}, fmt.Errorf("failed to read response: %w", err)
+	}
+	if !resp.OK {
+		return resp, errors.New(resp.Error)
+	}
+	return resp, nil
+}
+
+// WaitIdle polls the server until no task is running or queued, or timeout expires
+func WaitIdle(socket string, timeout, interval time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		resp, err := Call(socket, Request{Op: OpTasks})
+		if err != nil {
+			return err
+		}
+		if len(resp.Tasks) == 0 {
+			return nil
+		}
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("%d task(s) still pending after %s", len(resp.Tasks), timeout)
+		}
+		time.Sleep(interval)
+	}
+}
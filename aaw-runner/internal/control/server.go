@@ -0,0 +1,195 @@
+package control
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/admin"
+)
+
+// Runner is the part of the runner the socket inspects and drives
+type Runner interface {
+	admin.Runner
+	Pause()
+	Resume()
+	Paused() bool
+}
+
+// requestTimeout bounds how long a client may take to send its request
+const requestTimeout = 5 * time.Second
+
+// Credentials identify the process on the other end of a connection
+type Credentials struct {
+	PID int32
+	UID uint32
+	GID uint32
+}
+
+// Server answers control requests on a Unix socket
+type Server struct {
+	path   string
+	mode   os.FileMode
+	runner Runner
+	reload func() error
+	owner  uint32 // UID allowed to mutate besides root: the runner's own user
+	ln     net.Listener
+}
+
+// NewServer creates a control server for the socket at path, created with permissions mode
+// reload runs for OpReload.
+func NewServer(path string, mode os.FileMode, runner Runner, reload func() error) *Server {
+	return &Server{path: path, mode: mode, runner: runner, reload: reload, owner: uint32(os.Getuid())}
+}
+
+// Start creates the socket and begins serving in the background
+// A stale socket left by a previous run is replaced; one still answering is an error.
+func (s *Server) Start() error {
+	if _, err := os.Stat(s.path); err == nil {
+		if conn, err := net.Dial("unix", s.path); err == nil {
+			conn.Close()
+			return fmt.Errorf("control socket %s is in use by another process", s.path)
+		}
+		if err := os.Remove(s.path); err != nil {
+			return fmt.Errorf("failed to remove stale control socket: %w", err)
+		}
+	}
+
+	ln, err := net.Listen("unix", s.path)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(s.path, s.mode); err != nil {
+		ln.Close()
+		return fmt.Errorf("failed to set control socket permissions: %w", err)
+	}
+	s.ln = ln
+	log.Printf("[CONTROL] Serving the control socket on %s (mode %04o)", s.path, s.mode)
+	go s.serve()
+	return nil
+}
+
+// Close stops serving and removes the socket
+func (s *Server) Close() error {
+	if s.ln == nil {
+		return nil
+	}
+	// Closing a Unix listener unlinks its socket file
+	return s.ln.Close()
+}
+
+// serve accepts connections until the listener is closed
+func (s *Server) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("[CONTROL] Accept error: %v", err)
+			}
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+// handle answers the single request on conn
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(requestTimeout))
+	var req Request
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err == nil {
+		err = json.Unmarshal(line, &req)
+	}
+	var resp Response
+	if err != nil {
+		resp = Response{Error: fmt.Sprintf("invalid request: %v", err)}
+	} else {
+		resp = s.dispatch(req, peerCredentials(conn))
+	}
+
+	conn.SetWriteDeadline(time.Now().Add(requestTimeout))
+	json.NewEncoder(conn).Encode(resp)
+}
+
+// dispatch authorizes and runs one request
+func (s *Server) dispatch(req Request, creds *Credentials) Response {
+	if err := authorize(req.Op, creds, s.owner); err != nil {
+		log.Printf("[CONTROL] Refused %s: %v", req.Op, err)
+		return Response{Error: err.Error()}
+	}
+	if mutates(req.Op) {
+		log.Printf("[CONTROL] %s %s", req.Op, describePeer(req, creds))
+	}
+
+	var err error
+	switch req.Op {
+	case OpStatus:
+		return Response{OK: true, Status: &Status{Status: admin.CurrentStatus(s.runner), Paused: s.runner.Paused()}}
+	case OpTasks:
+		return Response{OK: true, Tasks: admin.ListTasks(s.runner, time.Now())}
+	case OpCancel, OpKill:
+		if req.TaskID <= 0 {
+			return Response{Error: "task id must be a positive integer"}
+		}
+		if req.Op == OpCancel {
+			err = s.runner.CancelTask(req.TaskID)
+		} else {
+			err = s.runner.KillTask(req.TaskID)
+		}
+	case OpDrain:
+		s.runner.Drain()
+	case OpUndrain:
+		err = s.runner.Undrain()
+	case OpPause:
+		s.runner.Pause()
+	case OpResume:
+		s.runner.Resume()
+	case OpReload:
+		if s.reload != nil {
+			err = s.reload()
+		}
+	default:
+		return Response{Error: fmt.Sprintf("unknown operation %q", req.Op)}
+	}
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+	return Response{OK: true}
+}
+
+// mutates reports whether op changes the runner's state (anything beyond status and tasks)
+func mutates(op string) bool {
+	return op != OpStatus && op != OpTasks
+}
+
+// authorize decides whether the peer may run op
+// Anyone who can open the socket may read; mutations need root or the runner's own user. Where the
+// platform cannot report peer credentials (creds is nil) the socket permissions are the only guard.
+func authorize(op string, creds *Credentials, owner uint32) error {
+	if !mutates(op) || creds == nil {
+		return nil
+	}
+	if creds.UID == 0 || creds.UID == owner {
+		return nil
+	}
+	return fmt.Errorf("%s requires root or uid %d (peer is uid %d)", op, owner, creds.UID)
+}
+
+// describePeer names the requester for the log
+func describePeer(req Request, creds *Credentials) string {
+	target := ""
+	if req.TaskID > 0 {
+		target = fmt.Sprintf("task %d ", req.TaskID)
+	}
+	if creds == nil {
+		return target + "(peer unknown)"
+	}
+	return fmt.Sprintf("%s(pid %d, uid %d)", target, creds.PID, creds.UID)
+}
@@ -0,0 +1,218 @@
+package control
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/executor"
+	"github.com/berno/aaw-runner/internal/runner"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRunner is a Runner that records the actions taken on it
+type fakeRunner struct {
+	mu        sync.Mutex
+	tasks     []executor.TaskSnapshot
+	draining  bool
+	paused    bool
+	cancelled []int64
+	killed    []int64
+	actionErr error
+}
+
+func (f *fakeRunner) Connected() bool           { return true }
+func (f *fakeRunner) Capacity() (int, int, int) { return 3, 1, 2 }
+func (f *fakeRunner) Draining() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.draining
+}
+func (f *fakeRunner) Tasks() []executor.TaskSnapshot {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.tasks
+}
+func (f *fakeRunner) CancelTask(taskID int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cancelled = append(f.cancelled, taskID)
+	return f.actionErr
+}
+func (f *fakeRunner) KillTask(taskID int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.killed = append(f.killed, taskID)
+	return f.actionErr
+}
+func (f *fakeRunner) Drain()                 { f.set(&f.draining, true) }
+func (f *fakeRunner) Undrain() error         { f.set(&f.draining, false); return nil }
+func (f *fakeRunner) Pause()                 { f.set(&f.paused, true) }
+func (f *fakeRunner) Resume()                { f.set(&f.paused, false) }
+func (f *fakeRunner) DroppedLogLines() int64 { return 0 }
+func (f *fakeRunner) Paused() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.paused
+}
+func (f *fakeRunner) set(field *bool, value bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	*field = value
+}
+
+// startServer serves f on a socket in a temporary directory
+func startServer(t *testing.T, f *fakeRunner, reload func() error) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "aaw.sock")
+	s := NewServer(path, 0600, f, reload)
+	assert.NoError(t, s.Start())
+	t.Cleanup(func() { s.Close() })
+	return path
+}
+
+// TestServer_StatusAndTasks verifies the read-only operations report the runner's state
+func TestServer_StatusAndTasks(t *testing.T) {
+	submitted := time.Now().Add(-time.Minute)
+	f := &fakeRunner{paused: true, tasks: []executor.TaskSnapshot{
+		{TaskID: 7, State: runner.TaskStateQueued, SubmittedAt: submitted, Metadata: map[string]string{"repo": "aaw"}},
+	}}
+	path := startServer(t, f, nil)
+
+	resp, err := Call(path, Request{Op: OpStatus})
+	assert.NoError(t, err)
+	if assert.NotNil(t, resp.Status) {
+		assert.True(t, resp.Status.Connected)
+		assert.Equal(t, 3, resp.Status.MaxParallel)
+		assert.True(t, resp.Status.Paused)
+	}
+
+	resp, err = Call(path, Request{Op: OpTasks})
+	assert.NoError(t, err)
+	if assert.Len(t, resp.Tasks, 1) {
+		assert.Equal(t, int64(7), resp.Tasks[0].TaskID)
+		assert.Equal(t, "QUEUED", resp.Tasks[0].State)
+		assert.Equal(t, "aaw", resp.Tasks[0].Metadata["repo"])
+	}
+}
+
+// TestServer_Mutations verifies cancel, kill, drain, undrain, pause, resume and reload reach the runner
+func TestServer_Mutations(t *testing.T) {
+	f := &fakeRunner{}
+	reloads := 0
+	path := startServer(t, f, func() error { reloads++; return nil })
+
+	for _, req := range []Request{
+		{Op: OpCancel, TaskID: 4},
+		{Op: OpKill, TaskID: 5},
+		{Op: OpDrain},
+		{Op: OpPause},
+	} {
+		_, err := Call(path, req)
+		assert.NoError(t, err, req.Op)
+	}
+	assert.Equal(t, []int64{4}, f.cancelled)
+	assert.Equal(t, []int64{5}, f.killed)
+	assert.True(t, f.Draining())
+	assert.True(t, f.Paused())
+
+	for _, op := range []string{OpUndrain, OpResume, OpReload} {
+		_, err := Call(path, Request{Op: op})
+		assert.NoError(t, err, op)
+	}
+	assert.False(t, f.Draining())
+	assert.False(t, f.Paused())
+	assert.Equal(t, 1, reloads)
+}
+
+// TestServer_Errors verifies failures and bad requests come back as errors
+func TestServer_Errors(t *testing.T) {
+	f := &fakeRunner{actionErr: errors.New("task 9 is not running")}
+	path := startServer(t, f, func() error { return errors.New("claude CLI not found") })
+
+	_, err := Call(path, Request{Op: OpCancel, TaskID: 9})
+	assert.EqualError(t, err, "task 9 is not running")
+	_, err = Call(path, Request{Op: OpKill})
+	assert.EqualError(t, err, "task id must be a positive integer")
+	_, err = Call(path, Request{Op: OpReload})
+	assert.EqualError(t, err, "claude CLI not found")
+	_, err = Call(path, Request{Op: "restart"})
+	assert.EqualError(t, err, `unknown operation "restart"`)
+}
+
+// TestServer_Socket verifies the socket's permissions, stale socket replacement and removal on close
+func TestServer_Socket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "aaw.sock")
+	assert.NoError(t, os.WriteFile(path, nil, 0644), "Stale file from a previous run")
+
+	s := NewServer(path, 0660, &fakeRunner{}, nil)
+	assert.NoError(t, s.Start())
+	info, err := os.Stat(path)
+	if assert.NoError(t, err) {
+		assert.Equal(t, os.FileMode(0660), info.Mode().Perm())
+	}
+
+	assert.ErrorContains(t, NewServer(path, 0660, &fakeRunner{}, nil).Start(), "in use")
+
+	assert.NoError(t, s.Close())
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "Socket should be removed on close")
+}
+
+// TestPeerCredentials verifies the server sees the caller's process and user
+func TestPeerCredentials(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("peer credentials are only read on Linux")
+	}
+	ln, err := net.Listen("unix", filepath.Join(t.TempDir(), "peer.sock"))
+	assert.NoError(t, err)
+	defer ln.Close()
+
+	client, err := net.Dial("unix", ln.Addr().String())
+	assert.NoError(t, err)
+	defer client.Close()
+	conn, err := ln.Accept()
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	creds := peerCredentials(conn)
+	if assert.NotNil(t, creds) {
+		assert.Equal(t, int32(os.Getpid()), creds.PID)
+		assert.Equal(t, uint32(os.Getuid()), creds.UID)
+	}
+}
+
+// TestAuthorize verifies reads are open while mutations need root or the runner's user
+func TestAuthorize(t *testing.T) {
+	const owner = 1000
+	stranger := &Credentials{PID: 42, UID: 1001}
+
+	assert.NoError(t, authorize(OpStatus, stranger, owner))
+	assert.NoError(t, authorize(OpTasks, stranger, owner))
+	for _, op := range []string{OpCancel, OpKill, OpDrain, OpUndrain, OpPause, OpResume, OpReload} {
+		assert.ErrorContains(t, authorize(op, stranger, owner), "requires root or uid 1000", op)
+		assert.NoError(t, authorize(op, &Credentials{UID: owner}, owner), op)
+		assert.NoError(t, authorize(op, &Credentials{UID: 0}, owner), op)
+		assert.NoError(t, authorize(op, nil, owner), op)
+	}
+}
+
+// TestWaitIdle verifies drain --wait returns once the last task is gone and times out otherwise
+func TestWaitIdle(t *testing.T) {
+	f := &fakeRunner{tasks: []executor.TaskSnapshot{{TaskID: 1, State: runner.TaskStateRunning}}}
+	path := startServer(t, f, nil)
+
+	assert.ErrorContains(t, WaitIdle(path, 50*time.Millisecond, 10*time.Millisecond), "1 task(s) still pending")
+
+	time.AfterFunc(50*time.Millisecond, func() {
+		f.mu.Lock()
+		f.tasks = nil
+		f.mu.Unlock()
+	})
+	assert.NoError(t, WaitIdle(path, 5*time.Second, 10*time.Millisecond))
+}
@@ -0,0 +1,60 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParse_Errors verifies malformed and out-of-range expressions are rejected with the offending field
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr string
+	}{
+		{"* * * *", "has 4 fields"},
+		{"60 * * * *", "minute: \"60\" is outside 0-59"},
+		{"* 24 * * *", "hour"},
+		{"* * 0 * *", "day of month"},
+		{"* * * 13 *", "month"},
+		{"* * * * 8", "day of week"},
+		{"*/0 * * * *", "bad step"},
+		{"5-1 * * * *", "bad range"},
+		{"x * * * *", "bad value"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.expr)
+		assert.ErrorContains(t, err, tt.wantErr, tt.expr)
+	}
+}
+
+// TestNext verifies the next match for steps, ranges, lists, day fields and year boundaries
+func TestNext(t *testing.T) {
+	// Wednesday 15 October 2025, 10:07:30
+	from := time.Date(2025, time.October, 15, 10, 7, 30, 0, time.UTC)
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2025, 10, 15, 10, 8, 0, 0, time.UTC)},
+		{"*/30 * * * *", time.Date(2025, 10, 15, 10, 30, 0, 0, time.UTC)},
+		{"7 * * * *", time.Date(2025, 10, 15, 11, 7, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2025, 10, 15, 13, 0, 0, 0, time.UTC)},
+		{"15,45 8 * * *", time.Date(2025, 10, 16, 8, 15, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2025, 11, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 0", time.Date(2025, 10, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2025, 10, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 1 *", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 20 * 5", time.Date(2025, 10, 17, 12, 0, 0, 0, time.UTC)},   // Either day field matches
+		{"0 12 */10 * 5", time.Date(2025, 10, 31, 12, 0, 0, 0, time.UTC)}, // With "*/10" both day fields must match
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.expr)
+		if assert.NoError(t, err, tt.expr) {
+			assert.Equal(t, tt.want, s.Next(from), tt.expr)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package doctor
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/config"
+	"github.com/berno/aaw-runner/internal/matcher"
+)
+
+// Clock skew thresholds against the backend's Date header
+const (
+	skewWarn = 30 * time.Second
+	skewFail = 5 * time.Minute
+)
+
+// Open file limits: each task holds pipes and its process's descriptors
+const (
+	noFileWarn = 4096
+	noFileFail = 256
+)
+
+// CheckConfig reports whether the configuration resolved
+func CheckConfig(cfg config.Config, loadErr error) Result {
+	r := Result{Name: "config", Level: Pass}
+	switch {
+	case loadErr != nil:
+		r.Level = Fail
+		r.Message = loadErr.Error()
+		r.Hint = "fix the flag or config file entry named above (see aaw-runner --help); the checks below use defaults"
+	case cfg.ConfigFile != "":
+		r.Message = "loaded " + cfg.ConfigFile
+	default:
+		r.Message = "no config file, using flags, environment and defaults"
+	}
+	return r
+}
+
+// CheckClaude resolves the claude binary (--claude-path) and asks it for its version
+func CheckClaude(binary string, lookPath func(string) (string, error), command func(string, ...string) ([]byte, error)) Result {
+	r := Result{Name: "claude", Hint: "install claude for the user the runner runs as, or add its directory to PATH in the service environment"}
+	path, err := lookPath(binary)
+	if err != nil {
+		r.Level = Fail
+		r.Message = "not found on PATH: " + err.Error()
+		return r
+	}
+
+	out, err := command(path, "--version")
+	version := strings.TrimSpace(string(out))
+	if err != nil || version == "" {
+		r.Level = Warn
+		r.Message = fmt.Sprintf("%s found but \"claude --version\" failed: %v", path, err)
+		r.Hint = "run \"claude --version\" as the runner's user; it may need to be logged in or updated"
+		return r
+	}
+	r.Level = Pass
+	r.Message = fmt.Sprintf("%s (%s)", path, firstLine(version))
+	return r
+}
+
+// CheckBackend attempts a WebSocket handshake with the backend, returning its response headers on success
+func CheckBackend(url string, dial func(string) (http.Header, error)) (Result, http.Header) {
+	r := Result{Name: "backend"}
+	header, err := dial(url)
+	if err != nil {
+		r.Level = Fail
+		r.Message = fmt.Sprintf("handshake with %s failed: %v", url, err)
+		r.Hint = "check --backend-url (AAW_BACKEND_URL); it must be a ws:// or wss:// URL reachable from this host"
+		return r, nil
+	}
+	r.Level = Pass
+	r.Message = "handshake with " + url + " succeeded"
+	return r, header
+}
+
+// CheckClock compares the local clock with the backend's Date header
+// Usage-limit reset times and log timestamps are only meaningful when the clocks agree
+func CheckClock(date string, now time.Time) Result {
+	r := Result{Name: "clock", Hint: "enable time synchronization (e.g. systemd-timesyncd or chrony) on this host"}
+	if date == "" {
+		r.Level = Warn
+		r.Message = "backend sent no Date header; clock skew not checked"
+		r.Hint = ""
+		return r
+	}
+	remote, err := http.ParseTime(date)
+	if err != nil {
+		r.Level = Warn
+		r.Message = fmt.Sprintf("unparseable Date header %q; clock skew not checked", date)
+		r.Hint = ""
+		return r
+	}
+
+	skew := now.Sub(remote)
+	if skew < 0 {
+		skew = -skew
+	}
+	// The Date header has one-second resolution
+	skew = skew.Truncate(time.Second)
+	switch {
+	case skew >= skewFail:
+		r.Level = Fail
+	case skew >= skewWarn:
+		r.Level = Warn
+	default:
+		r.Level = Pass
+	}
+	r.Message = fmt.Sprintf("local clock is %s off the backend's", skew)
+	return r
+}
+
+// CheckWritableDir verifies the runner can write to dir, or create it when missing
+// flagName names the setting that chooses the directory, for the hint
+func CheckWritableDir(name, flagName, dir string) Result {
+	r := Result{Name: name, Hint: fmt.Sprintf("make it writable by the runner's user or choose another directory with --%s", flagName)}
+	if dir == "" {
+		r.Level = Fail
+		r.Message = "not set"
+		return r
+	}
+
+	existing := dir
+	for {
+		info, err := os.Stat(existing)
+		if err == nil {
+			if !info.IsDir() {
+				r.Level = Fail
+				r.Message = existing + " is not a directory"
+				return r
+			}
+			break
+		}
+		parent := filepath.Dir(existing)
+		if !errors.Is(err, fs.ErrNotExist) || parent == existing {
+			r.Level = Fail
+			r.Message = err.Error()
+			return r
+		}
+		existing = parent
+	}
+
+	f, err := os.CreateTemp(existing, ".aaw-doctor-*")
+	if err != nil {
+		r.Level = Fail
+		r.Message = fmt.Sprintf("%s is not writable: %v", existing, err)
+		return r
+	}
+	f.Close()
+	os.Remove(f.Name())
+
+	r.Level = Pass
+	r.Message = dir + " is writable"
+	if existing != dir {
+		r.Message = fmt.Sprintf("%s does not exist yet and can be created under %s", dir, existing)
+	}
+	return r
+}
+
+// CheckMatcherPatterns validates the detection patterns file, if one is configured
+func CheckMatcherPatterns(path string) Result {
+	r := Result{Name: "matcher patterns", Level: Pass, Message: "built-in patterns"}
+	if path == "" {
+		return r
+	}
+	r.Hint = "fix the file or unset --matcher-patterns-file; the runner falls back to the built-in patterns"
+
+	cfg, err := matcher.LoadMatcherConfig(path)
+	if err == nil {
+		_, err = matcher.NewPatternMatcherWithConfig(*cfg)
+	}
+	if err != nil {
+		r.Level = Fail
+		r.Message = err.Error()
+		return r
+	}
+	r.Message = path + " is valid"
+	return r
+}
+
+// CheckSeverityRules validates the severity rules file, if one is configured
+func CheckSeverityRules(path string) Result {
+	r := Result{Name: "severity rules", Level: Pass, Message: "built-in rules"}
+	if path == "" {
+		return r
+	}
+	r.Hint = "fix the file or unset --severity-rules-file; the runner falls back to the built-in rules"
+
+	rules, err := matcher.LoadSeverityRules(path)
+	if err == nil {
+		_, err = matcher.NewSeverityClassifier(rules)
+	}
+	if err != nil {
+		r.Level = Fail
+		r.Message = err.Error()
+		return r
+	}
+	r.Message = fmt.Sprintf("%s is valid (%d rules)", path, len(rules))
+	return r
+}
+
+// CheckFileLimit checks the open file descriptor limit
+func CheckFileLimit(noFile func() (cur, max uint64, err error)) Result {
+	r := Result{Name: "open files limit", Hint: fmt.Sprintf("raise it to at least %d (LimitNOFILE= in the systemd unit, or ulimit -n)", noFileWarn)}
+	cur, max, err := noFile()
+	if err != nil {
+		r.Level = Warn
+		r.Message = "could not read RLIMIT_NOFILE: " + err.Error()
+		r.Hint = ""
+		return r
+	}
+
+	r.Message = fmt.Sprintf("soft limit %d (hard limit %d)", cur, max)
+	switch {
+	case cur < noFileFail:
+		r.Level = Fail
+	case cur < noFileWarn:
+		r.Level = Warn
+	default:
+		r.Level = Pass
+	}
+	return r
+}
+
+// firstLine returns s up to its first newline
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
@@ -0,0 +1,119 @@
+// Package doctor diagnoses the runner host: claude install, backend reachability, directories, config files and limits
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/config"
+	"github.com/gorilla/websocket"
+)
+
+// Level grades a check result
+type Level string
+
+const (
+	Pass Level = "PASS"
+	Warn Level = "WARN"
+	Fail Level = "FAIL"
+)
+
+// Result is the outcome of one check
+type Result struct {
+	Name    string
+	Level   Level
+	Message string
+	Hint    string // What to do about a warning or failure
+}
+
+// commandTimeout bounds external commands and the backend handshake
+const commandTimeout = 10 * time.Second
+
+// Env is the system access the checks need; tests replace it with fakes
+type Env struct {
+	LookPath func(file string) (string, error)
+	Command  func(name string, args ...string) ([]byte, error) // Runs a command, returning its combined output
+	Dial     func(url string) (http.Header, error)             // WebSocket handshake only; returns the response headers
+	Now      func() time.Time
+	Getwd    func() (string, error)
+	NoFile   func() (cur, max uint64, err error) // RLIMIT_NOFILE
+}
+
+// SystemEnv returns the Env backed by the real system
+func SystemEnv() Env {
+	return Env{
+		LookPath: exec.LookPath,
+		Command: func(name string, args ...string) ([]byte, error) {
+			ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+			defer cancel()
+			return exec.CommandContext(ctx, name, args...).CombinedOutput()
+		},
+		Dial: func(url string) (http.Header, error) {
+			dialer := websocket.Dialer{HandshakeTimeout: commandTimeout}
+			conn, resp, err := dialer.Dial(url, nil)
+			if err != nil {
+				return nil, err
+			}
+			// Handshake only: no HELO, so the backend never registers this connection as a runner
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, "doctor"), time.Now().Add(time.Second))
+			conn.Close()
+			return resp.Header, nil
+		},
+		Now:   time.Now,
+		Getwd: os.Getwd,
+		NoFile: func() (uint64, uint64, error) {
+			var rl syscall.Rlimit
+			err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rl)
+			return uint64(rl.Cur), uint64(rl.Max), err
+		},
+	}
+}
+
+// Run performs every check against cfg; loadErr is the error config.Load returned, if any
+func Run(cfg config.Config, loadErr error, env Env) []Result {
+	results := []Result{CheckConfig(cfg, loadErr), CheckClaude(cfg.ClaudePath, env.LookPath, env.Command)}
+
+	backend, header := CheckBackend(cfg.BackendURL, env.Dial)
+	results = append(results, backend)
+	if backend.Level == Pass {
+		results = append(results, CheckClock(header.Get("Date"), env.Now()))
+	}
+
+	workdir := cfg.Workdir
+	if workdir == "" {
+		workdir, _ = env.Getwd()
+	}
+	results = append(results,
+		CheckWritableDir("workdir", "workdir", workdir),
+		CheckWritableDir("state dir", "state-dir", cfg.StateDir))
+	if cfg.LogFile != "" {
+		results = append(results, CheckWritableDir("log dir", "log-file", filepath.Dir(cfg.LogFile)))
+	}
+
+	return append(results,
+		CheckMatcherPatterns(cfg.MatcherPatternsFile),
+		CheckSeverityRules(cfg.SeverityRulesFile),
+		CheckFileLimit(env.NoFile))
+}
+
+// Print writes the report and returns whether any check failed
+func Print(w io.Writer, results []Result) (failed bool) {
+	counts := make(map[Level]int)
+	for _, r := range results {
+		counts[r.Level]++
+		fmt.Fprintf(w, "[%s] %s: %s\n", r.Level, r.Name, r.Message)
+		if r.Hint != "" && r.Level != Pass {
+			fmt.Fprintf(w, "       hint: %s\n", r.Hint)
+		}
+	}
+	fmt.Fprintf(w, "\n%d passed, %d warnings, %d failed\n", counts[Pass], counts[Warn], counts[Fail])
+	return counts[Fail] > 0
+}
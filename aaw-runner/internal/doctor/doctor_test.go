@@ -0,0 +1,143 @@
+package doctor
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeEnv returns an Env where every check passes
+func fakeEnv(now time.Time) Env {
+	return Env{
+		LookPath: func(string) (string, error) { return "/usr/local/bin/claude", nil },
+		Command:  func(string, ...string) ([]byte, error) { return []byte("1.0.3 (Claude Code)\n"), nil },
+		Dial: func(string) (http.Header, error) {
+			return http.Header{"Date": {now.UTC().Format(http.TimeFormat)}}, nil
+		},
+		Now:    func() time.Time { return now },
+		Getwd:  os.Getwd,
+		NoFile: func() (uint64, uint64, error) { return 65536, 65536, nil },
+	}
+}
+
+// TestCheckClaude verifies the claude binary is resolved and version-checked
+func TestCheckClaude(t *testing.T) {
+	env := fakeEnv(time.Now())
+	r := CheckClaude("claude", env.LookPath, env.Command)
+	assert.Equal(t, Pass, r.Level)
+	assert.Equal(t, "/usr/local/bin/claude (1.0.3 (Claude Code))", r.Message)
+
+	r = CheckClaude("claude", func(string) (string, error) { return "", errors.New("executable file not found in $PATH") }, env.Command)
+	assert.Equal(t, Fail, r.Level)
+	assert.Contains(t, r.Hint, "PATH")
+
+	r = CheckClaude("claude", env.LookPath, func(string, ...string) ([]byte, error) { return nil, errors.New("exit status 1") })
+	assert.Equal(t, Warn, r.Level)
+}
+
+// TestCheckBackend verifies handshake failures are reported with the URL
+func TestCheckBackend(t *testing.T) {
+	r, header := CheckBackend("ws://backend/ws/logs", fakeEnv(time.Now()).Dial)
+	assert.Equal(t, Pass, r.Level)
+	assert.NotEmpty(t, header.Get("Date"))
+
+	r, header = CheckBackend("ws://typo/ws/logs", func(string) (http.Header, error) { return nil, errors.New("no such host") })
+	assert.Equal(t, Fail, r.Level)
+	assert.Contains(t, r.Message, "ws://typo/ws/logs")
+	assert.Nil(t, header)
+}
+
+// TestCheckClock verifies skew against the backend's Date header is graded
+func TestCheckClock(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	date := func(d time.Duration) string { return now.Add(d).Format(http.TimeFormat) }
+
+	assert.Equal(t, Pass, CheckClock(date(2*time.Second), now).Level)
+	assert.Equal(t, Warn, CheckClock(date(-time.Minute), now).Level)
+	r := CheckClock(date(10*time.Minute), now)
+	assert.Equal(t, Fail, r.Level)
+	assert.Equal(t, "local clock is 10m0s off the backend's", r.Message)
+	assert.Equal(t, Warn, CheckClock("", now).Level)
+	assert.Equal(t, Warn, CheckClock("yesterday", now).Level)
+}
+
+// TestCheckWritableDir verifies existing, missing and unusable directories
+func TestCheckWritableDir(t *testing.T) {
+	dir := t.TempDir()
+	assert.Equal(t, Pass, CheckWritableDir("state dir", "state-dir", dir).Level)
+
+	r := CheckWritableDir("state dir", "state-dir", filepath.Join(dir, "a", "b"))
+	assert.Equal(t, Pass, r.Level)
+	assert.Contains(t, r.Message, "can be created under "+dir)
+	assert.NoDirExists(t, filepath.Join(dir, "a"), "The check must not create anything")
+
+	file := filepath.Join(dir, "file")
+	assert.NoError(t, os.WriteFile(file, nil, 0o644))
+	r = CheckWritableDir("workdir", "workdir", file)
+	assert.Equal(t, Fail, r.Level)
+	assert.Contains(t, r.Hint, "--workdir")
+
+	if os.Geteuid() != 0 {
+		readOnly := filepath.Join(dir, "ro")
+		assert.NoError(t, os.Mkdir(readOnly, 0o555))
+		assert.Equal(t, Fail, CheckWritableDir("log dir", "log-file", readOnly).Level)
+	}
+	probes, _ := filepath.Glob(filepath.Join(dir, ".aaw-doctor-*"))
+	assert.Empty(t, probes, "Probe files are cleaned up")
+}
+
+// TestCheckPatternFiles verifies matcher patterns and severity rules files are validated
+func TestCheckPatternFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		return path
+	}
+
+	assert.Equal(t, Pass, CheckMatcherPatterns("").Level)
+	assert.Equal(t, Pass, CheckMatcherPatterns(write("ok.json", `{"categories":{"AUTH":{"exclude":["mock"]}}}`)).Level)
+	r := CheckMatcherPatterns(write("bad.json", `{"categories":{"NOPE":{}}}`))
+	assert.Equal(t, Fail, r.Level)
+	assert.Contains(t, r.Message, "NOPE")
+
+	assert.Equal(t, Pass, CheckSeverityRules("").Level)
+	assert.Equal(t, Pass, CheckSeverityRules(write("rules.json", `[{"severity":"warn","pattern":"slow"}]`)).Level)
+	assert.Equal(t, Fail, CheckSeverityRules(write("badrules.json", `[{"severity":"loud","pattern":"x"}]`)).Level)
+	assert.Equal(t, Fail, CheckSeverityRules(filepath.Join(dir, "missing.json")).Level)
+}
+
+// TestCheckFileLimit verifies the open files limit thresholds
+func TestCheckFileLimit(t *testing.T) {
+	limit := func(cur uint64) func() (uint64, uint64, error) {
+		return func() (uint64, uint64, error) { return cur, 1 << 20, nil }
+	}
+	assert.Equal(t, Pass, CheckFileLimit(limit(65536)).Level)
+	assert.Equal(t, Warn, CheckFileLimit(limit(1024)).Level)
+	assert.Equal(t, Fail, CheckFileLimit(limit(128)).Level)
+}
+
+// TestRun_Report verifies the full battery and the printed summary
+func TestRun_Report(t *testing.T) {
+	cfg := config.Default()
+	cfg.StateDir = t.TempDir()
+	cfg.Workdir = t.TempDir()
+
+	results := Run(cfg, nil, fakeEnv(time.Now()))
+	var out bytes.Buffer
+	assert.False(t, Print(&out, results))
+	assert.Contains(t, out.String(), "[PASS] clock:")
+	assert.Contains(t, out.String(), "0 failed")
+
+	results = Run(cfg, errors.New("invalid value for --max-parallel"), fakeEnv(time.Now()))
+	out.Reset()
+	assert.True(t, Print(&out, results))
+	assert.Contains(t, out.String(), "[FAIL] config: invalid value for --max-parallel\n       hint: ")
+}
@@ -0,0 +1,25 @@
+package executor
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/berno/aaw-runner/internal/models"
+)
+
+// MaxLoggedArgsBytes caps the arguments quoted in a task's start LOG line; the task gets them whole
+const MaxLoggedArgsBytes = 512
+
+// describeArgs is the part of a start LOG line listing args, each quoted, cut at MaxLoggedArgsBytes
+// Empty when there are none.
+func describeArgs(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = strconv.Quote(arg)
+	}
+	list, _ := models.TruncateText(strings.Join(quoted, " "), MaxLoggedArgsBytes)
+	return " with args " + list
+}
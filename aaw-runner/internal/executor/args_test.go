@@ -0,0 +1,48 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestArgs_PassedAsArgv verifies script arguments reach the task as separate argv entries, never through a
+// shell, and are quoted in its start LOG line
+func TestArgs_PassedAsArgv(t *testing.T) {
+	args := []string{"two words", "; touch pwned", "$(id)"}
+	dir := t.TempDir()
+	script := filepath.Join(dir, "args.sh")
+	assert.NoError(t, os.WriteFile(script, []byte(`for a in "$@"; do echo "[$a]"; done`+"\n"), 0o644))
+	result, output := runPoolTask(t, models.ExecuteMessage{TaskID: 1, Script: script, Args: args})
+	assert.True(t, result.Success, result.Error)
+	assert.Subset(t, output, []string{"[two words]", "[; touch pwned]", "[$(id)]"})
+	assert.Contains(t, output, `Starting execution: `+script+` with args "two words" "; touch pwned" "$(id)"`)
+	assert.NoFileExists(t, filepath.Join(dir, "pwned"))
+
+	testutil.FakeClaude(t, `shift; for a in "$@"; do echo "[$a]"; done`)
+	result, output = runPoolTask(t, models.ExecuteMessage{TaskID: 2, ScriptContent: "prompt", Args: args})
+	assert.True(t, result.Success, result.Error)
+	assert.Subset(t, output, []string{"[two words]", "[; touch pwned]", "[$(id)]"}, "After the prompt")
+	assert.Contains(t, output, `Starting dynamic execution (skip permissions: false) with args "two words" "; touch pwned" "$(id)"`)
+
+	te, rec := recordingExecutor()
+	te.interpreters = []string{"sh"}
+	result, output = runPoolTaskOn(t, te, rec, models.ExecuteMessage{TaskID: 3, ScriptContent: `echo "[$1|$2]"`, Interpreter: "sh", Args: args})
+	assert.True(t, result.Success, result.Error)
+	assert.Contains(t, output, "[two words|; touch pwned]", "From $1, as in a script file")
+}
+
+// TestDescribeArgs verifies the start LOG line quotes each argument and cuts a long list
+func TestDescribeArgs(t *testing.T) {
+	assert.Empty(t, describeArgs(nil))
+	assert.Equal(t, ` with args "a" "b\nc"`, describeArgs([]string{"a", "b\nc"}))
+
+	long := describeArgs([]string{strings.Repeat("x", 2*MaxLoggedArgsBytes)})
+	assert.Less(t, len(long), MaxLoggedArgsBytes+64)
+	assert.Contains(t, long, "truncated")
+}
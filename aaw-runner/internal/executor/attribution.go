@@ -0,0 +1,67 @@
+package executor
+
+import (
+	"time"
+)
+
+// Kinds of termination attempts
+const (
+	TerminationCancel = "CANCEL"
+	TerminationKill   = "KILL"
+)
+
+// Attribution says who asked for a task to be cancelled or killed, and why
+type Attribution struct {
+	RequestedBy string // Backend user or service, or a synthetic requester such as models.RequestedByShutdown
+	Reason      string
+}
+
+// TerminationAttempt is one cancel or kill of a task, reported to the termination observer
+type TerminationAttempt struct {
+	TaskID      int64
+	Kind        string // TerminationCancel or TerminationKill
+	By          Attribution
+	Err         error // Why the attempt failed (nil if the cancel was delivered or the kill verified)
+	RequestedAt time.Time
+	CompletedAt time.Time
+}
+
+// SetTerminationObserver registers fn to hear about every cancel and kill attempt, whatever its outcome
+// Must be called before Start.
+func (p *ExecutorPool) SetTerminationObserver(fn func(TerminationAttempt)) {
+	p.onTermination = fn
+}
+
+// attribute remembers the latest requester of a pending task's termination, to be echoed in its result
+func (p *ExecutorPool) attribute(taskID int64, by Attribution) {
+	p.doneMu.Lock()
+	defer p.doneMu.Unlock()
+	if task, ok := p.schedule[taskID]; ok {
+		task.TerminatedBy = by
+	}
+}
+
+// terminatedBy returns who cancelled or killed a pending task (zero if nobody did)
+func (p *ExecutorPool) terminatedBy(taskID int64) Attribution {
+	p.doneMu.Lock()
+	defer p.doneMu.Unlock()
+	if task, ok := p.schedule[taskID]; ok {
+		return task.TerminatedBy
+	}
+	return Attribution{}
+}
+
+// observeTermination reports a finished cancel or kill attempt
+func (p *ExecutorPool) observeTermination(kind string, taskID int64, by Attribution, requestedAt time.Time, err error) {
+	if p.onTermination == nil {
+		return
+	}
+	p.onTermination(TerminationAttempt{
+		TaskID:      taskID,
+		Kind:        kind,
+		By:          by,
+		Err:         err,
+		RequestedAt: requestedAt,
+		CompletedAt: time.Now(),
+	})
+}
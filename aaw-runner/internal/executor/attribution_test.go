@@ -0,0 +1,93 @@
+package executor
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+var byBackend = Attribution{RequestedBy: "alice@example.com", Reason: "wrong branch"}
+
+// attemptLog collects the attempts reported to a termination observer
+type attemptLog struct {
+	mu       sync.Mutex
+	attempts []TerminationAttempt
+}
+
+func (l *attemptLog) observe(attempt TerminationAttempt) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.attempts = append(l.attempts, attempt)
+}
+
+func (l *attemptLog) all() []TerminationAttempt {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]TerminationAttempt(nil), l.attempts...)
+}
+
+// TestTermination_BackendCancel verifies a backend cancel is reported with its requester and echoed in the result
+func TestTermination_BackendCancel(t *testing.T) {
+	testutil.FakeClaude(t, "sleep 30")
+	completed := make(chan TaskResult, 1)
+	te := NewTaskExecutor(func(models.LogMessage) {}, func(models.StatusUpdateMessage) {})
+	pool := NewExecutorPool(te, 1, nil, func(result TaskResult) { completed <- result })
+	attempts := &attemptLog{}
+	pool.SetTerminationObserver(attempts.observe)
+	pool.Start()
+	defer pool.Stop()
+
+	assert.True(t, pool.Submit(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 50, ScriptContent: "hello"}))
+	waitForRegistration(t, te, 50)
+	assert.NoError(t, pool.CancelTask(50, byBackend))
+	result := <-completed
+
+	assert.Equal(t, byBackend, result.TerminatedBy)
+	if got := attempts.all(); assert.Len(t, got, 1) {
+		assert.Equal(t, int64(50), got[0].TaskID)
+		assert.Equal(t, TerminationCancel, got[0].Kind)
+		assert.Equal(t, byBackend, got[0].By)
+		assert.NoError(t, got[0].Err)
+		assert.False(t, got[0].CompletedAt.Before(got[0].RequestedAt))
+	}
+}
+
+// TestTermination_ShutdownCancel verifies runner-initiated cancellations carry a synthetic requester
+func TestTermination_ShutdownCancel(t *testing.T) {
+	testutil.FakeClaude(t, "sleep 30")
+	completed := make(chan TaskResult, 1)
+	te := NewTaskExecutor(func(models.LogMessage) {}, func(models.StatusUpdateMessage) {})
+	pool := NewExecutorPool(te, 1, nil, func(result TaskResult) { completed <- result })
+	attempts := &attemptLog{}
+	pool.SetTerminationObserver(attempts.observe)
+	pool.Start()
+	defer pool.Stop()
+
+	assert.True(t, pool.Submit(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 51, ScriptContent: "hello"}))
+	waitForRegistration(t, te, 51)
+	assert.Equal(t, 1, pool.CancelAll())
+	result := <-completed
+
+	assert.Equal(t, models.RequestedByShutdown, result.TerminatedBy.RequestedBy)
+	if got := attempts.all(); assert.Len(t, got, 1) {
+		assert.Equal(t, models.RequestedByShutdown, got[0].By.RequestedBy)
+		assert.NoError(t, got[0].Err)
+	}
+}
+
+// TestTermination_FailedKill verifies a kill that cannot be delivered is still reported, with its failure
+func TestTermination_FailedKill(t *testing.T) {
+	pool := newTestPool(1)
+	attempts := &attemptLog{}
+	pool.SetTerminationObserver(attempts.observe)
+
+	pool.TerminateTask(98, byBackend, nil)
+
+	if got := attempts.all(); assert.Len(t, got, 1) {
+		assert.Equal(t, TerminationKill, got[0].Kind)
+		assert.ErrorContains(t, got[0].Err, "not running")
+	}
+}
@@ -0,0 +1,80 @@
+package executor
+
+import (
+	"sync"
+	"time"
+)
+
+// circuitBreaker stops the pool from taking on work after environmental failures that
+// would affect every task (e.g. expired credentials). While open, a single probe task
+// may run at a time; a successful probe or an operator reset closes it again.
+type circuitBreaker struct {
+	mu       sync.Mutex
+	open     bool
+	reason   string
+	openedAt time.Time
+	probe    int64 // Task admitted as the probe while open, zero for none
+}
+
+// trip opens the breaker, returning true if it was previously closed
+func (b *circuitBreaker) trip(reason string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.open {
+		return false
+	}
+	b.open = true
+	b.reason = reason
+	b.openedAt = time.Now()
+	b.probe = 0
+	return true
+}
+
+// admit records taskID as the probe if the breaker is open
+func (b *circuitBreaker) admit(taskID int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.open {
+		b.probe = taskID
+	}
+}
+
+// probeDone is called when a task finishes. If it was the probe it frees the probe slot, and if it
+// succeeded it closes the breaker, returning true. Any other task leaves the breaker as it is: one
+// that was already running when the breaker tripped proves nothing about the environment now.
+func (b *circuitBreaker) probeDone(taskID int64, succeeded bool) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.open || b.probe == 0 || b.probe != taskID {
+		return false
+	}
+	b.probe = 0
+	if !succeeded {
+		return false
+	}
+	b.open = false
+	b.reason = ""
+	b.openedAt = time.Time{}
+	return true
+}
+
+// reset closes the breaker, returning true if it was previously open
+func (b *circuitBreaker) reset() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.open {
+		return false
+	}
+	b.open = false
+	b.reason = ""
+	b.openedAt = time.Time{}
+	b.probe = 0
+	return true
+}
+
+// state returns whether the breaker is open and why
+func (b *circuitBreaker) state() (bool, string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.open, b.reason
+}
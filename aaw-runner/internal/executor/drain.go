@@ -0,0 +1,166 @@
+package executor
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/models"
+)
+
+// Drain stops the pool from accepting new tasks ahead of a shutdown
+// Tasks already submitted keep running; reservations are dropped, and capacity is reported with no
+// available slots from now on
+func (p *ExecutorPool) Drain() {
+	if p.draining.Swap(true) {
+		return
+	}
+	log.Println("[POOL] Draining: no new tasks will be accepted")
+	p.dropReservations(models.ExpiredDraining)
+	p.reportCapacity()
+}
+
+// Undrain accepts new tasks again after Drain
+// Not meant for a pool whose tasks were cancelled by CancelAll: queued tasks would still be failed
+func (p *ExecutorPool) Undrain() {
+	if !p.draining.Swap(false) {
+		return
+	}
+	log.Println("[POOL] Undrained: accepting new tasks")
+	p.reportCapacity()
+}
+
+// Draining reports whether the pool is refusing new tasks
+func (p *ExecutorPool) Draining() bool {
+	return p.draining.Load()
+}
+
+// Pause holds queued tasks: workers start nothing until Resume, while running tasks carry on
+// Tasks are still accepted up to capacity and wait in the queue
+func (p *ExecutorPool) Pause() {
+	p.pauseMu.Lock()
+	defer p.pauseMu.Unlock()
+	if p.resumed != nil {
+		return
+	}
+	p.resumed = make(chan struct{})
+	log.Println("[POOL] Paused: queued tasks will not be started until resumed")
+}
+
+// Resume lets workers start queued tasks again after Pause
+func (p *ExecutorPool) Resume() {
+	p.pauseMu.Lock()
+	defer p.pauseMu.Unlock()
+	if p.resumed == nil {
+		return
+	}
+	close(p.resumed)
+	p.resumed = nil
+	log.Println("[POOL] Resumed: starting queued tasks")
+}
+
+// Paused reports whether queued tasks are being held
+func (p *ExecutorPool) Paused() bool {
+	p.pauseMu.Lock()
+	defer p.pauseMu.Unlock()
+	return p.resumed != nil
+}
+
+// waitWhilePaused blocks a worker about to start taskID until the pool is resumed
+// Returns false if the pool was stopped or its tasks cancelled while waiting
+func (p *ExecutorPool) waitWhilePaused(workerID int, taskID int64) bool {
+	p.pauseMu.Lock()
+	resumed := p.resumed
+	p.pauseMu.Unlock()
+	if resumed == nil {
+		return true
+	}
+
+	log.Printf("[POOL] Worker %d holding task %d while paused", workerID, taskID)
+	select {
+	case <-p.stopChan:
+		return false
+	case <-p.abortChan:
+		return false
+	case <-resumed:
+		return true
+	}
+}
+
+// WaitIdle blocks until every submitted task has reported completion
+// Returns false if tasks were still pending when the timeout expired
+func (p *ExecutorPool) WaitIdle(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for _, taskID := range p.pendingTasks() {
+		if !p.waitForCompletion(taskID, time.Until(deadline)) {
+			return false
+		}
+	}
+	return true
+}
+
+// shutdown attributes the cancellations and kills of CancelAll and KillAll
+var shutdown = Attribution{RequestedBy: models.RequestedByShutdown, Reason: "runner shutdown"}
+
+// CancelAll cancels every task still pending at shutdown and returns how many there were
+// Running tasks go through the normal cancel path and report CANCELLED; queued tasks, including
+// those held by the backoff, report RUNNER_SHUTDOWN without being started
+func (p *ExecutorPool) CancelAll() int {
+	p.abortOnce.Do(func() { close(p.abortChan) })
+
+	pending := p.pendingTasks()
+	var wg sync.WaitGroup
+	for _, taskID := range pending {
+		if !p.executor.IsTaskRunning(taskID) {
+			continue
+		}
+		wg.Add(1)
+		go func(taskID int64) {
+			defer wg.Done()
+			if err := p.CancelTask(taskID, shutdown); err != nil {
+				log.Printf("[POOL] Failed to cancel task %d at shutdown: %v", taskID, err)
+			}
+		}(taskID)
+	}
+	wg.Wait()
+	return len(pending)
+}
+
+// KillAll force-kills every running task's process group and fails queued tasks, returning how many were pending
+// The escalation of CancelAll when an operator will not wait: killed tasks report CANCELLED, queued
+// tasks RUNNER_SHUTDOWN
+func (p *ExecutorPool) KillAll() int {
+	p.abortOnce.Do(func() { close(p.abortChan) })
+
+	pending := p.pendingTasks()
+	for _, taskID := range pending {
+		if !p.executor.IsTaskRunning(taskID) {
+			continue
+		}
+		if err := p.ForceKillTask(taskID, shutdown); err != nil {
+			log.Printf("[POOL] Failed to kill task %d at shutdown: %v", taskID, err)
+		}
+	}
+	return len(pending)
+}
+
+// pendingTasks lists the tasks submitted but not yet completed
+func (p *ExecutorPool) pendingTasks() []int64 {
+	p.doneMu.Lock()
+	defer p.doneMu.Unlock()
+	ids := make([]int64, 0, len(p.done))
+	for taskID := range p.done {
+		ids = append(ids, taskID)
+	}
+	return ids
+}
+
+// aborted reports whether CancelAll has been called
+func (p *ExecutorPool) aborted() bool {
+	select {
+	case <-p.abortChan:
+		return true
+	default:
+		return false
+	}
+}
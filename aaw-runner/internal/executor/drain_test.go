@@ -0,0 +1,122 @@
+package executor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/matcher"
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestDrain_RejectsNewTasks verifies a draining pool refuses tasks and advertises no slots
+func TestDrain_RejectsNewTasks(t *testing.T) {
+	pool := newTestPool(2)
+	pool.Drain()
+
+	assert.True(t, pool.Draining())
+	assert.False(t, pool.CanAccept())
+	assert.False(t, pool.Submit(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 1, ScriptContent: "hello"}))
+	code, _ := pool.RejectReason()
+	assert.Equal(t, models.ErrorCodeRunnerShutdown, code)
+	_, _, available := pool.GetCapacity()
+	assert.Zero(t, available)
+}
+
+// TestWaitIdle_TimesOutWhileTasksPending verifies WaitIdle only succeeds once every task has completed
+func TestWaitIdle_TimesOutWhileTasksPending(t *testing.T) {
+	testutil.FakeClaude(t, "sleep 0.3")
+	te := NewTaskExecutor(func(models.LogMessage) {}, func(models.StatusUpdateMessage) {})
+	pool := NewExecutorPool(te, 1, nil, nil)
+	pool.Start()
+	defer pool.Stop()
+
+	assert.True(t, pool.WaitIdle(0), "An empty pool is idle")
+	assert.True(t, pool.Submit(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 2, ScriptContent: "hello"}))
+	pool.Drain()
+
+	assert.False(t, pool.WaitIdle(50*time.Millisecond))
+	assert.True(t, pool.WaitIdle(5*time.Second))
+}
+
+// TestCancelAll_FailsHeldTasks verifies tasks held by the backoff report RUNNER_SHUTDOWN without starting
+func TestCancelAll_FailsHeldTasks(t *testing.T) {
+	results := make(chan TaskResult, 1)
+	te := NewTaskExecutor(func(models.LogMessage) {}, func(models.StatusUpdateMessage) {})
+	pool := NewExecutorPool(te, 1, nil, func(result TaskResult) { results <- result })
+	pool.onDetection(1, matcher.CategoryUsageLimit, time.Time{})
+	pool.Start()
+	defer pool.Stop()
+
+	assert.True(t, pool.Submit(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 3, ScriptContent: "hello"}))
+	pool.Drain()
+	assert.Equal(t, 1, pool.CancelAll())
+
+	select {
+	case result := <-results:
+		assert.Equal(t, int64(3), result.TaskID)
+		assert.Equal(t, models.ErrorCodeRunnerShutdown, result.ErrorCode)
+	case <-time.After(time.Second):
+		t.Fatal("held task was not reported")
+	}
+	assert.True(t, pool.WaitIdle(time.Second))
+}
+
+// TestKillAll_KillsProcessGroups verifies running tasks are SIGKILLed even when they ignore SIGTERM
+func TestKillAll_KillsProcessGroups(t *testing.T) {
+	testutil.FakeClaude(t, "trap '' TERM; sleep 30 & sleep 30")
+	results := make(chan TaskResult, 1)
+	te := NewTaskExecutor(func(models.LogMessage) {}, func(models.StatusUpdateMessage) {})
+	pool := NewExecutorPool(te, 1, nil, func(result TaskResult) { results <- result })
+	pool.Start()
+	defer pool.Stop()
+
+	assert.True(t, pool.Submit(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 4, ScriptContent: "hello"}))
+	waitForRegistration(t, te, 4)
+	pgid, _ := te.processGroup(4)
+	pool.Drain()
+
+	assert.Equal(t, 1, pool.KillAll())
+
+	select {
+	case result := <-results:
+		assert.Equal(t, models.ErrorCodeCancelled, result.ErrorCode)
+	case <-time.After(5 * time.Second):
+		t.Fatal("killed task was not reported")
+	}
+	assert.Empty(t, waitForGroupExit(pgid, 2*time.Second), "No process from the task's group may survive")
+}
+
+// TestPause_HoldsQueuedTasks verifies a paused pool accepts tasks but starts them only after Resume
+func TestPause_HoldsQueuedTasks(t *testing.T) {
+	testutil.FakeClaude(t, "exit 0")
+	completed := make(chan TaskResult, 1)
+	te := NewTaskExecutor(func(models.LogMessage) {}, func(models.StatusUpdateMessage) {})
+	pool := NewExecutorPool(te, 1, nil, func(result TaskResult) { completed <- result })
+	pool.Start()
+	defer pool.Stop()
+
+	pool.Pause()
+	assert.True(t, pool.Paused())
+	assert.True(t, pool.Submit(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 3, ScriptContent: "hello"}))
+
+	select {
+	case <-completed:
+		t.Fatal("Task started while paused")
+	case <-time.After(200 * time.Millisecond):
+	}
+	tasks := pool.Tasks()
+	if assert.Len(t, tasks, 1) {
+		assert.True(t, tasks[0].StartedAt.IsZero(), "Task should still be queued")
+	}
+
+	pool.Resume()
+	assert.False(t, pool.Paused())
+	select {
+	case result := <-completed:
+		assert.True(t, result.Success)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Task did not run after Resume")
+	}
+}
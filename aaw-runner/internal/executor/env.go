@@ -0,0 +1,69 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/berno/aaw-runner/internal/models"
+)
+
+// checkEnvName reports why name cannot be an environment variable name, if it cannot
+func checkEnvName(name string) error {
+	switch {
+	case name == "":
+		return errors.New("env has a variable with an empty name")
+	case strings.Contains(name, "="):
+		return fmt.Errorf("env variable name %q contains '='", name)
+	case strings.Contains(name, "\x00"):
+		return fmt.Errorf("env variable name %q contains NUL", name)
+	}
+	return nil
+}
+
+// prepareEnv adds the environment variables msg sets to its task's options, after checking them
+// Nothing is run when one cannot be set; the task fails with ErrorCodeInvalidTask. Values are never
+// logged, not even in that failure.
+func (te *TaskExecutor) prepareEnv(msg models.ExecuteMessage, opts *TaskOptions) error {
+	if len(msg.Env) == 0 {
+		return nil
+	}
+	for _, name := range slices.Sorted(maps.Keys(msg.Env)) {
+		if err := checkEnvName(name); err != nil {
+			return te.invalidTask(msg.TaskID, err)
+		}
+		if strings.Contains(msg.Env[name], "\x00") {
+			return te.invalidTask(msg.TaskID, fmt.Errorf("env variable %s has a NUL in its value", name))
+		}
+	}
+	opts.env = maps.Clone(msg.Env)
+	return nil
+}
+
+// envVars returns the environment variables the task sets, as "name=value" sorted by name, nil when none
+// Appended to the runner's own environment, they override the variables of the same name.
+func (o TaskOptions) envVars() []string {
+	if len(o.env) == 0 {
+		return nil
+	}
+	vars := make([]string, 0, len(o.env))
+	for _, name := range slices.Sorted(maps.Keys(o.env)) {
+		vars = append(vars, name+"="+o.env[name])
+	}
+	return vars
+}
+
+// remoteEnv returns the environment of the task run on an SSH host: the host's env, overridden by the task's
+func (o TaskOptions) remoteEnv(hostEnv map[string]string) map[string]string {
+	if len(o.env) == 0 {
+		return hostEnv
+	}
+	merged := maps.Clone(hostEnv)
+	if merged == nil {
+		merged = make(map[string]string, len(o.env))
+	}
+	maps.Copy(merged, o.env)
+	return merged
+}
@@ -0,0 +1,98 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// runPoolTask runs msg through a pool and returns its result and output
+func runPoolTask(t *testing.T, msg models.ExecuteMessage) (TaskResult, []string) {
+	t.Helper()
+	te, rec := recordingExecutor()
+	return runPoolTaskOn(t, te, rec, msg)
+}
+
+// runPoolTaskOn is runPoolTask with a given executor, recording its output in rec
+func runPoolTaskOn(t *testing.T, te *TaskExecutor, rec *messageRecorder, msg models.ExecuteMessage) (TaskResult, []string) {
+	t.Helper()
+	results := make(chan TaskResult, 1)
+	pool := NewExecutorPool(te, 1, nil, func(result TaskResult) { results <- result })
+	pool.Start()
+	defer pool.Stop()
+
+	msg.Type = models.TypeExecute
+	assert.True(t, pool.Submit(msg))
+	result := <-results
+	return result, logLines(rec)
+}
+
+// TestEnv_OverridesInherited verifies a task sees the runner's environment with its env on top, for
+// script-path and inline tasks alike
+func TestEnv_OverridesInherited(t *testing.T) {
+	t.Setenv("AAW_TEST_INHERITED", "runner")
+	t.Setenv("AAW_TEST_BRANCH", "runner")
+	env := map[string]string{"AAW_TEST_BRANCH": "feature/x", "AAW_TEST_FLAG": "a b=c"}
+	printEnv := `echo "$AAW_TEST_INHERITED|$AAW_TEST_BRANCH|$AAW_TEST_FLAG"`
+
+	script := filepath.Join(t.TempDir(), "env.sh")
+	assert.NoError(t, os.WriteFile(script, []byte(printEnv+"\n"), 0o644))
+	result, output := runPoolTask(t, models.ExecuteMessage{TaskID: 1, Script: script, Env: env})
+	assert.True(t, result.Success, result.Error)
+	assert.Contains(t, output, "runner|feature/x|a b=c")
+
+	testutil.FakeClaude(t, printEnv)
+	result, output = runPoolTask(t, models.ExecuteMessage{TaskID: 2, ScriptContent: "hello", Env: env})
+	assert.True(t, result.Success, result.Error)
+	assert.Contains(t, output, "runner|feature/x|a b=c")
+
+	result, output = runPoolTask(t, models.ExecuteMessage{TaskID: 3, ScriptContent: "hello"})
+	assert.True(t, result.Success, result.Error)
+	assert.Contains(t, output, "runner|runner|", "Without env the runner's environment is inherited as is")
+}
+
+// TestEnv_InvalidNames verifies an env that cannot be set fails the task before anything starts, naming
+// the variable but not echoing its value
+func TestEnv_InvalidNames(t *testing.T) {
+	testutil.FakeClaude(t, "echo started")
+	cases := map[string]struct {
+		env  map[string]string
+		want string
+	}{
+		"equals": {env: map[string]string{"A=B": "v4lue"}, want: `env variable name "A=B" contains '='`},
+		"nul":    {env: map[string]string{"A\x00B": "v4lue"}, want: `env variable name "A\x00B" contains NUL`},
+		"empty":  {env: map[string]string{"": "v4lue"}, want: "env has a variable with an empty name"},
+		"value":  {env: map[string]string{"TOKEN": "v4\x00lue"}, want: "env variable TOKEN has a NUL in its value"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			result, output := runPoolTask(t, models.ExecuteMessage{TaskID: 4, ScriptContent: "hello", Env: tc.env})
+			assert.False(t, result.Success)
+			assert.Equal(t, models.ErrorCodeInvalidTask, result.ErrorCode)
+			assert.Contains(t, result.Error, tc.want)
+			assert.NotContains(t, output, "started", "Nothing ran")
+			for _, line := range output {
+				assert.NotContains(t, line, "v4")
+			}
+		})
+	}
+}
+
+// TestRemoteEnv verifies a remote task's env overrides the host's, without changing the host's own map
+func TestRemoteEnv(t *testing.T) {
+	te, _ := recordingExecutor()
+	hostEnv := map[string]string{"REGION": "eu", "BRANCH": "main"}
+	var opts TaskOptions
+	assert.Equal(t, hostEnv, opts.remoteEnv(hostEnv))
+
+	assert.NoError(t, te.prepareEnv(models.ExecuteMessage{TaskID: 5, Env: map[string]string{"BRANCH": "feature/x"}}, &opts))
+	assert.Equal(t, map[string]string{"REGION": "eu", "BRANCH": "feature/x"}, opts.remoteEnv(hostEnv))
+	assert.Equal(t, "main", hostEnv["BRANCH"])
+	assert.Equal(t, map[string]string{"BRANCH": "feature/x"}, opts.remoteEnv(nil))
+	assert.True(t, strings.HasPrefix(opts.envVars()[0], "BRANCH="))
+}
@@ -0,0 +1,84 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"sync"
+)
+
+// errStdinClosed is returned by writes to an interactive task's stdin once it has been closed
+var errStdinClosed = errors.New("stdin already closed")
+
+// taskStdin is the write end of an interactive task's stdin
+// Writes are serialized, so the data of successive STDIN_INPUT messages is never interleaved.
+type taskStdin struct {
+	mu     sync.Mutex
+	w      io.WriteCloser
+	closed bool
+}
+
+// write writes data, then closes stdin when closeAfter is set, and returns the bytes written
+func (s *taskStdin) write(data string, closeAfter bool) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return 0, errStdinClosed
+	}
+	n, err := io.WriteString(s.w, data)
+	if err != nil {
+		return n, err
+	}
+	if closeAfter {
+		s.closed = true
+		return n, s.w.Close()
+	}
+	return n, nil
+}
+
+// openStdin connects an interactive task's stdin to a pipe WriteStdin writes to; nil for other tasks
+// Call it before cmd.Start. cmd.Wait closes the pipe once the process has exited.
+func openStdin(opts TaskOptions, cmd *exec.Cmd) (*taskStdin, error) {
+	if !opts.interactive {
+		return nil, nil
+	}
+	w, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	return &taskStdin{w: w}, nil
+}
+
+// WriteStdin writes data to the stdin of a running task started with interactive stdin, then closes
+// it when closeAfter is set. It returns the bytes written, and fails for a task that is not running,
+// not interactive, or whose stdin was closed.
+func (te *TaskExecutor) WriteStdin(taskID int64, data string, closeAfter bool) (int, error) {
+	task, exists := te.getRunningTask(taskID)
+	if !exists {
+		return 0, fmt.Errorf("task %d is not running", taskID)
+	}
+	if task.stdin == nil {
+		return 0, fmt.Errorf("task %d was not started with interactive stdin", taskID)
+	}
+	n, err := task.stdin.write(data, closeAfter)
+	if err != nil {
+		return n, fmt.Errorf("task %d: %w", taskID, err)
+	}
+	return n, nil
+}
+
+// WriteStdin writes data to the stdin of a running interactive task (see TaskExecutor.WriteStdin)
+func (p *ExecutorPool) WriteStdin(taskID int64, data string, closeAfter bool) (int, error) {
+	n, err := p.executor.WriteStdin(taskID, data, closeAfter)
+	if err != nil {
+		return n, err
+	}
+	if closeAfter {
+		log.Printf("[POOL] Wrote %d bytes to the stdin of task %d and closed it", n, taskID)
+	} else {
+		log.Printf("[POOL] Wrote %d bytes to the stdin of task %d", n, taskID)
+	}
+	return n, nil
+}
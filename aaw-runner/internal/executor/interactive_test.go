@@ -0,0 +1,60 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestInteractive_WriteStdin verifies WriteStdin answers a prompt of a running interactive task, and
+// that closing its stdin gives the task EOF
+func TestInteractive_WriteStdin(t *testing.T) {
+	testutil.FakeClaude(t, `read -r line; echo "got $line"; cat >/dev/null; echo eof`)
+	te, rec := recordingExecutor()
+	results := make(chan TaskResult, 1)
+	pool := NewExecutorPool(te, 1, nil, func(result TaskResult) { results <- result })
+	pool.Start()
+	defer pool.Stop()
+
+	assert.True(t, pool.Submit(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 1, ScriptContent: "ask", Interactive: true}))
+	waitForRegistration(t, te, 1)
+	n, err := pool.WriteStdin(1, "yes\n", false)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, n)
+	n, err = pool.WriteStdin(1, "", true)
+	assert.NoError(t, err)
+	assert.Zero(t, n)
+
+	result := <-results
+	assert.True(t, result.Success, result.Error)
+	assert.Subset(t, logLines(rec), []string{"got yes", "eof"})
+
+	_, err = pool.WriteStdin(1, "late\n", false)
+	assert.ErrorContains(t, err, "task 1 is not running")
+}
+
+// TestInteractive_Rejected verifies WriteStdin fails for a task not started interactive and once the
+// task's stdin is closed
+func TestInteractive_Rejected(t *testing.T) {
+	testutil.FakeClaude(t, "sleep 5")
+	te, _ := recordingExecutor()
+	pool := NewExecutorPool(te, 2, nil, nil)
+	pool.Start()
+	defer pool.Stop()
+	defer pool.KillAll()
+
+	assert.True(t, pool.Submit(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 2, ScriptContent: "wait"}))
+	assert.True(t, pool.Submit(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 3, ScriptContent: "wait", Interactive: true}))
+	waitForRegistration(t, te, 2)
+	waitForRegistration(t, te, 3)
+
+	_, err := pool.WriteStdin(2, "yes\n", false)
+	assert.ErrorContains(t, err, "was not started with interactive stdin")
+
+	_, err = pool.WriteStdin(3, "yes\n", true)
+	assert.NoError(t, err)
+	_, err = pool.WriteStdin(3, "again\n", false)
+	assert.ErrorIs(t, err, errStdinClosed)
+}
@@ -0,0 +1,65 @@
+package executor
+
+import (
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/berno/aaw-runner/internal/models"
+)
+
+// interpreter is a program a task runs under
+type interpreter struct {
+	name string // As an EXECUTE names it
+	path string // Executable run
+}
+
+// shells take the argument after -c's command as $0, so inline scripts get the shell's name there and
+// their own arguments from $1, as script-path ones do
+var shells = map[string]bool{"bash": true, "sh": true, "zsh": true, "dash": true, "ksh": true}
+
+// args is the argv (after the program) running target under the interpreter, followed by the task's
+// own arguments: target is a script path, or inline content, which claude takes as its prompt and any
+// other interpreter with -c
+func (i interpreter) args(target string, inline, skipPermissions bool, extra []string) []string {
+	var args []string
+	switch {
+	case i.name == models.InterpreterClaude:
+		if skipPermissions {
+			args = append(args, "--dangerously-skip-permissions")
+		}
+		args = append(args, target)
+	case inline:
+		args = append(args, "-c", target)
+		if shells[i.name] && len(extra) > 0 {
+			args = append(args, i.name)
+		}
+	default:
+		args = append(args, target)
+	}
+	return append(args, extra...)
+}
+
+// prepareInterpreter checks that the interpreter msg names is allowed and installed, and adds it to the
+// task's options. Nothing is run otherwise: the task fails with ErrorCodeInvalidTask when it is not allowed,
+// ErrorCodeEnvironment when it is not installed.
+func (te *TaskExecutor) prepareInterpreter(msg models.ExecuteMessage, opts *TaskOptions) error {
+	if !slices.Contains(te.interpreters, msg.Interpreter) {
+		return te.invalidTask(msg.TaskID, fmt.Errorf("interpreter %q is not allowed on this runner (allowed: %s)",
+			msg.Interpreter, strings.Join(te.interpreters, ", ")))
+	}
+	program := msg.Interpreter
+	if program == models.InterpreterClaude {
+		program = te.claudePath
+	}
+	path, err := exec.LookPath(program)
+	if err != nil {
+		errMsg := fmt.Sprintf("Interpreter %s unavailable on runner: %v", msg.Interpreter, err)
+		te.logCallback(models.NewLogMessage(msg.TaskID, errMsg, true))
+		te.flushOutput(msg.TaskID)
+		return newTaskError(models.ErrorCodeEnvironment, "%s", errMsg)
+	}
+	opts.interp = interpreter{name: msg.Interpreter, path: path}
+	return nil
+}
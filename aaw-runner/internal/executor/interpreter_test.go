@@ -0,0 +1,76 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestInterpreter_Args verifies the argv of each kind of task under claude and under another interpreter
+func TestInterpreter_Args(t *testing.T) {
+	claude := interpreter{name: models.InterpreterClaude, path: "/usr/bin/claude"}
+	assert.Equal(t, []string{"--dangerously-skip-permissions", "fix it"}, claude.args("fix it", true, true, nil))
+	assert.Equal(t, []string{"fix it"}, claude.args("fix it", true, false, nil))
+
+	sh := interpreter{name: "sh", path: "/bin/sh"}
+	assert.Equal(t, []string{"-c", "echo hi"}, sh.args("echo hi", true, true, nil))
+	assert.Equal(t, []string{"/srv/job.sh"}, sh.args("/srv/job.sh", false, false, nil))
+
+	args := []string{"a b", "$HOME"}
+	assert.Equal(t, []string{"fix it", "a b", "$HOME"}, claude.args("fix it", true, false, args))
+	assert.Equal(t, []string{"-c", "echo $1", "sh", "a b", "$HOME"}, sh.args("echo $1", true, false, args), "The shell's name is $0")
+	assert.Equal(t, []string{"/srv/job.sh", "a b", "$HOME"}, sh.args("/srv/job.sh", false, false, args))
+	python := interpreter{name: "python3", path: "/usr/bin/python3"}
+	assert.Equal(t, []string{"-c", "import sys", "a b", "$HOME"}, python.args("import sys", true, false, args))
+}
+
+// TestInterpreter_RunsTask verifies inline and script-path tasks run under the interpreter their EXECUTE
+// names, and say so when they start
+func TestInterpreter_RunsTask(t *testing.T) {
+	testutil.FakeClaude(t, "echo claude")
+	te, rec := recordingExecutor()
+	te.interpreters = []string{"sh", models.InterpreterClaude}
+	result, output := runPoolTaskOn(t, te, rec, models.ExecuteMessage{TaskID: 1, ScriptContent: "echo ran inline", Interpreter: "sh"})
+	assert.True(t, result.Success, result.Error)
+	assert.Contains(t, output, "ran inline")
+	assert.Contains(t, output, "Starting dynamic execution (skip permissions: false) under sh")
+	assert.NotContains(t, output, "claude")
+
+	script := filepath.Join(t.TempDir(), "job.sh")
+	assert.NoError(t, os.WriteFile(script, []byte(`echo "$BASH_VERSION"`+"\n"), 0o644))
+	te, rec = recordingExecutor()
+	te.interpreters = []string{"sh"}
+	result, output = runPoolTaskOn(t, te, rec, models.ExecuteMessage{TaskID: 2, Script: script, Interpreter: "sh"})
+	assert.True(t, result.Success, result.Error)
+	assert.Contains(t, output, "Starting execution: "+script+" under sh")
+
+	result, output = runPoolTask(t, models.ExecuteMessage{TaskID: 3, ScriptContent: "hello", Interpreter: models.InterpreterClaude})
+	assert.True(t, result.Success, result.Error)
+	assert.Contains(t, output, "claude")
+}
+
+// TestInterpreter_Refused verifies an interpreter that is not allowed, or not installed, fails the task
+// before anything starts
+func TestInterpreter_Refused(t *testing.T) {
+	testutil.FakeClaude(t, "echo started")
+	te, rec := recordingExecutor()
+	te.interpreters = []string{"bash", "no-such-interpreter"}
+	var opts TaskOptions
+	err := te.prepareInterpreter(models.ExecuteMessage{TaskID: 4, ScriptContent: "import os", Interpreter: "python3"}, &opts)
+	assert.Equal(t, models.ErrorCodeInvalidTask, ErrorCode(err))
+	assert.ErrorContains(t, err, `interpreter "python3" is not allowed on this runner (allowed: bash, no-such-interpreter)`)
+
+	err = te.prepareInterpreter(models.ExecuteMessage{TaskID: 5, ScriptContent: "hi", Interpreter: "no-such-interpreter"}, &opts)
+	assert.Equal(t, models.ErrorCodeEnvironment, ErrorCode(err))
+	assert.ErrorContains(t, err, "Interpreter no-such-interpreter unavailable on runner")
+	assert.Len(t, logLines(rec), 2)
+	assert.Zero(t, opts.interp)
+
+	result, output := runPoolTask(t, models.ExecuteMessage{TaskID: 6, ScriptContent: "hi", Interpreter: "zsh"})
+	assert.Equal(t, models.ErrorCodeInvalidTask, result.ErrorCode, "Not allowed by default")
+	assert.NotContains(t, output, "started")
+}
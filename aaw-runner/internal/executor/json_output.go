@@ -0,0 +1,73 @@
+package executor
+
+import (
+	"strings"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/streamjson"
+)
+
+// jsonOutputArgs are the claude flags making it print stream-json events, one per line
+var jsonOutputArgs = []string{"--print", "--output-format", "stream-json", "--verbose"}
+
+// outputFormatArgs returns the claude flags for the output format the task's EXECUTE asked for, none for text
+func (o TaskOptions) outputFormatArgs() []string {
+	if !o.jsonOutput {
+		return nil
+	}
+	return jsonOutputArgs
+}
+
+// processEvent forwards a stream-json event of a task: assistant text as LOG lines, tool calls as
+// TOOL_EVENTs, and the final result kept for the task's completion report
+func (te *TaskExecutor) processEvent(output *taskOutput, event streamjson.Event) {
+	for _, text := range event.Text {
+		for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+			te.forwardLine(output, []byte(line), false)
+		}
+	}
+	for _, call := range event.Tools {
+		var msg models.ToolEventMessage
+		if call.Phase == streamjson.PhaseUse {
+			msg = models.NewToolUse(output.taskID, call.ID, call.Name, te.maskText(output, string(call.Input)))
+		} else {
+			msg = models.NewToolResult(output.taskID, call.ID, te.maskText(output, call.Output), call.IsError)
+		}
+		te.output.tool(msg)
+	}
+	if event.Result != nil {
+		result := *event.Result
+		result.Result = te.maskText(output, result.Result)
+		te.recordClaudeResult(output.taskID, &result)
+	}
+}
+
+// maskText hides secrets and a task's sensitive variable values in text, as they are in its LOG lines
+func (te *TaskExecutor) maskText(output *taskOutput, text string) string {
+	if te.masker != nil {
+		text = te.masker.Mask(text)
+	}
+	if len(output.sensitive) > 0 {
+		text = maskValues(text, output.sensitive)
+	}
+	return text
+}
+
+// recordClaudeResult keeps the final result claude reported for a task, for its completion report
+func (te *TaskExecutor) recordClaudeResult(taskID int64, result *models.ClaudeResult) {
+	te.tailsMu.Lock()
+	defer te.tailsMu.Unlock()
+	if te.claudeResults == nil {
+		te.claudeResults = make(map[int64]*models.ClaudeResult)
+	}
+	te.claudeResults[taskID] = result
+}
+
+// takeClaudeResult returns and forgets the result claude reported for a task, nil if there is none
+func (te *TaskExecutor) takeClaudeResult(taskID int64) *models.ClaudeResult {
+	te.tailsMu.Lock()
+	defer te.tailsMu.Unlock()
+	result := te.claudeResults[taskID]
+	delete(te.claudeResults, taskID)
+	return result
+}
@@ -0,0 +1,89 @@
+package executor
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// streamJSONClaude is a fake claude printing its flags, then a short stream-json run and a malformed line
+const streamJSONClaude = `echo "args:$*" >&2
+cat <<'EOF'
+{"type":"system","subtype":"init","session_id":"5e6f7a8b-1c2d"}
+{"type":"assistant","message":{"content":[{"type":"text","text":"Looking at it.\nUsing token s3cr3t-value"}]}}
+{"type":"assistant","message":{"content":[{"type":"tool_use","id":"toolu_1","name":"Bash","input":{"command":"ls"}}]}}
+{"type":"user","message":{"content":[{"type":"tool_result","tool_use_id":"toolu_1","content":"main.go"}]}}
+{"type":"assistant","message":{"content":[{"type":"text","text":"cut
+{"type":"result","subtype":"success","is_error":false,"num_turns":2,"result":"Done with s3cr3t-value","total_cost_usd":0.01,"usage":{"input_tokens":10,"output_tokens":5}}
+EOF`
+
+// toolRecorder collects the TOOL_EVENTs emitted by an executor
+type toolRecorder struct {
+	mu     sync.Mutex
+	events []models.ToolEventMessage
+}
+
+func (r *toolRecorder) onTool(msg models.ToolEventMessage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, msg)
+}
+
+// TestJSONOutput_StructuredEvents verifies a task with outputFormat json runs claude with stream-json output,
+// and reports its assistant text as LOG lines, its tool calls as TOOL_EVENTs and its result on completion,
+// with sensitive values masked
+func TestJSONOutput_StructuredEvents(t *testing.T) {
+	testutil.FakeClaude(t, streamJSONClaude)
+	te, rec := recordingExecutor()
+	tools := &toolRecorder{}
+	te.SetToolEventHandler(tools.onTool)
+
+	result, output := runPoolTaskOn(t, te, rec, models.ExecuteMessage{TaskID: 1, ScriptContent: "list files",
+		OutputFormat: models.OutputFormatJSON, Variables: map[string]string{"token": "s3cr3t-value"}})
+	assert.True(t, result.Success, result.Error)
+	assert.Contains(t, output, "args:--print --output-format stream-json --verbose list files")
+	assert.Contains(t, output, "Looking at it.")
+	assert.Contains(t, output, "Using token ***")
+	assert.Contains(t, output, `{"type":"assistant","message":{"content":[{"type":"text","text":"cut`, "Malformed lines are forwarded as they are")
+	assert.NotContains(t, output, `{"type":"system","subtype":"init","session_id":"5e6f7a8b-1c2d"}`)
+
+	if assert.Len(t, tools.events, 2) {
+		use, res := tools.events[0], tools.events[1]
+		assert.Equal(t, models.TypeToolEvent, use.Type)
+		assert.Equal(t, int64(1), use.TaskID)
+		assert.Equal(t, models.ToolPhaseUse, use.Phase)
+		assert.Equal(t, "Bash", use.Tool)
+		assert.Equal(t, "toolu_1", use.ToolUseID)
+		assert.Equal(t, `{"command":"ls"}`, use.Input)
+		assert.Equal(t, models.ToolPhaseResult, res.Phase)
+		assert.Equal(t, "toolu_1", res.ToolUseID)
+		assert.Equal(t, "main.go", res.Output)
+		assert.False(t, res.IsError)
+	}
+
+	if assert.NotNil(t, result.ClaudeResult) {
+		assert.Equal(t, "success", result.ClaudeResult.Subtype)
+		assert.Equal(t, 2, result.ClaudeResult.NumTurns)
+		assert.Equal(t, "Done with ***", result.ClaudeResult.Result)
+		assert.Equal(t, int64(5), result.ClaudeResult.Usage.OutputTokens)
+	}
+	assert.Empty(t, te.claudeResults, "Taken for the completion report")
+}
+
+// TestJSONOutput_TextByDefault verifies claude's output is forwarded as it is without outputFormat json
+func TestJSONOutput_TextByDefault(t *testing.T) {
+	testutil.FakeClaude(t, streamJSONClaude)
+	te, rec := recordingExecutor()
+	tools := &toolRecorder{}
+	te.SetToolEventHandler(tools.onTool)
+
+	result, output := runPoolTaskOn(t, te, rec, models.ExecuteMessage{TaskID: 2, ScriptContent: "list files"})
+	assert.True(t, result.Success, result.Error)
+	assert.Contains(t, output, "args:list files")
+	assert.Contains(t, output, `{"type":"system","subtype":"init","session_id":"5e6f7a8b-1c2d"}`)
+	assert.Empty(t, tools.events)
+	assert.Nil(t, result.ClaudeResult)
+}
@@ -0,0 +1,55 @@
+package executor
+
+import (
+	"log"
+	"sort"
+	"unicode/utf8"
+)
+
+// Limits on the opaque metadata echoed back on every message about a task
+const (
+	MaxMetadataKeys       = 10
+	MaxMetadataValueBytes = 256
+)
+
+// LimitMetadata returns a copy of md trimmed to MaxMetadataKeys keys and MaxMetadataValueBytes per value
+// Keys are kept in sorted order so the same input is always trimmed the same way
+func LimitMetadata(taskID int64, md map[string]string) map[string]string {
+	if len(md) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(md))
+	for k := range md {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	if len(keys) > MaxMetadataKeys {
+		log.Printf("[POOL] Task %d metadata has %d keys, keeping the first %d: dropped %v",
+			taskID, len(keys), MaxMetadataKeys, keys[MaxMetadataKeys:])
+		keys = keys[:MaxMetadataKeys]
+	}
+
+	limited := make(map[string]string, len(keys))
+	for _, k := range keys {
+		v := md[k]
+		if len(v) > MaxMetadataValueBytes {
+			log.Printf("[POOL] Task %d metadata %q is %d bytes, truncating to %d", taskID, k, len(v), MaxMetadataValueBytes)
+			v = truncateUTF8(v, MaxMetadataValueBytes)
+		}
+		limited[k] = v
+	}
+	return limited
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a multi-byte character
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,37 @@
+package executor
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/berno/aaw-runner/internal/models"
+)
+
+// prepareModel checks that the model msg names is one the runner allows, and adds it to the task's options
+// A model that is not allowed fails the task with ErrorCodeInvalidTask before anything is run.
+func (te *TaskExecutor) prepareModel(msg models.ExecuteMessage, opts *TaskOptions) error {
+	if len(te.allowedModels) > 0 && !slices.Contains(te.allowedModels, msg.Model) {
+		return te.invalidTask(msg.TaskID, fmt.Errorf("model %q is not allowed on this runner (allowed: %s)",
+			msg.Model, strings.Join(te.allowedModels, ", ")))
+	}
+	opts.model = msg.Model
+	return nil
+}
+
+// modelArgs returns the claude flags selecting the model the task's EXECUTE named, none when it named none
+// The model is its own argv entry, never joined to the flag.
+func (o TaskOptions) modelArgs() []string {
+	if o.model == "" {
+		return nil
+	}
+	return []string{"--model", o.model}
+}
+
+// describeModel is the start LOG line's note of the model a task runs on, given its model flags
+func describeModel(modelArgs []string) string {
+	if len(modelArgs) == 0 {
+		return ""
+	}
+	return " on model " + modelArgs[1]
+}
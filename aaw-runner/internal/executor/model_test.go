@@ -0,0 +1,44 @@
+package executor
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestModel_PassedToClaude verifies the model of an EXECUTE reaches claude as --model and its own argv
+// entry, ahead of the prompt, and is named in the start line
+func TestModel_PassedToClaude(t *testing.T) {
+	testutil.FakeClaude(t, `for arg; do echo "arg:$arg"; done`)
+	result, output := runPoolTask(t, models.ExecuteMessage{TaskID: 1, ScriptContent: "fix the lint", Model: "claude-haiku-4-5", SkipPermissions: true})
+	assert.True(t, result.Success, result.Error)
+	assert.Subset(t, output, []string{"arg:--dangerously-skip-permissions", "arg:--model", "arg:claude-haiku-4-5", "arg:fix the lint"})
+	assert.Less(t, slices.Index(output, "arg:--model")+1, slices.Index(output, "arg:fix the lint"))
+	assert.Contains(t, output[0], "on model claude-haiku-4-5")
+
+	result, output = runPoolTask(t, models.ExecuteMessage{TaskID: 2, ScriptContent: "fix the lint"})
+	assert.True(t, result.Success, result.Error)
+	assert.NotContains(t, output, "arg:--model", "claude's default without a model")
+}
+
+// TestModel_Refused verifies a model outside the runner's allowlist fails the task as INVALID_TASK before
+// claude starts, with the reason in its output and its error
+func TestModel_Refused(t *testing.T) {
+	testutil.FakeClaude(t, "echo started")
+	te, rec := recordingExecutor()
+	te.allowedModels = []string{"haiku", "sonnet"}
+
+	result, output := runPoolTaskOn(t, te, rec, models.ExecuteMessage{TaskID: 3, ScriptContent: "refactor", Model: "opus"})
+	assert.False(t, result.Success)
+	assert.Equal(t, models.ErrorCodeInvalidTask, result.ErrorCode)
+	assert.Contains(t, result.Error, `model "opus" is not allowed on this runner (allowed: haiku, sonnet)`)
+	assert.Contains(t, output, `Invalid task: model "opus" is not allowed on this runner (allowed: haiku, sonnet)`)
+	assert.NotContains(t, output, "started")
+
+	result, output = runPoolTaskOn(t, te, rec, models.ExecuteMessage{TaskID: 4, ScriptContent: "lint", Model: "haiku"})
+	assert.True(t, result.Success, result.Error)
+	assert.Contains(t, output, "started")
+}
@@ -0,0 +1,135 @@
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// oomEvidenceSource reads the breadcrumbs the kernel OOM killer leaves behind
+// It is an interface so tests can fake kernel state
+type oomEvidenceSource interface {
+	// counters returns OOM kill counters keyed by source (e.g. "memory.events", "vmstat")
+	counters() map[string]int64
+	// kernelLog returns recent kernel messages, if readable by this process
+	kernelLog() (string, error)
+}
+
+// OOMError reports that a task was (or was very likely) killed for running out of memory
+type OOMError struct {
+	Err       error  // Underlying wait error
+	Evidence  string // Human-readable description of what pointed at OOM
+	Confirmed bool   // True when the kernel log names the task's process
+}
+
+func (e *OOMError) Error() string {
+	kind := "suspected out of memory"
+	if e.Confirmed {
+		kind = "out of memory"
+	}
+	return fmt.Sprintf("%s: %v (%s)", kind, e.Err, e.Evidence)
+}
+
+func (e *OOMError) Unwrap() error {
+	return e.Err
+}
+
+// linuxOOMEvidence reads cgroup v2 memory.events, /proc/vmstat and dmesg
+// On other platforms (or when the files are unreadable) it reports nothing
+type linuxOOMEvidence struct{}
+
+func (linuxOOMEvidence) counters() map[string]int64 {
+	result := make(map[string]int64)
+
+	if path := ownCgroupMemoryEvents(); path != "" {
+		if n, ok := readCounter(path, "oom_kill"); ok {
+			result["memory.events"] = n
+		}
+	}
+	if n, ok := readCounter("/proc/vmstat", "oom_kill"); ok {
+		result["vmstat"] = n
+	}
+
+	return result
+}
+
+func (linuxOOMEvidence) kernelLog() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// dmesg is frequently restricted for unprivileged users (kernel.dmesg_restrict)
+	out, err := exec.CommandContext(ctx, "dmesg").Output()
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// ownCgroupMemoryEvents returns the memory.events path of the runner's cgroup v2 group
+func ownCgroupMemoryEvents() string {
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		// cgroup v2 unified hierarchy: "0::/system.slice/aaw-runner.service"
+		if rel, ok := strings.CutPrefix(line, "0::"); ok {
+			return filepath.Join("/sys/fs/cgroup", rel, "memory.events")
+		}
+	}
+	return ""
+}
+
+// readCounter reads a "key value" counter from a flat keyed file
+func readCounter(path, key string) (int64, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == key {
+			n, err := strconv.ParseInt(fields[1], 10, 64)
+			return n, err == nil
+		}
+	}
+	return 0, false
+}
+
+// oomKillEvidence looks for kernel evidence that pid was OOM-killed since the baseline counters were taken
+// Only a kernel log line naming pid confirms the kill. The counters are per cgroup or host-wide, so an
+// increase may belong to any other process and is returned as suspected evidence (confirmed false).
+func oomKillEvidence(source oomEvidenceSource, pid int, baseline map[string]int64) (evidence string, confirmed bool) {
+	if source == nil {
+		return "", false
+	}
+
+	if kernelLog, err := source.kernelLog(); err == nil {
+		needle := fmt.Sprintf("Killed process %d ", pid)
+		for _, line := range strings.Split(kernelLog, "\n") {
+			if strings.Contains(line, needle) {
+				return "kernel: " + strings.TrimSpace(line), true
+			}
+		}
+	}
+
+	current := source.counters()
+	for _, key := range []string{"memory.events", "vmstat"} {
+		before, hadBefore := baseline[key]
+		after, hasAfter := current[key]
+		if hadBefore && hasAfter && after > before {
+			return fmt.Sprintf("%s oom_kill %d -> %d", key, before, after), false
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,133 @@
+package executor
+
+import (
+	"errors"
+	"os/exec"
+	"strconv"
+	"testing"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeOOMEvidence is a controllable stand-in for kernel OOM breadcrumbs
+type fakeOOMEvidence struct {
+	counterValues map[string]int64
+	log           string
+	logErr        error
+}
+
+func (f *fakeOOMEvidence) counters() map[string]int64 {
+	result := make(map[string]int64, len(f.counterValues))
+	for k, v := range f.counterValues {
+		result[k] = v
+	}
+	return result
+}
+
+func (f *fakeOOMEvidence) kernelLog() (string, error) {
+	return f.log, f.logErr
+}
+
+// runSelfKilled runs a process that SIGKILLs itself, mimicking the OOM killer
+func runSelfKilled(t *testing.T) (*exec.Cmd, error) {
+	cmd := exec.Command("/bin/sh", "-c", "kill -9 $$")
+	err := cmd.Run()
+	assert.Error(t, err, "Self-killed process should fail")
+	return cmd, err
+}
+
+// TestClassifyFailure_SuspectedFromCgroupCounter verifies SIGKILL + counter increase is a suspected OOM, since
+// the kill it counts may have been of another process
+func TestClassifyFailure_SuspectedFromCgroupCounter(t *testing.T) {
+	te, _ := recordingExecutor()
+	fake := &fakeOOMEvidence{counterValues: map[string]int64{"memory.events": 1}, logErr: errors.New("permission denied")}
+	te.oomEvidence = fake
+
+	output := te.newTaskOutput(1, TaskOptions{})
+	cmd, waitErr := runSelfKilled(t)
+	fake.counterValues["memory.events"] = 2
+
+	err := te.classifyFailure(output, cmd, waitErr)
+
+	var oomErr *OOMError
+	assert.True(t, errors.As(err, &oomErr), "Error should be classified as OOM")
+	assert.False(t, oomErr.Confirmed, "A counter does not say which process was killed")
+	assert.Equal(t, "memory.events oom_kill 1 -> 2", oomErr.Evidence)
+	assert.Equal(t, int64(1), te.OOMEventCount(), "OOM events should be counted")
+}
+
+// TestClassifyFailure_ConfirmedByKernelLog verifies dmesg evidence mentioning the pid confirms the OOM, whatever
+// the counters say
+func TestClassifyFailure_ConfirmedByKernelLog(t *testing.T) {
+	te, _ := recordingExecutor()
+	fake := &fakeOOMEvidence{counterValues: map[string]int64{"vmstat": 5}}
+	te.oomEvidence = fake
+
+	output := te.newTaskOutput(1, TaskOptions{})
+	cmd, waitErr := runSelfKilled(t)
+	fake.counterValues["vmstat"] = 6
+	fake.log = "[123.4] Out of memory: Killed process " + strconv.Itoa(cmd.Process.Pid) + " (python3) total-vm:123kB\n"
+
+	err := te.classifyFailure(output, cmd, waitErr)
+
+	var oomErr *OOMError
+	assert.True(t, errors.As(err, &oomErr))
+	assert.True(t, oomErr.Confirmed)
+	assert.Contains(t, oomErr.Evidence, "Killed process")
+}
+
+// TestClassifyFailure_PlainSigkillIsNotOOM verifies a SIGKILL without evidence stays unclassified
+func TestClassifyFailure_PlainSigkillIsNotOOM(t *testing.T) {
+	te, _ := recordingExecutor()
+	te.oomEvidence = &fakeOOMEvidence{counterValues: map[string]int64{"vmstat": 5}}
+
+	output := te.newTaskOutput(1, TaskOptions{})
+	cmd, waitErr := runSelfKilled(t)
+
+	err := te.classifyFailure(output, cmd, waitErr)
+
+	var oomErr *OOMError
+	assert.False(t, errors.As(err, &oomErr), "SIGKILL without evidence should not be classified as OOM")
+	assert.Equal(t, int64(0), te.OOMEventCount())
+}
+
+// TestClassifyFailure_SuspectedFromOutput verifies output patterns classify a failed task as suspected OOM
+func TestClassifyFailure_SuspectedFromOutput(t *testing.T) {
+	te, rec := recordingExecutor()
+	te.oomEvidence = &fakeOOMEvidence{}
+
+	output := te.newTaskOutput(3, TaskOptions{})
+	te.processLine(output, []byte("Processing batch 1"), false)
+	te.processLine(output, []byte("FATAL ERROR: Reached heap limit Allocation failed - JavaScript heap out of memory"), true)
+	te.flushOutput(3)
+
+	assert.Empty(t, rec.getStatuses(), "OOM output should not change the task status while it runs")
+
+	cmd := exec.Command("/bin/sh", "-c", "exit 134")
+	waitErr := cmd.Run()
+
+	err := te.classifyFailure(output, cmd, waitErr)
+
+	var oomErr *OOMError
+	assert.True(t, errors.As(err, &oomErr))
+	assert.False(t, oomErr.Confirmed, "Output-only evidence is a suspicion")
+	assert.Equal(t, "output line 2: Reached heap limit", oomErr.Evidence)
+
+	result := newTaskResult(3, err)
+	assert.False(t, result.Success)
+	assert.Equal(t, models.ClassificationOOM, result.Classification)
+	assert.Equal(t, oomErr.Evidence, result.Evidence)
+}
+
+// TestNewTaskResult_UnclassifiedFailure verifies ordinary failures carry no classification
+func TestNewTaskResult_UnclassifiedFailure(t *testing.T) {
+	result := newTaskResult(4, errors.New("exit status 1"))
+	assert.False(t, result.Success)
+	assert.Equal(t, "exit status 1", result.Error)
+	assert.Empty(t, result.Classification)
+
+	result = newTaskResult(5, nil)
+	assert.True(t, result.Success)
+	assert.Empty(t, result.Error)
+}
@@ -0,0 +1,74 @@
+package executor
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/berno/aaw-runner/internal/models"
+)
+
+// outputSize counts the bytes of a task's output lines, without their line endings
+type outputSize struct {
+	sent      int64 // Forwarded as LOG (or stream-json events)
+	discarded int64 // Printed past the task's output cap, read and dropped
+	lines     int64 // Lines discarded
+}
+
+// outputCapOf returns the bytes of output lines a task may send: its EXECUTE's cap, or the runner's
+func (te *TaskExecutor) outputCapOf(opts TaskOptions) int64 {
+	if opts.outputCap != 0 {
+		return opts.outputCap
+	}
+	return te.maxOutputBytes
+}
+
+// admit counts a line of n bytes against the task's output cap, reporting whether it may be forwarded
+// Once a line is over the cap, every later one is discarded too, so the output stops at a line boundary.
+func (o *taskOutput) admit(n int) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.size.lines > 0 || o.maxBytes > 0 && o.size.sent+int64(n) > o.maxBytes {
+		o.size.discarded += int64(n)
+		o.size.lines++
+		return false
+	}
+	o.size.sent += int64(n)
+	return true
+}
+
+// outputSize returns the byte counts of the task's output so far
+func (o *taskOutput) outputSize() outputSize {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.size
+}
+
+// recordOutputSize keeps the byte counts of an exited task's output until the pool reports them, and
+// says in its output how much of it was discarded, if any was
+func (te *TaskExecutor) recordOutputSize(output *taskOutput) {
+	size := output.outputSize()
+	if size.lines > 0 {
+		line := fmt.Sprintf("[runner] Output cap of %d bytes reached; %d more bytes (%d lines) were discarded",
+			output.maxBytes, size.discarded, size.lines)
+		log.Printf("[Executor] Task %d: %s", output.taskID, line)
+		msg := models.NewLogMessage(output.taskID, line, false)
+		msg.Severity = "warn"
+		te.logCallback(msg)
+	}
+
+	te.tailsMu.Lock()
+	defer te.tailsMu.Unlock()
+	if te.outputSizes == nil {
+		te.outputSizes = make(map[int64]outputSize)
+	}
+	te.outputSizes[output.taskID] = size
+}
+
+// takeOutputSize returns and forgets the byte counts of a task's output (zero if it never ran)
+func (te *TaskExecutor) takeOutputSize(taskID int64) outputSize {
+	te.tailsMu.Lock()
+	defer te.tailsMu.Unlock()
+	size := te.outputSizes[taskID]
+	delete(te.outputSizes, taskID)
+	return size
+}
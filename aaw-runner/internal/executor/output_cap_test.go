@@ -0,0 +1,49 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestOutputCap_DiscardsPastCap verifies a task's output stops at the line crossing its cap, while the rest
+// is still read to EOF (the task would block on a full pipe otherwise), counted, and noted in one LOG line
+func TestOutputCap_DiscardsPastCap(t *testing.T) {
+	testutil.FakeClaude(t, "yes 0123456789 | head -n 100000; echo done")
+	te, rec := recordingExecutor()
+	te.maxOutputBytes = 25
+
+	result, output := runPoolTaskOn(t, te, rec, models.ExecuteMessage{TaskID: 1, ScriptContent: "flood"})
+	assert.True(t, result.Success, result.Error)
+	assert.Equal(t, []string{"0123456789", "0123456789"}, linesWithPrefix(output, "0123456789"))
+	assert.NotContains(t, output, "done")
+	assert.Equal(t, []string{"[runner] Output cap of 25 bytes reached; 999984 more bytes (99999 lines) were discarded"},
+		linesWithPrefix(output, "[runner]"))
+	assert.Equal(t, "Dynamic execution completed", output[len(output)-1])
+	assert.Equal(t, int64(20), result.OutputBytes)
+	assert.Equal(t, int64(999984), result.DiscardedOutputBytes)
+}
+
+// TestOutputCap_ExecuteOverride verifies an EXECUTE's maxOutputBytes replaces the runner's cap, and that a
+// task within its cap sends everything and only has its bytes counted
+func TestOutputCap_ExecuteOverride(t *testing.T) {
+	testutil.FakeClaude(t, "echo first; echo second; echo third")
+	te, rec := recordingExecutor()
+	te.maxOutputBytes = 0
+
+	result, output := runPoolTaskOn(t, te, rec, models.ExecuteMessage{TaskID: 2, ScriptContent: "three", MaxOutputBytes: 12})
+	assert.True(t, result.Success, result.Error)
+	assert.Subset(t, output, []string{"first", "second"})
+	assert.NotContains(t, output, "third")
+	assert.Contains(t, output, "[runner] Output cap of 12 bytes reached; 5 more bytes (1 lines) were discarded")
+	assert.Equal(t, int64(11), result.OutputBytes)
+	assert.Equal(t, int64(5), result.DiscardedOutputBytes)
+
+	result, output = runPoolTaskOn(t, te, rec, models.ExecuteMessage{TaskID: 3, ScriptContent: "three"})
+	assert.True(t, result.Success, result.Error)
+	assert.Contains(t, output, "third")
+	assert.Equal(t, int64(16), result.OutputBytes)
+	assert.Zero(t, result.DiscardedOutputBytes)
+}
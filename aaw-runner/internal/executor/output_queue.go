@@ -0,0 +1,306 @@
+package executor
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+
+	"github.com/berno/aaw-runner/internal/config"
+	"github.com/berno/aaw-runner/internal/models"
+)
+
+// outputQueueSize is how many output lines may wait for the sender before the backpressure policy applies
+// A variable so tests can shrink it.
+var outputQueueSize = 4096
+
+// outputEvent is one entry of the output queue: a message to send, or a flush barrier
+type outputEvent struct {
+	log     *models.LogMessage
+	status  *models.StatusUpdateMessage
+	tool    *models.ToolEventMessage
+	summary bool          // log stands in for dropped lines; it does not count against outputQueueSize
+	flushed chan struct{} // Closed by the forwarder once everything queued before it was sent
+}
+
+// dropCount tracks the lines of a task lost to a full queue
+type dropCount struct {
+	unreported int64              // Dropped since the last summary line (drop-newest)
+	summary    *models.LogMessage // Queued summary that lines dropped from now on are added to (drop-oldest)
+	total      int64
+}
+
+// outputQueue decouples the stream readers from the callbacks that send their output over the network
+// Once the forwarder falls behind and outputQueueSize lines are waiting, the backpressure policy
+// decides what happens to the next line: with config.BackpressureDropNewest it is dropped, and a
+// summary line takes the place of the task's dropped lines as soon as there is room again; with
+// config.BackpressureDropOldest the oldest waiting line gives way, the first of a task's dropped lines
+// being replaced by a summary that counts the rest; with config.BackpressureBlock the reader waits for
+// room. STATUS_UPDATE messages never wait and are never dropped. Messages of one task keep their order.
+type outputQueue struct {
+	sendLog    func(models.LogMessage)
+	sendStatus func(models.StatusUpdateMessage)
+	sendTool   func(models.ToolEventMessage) // nil drops TOOL_EVENTs (see TaskExecutor.SetToolEventHandler)
+	policy     string
+
+	mu      sync.Mutex    // Held while queueing, so a summary is queued ahead of the line that follows it
+	events  []outputEvent // Waiting for the forwarder, oldest first
+	lines   int           // Output lines among events, summaries excluded
+	dropped map[int64]*dropCount
+	total   int64         // Lines dropped since startup
+	added   chan struct{} // Signalled when an event is queued
+	taken   chan struct{} // Signalled when the forwarder takes a line
+}
+
+// newOutputQueue starts the forwarder goroutine delivering to the callbacks, applying policy (one of the
+// config.Backpressure* policies) when it falls behind
+func newOutputQueue(policy string, sendLog func(models.LogMessage), sendStatus func(models.StatusUpdateMessage)) *outputQueue {
+	q := &outputQueue{
+		sendLog:    sendLog,
+		sendStatus: sendStatus,
+		policy:     policy,
+		dropped:    make(map[int64]*dropCount),
+		added:      make(chan struct{}, 1),
+		taken:      make(chan struct{}, 1),
+	}
+	go q.forward()
+	return q
+}
+
+// forward delivers queued messages in order; it is the only goroutine that calls the callbacks
+func (q *outputQueue) forward() {
+	for {
+		event := q.take()
+		switch {
+		case event.log != nil:
+			q.sendLog(*event.log)
+		case event.status != nil:
+			q.sendStatus(*event.status)
+		case event.tool != nil:
+			q.mu.Lock()
+			send := q.sendTool
+			q.mu.Unlock()
+			if send != nil {
+				send(*event.tool)
+			}
+		default:
+			close(event.flushed)
+		}
+	}
+}
+
+// take waits for the oldest event and removes it from the queue
+// A summary is copied, as lines dropped later no longer go into it once it has left the queue.
+func (q *outputQueue) take() outputEvent {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.events) == 0 {
+		q.mu.Unlock()
+		<-q.added
+		q.mu.Lock()
+	}
+	event := q.events[0]
+	q.events[0] = outputEvent{}
+	q.events = q.events[1:]
+	switch {
+	case event.summary:
+		if count := q.dropped[event.log.TaskID]; count != nil && count.summary == event.log {
+			count.summary = nil
+		}
+		summary := *event.log
+		event.log = &summary
+	case event.log != nil:
+		q.lines--
+		signal(q.taken)
+	}
+	return event
+}
+
+// log queues a LOG message, applying the backpressure policy when the queue is full
+func (q *outputQueue) log(msg models.LogMessage) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.lines >= outputQueueSize {
+		switch q.policy {
+		case config.BackpressureBlock:
+			for q.lines >= outputQueueSize {
+				q.mu.Unlock()
+				<-q.taken
+				q.mu.Lock()
+			}
+		case config.BackpressureDropOldest:
+			for q.lines >= outputQueueSize {
+				q.dropOldest()
+			}
+		default:
+			q.drop(msg.TaskID)
+			return
+		}
+	}
+	q.reportDropped(msg.TaskID)
+	q.push(outputEvent{log: &msg})
+	q.lines++
+	if q.lines < outputQueueSize {
+		signal(q.taken) // Room for another waiting reader
+	}
+}
+
+// status queues a STATUS_UPDATE, which never waits and is never dropped
+func (q *outputQueue) status(msg models.StatusUpdateMessage) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.reportDropped(msg.TaskID)
+	q.push(outputEvent{status: &msg})
+}
+
+// tool queues a TOOL_EVENT, which like a STATUS_UPDATE never waits and is never dropped
+func (q *outputQueue) tool(msg models.ToolEventMessage) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.reportDropped(msg.TaskID)
+	q.push(outputEvent{tool: &msg})
+}
+
+// push appends an event and wakes the forwarder; callers hold mu
+func (q *outputQueue) push(event outputEvent) {
+	q.events = append(q.events, event)
+	signal(q.added)
+}
+
+// countOf returns the drop count of a task, creating it; callers hold mu
+func (q *outputQueue) countOf(taskID int64) *dropCount {
+	count := q.dropped[taskID]
+	if count == nil {
+		count = &dropCount{}
+		q.dropped[taskID] = count
+	}
+	return count
+}
+
+// drop counts a new line that did not fit (drop-newest); callers hold mu
+func (q *outputQueue) drop(taskID int64) {
+	count := q.countOf(taskID)
+	if count.total == 0 {
+		log.Printf("[Executor] Output queue full: dropping output of task %d until the backend catches up", taskID)
+	}
+	count.unreported++
+	count.total++
+	q.total++
+}
+
+// dropOldest drops the oldest waiting line (drop-oldest): the first line of a task to go is replaced by a
+// summary, later ones are removed and added to it; callers hold mu
+func (q *outputQueue) dropOldest() {
+	for i, event := range q.events {
+		if event.log == nil || event.summary {
+			continue
+		}
+		count := q.countOf(event.log.TaskID)
+		if count.total == 0 {
+			log.Printf("[Executor] Output queue full: dropping the oldest output of task %d until the backend catches up", event.log.TaskID)
+		}
+		if count.summary == nil {
+			summary := droppedSummary(event.log.TaskID, 0)
+			count.summary = summary.log
+			q.events[i] = summary
+		} else {
+			q.events = slices.Delete(q.events, i, i+1)
+		}
+		summarize(count.summary, count.summary.Skipped+1)
+		q.lines--
+		count.total++
+		q.total++
+		return
+	}
+}
+
+// reportDropped queues the summary of a task's lines dropped since the last one (drop-newest), ahead of
+// its next message; callers hold mu
+func (q *outputQueue) reportDropped(taskID int64) {
+	if count := q.dropped[taskID]; count != nil && count.unreported > 0 {
+		q.push(droppedSummary(taskID, count.unreported))
+		count.unreported = 0
+	}
+}
+
+// flush waits until everything queued for a task has been sent, summarizing lines still unreported
+// Called once the task's readers are done; unlike them it may block.
+func (q *outputQueue) flush(taskID int64) {
+	flushed := make(chan struct{})
+	q.mu.Lock()
+	q.reportDropped(taskID)
+	q.push(outputEvent{flushed: flushed})
+	q.mu.Unlock()
+	<-flushed
+}
+
+// takeDropped returns and forgets how many lines of a task were dropped
+func (q *outputQueue) takeDropped(taskID int64) int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	count := q.dropped[taskID]
+	delete(q.dropped, taskID)
+	if count == nil {
+		return 0
+	}
+	return count.total
+}
+
+// droppedLines returns how many lines were dropped since startup
+func (q *outputQueue) droppedLines() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.total
+}
+
+// signal wakes whoever waits on ch, if anyone is not already due to wake
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// droppedSummary is the LOG line standing in for n dropped lines
+func droppedSummary(taskID int64, n int64) outputEvent {
+	msg := models.NewLogMessage(taskID, "", true)
+	summarize(&msg, n)
+	return outputEvent{log: &msg, summary: true}
+}
+
+// summarize makes msg the summary of n dropped lines
+func summarize(msg *models.LogMessage, n int64) {
+	msg.Skipped = n
+	msg.Line = fmt.Sprintf("[runner] …skipped %d lines… (the backend was not keeping up)", n)
+}
+
+// SetToolEventHandler registers fn to send the TOOL_EVENTs of tasks run with outputFormat json, in order
+// with their LOG lines; without one they are dropped
+func (te *TaskExecutor) SetToolEventHandler(fn func(models.ToolEventMessage)) {
+	te.output.mu.Lock()
+	defer te.output.mu.Unlock()
+	te.output.sendTool = fn
+}
+
+// flushOutput waits until the output of a task has been handed to the callbacks
+func (te *TaskExecutor) flushOutput(taskID int64) {
+	if te.output != nil {
+		te.output.flush(taskID)
+	}
+}
+
+// takeDroppedOutput returns and forgets how many output lines of a task were dropped
+func (te *TaskExecutor) takeDroppedOutput(taskID int64) int64 {
+	if te.output == nil {
+		return 0
+	}
+	return te.output.takeDropped(taskID)
+}
+
+// DroppedLines returns how many task output lines were dropped since startup because sending fell behind
+func (te *TaskExecutor) DroppedLines() int64 {
+	if te.output == nil {
+		return 0
+	}
+	return te.output.droppedLines()
+}
@@ -0,0 +1,216 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/config"
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// gatedSender is a send callback stuck on a slow backend until released
+type gatedSender struct {
+	entered chan struct{} // Closed when the first message reaches the sender
+	release chan struct{}
+	once    sync.Once
+
+	mu    sync.Mutex
+	lines []string
+}
+
+func newGatedSender() *gatedSender {
+	return &gatedSender{entered: make(chan struct{}), release: make(chan struct{})}
+}
+
+func (g *gatedSender) send(msg models.LogMessage) {
+	g.once.Do(func() { close(g.entered) })
+	<-g.release
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.lines = append(g.lines, msg.Line)
+}
+
+func (g *gatedSender) get() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]string(nil), g.lines...)
+}
+
+// TestOutputQueue_SlowSenderDoesNotStallTask verifies a task writing more than a pipe buffer finishes
+// while the sender is stuck, and that its output is all delivered, in order, before Execute returns
+func TestOutputQueue_SlowSenderDoesNotStallTask(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "done")
+	testutil.FakeClaude(t, `i=0; while [ $i -lt 2000 ]; do printf 'line %04d %0100d\n' $i 0; i=$((i+1)); done; touch `+marker)
+	sender := newGatedSender()
+	te := NewTaskExecutor(sender.send, func(models.StatusUpdateMessage) {})
+
+	done := make(chan error, 1)
+	go func() { done <- te.ExecuteDynamic(1, "flood", false, "", TaskOptions{}) }()
+
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(marker)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond, "The task must not block on write(2) while the sender is stuck")
+	select {
+	case <-done:
+		t.Fatal("Execute returned before its output was sent")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(sender.release)
+	assert.NoError(t, <-done)
+	lines := sender.get()
+	if assert.Len(t, lines, 2002, "Start line, 2000 output lines and the completion line") {
+		for i := 0; i < 2000; i++ {
+			assert.Equal(t, fmt.Sprintf("line %04d %0100d", i, 0), lines[i+1])
+		}
+	}
+	assert.Equal(t, int64(0), te.takeDroppedOutput(1))
+}
+
+// TestOutputQueue_OverflowDropsAndSummarizes verifies a full queue drops new lines without blocking under
+// drop-newest, counts them, and reports them in a summary line ahead of the next line that fits
+func TestOutputQueue_OverflowDropsAndSummarizes(t *testing.T) {
+	old := outputQueueSize
+	outputQueueSize = 8
+	defer func() { outputQueueSize = old }()
+
+	sender := newGatedSender()
+	q := newOutputQueue(config.BackpressureDropNewest, sender.send, func(models.StatusUpdateMessage) {})
+	q.log(models.NewLogMessage(1, "line 0", false))
+	<-sender.entered // Line 0 is with the sender, the queue is empty
+
+	for i := 1; i < 20; i++ {
+		q.log(models.NewLogMessage(1, fmt.Sprintf("line %d", i), false))
+	}
+	close(sender.release)
+	q.flush(1)
+	q.log(models.NewLogMessage(1, "line 20", false))
+	q.flush(1)
+
+	lines := sender.get()
+	assert.Equal(t, []string{
+		"line 0", "line 1", "line 2", "line 3", "line 4", "line 5", "line 6", "line 7", "line 8",
+		"[runner] …skipped 11 lines… (the backend was not keeping up)",
+		"line 20",
+	}, lines)
+	assert.Equal(t, int64(11), q.takeDropped(1))
+	assert.Equal(t, int64(0), q.takeDropped(1), "Counts are handed over once")
+}
+
+// shrinkOutputQueue sets outputQueueSize for the duration of a test
+func shrinkOutputQueue(t *testing.T, n int) {
+	old := outputQueueSize
+	outputQueueSize = n
+	t.Cleanup(func() { outputQueueSize = old })
+}
+
+// TestOutputQueue_DropOldest verifies drop-oldest makes the oldest waiting lines give way to new ones, a
+// summary with their count taking the place of the first
+func TestOutputQueue_DropOldest(t *testing.T) {
+	shrinkOutputQueue(t, 8)
+	sender := newGatedSender()
+	var skipped []int64
+	q := newOutputQueue(config.BackpressureDropOldest, func(msg models.LogMessage) {
+		if msg.Skipped > 0 {
+			skipped = append(skipped, msg.Skipped)
+		}
+		sender.send(msg)
+	}, func(models.StatusUpdateMessage) {})
+	q.log(models.NewLogMessage(1, "line 0", false))
+	<-sender.entered
+
+	for i := 1; i < 20; i++ {
+		q.log(models.NewLogMessage(1, fmt.Sprintf("line %d", i), false))
+	}
+	close(sender.release)
+	q.flush(1)
+
+	assert.Equal(t, []string{
+		"line 0",
+		"[runner] …skipped 11 lines… (the backend was not keeping up)",
+		"line 12", "line 13", "line 14", "line 15", "line 16", "line 17", "line 18", "line 19",
+	}, sender.get())
+	assert.Equal(t, []int64{11}, skipped)
+	assert.Equal(t, int64(11), q.droppedLines())
+	assert.Equal(t, int64(11), q.takeDropped(1))
+}
+
+// TestOutputQueue_Block verifies block makes the reader wait for room instead of dropping anything
+func TestOutputQueue_Block(t *testing.T) {
+	shrinkOutputQueue(t, 2)
+	sender := newGatedSender()
+	q := newOutputQueue(config.BackpressureBlock, sender.send, func(models.StatusUpdateMessage) {})
+	q.log(models.NewLogMessage(1, "line 0", false))
+	<-sender.entered
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 1; i < 10; i++ {
+			q.log(models.NewLogMessage(1, fmt.Sprintf("line %d", i), false))
+		}
+	}()
+	select {
+	case <-done:
+		t.Fatal("The reader did not wait for room")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(sender.release)
+	<-done
+	q.flush(1)
+
+	lines := sender.get()
+	if assert.Len(t, lines, 10) {
+		for i, line := range lines {
+			assert.Equal(t, fmt.Sprintf("line %d", i), line)
+		}
+	}
+	assert.Equal(t, int64(0), q.droppedLines())
+}
+
+// TestOutputQueue_StatusNeverDropped verifies a STATUS_UPDATE queued while lines are being dropped is
+// delivered, after the summary of the lines dropped before it
+func TestOutputQueue_StatusNeverDropped(t *testing.T) {
+	shrinkOutputQueue(t, 2)
+	summary := "[runner] …skipped 3 lines… (the backend was not keeping up)"
+	for policy, want := range map[string][]string{
+		config.BackpressureDropNewest: {"line 0", "line 1", "line 2", summary, models.StatusRateLimited},
+		config.BackpressureDropOldest: {"line 0", summary, "line 4", "line 5", models.StatusRateLimited},
+	} {
+		t.Run(policy, func(t *testing.T) {
+			sender := newGatedSender()
+			var order []string
+			var mu sync.Mutex
+			q := newOutputQueue(policy, func(msg models.LogMessage) {
+				sender.send(msg)
+				mu.Lock()
+				defer mu.Unlock()
+				order = append(order, msg.Line)
+			}, func(msg models.StatusUpdateMessage) {
+				mu.Lock()
+				defer mu.Unlock()
+				order = append(order, msg.Status)
+			})
+			q.log(models.NewLogMessage(1, "line 0", false))
+			<-sender.entered
+			for i := 1; i < 6; i++ {
+				q.log(models.NewLogMessage(1, fmt.Sprintf("line %d", i), false))
+			}
+			q.status(models.NewStatusUpdate(1, models.StatusRateLimited))
+			close(sender.release)
+			q.flush(1)
+
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, want, order)
+			assert.Equal(t, int64(3), q.takeDropped(1))
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// streamDrainTimeout bounds how long output is still read after the process exits
+// Background children that inherited the pipes would otherwise keep the task open
+var streamDrainTimeout = 2 * time.Second
+
+// maxLineChunk is how much of one output line streamOutput reads into a LOG message; longer lines are
+// split, and a piece may run up to utf8.UTFMax-1 bytes over to end on a character boundary
+var maxLineChunk = bufio.MaxScanTokenSize
+
+// readSegment reads until the next "\n" or "\r", returning the bytes up to and including it; like
+// ReadSlice, it returns bufio.ErrBufferFull with the buffer's contents when neither comes within them
+// The slice is only valid until the next read.
+func readSegment(r *bufio.Reader) ([]byte, error) {
+	searched := 0
+	for {
+		// Waits for more than what was searched already
+		buf, err := r.Peek(max(r.Buffered(), searched+1))
+		if i := bytes.IndexAny(buf[searched:], "\r\n"); i >= 0 {
+			buf = buf[:searched+i+1]
+			r.Discard(len(buf))
+			return buf, nil
+		}
+		searched = len(buf)
+		if err == nil && searched == r.Size() {
+			err = bufio.ErrBufferFull
+		}
+		if err != nil {
+			r.Discard(len(buf))
+			return buf, err
+		}
+	}
+}
+
+// incompleteTail returns how many bytes at the end of p are the start of a multi-byte character that
+// continues past p (0 when p ends on a character boundary, or in bytes that can never be valid)
+func incompleteTail(p []byte) int {
+	for i := len(p) - 1; i >= 0 && i >= len(p)-(utf8.UTFMax-1); i-- {
+		if utf8.RuneStart(p[i]) {
+			if utf8.FullRune(p[i:]) {
+				return 0
+			}
+			return len(p) - i
+		}
+	}
+	return 0
+}
+
+// outputPipes connects a command's stdout and stderr to pipes that are read until EOF
+// Unlike cmd.StdoutPipe, Wait does not close the read ends, so output written just before the
+// process exits is not lost
+type outputPipes struct {
+	readers []outputReader // Read ends
+	writers []*os.File
+	wg      sync.WaitGroup
+}
+
+// outputReader is a read end of a command's output
+type outputReader struct {
+	file    *os.File
+	src     io.Reader // What is read: file, or a wrapper around it
+	isError bool      // Carries stderr
+}
+
+// newOutputPipes creates the pipes and attaches their write ends to cmd
+func newOutputPipes(cmd *exec.Cmd) (*outputPipes, error) {
+	outR, outW, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	errR, errW, err := os.Pipe()
+	if err != nil {
+		outR.Close()
+		outW.Close()
+		return nil, err
+	}
+	cmd.Stdout, cmd.Stderr = outW, errW
+	return &outputPipes{
+		readers: []outputReader{{file: outR, src: outR}, {file: errR, src: errR, isError: true}},
+		writers: []*os.File{outW, errW},
+	}, nil
+}
+
+// newCombinedPipes creates one pipe and attaches its write end to cmd as both stdout and stderr, so the
+// command's output is read as a single stream, in the order it was written
+func newCombinedPipes(cmd *exec.Cmd) (*outputPipes, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stdout, cmd.Stderr = w, w
+	return &outputPipes{
+		readers: []outputReader{{file: r, src: r}},
+		writers: []*os.File{w},
+	}, nil
+}
+
+// started closes the parent's copies of the write ends, which the child now holds
+// Call it once cmd.Start has returned; after a failed start, call close as well
+func (p *outputPipes) started() {
+	for _, w := range p.writers {
+		w.Close()
+	}
+}
+
+// stream reads every pipe in the background with read
+func (p *outputPipes) stream(read func(reader io.Reader, isError bool)) {
+	p.wg.Add(len(p.readers))
+	for _, r := range p.readers {
+		go func() {
+			defer p.wg.Done()
+			read(r.src, r.isError)
+		}()
+	}
+}
+
+// wait blocks until every stream is read to EOF, giving up after streamDrainTimeout, and closes the pipes
+func (p *outputPipes) wait(taskID int64) {
+	drainStreams(&p.wg, taskID)
+	p.close()
+	p.wg.Wait()
+}
+
+// drainStreams waits up to streamDrainTimeout for the readers counted by wg to reach EOF, reporting whether
+// they did; if not, the caller closes what they read from and waits for them again
+func drainStreams(wg *sync.WaitGroup, taskID int64) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(streamDrainTimeout):
+		log.Printf("[Executor] Task %d output still open %s after exit (background process?), closing it", taskID, streamDrainTimeout)
+		return false
+	}
+}
+
+// close closes the read ends, ending any read in progress
+func (p *outputPipes) close() {
+	for _, r := range p.readers {
+		r.file.Close()
+	}
+}
@@ -0,0 +1,175 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestExecuteDynamic_KeepsOutputOfQuickExit verifies output written right before exit is not lost
+func TestExecuteDynamic_KeepsOutputOfQuickExit(t *testing.T) {
+	testutil.FakeClaude(t, `echo "first"; echo "last" >&2`)
+	te, rec := recordingExecutor()
+
+	for i := int64(0); i < 20; i++ {
+		assert.NoError(t, te.ExecuteDynamic(40+i, "hello", false, "", TaskOptions{}))
+	}
+
+	lines := logLines(rec)
+	count := func(line string) int {
+		n := 0
+		for _, l := range lines {
+			if l == line {
+				n++
+			}
+		}
+		return n
+	}
+	assert.Equal(t, 20, count("first"))
+	assert.Equal(t, 20, count("last"))
+}
+
+// TestExecuteDynamic_SplitsOversizeLine verifies a line longer than maxLineChunk arrives whole, split into
+// LOG messages of at most maxLineChunk bytes, and the lines after it on both streams are not lost
+func TestExecuteDynamic_SplitsOversizeLine(t *testing.T) {
+	testutil.FakeClaude(t, `head -c 2097152 /dev/zero | tr '\0' x; printf '\r\nafter\n\nlast'; echo "stderr after" >&2`)
+	te, rec := recordingExecutor()
+
+	assert.NoError(t, te.ExecuteDynamic(70, "hello", false, "", TaskOptions{}))
+
+	var stdout, stderr []string
+	long := 0
+	for _, msg := range rec.getLogs() {
+		switch {
+		case msg.IsError:
+			stderr = append(stderr, msg.Line)
+		case strings.HasPrefix(msg.Line, "x"):
+			assert.LessOrEqual(t, len(msg.Line), maxLineChunk)
+			assert.Equal(t, len(msg.Line), strings.Count(msg.Line, "x"))
+			long += len(msg.Line)
+		default:
+			stdout = append(stdout, msg.Line)
+		}
+	}
+	assert.Equal(t, 2<<20, long)
+	assert.Equal(t, []string{"after", "", "last", "Dynamic execution completed"}, stdout[1:])
+	assert.Equal(t, []string{"stderr after"}, stderr)
+}
+
+// TestStreamOutput_ExactChunk verifies a line of exactly maxLineChunk bytes is one LOG message, not followed
+// by an empty one for its line ending
+func TestStreamOutput_ExactChunk(t *testing.T) {
+	te, rec := recordingExecutor()
+	line := strings.Repeat("y", maxLineChunk)
+
+	te.streamOutput(te.newTaskOutput(71, TaskOptions{}), strings.NewReader(line+"\nnext\n"), false)
+	te.flushOutput(71)
+
+	assert.Equal(t, []string{line, "next"}, logLines(rec))
+}
+
+// TestExecuteDynamic_BackgroundChildDoesNotHold verifies a child holding the output open delays completion only briefly
+func TestExecuteDynamic_BackgroundChildDoesNotHold(t *testing.T) {
+	testutil.FakeClaude(t, `echo "started"; sleep 30 &`)
+	orig := streamDrainTimeout
+	streamDrainTimeout = 200 * time.Millisecond
+	defer func() { streamDrainTimeout = orig }()
+	te, rec := recordingExecutor()
+
+	start := time.Now()
+	assert.NoError(t, te.ExecuteDynamic(60, "hello", false, "", TaskOptions{}))
+
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.Contains(t, logLines(rec), "started")
+}
+
+// TestExecute_KeepsOutputOfQuickExit verifies legacy scripts keep their final output
+func TestExecute_KeepsOutputOfQuickExit(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "job.sh")
+	assert.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\necho done\n"), 0o755))
+	te, rec := recordingExecutor()
+
+	assert.NoError(t, te.Execute(61, script, TaskOptions{}))
+
+	assert.Contains(t, logLines(rec), "done")
+}
+
+// burstScript prints 1000 lines on each stream as fast as it can, then exits
+const burstScript = `i=0; while [ $i -lt 1000 ]; do echo "out $i"; echo "err $i" >&2; i=$((i+1)); done`
+
+// assertBurstDelivered checks every line of burstScript was recorded, in order on each stream
+func assertBurstDelivered(t *testing.T, rec *messageRecorder) {
+	t.Helper()
+	var stdout, stderr []string
+	for _, msg := range rec.getLogs() {
+		switch {
+		case strings.HasPrefix(msg.Line, "out "):
+			stdout = append(stdout, msg.Line)
+		case strings.HasPrefix(msg.Line, "err "):
+			stderr = append(stderr, msg.Line)
+		}
+	}
+	assert.Len(t, stdout, 1000)
+	assert.Len(t, stderr, 1000)
+	for i := range stdout {
+		assert.Equal(t, fmt.Sprintf("out %d", i), stdout[i])
+		assert.Equal(t, fmt.Sprintf("err %d", i), stderr[i])
+	}
+}
+
+// TestExecute_DeliversBurstBeforeReturn verifies a burst of output printed right before the task exits has
+// all been handed to the log callback by the time Execute and ExecuteDynamic return
+func TestExecute_DeliversBurstBeforeReturn(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "burst.sh")
+	assert.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\n"+burstScript+"\n"), 0o755))
+	te, rec := recordingExecutor()
+	assert.NoError(t, te.Execute(62, script, TaskOptions{}))
+	assertBurstDelivered(t, rec)
+
+	testutil.FakeClaude(t, burstScript)
+	te, rec = recordingExecutor()
+	assert.NoError(t, te.ExecuteDynamic(63, "hello", false, "", TaskOptions{}))
+	assertBurstDelivered(t, rec)
+	lines := logLines(rec)
+	assert.Equal(t, "Dynamic execution completed", lines[len(lines)-1])
+}
+
+// alternatingScript prints numbered lines alternately to stdout and stderr
+const alternatingScript = `for i in $(seq 1 50); do echo "out $i"; echo "err $i" >&2; done`
+
+// TestCombineOutput_KeepsOrder verifies a task with combineOutput has its stdout and stderr lines arrive in
+// the order it printed them, none marked isError, while by default stderr stays a stream of its own
+func TestCombineOutput_KeepsOrder(t *testing.T) {
+	testutil.FakeClaude(t, alternatingScript)
+	var want []string
+	for i := 1; i <= 50; i++ {
+		want = append(want, fmt.Sprintf("out %d", i), fmt.Sprintf("err %d", i))
+	}
+
+	te, rec := recordingExecutor()
+	result, output := runPoolTaskOn(t, te, rec, models.ExecuteMessage{TaskID: 1, ScriptContent: "both", CombineOutput: true})
+	assert.True(t, result.Success, result.Error)
+	assert.Equal(t, want, output[1:len(output)-1])
+	for _, msg := range rec.getLogs() {
+		assert.False(t, msg.IsError, "One stream: %q", msg.Line)
+	}
+
+	te, rec = recordingExecutor()
+	result, _ = runPoolTaskOn(t, te, rec, models.ExecuteMessage{TaskID: 2, ScriptContent: "both"})
+	assert.True(t, result.Success, result.Error)
+	errLines := 0
+	for _, msg := range rec.getLogs() {
+		if strings.HasPrefix(msg.Line, "err ") {
+			assert.True(t, msg.IsError, msg.Line)
+			errLines++
+		}
+	}
+	assert.Equal(t, 50, errLines)
+}
@@ -1,13 +1,106 @@
 package executor
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"os/exec"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
+	"github.com/berno/aaw-runner/internal/config"
+	"github.com/berno/aaw-runner/internal/matcher"
 	"github.com/berno/aaw-runner/internal/models"
 	"github.com/berno/aaw-runner/internal/runner"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/crypto/ssh"
 )
 
+// Default global backoff durations applied after limit detections
+const (
+	DefaultRateLimitCooldown  = config.DefaultRateLimitCooldown
+	DefaultUsageLimitCooldown = config.DefaultUsageLimitCooldown
+)
+
+// TaskResult describes how a task finished, as reported to the completion callback
+type TaskResult struct {
+	TaskID         int64
+	Success        bool
+	Error          string
+	ErrorCode      string               // Machine-readable failure code (models.ErrorCode*), empty on success
+	Classification string               // Failure classification (e.g. models.ClassificationOOM), empty when unclassified
+	Evidence       string               // What led to the classification
+	ExitCode       int                  // Process exit status, 128+N when signal N killed it; -1 when it never ran
+	Metadata       map[string]string    // Echo of the task's EXECUTE metadata
+	QueueWait      time.Duration        // Time from submission until a worker started the task
+	Duration       time.Duration        // Time since a worker started the task
+	TerminatedBy   Attribution          // Who cancelled or killed the task (zero if nobody did)
+	Usage          *ResourceUsage       // Final resource usage of the task's process; nil if it never ran or the platform has no rusage
+	DroppedOutput  int64                // Output lines dropped because the sender fell behind (see config.LogBackpressure)
+	ErrorDetail    string               // Last stderr lines of a failed task (see config.StderrTailLines)
+	ClaudeResult   *models.ClaudeResult // Final result claude reported, for tasks run with outputFormat json
+
+	OutputBytes          int64 // Bytes of output lines the task sent, without line endings
+	DiscardedOutputBytes int64 // Bytes of output lines printed past the task's output cap, and discarded
+}
+
+// Status is the task's final STATUS_UPDATE status: COMPLETED, CANCELLED or FAILED
+func (r TaskResult) Status() string {
+	switch {
+	case r.Success:
+		return models.StatusCompleted
+	case r.ErrorCode == models.ErrorCodeCancelled:
+		return models.StatusCancelled
+	default:
+		return models.StatusFailed
+	}
+}
+
+// newTaskResult builds the completion report for a task from its execution error
+func newTaskResult(taskID int64, err error) TaskResult {
+	result := TaskResult{TaskID: taskID, Success: err == nil}
+	if err == nil {
+		return result
+	}
+
+	result.Error = err.Error()
+	result.ErrorCode = ErrorCode(err)
+	result.ExitCode = exitCode(err)
+
+	var oomErr *OOMError
+	var authErr *AuthError
+	switch {
+	case errors.As(err, &oomErr):
+		result.Classification = models.ClassificationOOM
+		result.Evidence = oomErr.Evidence
+	case errors.As(err, &authErr):
+		result.Classification = models.ClassificationAuthError
+		result.Evidence = authErr.Evidence
+	}
+	return result
+}
+
+// exitCode is the exit status of the process a task failed with, as a shell reports it: the code it exited
+// with, or 128+N when signal N killed it. It is -1 when the task never ran, or lost its process.
+func exitCode(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return 128 + int(status.Signal())
+		}
+		return exitErr.ExitCode()
+	}
+	var remoteErr *ssh.ExitError
+	if errors.As(err, &remoteErr) {
+		return remoteExitCode(remoteErr)
+	}
+	return -1
+}
+
 // ExecutorPool manages concurrent task execution
 type ExecutorPool struct {
 	executor     *TaskExecutor
@@ -16,21 +109,74 @@ type ExecutorPool struct {
 	maxWorkers   int
 	wg           sync.WaitGroup
 	stopChan     chan struct{}
+	started      atomic.Bool
+	draining     atomic.Bool   // Set by Drain: Submit rejects every task
+	abortChan    chan struct{} // Closed by CancelAll: queued tasks are failed instead of started
+	abortOnce    sync.Once
+	pauseMu      sync.Mutex
+	resumed      chan struct{} // Non-nil while paused; closed by Resume
 	onCapacityChange func(maxParallel, running, available int)
-	onTaskComplete   func(taskID int64, success bool, errorMsg string)
+	onTaskComplete   func(result TaskResult)
+	onTaskStart      func(taskID int64, metadata map[string]string)
+	onDetected       func(taskID int64, category matcher.Category)
+	onTermination    func(attempt TerminationAttempt)
+
+	// Slots held for RESERVE_SLOT reservations, by reservation ID, and who hears of those dropped unused
+	reserveMu            sync.Mutex
+	reservations         map[string]*reservation
+	onReservationExpired func(reservationID, reason string)
+
+	// Tracing: the span of every pending task (no-op spans unless tracing is configured)
+	tracer  trace.Tracer
+	spansMu sync.Mutex
+	spans   map[int64]trace.Span
+
+	// Per-task EXECUTE metadata, held from Submit until completion
+	metadataMu sync.RWMutex
+	metadata   map[int64]map[string]string
+
+	// Per-task completion signals, closed once the completion callback has returned,
+	// and the submission/start times reported by Tasks
+	doneMu   sync.Mutex
+	done     map[int64]chan struct{}
+	schedule map[int64]*TaskSnapshot
+
+	// Tasks already killed via TerminateTask (duplicate KILL suppression)
+	terminatedMu sync.Mutex
+	terminated   map[int64]time.Time
+
+	// Global backoff: workers hold off starting queued tasks until backoffUntil
+	backoffMu          sync.Mutex
+	backoffUntil       time.Time
+	rateLimitCooldown  time.Duration
+	usageLimitCooldown time.Duration
+
+	// Environmental-failure circuit breaker (tripped by auth failures)
+	breaker circuitBreaker
 }
 
-// NewExecutorPool creates a new executor pool
+// NewExecutorPool creates a new executor pool with the default configuration and maxWorkers workers
 func NewExecutorPool(
 	executor *TaskExecutor,
 	maxWorkers int,
 	onCapacityChange func(maxParallel, running, available int),
-	onTaskComplete func(taskID int64, success bool, errorMsg string),
+	onTaskComplete func(result TaskResult),
 ) *ExecutorPool {
-	if maxWorkers <= 0 {
-		maxWorkers = runner.GetMaxParallel()
+	cfg := config.Default()
+	if maxWorkers > 0 {
+		cfg.MaxParallel = maxWorkers
 	}
+	return NewExecutorPoolWithConfig(executor, cfg, onCapacityChange, onTaskComplete)
+}
 
+// NewExecutorPoolWithConfig creates an executor pool sized and tuned by the resolved runner configuration
+func NewExecutorPoolWithConfig(
+	executor *TaskExecutor,
+	cfg config.Config,
+	onCapacityChange func(maxParallel, running, available int),
+	onTaskComplete func(result TaskResult),
+) *ExecutorPool {
+	maxWorkers := cfg.MaxParallel
 	stateManager := runner.NewTaskStateManager(maxWorkers, nil)
 
 	pool := &ExecutorPool{
@@ -39,23 +185,225 @@ func NewExecutorPool(
 		taskQueue:        make(chan models.ExecuteMessage, 100), // Buffered queue
 		maxWorkers:       maxWorkers,
 		stopChan:         make(chan struct{}),
+		abortChan:        make(chan struct{}),
 		onCapacityChange: onCapacityChange,
 		onTaskComplete:   onTaskComplete,
+		metadata:         make(map[int64]map[string]string),
+		done:             make(map[int64]chan struct{}),
+		schedule:         make(map[int64]*TaskSnapshot),
+		terminated:       make(map[int64]time.Time),
+		tracer:           defaultTracer(),
+		spans:            make(map[int64]trace.Span),
+		reservations:     make(map[string]*reservation),
+
+		rateLimitCooldown:  cfg.RateLimitCooldown,
+		usageLimitCooldown: cfg.UsageLimitCooldown,
 	}
 
+	executor.SetDetectionHandler(pool.onDetection)
+	executor.SetStallHandler(pool.onStall)
+	executor.traceContext = pool.taskContext
+
 	log.Printf("[POOL] Executor pool created: maxWorkers=%d", maxWorkers)
 	return pool
 }
 
+// SetTaskStartHandler registers a callback invoked when a worker begins executing a task
+func (p *ExecutorPool) SetTaskStartHandler(fn func(taskID int64, metadata map[string]string)) {
+	p.onTaskStart = fn
+}
+
+// SetDetectionObserver registers a callback invoked for every rate limit, usage limit or auth
+// detection in task output
+func (p *ExecutorPool) SetDetectionObserver(fn func(taskID int64, category matcher.Category)) {
+	p.onDetected = fn
+}
+
+// TaskMetadata returns the metadata submitted with a task that has not completed yet
+// The returned map must not be modified
+func (p *ExecutorPool) TaskMetadata(taskID int64) map[string]string {
+	p.metadataMu.RLock()
+	defer p.metadataMu.RUnlock()
+	return p.metadata[taskID]
+}
+
+// Engines that run a task, as reported in TaskSnapshot
+const (
+	EngineClaude = "claude" // Inline content run as a claude prompt
+	EngineScript = "script" // Legacy script path
+)
+
+// TaskSnapshot describes a submitted task that has not completed yet
+type TaskSnapshot struct {
+	TaskID      int64
+	Engine      string           // EngineClaude or EngineScript
+	State       runner.TaskState // TaskStateQueued until a worker starts it
+	SubmittedAt time.Time
+	StartedAt   time.Time // Zero while queued
+	Metadata    map[string]string
+
+	TerminatedBy Attribution // Latest requester of a cancel or kill
+}
+
+// Tasks lists the running and queued tasks, ordered by task ID
+func (p *ExecutorPool) Tasks() []TaskSnapshot {
+	p.doneMu.Lock()
+	tasks := make([]TaskSnapshot, 0, len(p.schedule))
+	for _, task := range p.schedule {
+		tasks = append(tasks, *task)
+	}
+	p.doneMu.Unlock()
+
+	for i := range tasks {
+		tasks[i].State = runner.TaskStateQueued
+		if !tasks[i].StartedAt.IsZero() {
+			tasks[i].State, _ = p.stateManager.GetTaskState(tasks[i].TaskID)
+		}
+		tasks[i].Metadata = p.TaskMetadata(tasks[i].TaskID)
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].TaskID < tasks[j].TaskID })
+	return tasks
+}
+
+// PoolSnapshot is the pool's tasks and capacity at one instant (see Snapshot)
+type PoolSnapshot struct {
+	TakenAt     time.Time
+	Tasks       []TaskSnapshot // Ordered by task ID; Metadata is not filled in
+	MaxParallel int
+	Running     int
+	Available   int
+}
+
+// Snapshot lists the tasks the pool holds together with the capacity they leave, read at a single
+// instant so the two agree. A task finishing at that moment is left out, as is one still being
+// submitted; its own TASK_COMPLETED or TASK_STARTED follows.
+func (p *ExecutorPool) Snapshot() PoolSnapshot {
+	p.doneMu.Lock()
+	entries, maxParallel, running := p.stateManager.Snapshot()
+	snap := PoolSnapshot{TakenAt: time.Now(), MaxParallel: maxParallel, Running: running}
+	for _, entry := range entries {
+		task, ok := p.schedule[entry.TaskID]
+		if !ok {
+			continue
+		}
+		t := *task
+		t.State = entry.State
+		if t.StartedAt.IsZero() && entry.State == runner.TaskStateRunning {
+			t.State = runner.TaskStateQueued // Counted as running from Submit on, to hold its slot
+		}
+		snap.Tasks = append(snap.Tasks, t)
+	}
+	p.doneMu.Unlock()
+
+	snap.Available = p.advertised(running, maxParallel-running)
+	sort.Slice(snap.Tasks, func(i, j int) bool { return snap.Tasks[i].TaskID < snap.Tasks[j].TaskID })
+	return snap
+}
+
+// setTaskMetadata stores (or with nil, forgets) a task's metadata
+func (p *ExecutorPool) setTaskMetadata(taskID int64, md map[string]string) {
+	p.metadataMu.Lock()
+	defer p.metadataMu.Unlock()
+	if md == nil {
+		delete(p.metadata, taskID)
+		return
+	}
+	p.metadata[taskID] = md
+}
+
+// trackCompletion creates the completion signal for a newly submitted task
+func (p *ExecutorPool) trackCompletion(msg models.ExecuteMessage) {
+	engine := taskEngine(msg)
+
+	p.doneMu.Lock()
+	defer p.doneMu.Unlock()
+	p.done[msg.TaskID] = make(chan struct{})
+	p.schedule[msg.TaskID] = &TaskSnapshot{TaskID: msg.TaskID, Engine: engine, SubmittedAt: time.Now()}
+}
+
+// timings returns how long a task was queued and how long it has been running (both zero while queued)
+func (p *ExecutorPool) timings(taskID int64) (queued, running time.Duration) {
+	p.doneMu.Lock()
+	defer p.doneMu.Unlock()
+	task, ok := p.schedule[taskID]
+	if !ok || task.StartedAt.IsZero() {
+		return 0, 0
+	}
+	return task.StartedAt.Sub(task.SubmittedAt), time.Since(task.StartedAt)
+}
+
+// taskEngine reports which engine runs msg
+func taskEngine(msg models.ExecuteMessage) string {
+	if msg.ScriptContent != "" {
+		return EngineClaude
+	}
+	return EngineScript
+}
+
+// markStarted records when a worker began executing a task and returns how long it was queued
+func (p *ExecutorPool) markStarted(taskID int64) time.Duration {
+	p.doneMu.Lock()
+	defer p.doneMu.Unlock()
+	task, ok := p.schedule[taskID]
+	if !ok {
+		return 0
+	}
+	task.StartedAt = time.Now()
+	return task.StartedAt.Sub(task.SubmittedAt)
+}
+
+// signalCompletion closes a task's completion signal
+func (p *ExecutorPool) signalCompletion(taskID int64) {
+	p.doneMu.Lock()
+	defer p.doneMu.Unlock()
+	if done, ok := p.done[taskID]; ok {
+		close(done)
+		delete(p.done, taskID)
+	}
+	delete(p.schedule, taskID)
+}
+
+// waitForCompletion blocks until the task's completion has been reported
+// Returns true immediately for tasks the pool is not tracking
+func (p *ExecutorPool) waitForCompletion(taskID int64, timeout time.Duration) bool {
+	p.doneMu.Lock()
+	done, ok := p.done[taskID]
+	p.doneMu.Unlock()
+	if !ok {
+		return true
+	}
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
 // Start launches the worker goroutines
 func (p *ExecutorPool) Start() {
 	log.Printf("[POOL] Starting %d workers", p.maxWorkers)
+	p.started.Store(true)
 	for i := 0; i < p.maxWorkers; i++ {
 		p.wg.Add(1)
 		go p.worker(i)
 	}
 }
 
+// Running reports whether the workers have been started and not yet stopped
+func (p *ExecutorPool) Running() bool {
+	if !p.started.Load() {
+		return false
+	}
+	select {
+	case <-p.stopChan:
+		return false
+	default:
+		return true
+	}
+}
+
 // Stop gracefully stops the pool
 func (p *ExecutorPool) Stop() {
 	log.Println("[POOL] Stopping executor pool")
@@ -65,15 +413,35 @@ func (p *ExecutorPool) Stop() {
 }
 
 // Submit adds a task to the execution queue
-// Returns false if the pool is at capacity
+// Returns false if the pool is at capacity or draining
 func (p *ExecutorPool) Submit(msg models.ExecuteMessage) bool {
-	if !p.stateManager.CanAcceptNewTask() {
+	if p.draining.Load() {
+		log.Printf("[POOL] Cannot accept task %d: pool is draining", msg.TaskID)
+		return false
+	}
+	if !p.breakerAllowsTask() {
+		_, reason := p.breaker.state()
+		log.Printf("[POOL] Cannot accept task %d: circuit breaker open (%s), probe task already running", msg.TaskID, reason)
+		return false
+	}
+	// A reserved slot is the task's whatever else is running
+	reserved := msg.ReservationID != "" && p.takeReservation(msg.ReservationID)
+	if reserved {
+		log.Printf("[POOL] Task %d takes reservation %s", msg.TaskID, msg.ReservationID)
+	} else if msg.ReservationID != "" {
+		log.Printf("[POOL] Task %d: reservation %s is not held, using regular capacity", msg.TaskID, msg.ReservationID)
+	}
+	if !reserved && p.freeSlots() <= 0 {
 		log.Printf("[POOL] Cannot accept task %d: pool at capacity", msg.TaskID)
 		return false
 	}
+	p.breaker.admit(msg.TaskID)
 
 	// Mark task as running in state manager
 	p.stateManager.SetTaskState(msg.TaskID, runner.TaskStateRunning)
+	p.setTaskMetadata(msg.TaskID, LimitMetadata(msg.TaskID, msg.Metadata))
+	p.trackCompletion(msg)
+	p.startTaskSpan(msg)
 
 	// Report capacity change
 	p.reportCapacity()
@@ -86,6 +454,9 @@ func (p *ExecutorPool) Submit(msg models.ExecuteMessage) bool {
 	default:
 		// Queue is full, revert state
 		p.stateManager.SetTaskState(msg.TaskID, runner.TaskStateFailed)
+		p.setTaskMetadata(msg.TaskID, nil)
+		p.signalCompletion(msg.TaskID)
+		p.endTaskSpan(newTaskResult(msg.TaskID, newTaskError(models.ErrorCodeCapacity, "queue full")))
 		log.Printf("[POOL] Task %d rejected: queue full", msg.TaskID)
 		p.reportCapacity()
 		return false
@@ -94,12 +465,63 @@ func (p *ExecutorPool) Submit(msg models.ExecuteMessage) bool {
 
 // CanAccept returns true if the pool can accept more tasks
 func (p *ExecutorPool) CanAccept() bool {
-	return p.stateManager.CanAcceptNewTask()
+	return !p.draining.Load() && p.freeSlots() > 0 && p.breakerAllowsTask()
+}
+
+// RejectReason explains why Submit would currently reject a task, with the matching failure code
+func (p *ExecutorPool) RejectReason() (code, reason string) {
+	if p.draining.Load() {
+		return models.ErrorCodeRunnerShutdown, "Runner is shutting down"
+	}
+	if open, reason := p.breaker.state(); open {
+		return models.ErrorCodePolicyRejected, "Runner paused by circuit breaker: " + reason
+	}
+	return models.ErrorCodeCapacity, "Runner at capacity"
 }
 
 // GetCapacity returns the current capacity information
+// Reserved slots are not available; while the circuit breaker is open at most one (probe) slot is
+// advertised, and none while draining
 func (p *ExecutorPool) GetCapacity() (maxParallel, running, available int) {
-	return p.stateManager.GetCapacity()
+	maxParallel, running, available = p.stateManager.GetCapacity()
+	return maxParallel, running, p.advertised(running, available)
+}
+
+// advertised returns how many of the available slots are offered to the backend (see GetCapacity)
+func (p *ExecutorPool) advertised(running, available int) int {
+	available = max(available-p.Reserved(), 0)
+	if p.draining.Load() {
+		return 0
+	}
+	if open, _ := p.breaker.state(); open {
+		if running > 0 {
+			available = 0
+		} else if available > 1 {
+			available = 1
+		}
+	}
+	return available
+}
+
+// BreakerState reports whether the environmental-failure circuit breaker is open and why
+func (p *ExecutorPool) BreakerState() (open bool, reason string) {
+	return p.breaker.state()
+}
+
+// ResetBreaker closes the circuit breaker (operator intervention)
+func (p *ExecutorPool) ResetBreaker() {
+	if p.breaker.reset() {
+		log.Println("[POOL] Circuit breaker reset by operator")
+		p.reportCapacity()
+	}
+}
+
+// breakerAllowsTask returns false while the breaker is open and a probe task is already running
+func (p *ExecutorPool) breakerAllowsTask() bool {
+	if open, _ := p.breaker.state(); !open {
+		return true
+	}
+	return p.stateManager.GetRunningCount() == 0
 }
 
 // IsTaskRunning checks if a specific task is currently running
@@ -109,14 +531,32 @@ func (p *ExecutorPool) IsTaskRunning(taskID int64) bool {
 }
 
 // CancelTask attempts to cancel a running task
-func (p *ExecutorPool) CancelTask(taskID int64) error {
+func (p *ExecutorPool) CancelTask(taskID int64, by Attribution) error {
+	requestedAt := time.Now()
+	p.attribute(taskID, by)
 	p.stateManager.SetTaskState(taskID, runner.TaskStateCancelling)
-	return p.executor.CancelTask(taskID)
+	span := p.startChildSpan(taskID, spanCancel)
+	err := p.executor.CancelTask(taskID)
+	endSpan(span, err)
+	p.observeTermination(TerminationCancel, taskID, by, requestedAt, err)
+	return err
+}
+
+// ForceKillTask immediately kills a running task, without waiting for it to die (see TerminateTask)
+func (p *ExecutorPool) ForceKillTask(taskID int64, by Attribution) error {
+	requestedAt := time.Now()
+	err := p.forceKill(taskID, by)
+	p.observeTermination(TerminationKill, taskID, by, requestedAt, err)
+	return err
 }
 
-// ForceKillTask immediately kills a running task
-func (p *ExecutorPool) ForceKillTask(taskID int64) error {
-	return p.executor.ForceKillTask(taskID)
+// forceKill delivers the kill signal to a task's process group
+func (p *ExecutorPool) forceKill(taskID int64, by Attribution) error {
+	p.attribute(taskID, by)
+	span := p.startChildSpan(taskID, spanKill)
+	err := p.executor.ForceKillTask(taskID)
+	endSpan(span, err)
+	return err
 }
 
 // worker processes tasks from the queue
@@ -130,58 +570,207 @@ func (p *ExecutorPool) worker(id int) {
 			log.Printf("[POOL] Worker %d stopping", id)
 			return
 		case msg := <-p.taskQueue:
+			if !p.waitWhilePaused(id, msg.TaskID) || !p.waitForBackoff(id, msg.TaskID) || p.aborted() {
+				log.Printf("[POOL] Worker %d shutting down (task %d not started)", id, msg.TaskID)
+				err := newTaskError(models.ErrorCodeRunnerShutdown, "runner shut down before task %d started", msg.TaskID)
+				p.completeTask(id, msg.TaskID, p.TaskMetadata(msg.TaskID), err)
+				continue
+			}
 			p.executeTask(id, msg)
 		}
 	}
 }
 
+// onDetection extends the global backoff when a task hits a rate or usage limit
+// Rate limits get a short cool-down; usage limits wait until the reported reset time
+// (or a long default cool-down when the reset time is unknown). Auth failures trip
+// the circuit breaker instead, since waiting does not fix bad credentials.
+func (p *ExecutorPool) onDetection(taskID int64, category matcher.Category, resetAt time.Time) {
+	p.traceDetection(taskID, category, resetAt)
+	if p.onDetected != nil {
+		p.onDetected(taskID, category)
+	}
+
+	var until time.Time
+	switch category {
+	case matcher.CategoryUsageLimit:
+		if !resetAt.IsZero() && resetAt.After(time.Now()) {
+			until = resetAt
+		} else {
+			until = time.Now().Add(p.usageLimitCooldown)
+		}
+	case matcher.CategoryRateLimit:
+		until = time.Now().Add(p.rateLimitCooldown)
+	case matcher.CategoryAuth:
+		reason := fmt.Sprintf("authentication failure detected in task %d", taskID)
+		if p.breaker.trip(reason) {
+			log.Printf("[POOL] Circuit breaker opened: %s; accepting only probe tasks until reset", reason)
+			p.reportCapacity()
+		}
+		return
+	default:
+		return
+	}
+
+	p.backoffMu.Lock()
+	defer p.backoffMu.Unlock()
+	// Never shorten an existing backoff (a rate limit must not cut a usage-limit cool-down short)
+	if until.After(p.backoffUntil) {
+		p.backoffUntil = until
+		log.Printf("[POOL] %s detected in task %d, holding new task starts until %s", category, taskID, until.Format(time.RFC3339))
+	}
+}
+
+// BackoffRemaining returns how long new task starts are currently held back
+func (p *ExecutorPool) BackoffRemaining() time.Duration {
+	p.backoffMu.Lock()
+	defer p.backoffMu.Unlock()
+	remaining := time.Until(p.backoffUntil)
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// waitForBackoff blocks until the global backoff expires before taskID is started
+// Returns false if the pool was stopped or its tasks cancelled while waiting
+func (p *ExecutorPool) waitForBackoff(workerID int, taskID int64) bool {
+	for {
+		remaining := p.BackoffRemaining()
+		if remaining <= 0 {
+			return true
+		}
+
+		log.Printf("[POOL] Worker %d waiting %s for backoff to expire", workerID, remaining.Round(time.Second))
+		span := p.startChildSpan(taskID, spanBackoff, attribute.Float64("aaw.backoff.seconds", remaining.Seconds()))
+		timer := time.NewTimer(remaining)
+		select {
+		case <-p.stopChan:
+			timer.Stop()
+			endSpan(span, errors.New("pool stopped"))
+			return false
+		case <-p.abortChan:
+			timer.Stop()
+			endSpan(span, errors.New("tasks cancelled"))
+			return false
+		case <-timer.C:
+			// Re-check: the backoff may have been extended while waiting
+			span.End()
+		}
+	}
+}
+
 // executeTask runs a single task
 func (p *ExecutorPool) executeTask(workerID int, msg models.ExecuteMessage) {
 	log.Printf("[POOL] Worker %d executing task %d", workerID, msg.TaskID)
 
-	var err error
+	metadata := p.TaskMetadata(msg.TaskID)
+	p.traceStarted(msg.TaskID, p.markStarted(msg.TaskID))
+	if p.onTaskStart != nil {
+		p.onTaskStart(msg.TaskID, metadata)
+	}
 
-	// Execute based on message type
+	p.completeTask(workerID, msg.TaskID, metadata, p.runTask(workerID, msg))
+}
+
+// runTask prepares a task's script and runs it, returning why it failed
+func (p *ExecutorPool) runTask(workerID int, msg models.ExecuteMessage) error {
+	var content string
+	opts := newTaskOptions(msg)
 	if msg.ScriptContent != "" {
+		var err error
+		if content, err = p.executor.prepareScript(msg, &opts); err != nil {
+			return err // Invalid variables or content; prepareScript reported why
+		}
+	}
+	if err := p.executor.prepareEnv(msg, &opts); err != nil {
+		return err // An env that cannot be set; prepareEnv reported why
+	}
+	if msg.WorkingDir != "" {
+		if err := p.executor.prepareWorkDir(msg, &opts); err != nil {
+			return err // A missing directory; prepareWorkDir reported why
+		}
+	}
+	if msg.Interpreter != "" {
+		if err := p.executor.prepareInterpreter(msg, &opts); err != nil {
+			return err // An interpreter not allowed or not installed; prepareInterpreter reported why
+		}
+	}
+	if msg.Model != "" {
+		if err := p.executor.prepareModel(msg, &opts); err != nil {
+			return err // A model not allowed; prepareModel reported why
+		}
+	}
+	if msg.Repo != "" {
+		if err := p.executor.prepareWorkspace(msg); err != nil {
+			return err // A failed checkout; already reported in the task's output
+		}
+	}
+
+	// Execute based on message type
+	switch {
+	case msg.Host != "":
+		// Dynamic execution on an SSH host
+		return p.executor.ExecuteRemote(msg.TaskID, msg.Host, content, msg.SkipPermissions, opts)
+	case msg.ScriptContent != "":
 		// Dynamic execution
-		err = p.executor.ExecuteDynamic(msg.TaskID, msg.ScriptContent, msg.SkipPermissions, msg.SessionMode)
-	} else if msg.Script != "" {
+		return p.executor.ExecuteDynamic(msg.TaskID, content, msg.SkipPermissions, msg.SessionMode, opts)
+	case msg.Script != "":
 		// Legacy execution
-		err = p.executor.Execute(msg.TaskID, msg.Script)
-	} else {
+		return p.executor.Execute(msg.TaskID, msg.Script, opts)
+	default:
 		log.Printf("[POOL] Worker %d: task %d has no script content", workerID, msg.TaskID)
-		err = nil
+		return nil
 	}
+}
 
-	success := err == nil
-	errorMsg := ""
+// completeTask records a task's outcome and reports it to the completion callback
+func (p *ExecutorPool) completeTask(workerID int, taskID int64, metadata map[string]string, err error) {
+	result := newTaskResult(taskID, err)
+	result.Metadata = metadata
+	result.QueueWait, result.Duration = p.timings(taskID)
+	result.TerminatedBy = p.terminatedBy(taskID)
+	result.Usage = p.executor.takeUsage(taskID)
+	result.DroppedOutput = p.executor.takeDroppedOutput(taskID)
+	result.ClaudeResult = p.executor.takeClaudeResult(taskID)
+	size := p.executor.takeOutputSize(taskID)
+	result.OutputBytes, result.DiscardedOutputBytes = size.sent, size.discarded
+	if stderr := p.executor.takeStderrTail(taskID); !result.Success {
+		result.ErrorDetail = stderr
+	}
+	p.executor.removeWorkspace(taskID)
 	if err != nil {
-		errorMsg = err.Error()
-		// Check if this was a cancellation
-		if errorMsg == "task cancelled" {
-			p.stateManager.SetTaskState(msg.TaskID, runner.TaskStateCancelled)
+		if result.ErrorCode == models.ErrorCodeCancelled {
+			p.stateManager.SetTaskState(taskID, runner.TaskStateCancelled)
 		} else {
-			p.stateManager.SetTaskState(msg.TaskID, runner.TaskStateFailed)
+			p.stateManager.SetTaskState(taskID, runner.TaskStateFailed)
 		}
 	} else {
-		p.stateManager.SetTaskState(msg.TaskID, runner.TaskStateCompleted)
+		p.stateManager.SetTaskState(taskID, runner.TaskStateCompleted)
+	}
+	// A successful probe proves the environment is healthy again
+	if p.breaker.probeDone(taskID, err == nil) {
+		log.Printf("[POOL] Circuit breaker closed: probe task %d succeeded", taskID)
 	}
 
-	log.Printf("[POOL] Worker %d completed task %d (success=%v)", workerID, msg.TaskID, success)
+	log.Printf("[POOL] Worker %d completed task %d (success=%v, code=%s)", workerID, taskID, result.Success, result.ErrorCode)
 
 	// Report capacity change
 	p.reportCapacity()
 
 	// Notify completion callback
 	if p.onTaskComplete != nil {
-		p.onTaskComplete(msg.TaskID, success, errorMsg)
+		p.onTaskComplete(result)
 	}
+	p.endTaskSpan(result)
+	p.setTaskMetadata(taskID, nil)
+	p.signalCompletion(taskID)
 }
 
 // reportCapacity sends current capacity to the callback
 func (p *ExecutorPool) reportCapacity() {
 	if p.onCapacityChange != nil {
-		max, running, available := p.stateManager.GetCapacity()
+		max, running, available := p.GetCapacity()
 		p.onCapacityChange(max, running, available)
 	}
 }
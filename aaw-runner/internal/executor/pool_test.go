@@ -0,0 +1,288 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/matcher"
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/runner"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestPool creates a pool whose executor discards all output
+func newTestPool(maxWorkers int) *ExecutorPool {
+	te := NewTaskExecutor(func(models.LogMessage) {}, func(models.StatusUpdateMessage) {})
+	return NewExecutorPool(te, maxWorkers, nil, nil)
+}
+
+// TestOnDetection_RateLimitUsesShortCooldown verifies rate limits trigger a short backoff
+func TestOnDetection_RateLimitUsesShortCooldown(t *testing.T) {
+	pool := newTestPool(1)
+	pool.rateLimitCooldown = 2 * time.Second
+	pool.usageLimitCooldown = time.Hour
+
+	pool.onDetection(1, matcher.CategoryRateLimit, time.Time{})
+
+	remaining := pool.BackoffRemaining()
+	assert.Greater(t, remaining, time.Duration(0), "Backoff should be active")
+	assert.LessOrEqual(t, remaining, 2*time.Second, "Rate limit backoff should use the short cool-down")
+}
+
+// TestOnDetection_UsageLimitUsesLongCooldown verifies usage limits trigger a long backoff
+func TestOnDetection_UsageLimitUsesLongCooldown(t *testing.T) {
+	pool := newTestPool(1)
+	pool.rateLimitCooldown = 2 * time.Second
+	pool.usageLimitCooldown = time.Hour
+
+	pool.onDetection(1, matcher.CategoryUsageLimit, time.Time{})
+
+	assert.Greater(t, pool.BackoffRemaining(), 59*time.Minute, "Usage limit without reset time should use the long cool-down")
+}
+
+// TestOnDetection_UsageLimitHonorsResetTime verifies the extracted reset time bounds the backoff
+func TestOnDetection_UsageLimitHonorsResetTime(t *testing.T) {
+	pool := newTestPool(1)
+	pool.usageLimitCooldown = time.Hour
+
+	resetAt := time.Now().Add(3 * time.Hour)
+	pool.onDetection(1, matcher.CategoryUsageLimit, resetAt)
+
+	remaining := pool.BackoffRemaining()
+	assert.Greater(t, remaining, 2*time.Hour+59*time.Minute)
+	assert.LessOrEqual(t, remaining, 3*time.Hour)
+}
+
+// TestOnDetection_RateLimitDoesNotShortenUsageBackoff verifies backoffs only ever extend
+func TestOnDetection_RateLimitDoesNotShortenUsageBackoff(t *testing.T) {
+	pool := newTestPool(1)
+	pool.rateLimitCooldown = time.Second
+	pool.usageLimitCooldown = time.Hour
+
+	pool.onDetection(1, matcher.CategoryUsageLimit, time.Time{})
+	pool.onDetection(2, matcher.CategoryRateLimit, time.Time{})
+
+	assert.Greater(t, pool.BackoffRemaining(), 59*time.Minute, "Rate limit must not cut a usage-limit cool-down short")
+}
+
+// TestWaitForBackoff_ReturnsFalseWhenStopped verifies workers waiting out a backoff exit on Stop
+func TestWaitForBackoff_ReturnsFalseWhenStopped(t *testing.T) {
+	pool := newTestPool(1)
+	pool.onDetection(1, matcher.CategoryUsageLimit, time.Time{})
+
+	result := make(chan bool, 1)
+	go func() {
+		result <- pool.waitForBackoff(0, 1)
+	}()
+
+	close(pool.stopChan)
+
+	select {
+	case ok := <-result:
+		assert.False(t, ok, "waitForBackoff should report the pool was stopped")
+	case <-time.After(time.Second):
+		t.Fatal("waitForBackoff did not return after stop")
+	}
+}
+
+// TestOnDetection_AuthTripsBreaker verifies auth failures stop the pool from accepting work
+func TestOnDetection_AuthTripsBreaker(t *testing.T) {
+	pool := newTestPool(3)
+
+	pool.onDetection(1, matcher.CategoryAuth, time.Time{})
+
+	open, reason := pool.BreakerState()
+	assert.True(t, open, "Breaker should open on auth failure")
+	assert.Contains(t, reason, "task 1")
+	assert.Equal(t, time.Duration(0), pool.BackoffRemaining(), "Auth failures should not use the time-based backoff")
+
+	_, _, available := pool.GetCapacity()
+	assert.Equal(t, 1, available, "Only a single probe slot should be advertised")
+	code, reason := pool.RejectReason()
+	assert.Equal(t, models.ErrorCodePolicyRejected, code)
+	assert.Contains(t, reason, "circuit breaker")
+}
+
+// TestBreaker_AllowsSingleProbeAndClosesOnSuccess verifies probe semantics
+func TestBreaker_AllowsSingleProbeAndClosesOnSuccess(t *testing.T) {
+	pool := newTestPool(3)
+	pool.onDetection(1, matcher.CategoryAuth, time.Time{})
+
+	assert.True(t, pool.Submit(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 10}), "First task should run as a probe")
+	assert.False(t, pool.Submit(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 11}), "Second task should be rejected while the probe runs")
+
+	_, _, available := pool.GetCapacity()
+	assert.Equal(t, 0, available, "No slots should be advertised while the probe runs")
+
+	// Probe succeeds (no script content executes as a no-op success)
+	pool.executeTask(0, <-pool.taskQueue)
+
+	open, _ := pool.BreakerState()
+	assert.False(t, open, "Successful probe should close the breaker")
+	assert.True(t, pool.CanAccept())
+}
+
+// TestBreaker_TaskRunningAtTripDoesNotClose verifies only the probe can close the breaker, not a task that
+// was already running when it tripped
+func TestBreaker_TaskRunningAtTripDoesNotClose(t *testing.T) {
+	pool := newTestPool(3)
+	assert.True(t, pool.Submit(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 10}))
+	pool.onDetection(1, matcher.CategoryAuth, time.Time{})
+
+	pool.executeTask(0, <-pool.taskQueue)
+
+	open, _ := pool.BreakerState()
+	assert.True(t, open, "A task admitted before the trip is not a probe")
+
+	assert.True(t, pool.Submit(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 11}), "The probe slot is free again")
+	pool.executeTask(0, <-pool.taskQueue)
+
+	open, _ = pool.BreakerState()
+	assert.False(t, open, "Successful probe should close the breaker")
+}
+
+// TestOnDetection_AuthReportsClampedCapacity verifies the capacity pushed when the breaker trips is the
+// single probe slot GetCapacity advertises, not every free slot
+func TestOnDetection_AuthReportsClampedCapacity(t *testing.T) {
+	var reported []int
+	te := NewTaskExecutor(func(models.LogMessage) {}, func(models.StatusUpdateMessage) {})
+	pool := NewExecutorPool(te, 3, func(maxParallel, running, available int) { reported = append(reported, available) }, nil)
+
+	pool.onDetection(1, matcher.CategoryAuth, time.Time{})
+
+	assert.Equal(t, []int{1}, reported)
+}
+
+// TestResetBreaker verifies operator reset closes the breaker
+func TestResetBreaker(t *testing.T) {
+	pool := newTestPool(2)
+	pool.onDetection(1, matcher.CategoryAuth, time.Time{})
+
+	pool.ResetBreaker()
+
+	open, _ := pool.BreakerState()
+	assert.False(t, open)
+	_, _, available := pool.GetCapacity()
+	assert.Equal(t, 2, available, "Full capacity should be advertised after reset")
+}
+
+// TestMetadata_EchoedOnStartAndCompletion verifies EXECUTE metadata comes back on task messages
+func TestMetadata_EchoedOnStartAndCompletion(t *testing.T) {
+	var started map[string]string
+	var completed TaskResult
+
+	te := NewTaskExecutor(func(models.LogMessage) {}, func(models.StatusUpdateMessage) {})
+	pool := NewExecutorPool(te, 1, nil, func(result TaskResult) { completed = result })
+	pool.SetTaskStartHandler(func(taskID int64, metadata map[string]string) {
+		started = metadata
+	})
+
+	md := map[string]string{"traceId": "abc123", "tenant": "acme"}
+	assert.True(t, pool.Submit(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 5, Metadata: md}))
+	assert.Equal(t, md, pool.TaskMetadata(5), "Metadata should be available while the task runs")
+
+	pool.executeTask(0, <-pool.taskQueue)
+
+	assert.Equal(t, md, started)
+	assert.Equal(t, md, completed.Metadata)
+	assert.Nil(t, pool.TaskMetadata(5), "Metadata should be released after completion")
+}
+
+// TestLimitMetadata verifies oversized metadata is trimmed rather than rejected
+func TestLimitMetadata(t *testing.T) {
+	assert.Nil(t, LimitMetadata(1, nil))
+
+	md := make(map[string]string)
+	for i := 0; i < MaxMetadataKeys+3; i++ {
+		md[fmt.Sprintf("key%02d", i)] = "v"
+	}
+	md["key00"] = strings.Repeat("x", MaxMetadataValueBytes+10)
+	// Multi-byte character straddling the limit must not be split
+	md["key01"] = strings.Repeat("a", MaxMetadataValueBytes-1) + "é"
+
+	limited := LimitMetadata(1, md)
+
+	assert.Len(t, limited, MaxMetadataKeys)
+	assert.Contains(t, limited, "key09")
+	assert.NotContains(t, limited, "key10", "Keys beyond the cap are dropped in sorted order")
+	assert.Len(t, limited["key00"], MaxMetadataValueBytes)
+	assert.Equal(t, strings.Repeat("a", MaxMetadataValueBytes-1), limited["key01"])
+	assert.Len(t, md, MaxMetadataKeys+3, "Input map must not be modified")
+}
+
+// TestTasks_ReportsQueuedAndRunning verifies task snapshots track a task from submission to completion
+func TestTasks_ReportsQueuedAndRunning(t *testing.T) {
+	testutil.FakeClaude(t, "sleep 0.3")
+	te := NewTaskExecutor(func(models.LogMessage) {}, func(models.StatusUpdateMessage) {})
+	pool := NewExecutorPool(te, 1, nil, nil)
+	md := map[string]string{"jobId": "42"}
+
+	assert.True(t, pool.Submit(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 8, ScriptContent: "hello", Metadata: md}))
+	tasks := pool.Tasks()
+	assert.Len(t, tasks, 1)
+	assert.Equal(t, runner.TaskStateQueued, tasks[0].State)
+	assert.Equal(t, EngineClaude, tasks[0].Engine)
+	assert.False(t, tasks[0].SubmittedAt.IsZero())
+	assert.True(t, tasks[0].StartedAt.IsZero())
+	assert.Equal(t, md, tasks[0].Metadata)
+
+	pool.Start()
+	defer pool.Stop()
+	waitForRegistration(t, te, 8)
+	tasks = pool.Tasks()
+	assert.Equal(t, runner.TaskStateRunning, tasks[0].State)
+	assert.False(t, tasks[0].StartedAt.Before(tasks[0].SubmittedAt))
+
+	assert.True(t, pool.WaitIdle(5*time.Second))
+	assert.Empty(t, pool.Tasks())
+}
+
+// TestSnapshot_TasksAndCapacity verifies a snapshot lists queued and running tasks with the capacity they leave
+func TestSnapshot_TasksAndCapacity(t *testing.T) {
+	testutil.FakeClaude(t, "sleep 0.3")
+	te := NewTaskExecutor(func(models.LogMessage) {}, func(models.StatusUpdateMessage) {})
+	pool := NewExecutorPool(te, 2, nil, nil)
+
+	assert.True(t, pool.Submit(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 8, ScriptContent: "hello"}))
+	snap := pool.Snapshot()
+	assert.Len(t, snap.Tasks, 1)
+	assert.Equal(t, runner.TaskStateQueued, snap.Tasks[0].State)
+	assert.Equal(t, 2, snap.MaxParallel)
+	assert.Equal(t, 1, snap.Running, "A queued task holds its slot")
+	assert.Equal(t, 1, snap.Available)
+
+	pool.Start()
+	defer pool.Stop()
+	waitForRegistration(t, te, 8)
+	assert.True(t, pool.Submit(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 9, ScriptContent: "hello"}))
+	waitForRegistration(t, te, 9)
+	snap = pool.Snapshot()
+	assert.Equal(t, []int64{8, 9}, []int64{snap.Tasks[0].TaskID, snap.Tasks[1].TaskID})
+	assert.Equal(t, runner.TaskStateRunning, snap.Tasks[0].State)
+	assert.False(t, snap.TakenAt.Before(snap.Tasks[0].StartedAt))
+	assert.Equal(t, 2, snap.Running)
+	assert.Zero(t, snap.Available)
+
+	assert.True(t, pool.WaitIdle(5*time.Second))
+	snap = pool.Snapshot()
+	assert.Empty(t, snap.Tasks)
+	assert.Equal(t, 2, snap.Available)
+}
+
+// TestOnDetection_NotifiesObserver verifies every detection reaches the observer
+func TestOnDetection_NotifiesObserver(t *testing.T) {
+	pool := newTestPool(1)
+	var seen []matcher.Category
+	pool.SetDetectionObserver(func(taskID int64, category matcher.Category) {
+		assert.Equal(t, int64(5), taskID)
+		seen = append(seen, category)
+	})
+
+	pool.onDetection(5, matcher.CategoryRateLimit, time.Time{})
+	pool.onDetection(5, matcher.CategoryAuth, time.Time{})
+
+	assert.Equal(t, []matcher.Category{matcher.CategoryRateLimit, matcher.CategoryAuth}, seen)
+}
@@ -0,0 +1,62 @@
+package executor
+
+import "time"
+
+// progressLine coalesces the renderings of a line a task redraws by ending it with "\r" rather than "\n"
+// (progress bars, spinners): at most one is sent per interval, the latest, and the line's final state once
+// a "\n" ends it. A line ended by "\r\n" is sent once, like any other.
+// Each stream of a task has its own, used only by the goroutine reading that stream.
+type progressLine struct {
+	interval time.Duration
+	latest   []byte    // Latest rendering of the current line (empty when it was not redrawn)
+	unsent   bool      // latest has not been sent
+	lastSent time.Time // When a rendering of the current line was last sent
+}
+
+// progressOf returns the progress line of a task's stdout or stderr
+func (o *taskOutput) progressOf(isError bool) *progressLine {
+	if isError {
+		return &o.progress[1]
+	}
+	return &o.progress[0]
+}
+
+// redraw records a rendering of the current line, returning it when it is due to be sent: the first one,
+// then the latest at most once per interval. The returned slice is only valid until the next call.
+func (p *progressLine) redraw(text []byte, now time.Time) ([]byte, bool) {
+	if len(text) == 0 {
+		// Nothing drawn yet, e.g. the "\r" a spinner starts each frame with
+		return nil, false
+	}
+	p.latest = append(p.latest[:0], text...)
+	p.unsent = true
+	if now.Sub(p.lastSent) < p.interval {
+		return nil, false
+	}
+	p.lastSent = now
+	p.unsent = false
+	return p.latest, true
+}
+
+// end finishes the current line at a "\n", returning what to send for it: text, or when text is empty the
+// rendering a "\r" ended just before, unless that was sent already
+func (p *progressLine) end(text []byte) ([]byte, bool) {
+	if len(text) > 0 || len(p.latest) == 0 {
+		p.reset()
+		return text, true
+	}
+	return p.flush()
+}
+
+// flush finishes the current line at the end of the output, returning its latest rendering if it was
+// not sent
+func (p *progressLine) flush() ([]byte, bool) {
+	latest, unsent := p.latest, p.unsent
+	p.reset()
+	return latest, unsent
+}
+
+// reset starts the next line, whose first rendering is sent right away
+func (p *progressLine) reset() {
+	p.latest, p.unsent, p.lastSent = p.latest[:0], false, time.Time{}
+}
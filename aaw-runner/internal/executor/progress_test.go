@@ -0,0 +1,82 @@
+package executor
+
+import (
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestProgressLine_Coalesces verifies a line's renderings are sent at most once per interval, its final state
+// always is, a rendering already sent is not repeated by the "\n" after it, and the next line starts afresh
+func TestProgressLine_Coalesces(t *testing.T) {
+	p := &progressLine{interval: time.Second}
+	start := time.Now()
+	sent := func(line []byte, ok bool) string {
+		if !ok {
+			return "<none>"
+		}
+		return string(line)
+	}
+
+	assert.Equal(t, "<none>", sent(p.redraw(nil, start)))
+	assert.Equal(t, "10%", sent(p.redraw([]byte("10%"), start)))
+	assert.Equal(t, "<none>", sent(p.redraw([]byte("20%"), start.Add(300*time.Millisecond))))
+	assert.Equal(t, "30%", sent(p.redraw([]byte("30%"), start.Add(1100*time.Millisecond))))
+	assert.Equal(t, "<none>", sent(p.redraw([]byte("40%"), start.Add(1200*time.Millisecond))))
+	assert.Equal(t, "40%", sent(p.end(nil)))
+	assert.Equal(t, "", sent(p.end(nil)))
+
+	assert.Equal(t, "crlf", sent(p.redraw([]byte("crlf"), start.Add(3*time.Second))))
+	assert.Equal(t, "<none>", sent(p.end(nil)))
+
+	assert.Equal(t, "50%", sent(p.redraw([]byte("50%"), start.Add(3*time.Second))))
+	assert.Equal(t, "<none>", sent(p.redraw([]byte("55%"), start.Add(3*time.Second))))
+	assert.Equal(t, "done", sent(p.end([]byte("done"))))
+	assert.Equal(t, "<none>", sent(p.flush()))
+
+	assert.Equal(t, "60%", sent(p.redraw([]byte("60%"), start.Add(3*time.Second))))
+	assert.Equal(t, "<none>", sent(p.redraw([]byte("70%"), start.Add(3*time.Second))))
+	assert.Equal(t, "70%", sent(p.flush()))
+}
+
+// progressOutput is a progress bar redrawn to 100%, then finished with a newline, between plain lines
+const progressOutput = "start\r\n\rDownloading 1%\rDownloading 2%\rDownloading 50%\rDownloading 100%\ndone\r\n\r\nspin |\rspin /"
+
+// TestStreamOutput_ProgressBar verifies both streaming modes send a progress bar's first rendering and its
+// final state, rather than every redraw or a single line of all of them
+func TestStreamOutput_ProgressBar(t *testing.T) {
+	want := []string{"start", "Downloading 1%", "Downloading 100%", "done", "", "spin |", "spin /"}
+	te, rec := recordingExecutor()
+	te.progressFlush = time.Hour
+
+	te.streamOutput(te.newTaskOutput(1, TaskOptions{}), strings.NewReader(progressOutput), false)
+	te.flushOutput(1)
+	assert.Equal(t, want, logLines(rec))
+
+	te, rec = recordingExecutor()
+	te.progressFlush = time.Hour
+	te.streamOutputRealtime(te.newTaskOutput(2, TaskOptions{}), iotest.OneByteReader(strings.NewReader(progressOutput)), false)
+	te.flushOutput(2)
+	assert.Equal(t, want, logLines(rec))
+}
+
+// TestProgress_FlushInterval verifies a task redrawing a line slower than the flush interval has every
+// rendering sent, and one redrawing it faster only some, ending with the final state
+func TestProgress_FlushInterval(t *testing.T) {
+	testutil.FakeClaude(t, `for i in 1 2 3; do printf '\rstep %d' $i; sleep 0.2; done; echo; `+
+		`for i in $(seq 1 500); do printf '\rfast %d' $i; done; printf '\rfast done\n'`)
+	te, rec := recordingExecutor()
+	te.progressFlush = 100 * time.Millisecond
+
+	result, output := runPoolTaskOn(t, te, rec, models.ExecuteMessage{TaskID: 3, ScriptContent: "bar"})
+	assert.True(t, result.Success, result.Error)
+	assert.Equal(t, []string{"step 1", "step 2", "step 3"}, linesWithPrefix(output, "step"))
+	fast := linesWithPrefix(output, "fast")
+	assert.Less(t, len(fast), 10)
+	assert.Equal(t, "fast done", fast[len(fast)-1])
+}
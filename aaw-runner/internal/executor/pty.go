@@ -0,0 +1,62 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/berno/aaw-runner/internal/pty"
+)
+
+// newPTYPipes runs cmd on a new pseudo-terminal of the given size, its output read from the
+// controlling end as a single stream: stdout and stderr interleaved as a terminal shows them
+func newPTYPipes(cmd *exec.Cmd, size pty.Size) (*outputPipes, error) {
+	controller, terminal, err := pty.Open(size)
+	if err != nil {
+		return nil, fmt.Errorf("open pseudo-terminal: %w", err)
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = terminal, terminal, terminal
+	// The task leads a session of its own, the terminal as its controlling one (its stdin, fd 0); its
+	// process group is still its PID, so cancel and kill reach everything it starts
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+	return &outputPipes{
+		readers: []outputReader{{file: controller, src: ptyReader{controller}}},
+		writers: []*os.File{terminal},
+	}, nil
+}
+
+// ptyReader reads the controlling end of a pseudo-terminal, ending with io.EOF rather than the EIO
+// Linux returns once every process holding the terminal end has exited
+type ptyReader struct {
+	f *os.File
+}
+
+func (r ptyReader) Read(p []byte) (int, error) {
+	n, err := r.f.Read(p)
+	if errors.Is(err, syscall.EIO) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// newTaskPipes connects cmd's output for the task: to a pseudo-terminal when it runs on one, to a single
+// pipe when its EXECUTE combines its output, else to a pipe for stdout and one for stderr
+func (te *TaskExecutor) newTaskPipes(opts TaskOptions, cmd *exec.Cmd) (*outputPipes, error) {
+	if te.usesPTY(opts) {
+		return newPTYPipes(cmd, te.ptySize)
+	}
+	if opts.combined {
+		return newCombinedPipes(cmd)
+	}
+	return newOutputPipes(cmd)
+}
+
+// usesPTY reports whether a task runs on a pseudo-terminal: when its EXECUTE asks to, or when every
+// task is forced to and it has neither stdinContent, which a terminal would echo into its output, nor
+// interactive stdin
+func (te *TaskExecutor) usesPTY(opts TaskOptions) bool {
+	return opts.pty || te.forcePTY && opts.stdin == "" && !opts.interactive
+}
@@ -0,0 +1,91 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/pty"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// skipWithoutPTY skips tests of pseudo-terminal tasks where the runner cannot open one
+func skipWithoutPTY(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("pseudo-terminals are not supported on " + runtime.GOOS)
+	}
+}
+
+// ptyScript prints whether the task's stdout is a terminal, its window size, and a line on stderr
+const ptyScript = `if [ -t 1 ]; then echo tty; else echo pipe; fi; stty size 2>/dev/null; echo oops >&2`
+
+// TestPTY_RunsOnTerminal verifies a task asking for pty runs on a terminal of the configured size, its
+// stderr read with its stdout
+func TestPTY_RunsOnTerminal(t *testing.T) {
+	skipWithoutPTY(t)
+	script := filepath.Join(t.TempDir(), "tty.sh")
+	assert.NoError(t, os.WriteFile(script, []byte(ptyScript+"\n"), 0o644))
+	te, rec := recordingExecutor()
+	result, output := runPoolTaskOn(t, te, rec, models.ExecuteMessage{TaskID: 1, Script: script, PTY: true})
+	assert.True(t, result.Success, result.Error)
+	assert.Subset(t, output, []string{"tty", "40 120", "oops"})
+	for _, msg := range rec.getLogs() {
+		assert.False(t, msg.IsError, "One stream: %q", msg.Line)
+	}
+
+	testutil.FakeClaude(t, ptyScript)
+	result, output = runPoolTask(t, models.ExecuteMessage{TaskID: 2, ScriptContent: "hello"})
+	assert.True(t, result.Success, result.Error)
+	assert.Contains(t, output, "pipe", "Pipes unless asked for")
+}
+
+// TestPTY_Forced verifies AAW_FORCE_PTY runs every task on a terminal of the configured size, except one
+// with stdinContent
+func TestPTY_Forced(t *testing.T) {
+	skipWithoutPTY(t)
+	testutil.FakeClaude(t, ptyScript)
+	te, rec := recordingExecutor()
+	te.forcePTY, te.ptySize = true, pty.Size{Cols: 80, Rows: 24}
+	result, output := runPoolTaskOn(t, te, rec, models.ExecuteMessage{TaskID: 3, ScriptContent: "hello"})
+	assert.True(t, result.Success, result.Error)
+	assert.Subset(t, output, []string{"tty", "24 80"})
+
+	te, rec = recordingExecutor()
+	te.forcePTY = true
+	result, output = runPoolTaskOn(t, te, rec, models.ExecuteMessage{TaskID: 4, ScriptContent: "hello", StdinContent: "input"})
+	assert.True(t, result.Success, result.Error)
+	assert.Contains(t, output, "pipe")
+}
+
+// TestPTY_Cancel verifies cancelling a task on a terminal ends it and everything it started
+func TestPTY_Cancel(t *testing.T) {
+	skipWithoutPTY(t)
+	te, _ := recordingExecutor()
+	script := filepath.Join(t.TempDir(), "sleep.sh")
+	assert.NoError(t, os.WriteFile(script, []byte("sleep 30 &\nsleep 30\n"), 0o644))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- te.Execute(5, script, TaskOptions{pty: true})
+	}()
+	waitForRegistration(t, te, 5)
+	pgid, ok := te.processGroup(5)
+	assert.True(t, ok)
+
+	assert.NoError(t, te.CancelTask(5))
+	select {
+	case err := <-done:
+		assert.Equal(t, models.ErrorCodeCancelled, ErrorCode(err))
+		assert.Equal(t, 128+int(syscall.SIGTERM), newTaskResult(5, err).ExitCode)
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancelled task did not return")
+	}
+	assert.Eventually(t, func() bool { return len(processGroupMembers(pgid)) == 0 }, 2*time.Second, 20*time.Millisecond,
+		"No process from the task's group may survive")
+}
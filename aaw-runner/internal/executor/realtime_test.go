@@ -0,0 +1,84 @@
+package executor
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// splitReader returns its data in two reads, the first of n bytes
+type splitReader struct {
+	data string
+	n    int
+}
+
+func (r *splitReader) Read(p []byte) (int, error) {
+	if r.data == "" {
+		return 0, io.EOF
+	}
+	n := min(len(r.data), len(p))
+	if r.n > 0 {
+		n = min(n, r.n)
+		r.n = 0
+	}
+	copy(p, r.data[:n])
+	r.data = r.data[n:]
+	return n, nil
+}
+
+// realtimeLines streams reader through streamOutputRealtime and returns the lines it sent
+func realtimeLines(t *testing.T, reader io.Reader) []string {
+	t.Helper()
+	te, rec := recordingExecutor()
+	te.streamOutputRealtime(te.newTaskOutput(1, TaskOptions{}), reader, false)
+	te.flushOutput(1)
+	return logLines(rec)
+}
+
+// TestStreamOutputRealtime_SplitCharacters verifies multi-byte characters arrive intact whatever read they
+// are split across, and CRLF endings are dropped
+func TestStreamOutputRealtime_SplitCharacters(t *testing.T) {
+	text := "안녕하세요 👋 café\r\n두 번째 줄 ✅\n끝"
+	want := []string{"안녕하세요 👋 café", "두 번째 줄 ✅", "끝"}
+
+	for n := 1; n < len(text); n++ {
+		assert.Equal(t, want, realtimeLines(t, &splitReader{data: text, n: n}), "split after byte %d", n)
+	}
+	assert.Equal(t, want, realtimeLines(t, iotest.OneByteReader(strings.NewReader(text))))
+}
+
+// TestStreamOutputRealtime_InvalidBytes verifies bytes that are not UTF-8 are sent as U+FFFD, including a
+// character cut short by the end of the output
+func TestStreamOutputRealtime_InvalidBytes(t *testing.T) {
+	lines := realtimeLines(t, iotest.OneByteReader(strings.NewReader("bad \xff\xfe end\n\xed\xa0\x80\ntail \xe2\x82")))
+
+	assert.Equal(t, []string{"bad �� end", "���", "tail ��"}, lines)
+	for _, line := range lines {
+		assert.True(t, utf8.ValidString(line), line)
+	}
+}
+
+// TestStreamOutput_SplitsBetweenCharacters verifies an oversize line is split between characters, never
+// inside one
+func TestStreamOutput_SplitsBetweenCharacters(t *testing.T) {
+	orig := maxLineChunk
+	maxLineChunk = 16
+	defer func() { maxLineChunk = orig }()
+	te, rec := recordingExecutor()
+	line := strings.Repeat("가", 20)
+
+	te.streamOutput(te.newTaskOutput(2, TaskOptions{}), strings.NewReader(line+"\nnext\n"), false)
+	te.flushOutput(2)
+
+	lines := logLines(rec)
+	assert.Equal(t, "next", lines[len(lines)-1])
+	for _, chunk := range lines {
+		assert.True(t, utf8.ValidString(chunk), chunk)
+		assert.LessOrEqual(t, len(chunk), maxLineChunk+utf8.UTFMax-1)
+	}
+	assert.Equal(t, line, strings.Join(lines[:len(lines)-1], ""))
+}
@@ -0,0 +1,208 @@
+package executor
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/remote"
+	"golang.org/x/crypto/ssh"
+)
+
+// remoteSignals names the signals a remote task can be sent, for kill(1) on the host
+var remoteSignals = map[syscall.Signal]string{
+	syscall.SIGTERM: "TERM",
+	syscall.SIGKILL: "KILL",
+	syscall.SIGSTOP: "STOP",
+	syscall.SIGCONT: "CONT",
+}
+
+// remoteProcess is a task running on an SSH host
+type remoteProcess struct {
+	hosts   *remote.Hosts
+	host    string
+	session *ssh.Session
+	pgid    atomic.Int64 // Remote process group, 0 until the task's shell has reported it
+	closed  atomic.Bool  // Set when the runner closed the session to cancel the task
+}
+
+// close abandons the session, as ForceKillTask does before signalling the process group
+func (p *remoteProcess) close() {
+	p.closed.Store(true)
+	p.session.Close()
+}
+
+// signal sends sig to the remote process group with kill(1) over a second session
+// Before the process group is known the signal goes to the session, which reaches the shell only.
+func (p *remoteProcess) signal(sig syscall.Signal) error {
+	name, ok := remoteSignals[sig]
+	if !ok {
+		return fmt.Errorf("signal %v cannot be sent to remote tasks", sig)
+	}
+	pgid := p.pgid.Load()
+	if pgid == 0 {
+		return p.session.Signal(ssh.Signal(name))
+	}
+	err := p.hosts.Run(p.host, fmt.Sprintf("kill -s %s -- -%d", name, pgid))
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		return syscall.ESRCH // kill(1) fails once the group is gone
+	}
+	return err
+}
+
+// readPgid consumes the process group line the task's shell prints first (see remote.Command)
+// Output that does not start with it is passed through untouched.
+func (p *remoteProcess) readPgid(stdout io.Reader) io.Reader {
+	reader := bufio.NewReader(stdout)
+	line, err := reader.ReadString('\n')
+	pgid, parseErr := strconv.ParseInt(strings.TrimSpace(strings.TrimPrefix(line, remote.PgidPrefix)), 10, 64)
+	if err != nil || !strings.HasPrefix(line, remote.PgidPrefix) || parseErr != nil {
+		return io.MultiReader(strings.NewReader(line), reader)
+	}
+	p.pgid.Store(pgid)
+	return reader
+}
+
+// ExecuteRemote runs a Claude prompt on an SSH host from the hosts file, streaming its output like a local task
+// The pooled connection to the host is reused; losing it mid-task fails the task with
+// ErrorCodeSSHConnectionLost, since its remote processes may well still be running.
+func (te *TaskExecutor) ExecuteRemote(taskID int64, hostName string, scriptContent string, skipPermissions bool, opts TaskOptions) error {
+	defer te.flushOutput(taskID)
+
+	modelArgs := opts.modelArgs()
+	formatArgs := opts.outputFormatArgs()
+	te.logCallback(models.NewLogMessage(taskID, fmt.Sprintf("Starting remote execution on %s (skip permissions: %v)", hostName, skipPermissions)+describeArgs(opts.args)+describeModel(modelArgs), false))
+
+	host, ok := te.hosts.Lookup(hostName)
+	if !ok {
+		errMsg := fmt.Sprintf("Unknown SSH host %q", hostName)
+		te.logCallback(models.NewLogMessage(taskID, errMsg, true))
+		return newTaskError(models.ErrorCodeStartFailed, "%s", errMsg)
+	}
+	host.Env = opts.remoteEnv(host.Env)
+	argv := []string{host.ClaudePath}
+	if skipPermissions {
+		argv = append(argv, "--dangerously-skip-permissions")
+	}
+	argv = append(argv, modelArgs...)
+	argv = append(argv, formatArgs...)
+	argv = append(argv, scriptContent)
+	argv = append(argv, opts.args...)
+
+	span := te.startSpan(taskID, spanStart)
+	var stdout, stderr io.Reader
+	session, client, err := te.hosts.Session(hostName)
+	if err == nil {
+		session.Stdin = opts.stdinReader()
+		command := remote.Command(host, argv)
+		if opts.combined {
+			// The shell sends the task's stderr down the channel of its stdout, in order
+			command += " 2>&1"
+		}
+		stdout, stderr, err = startRemote(session, command)
+		if err != nil {
+			session.Close()
+		}
+	}
+	endSpan(span, err)
+	if err != nil {
+		te.logCallback(models.NewLogMessage(taskID, fmt.Sprintf("Failed to start remote command: %v", err), true))
+		var keyErr *remote.HostKeyError
+		if errors.As(err, &keyErr) {
+			return withCode(models.ErrorCodeSSHHostKey, err)
+		}
+		return withCode(models.ErrorCodeSSHConnect, err)
+	}
+	defer session.Close()
+
+	process := &remoteProcess{hosts: te.hosts, host: hostName, session: session}
+	runningTask := &RunningTask{
+		TaskID:    taskID,
+		Cancel:    process.close,
+		StartedAt: time.Now(),
+		Host:      hostName,
+		signal:    process.signal,
+	}
+	te.registerTask(runningTask)
+	defer te.unregisterTask(taskID)
+
+	output := te.newTaskOutput(taskID, opts)
+	output.jsonEvents = formatArgs != nil
+	stopWatch := te.watchStall(output, te.stallPolicyOf(opts))
+	span = te.startSpan(taskID, spanStream)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		te.streamOutput(output, process.readPgid(stdout), false)
+	}()
+	go func() {
+		defer wg.Done()
+		te.streamOutput(output, stderr, true)
+	}()
+
+	err = session.Wait()
+	if !drainStreams(&wg, taskID) {
+		session.Close()
+	}
+	wg.Wait()
+	stopWatch()
+	te.recordStderrTail(output)
+	te.flushSpinners(output)
+	te.recordOutputSize(output)
+	span.End()
+	if err == nil {
+		te.logCallback(models.NewLogMessage(taskID, "Remote execution completed", false))
+		return nil
+	}
+	if runningTask.cancelRequested.Load() || process.closed.Load() {
+		te.logCallback(models.NewLogMessage(taskID, "Task was cancelled", false))
+		return cancelled(err)
+	}
+
+	var exitErr *ssh.ExitError
+	if !errors.As(err, &exitErr) {
+		// No exit status: the connection (or the session) went away under the task
+		te.hosts.Forget(hostName, client)
+		errMsg := fmt.Sprintf("Lost the connection to %s: %v", hostName, err)
+		te.logCallback(models.NewLogMessage(taskID, errMsg, true))
+		return newTaskError(models.ErrorCodeSSHConnectionLost, "%s", errMsg)
+	}
+	err = te.classifyOutput(output, err)
+	te.logCallback(models.NewLogMessage(taskID, fmt.Sprintf("Command failed: %v", err), true))
+	return withCode(models.ErrorCodeExitNonzero, err)
+}
+
+// startRemote starts command in the session, returning its output streams
+func startRemote(session *ssh.Session, command string) (stdout, stderr io.Reader, err error) {
+	if stdout, err = session.StdoutPipe(); err != nil {
+		return nil, nil, err
+	}
+	if stderr, err = session.StderrPipe(); err != nil {
+		return nil, nil, err
+	}
+	return stdout, stderr, session.Start(command)
+}
+
+// remoteExitCode is the exit status of a remote command, 128+N when signal N killed it (-1 for a signal
+// not in remoteSignals)
+func remoteExitCode(err *ssh.ExitError) int {
+	if err.Signal() == "" {
+		return err.ExitStatus()
+	}
+	for sig, name := range remoteSignals {
+		if name == err.Signal() {
+			return 128 + int(sig)
+		}
+	}
+	return -1
+}
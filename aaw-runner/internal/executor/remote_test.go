@@ -0,0 +1,194 @@
+package executor
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/config"
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/remote/sshtest"
+	"github.com/stretchr/testify/assert"
+)
+
+// remoteExecutor returns a recording executor whose hosts file has "box" on addr, running body as claude in workdir
+func remoteExecutor(t *testing.T, srv *sshtest.Server, addr, knownHosts, body string) (te *TaskExecutor, rec *messageRecorder, workdir string) {
+	t.Helper()
+	workdir = t.TempDir()
+	claude := filepath.Join(workdir, "claude")
+	assert.NoError(t, os.WriteFile(claude, []byte("#!/bin/sh\n"+body+"\n"), 0o755))
+	hostsFile := filepath.Join(workdir, "hosts.yaml")
+	assert.NoError(t, os.WriteFile(hostsFile, []byte(fmt.Sprintf(`knownHosts: %s
+hosts:
+  box:
+    address: %s
+    user: aaw
+    identityFile: %s
+    workdir: %s
+    claudePath: %s
+    env:
+      GREETING: hello
+`, knownHosts, addr, srv.IdentityFile, workdir, claude)), 0o600))
+
+	cfg := config.Default()
+	cfg.SSHHostsFile = hostsFile
+	rec = &messageRecorder{}
+	te = NewTaskExecutorWithConfig(cfg, rec.onLog, rec.onStatus)
+	t.Cleanup(te.hosts.Close)
+	return te, rec, workdir
+}
+
+// runRemote runs a task on "box" through a pool and returns its result
+func runRemote(te *TaskExecutor, taskID int64) TaskResult {
+	var completed TaskResult
+	pool := NewExecutorPool(te, 1, nil, func(result TaskResult) { completed = result })
+	pool.Submit(models.ExecuteMessage{Type: models.TypeExecute, TaskID: taskID, ScriptContent: "do it", Host: "box"})
+	pool.executeTask(0, <-pool.taskQueue)
+	return completed
+}
+
+// startRemoteTask runs a task on "box" in the background, returning its result once it finishes
+func startRemoteTask(t *testing.T, te *TaskExecutor, rec *messageRecorder, taskID int64) <-chan TaskResult {
+	t.Helper()
+	done := make(chan TaskResult, 1)
+	go func() { done <- runRemote(te, taskID) }()
+	assert.Eventually(t, func() bool { return strings.Contains(strings.Join(lines(rec), "\n"), "started") },
+		5*time.Second, 10*time.Millisecond)
+	return done
+}
+
+// lines returns the text of the recorded LOG lines
+func lines(rec *messageRecorder) []string {
+	var out []string
+	for _, msg := range rec.getLogs() {
+		out = append(out, msg.Line)
+	}
+	return out
+}
+
+// TestExecuteRemote_StreamsOutput verifies a remote task gets its workdir, environment and prompt, and streams both outputs
+func TestExecuteRemote_StreamsOutput(t *testing.T) {
+	srv := sshtest.NewServer(t)
+	te, rec, workdir := remoteExecutor(t, srv, srv.Addr, srv.KnownHostsFile, `echo "$GREETING from $(pwd): $1"; echo warning >&2`)
+
+	result := runRemote(te, 1)
+	assert.True(t, result.Success, result.Error)
+	assert.Equal(t, 0, result.ExitCode)
+	out := lines(rec)
+	assert.Equal(t, "Starting remote execution on box (skip permissions: false)", out[0])
+	assert.Contains(t, out, "hello from "+workdir+": do it")
+	assert.Contains(t, out, "warning")
+	assert.NotContains(t, strings.Join(out, "\n"), "AAW_PGID", "The process group line is not task output")
+	assert.Equal(t, "Remote execution completed", out[len(out)-1])
+}
+
+// TestExecuteRemote_ExitStatus verifies a failing remote command reports its exit status
+func TestExecuteRemote_ExitStatus(t *testing.T) {
+	srv := sshtest.NewServer(t)
+	te, _, _ := remoteExecutor(t, srv, srv.Addr, srv.KnownHostsFile, "exit 3")
+
+	result := runRemote(te, 2)
+	assert.False(t, result.Success)
+	assert.Equal(t, models.ErrorCodeExitNonzero, result.ErrorCode)
+	assert.Equal(t, 3, result.ExitCode)
+	assert.Equal(t, int64(1), srv.Connections.Load())
+}
+
+// TestExecuteRemote_CombineOutput verifies a remote task with combineOutput has its stderr sent down its
+// stdout, in the order it was printed
+func TestExecuteRemote_CombineOutput(t *testing.T) {
+	srv := sshtest.NewServer(t)
+	te, rec, _ := remoteExecutor(t, srv, srv.Addr, srv.KnownHostsFile, alternatingScript)
+
+	var result TaskResult
+	pool := NewExecutorPool(te, 1, nil, func(r TaskResult) { result = r })
+	pool.Submit(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 7, ScriptContent: "do it", Host: "box", CombineOutput: true})
+	pool.executeTask(0, <-pool.taskQueue)
+	assert.True(t, result.Success, result.Error)
+
+	var output []string
+	for _, msg := range rec.getLogs() {
+		if strings.HasPrefix(msg.Line, "out ") || strings.HasPrefix(msg.Line, "err ") {
+			assert.False(t, msg.IsError, msg.Line)
+			output = append(output, msg.Line)
+		}
+	}
+	assert.Equal(t, []string{"out 1", "err 1", "out 2", "err 2"}, output[:4])
+	assert.Len(t, output, 100)
+}
+
+// TestExecuteRemote_DeliversBurstBeforeReturn verifies a remote task's output printed right before it exits
+// has all been handed to the log callback by the time it completes
+func TestExecuteRemote_DeliversBurstBeforeReturn(t *testing.T) {
+	srv := sshtest.NewServer(t)
+	te, rec, _ := remoteExecutor(t, srv, srv.Addr, srv.KnownHostsFile, burstScript)
+
+	result := runRemote(te, 8)
+	assert.True(t, result.Success, result.Error)
+	assertBurstDelivered(t, rec)
+}
+
+// TestExecuteRemote_Cancel verifies cancelling signals the whole remote process group
+func TestExecuteRemote_Cancel(t *testing.T) {
+	srv := sshtest.NewServer(t)
+	te, rec, _ := remoteExecutor(t, srv, srv.Addr, srv.KnownHostsFile, `sleep 30 & echo "started $$"; wait`)
+	done := startRemoteTask(t, te, rec, 3)
+	var pgid int
+	for _, line := range lines(rec) {
+		fmt.Sscanf(line, "started %d", &pgid)
+	}
+	assert.NotZero(t, pgid)
+
+	assert.NoError(t, te.CancelTask(3))
+	select {
+	case result := <-done:
+		assert.Equal(t, models.ErrorCodeCancelled, result.ErrorCode)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Task did not finish after cancel")
+	}
+	assert.Eventually(t, func() bool { return len(processGroupMembers(pgid)) == 0 }, 2*time.Second, 20*time.Millisecond,
+		"The background sleep is in the task's process group too")
+}
+
+// TestExecuteRemote_ConnectionLost verifies a connection dropped mid-task fails it distinctly, and the next task reconnects
+func TestExecuteRemote_ConnectionLost(t *testing.T) {
+	srv := sshtest.NewServer(t)
+	te, rec, _ := remoteExecutor(t, srv, srv.Addr, srv.KnownHostsFile, `echo started; sleep 2`)
+	done := startRemoteTask(t, te, rec, 4)
+
+	srv.DropConnections()
+	select {
+	case result := <-done:
+		assert.Equal(t, models.ErrorCodeSSHConnectionLost, result.ErrorCode)
+		assert.Equal(t, -1, result.ExitCode)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Task did not notice the lost connection")
+	}
+
+	runRemote(te, 5)
+	assert.Equal(t, int64(2), srv.Connections.Load(), "A new connection replaces the lost one")
+}
+
+// TestExecuteRemote_ConnectFailures verifies unreachable hosts, untrusted host keys and unknown hosts have their own codes
+func TestExecuteRemote_ConnectFailures(t *testing.T) {
+	srv := sshtest.NewServer(t)
+	other := sshtest.NewServer(t)
+
+	te, _, _ := remoteExecutor(t, srv, srv.Addr, other.KnownHostsFile, "true")
+	assert.Equal(t, models.ErrorCodeSSHHostKey, runRemote(te, 6).ErrorCode)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	closed := listener.Addr().String()
+	listener.Close()
+	te, _, _ = remoteExecutor(t, srv, closed, srv.KnownHostsFile, "true")
+	assert.Equal(t, models.ErrorCodeSSHConnect, runRemote(te, 7).ErrorCode)
+
+	te, rec := recordingExecutor()
+	assert.Equal(t, models.ErrorCodeStartFailed, runRemote(te, 8).ErrorCode)
+	assert.Contains(t, lines(rec), `Unknown SSH host "box"`)
+}
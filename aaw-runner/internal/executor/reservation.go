@@ -0,0 +1,161 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/models"
+)
+
+// Reserve refusals
+var (
+	ErrReserveDraining     = errors.New("runner is draining")
+	ErrReserveInsufficient = errors.New("not enough free slots")
+)
+
+// reservation holds slots for a task the backend is about to send (RESERVE_SLOT)
+type reservation struct {
+	weight    int // Slots held
+	expiresAt time.Time
+	timer     *time.Timer
+}
+
+// SetReservationExpiryHandler registers fn to hear about reservations dropped unused, with a models.Expired* reason
+// Must be called before Start.
+func (p *ExecutorPool) SetReservationExpiryHandler(fn func(reservationID, reason string)) {
+	p.onReservationExpired = fn
+}
+
+// Reserve holds weight slots for reservationID until ttl passes, an EXECUTE naming it is submitted or
+// it is released; reserving an ID already held replaces it. Reserved slots are not advertised as
+// available, and only a task naming the reservation can use them.
+func (p *ExecutorPool) Reserve(reservationID string, weight int, ttl time.Duration) (time.Time, error) {
+	if weight <= 0 {
+		weight = 1
+	}
+	if p.draining.Load() {
+		return time.Time{}, ErrReserveDraining
+	}
+
+	p.reserveMu.Lock()
+	free := p.stateManager.GetAvailableSlots() - p.reservedLocked()
+	old := p.reservations[reservationID]
+	if old != nil {
+		free += old.weight
+	}
+	if weight > free {
+		p.reserveMu.Unlock()
+		return time.Time{}, fmt.Errorf("%w: %d requested, %d free", ErrReserveInsufficient, weight, max(free, 0))
+	}
+	if old != nil {
+		old.timer.Stop()
+	}
+	r := &reservation{weight: weight, expiresAt: time.Now().Add(ttl)}
+	r.timer = time.AfterFunc(ttl, func() { p.expireReservation(reservationID, r) })
+	p.reservations[reservationID] = r
+	p.reserveMu.Unlock()
+
+	log.Printf("[POOL] Reserved %d slots for %s until %s", weight, reservationID, r.expiresAt.Format(time.RFC3339))
+	p.reportCapacity()
+	return r.expiresAt, nil
+}
+
+// Release gives a reservation's slots back, reporting whether it was held
+func (p *ExecutorPool) Release(reservationID string) bool {
+	if !p.takeReservation(reservationID) {
+		return false
+	}
+	log.Printf("[POOL] Released reservation %s", reservationID)
+	p.reportCapacity()
+	return true
+}
+
+// Reserved returns the number of slots held by reservations
+func (p *ExecutorPool) Reserved() int {
+	p.reserveMu.Lock()
+	defer p.reserveMu.Unlock()
+	return p.reservedLocked()
+}
+
+// Reservations lists the reservations held, by ID
+func (p *ExecutorPool) Reservations() []models.Reservation {
+	p.reserveMu.Lock()
+	defer p.reserveMu.Unlock()
+	list := make([]models.Reservation, 0, len(p.reservations))
+	for id, r := range p.reservations {
+		list = append(list, models.Reservation{ReservationID: id, Weight: r.weight, ExpiresAt: r.expiresAt.Format(time.RFC3339)})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ReservationID < list[j].ReservationID })
+	return list
+}
+
+// reservedLocked sums the reservations' weights; callers hold reserveMu
+func (p *ExecutorPool) reservedLocked() int {
+	n := 0
+	for _, r := range p.reservations {
+		n += r.weight
+	}
+	return n
+}
+
+// freeSlots returns the slots a task without a reservation can have
+func (p *ExecutorPool) freeSlots() int {
+	return p.stateManager.GetAvailableSlots() - p.Reserved()
+}
+
+// takeReservation removes a reservation, reporting whether it was held
+// A task submitted with it takes one of its slots; any others are freed.
+func (p *ExecutorPool) takeReservation(reservationID string) bool {
+	p.reserveMu.Lock()
+	defer p.reserveMu.Unlock()
+	r, ok := p.reservations[reservationID]
+	if !ok {
+		return false
+	}
+	r.timer.Stop()
+	delete(p.reservations, reservationID)
+	return true
+}
+
+// expireReservation drops r at its TTL, unless it was used, released or replaced in the meantime
+func (p *ExecutorPool) expireReservation(reservationID string, r *reservation) {
+	p.reserveMu.Lock()
+	if p.reservations[reservationID] != r {
+		p.reserveMu.Unlock()
+		return
+	}
+	delete(p.reservations, reservationID)
+	p.reserveMu.Unlock()
+
+	log.Printf("[POOL] Reservation %s expired unused", reservationID)
+	p.reservationExpired(reservationID, models.ExpiredTTL)
+	p.reportCapacity()
+}
+
+// dropReservations releases every reservation, for reason (a models.Expired* reason)
+func (p *ExecutorPool) dropReservations(reason string) {
+	p.reserveMu.Lock()
+	ids := make([]string, 0, len(p.reservations))
+	for id, r := range p.reservations {
+		r.timer.Stop()
+		ids = append(ids, id)
+	}
+	p.reservations = make(map[string]*reservation)
+	p.reserveMu.Unlock()
+
+	sort.Strings(ids)
+	for _, id := range ids {
+		log.Printf("[POOL] Dropping reservation %s (%s)", id, reason)
+		p.reservationExpired(id, reason)
+	}
+}
+
+// reservationExpired tells the registered handler about a reservation dropped unused
+func (p *ExecutorPool) reservationExpired(reservationID, reason string) {
+	if p.onReservationExpired != nil {
+		p.onReservationExpired(reservationID, reason)
+	}
+}
@@ -0,0 +1,106 @@
+package executor
+
+import (
+	"maps"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// expiries records the reservations a pool drops unused
+type expiries struct {
+	mu      sync.Mutex
+	dropped map[string]string // Reason by reservation ID
+}
+
+func recordExpiries(pool *ExecutorPool) *expiries {
+	e := &expiries{dropped: make(map[string]string)}
+	pool.SetReservationExpiryHandler(func(reservationID, reason string) {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		e.dropped[reservationID] = reason
+	})
+	return e
+}
+
+func (e *expiries) get() map[string]string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return maps.Clone(e.dropped)
+}
+
+// TestReserve_Execute verifies reserved slots are withheld from other tasks and taken by the EXECUTE naming them
+func TestReserve_Execute(t *testing.T) {
+	pool := newTestPool(3)
+	assert.True(t, pool.Submit(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 1, ScriptContent: "hello"}))
+	_, err := pool.Reserve("deploy", 2, time.Minute)
+	assert.NoError(t, err)
+	_, _, available := pool.GetCapacity()
+	assert.Zero(t, available)
+	assert.Equal(t, 2, pool.Reserved())
+
+	_, err = pool.Reserve("another", 1, time.Minute)
+	assert.ErrorIs(t, err, ErrReserveInsufficient)
+	assert.False(t, pool.CanAccept())
+	assert.False(t, pool.Submit(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 2, ScriptContent: "hello"}))
+	assert.False(t, pool.Submit(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 2, ScriptContent: "hello", ReservationID: "unknown"}))
+
+	assert.True(t, pool.Submit(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 3, ScriptContent: "hello", ReservationID: "deploy"}))
+	_, running, available := pool.GetCapacity()
+	assert.Equal(t, 2, running)
+	assert.Equal(t, 1, available, "The reservation's other slot is freed")
+	assert.Zero(t, pool.Reserved())
+	assert.Empty(t, pool.Reservations())
+	assert.False(t, pool.Release("deploy"), "A reservation is used once")
+}
+
+// TestReserve_Expire verifies an unused reservation is dropped at its TTL, and a replaced or released one is not
+func TestReserve_Expire(t *testing.T) {
+	pool := newTestPool(2)
+	dropped := recordExpiries(pool)
+
+	_, err := pool.Reserve("deploy", 1, 20*time.Millisecond)
+	assert.NoError(t, err)
+	expiresAt, err := pool.Reserve("deploy", 1, time.Minute) // Extended
+	assert.NoError(t, err)
+	_, err = pool.Reserve("build", 1, 20*time.Millisecond)
+	assert.NoError(t, err)
+	if held := pool.Reservations(); assert.Len(t, held, 2) {
+		assert.Equal(t, "build", held[0].ReservationID)
+		assert.Equal(t, models.Reservation{ReservationID: "deploy", Weight: 1, ExpiresAt: expiresAt.Format(time.RFC3339)}, held[1])
+	}
+
+	assert.Eventually(t, func() bool { return len(dropped.get()) > 0 }, time.Second, 5*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, map[string]string{"build": models.ExpiredTTL}, dropped.get())
+	_, _, available := pool.GetCapacity()
+	assert.Equal(t, 1, available)
+
+	assert.True(t, pool.Release("deploy"))
+	_, _, available = pool.GetCapacity()
+	assert.Equal(t, 2, available)
+}
+
+// TestReserve_Drain verifies draining drops reservations and refuses new ones until undrained
+func TestReserve_Drain(t *testing.T) {
+	pool := newTestPool(2)
+	dropped := recordExpiries(pool)
+	_, err := pool.Reserve("deploy", 1, time.Minute)
+	assert.NoError(t, err)
+
+	pool.Drain()
+	assert.Equal(t, map[string]string{"deploy": models.ExpiredDraining}, dropped.get())
+	assert.Zero(t, pool.Reserved())
+	_, err = pool.Reserve("deploy", 1, time.Minute)
+	assert.ErrorIs(t, err, ErrReserveDraining)
+	assert.False(t, pool.Submit(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 1, ScriptContent: "hello", ReservationID: "deploy"}))
+
+	pool.Undrain()
+	_, err = pool.Reserve("deploy", 1, time.Minute)
+	assert.NoError(t, err)
+	_, _, available := pool.GetCapacity()
+	assert.Equal(t, 1, available)
+}
@@ -0,0 +1,123 @@
+package executor
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// sessionIDPattern finds the session ID claude reports: the session_id field of its JSON output
+// formats, or a "Session ID: <id>" line
+var sessionIDPattern = regexp.MustCompile(`(?i)"?session[_ ]id"?\s*[:=]\s*"?([0-9a-z][0-9a-z_-]{7,})`)
+
+// claudeSession is the claude conversation that PERSIST tasks with the same sessionKey continue
+type claudeSession struct {
+	id       string    // Last reported by claude; empty when a successful run did not print one
+	lastUsed time.Time // When its latest task finished; the session is forgotten ttl after that
+}
+
+// sessionRegistry remembers the claude session of each sessionKey, for PERSIST tasks
+// The empty key is the runner's own session, used by PERSIST tasks that name none.
+type sessionRegistry struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	now      func() time.Time // Faked in tests
+	sessions map[string]*claudeSession
+}
+
+// newSessionRegistry returns a registry forgetting sessions unused for ttl
+func newSessionRegistry(ttl time.Duration) *sessionRegistry {
+	return &sessionRegistry{ttl: ttl, now: time.Now, sessions: make(map[string]*claudeSession)}
+}
+
+// resumeArgs returns the claude flags continuing key's session: --resume with its ID, --continue when
+// claude did not report one, and none (a new session) when key has no live session
+func (r *sessionRegistry) resumeArgs(key string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.sessions[key]
+	if !ok {
+		return nil
+	}
+	if r.now().Sub(s.lastUsed) >= r.ttl {
+		delete(r.sessions, key)
+		return nil
+	}
+	if s.id == "" {
+		return []string{"--continue"}
+	}
+	return []string{"--resume", s.id}
+}
+
+// record notes that a task of key's session finished, reporting session ID id (empty when it printed
+// none). A failed task keeps the session it continued, and one that reported no ID starts none.
+func (r *sessionRegistry) record(key, id string, succeeded bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	for k, s := range r.sessions {
+		if now.Sub(s.lastUsed) >= r.ttl {
+			delete(r.sessions, k)
+		}
+	}
+	s, ok := r.sessions[key]
+	if !ok {
+		if id == "" && !succeeded {
+			return
+		}
+		s = &claudeSession{}
+		r.sessions[key] = s
+	}
+	if id != "" {
+		s.id = id
+	}
+	s.lastUsed = now
+}
+
+// noteSessionID records the session ID in a line of a PERSIST task's output, if it has one (the last
+// one reported wins)
+func (o *taskOutput) noteSessionID(raw []byte) {
+	m := sessionIDPattern.FindSubmatch(raw)
+	if m == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.sessionID = string(m[1])
+}
+
+// reportedSessionID returns the last session ID found in the task's output
+func (o *taskOutput) reportedSessionID() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.sessionID
+}
+
+// recordSession keeps the session a PERSIST task ran in for the next task with its key
+func (te *TaskExecutor) recordSession(key string, output *taskOutput, succeeded bool) {
+	id := output.reportedSessionID()
+	te.sessions.record(key, id, succeeded)
+	if id != "" {
+		log.Printf("[Executor] Task %d ran in claude session %s (key %q)", output.taskID, id, key)
+	}
+}
+
+// describeSession is the start LOG line's note of the claude session a PERSIST task runs in, given the
+// flags resuming it
+func describeSession(key string, resume []string) string {
+	var note string
+	switch {
+	case len(resume) == 0:
+		note = ", starting a new claude session"
+	case len(resume) == 1:
+		note = ", continuing the latest claude session"
+	default:
+		note = ", resuming claude session " + resume[1]
+	}
+	if key != "" {
+		note += fmt.Sprintf(" (key %q)", key)
+	}
+	return note
+}
@@ -0,0 +1,109 @@
+package executor
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSessionClaude installs a fake claude that prints the session ID it runs in: the one given to
+// --resume, or a new numbered one, and fails when its prompt contains "fail"
+func fakeSessionClaude(t *testing.T) {
+	counter := filepath.Join(t.TempDir(), "sessions")
+	testutil.FakeClaude(t, fmt.Sprintf(`case "$1" in
+--resume) id=$2; echo "resumed $id" ;;
+--continue) echo continued; exit 0 ;;
+*) n=$(( $(cat %[1]s 2>/dev/null || echo 0) + 1 )); echo $n > %[1]s; id=session-000$n; echo "new $id" ;;
+esac
+echo "{\"type\":\"result\",\"session_id\":\"$id\"}"
+case "$*" in *fail*) exit 1 ;; esac`, counter))
+}
+
+// TestSession_PersistResumes verifies PERSIST tasks resume the session claude reported for their key,
+// while NEW tasks and other keys start their own
+func TestSession_PersistResumes(t *testing.T) {
+	fakeSessionClaude(t)
+	te, rec := recordingExecutor()
+	run := func(taskID int64, prompt, mode, key string) []string {
+		before := len(logLines(rec))
+		result, output := runPoolTaskOn(t, te, rec, models.ExecuteMessage{TaskID: taskID, ScriptContent: prompt, SessionMode: mode, SessionKey: key})
+		assert.True(t, result.Success, result.Error)
+		return output[before:]
+	}
+
+	assert.Contains(t, run(1, "plan", models.SessionModePersist, "review"), "new session-0001")
+	assert.Contains(t, run(2, "apply", models.SessionModePersist, "review"), "resumed session-0001")
+	assert.Contains(t, run(3, "plan", models.SessionModePersist, "docs"), "new session-0002")
+	assert.Contains(t, run(4, "plan", models.SessionModeNew, ""), "new session-0003")
+	assert.Contains(t, run(5, "plan", "", ""), "new session-0004")
+	output := run(6, "check", models.SessionModePersist, "review")
+	assert.Contains(t, output, "resumed session-0001")
+	assert.Contains(t, output[0], `resuming claude session session-0001 (key "review")`)
+
+	assert.Contains(t, run(7, "plan", models.SessionModePersist, ""), "new session-0005")
+	assert.Contains(t, run(8, "apply", models.SessionModePersist, ""), "resumed session-0005")
+}
+
+// TestSession_SurvivesFailure verifies a failed task leaves its key's session for the next task, and a
+// first task that failed without reporting a session starts none
+func TestSession_SurvivesFailure(t *testing.T) {
+	fakeSessionClaude(t)
+	te, rec := recordingExecutor()
+
+	result, _ := runPoolTaskOn(t, te, rec, models.ExecuteMessage{TaskID: 1, ScriptContent: "plan", SessionMode: models.SessionModePersist, SessionKey: "review"})
+	assert.True(t, result.Success, result.Error)
+	result, output := runPoolTaskOn(t, te, rec, models.ExecuteMessage{TaskID: 2, ScriptContent: "fail now", SessionMode: models.SessionModePersist, SessionKey: "review"})
+	assert.False(t, result.Success)
+	assert.Contains(t, output, "resumed session-0001")
+
+	before := len(output)
+	_, output = runPoolTaskOn(t, te, rec, models.ExecuteMessage{TaskID: 3, ScriptContent: "retry", SessionMode: models.SessionModePersist, SessionKey: "review"})
+	assert.Contains(t, output[before:], "resumed session-0001")
+
+	testutil.FakeClaude(t, "exit 1")
+	runPoolTaskOn(t, te, rec, models.ExecuteMessage{TaskID: 4, ScriptContent: "plan", SessionMode: models.SessionModePersist, SessionKey: "fresh"})
+	assert.Nil(t, te.sessions.resumeArgs("fresh"))
+}
+
+// TestSessionRegistry_Expiry verifies a session is forgotten once unused for the TTL, and that a
+// successful run without a session ID is continued with --continue
+func TestSessionRegistry_Expiry(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := newSessionRegistry(time.Hour)
+	r.now = func() time.Time { return now }
+
+	assert.Nil(t, r.resumeArgs("review"))
+	r.record("review", "abc12345", true)
+	now = now.Add(59 * time.Minute)
+	assert.Equal(t, []string{"--resume", "abc12345"}, r.resumeArgs("review"))
+	r.record("review", "", false)
+	now = now.Add(59 * time.Minute)
+	assert.Equal(t, []string{"--resume", "abc12345"}, r.resumeArgs("review"), "Each task keeps it alive")
+
+	now = now.Add(time.Hour)
+	assert.Nil(t, r.resumeArgs("review"))
+
+	r.record("", "", true)
+	assert.Equal(t, []string{"--continue"}, r.resumeArgs(""))
+	now = now.Add(2 * time.Hour)
+	r.record("other", "def67890", true)
+	assert.Len(t, r.sessions, 1, "Expired sessions are dropped as others are recorded")
+}
+
+// TestSessionIDPattern verifies the session ID is found in claude's JSON output and its plain-text report
+func TestSessionIDPattern(t *testing.T) {
+	for line, want := range map[string]string{
+		`{"type":"system","subtype":"init","session_id":"9f0c2a6e-1b7d-4c1e-8a53-2f1e6b0c9d11"}`: "9f0c2a6e-1b7d-4c1e-8a53-2f1e6b0c9d11",
+		"Session ID: 4d5e6f70-aaaa": "4d5e6f70-aaaa",
+		"the session is over":       "",
+	} {
+		output := &taskOutput{}
+		output.noteSessionID([]byte(line))
+		assert.Equal(t, want, output.reportedSessionID(), line)
+	}
+}
@@ -0,0 +1,120 @@
+package executor
+
+import (
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/berno/aaw-runner/internal/models"
+)
+
+// spinnerSummaryInterval is how often a LOG line reports the spinner lines dropped from a task's output
+var spinnerSummaryInterval = 10 * time.Second
+
+// spinnerCount counts a task's output lines dropped as spinner frames, until a LOG line reports them
+type spinnerCount struct {
+	dropped int64     // Lines dropped since the last report
+	since   time.Time // When the first of them was dropped
+}
+
+// spinnerLine reports whether line only draws a spinner: braille spinner glyphs (U+2800-U+28FF),
+// cursor-control escapes and whitespace, with at least one glyph or escape. A line with anything else in
+// it, such as the text a spinner stands next to, is not one.
+func spinnerLine(line []byte) bool {
+	drawn := false
+	for i := 0; i < len(line); {
+		switch c := line[i]; {
+		case c == 0x1b:
+			n := cursorEscape(line[i:])
+			if n == 0 {
+				return false
+			}
+			drawn = true
+			i += n
+		case c == ' ' || c == '\t' || c == '\b' || c == '\v' || c == '\f':
+			i++
+		default:
+			r, size := utf8.DecodeRune(line[i:])
+			if r < 0x2800 || r > 0x28FF {
+				return false
+			}
+			drawn = true
+			i += size
+		}
+	}
+	return drawn
+}
+
+// cursorEscape returns the length of the escape sequence p starts with when it moves the cursor, erases,
+// hides or shows the cursor, or sets colours, and 0 for any other
+func cursorEscape(p []byte) int {
+	if len(p) >= 2 && (p[1] == '7' || p[1] == '8') {
+		// DEC save and restore cursor
+		return 2
+	}
+	if len(p) < 3 || p[1] != '[' {
+		return 0
+	}
+	// CSI: parameter bytes, intermediate bytes, then one final byte
+	i := 2
+	for i < len(p) && p[i] >= 0x30 && p[i] <= 0x3f {
+		i++
+	}
+	private := i > 2 && p[2] == '?'
+	for i < len(p) && p[i] >= 0x20 && p[i] <= 0x2f {
+		i++
+	}
+	if i == len(p) {
+		return 0
+	}
+	switch final := p[i]; {
+	case final >= 'A' && final <= 'H', final == 'J', final == 'K', final == 'f', final == 's', final == 'u',
+		final == 'm':
+		return i + 1
+	case private && (final == 'h' || final == 'l'):
+		// DEC private modes such as ?25l (hide cursor)
+		return i + 1
+	}
+	return 0
+}
+
+// dropSpinner reports whether a line of task output is a spinner frame (see spinnerLine) to drop rather
+// than forward, counting it; every spinnerSummaryInterval a LOG line reports how many were dropped
+func (te *TaskExecutor) dropSpinner(output *taskOutput, line []byte) bool {
+	if !te.filterSpinners || !spinnerLine(line) {
+		return false
+	}
+	output.mu.Lock()
+	count := &output.spinners
+	if count.dropped == 0 {
+		count.since = time.Now()
+	}
+	count.dropped++
+	n := int64(0)
+	if time.Since(count.since) >= spinnerSummaryInterval {
+		n, count.dropped = count.dropped, 0
+	}
+	output.mu.Unlock()
+
+	if n > 0 {
+		te.reportSpinners(output.taskID, n)
+	}
+	return true
+}
+
+// flushSpinners reports the spinner lines dropped from an exited task's output since the last report
+func (te *TaskExecutor) flushSpinners(output *taskOutput) {
+	output.mu.Lock()
+	n := output.spinners.dropped
+	output.spinners.dropped = 0
+	output.mu.Unlock()
+
+	if n > 0 {
+		te.reportSpinners(output.taskID, n)
+	}
+}
+
+// reportSpinners sends the LOG line saying n spinner lines of a task were dropped
+func (te *TaskExecutor) reportSpinners(taskID int64, n int64) {
+	te.logCallback(models.NewLogMessage(taskID, fmt.Sprintf("[runner] Dropped %d spinner lines (AAW_FILTER_SPINNERS=false keeps them)", n), false))
+}
@@ -0,0 +1,91 @@
+package executor
+
+import (
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSpinnerLine verifies only lines of spinner glyphs, cursor-control escapes and whitespace are spinner
+// frames, and any other text keeps a line
+func TestSpinnerLine(t *testing.T) {
+	for line, want := range map[string]bool{
+		"⠋":                               true,
+		"  ⠙\t":                           true,
+		"\x1b[2K\x1b[1G⠹":                 true,
+		"\x1b[?25l\x1b[38;5;174m⠸\x1b[0m": true,
+		"\x1b[1A\x1b[2K":                  true,
+		"\x1b7⠼\x1b8":                     true,
+		"":                                false,
+		"   ":                             false,
+		"⠋ Thinking…":                     false,
+		"Building ⠋":                      false,
+		"\x1b[2K⠙ 3/10":                   false,
+		"\x1b[31merror\x1b[0m":            false,
+		"\x1b]0;title\x07⠋":               false,
+		"\x1b[?1049h⠋":                    true,
+		"\x1b[1049h⠋":                     false,
+		"\x1b[":                           false,
+		"·":                               false,
+	} {
+		assert.Equal(t, want, spinnerLine([]byte(line)), "%q", line)
+	}
+}
+
+// spinnerOutput draws a spinner between lines of text, one of them next to a spinner glyph
+const spinnerOutput = "start\n⠋\n\x1b[2K⠙\n\x1b[2K⠹ Compiling\n⠸\n\ndone\n"
+
+// TestStreamOutput_DropsSpinnerLines verifies both streaming modes drop spinner frames but keep the text
+// beside one and blank lines, and that one LOG line reports how many were dropped
+func TestStreamOutput_DropsSpinnerLines(t *testing.T) {
+	want := []string{"start", "\x1b[2K⠹ Compiling", "", "done",
+		"[runner] Dropped 3 spinner lines (AAW_FILTER_SPINNERS=false keeps them)"}
+
+	te, rec := recordingExecutor()
+	output := te.newTaskOutput(1, TaskOptions{})
+	te.streamOutput(output, strings.NewReader(spinnerOutput), false)
+	te.flushSpinners(output)
+	te.flushOutput(1)
+	assert.Equal(t, want, logLines(rec))
+
+	te, rec = recordingExecutor()
+	output = te.newTaskOutput(2, TaskOptions{})
+	te.streamOutputRealtime(output, iotest.OneByteReader(strings.NewReader(spinnerOutput)), false)
+	te.flushSpinners(output)
+	te.flushOutput(2)
+	assert.Equal(t, want, logLines(rec))
+}
+
+// TestStreamOutput_ReportsSpinnersPeriodically verifies spinner frames are reported once the summary
+// interval has passed since the first unreported one, and not again once reported
+func TestStreamOutput_ReportsSpinnersPeriodically(t *testing.T) {
+	orig := spinnerSummaryInterval
+	spinnerSummaryInterval = 0
+	defer func() { spinnerSummaryInterval = orig }()
+	te, rec := recordingExecutor()
+
+	output := te.newTaskOutput(3, TaskOptions{})
+	te.streamOutput(output, strings.NewReader("⠋\n⠙\ntext\n"), false)
+	te.flushSpinners(output)
+	te.flushOutput(3)
+
+	summary := "[runner] Dropped 1 spinner lines (AAW_FILTER_SPINNERS=false keeps them)"
+	assert.Equal(t, []string{summary, summary, "text"}, logLines(rec))
+}
+
+// TestStreamOutput_SpinnerFilterDisabled verifies every line is forwarded when the filter is off
+func TestStreamOutput_SpinnerFilterDisabled(t *testing.T) {
+	te, rec := recordingExecutor()
+	te.filterSpinners = false
+	te.progressFlush = time.Hour
+
+	output := te.newTaskOutput(4, TaskOptions{})
+	te.streamOutput(output, strings.NewReader(spinnerOutput), false)
+	te.flushSpinners(output)
+	te.flushOutput(4)
+
+	assert.Equal(t, strings.Split(strings.TrimSuffix(spinnerOutput, "\n"), "\n"), logLines(rec))
+}
@@ -0,0 +1,131 @@
+package executor
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/models"
+)
+
+// stallPolicy says when a silent task is reported STALLED, and whether it is cancelled then
+type stallPolicy struct {
+	threshold time.Duration // Silence after which the task is STALLED; zero uses the runner's
+	kill      bool          // stallAction kill: cancel the task once it stalls
+}
+
+// stallPolicyOf returns the stall policy of a task, with the runner's threshold unless its EXECUTE set one
+func (te *TaskExecutor) stallPolicyOf(opts TaskOptions) stallPolicy {
+	policy := opts.stall
+	if policy.threshold <= 0 {
+		policy.threshold = te.stallThreshold
+	}
+	return policy
+}
+
+// SetStallHandler registers a callback invoked when a task whose EXECUTE set stallAction kill stalls
+// (used by the pool to cancel it)
+func (te *TaskExecutor) SetStallHandler(fn func(taskID int64, silent time.Duration)) {
+	te.mu.Lock()
+	defer te.mu.Unlock()
+	te.onStall = fn
+}
+
+// activityReader notes every read of a task's output that returned something, for stall detection
+type activityReader struct {
+	io.Reader
+	te     *TaskExecutor
+	output *taskOutput
+}
+
+func (r activityReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		r.te.noteOutput(r.output)
+	}
+	return n, err
+}
+
+// noteOutput records that a task printed something, clearing a STALLED status with RUNNING
+func (te *TaskExecutor) noteOutput(output *taskOutput) {
+	now := time.Now()
+	last := output.lastOutput.Swap(now.UnixNano())
+	if !output.stalled.Swap(false) {
+		return
+	}
+	silent := roundSilence(now.Sub(time.Unix(0, last)))
+	log.Printf("[Executor] Task %d printed again after %s", output.taskID, silent)
+	te.statusCallback(models.NewStatusUpdate(output.taskID, models.StatusRunning))
+	te.logCallback(models.NewLogMessage(output.taskID, fmt.Sprintf("[runner] Output resumed after %s of silence", silent), false))
+}
+
+// watchStall reports a task STALLED each time it prints nothing for its policy's threshold, until stop is called
+func (te *TaskExecutor) watchStall(output *taskOutput, policy stallPolicy) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		timer := time.NewTimer(policy.threshold)
+		defer timer.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-timer.C:
+			}
+			wait := policy.threshold
+			if !output.stalled.Load() {
+				silent := time.Since(time.Unix(0, output.lastOutput.Load()))
+				if silent < policy.threshold {
+					wait = policy.threshold - silent
+				} else if te.reportStall(output, silent, policy) {
+					return
+				}
+			}
+			timer.Reset(wait)
+		}
+	}()
+	return func() { close(done) }
+}
+
+// reportStall sends the STALLED status and LOG line of a silent task, and hands it to the stall handler
+// when its EXECUTE set stallAction kill; it reports whether the task was cancelled
+func (te *TaskExecutor) reportStall(output *taskOutput, silent time.Duration, policy stallPolicy) bool {
+	output.stalled.Store(true)
+	silent = roundSilence(silent)
+	log.Printf("[Executor] Task %d stalled: no output for %s", output.taskID, silent)
+	te.statusCallback(models.NewStatusUpdate(output.taskID, models.StatusStalled))
+	line := fmt.Sprintf("[runner] No output for %s, the task looks stalled", silent)
+	if policy.kill {
+		line += "; cancelling it (stallAction kill)"
+	}
+	msg := models.NewLogMessage(output.taskID, line, false)
+	msg.Severity = "warn"
+	te.logCallback(msg)
+
+	if !policy.kill {
+		return false
+	}
+	te.mu.RLock()
+	onStall := te.onStall
+	te.mu.RUnlock()
+	if onStall != nil {
+		onStall(output.taskID, silent)
+	}
+	return true
+}
+
+// roundSilence rounds a silent duration for display: to the second, or the millisecond below one
+func roundSilence(d time.Duration) time.Duration {
+	if d >= time.Second {
+		return d.Round(time.Second)
+	}
+	return d.Round(time.Millisecond)
+}
+
+// onStall cancels a task whose EXECUTE set stallAction kill once it stalls, attributed to the stall policy
+func (p *ExecutorPool) onStall(taskID int64, silent time.Duration) {
+	by := Attribution{RequestedBy: models.RequestedByStallPolicy, Reason: fmt.Sprintf("no output for %s", silent)}
+	if err := p.CancelTask(taskID, by); err != nil {
+		log.Printf("[POOL] Failed to cancel stalled task %d: %v", taskID, err)
+	}
+}
@@ -0,0 +1,76 @@
+package executor
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// statusesOf lists the statuses a recorder saw, in order
+func statusesOf(rec *messageRecorder) []string {
+	var statuses []string
+	for _, msg := range rec.getStatuses() {
+		statuses = append(statuses, msg.Status)
+	}
+	return statuses
+}
+
+// linesWithPrefix lists the output lines starting with prefix
+func linesWithPrefix(output []string, prefix string) []string {
+	var lines []string
+	for _, line := range output {
+		if strings.HasPrefix(line, prefix) {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// TestStall_ReportedAndCleared verifies a task silent for the stall threshold is reported STALLED with a LOG
+// line, and RUNNING again once it prints, without being stopped
+func TestStall_ReportedAndCleared(t *testing.T) {
+	testutil.FakeClaude(t, "echo thinking; sleep 0.5; echo done")
+	te, rec := recordingExecutor()
+	te.stallThreshold = 150 * time.Millisecond
+
+	result, output := runPoolTaskOn(t, te, rec, models.ExecuteMessage{TaskID: 1, ScriptContent: "plan"})
+	assert.True(t, result.Success, result.Error)
+	assert.Equal(t, []string{models.StatusStalled, models.StatusRunning}, statusesOf(rec))
+	assert.Len(t, linesWithPrefix(output, "[runner] No output for "), 1)
+	assert.Len(t, linesWithPrefix(output, "[runner] Output resumed after "), 1)
+	assert.Contains(t, output, "done")
+}
+
+// TestStall_QuietTaskNotReported verifies a task printing more often than the threshold never stalls
+func TestStall_QuietTaskNotReported(t *testing.T) {
+	testutil.FakeClaude(t, "for i in 1 2 3 4 5; do echo $i; sleep 0.05; done")
+	te, rec := recordingExecutor()
+	te.stallThreshold = 300 * time.Millisecond
+
+	result, output := runPoolTaskOn(t, te, rec, models.ExecuteMessage{TaskID: 2, ScriptContent: "count"})
+	assert.True(t, result.Success, result.Error)
+	assert.Empty(t, statusesOf(rec))
+	assert.Empty(t, linesWithPrefix(output, "[runner]"))
+}
+
+// TestStall_Kill verifies the EXECUTE's own threshold overrides the runner's, and stallAction kill cancels
+// the stalled task on behalf of the stall policy
+func TestStall_Kill(t *testing.T) {
+	testutil.FakeClaude(t, "echo started; sleep 30")
+	te, rec := recordingExecutor()
+	te.stallThreshold = time.Hour
+
+	result, output := runPoolTaskOn(t, te, rec, models.ExecuteMessage{TaskID: 3, ScriptContent: "hang",
+		StallThresholdSeconds: 1, StallAction: models.StallActionKill})
+	assert.False(t, result.Success)
+	assert.Equal(t, models.ErrorCodeCancelled, result.ErrorCode)
+	assert.Equal(t, models.RequestedByStallPolicy, result.TerminatedBy.RequestedBy)
+	assert.Equal(t, "no output for 1s", result.TerminatedBy.Reason)
+	assert.Equal(t, []string{models.StatusStalled}, statusesOf(rec))
+	assert.Contains(t, output, "[runner] No output for 1s, the task looks stalled; cancelling it (stallAction kill)")
+	assert.Less(t, result.Duration, 10*time.Second)
+}
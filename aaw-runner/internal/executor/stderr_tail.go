@@ -0,0 +1,80 @@
+package executor
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/berno/aaw-runner/internal/models"
+)
+
+// stderrTailBytes bounds the stderr kept per task, whatever the line count; it stays under
+// models.DefaultMaxErrorBytes so the tail is not cut again on the way out
+const stderrTailBytes = 3 * 1024
+
+// stderrTail keeps the last stderr lines of a task, for the completion report of a failed one
+// A nil stderrTail keeps nothing.
+type stderrTail struct {
+	mu       sync.Mutex
+	lines    []string // Oldest first
+	bytes    int      // Total length of lines
+	maxLines int
+}
+
+// newStderrTail returns a tail keeping up to maxLines lines, nil when maxLines is 0
+func newStderrTail(maxLines int) *stderrTail {
+	if maxLines <= 0 {
+		return nil
+	}
+	return &stderrTail{maxLines: maxLines}
+}
+
+// add appends a line, dropping the oldest ones once over maxLines lines or stderrTailBytes bytes
+// A line longer than half of stderrTailBytes is cut, leaving room for the lines before it.
+func (t *stderrTail) add(line string) {
+	if t == nil {
+		return
+	}
+	line, _ = models.TruncateText(line, stderrTailBytes/2)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lines = append(t.lines, line)
+	t.bytes += len(line)
+	for len(t.lines) > t.maxLines || t.bytes > stderrTailBytes {
+		t.bytes -= len(t.lines[0])
+		t.lines[0] = ""
+		t.lines = t.lines[1:]
+	}
+}
+
+// String returns the kept lines, one per line
+func (t *stderrTail) String() string {
+	if t == nil {
+		return ""
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return strings.Join(t.lines, "\n")
+}
+
+// recordStderrTail keeps the last stderr lines of a task whose process has exited, for its completion report
+func (te *TaskExecutor) recordStderrTail(output *taskOutput) {
+	tail := output.stderr.String()
+	if tail == "" {
+		return
+	}
+	te.tailsMu.Lock()
+	defer te.tailsMu.Unlock()
+	if te.stderrTails == nil {
+		te.stderrTails = make(map[int64]string)
+	}
+	te.stderrTails[output.taskID] = tail
+}
+
+// takeStderrTail returns and forgets the last stderr lines recorded for a task
+func (te *TaskExecutor) takeStderrTail(taskID int64) string {
+	te.tailsMu.Lock()
+	defer te.tailsMu.Unlock()
+	tail := te.stderrTails[taskID]
+	delete(te.stderrTails, taskID)
+	return tail
+}
@@ -0,0 +1,84 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestStderrTail_KeepsLastLines verifies the tail keeps the most recent lines, bounded in count and in bytes
+func TestStderrTail_KeepsLastLines(t *testing.T) {
+	tail := newStderrTail(3)
+	for i := 1; i <= 5; i++ {
+		tail.add(fmt.Sprintf("line %d", i))
+	}
+	assert.Equal(t, "line 3\nline 4\nline 5", tail.String())
+
+	tail = newStderrTail(100)
+	for i := 0; i < 10; i++ {
+		tail.add(strings.Repeat("x", 1000))
+	}
+	tail.add("last")
+	assert.LessOrEqual(t, len(tail.String()), stderrTailBytes+100)
+	assert.True(t, strings.HasSuffix(tail.String(), "\nlast"))
+
+	tail.add(strings.Repeat("y", 10*stderrTailBytes))
+	assert.LessOrEqual(t, len(tail.String()), stderrTailBytes+100, "A huge line is cut")
+
+	var none *stderrTail
+	none.add("ignored")
+	assert.Empty(t, none.String())
+	assert.Nil(t, newStderrTail(0))
+}
+
+// TestStderrTail_ConcurrentAdds verifies lines added from several goroutines are all accounted for
+func TestStderrTail_ConcurrentAdds(t *testing.T) {
+	tail := newStderrTail(1000)
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				tail.add("z")
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, strings.Split(tail.String(), "\n"), 400)
+}
+
+// TestExecute_FailureReportsStderrTail verifies a failed task's result carries its last stderr lines, a
+// successful one's none, and nothing is left behind once the pool has taken them
+func TestExecute_FailureReportsStderrTail(t *testing.T) {
+	te, _ := recordingExecutor()
+	te.stderrLines = 2
+	dir := t.TempDir()
+	failing := filepath.Join(dir, "fail.sh")
+	assert.NoError(t, os.WriteFile(failing, []byte("echo out\necho first >&2\necho second >&2\necho third >&2\nexit 1\n"), 0o644))
+	passing := filepath.Join(dir, "ok.sh")
+	assert.NoError(t, os.WriteFile(passing, []byte("echo warning >&2\n"), 0o644))
+
+	results := make(chan TaskResult, 2)
+	pool := NewExecutorPool(te, 1, nil, func(result TaskResult) { results <- result })
+	pool.Start()
+	defer pool.Stop()
+
+	assert.True(t, pool.Submit(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 11, Script: failing}))
+	result := <-results
+	assert.False(t, result.Success)
+	assert.Equal(t, "exit status 1", result.Error, "The legacy error string is unchanged")
+	assert.Equal(t, "second\nthird", result.ErrorDetail)
+
+	assert.True(t, pool.Submit(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 12, Script: passing}))
+	result = <-results
+	assert.True(t, result.Success)
+	assert.Empty(t, result.ErrorDetail)
+	assert.Empty(t, te.stderrTails)
+}
@@ -0,0 +1,16 @@
+package executor
+
+import (
+	"io"
+	"strings"
+)
+
+// stdinReader returns the standard input of the task, read in place rather than copied, and closed for
+// the process once read whole. It is nil when the EXECUTE gave none, which both exec.Cmd and ssh.Session
+// take as an empty input (exec.Cmd opens /dev/null), so a task never reads the runner's own stdin.
+func (o TaskOptions) stdinReader() io.Reader {
+	if o.stdin == "" {
+		return nil
+	}
+	return strings.NewReader(o.stdin)
+}
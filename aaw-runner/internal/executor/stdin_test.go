@@ -0,0 +1,41 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestStdin_PipedToTask verifies a task reads the stdinContent of its EXECUTE, whole and then EOF
+func TestStdin_PipedToTask(t *testing.T) {
+	testutil.FakeClaude(t, "cat; echo done")
+	result, output := runPoolTask(t, models.ExecuteMessage{TaskID: 1, ScriptContent: "review", StdinContent: "--- a/x\n+++ b/x\n"})
+	assert.True(t, result.Success, result.Error)
+	assert.Subset(t, output, []string{"--- a/x", "+++ b/x", "done"})
+
+	large := strings.Repeat("0123456789abcdef", 1<<16)
+	script := filepath.Join(t.TempDir(), "count.sh")
+	assert.NoError(t, os.WriteFile(script, []byte("wc -c | tr -d ' '\n"), 0o644))
+	result, output = runPoolTask(t, models.ExecuteMessage{TaskID: 2, Script: script, StdinContent: large})
+	assert.True(t, result.Success, result.Error)
+	assert.Contains(t, output, strconv.Itoa(len(large)))
+
+	testutil.FakeClaude(t, "echo ignored")
+	result, _ = runPoolTask(t, models.ExecuteMessage{TaskID: 3, ScriptContent: "review", StdinContent: large})
+	assert.True(t, result.Success, "A task may leave its stdin unread")
+}
+
+// TestStdin_EmptyWithoutContent verifies a task given no stdinContent reads EOF at once rather than the
+// runner's own stdin
+func TestStdin_EmptyWithoutContent(t *testing.T) {
+	testutil.FakeClaude(t, `if read -r line; then echo "read $line"; else echo eof; fi`)
+	result, output := runPoolTask(t, models.ExecuteMessage{TaskID: 4, ScriptContent: "review"})
+	assert.True(t, result.Success, result.Error)
+	assert.Contains(t, output, "eof")
+}
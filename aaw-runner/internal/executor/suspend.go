@@ -0,0 +1,60 @@
+package executor
+
+import (
+	"fmt"
+	"log"
+	"syscall"
+)
+
+// SuspendTask stops a running task's process group with SIGSTOP until ResumeTask
+// A suspended task can still be cancelled or killed.
+func (te *TaskExecutor) SuspendTask(taskID int64) error {
+	task, exists := te.getRunningTask(taskID)
+	if !exists {
+		return fmt.Errorf("task %d is not running", taskID)
+	}
+	if err := task.kill(syscall.SIGSTOP); err != nil {
+		return fmt.Errorf("failed to send SIGSTOP: %w", err)
+	}
+	task.suspended.Store(true)
+	return nil
+}
+
+// ResumeTask continues a task stopped by SuspendTask
+func (te *TaskExecutor) ResumeTask(taskID int64) error {
+	task, exists := te.getRunningTask(taskID)
+	if !exists {
+		return fmt.Errorf("task %d is not running", taskID)
+	}
+	if !task.suspended.Swap(false) {
+		return nil
+	}
+	if err := task.kill(syscall.SIGCONT); err != nil {
+		return fmt.Errorf("failed to send SIGCONT: %w", err)
+	}
+	return nil
+}
+
+// IsTaskSuspended reports whether a running task is stopped by SuspendTask
+func (te *TaskExecutor) IsTaskSuspended(taskID int64) bool {
+	task, exists := te.getRunningTask(taskID)
+	return exists && task.suspended.Load()
+}
+
+// SuspendTask stops a running task's process group until ResumeTask; queued tasks are unaffected (see Pause)
+func (p *ExecutorPool) SuspendTask(taskID int64) error {
+	if err := p.executor.SuspendTask(taskID); err != nil {
+		return err
+	}
+	log.Printf("[POOL] Suspended task %d", taskID)
+	return nil
+}
+
+// ResumeTask continues a task stopped by SuspendTask
+func (p *ExecutorPool) ResumeTask(taskID int64) error {
+	if err := p.executor.ResumeTask(taskID); err != nil {
+		return err
+	}
+	log.Printf("[POOL] Resumed task %d", taskID)
+	return nil
+}
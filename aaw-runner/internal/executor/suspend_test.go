@@ -0,0 +1,57 @@
+package executor
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// processState returns the /proc state letter of a process ("T" when stopped)
+func processState(t *testing.T, pid int) string {
+	t.Helper()
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		t.Skip("/proc unavailable")
+	}
+	stat := string(data)
+	return strings.Fields(stat[strings.LastIndexByte(stat, ')')+1:])[0]
+}
+
+// TestSuspendTask_StopsAndContinues verifies a suspended task is stopped until resumed, and still cancellable
+func TestSuspendTask_StopsAndContinues(t *testing.T) {
+	testutil.FakeClaude(t, "sleep 30")
+
+	te := NewTaskExecutor(func(models.LogMessage) {}, func(models.StatusUpdateMessage) {})
+	completed := make(chan TaskResult, 1)
+	pool := NewExecutorPool(te, 1, nil, func(result TaskResult) { completed <- result })
+	pool.Start()
+	defer pool.Stop()
+
+	assert.True(t, pool.Submit(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 30, ScriptContent: "hello"}))
+	waitForRegistration(t, te, 30)
+	pgid, _ := te.processGroup(30)
+
+	assert.NoError(t, pool.SuspendTask(30))
+	assert.True(t, te.IsTaskSuspended(30))
+	assert.Eventually(t, func() bool { return processState(t, pgid) == "T" }, time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, pool.ResumeTask(30))
+	assert.False(t, te.IsTaskSuspended(30))
+	assert.Eventually(t, func() bool { return processState(t, pgid) != "T" }, time.Second, 10*time.Millisecond)
+
+	// SIGTERM is only acted on once a stopped group is continued
+	assert.NoError(t, pool.SuspendTask(30))
+	start := time.Now()
+	assert.NoError(t, pool.CancelTask(30, byBackend))
+	assert.Less(t, time.Since(start), 5*time.Second, "Cancel should not need the SIGKILL escalation")
+	assert.Equal(t, models.ErrorCodeCancelled, (<-completed).ErrorCode)
+
+	assert.Error(t, pool.SuspendTask(30), "Finished tasks cannot be suspended")
+	assert.Error(t, pool.ResumeTask(30))
+}
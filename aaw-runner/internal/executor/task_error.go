@@ -0,0 +1,65 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/berno/aaw-runner/internal/models"
+)
+
+// TaskError attaches a machine-readable failure code (models.ErrorCode*) to a task failure
+// Error() returns the underlying message unchanged so the legacy error string stays the same
+type TaskError struct {
+	Code string
+	Err  error
+}
+
+func (e *TaskError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TaskError) Unwrap() error {
+	return e.Err
+}
+
+// newTaskError creates a coded error with a formatted message
+func newTaskError(code, format string, args ...interface{}) error {
+	return &TaskError{Code: code, Err: fmt.Errorf(format, args...)}
+}
+
+// withCode wraps err with a failure code
+func withCode(code string, err error) error {
+	return &TaskError{Code: code, Err: err}
+}
+
+// cancelledError is the failure of a cancelled task: the legacy "task cancelled" message, wrapping the error
+// the process exited with so its exit code is still reported
+type cancelledError struct {
+	exit error
+}
+
+func (e *cancelledError) Error() string {
+	return "task cancelled"
+}
+
+func (e *cancelledError) Unwrap() error {
+	return e.exit
+}
+
+// cancelled is the CANCELLED failure of a task whose process ended with exitErr once cancelled
+func cancelled(exitErr error) error {
+	return withCode(models.ErrorCodeCancelled, &cancelledError{exit: exitErr})
+}
+
+// ErrorCode returns the failure code for a task error
+// Errors that never passed through a coded failure path report models.ErrorCodeInternal
+func ErrorCode(err error) string {
+	if err == nil {
+		return ""
+	}
+	var taskErr *TaskError
+	if errors.As(err, &taskErr) {
+		return taskErr.Code
+	}
+	return models.ErrorCodeInternal
+}
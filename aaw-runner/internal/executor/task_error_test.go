@@ -0,0 +1,216 @@
+package executor
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/matcher"
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// waitForRegistration blocks until the executor is tracking the task
+func waitForRegistration(t *testing.T, te *TaskExecutor, taskID int64) {
+	t.Helper()
+	assert.Eventually(t, func() bool {
+		te.mu.RLock()
+		defer te.mu.RUnlock()
+		_, ok := te.runningTasks[taskID]
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// TestErrorCode_MissingScript verifies a missing legacy script reports START_FAILED
+func TestErrorCode_MissingScript(t *testing.T) {
+	te, _ := recordingExecutor()
+
+	err := te.Execute(1, filepath.Join(t.TempDir(), "missing.sh"), TaskOptions{})
+
+	assert.Equal(t, models.ErrorCodeStartFailed, ErrorCode(err))
+	assert.Contains(t, err.Error(), "Script not found", "Legacy error text should be unchanged")
+}
+
+// TestErrorCode_NonzeroExit verifies a failing script reports EXIT_NONZERO
+func TestErrorCode_NonzeroExit(t *testing.T) {
+	te, _ := recordingExecutor()
+	script := filepath.Join(t.TempDir(), "fail.sh")
+	assert.NoError(t, os.WriteFile(script, []byte("exit 3\n"), 0o644))
+
+	err := te.Execute(2, script, TaskOptions{})
+
+	assert.Equal(t, models.ErrorCodeExitNonzero, ErrorCode(err))
+	assert.Contains(t, err.Error(), "exit status 3")
+	assert.Equal(t, 3, newTaskResult(2, err).ExitCode)
+}
+
+// TestExitCode_KilledBySignal verifies a script killed by a signal reports 128+N, as a shell would
+func TestExitCode_KilledBySignal(t *testing.T) {
+	te, _ := recordingExecutor()
+	script := filepath.Join(t.TempDir(), "kill.sh")
+	assert.NoError(t, os.WriteFile(script, []byte("kill -KILL $$\n"), 0o644))
+
+	err := te.Execute(10, script, TaskOptions{})
+
+	assert.Equal(t, models.ErrorCodeExitNonzero, ErrorCode(err))
+	assert.Equal(t, 137, newTaskResult(10, err).ExitCode)
+}
+
+// TestErrorCode_ClaudeNotFound verifies a missing claude binary reports START_FAILED
+func TestErrorCode_ClaudeNotFound(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	te, _ := recordingExecutor()
+
+	err := te.ExecuteDynamic(3, "hello", false, "", TaskOptions{})
+
+	assert.Equal(t, models.ErrorCodeStartFailed, ErrorCode(err))
+	assert.Equal(t, -1, newTaskResult(3, err).ExitCode)
+}
+
+// TestErrorCode_Cancelled verifies a cancelled task reports CANCELLED and keeps the legacy message
+func TestErrorCode_Cancelled(t *testing.T) {
+	testutil.FakeClaude(t, "sleep 10")
+	te, _ := recordingExecutor()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- te.ExecuteDynamic(4, "hello", false, "", TaskOptions{})
+	}()
+	waitForRegistration(t, te, 4)
+
+	assert.NoError(t, te.CancelTask(4))
+
+	select {
+	case err := <-done:
+		assert.Equal(t, models.ErrorCodeCancelled, ErrorCode(err))
+		assert.Equal(t, "task cancelled", err.Error())
+	case <-time.After(5 * time.Second):
+		t.Fatal("cancelled task did not return")
+	}
+}
+
+// TestExecute_OwnProcessGroup verifies a legacy script runs in a process group of its own and, when it
+// succeeds, streams its output and returns nil as before
+func TestExecute_OwnProcessGroup(t *testing.T) {
+	te, rec := recordingExecutor()
+	script := filepath.Join(t.TempDir(), "ok.sh")
+	assert.NoError(t, os.WriteFile(script, []byte("echo hello\nsleep 0.2\n"), 0o644))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- te.Execute(9, script, TaskOptions{})
+	}()
+	waitForRegistration(t, te, 9)
+	pgid, ok := te.processGroup(9)
+	assert.True(t, ok)
+	assert.NotEqual(t, syscall.Getpgrp(), pgid, "The script must not share the runner's process group")
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("script did not finish")
+	}
+	var lines []string
+	for _, msg := range rec.getLogs() {
+		lines = append(lines, msg.Line)
+	}
+	assert.Contains(t, lines, "hello")
+	assert.False(t, te.IsTaskRunning(9))
+}
+
+// TestExecute_Cancel verifies a legacy script task can be cancelled or killed like a dynamic one: it reports
+// CANCELLED, and no process of its group survives
+func TestExecute_Cancel(t *testing.T) {
+	for name, tc := range map[string]struct {
+		cancel   func(te *TaskExecutor, taskID int64) error
+		exitCode int
+	}{
+		"cancel": {(*TaskExecutor).CancelTask, 128 + int(syscall.SIGTERM)},
+		"kill":   {(*TaskExecutor).ForceKillTask, 128 + int(syscall.SIGKILL)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			te, _ := recordingExecutor()
+			script := filepath.Join(t.TempDir(), "sleep.sh")
+			assert.NoError(t, os.WriteFile(script, []byte("sleep 30 &\nsleep 30\n"), 0o644))
+
+			done := make(chan error, 1)
+			go func() {
+				done <- te.Execute(8, script, TaskOptions{})
+			}()
+			waitForRegistration(t, te, 8)
+			pgid, ok := te.processGroup(8)
+			assert.True(t, ok)
+
+			assert.NoError(t, tc.cancel(te, 8))
+
+			select {
+			case err := <-done:
+				assert.Equal(t, models.ErrorCodeCancelled, ErrorCode(err))
+				assert.Equal(t, "task cancelled", err.Error())
+				assert.Equal(t, tc.exitCode, newTaskResult(8, err).ExitCode)
+			case <-time.After(5 * time.Second):
+				t.Fatal("cancelled task did not return")
+			}
+			assert.False(t, te.IsTaskRunning(8))
+			assert.Eventually(t, func() bool { return len(processGroupMembers(pgid)) == 0 }, 2*time.Second, 20*time.Millisecond,
+				"No process from the task's group may survive")
+		})
+	}
+}
+
+// TestErrorCode_Uncoded verifies errors without a code are reported as INTERNAL
+func TestErrorCode_Uncoded(t *testing.T) {
+	assert.Equal(t, "", ErrorCode(nil))
+	assert.Equal(t, models.ErrorCodeInternal, ErrorCode(errors.New("boom")))
+}
+
+// TestErrorCode_ClassifiedFailureKeepsCode verifies OOM/auth classification and the code coexist
+func TestErrorCode_ClassifiedFailureKeepsCode(t *testing.T) {
+	err := withCode(models.ErrorCodeExitNonzero, &OOMError{Err: errors.New("signal: killed"), Evidence: "vmstat", Confirmed: true})
+
+	result := newTaskResult(5, err)
+
+	assert.Equal(t, models.ErrorCodeExitNonzero, result.ErrorCode)
+	assert.Equal(t, models.ClassificationOOM, result.Classification)
+}
+
+// TestErrorCode_RunnerShutdown verifies a task held by the backoff reports RUNNER_SHUTDOWN on Stop
+func TestErrorCode_RunnerShutdown(t *testing.T) {
+	results := make(chan TaskResult, 1)
+	te := NewTaskExecutor(func(models.LogMessage) {}, func(models.StatusUpdateMessage) {})
+	pool := NewExecutorPool(te, 1, nil, func(result TaskResult) { results <- result })
+	pool.onDetection(1, matcher.CategoryUsageLimit, time.Time{})
+
+	assert.True(t, pool.Submit(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 6, ScriptContent: "hello"}))
+	pool.Start()
+	// Make sure the worker has dequeued the task and is waiting out the backoff
+	assert.Eventually(t, func() bool { return len(pool.taskQueue) == 0 }, time.Second, 5*time.Millisecond)
+	pool.Stop()
+
+	select {
+	case result := <-results:
+		assert.Equal(t, int64(6), result.TaskID)
+		assert.False(t, result.Success)
+		assert.Equal(t, models.ErrorCodeRunnerShutdown, result.ErrorCode)
+	case <-time.After(time.Second):
+		t.Fatal("no completion reported for the task held at shutdown")
+	}
+}
+
+// TestRejectReason_Codes verifies rejection reasons carry their failure codes
+func TestRejectReason_Codes(t *testing.T) {
+	pool := newTestPool(1)
+	assert.True(t, pool.Submit(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 7}))
+
+	code, _ := pool.RejectReason()
+	assert.Equal(t, models.ErrorCodeCapacity, code)
+
+	pool.onDetection(7, matcher.CategoryAuth, time.Time{})
+	code, _ = pool.RejectReason()
+	assert.Equal(t, models.ErrorCodePolicyRejected, code)
+}
@@ -2,30 +2,91 @@ package executor
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
+	"unicode/utf8"
 
+	"github.com/berno/aaw-runner/internal/checkout"
+	"github.com/berno/aaw-runner/internal/config"
 	"github.com/berno/aaw-runner/internal/matcher"
 	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/pty"
+	"github.com/berno/aaw-runner/internal/remote"
+	"github.com/berno/aaw-runner/internal/streamjson"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// useRealtimeStreaming enables character-level streaming for lower latency
-// Set AAW_REALTIME_STREAMING=true to enable
-var useRealtimeStreaming = os.Getenv("AAW_REALTIME_STREAMING") == "true"
+// newPatternMatcher builds the detection matcher
+// Custom patterns and exclusions are read from path (if set); invalid files fall back to the defaults
+func newPatternMatcher(path string) *matcher.PatternMatcher {
+	if path == "" {
+		return matcher.NewPatternMatcher()
+	}
 
-func init() {
-	if useRealtimeStreaming {
-		log.Println("[Executor] Real-time streaming mode enabled")
+	cfg, err := matcher.LoadMatcherConfig(path)
+	if err != nil {
+		log.Printf("[Executor] %v, using default detection patterns", err)
+		return matcher.NewPatternMatcher()
+	}
+
+	pm, err := matcher.NewPatternMatcherWithConfig(*cfg)
+	if err != nil {
+		log.Printf("[Executor] Invalid detection patterns (%v), using defaults", err)
+		return matcher.NewPatternMatcher()
+	}
+	return pm
+}
+
+// loadSSHHosts reads the hosts remote tasks may run on; without a usable file no task can run remotely
+func loadSSHHosts(path string) *remote.Hosts {
+	if path == "" {
+		return nil
+	}
+	hosts, err := remote.Load(path)
+	if err != nil {
+		log.Printf("[Executor] %v; remote execution is disabled", err)
+		return nil
 	}
+	log.Printf("[Executor] Remote execution enabled on SSH hosts: %s", strings.Join(hosts.Names(), ", "))
+	return hosts
+}
+
+// newSeverityClassifier builds the classifier used for streamed output
+// Custom rules are read from the configured rules file; invalid rules fall back to the defaults
+func newSeverityClassifier(cfg config.Config) *matcher.SeverityClassifier {
+	if !cfg.SeverityClassification {
+		return nil
+	}
+
+	rules := matcher.DefaultSeverityRules()
+	if path := cfg.SeverityRulesFile; path != "" {
+		custom, err := matcher.LoadSeverityRules(path)
+		if err != nil {
+			log.Printf("[Executor] %v, using default severity rules", err)
+		} else {
+			rules = custom
+		}
+	}
+
+	classifier, err := matcher.NewSeverityClassifier(rules)
+	if err != nil {
+		log.Printf("[Executor] Invalid severity rules (%v), using defaults", err)
+		classifier, _ = matcher.NewSeverityClassifier(matcher.DefaultSeverityRules())
+	}
+	return classifier
 }
 
 // CancelTimeout is the duration to wait for graceful shutdown before force kill
@@ -38,155 +99,464 @@ type RunningTask struct {
 	Cancel    context.CancelFunc
 	Pgid      int       // Process group ID for killing child processes
 	StartedAt time.Time
+	Host      string // SSH host the task runs on; empty for local tasks
+
+	// Delivers a signal to the task's process group; nil signals Pgid locally
+	signal func(sig syscall.Signal) error
+
+	// Set once CancelTask has signalled the task, so its exit is reported as a cancellation
+	cancelRequested atomic.Bool
+	// Set while the process group is stopped by SuspendTask
+	suspended atomic.Bool
+	// Writable stdin of an interactive task (see WriteStdin); nil for other tasks
+	stdin *taskStdin
+}
+
+// kill sends sig to the task's process group, wherever it runs
+func (t *RunningTask) kill(sig syscall.Signal) error {
+	if t.signal != nil {
+		return t.signal(sig)
+	}
+	return syscall.Kill(-t.Pgid, sig)
+}
+
+// taskOutput holds per-task state shared by a task's stdout and stderr streams
+type taskOutput struct {
+	taskID    int64
+	lineCount atomic.Int64 // Lines forwarded so far across both streams
+	sensitive []string     // Variable values masked in every line
+	stderr    *stderrTail  // Last stderr lines, reported when the task fails
+
+	oomBaseline  map[string]int64 // Kernel OOM counters sampled at task start
+	mu           sync.Mutex
+	oomSuspect   string // First output line that looked like memory exhaustion
+	authEvidence string // First output line that looked like a credential failure
+
+	captureSession bool   // A PERSIST task: session IDs in its output are recorded
+	sessionID      string // Last session ID claude reported (guarded by mu)
+	jsonEvents     bool   // Claude prints stream-json events, parsed rather than forwarded as they are
+
+	maxBytes int64      // Bytes of output lines the task may send (0 for no cap)
+	size     outputSize // Bytes of output lines sent and discarded so far (guarded by mu)
+
+	lastOutput atomic.Int64 // When the task last printed anything (UnixNano), for stall detection
+	stalled    atomic.Bool  // Reported STALLED, and silent since
+
+	progress [2]progressLine // Lines redrawn with "\r" on stdout and stderr
+	spinners spinnerCount    // Spinner frames dropped and not yet reported (guarded by mu)
+}
+
+// recordAuthFailure records output evidence of an authentication failure (first occurrence wins)
+func (o *taskOutput) recordAuthFailure(evidence string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.authEvidence == "" {
+		o.authEvidence = evidence
+	}
+}
+
+// authFailure returns the recorded output evidence of an authentication failure, if any
+func (o *taskOutput) authFailure() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.authEvidence
+}
+
+// suspectOOM records output evidence of memory exhaustion (first occurrence wins)
+func (o *taskOutput) suspectOOM(evidence string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.oomSuspect == "" {
+		o.oomSuspect = evidence
+	}
+}
+
+// oomSuspicion returns the recorded output evidence of memory exhaustion, if any
+func (o *taskOutput) oomSuspicion() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.oomSuspect
 }
 
 // TaskExecutor executes shell scripts and streams output
 type TaskExecutor struct {
+	realtime       bool     // Character-level streaming instead of line scanning
+	debug          bool     // Print per-line [DEBUG] stream traces
+	claudePath     string   // Binary run for dynamic tasks
+	interpreters   []string // Interpreters an EXECUTE may name
+	allowedModels  []string // Models an EXECUTE may name (empty allows any)
+	tracer         trace.Tracer
 	matcher        *matcher.PatternMatcher
+	masker         *matcher.SecretMasker       // nil when masking is disabled
+	classifier     *matcher.SeverityClassifier // nil when classification is disabled
 	logCallback    func(models.LogMessage)
 	statusCallback func(models.StatusUpdateMessage)
 	runningTasks   map[int64]*RunningTask
 	mu             sync.RWMutex
+
+	// onDetection is notified of rate/usage limit and auth detections (used by the pool for backoff and the circuit breaker)
+	onDetection func(taskID int64, category matcher.Category, resetAt time.Time)
+	// onStall is notified when a task whose EXECUTE set stallAction kill stalls (used by the pool to cancel it)
+	onStall func(taskID int64, silent time.Duration)
+
+	// traceContext returns the context carrying a task's span (set by the pool; nil starts root spans)
+	traceContext func(taskID int64) context.Context
+
+	oomEvidence oomEvidenceSource // Kernel OOM breadcrumbs, faked in tests
+	oomEvents   atomic.Int64      // Confirmed or suspected OOM kills since startup
+
+	usageMu sync.Mutex
+	usage   map[int64]*ResourceUsage // Final resource usage of exited tasks, until the pool reports them
+
+	stderrLines   int // Stderr lines kept per task for the completion report of a failed one
+	tailsMu       sync.Mutex
+	stderrTails   map[int64]string               // Last stderr lines of exited tasks, until the pool reports them
+	claudeResults map[int64]*models.ClaudeResult // Results claude reported for tasks with JSON output, likewise
+	outputSizes   map[int64]outputSize           // Byte counts of exited tasks' output, likewise
+
+	maxOutputBytes int64         // Bytes of output lines a task may send unless its EXECUTE sets its own cap (0 for no cap)
+	progressFlush  time.Duration // Least time between LOG lines of one line a task redraws with "\r"
+	filterSpinners bool          // Drop output lines that only draw a spinner (see spinnerLine)
+
+	output *outputQueue // Behind logCallback and statusCallback, so readers never wait for the network
+
+	hosts *remote.Hosts // SSH hosts tasks may run on (nil when none are configured)
+
+	checkouts *checkout.Manager // Git checkouts tasks run in
+
+	missingKey string // What a placeholder without a value expands to (config.MissingKeyError or MissingKeyEmpty)
+
+	forcePTY bool     // Run every local task on a pseudo-terminal
+	ptySize  pty.Size // Window size of task pseudo-terminals
+
+	sessions *sessionRegistry // Claude sessions PERSIST tasks continue, by sessionKey
+
+	stallThreshold time.Duration // Silence after which a task is reported STALLED, unless its EXECUTE sets its own
 }
 
-// NewTaskExecutor creates a new task executor
+// NewTaskExecutor creates a new task executor with the default configuration
 func NewTaskExecutor(
 	logCallback func(models.LogMessage),
 	statusCallback func(models.StatusUpdateMessage),
 ) *TaskExecutor {
+	return NewTaskExecutorWithConfig(config.Default(), logCallback, statusCallback)
+}
+
+// NewTaskExecutorWithConfig creates a task executor using the resolved runner configuration
+func NewTaskExecutorWithConfig(
+	cfg config.Config,
+	logCallback func(models.LogMessage),
+	statusCallback func(models.StatusUpdateMessage),
+) *TaskExecutor {
+	var masker *matcher.SecretMasker
+	if cfg.SecretMasking {
+		masker = matcher.NewSecretMasker()
+	}
+	if cfg.RealtimeStreaming {
+		log.Println("[Executor] Real-time streaming mode enabled")
+	}
+
+	output := newOutputQueue(cfg.LogBackpressure, logCallback, statusCallback)
 	return &TaskExecutor{
-		matcher:        matcher.NewPatternMatcher(),
-		logCallback:    logCallback,
-		statusCallback: statusCallback,
+		realtime:       cfg.RealtimeStreaming,
+		debug:          cfg.Debug(),
+		claudePath:     cfg.ClaudePath,
+		interpreters:   cfg.Interpreters(),
+		allowedModels:  cfg.Models(),
+		tracer:         defaultTracer(),
+		matcher:        newPatternMatcher(cfg.MatcherPatternsFile),
+		masker:         masker,
+		classifier:     newSeverityClassifier(cfg),
+		logCallback:    output.log,
+		statusCallback: output.status,
+		output:         output,
 		runningTasks:   make(map[int64]*RunningTask),
+		oomEvidence:    linuxOOMEvidence{},
+		hosts:          loadSSHHosts(cfg.SSHHostsFile),
+		checkouts:      checkout.NewManager(filepath.Join(cfg.StateDir, checkout.DirName), cfg.GitSSHKey, cfg.GitCredentialHelper),
+		missingKey:     cfg.TemplateMissingKey,
+		stderrLines:    cfg.StderrTailLines,
+		maxOutputBytes: int64(cfg.MaxTaskOutputBytes),
+		forcePTY:       cfg.ForcePTY,
+		ptySize:        pty.Size{Cols: uint16(min(cfg.PTYCols, math.MaxUint16)), Rows: uint16(min(cfg.PTYRows, math.MaxUint16))},
+		sessions:       newSessionRegistry(cfg.SessionTTL),
+		stallThreshold: cfg.StallThreshold,
+		progressFlush:  cfg.ProgressFlush,
+		filterSpinners: cfg.FilterSpinners,
 	}
 }
 
+// debugf logs a [DEBUG] trace when the log level is debug
+func (te *TaskExecutor) debugf(format string, args ...interface{}) {
+	if te.debug {
+		log.Printf("[DEBUG] "+format, args...)
+	}
+}
+
+// newTaskOutput creates the per-task stream state, sampling OOM counters as a baseline
+func (te *TaskExecutor) newTaskOutput(taskID int64, opts TaskOptions) *taskOutput {
+	output := &taskOutput{taskID: taskID, sensitive: opts.sensitive, stderr: newStderrTail(te.stderrLines),
+		maxBytes: te.outputCapOf(opts)}
+	output.progress[0].interval = te.progressFlush
+	output.progress[1].interval = te.progressFlush
+	output.lastOutput.Store(time.Now().UnixNano())
+	if te.oomEvidence != nil {
+		output.oomBaseline = te.oomEvidence.counters()
+	}
+	return output
+}
+
+// OOMEventCount returns the number of tasks classified as OOM since startup
+func (te *TaskExecutor) OOMEventCount() int64 {
+	return te.oomEvents.Load()
+}
+
+// classifyFailure wraps a wait error in an OOMError when kernel or output evidence points at memory exhaustion
+// Kernel evidence is only consulted for SIGKILL terminations, which is how the OOM killer stops processes
+func (te *TaskExecutor) classifyFailure(output *taskOutput, cmd *exec.Cmd, waitErr error) error {
+	if killedBySignal(cmd, syscall.SIGKILL) {
+		if evidence, confirmed := oomKillEvidence(te.oomEvidence, cmd.Process.Pid, output.oomBaseline); evidence != "" {
+			te.oomEvents.Add(1)
+			return &OOMError{Err: waitErr, Evidence: evidence, Confirmed: confirmed}
+		}
+	}
+	return te.classifyOutput(output, waitErr)
+}
+
+// classifyOutput wraps a wait error in an AuthError or suspected OOMError when the task's output points at one
+func (te *TaskExecutor) classifyOutput(output *taskOutput, waitErr error) error {
+	if evidence := output.authFailure(); evidence != "" {
+		return &AuthError{Err: waitErr, Evidence: evidence}
+	}
+
+	if suspect := output.oomSuspicion(); suspect != "" {
+		te.oomEvents.Add(1)
+		return &OOMError{Err: waitErr, Evidence: suspect}
+	}
+
+	return waitErr
+}
+
+// AuthError reports that a task failed after printing an authentication/credential error
+type AuthError struct {
+	Err      error  // Underlying wait error
+	Evidence string // Output line that identified the credential failure
+}
+
+func (e *AuthError) Error() string {
+	return fmt.Sprintf("authentication failure: %v (%s)", e.Err, e.Evidence)
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+// killedBySignal reports whether the finished command was terminated by sig
+func killedBySignal(cmd *exec.Cmd, sig syscall.Signal) bool {
+	if cmd.ProcessState == nil {
+		return false
+	}
+	status, ok := cmd.ProcessState.Sys().(syscall.WaitStatus)
+	return ok && status.Signaled() && status.Signal() == sig
+}
+
 // Execute runs a script and streams its output
-func (te *TaskExecutor) Execute(taskID int64, scriptPath string) error {
+func (te *TaskExecutor) Execute(taskID int64, scriptPath string, opts TaskOptions) error {
+	defer te.flushOutput(taskID)
+
 	// Get absolute path
 	absPath, err := filepath.Abs(scriptPath)
 	if err != nil {
 		errMsg := fmt.Sprintf("Failed to resolve script path: %v", err)
-		te.logCallback(models.LogMessage{
-			Type:    models.TypeLog,
-			TaskID:  taskID,
-			Line:    errMsg,
-			IsError: true,
-		})
-		return fmt.Errorf(errMsg)
+		te.logCallback(models.NewLogMessage(taskID, errMsg, true))
+		return newTaskError(models.ErrorCodeStartFailed, "%s", errMsg)
 	}
 
 	// Check if script exists
 	if _, err := os.Stat(absPath); os.IsNotExist(err) {
 		errMsg := fmt.Sprintf("Script not found: %s", absPath)
-		te.logCallback(models.LogMessage{
-			Type:    models.TypeLog,
-			TaskID:  taskID,
-			Line:    errMsg,
-			IsError: true,
-		})
-		return fmt.Errorf(errMsg)
-	}
-
-	// Log execution start
-	te.logCallback(models.LogMessage{
-		Type:    models.TypeLog,
-		TaskID:  taskID,
-		Line:    fmt.Sprintf("Starting execution: %s", absPath),
-		IsError: false,
-	})
-
-	// Create command
-	cmd := exec.Command("/bin/bash", absPath)
-	cmd.Dir = filepath.Dir(absPath)
+		te.logCallback(models.NewLogMessage(taskID, errMsg, true))
+		return newTaskError(models.ErrorCodeStartFailed, "%s", errMsg)
+	}
 
-	// Create pipes for stdout and stderr
-	stdout, err := cmd.StdoutPipe()
+	// Log execution start, with the arguments, interpreter and directory of the task if its EXECUTE set them
+	startLine := fmt.Sprintf("Starting execution: %s", absPath) + describeArgs(opts.args)
+	interp := opts.interp
+	if interp.path != "" {
+		startLine += " under " + interp.name
+	} else {
+		interp = interpreter{name: "bash", path: "/bin/bash"}
+	}
+	if opts.workDir != "" {
+		startLine += " in " + opts.workDir
+	}
+	te.logCallback(models.NewLogMessage(taskID, startLine, false))
+
+	// Create cancellable context
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Create command with context for cancellation support
+	cmd := exec.CommandContext(ctx, interp.path, interp.args(absPath, false, false, opts.args)...)
+	cmd.Dir = filepath.Dir(absPath)
+	if opts.workDir != "" {
+		cmd.Dir = opts.workDir
+	}
+	cmd.Stdin = opts.stdinReader()
+	stdin, err := openStdin(opts, cmd)
 	if err != nil {
-		return fmt.Errorf("failed to create stdout pipe: %w", err)
+		cancel()
+		return withCode(models.ErrorCodeStartFailed, fmt.Errorf("failed to create stdin pipe: %w", err))
 	}
+	if env := opts.envVars(); env != nil {
+		cmd.Env = append(cmd.Environ(), env...)
+	}
+
+	// Set process group for killing child processes
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 
-	stderr, err := cmd.StderrPipe()
+	// Create pipes for stdout and stderr, or the pseudo-terminal the task runs on
+	pipes, err := te.newTaskPipes(opts, cmd)
 	if err != nil {
-		return fmt.Errorf("failed to create stderr pipe: %w", err)
+		cancel()
+		return withCode(models.ErrorCodeStartFailed, fmt.Errorf("failed to create output pipes: %w", err))
 	}
 
 	// Start the command
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start command: %w", err)
+	span := te.startSpan(taskID, spanStart)
+	err = cmd.Start()
+	pipes.started()
+	endSpan(span, err)
+	if err != nil {
+		pipes.close()
+		cancel()
+		return withCode(models.ErrorCodeStartFailed, fmt.Errorf("failed to start command: %w", err))
 	}
 
-	// Stream stdout
-	go te.streamOutput(taskID, stdout, false)
+	// Get process group ID (same as PID when Setpgid is true)
+	pgid, err := syscall.Getpgid(cmd.Process.Pid)
+	if err != nil {
+		pgid = cmd.Process.Pid // Fallback to PID if we can't get PGID
+	}
 
-	// Stream stderr
-	go te.streamOutput(taskID, stderr, true)
+	// Register running task, so CancelTask and ForceKillTask can reach it
+	runningTask := &RunningTask{
+		TaskID:    taskID,
+		Cmd:       cmd,
+		Cancel:    cancel,
+		Pgid:      pgid,
+		StartedAt: time.Now(),
+		stdin:     stdin,
+	}
+	te.registerTask(runningTask)
 
-	// Wait for command to complete
-	if err := cmd.Wait(); err != nil {
-		te.logCallback(models.LogMessage{
-			Type:    models.TypeLog,
-			TaskID:  taskID,
-			Line:    fmt.Sprintf("Command failed: %v", err),
-			IsError: true,
-		})
-		return err
+	// Ensure cleanup on exit
+	defer te.unregisterTask(taskID)
+
+	output := te.newTaskOutput(taskID, opts)
+	stopWatch := te.watchStall(output, te.stallPolicyOf(opts))
+	span = te.startSpan(taskID, spanStream)
+
+	// Stream stdout and stderr
+	pipes.stream(func(reader io.Reader, isError bool) { te.streamOutput(output, reader, isError) })
+
+	// Wait for command to complete, then for the rest of its output
+	err = cmd.Wait()
+	te.recordUsage(taskID, cmd.ProcessState)
+	pipes.wait(taskID)
+	stopWatch()
+	te.recordStderrTail(output)
+	te.flushSpinners(output)
+	te.recordOutputSize(output)
+	span.End()
+	if err != nil {
+		// Check if this was a cancellation (context cancelled by ForceKillTask, or SIGTERM from CancelTask)
+		if ctx.Err() == context.Canceled || runningTask.cancelRequested.Load() {
+			te.logCallback(models.NewLogMessage(taskID, "Task was cancelled", false))
+			return cancelled(err)
+		}
+
+		err = te.classifyFailure(output, cmd, err)
+		te.logCallback(models.NewLogMessage(taskID, fmt.Sprintf("Command failed: %v", err), true))
+		return withCode(models.ErrorCodeExitNonzero, err)
 	}
 
 	return nil
 }
 
 // ExecuteDynamic executes a Claude command with inline script content
-func (te *TaskExecutor) ExecuteDynamic(taskID int64, scriptContent string, skipPermissions bool, sessionMode string) error {
-	// Log execution start
-	te.logCallback(models.LogMessage{
-		Type:    models.TypeLog,
-		TaskID:  taskID,
-		Line:    fmt.Sprintf("Starting dynamic execution (skip permissions: %v)", skipPermissions),
-		IsError: false,
-	})
+func (te *TaskExecutor) ExecuteDynamic(taskID int64, scriptContent string, skipPermissions bool, sessionMode string, opts TaskOptions) error {
+	defer te.flushOutput(taskID)
+
+	// Log execution start, with the arguments, interpreter and checkout or directory of the task if it has them
+	startLine := fmt.Sprintf("Starting dynamic execution (skip permissions: %v)", skipPermissions) + describeArgs(opts.args)
+	interp := opts.interp
+	if interp.path != "" {
+		startLine += " under " + interp.name
+	} else {
+		interp = interpreter{name: models.InterpreterClaude, path: te.claudePath}
+	}
+	modelArgs := opts.modelArgs()
+	startLine += describeModel(modelArgs)
+	formatArgs := opts.outputFormatArgs()
+	persist := sessionMode == models.SessionModePersist && interp.name == models.InterpreterClaude
+	var resume []string
+	if persist {
+		resume = te.sessions.resumeArgs(opts.sessionKey)
+		startLine += describeSession(opts.sessionKey, resume)
+	}
+	workspace, hasWorkspace := te.checkouts.Lookup(taskID)
+	if hasWorkspace {
+		startLine += fmt.Sprintf(" in %s at %s", workspace.Path, workspace.Commit)
+	} else if opts.workDir != "" {
+		startLine += " in " + opts.workDir
+	}
+	te.logCallback(models.NewLogMessage(taskID, startLine, false))
 
 	// Create cancellable context
 	ctx, cancel := context.WithCancel(context.Background())
 
 	// Build command arguments (SECURITY: using args array to prevent command injection)
-	args := []string{}
-	if skipPermissions {
-		args = append(args, "--dangerously-skip-permissions")
-	}
-	args = append(args, scriptContent)
+	args := slices.Concat(resume, modelArgs, formatArgs, interp.args(scriptContent, true, skipPermissions, opts.args))
 
 	// Create command with context for cancellation support
-	cmd := exec.CommandContext(ctx, "claude", args...)
-
-	// Set process group for killing child processes
-	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
-
-	// Create pipes for stdout and stderr
-	stdout, err := cmd.StdoutPipe()
+	cmd := exec.CommandContext(ctx, interp.path, args...)
+	if hasWorkspace {
+		cmd.Dir = workspace.Path
+		cmd.Env = append(os.Environ(), workspace.Env()...)
+	} else if opts.workDir != "" {
+		cmd.Dir = opts.workDir
+	}
+	cmd.Stdin = opts.stdinReader()
+	stdin, err := openStdin(opts, cmd)
 	if err != nil {
 		cancel()
-		return fmt.Errorf("failed to create stdout pipe: %w", err)
+		return withCode(models.ErrorCodeStartFailed, fmt.Errorf("failed to create stdin pipe: %w", err))
+	}
+	if env := opts.envVars(); env != nil {
+		cmd.Env = append(cmd.Environ(), env...)
 	}
 
-	stderr, err := cmd.StderrPipe()
+	// Set process group for killing child processes
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	// Create pipes for stdout and stderr, or the pseudo-terminal the task runs on
+	pipes, err := te.newTaskPipes(opts, cmd)
 	if err != nil {
 		cancel()
-		return fmt.Errorf("failed to create stderr pipe: %w", err)
+		return withCode(models.ErrorCodeStartFailed, fmt.Errorf("failed to create output pipes: %w", err))
 	}
 
 	// Start the command
-	if err := cmd.Start(); err != nil {
+	span := te.startSpan(taskID, spanStart)
+	err = cmd.Start()
+	pipes.started()
+	endSpan(span, err)
+	if err != nil {
+		pipes.close()
 		cancel()
-		errMsg := fmt.Sprintf("Failed to start claude command: %v", err)
-		te.logCallback(models.LogMessage{
-			Type:    models.TypeLog,
-			TaskID:  taskID,
-			Line:    errMsg,
-			IsError: true,
-		})
-		return fmt.Errorf(errMsg)
+		errMsg := fmt.Sprintf("Failed to start %s command: %v", interp.name, err)
+		te.logCallback(models.NewLogMessage(taskID, errMsg, true))
+		return newTaskError(models.ErrorCodeStartFailed, "%s", errMsg)
 	}
 
 	// Get process group ID (same as PID when Setpgid is true)
@@ -202,6 +572,7 @@ func (te *TaskExecutor) ExecuteDynamic(taskID int64, scriptContent string, skipP
 		Cancel:    cancel,
 		Pgid:      pgid,
 		StartedAt: time.Now(),
+		stdin:     stdin,
 	}
 	te.registerTask(runningTask)
 
@@ -209,175 +580,197 @@ func (te *TaskExecutor) ExecuteDynamic(taskID int64, scriptContent string, skipP
 	defer te.unregisterTask(taskID)
 
 	// Stream stdout and stderr using the appropriate mode
-	if useRealtimeStreaming {
-		go te.streamOutputRealtime(taskID, stdout, false)
-		go te.streamOutputRealtime(taskID, stderr, true)
+	output := te.newTaskOutput(taskID, opts)
+	output.captureSession = persist
+	output.jsonEvents = formatArgs != nil
+	stopWatch := te.watchStall(output, te.stallPolicyOf(opts))
+	span = te.startSpan(taskID, spanStream)
+	if te.realtime {
+		pipes.stream(func(reader io.Reader, isError bool) { te.streamOutputRealtime(output, reader, isError) })
 	} else {
-		go te.streamOutput(taskID, stdout, false)
-		go te.streamOutput(taskID, stderr, true)
-	}
-
-	// Wait for command to complete
-	if err := cmd.Wait(); err != nil {
-		// Check if this was a cancellation
-		if ctx.Err() == context.Canceled {
-			te.logCallback(models.LogMessage{
-				Type:    models.TypeLog,
-				TaskID:  taskID,
-				Line:    "Task was cancelled",
-				IsError: false,
-			})
-			return fmt.Errorf("task cancelled")
+		pipes.stream(func(reader io.Reader, isError bool) { te.streamOutput(output, reader, isError) })
+	}
+
+	// Wait for command to complete, then for the rest of its output
+	err = cmd.Wait()
+	te.recordUsage(taskID, cmd.ProcessState)
+	pipes.wait(taskID)
+	stopWatch()
+	te.recordStderrTail(output)
+	te.flushSpinners(output)
+	te.recordOutputSize(output)
+	if persist {
+		te.recordSession(opts.sessionKey, output, err == nil)
+	}
+	span.End()
+	if err != nil {
+		// Check if this was a cancellation (context cancelled by ForceKillTask, or SIGTERM from CancelTask)
+		if ctx.Err() == context.Canceled || runningTask.cancelRequested.Load() {
+			te.logCallback(models.NewLogMessage(taskID, "Task was cancelled", false))
+			return cancelled(err)
 		}
 
-		te.logCallback(models.LogMessage{
-			Type:    models.TypeLog,
-			TaskID:  taskID,
-			Line:    fmt.Sprintf("Command failed: %v", err),
-			IsError: true,
-		})
-		return err
+		err = te.classifyFailure(output, cmd, err)
+		te.logCallback(models.NewLogMessage(taskID, fmt.Sprintf("Command failed: %v", err), true))
+		return withCode(models.ErrorCodeExitNonzero, err)
 	}
 
-	te.logCallback(models.LogMessage{
-		Type:    models.TypeLog,
-		TaskID:  taskID,
-		Line:    "Dynamic execution completed",
-		IsError: false,
-	})
+	te.logCallback(models.NewLogMessage(taskID, "Dynamic execution completed", false))
 
 	return nil
 }
 
-// streamOutput reads from a pipe and sends log messages
-// Uses a smaller buffer (256 bytes initial) for faster flushing compared to default 64KB
-func (te *TaskExecutor) streamOutput(taskID int64, reader io.Reader, isError bool) {
-	scanner := bufio.NewScanner(reader)
-
-	// Use smaller buffer for faster flushing (256 bytes initial, max 1MB)
-	// This reduces latency compared to the default 64KB buffer
-	buf := make([]byte, 256)
-	scanner.Buffer(buf, bufio.MaxScanTokenSize)
+// streamOutput reads from a pipe and sends log messages, until EOF
+// A line longer than maxLineChunk is sent as several LOG messages of about that many bytes each, split
+// between characters. A line redrawn with "\r" is coalesced by its progressLine.
+func (te *TaskExecutor) streamOutput(output *taskOutput, reader io.Reader, isError bool) {
+	taskID := output.taskID
+	// Reads return as soon as the pipe has data, so a large buffer does not delay lines
+	buffered := bufio.NewReaderSize(activityReader{reader, te, output}, maxLineChunk)
 
 	streamType := "stdout"
 	if isError {
 		streamType = "stderr"
 	}
-	fmt.Printf("[DEBUG] Starting %s stream for task %d\n", streamType, taskID)
+	te.debugf("Starting %s stream for task %d", streamType, taskID)
 
 	lineCount := 0
-	for scanner.Scan() {
-		line := scanner.Text()
+	send := func(line []byte) {
 		lineCount++
-		fmt.Printf("[DEBUG] Task %d %s line %d: %s\n", taskID, streamType, lineCount, line)
-
-		// Send log message
-		te.logCallback(models.LogMessage{
-			Type:    models.TypeLog,
-			TaskID:  taskID,
-			Line:    line,
-			IsError: isError,
-		})
-
-		// Check for rate limit pattern
-		if te.matcher.IsRateLimitDetected(line) {
-			fmt.Printf("[DEBUG] Rate limit detected in line: %s\n", line)
-			te.statusCallback(models.StatusUpdateMessage{
-				Type:   models.TypeStatusUpdate,
-				TaskID: taskID,
-				Status: models.StatusRateLimited,
-			})
-		}
-	}
+		te.debugf("Task %d %s line %d: %s", taskID, streamType, lineCount, line)
 
-	fmt.Printf("[DEBUG] Finished %s stream for task %d (read %d lines)\n", streamType, taskID, lineCount)
+		te.processLine(output, line, isError)
+	}
+	progress := output.progressOf(isError)
+	split := false // The previous chunk filled the buffer without ending its line
+	var carry []byte
+	for {
+		// readSegment avoids a copy; processLine must not retain the slice
+		chunk, err := readSegment(buffered)
+		if len(carry) > 0 {
+			// The start of a character the previous chunk was split in
+			chunk = append(carry, chunk...)
+			carry = nil
+		}
+		last := byte(0)
+		if len(chunk) > 0 {
+			last = chunk[len(chunk)-1]
+		}
+		switch {
+		case err == bufio.ErrBufferFull:
+			hold := incompleteTail(chunk)
+			carry = bytes.Clone(chunk[len(chunk)-hold:])
+			if line := chunk[:len(chunk)-hold]; len(line) > 0 {
+				send(line)
+			}
+		case last == '\r':
+			if line, ok := progress.redraw(chunk[:len(chunk)-1], time.Now()); ok {
+				send(line)
+			}
+		case last == '\n':
+			// A line of exactly maxLineChunk bytes leaves just its line ending for the next read
+			if line, ok := progress.end(chunk[:len(chunk)-1]); ok && (len(line) > 0 || !split) {
+				send(line)
+			}
+		case len(chunk) > 0:
+			// The output ended without a line ending
+			if line, ok := progress.end(chunk); ok {
+				send(line)
+			}
+		}
+		// A lone "\r" after a split chunk may begin the "\r\n" ending its line
+		split = err == bufio.ErrBufferFull || split && len(chunk) == 1 && last == '\r'
+		if err == nil || err == bufio.ErrBufferFull {
+			continue
+		}
+		if line, ok := progress.flush(); ok {
+			send(line)
+		}
 
-	if err := scanner.Err(); err != nil {
 		// Ignore "file already closed" and "EOF" errors - these are expected when command completes
-		errStr := err.Error()
-		if errStr != "EOF" && !strings.Contains(errStr, "file already closed") {
-			fmt.Printf("[DEBUG] Scanner error: %v\n", err)
-			te.logCallback(models.LogMessage{
-				Type:    models.TypeLog,
-				TaskID:  taskID,
-				Line:    fmt.Sprintf("Error reading output: %v", err),
-				IsError: true,
-			})
+		if err != io.EOF && !strings.Contains(err.Error(), "file already closed") {
+			te.debugf("Read error: %v", err)
+			te.logCallback(models.NewLogMessage(taskID, fmt.Sprintf("Error reading output: %v", err), true))
 		}
+		break
 	}
+
+	te.debugf("Finished %s stream for task %d (read %d lines)", streamType, taskID, lineCount)
 }
 
 // streamOutputRealtime provides character-level streaming for real-time output
 // Use this when immediate feedback is more important than line-buffered output
 // Enable with AAW_REALTIME_STREAMING=true environment variable
-func (te *TaskExecutor) streamOutputRealtime(taskID int64, reader io.Reader, isError bool) {
+// Output is decoded a whole character at a time: one split across reads is completed by the next, and
+// bytes that are not UTF-8 are sent as U+FFFD.
+func (te *TaskExecutor) streamOutputRealtime(output *taskOutput, reader io.Reader, isError bool) {
+	taskID := output.taskID
+	reader = activityReader{reader, te, output}
 	buf := make([]byte, 1024)
-	var lineBuffer strings.Builder
+	var lineBuffer bytes.Buffer
+	var pending []byte // Start of a character the previous read ended in
+	progress := output.progressOf(isError)
 
 	streamType := "stdout"
 	if isError {
 		streamType = "stderr"
 	}
-	fmt.Printf("[DEBUG] Starting realtime %s stream for task %d\n", streamType, taskID)
+	te.debugf("Starting realtime %s stream for task %d", streamType, taskID)
 
 	lineCount := 0
 	for {
 		n, err := reader.Read(buf)
-		if n > 0 {
-			for i := 0; i < n; i++ {
-				if buf[i] == '\n' {
-					// Send complete line
-					line := lineBuffer.String()
-					lineCount++
-					fmt.Printf("[DEBUG] Task %d %s line %d: %s\n", taskID, streamType, lineCount, line)
-
-					te.logCallback(models.LogMessage{
-						Type:    models.TypeLog,
-						TaskID:  taskID,
-						Line:    line,
-						IsError: isError,
-					})
-
-					// Check for rate limit in the line
-					if te.matcher.IsRateLimitDetected(line) {
-						fmt.Printf("[DEBUG] Rate limit detected in line: %s\n", line)
-						te.statusCallback(models.StatusUpdateMessage{
-							Type:   models.TypeStatusUpdate,
-							TaskID: taskID,
-							Status: models.StatusRateLimited,
-						})
-					}
-
-					lineBuffer.Reset()
+		data := append(pending, buf[:n]...)
+		hold := 0
+		if err == nil {
+			hold = incompleteTail(data)
+		}
+		for i := 0; i < len(data)-hold; {
+			r, size := rune(data[i]), 1
+			if r >= utf8.RuneSelf {
+				r, size = utf8.DecodeRune(data[i : len(data)-hold])
+			}
+			switch {
+			case r == '\r' || r == '\n':
+				// Send a complete line, or a rendering of one redrawn with "\r" when it is due
+				var line []byte
+				var ok bool
+				if r == '\r' {
+					line, ok = progress.redraw(lineBuffer.Bytes(), time.Now())
 				} else {
-					lineBuffer.WriteByte(buf[i])
+					line, ok = progress.end(lineBuffer.Bytes())
 				}
+				if ok {
+					lineCount++
+					te.debugf("Task %d %s line %d: %s", taskID, streamType, lineCount, line)
+
+					te.processLine(output, line, isError)
+				}
+
+				lineBuffer.Reset()
+			case r == utf8.RuneError && size == 1:
+				lineBuffer.WriteRune(utf8.RuneError)
+			default:
+				lineBuffer.Write(data[i : i+size])
 			}
+			i += size
 		}
+		pending = append(pending[:0], data[len(data)-hold:]...)
 
 		if err == io.EOF {
-			// Send remaining buffer content as final line
+			// Send remaining buffer content as final line, or the last rendering of a redrawn one
+			var line []byte
+			var ok bool
 			if lineBuffer.Len() > 0 {
-				line := lineBuffer.String()
+				line, ok = progress.end(lineBuffer.Bytes())
+			} else {
+				line, ok = progress.flush()
+			}
+			if ok {
 				lineCount++
-				fmt.Printf("[DEBUG] Task %d %s line %d (final): %s\n", taskID, streamType, lineCount, line)
-
-				te.logCallback(models.LogMessage{
-					Type:    models.TypeLog,
-					TaskID:  taskID,
-					Line:    line,
-					IsError: isError,
-				})
-
-				// Check for rate limit in the final line
-				if te.matcher.IsRateLimitDetected(line) {
-					fmt.Printf("[DEBUG] Rate limit detected in final line: %s\n", line)
-					te.statusCallback(models.StatusUpdateMessage{
-						Type:   models.TypeStatusUpdate,
-						TaskID: taskID,
-						Status: models.StatusRateLimited,
-					})
-				}
+				te.debugf("Task %d %s line %d (final): %s", taskID, streamType, lineCount, line)
+
+				te.processLine(output, line, isError)
 			}
 			break
 		}
@@ -392,7 +785,121 @@ func (te *TaskExecutor) streamOutputRealtime(taskID int64, reader io.Reader, isE
 		}
 	}
 
-	fmt.Printf("[DEBUG] Finished realtime %s stream for task %d (read %d lines)\n", streamType, taskID, lineCount)
+	te.debugf("Finished realtime %s stream for task %d (read %d lines)", streamType, taskID, lineCount)
+}
+
+// processLine handles a single line of task output: the stream-json events of a task with JSON output
+// are parsed (see processEvent), anything else is forwarded as it is. Spinner frames are dropped (see
+// dropSpinner), and lines past the task's output cap only counted (see recordOutputSize).
+// raw is only valid for the duration of the call (it aliases the reader's buffer)
+func (te *TaskExecutor) processLine(output *taskOutput, raw []byte, isError bool) {
+	if te.dropSpinner(output, raw) {
+		return
+	}
+	if output.captureSession {
+		output.noteSessionID(raw)
+	}
+	if !output.admit(len(raw)) {
+		return
+	}
+	if output.jsonEvents && !isError {
+		if event, ok := streamjson.Parse(raw); ok {
+			te.processEvent(output, event)
+			return
+		}
+	}
+	te.forwardLine(output, raw, isError)
+}
+
+// forwardLine sends a line of task output as a LOG message and runs pattern detection on it
+// Secrets are masked first so neither the LOG line nor detection metadata can leak them
+func (te *TaskExecutor) forwardLine(output *taskOutput, raw []byte, isError bool) {
+	taskID := output.taskID
+	lineNumber := output.lineCount.Add(1)
+
+	line := string(raw)
+	masked := false
+	if te.masker != nil {
+		maskedLine := te.masker.Mask(line)
+		masked = maskedLine != line
+		line = maskedLine
+	}
+	if len(output.sensitive) > 0 {
+		maskedLine := maskValues(line, output.sensitive)
+		masked = masked || maskedLine != line
+		line = maskedLine
+	}
+
+	severity := ""
+	if te.classifier != nil {
+		severity = te.classifier.Classify(line)
+	}
+
+	logMsg := models.NewOutputLog(taskID, line, isError)
+	logMsg.Severity = severity
+	te.logCallback(logMsg)
+	if isError {
+		output.stderr.add(line)
+	}
+
+	// Check for rate limit / usage limit patterns on the raw bytes; almost every
+	// line misses, and that path does not allocate
+	match, detected := te.matcher.MatchBytes(raw)
+	if !detected {
+		return
+	}
+	if masked {
+		// Re-run on the masked line so reported text and index never expose a secret
+		if match, detected = te.matcher.Match(line); !detected {
+			return
+		}
+	}
+	category := match.Category
+
+	// OOM output is only a suspicion until the task actually fails; it is
+	// resolved into a classification when the process exits
+	if category == matcher.CategoryOOM {
+		output.suspectOOM(fmt.Sprintf("output line %d: %s", lineNumber, match.Text))
+		return
+	}
+
+	statusMsg := models.NewStatusUpdate(taskID, models.StatusRateLimited)
+	statusMsg.Detection = &models.DetectionInfo{
+		Pattern:    match.Pattern,
+		Category:   string(match.Category),
+		Matched:    match.Text,
+		LineNumber: lineNumber,
+		Index:      match.Index,
+	}
+	var resetAt time.Time
+	if category == matcher.CategoryAuth {
+		statusMsg.Status = models.StatusAuthError
+		output.recordAuthFailure(fmt.Sprintf("output line %d: %s", lineNumber, match.Text))
+	}
+	if category == matcher.CategoryUsageLimit {
+		statusMsg.Status = models.StatusUsageLimited
+		if t, ok := matcher.ExtractResetTime(line, time.Now()); ok {
+			resetAt = t
+			statusMsg.ResetAt = t.Format(time.RFC3339)
+		}
+	}
+
+	te.debugf("%s detected (pattern %s) in line %d: %s", category, match.Pattern, lineNumber, line)
+	te.statusCallback(statusMsg)
+
+	te.mu.RLock()
+	onDetection := te.onDetection
+	te.mu.RUnlock()
+	if onDetection != nil {
+		onDetection(taskID, category, resetAt)
+	}
+}
+
+// SetDetectionHandler registers a callback invoked whenever a rate limit, usage limit or auth failure is detected
+func (te *TaskExecutor) SetDetectionHandler(fn func(taskID int64, category matcher.Category, resetAt time.Time)) {
+	te.mu.Lock()
+	defer te.mu.Unlock()
+	te.onDetection = fn
 }
 
 // registerTask adds a running task to the tracking map
@@ -400,7 +907,7 @@ func (te *TaskExecutor) registerTask(task *RunningTask) {
 	te.mu.Lock()
 	defer te.mu.Unlock()
 	te.runningTasks[task.TaskID] = task
-	fmt.Printf("[DEBUG] Registered task %d (pgid: %d)\n", task.TaskID, task.Pgid)
+	te.debugf("Registered task %d (pgid: %d)", task.TaskID, task.Pgid)
 }
 
 // unregisterTask removes a task from the tracking map
@@ -408,7 +915,7 @@ func (te *TaskExecutor) unregisterTask(taskID int64) {
 	te.mu.Lock()
 	defer te.mu.Unlock()
 	delete(te.runningTasks, taskID)
-	fmt.Printf("[DEBUG] Unregistered task %d\n", taskID)
+	te.debugf("Unregistered task %d", taskID)
 }
 
 // getRunningTask retrieves a running task by ID (thread-safe)
@@ -435,15 +942,20 @@ func (te *TaskExecutor) CancelTask(taskID int64) error {
 	}
 
 	fmt.Printf("[CANCEL] Sending SIGTERM to task %d (pgid: %d)\n", taskID, task.Pgid)
+	task.cancelRequested.Store(true)
 
 	// Send SIGTERM to the entire process group (negative pgid)
-	if err := syscall.Kill(-task.Pgid, syscall.SIGTERM); err != nil {
+	if err := task.kill(syscall.SIGTERM); err != nil {
 		// Process might already be gone
 		if err != syscall.ESRCH {
 			fmt.Printf("[CANCEL] Error sending SIGTERM to task %d: %v\n", taskID, err)
 			return fmt.Errorf("failed to send SIGTERM: %w", err)
 		}
 	}
+	// A stopped process group only acts on SIGTERM once continued
+	if task.suspended.Swap(false) {
+		task.kill(syscall.SIGCONT)
+	}
 
 	// ✅ FIX: Wait with verification - poll task state with proper timeout handling
 	done := make(chan bool, 1)
@@ -490,7 +1002,7 @@ func (te *TaskExecutor) ForceKillTask(taskID int64) error {
 	task.Cancel()
 
 	// Send SIGKILL to the entire process group (negative pgid)
-	if err := syscall.Kill(-task.Pgid, syscall.SIGKILL); err != nil {
+	if err := task.kill(syscall.SIGKILL); err != nil {
 		// Process might already be gone
 		if err == syscall.ESRCH {
 			fmt.Printf("[KILL] Task %d process already terminated\n", taskID)
@@ -0,0 +1,144 @@
+package executor
+
+import (
+	"os/exec"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/matcher"
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingExecutor creates an executor that records every emitted message
+func recordingExecutor() (*TaskExecutor, *messageRecorder) {
+	rec := &messageRecorder{}
+	te := NewTaskExecutor(rec.onLog, rec.onStatus)
+	return te, rec
+}
+
+// messageRecorder collects LOG and STATUS_UPDATE messages emitted by an executor
+type messageRecorder struct {
+	mu       sync.Mutex
+	logs     []models.LogMessage
+	statuses []models.StatusUpdateMessage
+}
+
+func (r *messageRecorder) onLog(msg models.LogMessage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.logs = append(r.logs, msg)
+}
+
+func (r *messageRecorder) onStatus(msg models.StatusUpdateMessage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statuses = append(r.statuses, msg)
+}
+
+func (r *messageRecorder) getLogs() []models.LogMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]models.LogMessage{}, r.logs...)
+}
+
+func (r *messageRecorder) getStatuses() []models.StatusUpdateMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]models.StatusUpdateMessage{}, r.statuses...)
+}
+
+// logLines returns the text of every recorded LOG message
+func logLines(rec *messageRecorder) []string {
+	var lines []string
+	for _, msg := range rec.getLogs() {
+		lines = append(lines, msg.Line)
+	}
+	return lines
+}
+
+// TestProcessLine_DetectionIncludesMatchMetadata verifies STATUS_UPDATE carries detection details
+func TestProcessLine_DetectionIncludesMatchMetadata(t *testing.T) {
+	te, rec := recordingExecutor()
+	output := &taskOutput{taskID: 7}
+
+	te.processLine(output, []byte("Processing item 1"), false)
+	te.processLine(output, []byte("Processing item 2"), true)
+	te.processLine(output, []byte("api_key=hunter22 got ERROR: 429 from upstream"), false)
+	te.flushOutput(7)
+
+	statuses := rec.getStatuses()
+	assert.Equal(t, 1, len(statuses), "Only the matching line should produce a status update")
+
+	status := statuses[0]
+	assert.Equal(t, models.StatusRateLimited, status.Status)
+	assert.NotNil(t, status.Detection, "Detection metadata should be attached")
+	assert.Equal(t, "http_429", status.Detection.Pattern)
+	assert.Equal(t, "RATE_LIMIT", status.Detection.Category)
+	assert.Equal(t, "ERROR: 429", status.Detection.Matched)
+	assert.Equal(t, int64(3), status.Detection.LineNumber, "Line number should count both streams")
+
+	logs := rec.getLogs()
+	assert.Equal(t, "api_key=*** got ERROR: 429 from upstream", logs[2].Line, "Forwarded line should be masked")
+	assert.Equal(t, status.Detection.Matched, logs[2].Line[status.Detection.Index:status.Detection.Index+len(status.Detection.Matched)],
+		"Index should locate the match within the forwarded (masked) line")
+}
+
+// TestExecuteDynamic_TagsStreams verifies task output is tagged with the stream it was printed on, and the
+// lines the runner writes about the task with system
+func TestExecuteDynamic_TagsStreams(t *testing.T) {
+	testutil.FakeClaude(t, `echo out; echo err >&2`)
+	te, rec := recordingExecutor()
+
+	assert.NoError(t, te.ExecuteDynamic(9, "hello", false, "", TaskOptions{}))
+	streams := make(map[string]string)
+	for _, msg := range rec.getLogs() {
+		streams[msg.Line] = msg.Stream
+		assert.Equal(t, msg.Stream == models.StreamStderr, msg.IsError, msg.Line)
+	}
+	assert.Equal(t, models.StreamStdout, streams["out"])
+	assert.Equal(t, models.StreamStderr, streams["err"])
+	assert.Equal(t, models.StreamSystem, streams["Dynamic execution completed"])
+}
+
+// TestProcessLine_MatchedTextIsMasked verifies detection metadata never carries unmasked secrets
+func TestProcessLine_MatchedTextIsMasked(t *testing.T) {
+	te, rec := recordingExecutor()
+	output := &taskOutput{taskID: 8}
+
+	te.processLine(output, []byte("Error: too many requests for token=abcdef123456"), false)
+	te.flushOutput(8)
+
+	statuses := rec.getStatuses()
+	assert.Equal(t, 1, len(statuses))
+	assert.NotContains(t, statuses[0].Detection.Matched, "abcdef123456", "Matched text must be masked")
+}
+
+// TestProcessLine_AuthErrorStatusAndClassification verifies auth detection end to end
+func TestProcessLine_AuthErrorStatusAndClassification(t *testing.T) {
+	te, rec := recordingExecutor()
+	te.oomEvidence = nil
+
+	var detected []string
+	te.SetDetectionHandler(func(taskID int64, category matcher.Category, resetAt time.Time) {
+		detected = append(detected, string(category))
+	})
+
+	output := te.newTaskOutput(5, TaskOptions{})
+	te.processLine(output, []byte("Invalid API key · Please run /login"), false)
+	te.flushOutput(5)
+
+	statuses := rec.getStatuses()
+	assert.Equal(t, 1, len(statuses))
+	assert.Equal(t, models.StatusAuthError, statuses[0].Status)
+	assert.Equal(t, []string{"AUTH"}, detected, "Detection should be forwarded to the pool")
+
+	cmd := exec.Command("/bin/sh", "-c", "exit 1")
+	err := te.classifyFailure(output, cmd, cmd.Run())
+
+	result := newTaskResult(5, err)
+	assert.Equal(t, models.ClassificationAuthError, result.Classification)
+	assert.Equal(t, "output line 1: Invalid API key", result.Evidence)
+}
@@ -0,0 +1,50 @@
+package executor
+
+import (
+	"slices"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/models"
+)
+
+// TaskOptions are the settings a task's EXECUTE gives it besides its script, built by the pool from the
+// message and passed to Execute, ExecuteDynamic or ExecuteRemote. They go with the task, so there is
+// nothing to forget once it is reported. The zero value runs a task with the runner's defaults.
+type TaskOptions struct {
+	sensitive   []string          // Variable values masked in the task's output
+	env         map[string]string // Environment variables set on top of the runner's
+	workDir     string            // Working directory; empty for the script's own, or the checkout
+	stdin       string            // Standard input; empty for none
+	interp      interpreter       // Interpreter the task runs under; zero for the default of its mode
+	args        []string          // Script arguments
+	pty         bool              // Run on a pseudo-terminal
+	combined    bool              // Merge stderr into stdout
+	interactive bool              // Keep stdin open for WriteStdin
+	sessionKey  string            // Session key of a PERSIST task; empty for the runner's own session
+	model       string            // Claude model; empty for claude's default
+	jsonOutput  bool              // Run claude with stream-json output
+	stall       stallPolicy       // Stall threshold and action
+	outputCap   int64             // Bytes of output lines the task may send; zero for the runner's cap
+}
+
+// newTaskOptions returns the options msg sets that need no checking; prepareScript, prepareEnv,
+// prepareWorkDir, prepareInterpreter and prepareModel add the others
+func newTaskOptions(msg models.ExecuteMessage) TaskOptions {
+	opts := TaskOptions{
+		stdin:       msg.StdinContent,
+		args:        slices.Clone(msg.Args),
+		pty:         msg.PTY,
+		combined:    msg.CombineOutput,
+		interactive: msg.Interactive,
+		jsonOutput:  msg.OutputFormat == models.OutputFormatJSON,
+		stall: stallPolicy{
+			threshold: time.Duration(msg.StallThresholdSeconds) * time.Second,
+			kill:      msg.StallAction == models.StallActionKill,
+		},
+		outputCap: msg.MaxOutputBytes,
+	}
+	if msg.SessionMode == models.SessionModePersist {
+		opts.sessionKey = msg.SessionKey
+	}
+	return opts
+}
@@ -0,0 +1,48 @@
+package executor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestNewTaskOptions verifies the options an EXECUTE sets without checks are taken from it, and that a
+// bare EXECUTE leaves the runner's defaults
+func TestNewTaskOptions(t *testing.T) {
+	args := []string{"a", "b"}
+	opts := newTaskOptions(models.ExecuteMessage{
+		TaskID:                1,
+		StdinContent:          "in",
+		Args:                  args,
+		PTY:                   true,
+		CombineOutput:         true,
+		Interactive:           true,
+		SessionMode:           models.SessionModePersist,
+		SessionKey:            "review",
+		OutputFormat:          models.OutputFormatJSON,
+		StallThresholdSeconds: 30,
+		StallAction:           models.StallActionKill,
+		MaxOutputBytes:        4096,
+	})
+	assert.Equal(t, "in", opts.stdin)
+	assert.Equal(t, args, opts.args)
+	assert.True(t, opts.pty)
+	assert.True(t, opts.combined)
+	assert.True(t, opts.interactive)
+	assert.Equal(t, "review", opts.sessionKey)
+	assert.True(t, opts.jsonOutput)
+	assert.Equal(t, stallPolicy{threshold: 30 * time.Second, kill: true}, opts.stall)
+	assert.Equal(t, int64(4096), opts.outputCap)
+	args[0] = "changed"
+	assert.Equal(t, "a", opts.args[0], "A copy, not the message's slice")
+
+	opts = newTaskOptions(models.ExecuteMessage{TaskID: 2})
+	assert.Nil(t, opts.stdinReader())
+	assert.Nil(t, opts.args)
+	assert.Nil(t, opts.outputFormatArgs(), "Text output by default")
+
+	opts = newTaskOptions(models.ExecuteMessage{TaskID: 3, SessionKey: "review"})
+	assert.Empty(t, opts.sessionKey, "Only a PERSIST task continues a session")
+}
@@ -0,0 +1,119 @@
+package executor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/template/parse"
+
+	"github.com/berno/aaw-runner/internal/config"
+	"github.com/berno/aaw-runner/internal/matcher"
+	"github.com/berno/aaw-runner/internal/models"
+)
+
+// MaxScriptContentBytes caps scriptContent once its variables are expanded
+// It is passed to claude as a single argument, and Linux refuses arguments over 128 KiB.
+const MaxScriptContentBytes = 100 << 10
+
+// expandVariables substitutes vars into the {{name}} placeholders of content
+// content is parsed with text/template, but nothing in it is executed: each placeholder must be a bare
+// name, and its value is inserted as is, so values are never expanded themselves. A name missing from
+// vars is an error, or expands to nothing with config.MissingKeyEmpty.
+func expandVariables(content string, vars map[string]string, missingKey string) (string, error) {
+	tree := parse.New("scriptContent")
+	tree.Mode = parse.SkipFuncCheck // Names are looked up in vars, not as template functions
+	trees := make(map[string]*parse.Tree)
+	if _, err := tree.Parse(content, "", "", trees); err != nil {
+		return "", err
+	}
+	if len(trees) > 1 {
+		return "", fmt.Errorf("scriptContent may not define templates")
+	}
+
+	var b strings.Builder
+	for _, node := range tree.Root.Nodes {
+		switch node := node.(type) {
+		case *parse.TextNode:
+			b.Write(node.Text)
+		case *parse.ActionNode:
+			name, ok := placeholder(node)
+			if !ok {
+				return "", fmt.Errorf("%s is not a {{name}} placeholder", node)
+			}
+			value, ok := vars[name]
+			if !ok && missingKey != config.MissingKeyEmpty {
+				return "", fmt.Errorf("no value for variable %q", name)
+			}
+			b.WriteString(value)
+		default:
+			return "", fmt.Errorf("%s is not a {{name}} placeholder", node)
+		}
+		if b.Len() > MaxScriptContentBytes {
+			break
+		}
+	}
+	return b.String(), nil
+}
+
+// placeholder returns the variable an action names, if it is nothing but a name
+func placeholder(action *parse.ActionNode) (string, bool) {
+	pipe := action.Pipe
+	if len(pipe.Decl) > 0 || len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return "", false
+	}
+	ident, ok := pipe.Cmds[0].Args[0].(*parse.IdentifierNode)
+	if !ok {
+		return "", false
+	}
+	return ident.Ident, true
+}
+
+// prepareScript returns the content a task runs: msg's scriptContent with its variables expanded
+// Values not marked public are added to the task's options, to be masked in its output.
+func (te *TaskExecutor) prepareScript(msg models.ExecuteMessage, opts *TaskOptions) (string, error) {
+	content := msg.ScriptContent
+	if msg.Variables != nil {
+		expanded, err := expandVariables(content, msg.Variables, te.missingKey)
+		if err != nil {
+			return "", te.invalidTask(msg.TaskID, err)
+		}
+		content = expanded
+		opts.sensitive = sensitiveValues(msg)
+	}
+	if len(content) > MaxScriptContentBytes {
+		return "", te.invalidTask(msg.TaskID, fmt.Errorf("scriptContent is over %d bytes", MaxScriptContentBytes))
+	}
+	return content, nil
+}
+
+// invalidTask reports why a task cannot run in its output and returns its failure
+func (te *TaskExecutor) invalidTask(taskID int64, err error) error {
+	te.logCallback(models.NewLogMessage(taskID, fmt.Sprintf("Invalid task: %v", err), true))
+	te.flushOutput(taskID)
+	return withCode(models.ErrorCodeInvalidTask, err)
+}
+
+// sensitiveValues lists the values of msg's variables that are not named in publicVariables
+// Longer values come first, so a value containing another one is masked whole.
+func sensitiveValues(msg models.ExecuteMessage) []string {
+	public := make(map[string]bool, len(msg.PublicVariables))
+	for _, name := range msg.PublicVariables {
+		public[name] = true
+	}
+	var values []string
+	for name, value := range msg.Variables {
+		if value != "" && !public[name] {
+			values = append(values, value)
+		}
+	}
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	return values
+}
+
+// maskValues replaces every occurrence of values in line with matcher.MaskReplacement
+func maskValues(line string, values []string) string {
+	for _, value := range values {
+		line = strings.ReplaceAll(line, value, matcher.MaskReplacement)
+	}
+	return line
+}
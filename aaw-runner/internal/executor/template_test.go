@@ -0,0 +1,115 @@
+package executor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/berno/aaw-runner/internal/config"
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestExpandVariables verifies placeholders are substituted verbatim, and anything else in braces is refused
+func TestExpandVariables(t *testing.T) {
+	vars := map[string]string{
+		"repo":   "berno/aaw",
+		"quoted": `it's "$HOME" & <b>; rm -rf /`,
+		"nested": "{{repo}}",
+		"multi":  "line one\nline two",
+	}
+	tests := []struct {
+		name       string
+		content    string
+		missingKey string
+		want       string
+		wantErr    string
+	}{
+		{name: "plain", content: "Review {{repo}} and {{ repo }}", want: "Review berno/aaw and berno/aaw"},
+		{name: "no placeholders", content: "Nothing to expand", want: "Nothing to expand"},
+		{name: "special characters", content: "echo {{quoted}}", want: `echo it's "$HOME" & <b>; rm -rf /`},
+		{name: "values are not expanded", content: "{{nested}}", want: "{{repo}}"},
+		{name: "multi-line value", content: "[{{multi}}]", want: "[line one\nline two]"},
+		{name: "missing key errors", content: "Fix {{issue}}", wantErr: `no value for variable "issue"`},
+		{name: "missing key empty", content: "Fix {{issue}}.", missingKey: config.MissingKeyEmpty, want: "Fix ."},
+		{name: "field", content: "{{.repo}}", wantErr: "{{.repo}} is not a {{name}} placeholder"},
+		{name: "function call", content: `{{printf "%s" repo}}`, wantErr: "is not a {{name}} placeholder"},
+		{name: "pipeline", content: "{{repo | html}}", wantErr: "is not a {{name}} placeholder"},
+		{name: "assignment", content: "{{$r := repo}}", wantErr: "is not a {{name}} placeholder"},
+		{name: "control flow", content: "{{if repo}}yes{{end}}", wantErr: "is not a {{name}} placeholder"},
+		{name: "define", content: `{{define "x"}}hi{{end}}`, wantErr: "may not define templates"},
+		{name: "syntax error", content: "Fix {{repo", wantErr: "unclosed action"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			missingKey := tt.missingKey
+			if missingKey == "" {
+				missingKey = config.MissingKeyError
+			}
+			got, err := expandVariables(tt.content, vars, missingKey)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// TestPrepareScript_SizeLimit verifies the size limit applies to scriptContent after expansion
+func TestPrepareScript_SizeLimit(t *testing.T) {
+	half := strings.Repeat("x", MaxScriptContentBytes/2)
+	tests := []struct {
+		name    string
+		msg     models.ExecuteMessage
+		wantErr bool
+	}{
+		{name: "at the limit", msg: models.ExecuteMessage{ScriptContent: half + half}},
+		{name: "over the limit", msg: models.ExecuteMessage{ScriptContent: half + half + "x"}, wantErr: true},
+		{name: "expanded at the limit", msg: models.ExecuteMessage{ScriptContent: "{{v}}{{v}}", Variables: map[string]string{"v": half}}},
+		{name: "expanded over the limit", msg: models.ExecuteMessage{ScriptContent: "{{v}}{{v}}{{v}}", Variables: map[string]string{"v": half}}, wantErr: true},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			te, rec := recordingExecutor()
+			tt.msg.TaskID = int64(i + 1)
+			content, err := te.prepareScript(tt.msg, &TaskOptions{})
+			if !tt.wantErr {
+				assert.NoError(t, err)
+				assert.Len(t, content, MaxScriptContentBytes)
+				return
+			}
+			assert.Equal(t, models.ErrorCodeInvalidTask, ErrorCode(err))
+			assert.Equal(t, []string{"Invalid task: scriptContent is over 102400 bytes"}, lines(rec))
+		})
+	}
+}
+
+// TestExecuteTask_Variables verifies a task runs its expanded prompt with sensitive values masked in its output
+func TestExecuteTask_Variables(t *testing.T) {
+	testutil.FakeClaude(t, `echo "prompt: $1"`)
+	te, rec := recordingExecutor()
+	var completed TaskResult
+	pool := NewExecutorPool(te, 1, nil, func(result TaskResult) { completed = result })
+
+	pool.Submit(models.ExecuteMessage{
+		Type:            models.TypeExecute,
+		TaskID:          1,
+		ScriptContent:   "Deploy {{service}} with token {{token}}",
+		Variables:       map[string]string{"service": "billing", "token": "hunter2hunter2"},
+		PublicVariables: []string{"service"},
+	})
+	pool.executeTask(0, <-pool.taskQueue)
+	assert.True(t, completed.Success, completed.Error)
+	assert.Contains(t, lines(rec), "prompt: Deploy billing with token ***")
+
+	pool.Submit(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 2, ScriptContent: "Fix {{issue}}", Variables: map[string]string{}})
+	pool.executeTask(0, <-pool.taskQueue)
+	assert.Equal(t, models.ErrorCodeInvalidTask, completed.ErrorCode)
+	assert.Contains(t, completed.Error, `no value for variable "issue"`)
+
+	pool.Submit(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 3, ScriptContent: "Keep {{braces}} as they are"})
+	pool.executeTask(0, <-pool.taskQueue)
+	assert.Contains(t, lines(rec), "prompt: Keep {{braces}} as they are", "Without variables scriptContent is not a template")
+}
@@ -0,0 +1,175 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// terminationTimeout bounds how long a kill waits for the task to finish reporting and its process group to die
+var terminationTimeout = 10 * time.Second
+
+// terminatedRetention is how long a terminated task ID is remembered to suppress duplicate KILLs
+const terminatedRetention = time.Hour
+
+// Termination is the verified outcome of a KILL_TASK
+type Termination struct {
+	Err       error // Error delivering the kill signal
+	Survivors []int // PIDs of the task's process group still alive after the timeout
+	Duplicate bool  // The task was already terminated by an earlier kill; nothing more should be reported
+}
+
+// asError returns the failure as an error (nil on success)
+func (t Termination) asError() error {
+	if t.Success() {
+		return nil
+	}
+	return errors.New(t.ErrorString())
+}
+
+// Success reports whether the task is verified dead and safe to delete
+func (t Termination) Success() bool {
+	return t.Err == nil && len(t.Survivors) == 0
+}
+
+// ErrorString describes why termination could not be verified (empty on success)
+func (t Termination) ErrorString() string {
+	var parts []string
+	if t.Err != nil {
+		parts = append(parts, t.Err.Error())
+	}
+	if len(t.Survivors) > 0 {
+		parts = append(parts, fmt.Sprintf("processes still alive after %s: %v", terminationTimeout, t.Survivors))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// TerminateTask force-kills a task on behalf of by and waits until it is verified dead
+// onSignalled runs as soon as the kill signal has been delivered (or failed), before verification,
+// so callers can acknowledge the request immediately. By the time TerminateTask returns the task's
+// completion has been reported and no process from its group is left, so the result is the last
+// word on the task. A repeated kill for the same task reports Duplicate instead of verifying again.
+// The attempt is reported to the termination observer once its outcome is known.
+func (p *ExecutorPool) TerminateTask(taskID int64, by Attribution, onSignalled func(err error)) Termination {
+	requestedAt := time.Now()
+	first := p.markTerminated(taskID)
+	pgid, hasGroup := p.executor.processGroup(taskID)
+
+	err := p.forceKill(taskID, by)
+	if onSignalled != nil {
+		onSignalled(err)
+	}
+	if !first {
+		log.Printf("[KILL] Task %d already terminated, not re-verifying", taskID)
+		term := Termination{Err: err, Duplicate: true}
+		p.observeTermination(TerminationKill, taskID, by, requestedAt, term.asError())
+		return term
+	}
+	if err != nil {
+		p.observeTermination(TerminationKill, taskID, by, requestedAt, err)
+		return Termination{Err: err}
+	}
+
+	deadline := time.Now().Add(terminationTimeout)
+	if !p.waitForCompletion(taskID, time.Until(deadline)) {
+		log.Printf("[KILL] Task %d did not report completion within %s", taskID, terminationTimeout)
+	}
+
+	var survivors []int
+	if hasGroup {
+		survivors = waitForGroupExit(pgid, time.Until(deadline))
+	}
+	if len(survivors) > 0 {
+		log.Printf("[KILL] Task %d process group %d still has live processes: %v", taskID, pgid, survivors)
+	} else {
+		log.Printf("[KILL] Task %d verified terminated", taskID)
+	}
+	term := Termination{Survivors: survivors}
+	p.observeTermination(TerminationKill, taskID, by, requestedAt, term.asError())
+	return term
+}
+
+// markTerminated records a kill for taskID, returning false if one was already recorded
+func (p *ExecutorPool) markTerminated(taskID int64) bool {
+	p.terminatedMu.Lock()
+	defer p.terminatedMu.Unlock()
+
+	now := time.Now()
+	for id, at := range p.terminated {
+		if now.Sub(at) > terminatedRetention {
+			delete(p.terminated, id)
+		}
+	}
+
+	if _, seen := p.terminated[taskID]; seen {
+		return false
+	}
+	p.terminated[taskID] = now
+	return true
+}
+
+// processGroup returns the local process group of a running task
+// Remote tasks have none: their processes cannot be listed from here.
+func (te *TaskExecutor) processGroup(taskID int64) (int, bool) {
+	task, exists := te.getRunningTask(taskID)
+	if !exists || task.Host != "" {
+		return 0, false
+	}
+	return task.Pgid, true
+}
+
+// waitForGroupExit polls until no live process remains in the group, returning the survivors on timeout
+func waitForGroupExit(pgid int, timeout time.Duration) []int {
+	deadline := time.Now().Add(timeout)
+	for {
+		members := processGroupMembers(pgid)
+		if len(members) == 0 || !time.Now().Before(deadline) {
+			return members
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// processGroupMembers lists live (non-zombie) processes in a process group
+// Falls back to a signal-0 probe of the group when /proc is unavailable
+func processGroupMembers(pgid int) []int {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		if syscall.Kill(-pgid, 0) == nil {
+			return []int{pgid}
+		}
+		return nil
+	}
+
+	var members []int
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+		// Format: "pid (comm) state ppid pgrp ..."; comm may contain spaces, so split after the last ')'
+		stat := string(data)
+		end := strings.LastIndexByte(stat, ')')
+		if end < 0 {
+			continue
+		}
+		fields := strings.Fields(stat[end+1:])
+		if len(fields) < 3 || fields[0] == "Z" {
+			continue
+		}
+		if group, err := strconv.Atoi(fields[2]); err == nil && group == pgid {
+			members = append(members, pid)
+		}
+	}
+	return members
+}
@@ -0,0 +1,134 @@
+package executor
+
+import (
+	"errors"
+	"os/exec"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// eventLog records the order in which callbacks fire
+type eventLog struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *eventLog) add(event string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event)
+}
+
+func (l *eventLog) indexOf(event string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, e := range l.events {
+		if e == event {
+			return i
+		}
+	}
+	return -1
+}
+
+// TestTerminateTask_VerifiesBeforeReporting verifies the kill is acknowledged first and termination reported last
+func TestTerminateTask_VerifiesBeforeReporting(t *testing.T) {
+	// The child sleep shares the task's process group and must die with it
+	testutil.FakeClaude(t, "sleep 30 & sleep 30")
+
+	events := &eventLog{}
+	te := NewTaskExecutor(func(models.LogMessage) {}, func(models.StatusUpdateMessage) {})
+	pool := NewExecutorPool(te, 1, nil, func(result TaskResult) {
+		events.add("completed:" + result.ErrorCode)
+	})
+	pool.Start()
+	defer pool.Stop()
+
+	assert.True(t, pool.Submit(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 20, ScriptContent: "hello"}))
+	waitForRegistration(t, te, 20)
+	pgid, ok := te.processGroup(20)
+	assert.True(t, ok)
+
+	term := pool.TerminateTask(20, byBackend, func(err error) {
+		assert.NoError(t, err)
+		events.add("signalled")
+	})
+	events.add("terminated")
+
+	assert.True(t, term.Success(), term.ErrorString())
+	assert.False(t, term.Duplicate)
+	assert.Empty(t, processGroupMembers(pgid), "No process from the task's group may survive")
+
+	signalled := events.indexOf("signalled")
+	completed := events.indexOf("completed:" + models.ErrorCodeCancelled)
+	terminated := events.indexOf("terminated")
+	assert.GreaterOrEqual(t, signalled, 0)
+	assert.GreaterOrEqual(t, completed, 0, "Killed task should complete as cancelled")
+	assert.Less(t, signalled, terminated, "Kill must be acknowledged before termination is reported")
+	assert.Less(t, completed, terminated, "Termination must be reported after completion")
+}
+
+// TestTerminateTask_DuplicateKill verifies a second kill does not report termination again
+func TestTerminateTask_DuplicateKill(t *testing.T) {
+	testutil.FakeClaude(t, "sleep 30")
+
+	te := NewTaskExecutor(func(models.LogMessage) {}, func(models.StatusUpdateMessage) {})
+	pool := NewExecutorPool(te, 1, nil, nil)
+	pool.Start()
+	defer pool.Stop()
+
+	assert.True(t, pool.Submit(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 21, ScriptContent: "hello"}))
+	waitForRegistration(t, te, 21)
+
+	first := pool.TerminateTask(21, byBackend, nil)
+	assert.True(t, first.Success())
+
+	signalled := false
+	second := pool.TerminateTask(21, byBackend, func(err error) {
+		signalled = true
+		assert.Error(t, err, "Task is no longer running")
+	})
+	assert.True(t, second.Duplicate)
+	assert.True(t, signalled, "Duplicate kills are still acknowledged")
+}
+
+// TestTerminateTask_NotRunning verifies killing an unknown task reports the failure
+func TestTerminateTask_NotRunning(t *testing.T) {
+	pool := newTestPool(1)
+
+	term := pool.TerminateTask(99, byBackend, nil)
+
+	assert.False(t, term.Success())
+	assert.False(t, term.Duplicate)
+	assert.Contains(t, term.ErrorString(), "not running")
+}
+
+// TestWaitForGroupExit_ReportsSurvivors verifies live group members are listed until they exit
+func TestWaitForGroupExit_ReportsSurvivors(t *testing.T) {
+	cmd := exec.Command("sleep", "30")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	assert.NoError(t, cmd.Start())
+	pgid := cmd.Process.Pid
+
+	survivors := waitForGroupExit(pgid, 100*time.Millisecond)
+	assert.Equal(t, []int{pgid}, survivors)
+
+	assert.NoError(t, syscall.Kill(-pgid, syscall.SIGKILL))
+	cmd.Wait()
+
+	assert.Empty(t, waitForGroupExit(pgid, time.Second))
+}
+
+// TestTermination_ErrorString verifies the TASK_TERMINATED error text
+func TestTermination_ErrorString(t *testing.T) {
+	assert.Equal(t, "", Termination{}.ErrorString())
+
+	term := Termination{Err: errors.New("failed to kill task 1"), Survivors: []int{101, 102}}
+	assert.Equal(t, "failed to kill task 1; processes still alive after 10s: [101 102]", term.ErrorString())
+	assert.False(t, term.Success())
+}
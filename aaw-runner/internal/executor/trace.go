@@ -0,0 +1,130 @@
+package executor
+
+import (
+	"context"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/matcher"
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Spans of the task lifecycle: one task span from submission to completion (so it covers the
+// queue wait), with a child for each backoff hold, the repo checkout, the process start, output
+// streaming and every cancel or kill
+const (
+	spanTask     = "aaw.task"
+	spanBackoff  = "aaw.task.backoff"
+	spanCheckout = "aaw.task.checkout"
+	spanStart    = "aaw.task.start"
+	spanStream   = "aaw.task.stream"
+	spanCancel   = "aaw.task.cancel"
+	spanKill     = "aaw.task.kill"
+	attrPrefix   = "aaw.task."
+	eventStarted = "aaw.task.started"
+	eventDetect  = "aaw.detection"
+)
+
+// defaultTracer reports to the global provider, a no-op unless tracing.Setup installed an exporter
+func defaultTracer() trace.Tracer {
+	return otel.Tracer(tracing.TracerName)
+}
+
+// SetTracerProvider sends the pool's and executor's spans to tp instead of the global provider
+func (p *ExecutorPool) SetTracerProvider(tp trace.TracerProvider) {
+	p.tracer = tp.Tracer(tracing.TracerName)
+	p.executor.tracer = p.tracer
+}
+
+// startTaskSpan opens the span of a newly submitted task, continuing the trace in its metadata
+func (p *ExecutorPool) startTaskSpan(msg models.ExecuteMessage) {
+	_, span := p.tracer.Start(tracing.ContextFromMetadata(msg.Metadata), spanTask,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attribute.Int64(attrPrefix+"id", msg.TaskID), attribute.String(attrPrefix+"engine", taskEngine(msg))))
+
+	p.spansMu.Lock()
+	defer p.spansMu.Unlock()
+	p.spans[msg.TaskID] = span
+}
+
+// taskSpan returns a pending task's span (a no-op span when there is none)
+func (p *ExecutorPool) taskSpan(taskID int64) trace.Span {
+	p.spansMu.Lock()
+	defer p.spansMu.Unlock()
+	if span, ok := p.spans[taskID]; ok {
+		return span
+	}
+	return trace.SpanFromContext(context.Background())
+}
+
+// taskContext returns a context carrying a pending task's span, for starting its child spans
+func (p *ExecutorPool) taskContext(taskID int64) context.Context {
+	return trace.ContextWithSpan(context.Background(), p.taskSpan(taskID))
+}
+
+// startChildSpan opens a child span of a task's span
+func (p *ExecutorPool) startChildSpan(taskID int64, name string, attrs ...attribute.KeyValue) trace.Span {
+	_, span := p.tracer.Start(p.taskContext(taskID), name, trace.WithAttributes(attrs...))
+	return span
+}
+
+// traceStarted marks on the task span that a worker picked the task up
+func (p *ExecutorPool) traceStarted(taskID int64, queued time.Duration) {
+	p.taskSpan(taskID).AddEvent(eventStarted, trace.WithAttributes(attribute.Float64("aaw.queue.seconds", queued.Seconds())))
+}
+
+// traceDetection records a rate limit, usage limit or auth detection on the task span
+func (p *ExecutorPool) traceDetection(taskID int64, category matcher.Category, resetAt time.Time) {
+	attrs := []attribute.KeyValue{attribute.String("aaw.detection.category", string(category))}
+	if !resetAt.IsZero() {
+		attrs = append(attrs, attribute.String("aaw.detection.reset_at", resetAt.Format(time.RFC3339)))
+	}
+	p.taskSpan(taskID).AddEvent(eventDetect, trace.WithAttributes(attrs...))
+}
+
+// endTaskSpan annotates a task's span with its outcome and ends it
+func (p *ExecutorPool) endTaskSpan(result TaskResult) {
+	p.spansMu.Lock()
+	span, ok := p.spans[result.TaskID]
+	delete(p.spans, result.TaskID)
+	p.spansMu.Unlock()
+	if !ok {
+		return
+	}
+
+	span.SetAttributes(
+		attribute.String(attrPrefix+"status", result.Status()),
+		attribute.Int(attrPrefix+"exit_code", result.ExitCode),
+	)
+	if !result.Success {
+		span.SetAttributes(attribute.String(attrPrefix+"error_code", result.ErrorCode))
+		if result.Classification != "" {
+			span.SetAttributes(attribute.String(attrPrefix+"classification", result.Classification))
+		}
+		span.SetStatus(codes.Error, result.Error)
+	}
+	span.End()
+}
+
+// startSpan opens a child span of a task's span from inside the executor
+func (te *TaskExecutor) startSpan(taskID int64, name string) trace.Span {
+	ctx := context.Background()
+	if te.traceContext != nil {
+		ctx = te.traceContext(taskID)
+	}
+	_, span := te.tracer.Start(ctx, name)
+	return span
+}
+
+// endSpan ends span, marking it failed when err is set
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,155 @@
+package executor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/matcher"
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// tracedPool creates a pool whose spans are recorded in memory
+func tracedPool(t *testing.T, onComplete func(TaskResult)) (*ExecutorPool, *tracetest.InMemoryExporter) {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+
+	te := NewTaskExecutor(func(models.LogMessage) {}, func(models.StatusUpdateMessage) {})
+	pool := NewExecutorPool(te, 1, nil, onComplete)
+	pool.SetTracerProvider(provider)
+	return pool, exporter
+}
+
+// findSpan returns the first recorded span with the given name
+func findSpan(exporter *tracetest.InMemoryExporter, name string) (tracetest.SpanStub, bool) {
+	for _, span := range exporter.GetSpans() {
+		if span.Name == name {
+			return span, true
+		}
+	}
+	return tracetest.SpanStub{}, false
+}
+
+// spanAttr returns the value of a span attribute
+func spanAttr(span tracetest.SpanStub, key string) attribute.Value {
+	for _, kv := range span.Attributes {
+		if string(kv.Key) == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+// TestTrace_TaskSpanContinuesBackendTrace verifies the task span joins the traceparent from EXECUTE metadata
+func TestTrace_TaskSpanContinuesBackendTrace(t *testing.T) {
+	testutil.FakeClaude(t, "echo working; exit 3")
+	pool, exporter := tracedPool(t, nil)
+	pool.Start()
+	defer pool.Stop()
+
+	assert.True(t, pool.Submit(models.ExecuteMessage{
+		Type:          models.TypeExecute,
+		TaskID:        40,
+		ScriptContent: "hello",
+		Metadata:      map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+	}))
+
+	var task tracetest.SpanStub
+	assert.Eventually(t, func() bool {
+		var ok bool
+		task, ok = findSpan(exporter, spanTask)
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", task.SpanContext.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", task.Parent.SpanID().String())
+	assert.Equal(t, int64(40), spanAttr(task, "aaw.task.id").AsInt64())
+	assert.Equal(t, EngineClaude, spanAttr(task, "aaw.task.engine").AsString())
+	assert.Equal(t, models.StatusFailed, spanAttr(task, "aaw.task.status").AsString())
+	assert.Equal(t, int64(3), spanAttr(task, "aaw.task.exit_code").AsInt64())
+	assert.Equal(t, models.ErrorCodeExitNonzero, spanAttr(task, "aaw.task.error_code").AsString())
+	if assert.Len(t, task.Events, 1) {
+		assert.Equal(t, eventStarted, task.Events[0].Name)
+	}
+
+	for _, name := range []string{spanStart, spanStream} {
+		child, ok := findSpan(exporter, name)
+		if assert.True(t, ok, "Missing %s span", name) {
+			assert.Equal(t, task.SpanContext.SpanID(), child.Parent.SpanID(), "%s should be a child of the task span", name)
+		}
+	}
+}
+
+// TestTrace_CancelSpan verifies cancelling a task records a cancel child span and a CANCELLED status
+func TestTrace_CancelSpan(t *testing.T) {
+	testutil.FakeClaude(t, "sleep 30")
+	completed := make(chan TaskResult, 1)
+	pool, exporter := tracedPool(t, func(result TaskResult) { completed <- result })
+	pool.Start()
+	defer pool.Stop()
+
+	assert.True(t, pool.Submit(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 41, ScriptContent: "hello"}))
+	waitForRegistration(t, pool.executor, 41)
+	assert.NoError(t, pool.CancelTask(41, byBackend))
+	<-completed
+
+	var task tracetest.SpanStub
+	assert.Eventually(t, func() bool {
+		var ok bool
+		task, ok = findSpan(exporter, spanTask)
+		return ok
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, models.StatusCancelled, spanAttr(task, "aaw.task.status").AsString())
+
+	cancel, ok := findSpan(exporter, spanCancel)
+	if assert.True(t, ok) {
+		assert.Equal(t, task.SpanContext.SpanID(), cancel.Parent.SpanID())
+	}
+}
+
+// TestTrace_DetectionAndBackoff verifies rate limits are recorded on the task span and backoff waits get child spans
+func TestTrace_DetectionAndBackoff(t *testing.T) {
+	pool, exporter := tracedPool(t, nil)
+	pool.rateLimitCooldown = 50 * time.Millisecond
+
+	msg := models.ExecuteMessage{Type: models.TypeExecute, TaskID: 42, ScriptContent: "hello"}
+	pool.startTaskSpan(msg)
+	pool.onDetection(42, matcher.CategoryRateLimit, time.Time{})
+	assert.True(t, pool.waitForBackoff(0, 42))
+	pool.endTaskSpan(newTaskResult(42, nil))
+
+	task, ok := findSpan(exporter, spanTask)
+	if !assert.True(t, ok) {
+		return
+	}
+	assert.Equal(t, models.StatusCompleted, spanAttr(task, "aaw.task.status").AsString())
+	if assert.Len(t, task.Events, 1) {
+		assert.Equal(t, eventDetect, task.Events[0].Name)
+		assert.Contains(t, task.Events[0].Attributes, attribute.String("aaw.detection.category", string(matcher.CategoryRateLimit)))
+	}
+
+	backoff, ok := findSpan(exporter, spanBackoff)
+	if assert.True(t, ok) {
+		assert.Equal(t, task.SpanContext.SpanID(), backoff.Parent.SpanID())
+	}
+}
+
+// TestTrace_NoProviderIsNoop verifies an untraced pool runs tasks without recording spans
+func TestTrace_NoProviderIsNoop(t *testing.T) {
+	pool := newTestPool(1)
+
+	pool.startTaskSpan(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 43, ScriptContent: "hello"})
+	assert.False(t, pool.taskSpan(43).IsRecording())
+	pool.endTaskSpan(newTaskResult(43, nil))
+
+	pool.spansMu.Lock()
+	defer pool.spansMu.Unlock()
+	assert.Empty(t, pool.spans)
+}
@@ -0,0 +1,39 @@
+package executor
+
+import (
+	"os"
+	"time"
+)
+
+// ResourceUsage is what a task's process consumed, read from its rusage once it exited
+// The figures cover the task process and the descendants it waited for; processes it left behind
+// are not counted. MaxRSSKb is the peak of the largest single process, not of the whole tree.
+type ResourceUsage struct {
+	UserCPU   time.Duration
+	SystemCPU time.Duration
+	MaxRSSKb  int64
+}
+
+// recordUsage keeps the final resource usage of a task's process for its completion report
+// Nothing is recorded on platforms without rusage.
+func (te *TaskExecutor) recordUsage(taskID int64, state *os.ProcessState) {
+	usage := resourceUsage(state)
+	if usage == nil {
+		return
+	}
+	te.usageMu.Lock()
+	defer te.usageMu.Unlock()
+	if te.usage == nil {
+		te.usage = make(map[int64]*ResourceUsage)
+	}
+	te.usage[taskID] = usage
+}
+
+// takeUsage returns and forgets the resource usage recorded for a task, nil if there is none
+func (te *TaskExecutor) takeUsage(taskID int64) *ResourceUsage {
+	te.usageMu.Lock()
+	defer te.usageMu.Unlock()
+	usage := te.usage[taskID]
+	delete(te.usage, taskID)
+	return usage
+}
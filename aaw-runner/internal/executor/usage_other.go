@@ -0,0 +1,10 @@
+//go:build !unix
+
+package executor
+
+import "os"
+
+// resourceUsage returns nil: there is no rusage on this platform
+func resourceUsage(state *os.ProcessState) *ResourceUsage {
+	return nil
+}
@@ -0,0 +1,42 @@
+package executor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestUsage_ReportedOnCompletion verifies the CPU a task burned, including in a child it waited for, reaches its result
+func TestUsage_ReportedOnCompletion(t *testing.T) {
+	testutil.FakeClaude(t, `sh -c 'end=$(($(date +%s) + 2)); while [ $(date +%s) -lt $end ]; do i=0; while [ $i -lt 1000 ]; do i=$((i+1)); done; done'`)
+	var completed TaskResult
+	te := NewTaskExecutor(func(models.LogMessage) {}, func(models.StatusUpdateMessage) {})
+	pool := NewExecutorPool(te, 1, nil, func(result TaskResult) { completed = result })
+
+	started := time.Now()
+	assert.True(t, pool.Submit(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 7, ScriptContent: "burn"}))
+	pool.executeTask(0, <-pool.taskQueue)
+	elapsed := time.Since(started)
+
+	if assert.NotNil(t, completed.Usage) {
+		assert.Greater(t, completed.Usage.UserCPU, 100*time.Millisecond, "The busy loop runs in a grandchild")
+		assert.LessOrEqual(t, completed.Usage.UserCPU+completed.Usage.SystemCPU, elapsed+100*time.Millisecond, "One busy process cannot outrun the clock")
+		assert.Greater(t, completed.Usage.MaxRSSKb, int64(0))
+	}
+	assert.Nil(t, te.takeUsage(7), "Usage is handed over once")
+}
+
+// TestUsage_NilWhenNeverRan verifies a task that never started reports no usage
+func TestUsage_NilWhenNeverRan(t *testing.T) {
+	var completed TaskResult
+	te := NewTaskExecutor(func(models.LogMessage) {}, func(models.StatusUpdateMessage) {})
+	pool := NewExecutorPool(te, 1, nil, func(result TaskResult) { completed = result })
+
+	assert.True(t, pool.Submit(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 8, Script: "testdata/missing.sh"}))
+	pool.executeTask(0, <-pool.taskQueue)
+	assert.False(t, completed.Success)
+	assert.Nil(t, completed.Usage)
+}
@@ -0,0 +1,31 @@
+//go:build unix
+
+package executor
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// resourceUsage converts the rusage of an exited process
+// ru_maxrss is in kilobytes except on macOS, which reports bytes.
+func resourceUsage(state *os.ProcessState) *ResourceUsage {
+	if state == nil {
+		return nil
+	}
+	ru, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok || ru == nil {
+		return nil
+	}
+	maxRSS := int64(ru.Maxrss)
+	if runtime.GOOS == "darwin" || runtime.GOOS == "ios" {
+		maxRSS /= 1024
+	}
+	return &ResourceUsage{
+		UserCPU:   time.Duration(ru.Utime.Nano()),
+		SystemCPU: time.Duration(ru.Stime.Nano()),
+		MaxRSSKb:  maxRSS,
+	}
+}
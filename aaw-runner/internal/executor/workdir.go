@@ -0,0 +1,29 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/berno/aaw-runner/internal/models"
+)
+
+// prepareWorkDir checks that the working directory msg sets exists, and adds it to the task's options
+// Nothing is run when it does not; the task fails with ErrorCodeStartFailed.
+func (te *TaskExecutor) prepareWorkDir(msg models.ExecuteMessage, opts *TaskOptions) error {
+	dir, err := filepath.Abs(msg.WorkingDir)
+	if err == nil {
+		var info os.FileInfo
+		if info, err = os.Stat(dir); err == nil && !info.IsDir() {
+			err = fmt.Errorf("%s is not a directory", dir)
+		}
+	}
+	if err != nil {
+		errMsg := fmt.Sprintf("Invalid working directory: %v", err)
+		te.logCallback(models.NewLogMessage(msg.TaskID, errMsg, true))
+		te.flushOutput(msg.TaskID)
+		return newTaskError(models.ErrorCodeStartFailed, "%s", errMsg)
+	}
+	opts.workDir = dir
+	return nil
+}
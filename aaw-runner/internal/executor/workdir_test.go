@@ -0,0 +1,56 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWorkingDir_RunsThere verifies inline and script-path tasks run in the workingDir of their EXECUTE,
+// and say so when they start
+func TestWorkingDir_RunsThere(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	assert.NoError(t, err)
+
+	testutil.FakeClaude(t, "pwd")
+	result, output := runPoolTask(t, models.ExecuteMessage{TaskID: 1, ScriptContent: "hello", WorkingDir: dir})
+	assert.True(t, result.Success, result.Error)
+	assert.Contains(t, output, dir)
+	assert.Contains(t, output, "Starting dynamic execution (skip permissions: false) in "+dir)
+
+	script := filepath.Join(t.TempDir(), "pwd.sh")
+	assert.NoError(t, os.WriteFile(script, []byte("pwd\n"), 0o644))
+	result, output = runPoolTask(t, models.ExecuteMessage{TaskID: 2, Script: script, WorkingDir: dir})
+	assert.True(t, result.Success, result.Error)
+	assert.Contains(t, output, dir, "Not the script's directory")
+	assert.Contains(t, output, "Starting execution: "+script+" in "+dir)
+}
+
+// TestWorkingDir_Invalid verifies a workingDir that is missing or not a directory fails the task before
+// anything starts
+func TestWorkingDir_Invalid(t *testing.T) {
+	testutil.FakeClaude(t, "echo started")
+	file := filepath.Join(t.TempDir(), "file")
+	assert.NoError(t, os.WriteFile(file, nil, 0o644))
+	cases := map[string]struct {
+		dir  string
+		want string
+	}{
+		"missing":   {dir: filepath.Join(t.TempDir(), "gone"), want: "no such file or directory"},
+		"not a dir": {dir: file, want: file + " is not a directory"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			result, output := runPoolTask(t, models.ExecuteMessage{TaskID: 3, ScriptContent: "hello", WorkingDir: tc.dir})
+			assert.False(t, result.Success)
+			assert.Equal(t, models.ErrorCodeStartFailed, result.ErrorCode)
+			assert.Contains(t, result.Error, "Invalid working directory")
+			assert.Contains(t, result.Error, tc.want)
+			assert.NotContains(t, output, "started", "Nothing ran")
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/berno/aaw-runner/internal/models"
+)
+
+// prepareWorkspace checks out msg's repo for the task, which then runs in the checkout
+// Nothing is run when the checkout fails; the task fails with ErrorCodeCheckout.
+func (te *TaskExecutor) prepareWorkspace(msg models.ExecuteMessage) error {
+	ref := msg.Ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+	te.logCallback(models.NewLogMessage(msg.TaskID, fmt.Sprintf("Checking out %s at %s", msg.Repo, ref), false))
+
+	span := te.startSpan(msg.TaskID, spanCheckout)
+	_, err := te.checkouts.Checkout(context.Background(), msg.TaskID, msg.Repo, msg.Ref, msg.Depth)
+	endSpan(span, err)
+	if err != nil {
+		te.logCallback(models.NewLogMessage(msg.TaskID, fmt.Sprintf("Checkout failed: %v", err), true))
+		te.flushOutput(msg.TaskID)
+		return withCode(models.ErrorCodeCheckout, err)
+	}
+	return nil
+}
+
+// removeWorkspace deletes a finished task's checkout, if it had one
+func (te *TaskExecutor) removeWorkspace(taskID int64) {
+	if err := te.checkouts.Remove(taskID); err != nil {
+		log.Printf("[Executor] Failed to remove the workspace of task %d: %v", taskID, err)
+	}
+}
@@ -0,0 +1,52 @@
+package executor
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/berno/aaw-runner/internal/config"
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestExecuteTask_Checkout verifies a task with a repo runs in its checkout, and the checkout goes with the task
+func TestExecuteTask_Checkout(t *testing.T) {
+	repo := t.TempDir()
+	for _, args := range [][]string{{"init", "--quiet"}, {"commit", "--quiet", "--allow-empty", "-m", "initial"}} {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=aaw", "-c", "user.email=aaw@example.com"}, args...)...)
+		cmd.Dir = repo
+		out, err := cmd.CombinedOutput()
+		assert.NoError(t, err, string(out))
+	}
+	head, err := exec.Command("git", "-C", repo, "rev-parse", "HEAD").Output()
+	assert.NoError(t, err)
+	commit := strings.TrimSpace(string(head))
+
+	testutil.FakeClaude(t, `echo "in $(pwd) at $(git rev-parse HEAD), env $AAW_CHECKOUT_PATH $AAW_CHECKOUT_COMMIT"`)
+	cfg := config.Default()
+	cfg.StateDir = t.TempDir()
+	rec := &messageRecorder{}
+	te := NewTaskExecutorWithConfig(cfg, rec.onLog, rec.onStatus)
+	var completed TaskResult
+	pool := NewExecutorPool(te, 1, nil, func(result TaskResult) { completed = result })
+
+	pool.Submit(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 1, ScriptContent: "build", Repo: repo})
+	pool.executeTask(0, <-pool.taskQueue)
+	assert.True(t, completed.Success, completed.Error)
+	workspace := filepath.Join(cfg.StateDir, "git", "workspaces", "task-1")
+	out := lines(rec)
+	assert.Contains(t, out, "Checking out "+repo+" at HEAD")
+	assert.Contains(t, out, "Starting dynamic execution (skip permissions: false) in "+workspace+" at "+commit)
+	assert.Contains(t, out, "in "+workspace+" at "+commit+", env "+workspace+" "+commit)
+	_, err = os.Stat(workspace)
+	assert.True(t, os.IsNotExist(err), "The checkout is removed with the task")
+
+	pool.Submit(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 2, ScriptContent: "build", Repo: repo, Ref: "no-such-ref"})
+	pool.executeTask(0, <-pool.taskQueue)
+	assert.Equal(t, models.ErrorCodeCheckout, completed.ErrorCode)
+	assert.Equal(t, 1, strings.Count(strings.Join(lines(rec), "\n"), "Starting dynamic execution"), "Nothing runs without its checkout")
+}
@@ -0,0 +1,142 @@
+// Package health serves the runner's liveness and readiness probes over HTTP
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os/exec"
+	"time"
+)
+
+// pingTimeout bounds how long /healthz waits for the runner to respond
+var pingTimeout = 2 * time.Second
+
+// Probe exposes the runner state the endpoints check
+type Probe interface {
+	Ping()             // Returns once the runner can make progress; blocking means it is wedged
+	Connected() bool   // WebSocket connected to the backend
+	PoolRunning() bool // Executor pool workers started and not stopped
+}
+
+// Report is the JSON body of both endpoints
+type Report struct {
+	Status   string   `json:"status"`             // "ok" or "unavailable"
+	Failures []string `json:"failures,omitempty"` // Why the probe failed
+}
+
+// Server answers /healthz and /readyz
+type Server struct {
+	probe      Probe
+	claudePath string                            // claude binary that must resolve for readiness
+	lookPath   func(file string) (string, error) // Resolves the claude binary, faked in tests
+	http       *http.Server
+}
+
+// NewServer creates a health server listening on addr (e.g. ":8081")
+func NewServer(addr, claudePath string, probe Probe) *Server {
+	s := &Server{probe: probe, claudePath: claudePath, lookPath: exec.LookPath}
+	s.http = &http.Server{Addr: addr, Handler: s.Handler(), ReadHeaderTimeout: 5 * time.Second}
+	return s
+}
+
+// Handler returns the HTTP handler serving both endpoints
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.handleHealthz)
+	mux.HandleFunc("/readyz", s.handleReadyz)
+	return mux
+}
+
+// Start begins serving in the background; the listener is opened before returning so bind errors surface here
+func (s *Server) Start() error {
+	ln, err := s.listen()
+	if err != nil {
+		return err
+	}
+	go s.serve(ln)
+	return nil
+}
+
+// listen opens the configured address
+func (s *Server) listen() (net.Listener, error) {
+	ln, err := net.Listen("tcp", s.http.Addr)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[HEALTH] Serving /healthz and /readyz on %s", ln.Addr())
+	return ln, nil
+}
+
+// serve answers probes on ln until Shutdown
+func (s *Server) serve(ln net.Listener) {
+	if err := s.http.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("[HEALTH] Server error: %v", err)
+	}
+}
+
+// Shutdown stops the server, waiting for in-flight probes until ctx expires
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.http.Shutdown(ctx)
+}
+
+// handleHealthz reports liveness: the process is up and its loops respond
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	var failures []string
+	if !s.ping() {
+		failures = append(failures, "runner did not respond within "+pingTimeout.String())
+	}
+	writeReport(w, failures)
+}
+
+// handleReadyz reports readiness: connected to the backend and able to run tasks
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	var failures []string
+	if !s.probe.Connected() {
+		failures = append(failures, "websocket not connected")
+	}
+	if !s.probe.PoolRunning() {
+		failures = append(failures, "executor pool not running")
+	}
+	if _, err := s.lookPath(s.claudePath); err != nil {
+		failures = append(failures, "claude binary not found: "+err.Error())
+	}
+	writeReport(w, failures)
+}
+
+// ping runs the probe's Ping with a timeout
+func (s *Server) ping() bool {
+	return PingWithin(s.probe, pingTimeout)
+}
+
+// PingWithin reports whether p.Ping returns within timeout
+// A Ping that never returns leaks one goroutine, which is acceptable for a wedged process
+func PingWithin(p interface{ Ping() }, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		p.Ping()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// writeReport answers 200 with status "ok", or 503 listing the failures
+func writeReport(w http.ResponseWriter, failures []string) {
+	report := Report{Status: "ok"}
+	code := http.StatusOK
+	if len(failures) > 0 {
+		report = Report{Status: "unavailable", Failures: failures}
+		code = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(report)
+}
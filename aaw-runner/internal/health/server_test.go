@@ -0,0 +1,117 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeProbe is a Probe with fixed answers
+type fakeProbe struct {
+	connected   bool
+	poolRunning bool
+	block       chan struct{} // Ping blocks until closed, when set
+}
+
+func (p *fakeProbe) Ping() {
+	if p.block != nil {
+		<-p.block
+	}
+}
+func (p *fakeProbe) Connected() bool   { return p.connected }
+func (p *fakeProbe) PoolRunning() bool { return p.poolRunning }
+
+// newTestServer creates a server whose claude lookup succeeds unless claudeErr is set
+func newTestServer(probe Probe, claudeErr error) *Server {
+	s := NewServer("127.0.0.1:0", "claude", probe)
+	s.lookPath = func(string) (string, error) { return "/usr/bin/claude", claudeErr }
+	return s
+}
+
+// get requests path from the server and decodes the report
+func get(t *testing.T, s *Server, path string) (int, Report) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+	var report Report
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	return rec.Code, report
+}
+
+// TestReadyz_Connected verifies a connected runner with a running pool is ready
+func TestReadyz_Connected(t *testing.T) {
+	s := newTestServer(&fakeProbe{connected: true, poolRunning: true}, nil)
+
+	code, report := get(t, s, "/readyz")
+
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, Report{Status: "ok"}, report)
+}
+
+// TestReadyz_Disconnected verifies each failed check is reported with 503
+func TestReadyz_Disconnected(t *testing.T) {
+	s := newTestServer(&fakeProbe{}, errors.New("executable file not found in $PATH"))
+
+	code, report := get(t, s, "/readyz")
+
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "unavailable", report.Status)
+	assert.Equal(t, []string{
+		"websocket not connected",
+		"executor pool not running",
+		"claude binary not found: executable file not found in $PATH",
+	}, report.Failures)
+}
+
+// TestHealthz_AliveWhileDisconnected verifies liveness does not depend on the backend connection
+func TestHealthz_AliveWhileDisconnected(t *testing.T) {
+	s := newTestServer(&fakeProbe{}, nil)
+
+	code, report := get(t, s, "/healthz")
+
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", report.Status)
+}
+
+// TestHealthz_Unresponsive verifies a wedged runner fails liveness
+func TestHealthz_Unresponsive(t *testing.T) {
+	defer func(old time.Duration) { pingTimeout = old }(pingTimeout)
+	pingTimeout = 50 * time.Millisecond
+
+	probe := &fakeProbe{block: make(chan struct{})}
+	defer close(probe.block)
+	s := newTestServer(probe, nil)
+
+	code, report := get(t, s, "/healthz")
+
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, []string{"runner did not respond within 50ms"}, report.Failures)
+}
+
+// TestServer_StartShutdown verifies the listener serves probes and stops on Shutdown
+func TestServer_StartShutdown(t *testing.T) {
+	s := newTestServer(&fakeProbe{connected: true, poolRunning: true}, nil)
+	ln, err := s.listen()
+	assert.NoError(t, err)
+	go s.serve(ln)
+
+	resp, err := http.Get("http://" + ln.Addr().String() + "/readyz")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, s.Shutdown(ctx))
+
+	_, err = http.Get("http://" + ln.Addr().String() + "/readyz")
+	assert.Error(t, err, "Server should be closed after Shutdown")
+}
@@ -0,0 +1,227 @@
+// Package journal keeps the messages the backend must not miss (TASK_COMPLETED and final STATUS_UPDATE)
+// in a write-ahead file until the backend acknowledges them, so a crash or a dropped connection at the
+// moment a task finishes does not leave the task running forever on the backend's side. Messages are
+// written before they are sent and re-sent until acknowledged, so the backend may see one twice; it
+// tells the copies apart by their message ID.
+package journal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FileName is the journal's name inside the state dir
+const FileName = "delivery.jsonl"
+
+// compactSlack is how many superseded lines the file may hold before it is rewritten
+// A variable so tests can lower it.
+var compactSlack = 256
+
+// Entry is a message waiting for the backend's acknowledgement
+type Entry struct {
+	ID      string          `json:"id"` // The message's messageId, which the backend's ACK names
+	Type    string          `json:"type,omitempty"`
+	TaskID  int64           `json:"taskId,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"` // The message as first sent
+}
+
+// line is one line of the file: an entry, or the acknowledgement of an earlier one
+type line struct {
+	Entry
+	Acked bool `json:"acked,omitempty"`
+}
+
+// Journal is the append-only file of unacknowledged messages. Appends are synced to disk before they
+// return; acknowledgements are not, since losing one only means a message is sent again. The file is
+// rewritten with just the pending entries once enough lines are superseded, and at most limit entries
+// are kept: the oldest gives way to a new one. A nil *Journal keeps nothing.
+type Journal struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	limit   int
+	pending []Entry // Oldest first
+	lines   int     // Lines in the file
+}
+
+// Open loads the journal at FileName in stateDir (created if missing), keeping at most limit entries
+// A line cut short by a crash is skipped; the file is rewritten with only the pending entries.
+func Open(stateDir string, limit int) (*Journal, error) {
+	if err := os.MkdirAll(stateDir, 0o700); err != nil {
+		return nil, err
+	}
+	j := &Journal{path: filepath.Join(stateDir, FileName), limit: limit}
+	if err := j.load(); err != nil {
+		return nil, err
+	}
+	if len(j.pending) > limit {
+		log.Printf("[JOURNAL] Dropping %d unacknowledged messages over the limit of %d", len(j.pending)-limit, limit)
+		j.pending = j.pending[len(j.pending)-limit:]
+	}
+	if err := j.compact(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// load reads the pending entries from the file, in the order they were appended
+func (j *Journal) load() error {
+	data, err := os.ReadFile(j.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for n := 1; scanner.Scan(); n++ {
+		var l line
+		if err := json.Unmarshal(scanner.Bytes(), &l); err != nil || l.ID == "" {
+			log.Printf("[JOURNAL] Skipping unreadable line %d of %s", n, j.path)
+			continue
+		}
+		j.remove(l.ID)
+		if !l.Acked {
+			j.pending = append(j.pending, l.Entry)
+		}
+	}
+	return scanner.Err()
+}
+
+// Append writes e to the journal, returning once it is on disk
+func (j *Journal) Append(e Entry) error {
+	if j == nil {
+		return nil
+	}
+	if e.ID == "" {
+		return fmt.Errorf("journal entry for task %d has no ID", e.TaskID)
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if len(j.pending) >= j.limit {
+		oldest := j.pending[0]
+		log.Printf("[JOURNAL] Limit of %d unacknowledged messages reached; dropping %s of task %d (%s)", j.limit, oldest.Type, oldest.TaskID, oldest.ID)
+		j.pending = j.pending[1:]
+		if err := j.write(line{Entry: Entry{ID: oldest.ID}, Acked: true}); err != nil {
+			return err
+		}
+	}
+	j.pending = append(j.pending, e)
+	if j.lines-len(j.pending) >= compactSlack {
+		return j.compact()
+	}
+	if err := j.write(line{Entry: e}); err != nil {
+		return err
+	}
+	return j.file.Sync()
+}
+
+// Ack marks the entry with the given ID delivered, reporting whether it was pending
+func (j *Journal) Ack(id string) (bool, error) {
+	if j == nil {
+		return false, nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if !j.remove(id) {
+		return false, nil
+	}
+	if j.lines-len(j.pending) >= compactSlack {
+		return true, j.compact()
+	}
+	return true, j.write(line{Entry: Entry{ID: id}, Acked: true})
+}
+
+// Pending returns the entries not acknowledged yet, oldest first
+func (j *Journal) Pending() []Entry {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]Entry(nil), j.pending...)
+}
+
+// Close closes the file
+func (j *Journal) Close() error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.file.Close()
+}
+
+// remove drops the pending entry with the given ID, reporting whether there was one; callers hold mu
+func (j *Journal) remove(id string) bool {
+	for i, e := range j.pending {
+		if e.ID == id {
+			j.pending = append(j.pending[:i], j.pending[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// write appends one line to the file; callers hold mu
+func (j *Journal) write(l line) error {
+	data, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	if _, err := j.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	j.lines++
+	return nil
+}
+
+// compact replaces the file, atomically, with one holding only the pending entries, and reopens it
+// for appending; callers hold mu (or have the only reference)
+func (j *Journal) compact() error {
+	var buf bytes.Buffer
+	for _, e := range j.pending {
+		data, err := json.Marshal(line{Entry: e})
+		if err != nil {
+			return err
+		}
+		buf.Write(append(data, '\n'))
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(j.path), "."+FileName+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), j.path); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(j.path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if j.file != nil {
+		j.file.Close()
+	}
+	j.file = file
+	j.lines = len(j.pending)
+	return nil
+}
@@ -0,0 +1,144 @@
+package journal
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// entry builds a journal entry for a TASK_COMPLETED of taskID
+func entry(id string, taskID int64) Entry {
+	return Entry{ID: id, Type: "TASK_COMPLETED", TaskID: taskID, Payload: json.RawMessage(`{"type":"TASK_COMPLETED"}`)}
+}
+
+// ids returns the IDs of entries, in order
+func ids(entries []Entry) []string {
+	var out []string
+	for _, e := range entries {
+		out = append(out, e.ID)
+	}
+	return out
+}
+
+// fileLines returns the lines of the journal file in dir
+func fileLines(t *testing.T, dir string) []string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	assert.NoError(t, err)
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+// TestJournal_SurvivesRestart verifies entries appended before a crash are pending again on the next
+// Open, minus those acknowledged
+func TestJournal_SurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	j, err := Open(dir, 10)
+	assert.NoError(t, err)
+	assert.NoError(t, j.Append(entry("a", 1)))
+	assert.NoError(t, j.Append(entry("b", 2)))
+	assert.NoError(t, j.Append(entry("c", 3)))
+	acked, err := j.Ack("b")
+	assert.True(t, acked)
+	assert.NoError(t, err)
+	acked, _ = j.Ack("unknown")
+	assert.False(t, acked)
+	// No Close: the process died
+
+	j, err = Open(dir, 10)
+	assert.NoError(t, err)
+	defer j.Close()
+	pending := j.Pending()
+	assert.Equal(t, []string{"a", "c"}, ids(pending))
+	assert.Equal(t, entry("c", 3), pending[1])
+	assert.Len(t, fileLines(t, dir), 2, "Open compacts the file")
+}
+
+// TestJournal_TornLine verifies a line cut short by a crash mid-write is skipped
+func TestJournal_TornLine(t *testing.T) {
+	dir := t.TempDir()
+	j, err := Open(dir, 10)
+	assert.NoError(t, err)
+	assert.NoError(t, j.Append(entry("a", 1)))
+	j.Close()
+	f, err := os.OpenFile(filepath.Join(dir, FileName), os.O_APPEND|os.O_WRONLY, 0o600)
+	assert.NoError(t, err)
+	f.WriteString(`{"id":"b","type":"TASK_COMPL`)
+	f.Close()
+
+	j, err = Open(dir, 10)
+	assert.NoError(t, err)
+	defer j.Close()
+	assert.Equal(t, []string{"a"}, ids(j.Pending()))
+	assert.NoError(t, j.Append(entry("c", 3)))
+	assert.Equal(t, []string{"a", "c"}, ids(j.Pending()))
+}
+
+// TestJournal_LeftoverCompaction verifies a compaction interrupted before its rename leaves the journal intact
+func TestJournal_LeftoverCompaction(t *testing.T) {
+	dir := t.TempDir()
+	j, err := Open(dir, 10)
+	assert.NoError(t, err)
+	assert.NoError(t, j.Append(entry("a", 1)))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "."+FileName+".123.tmp"), []byte("garbage"), 0o600))
+
+	j, err = Open(dir, 10)
+	assert.NoError(t, err)
+	defer j.Close()
+	assert.Equal(t, []string{"a"}, ids(j.Pending()))
+}
+
+// TestJournal_Bounded verifies the oldest entry gives way once the limit is reached, also after a restart
+func TestJournal_Bounded(t *testing.T) {
+	dir := t.TempDir()
+	j, err := Open(dir, 2)
+	assert.NoError(t, err)
+	for i, id := range []string{"a", "b", "c"} {
+		assert.NoError(t, j.Append(entry(id, int64(i+1))))
+	}
+	assert.Equal(t, []string{"b", "c"}, ids(j.Pending()))
+
+	j, err = Open(dir, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b", "c"}, ids(j.Pending()))
+	j.Close()
+
+	j, err = Open(dir, 1)
+	assert.NoError(t, err)
+	defer j.Close()
+	assert.Equal(t, []string{"c"}, ids(j.Pending()), "A lower limit keeps the newest")
+}
+
+// TestJournal_Compacts verifies the file is rewritten once enough lines are superseded
+func TestJournal_Compacts(t *testing.T) {
+	defer func(slack int) { compactSlack = slack }(compactSlack)
+	compactSlack = 4
+	dir := t.TempDir()
+	j, err := Open(dir, 100)
+	assert.NoError(t, err)
+	defer j.Close()
+
+	assert.NoError(t, j.Append(entry("keep", 1)))
+	for i := int64(2); i < 20; i++ {
+		id := string(rune('a' + i))
+		assert.NoError(t, j.Append(entry(id, i)))
+		_, err := j.Ack(id)
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, len(fileLines(t, dir)), 1+compactSlack)
+	}
+	assert.Equal(t, []string{"keep"}, ids(j.Pending()))
+}
+
+// TestJournal_Nil verifies a nil journal keeps nothing
+func TestJournal_Nil(t *testing.T) {
+	var j *Journal
+	assert.NoError(t, j.Append(entry("a", 1)))
+	acked, err := j.Ack("a")
+	assert.False(t, acked)
+	assert.NoError(t, err)
+	assert.Empty(t, j.Pending())
+	assert.NoError(t, j.Close())
+}
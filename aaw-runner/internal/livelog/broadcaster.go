@@ -0,0 +1,138 @@
+// Package livelog fans each running task's output out to local viewers (the admin API's log viewer)
+// Viewers never slow execution down: one that falls behind is dropped instead of being waited for.
+package livelog
+
+import (
+	"sync"
+)
+
+// subscriberBuffer is how many lines a viewer may fall behind, beyond the backlog replayed to it, before it is dropped
+const subscriberBuffer = 256
+
+// Broadcaster keeps the last lines of each running task and streams new ones to subscribers
+// A nil *Broadcaster records and streams nothing.
+type Broadcaster struct {
+	mu      sync.Mutex
+	backlog int
+	tasks   map[int64]*stream
+}
+
+// stream is one running task's recent output and viewers
+type stream struct {
+	lines []string // Oldest first, at most backlog
+	subs  map[*Subscription]struct{}
+}
+
+// Subscription delivers one task's lines to one viewer
+type Subscription struct {
+	lines   chan string
+	dropped bool // Set (under the broadcaster's lock) when lines was closed because the viewer fell behind
+	b       *Broadcaster
+	taskID  int64
+}
+
+// New creates a broadcaster replaying up to backlog lines to viewers joining a running task
+func New(backlog int) *Broadcaster {
+	return &Broadcaster{backlog: backlog, tasks: make(map[int64]*stream)}
+}
+
+// Start opens a task's stream; lines published before Start are ignored
+func (b *Broadcaster) Start(taskID int64) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.tasks[taskID]; !ok {
+		b.tasks[taskID] = &stream{subs: make(map[*Subscription]struct{})}
+	}
+}
+
+// Publish sends a line of a task's output to its viewers without ever blocking
+func (b *Broadcaster) Publish(taskID int64, line string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, ok := b.tasks[taskID]
+	if !ok {
+		return
+	}
+	if b.backlog > 0 {
+		if len(s.lines) == b.backlog {
+			s.lines = append(s.lines[:0], s.lines[1:]...)
+		}
+		s.lines = append(s.lines, line)
+	}
+	for sub := range s.subs {
+		select {
+		case sub.lines <- line:
+		default:
+			sub.dropped = true
+			close(sub.lines)
+			delete(s.subs, sub)
+		}
+	}
+}
+
+// Finish closes a task's stream, ending every subscription to it
+func (b *Broadcaster) Finish(taskID int64) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, ok := b.tasks[taskID]
+	if !ok {
+		return
+	}
+	for sub := range s.subs {
+		close(sub.lines)
+	}
+	delete(b.tasks, taskID)
+}
+
+// Subscribe follows a running task's output, starting with its backlog
+// Returns false if the task has no open stream (not started yet, or already finished).
+func (b *Broadcaster) Subscribe(taskID int64) (*Subscription, bool) {
+	if b == nil {
+		return nil, false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, ok := b.tasks[taskID]
+	if !ok {
+		return nil, false
+	}
+	sub := &Subscription{lines: make(chan string, len(s.lines)+subscriberBuffer), b: b, taskID: taskID}
+	for _, line := range s.lines {
+		sub.lines <- line
+	}
+	s.subs[sub] = struct{}{}
+	return sub, true
+}
+
+// Lines delivers the task's lines; it is closed when the task finishes or the viewer is dropped
+func (sub *Subscription) Lines() <-chan string {
+	return sub.lines
+}
+
+// Dropped reports whether Lines was closed because the viewer fell behind, rather than because the task finished
+func (sub *Subscription) Dropped() bool {
+	sub.b.mu.Lock()
+	defer sub.b.mu.Unlock()
+	return sub.dropped
+}
+
+// Close ends the subscription early (the viewer went away)
+func (sub *Subscription) Close() {
+	sub.b.mu.Lock()
+	defer sub.b.mu.Unlock()
+	if s, ok := sub.b.tasks[sub.taskID]; ok {
+		if _, subscribed := s.subs[sub]; subscribed {
+			delete(s.subs, sub)
+			close(sub.lines)
+		}
+	}
+}
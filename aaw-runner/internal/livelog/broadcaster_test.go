@@ -0,0 +1,76 @@
+package livelog
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// drain collects what is left on a subscription until it is closed
+func drain(sub *Subscription) []string {
+	var lines []string
+	for line := range sub.Lines() {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// TestBroadcaster_ReplaysBacklogThenStreams verifies a viewer gets the last lines, then new ones until the task finishes
+func TestBroadcaster_ReplaysBacklogThenStreams(t *testing.T) {
+	b := New(2)
+	b.Publish(1, "before start")
+	b.Start(1)
+	b.Publish(1, "one")
+	b.Publish(1, "two")
+	b.Publish(1, "three")
+
+	sub, ok := b.Subscribe(1)
+	assert.True(t, ok)
+	b.Publish(1, "four")
+	b.Publish(2, "other task")
+	b.Finish(1)
+
+	assert.Equal(t, []string{"two", "three", "four"}, drain(sub))
+	assert.False(t, sub.Dropped())
+
+	_, ok = b.Subscribe(1)
+	assert.False(t, ok, "Finished tasks cannot be followed")
+	_, ok = b.Subscribe(3)
+	assert.False(t, ok, "Unknown tasks cannot be followed")
+}
+
+// TestBroadcaster_DropsSlowViewer verifies a viewer that falls behind is cut off without blocking the publisher or other viewers
+func TestBroadcaster_DropsSlowViewer(t *testing.T) {
+	b := New(0)
+	b.Start(1)
+	slow, _ := b.Subscribe(1)
+	fast, _ := b.Subscribe(1)
+
+	var got []string
+	for i := 0; i < subscriberBuffer*3; i++ {
+		line := fmt.Sprintf("line %d", i)
+		b.Publish(1, line) // Must never block
+		got = append(got, <-fast.Lines())
+	}
+
+	assert.Len(t, drain(slow), subscriberBuffer, "Only what fit in its buffer was delivered")
+	assert.True(t, slow.Dropped())
+	assert.Len(t, got, subscriberBuffer*3)
+	assert.False(t, fast.Dropped())
+
+	fast.Close()
+	fast.Close() // Idempotent
+	b.Publish(1, "after close")
+	b.Finish(1)
+}
+
+// TestBroadcaster_Nil verifies a nil broadcaster ignores everything
+func TestBroadcaster_Nil(t *testing.T) {
+	var b *Broadcaster
+	b.Start(1)
+	b.Publish(1, "ignored")
+	b.Finish(1)
+	_, ok := b.Subscribe(1)
+	assert.False(t, ok)
+}
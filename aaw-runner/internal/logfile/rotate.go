@@ -0,0 +1,143 @@
+// Package logfile writes the runner's log to a file with size-based rotation
+package logfile
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Writer is an io.Writer that appends to a log file, rotating it by size
+// Safe for concurrent use. Writes never fail: if the file cannot be written (e.g. the disk is full)
+// file output is dropped with a one-time warning until Reopen succeeds, so logging never stalls the runner.
+type Writer struct {
+	path       string
+	maxSize    int64 // Rotate before a write would grow the file past this many bytes
+	maxBackups int   // Rotated files kept as path.1 (newest) .. path.N
+
+	mu      sync.Mutex
+	file    *os.File
+	size    int64
+	failed  bool      // File output disabled after an error
+	warnOut io.Writer // Where the one-time failure warning goes (stderr)
+}
+
+// Open opens (or creates) path for appending
+func Open(path string, maxSizeMB, maxBackups int) (*Writer, error) {
+	w := &Writer{
+		path:       path,
+		maxSize:    int64(maxSizeMB) * 1024 * 1024,
+		maxBackups: maxBackups,
+		warnOut:    os.Stderr,
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write appends p to the file, rotating first if it would exceed the size limit
+// Always reports success so an io.MultiWriter keeps feeding the other outputs
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.failed {
+		return len(p), nil
+	}
+	if w.size > 0 && w.size+int64(len(p)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			w.fail(err)
+			return len(p), nil
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	if err != nil {
+		w.fail(err)
+	}
+	return len(p), nil
+}
+
+// Reopen closes and reopens the file, for logrotate-style external rotation (SIGHUP)
+// It also re-enables file output after a failure
+func (w *Writer) Reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file != nil {
+		w.file.Close()
+	}
+	if err := w.open(); err != nil {
+		w.fail(err)
+		return err
+	}
+	w.failed = false
+	return nil
+}
+
+// Close closes the file
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.failed = true
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// open opens the log file for appending and records its current size
+func (w *Writer) open() error {
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+	w.file = file
+	w.size = info.Size()
+	return nil
+}
+
+// rotate shifts path -> path.1 -> ... -> path.N, dropping the oldest, and starts a fresh file
+func (w *Writer) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	w.file = nil
+
+	if w.maxBackups > 0 {
+		for i := w.maxBackups - 1; i >= 1; i-- {
+			os.Rename(w.backupPath(i), w.backupPath(i+1)) // Missing backups are fine
+		}
+		if err := os.Rename(w.path, w.backupPath(1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(w.path); err != nil {
+		return err
+	}
+	return w.open()
+}
+
+// backupPath returns the name of the i-th rotated file
+func (w *Writer) backupPath(i int) string {
+	return fmt.Sprintf("%s.%d", w.path, i)
+}
+
+// fail disables file output and warns once on stderr
+func (w *Writer) fail(err error) {
+	if w.failed {
+		return
+	}
+	w.failed = true
+	fmt.Fprintf(w.warnOut, "[LOG] Writing %s failed, dropping file output until reopened (SIGHUP): %v\n", w.path, err)
+}
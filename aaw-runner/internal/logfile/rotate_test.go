@@ -0,0 +1,118 @@
+package logfile
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// openSmall opens a writer with a byte-sized (rather than MB-sized) limit for tests
+func openSmall(t *testing.T, maxSize int64, maxBackups int) (*Writer, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "runner.log")
+	w, err := Open(path, 1, maxBackups)
+	assert.NoError(t, err)
+	w.maxSize = maxSize
+	t.Cleanup(func() { w.Close() })
+	return w, path
+}
+
+// readLines returns the lines of a file, or nil if it does not exist
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	assert.NoError(t, err)
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines
+}
+
+// TestWriter_RotatesBySize verifies files are shifted to .1, .2 and the oldest dropped
+func TestWriter_RotatesBySize(t *testing.T) {
+	w, path := openSmall(t, 20, 2)
+
+	for i := 0; i < 4; i++ {
+		fmt.Fprintf(w, "line %d -- 15 bytes\n", i)
+	}
+
+	assert.Equal(t, []string{"line 3 -- 15 bytes"}, readLines(t, path))
+	assert.Equal(t, []string{"line 2 -- 15 bytes"}, readLines(t, path+".1"))
+	assert.Equal(t, []string{"line 1 -- 15 bytes"}, readLines(t, path+".2"))
+	assert.Nil(t, readLines(t, path+".3"), "Only maxBackups rotated files are kept")
+}
+
+// TestWriter_ConcurrentWrites verifies no line is lost or interleaved across rotations
+func TestWriter_ConcurrentWrites(t *testing.T) {
+	w, path := openSmall(t, 4096, 1000)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				fmt.Fprintf(w, "goroutine %02d line %03d\n", g, i)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	matches, err := filepath.Glob(path + "*")
+	assert.NoError(t, err)
+	assert.Greater(t, len(matches), 1, "Should have rotated")
+
+	seen := make(map[string]bool)
+	for _, file := range matches {
+		for _, line := range readLines(t, file) {
+			assert.Regexp(t, `^goroutine \d{2} line \d{3}$`, line)
+			seen[line] = true
+		}
+	}
+	assert.Len(t, seen, 16*200)
+}
+
+// TestWriter_Reopen verifies a logrotate-style rename followed by Reopen starts a new file
+func TestWriter_Reopen(t *testing.T) {
+	w, path := openSmall(t, 1024, 1)
+	fmt.Fprintln(w, "before")
+
+	assert.NoError(t, os.Rename(path, path+".rotated"))
+	fmt.Fprintln(w, "still old file")
+	assert.NoError(t, w.Reopen())
+	fmt.Fprintln(w, "after")
+
+	assert.Equal(t, []string{"before", "still old file"}, readLines(t, path+".rotated"))
+	assert.Equal(t, []string{"after"}, readLines(t, path))
+}
+
+// TestWriter_FailureDegrades verifies write errors drop file output with a single warning
+func TestWriter_FailureDegrades(t *testing.T) {
+	w, path := openSmall(t, 1024, 1)
+	var warnings bytes.Buffer
+	w.warnOut = &warnings
+
+	w.file.Close() // Simulate the file becoming unwritable
+	for i := 0; i < 3; i++ {
+		n, err := w.Write([]byte("dropped\n"))
+		assert.NoError(t, err, "Writes never fail")
+		assert.Equal(t, 8, n)
+	}
+	assert.Equal(t, 1, strings.Count(warnings.String(), "dropping file output"))
+
+	assert.NoError(t, w.Reopen())
+	fmt.Fprintln(w, "recovered")
+	assert.Equal(t, []string{"recovered"}, readLines(t, path))
+}
@@ -0,0 +1,85 @@
+package matcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// PatternConfig is a named detection pattern supplied by the operator
+type PatternConfig struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
+
+// CategoryConfig customizes the patterns of one detection category
+type CategoryConfig struct {
+	Patterns []PatternConfig `json:"patterns,omitempty"` // Added to (or replacing) the built-in patterns
+	Exclude  []string        `json:"exclude,omitempty"`  // Lines matching any of these never trigger the category
+	Replace  bool            `json:"replace,omitempty"`  // Replace the built-in patterns and exclusions instead of extending them
+}
+
+// MatcherConfig customizes detection categories, keyed by category name (e.g. "AUTH")
+type MatcherConfig struct {
+	Categories map[Category]CategoryConfig `json:"categories"`
+}
+
+// LoadMatcherConfig reads a JSON matcher configuration from a file
+func LoadMatcherConfig(path string) (*MatcherConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read matcher patterns: %w", err)
+	}
+
+	var cfg MatcherConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse matcher patterns %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// NewPatternMatcherWithConfig creates a matcher from the built-in categories customized by cfg
+func NewPatternMatcherWithConfig(cfg MatcherConfig) (*PatternMatcher, error) {
+	categories := defaultCategories()
+
+	index := make(map[Category]int, len(categories))
+	for i, cp := range categories {
+		index[cp.category] = i
+	}
+
+	for category, custom := range cfg.Categories {
+		i, known := index[category]
+		if !known {
+			return nil, fmt.Errorf("unknown matcher category %q", category)
+		}
+
+		cp := &categories[i]
+		if custom.Replace {
+			cp.patterns = nil
+			cp.exclude = nil
+		}
+
+		for j, pc := range custom.Patterns {
+			re, err := regexp.Compile(pc.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("category %s pattern %d (%s): %w", category, j, pc.Name, err)
+			}
+			name := pc.Name
+			if name == "" {
+				name = fmt.Sprintf("custom_%d", j)
+			}
+			cp.patterns = append(cp.patterns, namedPattern{name: name, regex: re})
+		}
+
+		for j, ex := range custom.Exclude {
+			re, err := regexp.Compile(ex)
+			if err != nil {
+				return nil, fmt.Errorf("category %s exclusion %d: %w", category, j, err)
+			}
+			cp.exclude = append(cp.exclude, re)
+		}
+	}
+
+	return &PatternMatcher{categories: categories}, nil
+}
@@ -2,44 +2,192 @@ package matcher
 
 import (
 	"regexp"
-	"strings"
 )
 
-// PatternMatcher detects rate limit patterns in log lines
+// Category identifies the kind of condition a detection pattern recognizes
+type Category string
+
+const (
+	// CategoryRateLimit is a transient API rate limit that usually clears within seconds
+	CategoryRateLimit Category = "RATE_LIMIT"
+	// CategoryUsageLimit is subscription/usage quota exhaustion that lasts until the quota resets (hours)
+	CategoryUsageLimit Category = "USAGE_LIMIT"
+	// CategoryOOM is output suggesting the task (or one of its children) ran out of memory
+	CategoryOOM Category = "OOM"
+	// CategoryAuth is an authentication/credential failure that will affect every subsequent task
+	CategoryAuth Category = "AUTH"
+)
+
+// Match describes where and why a detection pattern fired
+type Match struct {
+	Pattern  string   // Name of the pattern that matched
+	Category Category // Category the pattern belongs to
+	Text     string   // Exact matched substring
+	Index    int      // Byte offset of Text within the original line
+}
+
+// namedPattern is a detection regex with a stable name reported in match metadata
+type namedPattern struct {
+	name  string
+	regex *regexp.Regexp
+}
+
+// categoryPatterns holds the patterns belonging to one detection category
+// A line matching any exclusion never triggers the category (false-positive control)
+type categoryPatterns struct {
+	category Category
+	patterns []namedPattern
+	exclude  []*regexp.Regexp
+}
+
+// PatternMatcher detects rate limit, usage limit, out-of-memory and authentication patterns in log lines
 type PatternMatcher struct {
-	patterns []*regexp.Regexp
+	// Categories are checked in order; more specific categories come first
+	categories []categoryPatterns
 }
 
 // NewPatternMatcher creates a new pattern matcher
 // Patterns are designed to match actual API rate limit errors, not casual mentions
 func NewPatternMatcher() *PatternMatcher {
-	return &PatternMatcher{
-		patterns: []*regexp.Regexp{
-			// HTTP 429 with error context (e.g., "Error: 429", "status: 429", "HTTP 429")
-			regexp.MustCompile(`(?i)(error|status|http|code)[:\s]+429`),
-			// Explicit rate limit error messages (with error/exceeded/hit context)
-			regexp.MustCompile(`(?i)rate\s+limit\s+(exceeded|hit|reached|error)`),
-			// Quota exceeded with error context
-			regexp.MustCompile(`(?i)(error|failed).*quota\s+exceeded`),
-			regexp.MustCompile(`(?i)quota\s+exceeded.*(error|failed)`),
-			// Too Many Requests as error message
-			regexp.MustCompile(`(?i)(error|status)[:\s].*too\s+many\s+requests`),
-			// API-specific rate limit messages
-			regexp.MustCompile(`(?i)rate_limit_exceeded`),
-			regexp.MustCompile(`(?i)RateLimitError`),
+	return &PatternMatcher{categories: defaultCategories()}
+}
+
+// testFixtureExclusions keeps status codes quoted in test code and fixtures from triggering detections
+func testFixtureExclusions() []*regexp.Regexp {
+	return []*regexp.Regexp{
+		// Test files and fixture paths (e.g. "auth_test.go:42", "testdata/401.json", "tests/fixtures/")
+		regexp.MustCompile(`(?i)(_test\.(go|py|js|ts)|\.(spec|test)\.(js|ts)x?|\btest_\w+\.py|fixtures?/|testdata/)`),
+		// Assertions about expected status codes (e.g. "expect(res.status).toBe(401)", "assert.Equal(t, 401, code)")
+		regexp.MustCompile(`(?i)\b(expect|assert)\w*[.(]`),
+	}
+}
+
+// defaultCategories returns the built-in detection categories in evaluation order
+func defaultCategories() []categoryPatterns {
+	return []categoryPatterns{
+		{
+			category: CategoryUsageLimit,
+			patterns: []namedPattern{
+				// "Claude usage limit reached. Your limit will reset at 7pm" / "Claude AI usage limit reached|1749924000"
+				{"claude_usage_limit", regexp.MustCompile(`(?i)claude(\s+ai)?(\s+(pro|max))?\s+usage\s+limit\s+reached`)},
+				// "You've reached your usage limit" / "You have hit your usage limit"
+				{"usage_limit_reached", regexp.MustCompile(`(?i)you('ve|\s+have)\s+(reached|hit)\s+your\s+(usage\s+)?limit`)},
+				// "5-hour limit reached ∙ resets 7pm" / "Weekly limit reached" / "Opus limit reached"
+				{"window_limit_reached", regexp.MustCompile(`(?i)(5-hour|weekly|opus|session)\s+limit\s+reached`)},
+				// "Your limit will reset at 7pm"
+				{"limit_reset_notice", regexp.MustCompile(`(?i)your\s+limit\s+will\s+reset\s+at`)},
+			},
+		},
+		{
+			category: CategoryRateLimit,
+			patterns: []namedPattern{
+				// HTTP 429 with error context (e.g., "Error: 429", "status: 429", "HTTP 429")
+				{"http_429", regexp.MustCompile(`(?i)(error|status|http|code)[:\s]+429`)},
+				// Explicit rate limit error messages (with error/exceeded/hit context)
+				{"rate_limit_phrase", regexp.MustCompile(`(?i)rate\s+limit\s+(exceeded|hit|reached|error)`)},
+				// Quota exceeded with error context
+				{"quota_exceeded", regexp.MustCompile(`(?i)(error|failed).*quota\s+exceeded`)},
+				{"quota_exceeded", regexp.MustCompile(`(?i)quota\s+exceeded.*(error|failed)`)},
+				// Too Many Requests as error message
+				{"too_many_requests", regexp.MustCompile(`(?i)(error|status)[:\s].*too\s+many\s+requests`)},
+				// API-specific rate limit messages
+				{"rate_limit_exceeded", regexp.MustCompile(`(?i)rate_limit_exceeded`)},
+				{"rate_limit_error", regexp.MustCompile(`(?i)RateLimitError`)},
+			},
 		},
+		{
+			category: CategoryOOM,
+			patterns: []namedPattern{
+				// bash job report for a SIGKILLed child: "line 3: 12345 Killed   python train.py"
+				// (bash pads the command column with several spaces, which keeps "Killed 3 workers" out)
+				{"shell_killed", regexp.MustCompile(`^\s*(.*:\s+)?(line\s+\d+:\s+)?(\d+\s+)?Killed(\s{2,}.*?)?\s*$`)},
+				// ENOMEM from libc / syscalls
+				{"enomem", regexp.MustCompile(`(?i)cannot\s+allocate\s+memory`)},
+				// Node.js / V8 heap exhaustion
+				{"v8_heap_limit", regexp.MustCompile(`(?i)reached\s+heap\s+limit`)},
+				{"js_heap_oom", regexp.MustCompile(`(?i)javascript\s+heap\s+out\s+of\s+memory`)},
+				// Go runtime and Python allocation failures
+				{"go_runtime_oom", regexp.MustCompile(`fatal error: runtime: out of memory`)},
+				{"python_memory_error", regexp.MustCompile(`^\s*MemoryError\b`)},
+				// C++ allocation failure
+				{"bad_alloc", regexp.MustCompile(`std::bad_alloc`)},
+				// Kernel OOM killer messages surfaced in output
+				{"kernel_oom_kill", regexp.MustCompile(`(?i)out\s+of\s+memory:\s+kill(ed)?\s+process`)},
+			},
+		},
+		{
+			category: CategoryAuth,
+			patterns: []namedPattern{
+				// "Invalid API key · Please run /login"
+				{"invalid_api_key", regexp.MustCompile(`(?i)invalid\s+(x-)?api[\s_-]?key`)},
+				{"run_login", regexp.MustCompile(`(?i)please\s+run\s+/login`)},
+				// HTTP 401 with error context (e.g. "API Error: 401", "status 401", "401 Unauthorized")
+				{"http_401", regexp.MustCompile(`(?i)((error|status|http|code)[:\s]+401\b|\b401\s+unauthorized)`)},
+				{"authentication_error", regexp.MustCompile(`(?i)"?authentication_error"?`)},
+				// "OAuth token has expired", "credentials expired", "Your credentials have expired"
+				{"credentials_expired", regexp.MustCompile(`(?i)(credentials?|oauth\s+token|session\s+token)\s+(has\s+|have\s+)?expired`)},
+			},
+			exclude: testFixtureExclusions(),
+		},
+	}
+}
+
+// MatchBytes returns details of the first pattern that matches the log line
+// Categories are evaluated in order, so more specific categories take precedence.
+// This is the hot path used by the streaming readers: a line that matches nothing
+// performs no allocations (match positions are only computed once a pattern fires)
+func (pm *PatternMatcher) MatchBytes(line []byte) (Match, bool) {
+	for i := range pm.categories {
+		cp := &pm.categories[i]
+		for _, p := range cp.patterns {
+			if !p.regex.Match(line) {
+				continue
+			}
+			if cp.excluded(line) {
+				break
+			}
+			loc := p.regex.FindIndex(line)
+			return Match{
+				Pattern:  p.name,
+				Category: cp.category,
+				Text:     string(line[loc[0]:loc[1]]),
+				Index:    loc[0],
+			}, true
+		}
 	}
+
+	return Match{}, false
+}
+
+// Match is the string form of MatchBytes, kept for compatibility
+func (pm *PatternMatcher) Match(line string) (Match, bool) {
+	return pm.MatchBytes([]byte(line))
+}
+
+// Detect returns the first category whose patterns match the log line
+func (pm *PatternMatcher) Detect(line string) (Category, bool) {
+	m, ok := pm.Match(line)
+	return m.Category, ok
 }
 
 // IsRateLimitDetected checks if the log line contains rate limit patterns
 func (pm *PatternMatcher) IsRateLimitDetected(line string) bool {
-	trimmedLine := strings.TrimSpace(line)
+	category, ok := pm.Detect(line)
+	return ok && category == CategoryRateLimit
+}
 
-	for _, pattern := range pm.patterns {
-		if pattern.MatchString(trimmedLine) {
+// IsUsageLimitDetected checks if the log line contains usage limit exhaustion patterns
+func (pm *PatternMatcher) IsUsageLimitDetected(line string) bool {
+	category, ok := pm.Detect(line)
+	return ok && category == CategoryUsageLimit
+}
+
+// excluded reports whether the line matches one of the category's exclusions
+func (cp *categoryPatterns) excluded(line []byte) bool {
+	for _, ex := range cp.exclude {
+		if ex.Match(line) {
 			return true
 		}
 	}
-
 	return false
 }
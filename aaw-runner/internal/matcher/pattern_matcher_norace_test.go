@@ -0,0 +1,29 @@
+//go:build !race
+
+package matcher
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The race detector instruments the matcher and allocates on its behalf, so allocations are only
+// counted in ordinary builds
+
+// TestMatchBytes_NoMatchDoesNotAllocate verifies the common no-match path is allocation free
+func TestMatchBytes_NoMatchDoesNotAllocate(t *testing.T) {
+	pm := NewPatternMatcher()
+	lines := [][]byte{
+		[]byte("    Compiling serde v1.0.197"),
+		[]byte("[INFO] Processing item 42 of 1000"),
+		[]byte("  ✓ renders the dashboard (35 ms)   "),
+	}
+
+	for _, line := range lines {
+		allocs := testing.AllocsPerRun(100, func() {
+			pm.MatchBytes(line)
+		})
+		assert.Zero(t, allocs, "No-match path should not allocate for %q", line)
+	}
+}
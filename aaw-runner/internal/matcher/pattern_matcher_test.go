@@ -0,0 +1,333 @@
+package matcher
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestDetect_CapturedMessages verifies categorization of real CLI/API output
+func TestDetect_CapturedMessages(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		category Category
+		detected bool
+	}{
+		// Usage limit exhaustion (hours of waiting)
+		{name: "Claude usage limit with reset", line: "Claude usage limit reached. Your limit will reset at 7pm (America/New_York).", category: CategoryUsageLimit, detected: true},
+		{name: "Claude AI usage limit epoch", line: "Claude AI usage limit reached|1749924000", category: CategoryUsageLimit, detected: true},
+		{name: "Claude Pro usage limit", line: "Claude Pro usage limit reached", category: CategoryUsageLimit, detected: true},
+		{name: "5-hour limit", line: "5-hour limit reached ∙ resets 7pm", category: CategoryUsageLimit, detected: true},
+		{name: "Weekly limit", line: "Weekly limit reached ∙ resets Mon 9am", category: CategoryUsageLimit, detected: true},
+		{name: "You've reached your usage limit", line: "You've reached your usage limit for Claude.", category: CategoryUsageLimit, detected: true},
+		{name: "Limit will reset", line: "  Your limit will reset at 3am  ", category: CategoryUsageLimit, detected: true},
+
+		// Transient API rate limits (seconds of waiting)
+		{name: "HTTP 429", line: "ERROR: 429 Rate limit exceeded - pausing", category: CategoryRateLimit, detected: true},
+		{name: "Status 429", line: "API Error: status 429", category: CategoryRateLimit, detected: true},
+		{name: "rate_limit_error type", line: `{"type":"error","error":{"type":"rate_limit_exceeded"}}`, category: CategoryRateLimit, detected: true},
+		{name: "RateLimitError", line: "anthropic.RateLimitError: Error code: 429", category: CategoryRateLimit, detected: true},
+		{name: "Too many requests", line: "Error: Too Many Requests", category: CategoryRateLimit, detected: true},
+
+		// Casual mentions
+		{name: "Plain output", line: "Processing item 42", detected: false},
+		{name: "Discussing limits", line: "We should add a usage limit to the API", detected: false},
+		{name: "Port number", line: "listening on :4290", detected: false},
+	}
+
+	pm := NewPatternMatcher()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			category, detected := pm.Detect(tt.line)
+			assert.Equal(t, tt.detected, detected, "Detection should match for %q", tt.line)
+			assert.Equal(t, tt.category, category, "Category should match for %q", tt.line)
+		})
+	}
+}
+
+// TestIsRateLimitDetected_ExcludesUsageLimits verifies the two categories don't collapse
+func TestIsRateLimitDetected_ExcludesUsageLimits(t *testing.T) {
+	pm := NewPatternMatcher()
+
+	usage := "Claude usage limit reached. Your limit will reset at 7pm"
+	assert.False(t, pm.IsRateLimitDetected(usage), "Usage limit should not be reported as rate limit")
+	assert.True(t, pm.IsUsageLimitDetected(usage))
+
+	rate := "ERROR: 429 Rate limit exceeded"
+	assert.True(t, pm.IsRateLimitDetected(rate))
+	assert.False(t, pm.IsUsageLimitDetected(rate), "Rate limit should not be reported as usage limit")
+}
+
+// TestExtractResetTime verifies parsing of the various reset time phrasings
+func TestExtractResetTime(t *testing.T) {
+	utc := time.UTC
+	now := time.Date(2025, 6, 14, 15, 30, 0, 0, utc) // 3:30pm UTC
+
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("tzdata not available")
+	}
+
+	tests := []struct {
+		name     string
+		line     string
+		expected time.Time
+		ok       bool
+	}{
+		{name: "Epoch suffix", line: "Claude AI usage limit reached|1749924000", expected: time.Unix(1749924000, 0), ok: true},
+		{name: "Later today pm", line: "Your limit will reset at 7pm", expected: time.Date(2025, 6, 14, 19, 0, 0, 0, utc), ok: true},
+		{name: "With minutes", line: "resets 7:30 pm", expected: time.Date(2025, 6, 14, 19, 30, 0, 0, utc), ok: true},
+		{name: "Already passed rolls to tomorrow", line: "resets 9am", expected: time.Date(2025, 6, 15, 9, 0, 0, 0, utc), ok: true},
+		{name: "Noon", line: "resets 12pm", expected: time.Date(2025, 6, 15, 12, 0, 0, 0, utc), ok: true},
+		{name: "Midnight", line: "resets 12am", expected: time.Date(2025, 6, 15, 0, 0, 0, 0, utc), ok: true},
+		{name: "24-hour clock", line: "limit will reset at 19:00", expected: time.Date(2025, 6, 14, 19, 0, 0, 0, utc), ok: true},
+		{name: "Named timezone", line: "Your limit will reset at 7pm (America/New_York).", expected: time.Date(2025, 6, 14, 19, 0, 0, 0, ny), ok: true},
+		{name: "Bare number is ambiguous", line: "resets 5", ok: false},
+		{name: "Invalid hour", line: "resets 13pm", ok: false},
+		{name: "No reset time", line: "Claude usage limit reached", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reset, ok := ExtractResetTime(tt.line, now)
+			assert.Equal(t, tt.ok, ok)
+			if tt.ok {
+				assert.True(t, tt.expected.Equal(reset), "expected %s, got %s", tt.expected, reset)
+			}
+		})
+	}
+}
+
+// TestMatch_ReturnsExactSubstringAndIndex verifies match metadata extraction
+func TestMatch_ReturnsExactSubstringAndIndex(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		pattern  string
+		category Category
+		text     string
+		index    int
+	}{
+		{name: "Match at start", line: "ERROR: 429 Rate limit exceeded", pattern: "http_429", category: CategoryRateLimit, text: "ERROR: 429", index: 0},
+		{name: "Match mid-line", line: "request failed: rate limit exceeded, retrying", pattern: "rate_limit_phrase", category: CategoryRateLimit, text: "rate limit exceeded", index: 16},
+		{name: "Leading whitespace preserved in index", line: "   RateLimitError raised", pattern: "rate_limit_error", category: CategoryRateLimit, text: "RateLimitError", index: 3},
+		{name: "Usage limit", line: "› Claude usage limit reached. Your limit will reset at 7pm", pattern: "claude_usage_limit", category: CategoryUsageLimit, text: "Claude usage limit reached", index: 4},
+	}
+
+	pm := NewPatternMatcher()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, ok := pm.Match(tt.line)
+			assert.True(t, ok, "Line should match")
+			assert.Equal(t, tt.pattern, m.Pattern, "Pattern name should match")
+			assert.Equal(t, tt.category, m.Category, "Category should match")
+			assert.Equal(t, tt.text, m.Text, "Matched text should be the exact substring")
+			assert.Equal(t, tt.index, m.Index, "Index should be the byte offset in the original line")
+			assert.Equal(t, tt.text, tt.line[m.Index:m.Index+len(m.Text)], "Index should locate the matched text")
+		})
+	}
+
+	_, ok := pm.Match("nothing to see here")
+	assert.False(t, ok, "Non-matching line should not produce a match")
+}
+
+// TestDetect_OOMOutput verifies out-of-memory output is categorized as OOM
+func TestDetect_OOMOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		pattern string
+	}{
+		{name: "bash Killed report", line: "./train.sh: line 12: 48213 Killed                  python3 train.py", pattern: "shell_killed"},
+		{name: "Bare Killed", line: "Killed", pattern: "shell_killed"},
+		{name: "ENOMEM", line: "fork: Cannot allocate memory", pattern: "enomem"},
+		{name: "Node heap", line: "FATAL ERROR: Reached heap limit Allocation failed - JavaScript heap out of memory", pattern: "v8_heap_limit"},
+		{name: "Node heap short", line: "JavaScript heap out of memory", pattern: "js_heap_oom"},
+		{name: "Go runtime", line: "fatal error: runtime: out of memory", pattern: "go_runtime_oom"},
+		{name: "Python", line: "MemoryError", pattern: "python_memory_error"},
+		{name: "C++", line: "terminate called after throwing an instance of 'std::bad_alloc'", pattern: "bad_alloc"},
+	}
+
+	pm := NewPatternMatcher()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, ok := pm.Match(tt.line)
+			assert.True(t, ok, "Line should match")
+			assert.Equal(t, CategoryOOM, m.Category)
+			assert.Equal(t, tt.pattern, m.Pattern)
+		})
+	}
+
+	for _, line := range []string{"Process killed by user request", "Killed 3 stale workers", "no MemoryErrors found"} {
+		_, ok := pm.Match(line)
+		assert.False(t, ok, "Casual mention should not match: %q", line)
+	}
+}
+
+// TestDetect_AuthErrors verifies credential failures are categorized as AUTH
+func TestDetect_AuthErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		pattern string
+	}{
+		{name: "Invalid API key", line: "Invalid API key · Please run /login", pattern: "invalid_api_key"},
+		{name: "Run login", line: "Please run /login to authenticate", pattern: "run_login"},
+		{name: "API error 401", line: `API Error: 401 {"type":"error","error":{"type":"authentication_error"}}`, pattern: "http_401"},
+		{name: "401 Unauthorized", line: "HTTP/1.1 401 Unauthorized", pattern: "http_401"},
+		{name: "authentication_error", line: `{"error":{"type":"authentication_error","message":"invalid x-api-key"}}`, pattern: "invalid_api_key"},
+		{name: "OAuth expired", line: "OAuth token has expired. Please obtain a new token", pattern: "credentials_expired"},
+		{name: "Credentials expired", line: "Your credentials have expired", pattern: "credentials_expired"},
+	}
+
+	pm := NewPatternMatcher()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, ok := pm.Match(tt.line)
+			assert.True(t, ok, "Line should match")
+			assert.Equal(t, CategoryAuth, m.Category)
+			assert.Equal(t, tt.pattern, m.Pattern)
+		})
+	}
+}
+
+// TestDetect_AuthExclusions verifies 401s in test output and fixtures do not trigger AUTH
+func TestDetect_AuthExclusions(t *testing.T) {
+	pm := NewPatternMatcher()
+
+	for _, line := range []string{
+		"--- FAIL: TestLogin (0.00s) auth_test.go:42: status 401 unexpected",
+		"loading testdata/http_401.json: status 401",
+		"    expect(res.status).toBe(401) // status 401",
+		"assert.Equal(t, 401, resp.StatusCode) status: 401",
+		"tests/fixtures/unauthorized.txt: HTTP 401 Unauthorized",
+		"Listing 401 files",
+	} {
+		_, ok := pm.Match(line)
+		assert.False(t, ok, "Line should be excluded: %q", line)
+	}
+}
+
+// TestNewPatternMatcherWithConfig verifies operator-supplied patterns and exclusions
+func TestNewPatternMatcherWithConfig(t *testing.T) {
+	pm, err := NewPatternMatcherWithConfig(MatcherConfig{
+		Categories: map[Category]CategoryConfig{
+			CategoryAuth: {
+				Patterns: []PatternConfig{{Name: "vault_denied", Pattern: `(?i)vault: permission denied`}},
+				Exclude:  []string{`(?i)mock server`},
+			},
+			CategoryRateLimit: {
+				Replace:  true,
+				Patterns: []PatternConfig{{Pattern: `SLOW DOWN`}},
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	m, ok := pm.Match("vault: permission denied for secret/data/app")
+	assert.True(t, ok, "Custom pattern should be added")
+	assert.Equal(t, "vault_denied", m.Pattern)
+	assert.Equal(t, CategoryAuth, m.Category)
+
+	_, ok = pm.Match("Invalid API key (mock server)")
+	assert.False(t, ok, "Custom exclusion should suppress built-in pattern")
+
+	_, ok = pm.Match("Invalid API key · Please run /login")
+	assert.True(t, ok, "Built-in patterns should be kept when not replaced")
+
+	m, ok = pm.Match("upstream said SLOW DOWN")
+	assert.True(t, ok)
+	assert.Equal(t, "custom_0", m.Pattern, "Unnamed patterns get a generated name")
+
+	_, ok = pm.Match("ERROR: 429 Rate limit exceeded")
+	assert.False(t, ok, "Replaced category should drop built-in patterns")
+
+	_, err = NewPatternMatcherWithConfig(MatcherConfig{Categories: map[Category]CategoryConfig{"BOGUS": {}}})
+	assert.Error(t, err, "Unknown category should be rejected")
+
+	_, err = NewPatternMatcherWithConfig(MatcherConfig{Categories: map[Category]CategoryConfig{
+		CategoryAuth: {Patterns: []PatternConfig{{Name: "bad", Pattern: `(`}}},
+	}})
+	assert.Error(t, err, "Invalid regex should be rejected")
+}
+
+// benchmarkCorpus is a realistic slice of task output: mostly ordinary build and test
+// chatter, with the occasional line a detection pattern fires on
+var benchmarkCorpus = []string{
+	"    Compiling serde v1.0.197",
+	"ok  \tgithub.com/berno/aaw-runner/internal/executor\t0.012s",
+	"[INFO] Processing item 42 of 1000",
+	"npm WARN deprecated inflight@1.0.6: This module is not supported",
+	"  ✓ renders the dashboard (35 ms)",
+	"Reading file src/components/TaskList.tsx",
+	"Step 3/12 : RUN go build -o /app ./...",
+	"2024-06-14T10:22:31Z DEBUG fetching page 7",
+	"",
+	"error: could not find module 'foo'",
+	"Error: 429 Too Many Requests",
+	"Claude usage limit reached. Your limit will reset at 7pm (America/New_York).",
+}
+
+// legacyMatch reproduces the previous string-based implementation for comparison
+func legacyMatch(pm *PatternMatcher, line string) (Match, bool) {
+	trimmedLine := strings.TrimSpace(line)
+	for _, cp := range pm.categories {
+		for _, p := range cp.patterns {
+			loc := p.regex.FindStringIndex(trimmedLine)
+			if loc == nil {
+				continue
+			}
+			return Match{Pattern: p.name, Category: cp.category, Text: trimmedLine[loc[0]:loc[1]], Index: loc[0]}, true
+		}
+	}
+	return Match{}, false
+}
+
+// corpusBytes returns the benchmark corpus as the streaming readers see it
+func corpusBytes() [][]byte {
+	corpus := make([][]byte, len(benchmarkCorpus))
+	for i, line := range benchmarkCorpus {
+		corpus[i] = []byte(line)
+	}
+	return corpus
+}
+
+// TestMatchBytes_AgreesWithMatch verifies the byte and string forms report identical results
+func TestMatchBytes_AgreesWithMatch(t *testing.T) {
+	pm := NewPatternMatcher()
+	for _, line := range benchmarkCorpus {
+		fromString, okString := pm.Match(line)
+		fromBytes, okBytes := pm.MatchBytes([]byte(line))
+		assert.Equal(t, okString, okBytes, line)
+		assert.Equal(t, fromString, fromBytes, line)
+	}
+}
+
+// BenchmarkMatch_Legacy measures the old path: scanner.Text() copy, TrimSpace, then string matching
+func BenchmarkMatch_Legacy(b *testing.B) {
+	pm := NewPatternMatcher()
+	corpus := corpusBytes()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, line := range corpus {
+			legacyMatch(pm, string(line))
+		}
+	}
+}
+
+// BenchmarkMatchBytes measures matching directly on the reader's bytes
+func BenchmarkMatchBytes(b *testing.B) {
+	pm := NewPatternMatcher()
+	corpus := corpusBytes()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, line := range corpus {
+			pm.MatchBytes(line)
+		}
+	}
+}
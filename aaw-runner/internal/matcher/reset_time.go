@@ -0,0 +1,78 @@
+package matcher
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// "Claude AI usage limit reached|1749924000" (epoch seconds emitted by the CLI)
+	resetEpochPattern = regexp.MustCompile(`(?i)limit\s+reached\|(\d{10})`)
+	// "resets 7pm", "will reset at 7:30 pm", "resets at 19:00 (America/New_York)"
+	resetClockPattern = regexp.MustCompile(`(?i)resets?\s+(?:at\s+)?(\d{1,2})(?::(\d{2}))?\s*(am|pm)?(?:\s*\(([A-Za-z_]+(?:/[A-Za-z_+\-0-9]+)*)\))?`)
+)
+
+// ExtractResetTime parses the quota reset time out of a usage limit message
+// Clock times without a date resolve to their next occurrence after now, in the
+// timezone named by the message when it is loadable and in now's location otherwise
+func ExtractResetTime(line string, now time.Time) (time.Time, bool) {
+	if m := resetEpochPattern.FindStringSubmatch(line); m != nil {
+		sec, err := strconv.ParseInt(m[1], 10, 64)
+		if err == nil {
+			return time.Unix(sec, 0), true
+		}
+	}
+
+	m := resetClockPattern.FindStringSubmatch(line)
+	if m == nil {
+		return time.Time{}, false
+	}
+
+	hour, _ := strconv.Atoi(m[1])
+	minute := 0
+	if m[2] != "" {
+		minute, _ = strconv.Atoi(m[2])
+	}
+	meridiem := strings.ToLower(m[3])
+
+	// A bare number ("resets 5") is too ambiguous to act on
+	if m[2] == "" && meridiem == "" {
+		return time.Time{}, false
+	}
+
+	switch meridiem {
+	case "am":
+		if hour < 1 || hour > 12 {
+			return time.Time{}, false
+		}
+		if hour == 12 {
+			hour = 0
+		}
+	case "pm":
+		if hour < 1 || hour > 12 {
+			return time.Time{}, false
+		}
+		if hour != 12 {
+			hour += 12
+		}
+	}
+	if hour > 23 || minute > 59 {
+		return time.Time{}, false
+	}
+
+	loc := now.Location()
+	if m[4] != "" {
+		if named, err := time.LoadLocation(m[4]); err == nil {
+			loc = named
+		}
+	}
+
+	local := now.In(loc)
+	reset := time.Date(local.Year(), local.Month(), local.Day(), hour, minute, 0, 0, loc)
+	if !reset.After(local) {
+		reset = reset.AddDate(0, 0, 1)
+	}
+	return reset, true
+}
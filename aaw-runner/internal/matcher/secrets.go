@@ -0,0 +1,84 @@
+package matcher
+
+import (
+	"regexp"
+	"strings"
+)
+
+// MaskReplacement is substituted for detected secret values
+const MaskReplacement = "***"
+
+// secretPattern masks capture group "secret" (or the whole match when absent)
+type secretPattern struct {
+	keyword string // Case-insensitive prefilter literal
+	regex   *regexp.Regexp
+}
+
+// SecretMasker redacts credentials from task output before it leaves the runner
+type SecretMasker struct {
+	patterns []secretPattern
+}
+
+// NewSecretMasker creates a masker with the built-in credential patterns
+func NewSecretMasker() *SecretMasker {
+	return &SecretMasker{
+		patterns: []secretPattern{
+			// Anthropic / OpenAI style API keys
+			{"sk-", regexp.MustCompile(`(?P<secret>sk-(ant-)?[A-Za-z0-9_\-]{16,})`)},
+			// AWS access key IDs
+			{"akia", regexp.MustCompile(`(?P<secret>AKIA[0-9A-Z]{16})`)},
+			// Authorization: Bearer <token>
+			{"bearer", regexp.MustCompile(`(?i)bearer\s+(?P<secret>[A-Za-z0-9._~+/\-]{8,}=*)`)},
+			// key=value / key: value assignments of sensitive names
+			{"", regexp.MustCompile(`(?i)(api[_-]?key|token|secret|password|passwd)["']?\s*[=:]\s*["']?(?P<secret>[^\s"',;]+)`)},
+		},
+	}
+}
+
+// Mask returns the line with secret values replaced by MaskReplacement
+// Lines without any candidate keyword are returned unchanged without allocating
+func (sm *SecretMasker) Mask(line string) string {
+	for _, p := range sm.patterns {
+		if p.keyword != "" && !containsFold(line, p.keyword) {
+			continue
+		}
+		if p.keyword == "" && !containsSensitiveName(line) {
+			continue
+		}
+		line = maskGroup(p.regex, line)
+	}
+	return line
+}
+
+// containsSensitiveName is the prefilter for the key=value pattern
+func containsSensitiveName(line string) bool {
+	for _, kw := range []string{"key", "token", "secret", "passw"} {
+		if containsFold(line, kw) {
+			return true
+		}
+	}
+	return false
+}
+
+// maskGroup replaces the "secret" capture group of every match
+func maskGroup(re *regexp.Regexp, line string) string {
+	group := re.SubexpIndex("secret")
+	matches := re.FindAllStringSubmatchIndex(line, -1)
+	if matches == nil {
+		return line
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[2*group], m[2*group+1]
+		if start < 0 {
+			continue
+		}
+		b.WriteString(line[last:start])
+		b.WriteString(MaskReplacement)
+		last = end
+	}
+	b.WriteString(line[last:])
+	return b.String()
+}
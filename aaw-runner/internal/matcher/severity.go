@@ -0,0 +1,125 @@
+package matcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Severity levels assigned to log lines
+const (
+	SeverityDebug = "debug"
+	SeverityInfo  = "info"
+	SeverityWarn  = "warn"
+	SeverityError = "error"
+)
+
+// SeverityRule maps lines matching Pattern to Severity
+// Keyword is an optional case-insensitive literal checked before the regex runs,
+// so lines that cannot possibly match never pay for a regex evaluation
+type SeverityRule struct {
+	Severity string `json:"severity"`
+	Keyword  string `json:"keyword,omitempty"`
+	Pattern  string `json:"pattern"`
+}
+
+type compiledSeverityRule struct {
+	severity string
+	keyword  string
+	pattern  *regexp.Regexp
+}
+
+// SeverityClassifier assigns a severity to log lines regardless of the pipe they came from
+type SeverityClassifier struct {
+	rules []compiledSeverityRule
+}
+
+// DefaultSeverityRules returns the built-in classification rules
+// Rules are evaluated in order and the first match wins
+func DefaultSeverityRules() []SeverityRule {
+	return []SeverityRule{
+		// Go panics and Python tracebacks
+		{Severity: SeverityError, Keyword: "panic:", Pattern: `panic:`},
+		{Severity: SeverityError, Keyword: "traceback", Pattern: `Traceback \(most recent call last\)`},
+		// Explicit error/fatal markers as whole words (avoids "errors=0"-style noise being matched by substring only)
+		{Severity: SeverityError, Keyword: "error", Pattern: `(?i)\berror\b`},
+		{Severity: SeverityError, Keyword: "fatal", Pattern: `(?i)\bfatal\b`},
+		// Warnings
+		{Severity: SeverityWarn, Keyword: "warn", Pattern: `(?i)\bwarn(ing)?\b`},
+		// Debug output tagged at the start of the line
+		{Severity: SeverityDebug, Keyword: "debug", Pattern: `(?i)^\s*\[?debug\]?[:\s]`},
+	}
+}
+
+// NewSeverityClassifier compiles the given rules into a classifier
+func NewSeverityClassifier(rules []SeverityRule) (*SeverityClassifier, error) {
+	compiled := make([]compiledSeverityRule, 0, len(rules))
+	for i, rule := range rules {
+		if !IsValidSeverity(rule.Severity) {
+			return nil, fmt.Errorf("severity rule %d: unknown severity %q", i, rule.Severity)
+		}
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("severity rule %d: invalid pattern: %w", i, err)
+		}
+		compiled = append(compiled, compiledSeverityRule{
+			severity: rule.Severity,
+			keyword:  rule.Keyword,
+			pattern:  pattern,
+		})
+	}
+	return &SeverityClassifier{rules: compiled}, nil
+}
+
+// LoadSeverityRules reads a JSON array of severity rules from a file
+func LoadSeverityRules(path string) ([]SeverityRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read severity rules: %w", err)
+	}
+
+	var rules []SeverityRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse severity rules %s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// IsValidSeverity reports whether s is one of the known severity levels
+func IsValidSeverity(s string) bool {
+	switch s {
+	case SeverityDebug, SeverityInfo, SeverityWarn, SeverityError:
+		return true
+	default:
+		return false
+	}
+}
+
+// Classify returns the severity of a log line, defaulting to info when no rule matches
+func (sc *SeverityClassifier) Classify(line string) string {
+	for _, rule := range sc.rules {
+		if rule.keyword != "" && !containsFold(line, rule.keyword) {
+			continue
+		}
+		if rule.pattern.MatchString(line) {
+			return rule.severity
+		}
+	}
+	return SeverityInfo
+}
+
+// containsFold reports whether substr is within s, ignoring ASCII/Unicode case, without allocating
+func containsFold(s, substr string) bool {
+	n := len(substr)
+	if n == 0 {
+		return true
+	}
+	for i := 0; i+n <= len(s); i++ {
+		if strings.EqualFold(s[i:i+n], substr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package matcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSeverityClassifier_DefaultRules verifies the built-in classification rules
+func TestSeverityClassifier_DefaultRules(t *testing.T) {
+	classifier, err := NewSeverityClassifier(DefaultSeverityRules())
+	assert.NoError(t, err, "Default rules should compile")
+
+	tests := []struct {
+		name     string
+		line     string
+		expected string
+	}{
+		{name: "Plain output", line: "Processing item 42", expected: SeverityInfo},
+		{name: "Upper-case ERROR", line: "ERROR: connection refused", expected: SeverityError},
+		{name: "Lower-case error", line: "build error in main.go", expected: SeverityError},
+		{name: "Error count is not an error", line: "errors=0 warnings=0", expected: SeverityInfo},
+		{name: "Go panic", line: "panic: runtime error: index out of range", expected: SeverityError},
+		{name: "Python traceback", line: "Traceback (most recent call last):", expected: SeverityError},
+		{name: "Fatal", line: "FATAL could not open database", expected: SeverityError},
+		{name: "WARN", line: "WARN disk usage at 91%", expected: SeverityWarn},
+		{name: "Warning", line: "npm warning: deprecated package", expected: SeverityWarn},
+		{name: "Debug prefix", line: "[DEBUG] cache hit", expected: SeverityDebug},
+		{name: "Debug mid-line is info", line: "enable debug mode with -v", expected: SeverityInfo},
+		{name: "Empty line", line: "", expected: SeverityInfo},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, classifier.Classify(tt.line), "Severity should match for %q", tt.line)
+		})
+	}
+}
+
+// TestSeverityClassifier_CustomRules verifies that a custom rule set replaces the defaults
+func TestSeverityClassifier_CustomRules(t *testing.T) {
+	classifier, err := NewSeverityClassifier([]SeverityRule{
+		{Severity: SeverityWarn, Keyword: "deprecated", Pattern: `(?i)deprecated`},
+		{Severity: SeverityError, Pattern: `^FAIL\b`},
+	})
+	assert.NoError(t, err, "Custom rules should compile")
+
+	assert.Equal(t, SeverityWarn, classifier.Classify("this API is DEPRECATED"))
+	assert.Equal(t, SeverityError, classifier.Classify("FAIL github.com/berno/aaw-runner"))
+	// Default rules no longer apply
+	assert.Equal(t, SeverityInfo, classifier.Classify("ERROR: not covered by custom rules"))
+}
+
+// TestSeverityClassifier_FirstMatchWins verifies rule ordering
+func TestSeverityClassifier_FirstMatchWins(t *testing.T) {
+	classifier, err := NewSeverityClassifier([]SeverityRule{
+		{Severity: SeverityWarn, Pattern: `retrying`},
+		{Severity: SeverityError, Pattern: `error`},
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, SeverityWarn, classifier.Classify("error: retrying in 5s"))
+}
+
+// TestNewSeverityClassifier_RejectsInvalidRules verifies validation of rule definitions
+func TestNewSeverityClassifier_RejectsInvalidRules(t *testing.T) {
+	_, err := NewSeverityClassifier([]SeverityRule{{Severity: "critical", Pattern: `x`}})
+	assert.Error(t, err, "Unknown severity should be rejected")
+
+	_, err = NewSeverityClassifier([]SeverityRule{{Severity: SeverityError, Pattern: `(`}})
+	assert.Error(t, err, "Invalid regex should be rejected")
+}
+
+// TestLoadSeverityRules verifies loading custom rules from a JSON file
+func TestLoadSeverityRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "severity.json")
+	content := `[{"severity":"warn","keyword":"slow","pattern":"(?i)slow query"}]`
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+	rules, err := LoadSeverityRules(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []SeverityRule{{Severity: SeverityWarn, Keyword: "slow", Pattern: "(?i)slow query"}}, rules)
+
+	_, err = LoadSeverityRules(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err, "Missing file should return an error")
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// AuthHMAC returns the hmac of an AUTH_RESPONSE: the hex HMAC-SHA256 of nonce keyed by secret
+func AuthHMAC(secret []byte, nonce string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(nonce))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyAuthResponse reports whether msg answers the challenge with nonce using secret, comparing in
+// constant time; it is what a backend checks before sending AUTH_OK
+func VerifyAuthResponse(msg AuthResponseMessage, nonce string, secret []byte) bool {
+	return msg.Nonce == nonce && hmac.Equal([]byte(msg.HMAC), []byte(AuthHMAC(secret, nonce)))
+}
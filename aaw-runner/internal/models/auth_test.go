@@ -0,0 +1,18 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestVerifyAuthResponse verifies a response is accepted for the nonce and secret it was built with only
+func TestVerifyAuthResponse(t *testing.T) {
+	secret := []byte("shared")
+	msg := NewAuthResponse("nonce-1", secret)
+	assert.True(t, VerifyAuthResponse(msg, "nonce-1", secret))
+	assert.False(t, VerifyAuthResponse(msg, "nonce-1", []byte("other")), "Bad secret")
+	assert.False(t, VerifyAuthResponse(msg, "nonce-2", secret), "Replayed for another challenge")
+	// Known answer, so backends in other languages can check their implementation
+	assert.Equal(t, "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8", AuthHMAC([]byte("key"), "The quick brown fox jumps over the lazy dog"))
+}
@@ -0,0 +1,304 @@
+package models
+
+import (
+	"time"
+
+	"github.com/berno/aaw-runner/internal/version"
+)
+
+// NewHelo builds the HELO handshake, advertising the range of schema versions the runner can emit
+// and the runner's build information
+func NewHelo(hostname, workdir string) HeloMessage {
+	return HeloMessage{
+		Type:             TypeHelo,
+		Hostname:         hostname,
+		Workdir:          workdir,
+		ProtocolVersion:  SchemaVersion,
+		MinSchemaVersion: MinSchemaVersion,
+		RunnerVersion:    version.Version,
+		Commit:           version.Commit,
+		BuildDate:        version.BuildDate,
+	}
+}
+
+// NewLogMessage builds a LOG line the runner writes about a task, such as "Task was cancelled"
+func NewLogMessage(taskID int64, line string, isError bool) LogMessage {
+	return LogMessage{
+		Type:    TypeLog,
+		TaskID:  taskID,
+		Line:    line,
+		IsError: isError,
+		Stream:  StreamSystem,
+	}
+}
+
+// NewOutputLog builds a LOG line a task printed on its stderr (isError) or stdout
+func NewOutputLog(taskID int64, line string, isError bool) LogMessage {
+	msg := LogMessage{
+		Type:    TypeLog,
+		TaskID:  taskID,
+		Line:    line,
+		IsError: isError,
+		Stream:  StreamStdout,
+	}
+	if isError {
+		msg.Stream = StreamStderr
+	}
+	return msg
+}
+
+// NewSystemLog builds a LOG carrying a notice from the runner itself, which belongs to no task
+func NewSystemLog(line, severity string) LogMessage {
+	return LogMessage{
+		Type:     TypeLog,
+		Line:     line,
+		Severity: severity,
+		Stream:   StreamSystem,
+		System:   true,
+	}
+}
+
+// NewRecurrenceFired builds the RECURRENCE_FIRED announcing an instance of a recurrence
+func NewRecurrenceFired(recurrenceID, instanceID string, taskID int64, scheduledAt time.Time, catchUp bool) RecurrenceFiredMessage {
+	return RecurrenceFiredMessage{
+		Type:         TypeRecurrenceFired,
+		RecurrenceID: recurrenceID,
+		InstanceID:   instanceID,
+		TaskID:       taskID,
+		ScheduledAt:  scheduledAt.Format(time.RFC3339),
+		CatchUp:      catchUp,
+	}
+}
+
+// NewResumeLogsResult builds a successful RESUME_LOGS_RESULT: lines from to next-1 will be replayed
+func NewResumeLogsResult(taskID, from, next int64) ResumeLogsResultMessage {
+	return ResumeLogsResultMessage{
+		Type:                TypeResumeLogsResult,
+		TaskID:              taskID,
+		Success:             true,
+		FromIndex:           from,
+		NextIndex:           next,
+		FirstAvailableIndex: 0,
+	}
+}
+
+// NewResumeLogsRefusal builds a RESUME_LOGS_RESULT saying why nothing can be replayed
+func NewResumeLogsRefusal(taskID int64, code, reason string, firstAvailable, next int64) ResumeLogsResultMessage {
+	return ResumeLogsResultMessage{
+		Type:                TypeResumeLogsResult,
+		TaskID:              taskID,
+		Code:                code,
+		Error:               reason,
+		FirstAvailableIndex: firstAvailable,
+		NextIndex:           next,
+	}
+}
+
+// NewReserveSlotResult builds a successful RESERVE_SLOT_RESULT
+func NewReserveSlotResult(reservationID string, expiresAt time.Time) ReserveSlotResultMessage {
+	return ReserveSlotResultMessage{
+		Type:          TypeReserveSlotResult,
+		ReservationID: reservationID,
+		Success:       true,
+		ExpiresAt:     expiresAt.Format(time.RFC3339),
+	}
+}
+
+// NewReserveSlotRefusal builds a RESERVE_SLOT_RESULT saying why nothing was reserved
+func NewReserveSlotRefusal(reservationID, code, reason string) ReserveSlotResultMessage {
+	return ReserveSlotResultMessage{
+		Type:          TypeReserveSlotResult,
+		ReservationID: reservationID,
+		Code:          code,
+		Error:         reason,
+	}
+}
+
+// NewReservationExpired builds a RESERVATION_EXPIRED notice
+func NewReservationExpired(reservationID, reason string) ReservationExpiredMessage {
+	return ReservationExpiredMessage{
+		Type:          TypeReservationExpired,
+		ReservationID: reservationID,
+		Reason:        reason,
+	}
+}
+
+// NewStatusUpdate builds a STATUS_UPDATE for a task
+func NewStatusUpdate(taskID int64, status string) StatusUpdateMessage {
+	return StatusUpdateMessage{
+		Type:   TypeStatusUpdate,
+		TaskID: taskID,
+		Status: status,
+	}
+}
+
+// NewRunnerStatus builds a RUNNER_STATUS ("IDLE" or "BUSY")
+func NewRunnerStatus(status string) RunnerStatusMessage {
+	return RunnerStatusMessage{
+		Type:   TypeRunnerStatus,
+		Status: status,
+	}
+}
+
+// NewRunnerCapacity builds a RUNNER_CAPACITY report
+func NewRunnerCapacity(maxParallel, running, available, reserved int) RunnerCapacityMessage {
+	return RunnerCapacityMessage{
+		Type:           TypeRunnerCapacity,
+		MaxParallel:    maxParallel,
+		RunningTasks:   running,
+		AvailableSlots: available,
+		Reserved:       reserved,
+	}
+}
+
+// NewTaskStarted builds a TASK_STARTED notification
+func NewTaskStarted(taskID int64, metadata map[string]string) TaskStartedMessage {
+	return TaskStartedMessage{
+		Type:     TypeTaskStarted,
+		TaskID:   taskID,
+		Metadata: metadata,
+	}
+}
+
+// NewTaskCompleted builds a TASK_COMPLETED notification
+// Failure details (error, errorCode, classification) are filled in by the caller
+func NewTaskCompleted(taskID int64, success bool) TaskCompletedMessage {
+	return TaskCompletedMessage{
+		Type:    TypeTaskCompleted,
+		TaskID:  taskID,
+		Success: success,
+	}
+}
+
+// SetUsage fills in the final resource usage of the task's process (CPU times in milliseconds)
+func (m *TaskCompletedMessage) SetUsage(userCPU, systemCPU time.Duration, maxRSSKb int64) {
+	user, system := userCPU.Milliseconds(), systemCPU.Milliseconds()
+	m.UserCPUMs, m.SystemCPUMs, m.MaxRSSKb = &user, &system, &maxRSSKb
+}
+
+// NewCancelAck builds a CANCEL_ACK ("CANCELLED" or "KILLED")
+func NewCancelAck(taskID int64, status string, success bool, errMsg string) CancelAckMessage {
+	return CancelAckMessage{
+		Type:    TypeCancelAck,
+		TaskID:  taskID,
+		Status:  status,
+		Success: success,
+		Error:   errMsg,
+	}
+}
+
+// NewTaskTerminated builds the TASK_TERMINATED acknowledgment for a killed task
+func NewTaskTerminated(taskID int64, success bool, errMsg string) TaskTerminatedMessage {
+	return TaskTerminatedMessage{
+		Type:    TypeTaskTerminated,
+		TaskID:  taskID,
+		Status:  "KILLED",
+		Success: success,
+		Error:   errMsg,
+	}
+}
+
+// NewRunnerDraining builds the RUNNER_DRAINING announcement that starts a graceful shutdown
+func NewRunnerDraining(running int, grace time.Duration) RunnerDrainingMessage {
+	return RunnerDrainingMessage{
+		Type:         TypeRunnerDraining,
+		RunningTasks: running,
+		GraceSeconds: int(grace / time.Second),
+	}
+}
+
+// NewBye builds the BYE that ends a graceful shutdown
+func NewBye(drained bool, cancelled int) ByeMessage {
+	return ByeMessage{
+		Type:           TypeBye,
+		Drained:        drained,
+		CancelledTasks: cancelled,
+	}
+}
+
+// NewGoodbye builds the GOODBYE sent before the runner closes the connection
+func NewGoodbye(reason string, unfinished []int64) GoodbyeMessage {
+	if unfinished == nil {
+		unfinished = []int64{}
+	}
+	return GoodbyeMessage{
+		Type:            TypeGoodbye,
+		Reason:          reason,
+		UnfinishedTasks: unfinished,
+	}
+}
+
+// NewRunnerHeartbeat builds a RUNNER_HEARTBEAT
+func NewRunnerHeartbeat(uptime time.Duration, maxParallel, running, available, queued int, load float64) RunnerHeartbeatMessage {
+	return RunnerHeartbeatMessage{
+		Type:           TypeRunnerHeartbeat,
+		UptimeSeconds:  int64(uptime / time.Second),
+		MaxParallel:    maxParallel,
+		RunningTasks:   running,
+		AvailableSlots: available,
+		QueuedTasks:    queued,
+		LoadAverage1:   load,
+	}
+}
+
+// NewAuthResponse builds the AUTH_RESPONSE to a challenge with nonce, signed with secret
+func NewAuthResponse(nonce string, secret []byte) AuthResponseMessage {
+	return AuthResponseMessage{
+		Type:  TypeAuthResponse,
+		Nonce: nonce,
+		HMAC:  AuthHMAC(secret, nonce),
+	}
+}
+
+// NewRunnerResync builds the RUNNER_RESYNC sent after a reconnect
+func NewRunnerResync(tasks []ResyncTask, maxParallel, running, available int) RunnerResyncMessage {
+	if tasks == nil {
+		tasks = []ResyncTask{}
+	}
+	return RunnerResyncMessage{
+		Type:           TypeRunnerResync,
+		Tasks:          tasks,
+		MaxParallel:    maxParallel,
+		RunningTasks:   running,
+		AvailableSlots: available,
+	}
+}
+
+// NewStdinInputResult builds a STDIN_INPUT_RESULT for data of which written bytes reached the task's
+// stdin, err saying why the rest did not
+func NewStdinInputResult(taskID int64, written int, err error) StdinInputResultMessage {
+	msg := StdinInputResultMessage{
+		Type:    TypeStdinInputResult,
+		TaskID:  taskID,
+		Success: err == nil,
+		Bytes:   written,
+	}
+	if err != nil {
+		msg.Error = err.Error()
+	}
+	return msg
+}
+
+// NewToolUse builds the TOOL_EVENT for claude asking for tool to run with input (JSON)
+func NewToolUse(taskID int64, toolUseID, tool, input string) ToolEventMessage {
+	return ToolEventMessage{
+		Type:      TypeToolEvent,
+		TaskID:    taskID,
+		Phase:     ToolPhaseUse,
+		ToolUseID: toolUseID,
+		Tool:      tool,
+		Input:     input,
+	}
+}
+
+// NewToolResult builds the TOOL_EVENT for the output of a tool use going back to claude
+func NewToolResult(taskID int64, toolUseID, output string, isError bool) ToolEventMessage {
+	return ToolEventMessage{
+		Type:      TypeToolEvent,
+		TaskID:    taskID,
+		Phase:     ToolPhaseResult,
+		ToolUseID: toolUseID,
+		Output:    output,
+		IsError:   isError,
+	}
+}
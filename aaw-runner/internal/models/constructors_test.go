@@ -0,0 +1,96 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/version"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestConstructors_SetType verifies every constructor stamps its message type and builds a valid message
+func TestConstructors_SetType(t *testing.T) {
+	cases := []struct {
+		name string
+		msg  interface{ Validate() error }
+		typ  string
+	}{
+		{"helo", NewHelo("host", "/work"), TypeHelo},
+		{"log", NewLogMessage(1, "line", false), TypeLog},
+		{"output log", NewOutputLog(1, "line", true), TypeLog},
+		{"status", NewStatusUpdate(1, StatusRunning), TypeStatusUpdate},
+		{"runner status", NewRunnerStatus("IDLE"), TypeRunnerStatus},
+		{"capacity", NewRunnerCapacity(3, 1, 1, 1), TypeRunnerCapacity},
+		{"started", NewTaskStarted(1, map[string]string{"jobId": "7"}), TypeTaskStarted},
+		{"completed", NewTaskCompleted(1, true), TypeTaskCompleted},
+		{"cancel ack", NewCancelAck(1, "CANCELLED", true, ""), TypeCancelAck},
+		{"terminated", NewTaskTerminated(1, true, ""), TypeTaskTerminated},
+		{"draining", NewRunnerDraining(2, 30*time.Second), TypeRunnerDraining},
+		{"bye", NewBye(false, 2), TypeBye},
+		{"goodbye", NewGoodbye(GoodbyeClosed, nil), TypeGoodbye},
+		{"resync", NewRunnerResync(nil, 5, 0, 5), TypeRunnerResync},
+		{"heartbeat", NewRunnerHeartbeat(time.Minute, 5, 1, 4, 2, 0.5), TypeRunnerHeartbeat},
+		{"system log", NewSystemLog("[runner] reconnected", "info"), TypeLog},
+		{"auth response", NewAuthResponse("9f2c41d07a", []byte("secret")), TypeAuthResponse},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := json.Marshal(tc.msg)
+			assert.NoError(t, err)
+			var header struct {
+				Type string `json:"type"`
+			}
+			assert.NoError(t, json.Unmarshal(data, &header))
+			assert.Equal(t, tc.typ, header.Type)
+			assert.NoError(t, tc.msg.Validate())
+		})
+	}
+}
+
+// TestLogMessage_StreamJSON verifies LOG lines carry the stream they come from next to isError, and that a
+// frame without one still decodes
+func TestLogMessage_StreamJSON(t *testing.T) {
+	cases := []struct {
+		name string
+		msg  LogMessage
+		want string
+	}{
+		{"stdout", NewOutputLog(1, "out", false), `"isError":false,"stream":"stdout"`},
+		{"stderr", NewOutputLog(1, "err", true), `"isError":true,"stream":"stderr"`},
+		{"runner line", NewLogMessage(1, "Task was cancelled", false), `"isError":false,"stream":"system"`},
+		{"runner error", NewLogMessage(1, "Command failed", true), `"isError":true,"stream":"system"`},
+		{"runner notice", NewSystemLog("[runner] reconnected", "info"), `"isError":false,"stream":"system"`},
+		{"unset", LogMessage{Type: TypeLog, TaskID: 1, Line: "old"}, `"line":"old","isError":false,"lineIndex"`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := json.Marshal(tc.msg)
+			assert.NoError(t, err)
+			assert.Contains(t, string(data), tc.want)
+
+			var got LogMessage
+			assert.NoError(t, json.Unmarshal(data, &got))
+			assert.Equal(t, tc.msg, got)
+		})
+	}
+}
+
+// TestConstructors_Defaults verifies constructors fill in protocol-mandated fields
+func TestConstructors_Defaults(t *testing.T) {
+	helo := NewHelo("host", "/work")
+	assert.Equal(t, MinSchemaVersion, helo.MinSchemaVersion)
+	assert.Equal(t, version.Version, helo.RunnerVersion)
+	assert.Equal(t, version.Commit, helo.Commit)
+	assert.Equal(t, "KILLED", NewTaskTerminated(1, false, "boom").Status)
+
+	completed := NewTaskCompleted(2, false)
+	assert.False(t, completed.Success)
+	assert.Empty(t, completed.ErrorCode, "Failure details are left to the caller")
+	assert.Nil(t, completed.UserCPUMs, "Usage is omitted unless set")
+	completed.SetUsage(1500*time.Millisecond, 250*time.Millisecond, 8192)
+	assert.Equal(t, int64(1500), *completed.UserCPUMs)
+	assert.Equal(t, int64(250), *completed.SystemCPUMs)
+	assert.Equal(t, int64(8192), *completed.MaxRSSKb)
+}
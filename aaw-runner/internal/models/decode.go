@@ -0,0 +1,208 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// ErrUnknownMessageType is returned by DecodeIncoming for a well-formed frame whose type the runner does not handle
+var ErrUnknownMessageType = errors.New("unknown message type")
+
+// ErrMalformedMessage is returned by DecodeIncoming when a frame cannot be parsed
+var ErrMalformedMessage = errors.New("malformed message")
+
+// ErrMessageTooLarge reports a frame over the runner's read limit, which could not be read at all
+var ErrMessageTooLarge = errors.New("message too large")
+
+// Incoming is a decoded message sent from the backend to the runner
+type Incoming interface {
+	MessageType() string
+	Validate() error
+	Version() int
+}
+
+// incomingTypes is the single list of message types the runner accepts, with a constructor for each
+var incomingTypes = map[string]func() Incoming{
+	TypeHeloAck:    func() Incoming { return &HeloAckMessage{} },
+	TypeExecute:    func() Incoming { return &ExecuteMessage{} },
+	TypeCancelTask: func() Incoming { return &CancelTaskMessage{} },
+	TypeKillTask:   func() Incoming { return &KillTaskMessage{} },
+	TypeResumeLogs: func() Incoming { return &ResumeLogsMessage{} },
+
+	TypeRecurringExecute: func() Incoming { return &RecurringExecuteMessage{} },
+	TypeCancelRecurring:  func() Incoming { return &CancelRecurringMessage{} },
+	TypeReserveSlot:      func() Incoming { return &ReserveSlotMessage{} },
+	TypeReleaseSlot:      func() Incoming { return &ReleaseSlotMessage{} },
+	TypeAck:              func() Incoming { return &AckMessage{} },
+	TypeAuthChallenge:    func() Incoming { return &AuthChallengeMessage{} },
+	TypeAuthOK:           func() Incoming { return &AuthOKMessage{} },
+	TypeShutdown:         func() Incoming { return &ShutdownMessage{} },
+	TypeStdinInput:       func() Incoming { return &StdinInputMessage{} },
+}
+
+func (m *HeloAckMessage) MessageType() string    { return TypeHeloAck }
+func (m *ExecuteMessage) MessageType() string    { return TypeExecute }
+func (m *CancelTaskMessage) MessageType() string { return TypeCancelTask }
+func (m *KillTaskMessage) MessageType() string   { return TypeKillTask }
+func (m *ResumeLogsMessage) MessageType() string { return TypeResumeLogs }
+
+func (m *RecurringExecuteMessage) MessageType() string { return TypeRecurringExecute }
+func (m *CancelRecurringMessage) MessageType() string  { return TypeCancelRecurring }
+func (m *ReserveSlotMessage) MessageType() string      { return TypeReserveSlot }
+func (m *ReleaseSlotMessage) MessageType() string      { return TypeReleaseSlot }
+func (m *AckMessage) MessageType() string              { return TypeAck }
+func (m *AuthChallengeMessage) MessageType() string    { return TypeAuthChallenge }
+func (m *AuthOKMessage) MessageType() string           { return TypeAuthOK }
+func (m *ShutdownMessage) MessageType() string         { return TypeShutdown }
+func (m *StdinInputMessage) MessageType() string       { return TypeStdinInput }
+
+// DecodeIncoming parses a raw frame into its concrete message type and validates it
+// Only the type field is read up front; the frame is then decoded once into the matching struct (see DecodeAs).
+func DecodeIncoming(data []byte) (Incoming, error) {
+	msgType, err := PeekType(data)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeAs(msgType, data)
+}
+
+// DecodeAs decodes a raw frame whose type field was already read as msgType, and validates it, so a
+// caller that dispatched on the type does not parse it again
+// Top-level snake_case keys (task_id, script_content, ...) are accepted as aliases of the camelCase names
+func DecodeAs(msgType string, data []byte) (Incoming, error) {
+	newMsg, ok := incomingTypes[msgType]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMessageType, msgType)
+	}
+
+	data, err := normalizeKeys(msgType, data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrMalformedMessage, msgType, err)
+	}
+
+	msg := newMsg()
+	if err := json.Unmarshal(data, msg); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrMalformedMessage, msgType, err)
+	}
+	if err := msg.Validate(); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// PeekType reads only the type field of a raw frame, without decoding the rest
+func PeekType(data []byte) (string, error) {
+	var header struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrMalformedMessage, err)
+	}
+	if header.Type == "" {
+		return "", fmt.Errorf("%w: missing type field", ErrMalformedMessage)
+	}
+	return header.Type, nil
+}
+
+// normalizeKeys rewrites top-level snake_case keys to their camelCase equivalents
+// When both spellings are present the canonical camelCase value wins and the alias is dropped with a warning.
+// Frames without such a key are returned untouched without re-encoding
+func normalizeKeys(msgType string, data []byte) ([]byte, error) {
+	if snake, err := hasSnakeKey(data); err != nil || !snake {
+		return data, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	var aliases []string
+	for key := range fields {
+		if strings.Contains(key, "_") {
+			aliases = append(aliases, key)
+		}
+	}
+	if len(aliases) == 0 {
+		return data, nil
+	}
+	sort.Strings(aliases)
+
+	for _, alias := range aliases {
+		canonical := snakeToCamel(alias)
+		if _, exists := fields[canonical]; exists {
+			log.Printf("[WS] Warning: %s has both %q and %q; using %q", msgType, canonical, alias, canonical)
+		} else {
+			fields[canonical] = fields[alias]
+		}
+		delete(fields, alias)
+	}
+
+	return json.Marshal(fields)
+}
+
+// hasSnakeKey reports whether a top-level key of the JSON object in data contains an underscore
+// Only the keys are read; values, however large or nested, are skipped over.
+func hasSnakeKey(data []byte) (bool, error) {
+	if bytes.IndexByte(data, '_') < 0 {
+		return false, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil {
+		return false, err
+	} else if tok != json.Delim('{') {
+		return false, fmt.Errorf("frame is not a JSON object")
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return false, err
+		}
+		if strings.Contains(tok.(string), "_") {
+			return true, nil
+		}
+		if err := skipValue(dec); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// skipValue reads past the next value of dec, a scalar or a whole array or object
+func skipValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// snakeToCamel converts "script_content" to "scriptContent"
+func snakeToCamel(key string) string {
+	parts := strings.Split(key, "_")
+	var b strings.Builder
+	b.WriteString(parts[0])
+	for _, part := range parts[1:] {
+		if part == "" {
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
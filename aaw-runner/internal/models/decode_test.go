@@ -0,0 +1,166 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestDecodeIncoming_KnownTypes verifies every accepted message type round-trips through the decoder
+func TestDecodeIncoming_KnownTypes(t *testing.T) {
+	tests := []Incoming{
+		&HeloAckMessage{Type: TypeHeloAck, ProtocolVersion: SchemaVersion},
+		&ExecuteMessage{Type: TypeExecute, TaskID: 7, ScriptContent: "echo hi", SkipPermissions: true, SessionMode: "NEW"},
+		&CancelTaskMessage{Type: TypeCancelTask, TaskID: 8},
+		&KillTaskMessage{Type: TypeKillTask, TaskID: 9},
+		&ResumeLogsMessage{Type: TypeResumeLogs, TaskID: 10, LastLineIndex: -1},
+		&RecurringExecuteMessage{Type: TypeRecurringExecute, RecurrenceID: "health", Cron: "*/30 * * * *", Task: ExecuteMessage{ScriptContent: "check"}},
+		&CancelRecurringMessage{Type: TypeCancelRecurring, RecurrenceID: "health"},
+		&ReserveSlotMessage{Type: TypeReserveSlot, ReservationID: "deploy", TTLSeconds: 60, Weight: 2},
+		&ReleaseSlotMessage{Type: TypeReleaseSlot, ReservationID: "deploy"},
+		&AckMessage{Type: TypeAck, MessageID: "0b4e7c1e-5f0a-4d47-9a54-2f1d6c1e0a11"},
+		&AuthChallengeMessage{Type: TypeAuthChallenge, Nonce: "9f2c41d07a"},
+		&AuthOKMessage{Type: TypeAuthOK},
+		&ShutdownMessage{Type: TypeShutdown, GraceSeconds: 30, Reason: "maintenance"},
+		&StdinInputMessage{Type: TypeStdinInput, TaskID: 7, Data: "y\n", CloseAfter: true},
+	}
+
+	assert.Len(t, tests, len(incomingTypes), "Every registered type should be covered")
+
+	for _, want := range tests {
+		data, err := json.Marshal(want)
+		assert.NoError(t, err)
+
+		got, err := DecodeIncoming(data)
+		assert.NoError(t, err, want.MessageType())
+		assert.Equal(t, want, got, want.MessageType())
+	}
+}
+
+// TestDecodeIncoming_UnknownType verifies unknown types produce the sentinel error
+func TestDecodeIncoming_UnknownType(t *testing.T) {
+	_, err := DecodeIncoming([]byte(`{"type":"PAUSE_TASK","taskId":1}`))
+	assert.True(t, errors.Is(err, ErrUnknownMessageType))
+	assert.Contains(t, err.Error(), "PAUSE_TASK")
+}
+
+// TestDecodeIncoming_Malformed verifies broken frames are rejected as malformed
+func TestDecodeIncoming_Malformed(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "empty", data: ``},
+		{name: "not json", data: `hello`},
+		{name: "truncated", data: `{"type":"EXECUTE","taskId":1`},
+		{name: "truncated mid string", data: `{"type":"EXEC`},
+		{name: "array", data: `[1,2,3]`},
+		{name: "missing type", data: `{"taskId":1}`},
+		{name: "type not string", data: `{"type":42}`},
+		{name: "taskId as string", data: `{"type":"EXECUTE","taskId":"1"}`},
+		{name: "taskId fractional", data: `{"type":"CANCEL_TASK","taskId":1.5}`},
+		{name: "skipPermissions as string", data: `{"type":"EXECUTE","taskId":1,"skipPermissions":"yes"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := DecodeIncoming([]byte(tt.data))
+			assert.Nil(t, msg)
+			assert.True(t, errors.Is(err, ErrMalformedMessage), "got %v", err)
+		})
+	}
+}
+
+// TestDecodeIncoming_TruncatedPrefixes verifies no prefix of a valid frame panics or decodes
+func TestDecodeIncoming_TruncatedPrefixes(t *testing.T) {
+	data := []byte(`{"type":"EXECUTE","taskId":42,"scriptContent":"do it","sessionMode":"PERSIST"}`)
+
+	for i := 0; i < len(data); i++ {
+		msg, err := DecodeIncoming(data[:i])
+		assert.Error(t, err, "prefix %q", data[:i])
+		assert.Nil(t, msg)
+	}
+}
+
+// TestDecodeIncoming_SnakeCaseAliases verifies snake_case, camelCase and mixed payloads decode identically
+func TestDecodeIncoming_SnakeCaseAliases(t *testing.T) {
+	execute := &ExecuteMessage{Type: TypeExecute, TaskID: 7, ScriptContent: "do it", SkipPermissions: true, SessionMode: SessionModePersist,
+		Metadata: map[string]string{"trace_id": "abc"}}
+	cancel := &CancelTaskMessage{Type: TypeCancelTask, TaskID: 8}
+	kill := &KillTaskMessage{Type: TypeKillTask, TaskID: 9}
+
+	tests := []struct {
+		name string
+		data string
+		want Incoming
+	}{
+		{name: "execute camel", data: `{"type":"EXECUTE","taskId":7,"scriptContent":"do it","skipPermissions":true,"sessionMode":"PERSIST","metadata":{"trace_id":"abc"}}`, want: execute},
+		{name: "execute snake", data: `{"type":"EXECUTE","task_id":7,"script_content":"do it","skip_permissions":true,"session_mode":"PERSIST","metadata":{"trace_id":"abc"}}`, want: execute},
+		{name: "execute mixed", data: `{"type":"EXECUTE","task_id":7,"scriptContent":"do it","skip_permissions":true,"sessionMode":"PERSIST","metadata":{"trace_id":"abc"}}`, want: execute},
+		{name: "cancel camel", data: `{"type":"CANCEL_TASK","taskId":8}`, want: cancel},
+		{name: "cancel snake", data: `{"type":"CANCEL_TASK","task_id":8}`, want: cancel},
+		{name: "cancel mixed", data: `{"type":"CANCEL_TASK","task_id":8,"schema_version":0}`, want: cancel},
+		{name: "kill camel", data: `{"type":"KILL_TASK","taskId":9}`, want: kill},
+		{name: "kill snake", data: `{"type":"KILL_TASK","task_id":9}`, want: kill},
+		{name: "kill mixed", data: `{"type":"KILL_TASK","taskId":9,"schema_version":0}`, want: kill},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeIncoming([]byte(tt.data))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// TestDecodeIncoming_CanonicalNameWins verifies camelCase takes precedence when both spellings are present
+func TestDecodeIncoming_CanonicalNameWins(t *testing.T) {
+	got, err := DecodeIncoming([]byte(`{"type":"EXECUTE","task_id":1,"taskId":2,"scriptContent":"a","script_content":"b"}`))
+	assert.NoError(t, err)
+
+	msg := got.(*ExecuteMessage)
+	assert.Equal(t, int64(2), msg.TaskID)
+	assert.Equal(t, "a", msg.ScriptContent)
+}
+
+// TestHasSnakeKey verifies only top-level keys decide whether a frame needs its keys rewritten, not
+// underscores in values or nested keys
+func TestHasSnakeKey(t *testing.T) {
+	for data, want := range map[string]bool{
+		`{"type":"EXECUTE","taskId":1}`:                                              false,
+		`{"type":"EXECUTE","scriptContent":"run_tests","metadata":{"trace_id":"a"}}`: false,
+		`{"type":"EXECUTE","args":["--max_turns",{"x_y":[1,{"z_":2}]}],"taskId":1}`:  false,
+		`{"type":"EXECUTE","metadata":{"trace_id":"a"},"task_id":1}`:                 true,
+		`{"task_id":1}`: true,
+	} {
+		got, err := hasSnakeKey([]byte(data))
+		assert.NoError(t, err, data)
+		assert.Equal(t, want, got, data)
+	}
+
+	_, err := hasSnakeKey([]byte(`["task_id"]`))
+	assert.Error(t, err)
+	_, err = hasSnakeKey([]byte(`{"scriptContent":"run_tests"`))
+	assert.Error(t, err)
+}
+
+// TestDecodeAs verifies a frame decodes as the type it was dispatched on, and an unknown type is reported
+func TestDecodeAs(t *testing.T) {
+	got, err := DecodeAs(TypeCancelTask, []byte(`{"type":"CANCEL_TASK","task_id":8}`))
+	assert.NoError(t, err)
+	assert.Equal(t, &CancelTaskMessage{Type: TypeCancelTask, TaskID: 8}, got)
+
+	_, err = DecodeAs("PAUSE_TASK", []byte(`{"type":"PAUSE_TASK","taskId":1}`))
+	assert.ErrorIs(t, err, ErrUnknownMessageType)
+}
+
+// TestSnakeToCamel verifies key conversion
+func TestSnakeToCamel(t *testing.T) {
+	assert.Equal(t, "taskId", snakeToCamel("task_id"))
+	assert.Equal(t, "scriptContent", snakeToCamel("script_content"))
+	assert.Equal(t, "skipPermissions", snakeToCamel("skip__permissions"))
+	assert.Equal(t, "type", snakeToCamel("type"))
+}
@@ -0,0 +1,26 @@
+package models
+
+// Allowed values for enumerated message fields
+// Validate and the JSON Schema generator both read these, so a new constant only needs adding here
+var (
+	TaskStatuses = []string{StatusPending, StatusRunning, StatusPaused, StatusRateLimited, StatusUsageLimited,
+		StatusAuthError, StatusStalled, StatusCompleted, StatusFailed, StatusCancelled}
+	RunnerStatuses    = []string{"IDLE", "BUSY"}
+	CancelAckStatuses = []string{StatusCancelled, "KILLED"}
+	Severities        = []string{"debug", "info", "warn", "error"}
+	Streams           = []string{StreamStdout, StreamStderr, StreamSystem}
+	SessionModes      = []string{SessionModeNew, SessionModePersist}
+	OutputFormats     = []string{OutputFormatText, OutputFormatJSON}
+	StallActions      = []string{StallActionNotify, StallActionKill}
+	ToolPhases        = []string{ToolPhaseUse, ToolPhaseResult}
+	ErrorCodes        = []string{ErrorCodeCancelled, ErrorCodeTimeout, ErrorCodeStartFailed, ErrorCodePolicyRejected,
+		ErrorCodeCapacity, ErrorCodeRunnerShutdown, ErrorCodeExitNonzero, ErrorCodeEnvironment, ErrorCodeInternal,
+		ErrorCodeInvalidTask, ErrorCodeCheckout, ErrorCodeSSHConnect, ErrorCodeSSHHostKey, ErrorCodeSSHConnectionLost}
+	Classifications   = []string{ClassificationOOM, ClassificationAuthError}
+	MessageErrorCodes = []string{MessageErrorMalformed, MessageErrorUnknownType, MessageErrorInvalid, MessageErrorUnsupportedVersion, MessageErrorTooLarge}
+	ResumeCodes       = []string{ResumeNotRunning, ResumeNotPersisted, ResumeOutOfRange}
+	ReserveCodes      = []string{ReserveDraining, ReserveInsufficient}
+	ExpiryReasons     = []string{ExpiredTTL, ExpiredDraining}
+	GoodbyeReasons    = []string{GoodbyeShutdown, GoodbyeForced, GoodbyeClosed}
+	ResyncStates      = []string{ResyncQueued, ResyncRunning, ResyncCancelling}
+)
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Schema versions of the wire protocol
+// Bump SchemaVersion on breaking changes and keep emitting older versions for as long as backends need them
+const (
+	// SchemaVersionLegacy is the original format, which has no schemaVersion field
+	SchemaVersionLegacy = 1
+	// SchemaVersion is the newest version this runner understands and emits by default
+	SchemaVersion = 2
+	// MinSchemaVersion is the oldest version this runner can still emit
+	MinSchemaVersion = SchemaVersionLegacy
+)
+
+// ErrUnsupportedVersion reports an incoming message written in a newer schema than the runner understands
+var ErrUnsupportedVersion = errors.New("unsupported schema version")
+
+// Envelope carries protocol metadata shared by every message
+// It is embedded so its fields sit at the top level of the JSON object
+type Envelope struct {
+	SchemaVersion int `json:"schemaVersion,omitempty"`
+}
+
+// Version returns the schema version a message was written with
+// Messages without the field predate the envelope and are treated as legacy
+func (e Envelope) Version() int {
+	if e.SchemaVersion == 0 {
+		return SchemaVersionLegacy
+	}
+	return e.SchemaVersion
+}
+
+// SetSchemaVersion stamps an outgoing message with the version it is being written in
+// Legacy messages omit the field entirely, exactly as they did before it existed
+func (e *Envelope) SetSchemaVersion(version int) {
+	if version <= SchemaVersionLegacy {
+		e.SchemaVersion = 0
+		return
+	}
+	e.SchemaVersion = version
+}
+
+// NegotiateSchemaVersion picks the version to emit given the backend's protocol version from HELO_ACK
+// Versions outside the supported range are clamped to the nearest one the runner can write
+func NegotiateSchemaVersion(protocolVersion int) int {
+	if protocolVersion < MinSchemaVersion {
+		return MinSchemaVersion
+	}
+	if protocolVersion > SchemaVersion {
+		return SchemaVersion
+	}
+	return protocolVersion
+}
+
+// CheckVersion reports whether the runner understands the schema an incoming message was written in
+// Callers decide whether a newer message is rejected or handled best-effort
+func CheckVersion(msg Incoming) error {
+	if v := msg.Version(); v > SchemaVersion {
+		return fmt.Errorf("%w: %s has schemaVersion %d, runner supports up to %d", ErrUnsupportedVersion, msg.MessageType(), v, SchemaVersion)
+	}
+	return nil
+}
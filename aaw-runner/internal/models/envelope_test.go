@@ -0,0 +1,87 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestNegotiateSchemaVersion verifies the HELO_ACK protocol version is clamped to the supported range
+func TestNegotiateSchemaVersion(t *testing.T) {
+	assert.Equal(t, MinSchemaVersion, NegotiateSchemaVersion(0))
+	assert.Equal(t, SchemaVersionLegacy, NegotiateSchemaVersion(SchemaVersionLegacy))
+	assert.Equal(t, SchemaVersion, NegotiateSchemaVersion(SchemaVersion))
+	assert.Equal(t, SchemaVersion, NegotiateSchemaVersion(SchemaVersion+5))
+}
+
+// TestExecuteMessage_RoundTripAcrossVersions verifies EXECUTE decodes in every schema version
+func TestExecuteMessage_RoundTripAcrossVersions(t *testing.T) {
+	for version := MinSchemaVersion; version <= SchemaVersion; version++ {
+		sent := ExecuteMessage{Type: TypeExecute, TaskID: 3, ScriptContent: "do it", SessionMode: SessionModeNew}
+		sent.SetSchemaVersion(version)
+
+		data, err := json.Marshal(&sent)
+		assert.NoError(t, err)
+		assert.Equal(t, version > SchemaVersionLegacy, containsKey(t, data, "schemaVersion"),
+			"schemaVersion should only be on the wire after the legacy version (v%d)", version)
+
+		got, err := DecodeIncoming(data)
+		assert.NoError(t, err)
+		assert.Equal(t, version, got.Version())
+		assert.NoError(t, CheckVersion(got))
+		assert.Equal(t, &sent, got)
+	}
+}
+
+// TestExecuteMessage_NewerVersion verifies newer messages still decode but are flagged
+func TestExecuteMessage_NewerVersion(t *testing.T) {
+	data := []byte(`{"type":"EXECUTE","schemaVersion":99,"taskId":3,"scriptContent":"do it","futureField":{"a":1}}`)
+
+	got, err := DecodeIncoming(data)
+	assert.NoError(t, err, "Unknown fields from newer schemas should be ignored")
+	assert.Equal(t, int64(3), got.(*ExecuteMessage).TaskID)
+
+	err = CheckVersion(got)
+	assert.True(t, errors.Is(err, ErrUnsupportedVersion))
+
+	reply := NewMessageError(data, err)
+	assert.Equal(t, MessageErrorUnsupportedVersion, reply.Code)
+	assert.Equal(t, int64(3), reply.TaskID)
+	assert.NoError(t, reply.Validate())
+}
+
+// TestTaskCompletedMessage_RoundTripAcrossVersions verifies TASK_COMPLETED in every schema version
+func TestTaskCompletedMessage_RoundTripAcrossVersions(t *testing.T) {
+	for version := MinSchemaVersion; version <= SchemaVersion; version++ {
+		sent := TaskCompletedMessage{Type: TypeTaskCompleted, TaskID: 9, Success: false, Error: "boom", Classification: ClassificationOOM}
+		sent.SetSchemaVersion(version)
+
+		data, err := json.Marshal(&sent)
+		assert.NoError(t, err)
+
+		var raw map[string]interface{}
+		assert.NoError(t, json.Unmarshal(data, &raw))
+		assert.Equal(t, "TASK_COMPLETED", raw["type"], "Envelope must not nest fields")
+		if version == SchemaVersionLegacy {
+			assert.NotContains(t, raw, "schemaVersion", "Legacy output must match the pre-envelope format")
+		} else {
+			assert.Equal(t, float64(version), raw["schemaVersion"])
+		}
+
+		var got TaskCompletedMessage
+		assert.NoError(t, json.Unmarshal(data, &got))
+		assert.Equal(t, sent, got)
+		assert.Equal(t, version, got.Version())
+	}
+}
+
+// containsKey reports whether a JSON object has the given top-level key
+func containsKey(t *testing.T, data []byte, key string) bool {
+	t.Helper()
+	var raw map[string]json.RawMessage
+	assert.NoError(t, json.Unmarshal(data, &raw))
+	_, ok := raw[key]
+	return ok
+}
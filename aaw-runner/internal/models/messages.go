@@ -13,100 +13,683 @@ const (
 	TypeCancelAck       = "CANCEL_ACK"
 	TypeTaskTerminated  = "TASK_TERMINATED" // New: Explicit ACK for delete operation
 	TypeRunnerCapacity  = "RUNNER_CAPACITY"
+	TypeMessageError    = "MESSAGE_ERROR" // Runner rejected an incoming message
+	TypeHeloAck         = "HELO_ACK"      // Backend accepted the handshake
+	TypeTaskStarted     = "TASK_STARTED"  // A worker began executing the task
+	TypeRunnerDraining  = "RUNNER_DRAINING" // Runner is shutting down and takes no new tasks
+	TypeBye             = "BYE"             // Last message before the runner disconnects
+	TypeResumeLogs       = "RESUME_LOGS"        // Backend asks for the output lines it missed while disconnected
+	TypeResumeLogsResult = "RESUME_LOGS_RESULT" // Runner's answer to RESUME_LOGS
+	TypeRecurringExecute = "RECURRING_EXECUTE"  // Backend registers a task the runner starts on a schedule
+	TypeCancelRecurring  = "CANCEL_RECURRING"   // Backend removes a registered recurrence
+	TypeRecurrenceFired  = "RECURRENCE_FIRED"   // Runner started an instance of a recurrence
+
+	TypeReserveSlot        = "RESERVE_SLOT"        // Backend holds capacity for a task it is about to send
+	TypeReleaseSlot        = "RELEASE_SLOT"        // Backend gives a reservation back unused
+	TypeReserveSlotResult  = "RESERVE_SLOT_RESULT" // Runner's answer to RESERVE_SLOT
+	TypeReservationExpired = "RESERVATION_EXPIRED" // Runner dropped a reservation that was not used
+
+	TypeGoodbye      = "GOODBYE"       // Runner is closing the connection; precedes the WebSocket close frame
+	TypeRunnerResync = "RUNNER_RESYNC" // Runner lists the tasks it holds after reconnecting
+	TypeAck          = "ACK"           // Backend confirms it stored a journaled message (see internal/journal)
+
+	TypeRunnerHeartbeat = "RUNNER_HEARTBEAT" // Runner's periodic liveness report
+
+	TypeAuthChallenge = "AUTH_CHALLENGE" // Backend asks the runner to prove it holds the shared secret
+	TypeAuthResponse  = "AUTH_RESPONSE"  // Runner's answer to AUTH_CHALLENGE
+	TypeAuthOK        = "AUTH_OK"        // Backend accepted the AUTH_RESPONSE; the runner may take tasks
+
+	TypeShutdown = "SHUTDOWN" // Backend asks the runner to drain and exit
+
+	TypeStdinInput       = "STDIN_INPUT"        // Backend writes to the stdin of a running interactive task
+	TypeStdinInputResult = "STDIN_INPUT_RESULT" // Runner's answer to STDIN_INPUT
+
+	TypeToolEvent = "TOOL_EVENT" // A task running with outputFormat json used a tool, or got its result
 )
 
 // HeloMessage represents the initial handshake message
 type HeloMessage struct {
-	Type     string `json:"type"`
-	Hostname string `json:"hostname"`
-	Workdir  string `json:"workdir"`
+	Envelope
+	Type             string      `json:"type"`
+	Hostname         string      `json:"hostname"`
+	Workdir          string      `json:"workdir"`
+	RunnerID         string      `json:"runnerId,omitempty"`         // Same across reconnects and restarts (see internal/runnerid)
+	ProtocolVersion  int         `json:"protocolVersion"`            // Newest schema version the runner can emit; HELO_ACK picks the one used
+	MinSchemaVersion int         `json:"minSchemaVersion,omitempty"` // Oldest schema version the runner can emit
+	RunnerVersion    string      `json:"runnerVersion,omitempty"`    // Build information (see internal/version)
+	Commit           string      `json:"commit,omitempty"`
+	BuildDate        string      `json:"buildDate,omitempty"`
+	Claude           *ClaudeInfo `json:"claude,omitempty"` // Result of the startup probe of the claude CLI
+}
+
+// ClaudeInfo reports what the runner found out about the claude CLI that runs dynamic tasks
+type ClaudeInfo struct {
+	Present bool   `json:"present"`           // Binary found and answered --version; dynamic tasks are refused otherwise
+	Version string `json:"version,omitempty"` // Parsed "claude --version" output
+	AuthOK  bool   `json:"authOk"`            // Credentials appear to be configured
+}
+
+// HeloAckMessage is the backend's reply to HELO
+// ProtocolVersion selects the schema version the runner emits for the rest of the connection
+type HeloAckMessage struct {
+	Envelope
+	Type            string `json:"type"`
+	ProtocolVersion int    `json:"protocolVersion"`
 }
 
 // LogMessage represents a log line from task execution
+// IsError reflects the pipe the line came from (stderr); Severity reflects its content
 type LogMessage struct {
-	Type    string `json:"type"`
-	TaskID  int64  `json:"taskId"`
-	Line    string `json:"line"`
-	IsError bool   `json:"isError"`
+	Envelope
+	Type     string            `json:"type"`
+	TaskID   int64             `json:"taskId"`
+	Line     string            `json:"line"`
+	IsError  bool              `json:"isError"`            // Kept for older backends: true for stream "stderr" and the runner's errors
+	Stream   string            `json:"stream,omitempty"`   // "stdout" or "stderr" for task output, "system" for lines the runner writes
+	Severity string            `json:"severity,omitempty"` // "debug", "info", "warn" or "error"
+	Metadata map[string]string `json:"metadata,omitempty"` // Echo of the task's EXECUTE metadata
+
+	LineIndex int64 `json:"lineIndex"`          // Position of the line in the task's output, from 0
+	Replayed  bool  `json:"replayed,omitempty"` // Resent in answer to RESUME_LOGS (isError, stream and severity are not kept)
+	Skipped   int64 `json:"skipped,omitempty"`  // On a "[runner] …skipped N lines…" line: the N output lines it stands in for
+	System    bool  `json:"system,omitempty"`   // A notice from the runner itself rather than task output; TaskID is 0
 }
 
 // StatusUpdateMessage represents a task status change
 type StatusUpdateMessage struct {
-	Type   string `json:"type"`
-	TaskID int64  `json:"taskId"`
-	Status string `json:"status"`
+	Envelope
+	Type      string            `json:"type"`
+	TaskID    int64             `json:"taskId"`
+	Status    string            `json:"status"`
+	ResetAt   string            `json:"resetAt,omitempty"`   // RFC3339 quota reset time for USAGE_LIMITED, when known
+	Detection *DetectionInfo    `json:"detection,omitempty"` // What triggered a detection-driven status change
+	Metadata  map[string]string `json:"metadata,omitempty"`  // Echo of the task's EXECUTE metadata
+	MessageID string            `json:"messageId,omitempty"` // Set on final statuses when the delivery journal is on; ACK names it
+}
+
+// DetectionInfo describes the output line that triggered a detection
+// All fields are optional so backends that predate them can ignore the object
+type DetectionInfo struct {
+	Pattern    string `json:"pattern"`    // Name of the matching pattern
+	Category   string `json:"category"`   // Detection category (e.g. "RATE_LIMIT")
+	Matched    string `json:"matched"`    // Matched substring, after secret masking
+	LineNumber int64  `json:"lineNumber"` // 1-based line number within the task's output (both streams)
+	Index      int    `json:"index"`      // Byte offset of the match within the line
 }
 
 // ExecuteMessage represents a command from backend to execute a task
 type ExecuteMessage struct {
+	Envelope
 	Type            string `json:"type"`
 	TaskID          int64  `json:"taskId"`
 	Script          string `json:"script"`          // Legacy: file path to script
 	ScriptContent   string `json:"scriptContent"`   // New: inline script/prompt content
 	SkipPermissions bool   `json:"skipPermissions"` // Whether to use --dangerously-skip-permissions
 	SessionMode     string `json:"sessionMode"`     // "NEW" or "PERSIST"
+	Host            string `json:"host,omitempty"`  // SSH host (from the runner's hosts file) to run the task on; empty runs it locally
+	// Claude session a PERSIST task continues, shared by the tasks naming the same key; empty is the
+	// runner's own session. Local claude tasks only.
+	SessionKey string `json:"sessionKey,omitempty"`
+	// Values for {{name}} placeholders in scriptContent; when present, scriptContent is a template
+	// Values are masked in the task's output and the audit log unless named in publicVariables.
+	Variables       map[string]string `json:"variables,omitempty"`
+	PublicVariables []string          `json:"publicVariables,omitempty"` // Variables whose values may appear in logs
+	// Git repository checked out as the task's working directory (local tasks only), at ref
+	// (branch, tag or commit; default the remote HEAD) and, when depth > 0, with that much history
+	Repo  string `json:"repo,omitempty"`
+	Ref   string `json:"ref,omitempty"`
+	Depth int    `json:"depth,omitempty"`
+	// Existing directory on the runner the task runs in, instead of the runner's own (inline tasks)
+	// or the script's (script-path tasks); not with repo, which sets its own
+	WorkingDir string `json:"workingDir,omitempty"`
+	// Standard input of the task's process; without it the process reads from /dev/null
+	StdinContent string `json:"stdinContent,omitempty"`
+	// Program the task runs under, from the runner's allowed interpreters: given the script path, or
+	// scriptContent as its prompt (claude) or with -c (any other). Empty runs bash or claude.
+	Interpreter string `json:"interpreter,omitempty"`
+	// Claude model the task runs on, passed to claude as --model; one of the runner's allowed models
+	// when it restricts them. Empty leaves claude's default.
+	Model string `json:"model,omitempty"`
+	// Output claude is asked for: "text" (the default), or "json" for its stream-json events, which the
+	// runner turns into LOG lines (assistant text), TOOL_EVENT messages and TASK_COMPLETED's claudeResult
+	OutputFormat string `json:"outputFormat,omitempty"`
+	// Bytes of output lines the task may send before the rest is discarded, with a LOG line saying how
+	// much; 0 uses the runner's AAW_MAX_TASK_OUTPUT_BYTES
+	MaxOutputBytes int64 `json:"maxOutputBytes,omitempty"`
+	// Seconds without output after which the task is reported STALLED; 0 uses the runner's
+	// AAW_STALL_THRESHOLD
+	StallThresholdSeconds int64 `json:"stallThresholdSeconds,omitempty"`
+	// What a stall does besides the STALLED status: "notify" (the default) or "kill", which cancels the
+	// task as if CANCEL_TASK had come from runner:stall-policy
+	StallAction string `json:"stallAction,omitempty"`
+	// Positional arguments of the script, after the script path or scriptContent; each is one argv
+	// entry, never joined into a shell command line
+	Args []string `json:"args,omitempty"`
+	// Run the task on a pseudo-terminal, for programs that act differently on a pipe; its stdout and
+	// stderr then arrive interleaved as one stream, none of it marked isError
+	PTY bool `json:"pty,omitempty"`
+	// Send the task's stderr to the same pipe as its stdout, so its output is one stream in the order it
+	// was printed, none of it marked isError (a task with pty has one stream already)
+	CombineOutput bool `json:"combineOutput,omitempty"`
+	// Keep the task's stdin open for STDIN_INPUT messages, to answer its prompts; not with
+	// stdinContent or pty
+	Interactive bool `json:"interactive,omitempty"`
+	// Reservation (RESERVE_SLOT) the task takes its slot from, so it is accepted even when every
+	// unreserved slot is busy; an unknown or expired one leaves the task to regular capacity
+	ReservationID string `json:"reservationId,omitempty"`
+	// Environment variables set for the task's process, over those the runner inherited
+	// Their values are never logged, and hidden in the audit log.
+	Env map[string]string `json:"env,omitempty"`
+	// Opaque correlation data echoed back on every message about the task
+	// (capped at executor.MaxMetadataKeys keys and executor.MaxMetadataValueBytes per value)
+	Metadata map[string]string `json:"metadata,omitempty"`
 }
 
+// InterpreterClaude is the interpreter inline tasks run under by default: the Claude CLI, given
+// scriptContent as its prompt
+const InterpreterClaude = "claude"
+
+// Session modes accepted on EXECUTE (empty means the default, NEW)
+const (
+	SessionModeNew     = "NEW"
+	SessionModePersist = "PERSIST"
+)
+
 // RunnerStatusMessage represents the runner's current state
 type RunnerStatusMessage struct {
-	Type   string `json:"type"`
-	Status string `json:"status"` // "IDLE" or "BUSY"
+	Envelope
+	Type     string `json:"type"`
+	Status   string `json:"status"`             // "IDLE" or "BUSY"
+	RunnerID string `json:"runnerId,omitempty"` // As sent in HELO
 }
 
 // TaskCompletedMessage represents task completion notification
 type TaskCompletedMessage struct {
-	Type    string `json:"type"`
-	TaskID  int64  `json:"taskId"`
-	Success bool   `json:"success"`
-	Error   string `json:"error,omitempty"` // Optional error message
+	Envelope
+	Type           string            `json:"type"`
+	TaskID         int64             `json:"taskId"`
+	Success        bool              `json:"success"`
+	ExitCode       int               `json:"exitCode"`                 // Exit status of the task's process, 128+N when signal N killed it, -1 when it never ran
+	Error          string            `json:"error,omitempty"`          // Optional error message (legacy, human-readable)
+	ErrorCode      string            `json:"errorCode,omitempty"`      // Machine-readable failure code (ErrorCode*)
+	ErrorDetail    string            `json:"errorDetail,omitempty"`    // Last stderr lines of a failed task, oldest first
+	Classification string            `json:"classification,omitempty"` // Failure classification (e.g. "OOM")
+	Evidence       string            `json:"evidence,omitempty"`       // What led to the classification
+	Metadata       map[string]string `json:"metadata,omitempty"`       // Echo of the task's EXECUTE metadata
+	LogURL         string            `json:"logUrl,omitempty"`         // Where the task's output log is being uploaded
+	RequestedBy    string            `json:"requestedBy,omitempty"`    // Who cancelled or killed the task
+	Reason         string            `json:"reason,omitempty"`         // Why the task was cancelled or killed
+	MessageID      string            `json:"messageId,omitempty"`      // Set when the delivery journal is on; ACK names it
+
+	// Final resource usage of the task's process, from its rusage; omitted when it never ran or the
+	// platform has no rusage. They include the descendants the process waited for, but maxRssKb is the
+	// peak of the largest single process (on Linux usually the direct child), not of the whole tree.
+	UserCPUMs   *int64 `json:"userCpuMs,omitempty"`
+	SystemCPUMs *int64 `json:"systemCpuMs,omitempty"`
+	MaxRSSKb    *int64 `json:"maxRssKb,omitempty"`
+
+	// Bytes of output lines (without line endings) the task sent as LOG, and those it printed past its
+	// output cap, which were discarded
+	OutputBytes          int64 `json:"outputBytes,omitempty"`
+	DiscardedOutputBytes int64 `json:"discardedOutputBytes,omitempty"`
+
+	// Final result claude reported, for tasks run with outputFormat json that got as far as one
+	ClaudeResult *ClaudeResult `json:"claudeResult,omitempty"`
+}
+
+// ClaudeResult is the result event ending claude's stream-json output
+type ClaudeResult struct {
+	Subtype       string       `json:"subtype"`          // "success", or why the run stopped (e.g. "error_max_turns")
+	IsError       bool         `json:"isError"`          // Claude reported the run as failed
+	Result        string       `json:"result,omitempty"` // Final assistant text
+	NumTurns      int          `json:"numTurns"`
+	DurationMs    int64        `json:"durationMs"`
+	DurationAPIMs int64        `json:"durationApiMs"` // Time spent waiting on the API
+	TotalCostUSD  float64      `json:"totalCostUsd"`
+	SessionID     string       `json:"sessionId,omitempty"`
+	Usage         *ClaudeUsage `json:"usage,omitempty"`
+}
+
+// ClaudeUsage counts the tokens of a claude run
+type ClaudeUsage struct {
+	InputTokens              int64 `json:"inputTokens"`
+	OutputTokens             int64 `json:"outputTokens"`
+	CacheCreationInputTokens int64 `json:"cacheCreationInputTokens"`
+	CacheReadInputTokens     int64 `json:"cacheReadInputTokens"`
 }
 
+// TaskStartedMessage reports that a queued task has started executing
+type TaskStartedMessage struct {
+	Envelope
+	Type     string            `json:"type"`
+	TaskID   int64             `json:"taskId"`
+	Metadata map[string]string `json:"metadata,omitempty"` // Echo of the task's EXECUTE metadata
+}
+
+// Failure codes reported as errorCode on TASK_COMPLETED
+const (
+	ErrorCodeCancelled      = "CANCELLED"       // Cancelled on request
+	ErrorCodeTimeout        = "TIMEOUT"         // Exceeded its time limit
+	ErrorCodeStartFailed    = "START_FAILED"    // Process could not be started (missing script/binary, pipe errors)
+	ErrorCodePolicyRejected = "POLICY_REJECTED" // Refused by runner policy (e.g. circuit breaker open)
+	ErrorCodeCapacity       = "AT_CAPACITY"     // Refused because no slot or queue space was free
+	ErrorCodeRunnerShutdown = "RUNNER_SHUTDOWN" // Runner stopped before the task could run
+	ErrorCodeExitNonzero    = "EXIT_NONZERO"    // Process exited unsuccessfully (non-zero status or signal)
+	ErrorCodeEnvironment    = "ENVIRONMENT"     // Runner host is missing something the task needs (e.g. the claude CLI)
+	ErrorCodeInternal       = "INTERNAL"        // Any other runner-side failure
+	ErrorCodeInvalidTask    = "INVALID_TASK"    // The EXECUTE cannot be run as sent (a variable is missing, scriptContent is too large)
+	ErrorCodeCheckout       = "CHECKOUT_FAILED" // The task's repo could not be fetched or checked out; nothing was run
+
+	// Remote (SSH) execution failures
+	ErrorCodeSSHConnect        = "SSH_CONNECT_FAILED"  // Could not reach or authenticate to the task's host
+	ErrorCodeSSHHostKey        = "SSH_HOST_KEY"        // The host's key is unknown or does not match the known_hosts file
+	ErrorCodeSSHConnectionLost = "SSH_CONNECTION_LOST" // The connection dropped while the task was running; its outcome is unknown
+)
+
+// Failure classifications reported on TASK_COMPLETED
+const (
+	ClassificationOOM       = "OOM"        // Killed (or very likely killed) for running out of memory
+	ClassificationAuthError = "AUTH_ERROR" // Failed after an authentication/credential error
+)
+
 // Task status constants
 const (
-	StatusPending     = "PENDING"
-	StatusRunning     = "RUNNING"
-	StatusPaused      = "PAUSED"
-	StatusRateLimited = "RATE_LIMITED"
-	StatusCompleted   = "COMPLETED"
-	StatusFailed      = "FAILED"
-	StatusCancelled   = "CANCELLED"
+	StatusPending      = "PENDING"
+	StatusRunning      = "RUNNING"
+	StatusPaused       = "PAUSED"
+	StatusRateLimited  = "RATE_LIMITED"
+	StatusUsageLimited = "USAGE_LIMITED" // Subscription/usage quota exhausted until its reset time
+	StatusAuthError    = "AUTH_ERROR"    // Credentials rejected or expired; needs operator attention
+	StatusStalled      = "STALLED"       // No output for the task's stall threshold; RUNNING clears it
+	StatusCompleted    = "COMPLETED"
+	StatusFailed       = "FAILED"
+	StatusCancelled    = "CANCELLED"
+)
+
+// Synthetic requesters of cancellations and kills that did not come with a requestedBy
+const (
+	RequestedByBackend      = "backend"              // CANCEL_TASK/KILL_TASK without a requestedBy
+	RequestedByOperator     = "runner:operator"      // Admin API, control socket or TUI
+	RequestedByShutdown     = "runner:shutdown"      // Runner shutting down
+	RequestedByInterrupt    = "runner:interrupt"     // Interrupted run-once invocation
+	RequestedByOrphanPolicy = "runner:orphan-policy" // Backend unreachable for longer than the orphan policy allows
+	RequestedByStallPolicy  = "runner:stall-policy"  // Stalled task whose EXECUTE set stallAction kill
 )
 
 // CancelTaskMessage represents a request to gracefully cancel a task
 type CancelTaskMessage struct {
-	Type   string `json:"type"`
-	TaskID int64  `json:"taskId"`
+	Envelope
+	Type        string `json:"type"`
+	TaskID      int64  `json:"taskId"`
+	RequestedBy string `json:"requestedBy,omitempty"` // User or service asking for the cancellation
+	Reason      string `json:"reason,omitempty"`
 }
 
 // KillTaskMessage represents a request to forcefully kill a task
 type KillTaskMessage struct {
-	Type   string `json:"type"`
-	TaskID int64  `json:"taskId"`
+	Envelope
+	Type        string `json:"type"`
+	TaskID      int64  `json:"taskId"`
+	RequestedBy string `json:"requestedBy,omitempty"` // User or service asking for the kill
+	Reason      string `json:"reason,omitempty"`
 }
 
 // CancelAckMessage represents acknowledgment of cancel/kill request
 type CancelAckMessage struct {
+	Envelope
 	Type    string `json:"type"`
 	TaskID  int64  `json:"taskId"`
 	Status  string `json:"status"`          // "CANCELLED" or "KILLED"
 	Success bool   `json:"success"`
 	Error   string `json:"error,omitempty"`
+
+	RequestedBy string `json:"requestedBy,omitempty"` // Echo of the request's requestedBy
+	Reason      string `json:"reason,omitempty"`
 }
 
 // TaskTerminatedMessage represents explicit ACK after task termination for safe deletion
 // Used by backend to wait for confirmation before soft-deleting task record
 type TaskTerminatedMessage struct {
+	Envelope
 	Type    string `json:"type"`
 	TaskID  int64  `json:"taskId"`
 	Status  string `json:"status"`          // "KILLED"
 	Success bool   `json:"success"`
 	Error   string `json:"error,omitempty"`
+
+	RequestedBy string `json:"requestedBy,omitempty"` // Echo of the request's requestedBy
+	Reason      string `json:"reason,omitempty"`
 }
 
 // RunnerCapacityMessage represents the runner's capacity for concurrent tasks
+// Reserved slots are held for RESERVE_SLOT reservations and not counted in AvailableSlots. The
+// capacity sent on connect also lists the reservations held, as they survive a reconnect.
 type RunnerCapacityMessage struct {
+	Envelope
+	Type           string        `json:"type"`
+	MaxParallel    int           `json:"maxParallel"`
+	RunningTasks   int           `json:"runningTasks"`
+	AvailableSlots int           `json:"availableSlots"`
+	Reserved       int           `json:"reserved"`
+	Reservations   []Reservation `json:"reservations,omitempty"`
+	RunnerID       string        `json:"runnerId,omitempty"` // As sent in HELO
+}
+
+// Reservation is a reservation held by the runner, as listed in RUNNER_CAPACITY
+type Reservation struct {
+	ReservationID string `json:"reservationId"`
+	Weight        int    `json:"weight"`
+	ExpiresAt     string `json:"expiresAt"` // RFC3339
+}
+
+// RunnerDrainingMessage announces a graceful shutdown: no new tasks are accepted, and running tasks
+// get GraceSeconds to finish before they are cancelled
+type RunnerDrainingMessage struct {
+	Envelope
+	Type         string `json:"type"`
+	RunningTasks int    `json:"runningTasks"`
+	GraceSeconds int    `json:"graceSeconds"`
+}
+
+// ByeMessage is the last message of a graceful shutdown, sent once every task has been reported
+type ByeMessage struct {
+	Envelope
 	Type           string `json:"type"`
-	MaxParallel    int    `json:"maxParallel"`
-	RunningTasks   int    `json:"runningTasks"`
-	AvailableSlots int    `json:"availableSlots"`
+	Drained        bool   `json:"drained"`        // Every task finished within the grace period
+	CancelledTasks int    `json:"cancelledTasks"` // Tasks cancelled when the grace period ran out
+}
+
+// GoodbyeMessage is the last message on every connection the runner closes itself, graceful or not
+// UnfinishedTasks lists the tasks the runner accepted but never reported complete; the backend should
+// treat them as lost.
+type GoodbyeMessage struct {
+	Envelope
+	Type            string  `json:"type"`
+	Reason          string  `json:"reason"` // One of the Goodbye* reasons
+	UnfinishedTasks []int64 `json:"unfinishedTasks"`
+}
+
+// RunnerResyncMessage follows RUNNER_CAPACITY on every reconnect, listing the tasks the runner still holds
+// so the backend can reconcile what it recorded while the connection was down. The capacity is read at
+// the same instant as the tasks.
+type RunnerResyncMessage struct {
+	Envelope
+	Type           string       `json:"type"`
+	Tasks          []ResyncTask `json:"tasks"`
+	MaxParallel    int          `json:"maxParallel"`
+	RunningTasks   int          `json:"runningTasks"`
+	AvailableSlots int          `json:"availableSlots"`
+	RunnerID       string       `json:"runnerId,omitempty"` // As sent in HELO
+}
+
+// ResyncTask is a task the runner holds, as listed in RUNNER_RESYNC
+type ResyncTask struct {
+	TaskID    int64  `json:"taskId"`
+	State     string `json:"state"`               // One of the Resync* states
+	StartedAt string `json:"startedAt,omitempty"` // RFC3339, once a worker has started the task
+	ElapsedMs int64  `json:"elapsedMs"`           // How long it has been running (0 while queued)
+}
+
+// RunnerHeartbeatMessage is sent every HeartbeatInterval while connected, so the backend can tell a
+// runner that is alive and keeping up from one whose connection merely stays open
+type RunnerHeartbeatMessage struct {
+	Envelope
+	Type           string  `json:"type"`
+	UptimeSeconds  int64   `json:"uptimeSeconds"` // Since the runner started, across reconnects
+	MaxParallel    int     `json:"maxParallel"`
+	RunningTasks   int     `json:"runningTasks"`
+	AvailableSlots int     `json:"availableSlots"`
+	QueuedTasks    int     `json:"queuedTasks"`        // Accepted, waiting for a worker
+	LoadAverage1   float64 `json:"loadAverage1"`       // The host's 1-minute load average (0 where it cannot be read)
+	WriteP95Millis int64   `json:"writeP95Ms"`         // 95th percentile of recent message write latencies
+	Degraded       bool    `json:"degraded,omitempty"` // Writes are slow enough that task output is being sampled
+	RunnerID       string  `json:"runnerId,omitempty"` // As sent in HELO
+}
+
+// AckMessage confirms the backend stored the TASK_COMPLETED or STATUS_UPDATE carrying MessageID
+// Until it arrives the runner re-sends the message on every connect, so the backend may see a
+// messageId twice and should store it once.
+type AckMessage struct {
+	Envelope
+	Type      string `json:"type"`
+	MessageID string `json:"messageId"`
+}
+
+// AuthChallengeMessage asks the runner, right after HELO, to sign Nonce with its shared secret
+// A backend that accepts the answer sends AUTH_OK; one that does not closes the connection.
+type AuthChallengeMessage struct {
+	Envelope
+	Type  string `json:"type"`
+	Nonce string `json:"nonce"`
+}
+
+// AuthResponseMessage answers AUTH_CHALLENGE with the hex HMAC-SHA256 of its nonce (see AuthHMAC)
+type AuthResponseMessage struct {
+	Envelope
+	Type     string `json:"type"`
+	Nonce    string `json:"nonce"`
+	HMAC     string `json:"hmac"`
+	RunnerID string `json:"runnerId,omitempty"` // As sent in HELO
+}
+
+// AuthOKMessage accepts the runner's AUTH_RESPONSE
+type AuthOKMessage struct {
+	Envelope
+	Type string `json:"type"`
+}
+
+// ShutdownMessage asks the runner to drain and exit: running tasks get GraceSeconds to finish (none
+// when 0) before they are cancelled, then the runner sends BYE and GOODBYE and the process exits
+type ShutdownMessage struct {
+	Envelope
+	Type         string `json:"type"`
+	GraceSeconds int    `json:"graceSeconds"`
+	Reason       string `json:"reason,omitempty"` // Why the backend wants the runner gone, for the log
+}
+
+// StdinInputMessage writes Data to the stdin of a running task started with interactive set, closing
+// it afterwards when CloseAfter is set; the runner answers with STDIN_INPUT_RESULT
+type StdinInputMessage struct {
+	Envelope
+	Type       string `json:"type"`
+	TaskID     int64  `json:"taskId"`
+	Data       string `json:"data"`
+	CloseAfter bool   `json:"closeAfter,omitempty"`
+}
+
+// StdinInputResultMessage answers STDIN_INPUT
+type StdinInputResultMessage struct {
+	Envelope
+	Type    string `json:"type"`
+	TaskID  int64  `json:"taskId"`
+	Success bool   `json:"success"`
+	Bytes   int    `json:"bytes"`           // Bytes of data written, all of them when Success is true
+	Error   string `json:"error,omitempty"` // Why the data was not (all) written, when Success is false
+}
+
+// ToolEventMessage reports a tool claude used in a task run with outputFormat json: its input when
+// claude asks for it (USE), then its output (RESULT). Both are masked like LOG lines.
+type ToolEventMessage struct {
+	Envelope
+	Type      string            `json:"type"`
+	TaskID    int64             `json:"taskId"`
+	Phase     string            `json:"phase"`               // ToolPhaseUse or ToolPhaseResult
+	ToolUseID string            `json:"toolUseId,omitempty"` // Pairs a RESULT with its USE
+	Tool      string            `json:"tool,omitempty"`      // Tool name (USE)
+	Input     string            `json:"input,omitempty"`     // Tool input, as JSON (USE)
+	Output    string            `json:"output,omitempty"`    // Text the tool returned (RESULT)
+	IsError   bool              `json:"isError,omitempty"`   // The tool failed (RESULT)
+	Metadata  map[string]string `json:"metadata,omitempty"`  // Echo of the task's EXECUTE metadata
+}
+
+// Streams a LOG line comes from
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+	StreamSystem = "system" // Written by the runner about the task (or about itself), not by the task
+)
+
+// Tool event phases
+const (
+	ToolPhaseUse    = "USE"
+	ToolPhaseResult = "RESULT"
+)
+
+// Stall actions accepted on EXECUTE (empty means the default, notify)
+const (
+	StallActionNotify = "notify"
+	StallActionKill   = "kill"
+)
+
+// Output formats accepted on EXECUTE (empty means the default, text)
+const (
+	OutputFormatText = "text"
+	OutputFormatJSON = "json"
+)
+
+// MessageErrorMessage reports an incoming message the runner could not accept
+// MessageType and TaskID are filled in when they could be read from the rejected frame
+type MessageErrorMessage struct {
+	Envelope
+	Type        string `json:"type"`
+	MessageType string `json:"messageType,omitempty"`
+	TaskID      int64  `json:"taskId,omitempty"`
+	Code        string `json:"code"`  // One of the MessageError* codes
+	Error       string `json:"error"` // Human-readable reason
+}
+
+// ResumeLogsMessage asks for a running task's output after LastLineIndex to be sent again
+// LastLineIndex is the last line the backend has, or -1 for none.
+type ResumeLogsMessage struct {
+	Envelope
+	Type          string `json:"type"`
+	TaskID        int64  `json:"taskId"`
+	LastLineIndex int64  `json:"lastLineIndex"`
+}
+
+// ResumeLogsResultMessage answers RESUME_LOGS
+// On success lines FromIndex to NextIndex-1 follow as LOG messages with replayed set, interleaved with
+// live output (which continues from NextIndex). Otherwise Code says why nothing can be replayed and
+// FirstAvailableIndex is the oldest line that could be.
+type ResumeLogsResultMessage struct {
+	Envelope
+	Type                string `json:"type"`
+	TaskID              int64  `json:"taskId"`
+	Success             bool   `json:"success"`
+	FromIndex           int64  `json:"fromIndex"`           // First replayed line
+	NextIndex           int64  `json:"nextIndex"`           // Index of the next live line
+	FirstAvailableIndex int64  `json:"firstAvailableIndex"` // Oldest line the runner can replay
+	Code                string `json:"code,omitempty"`      // One of the Resume* codes when Success is false
+	Error               string `json:"error,omitempty"`
 }
+
+// RecurringExecuteMessage registers Task to be run by the runner itself on a schedule: every
+// IntervalSeconds, or at the times matching Cron (five fields: minute hour day-of-month month
+// day-of-week, in the runner's local time). Task's taskId is ignored; each instance gets its own.
+// Registering an existing RecurrenceID replaces it.
+type RecurringExecuteMessage struct {
+	Envelope
+	Type            string         `json:"type"`
+	RecurrenceID    string         `json:"recurrenceId"`
+	Cron            string         `json:"cron,omitempty"`
+	IntervalSeconds int64          `json:"intervalSeconds,omitempty"`
+	Task            ExecuteMessage `json:"task"`
+}
+
+// CancelRecurringMessage removes a registered recurrence; instances already started are not affected
+type CancelRecurringMessage struct {
+	Envelope
+	Type         string `json:"type"`
+	RecurrenceID string `json:"recurrenceId"`
+}
+
+// RecurrenceFiredMessage announces an instance of a recurrence, just before its task is submitted
+// The task is then reported like any other, under TaskID.
+type RecurrenceFiredMessage struct {
+	Envelope
+	Type         string `json:"type"`
+	RecurrenceID string `json:"recurrenceId"`
+	InstanceID   string `json:"instanceId"`        // recurrenceId plus a runner-generated suffix, unique per instance
+	TaskID       int64  `json:"taskId"`            // Runner-assigned ID of the instance's task
+	ScheduledAt  string `json:"scheduledAt"`       // RFC3339 time of the tick the instance is for
+	CatchUp      bool   `json:"catchUp,omitempty"` // Run late, for ticks missed while disconnected or draining
+}
+
+// ReserveSlotMessage asks the runner to hold Weight slots (default 1) for TTLSeconds, until an EXECUTE
+// names ReservationID or RELEASE_SLOT gives them back. Reserving an ID already held replaces it.
+type ReserveSlotMessage struct {
+	Envelope
+	Type          string `json:"type"`
+	ReservationID string `json:"reservationId"`
+	TTLSeconds    int64  `json:"ttlSeconds"`
+	Weight        int    `json:"weight,omitempty"`
+}
+
+// ReleaseSlotMessage gives back a reservation that will not be used
+type ReleaseSlotMessage struct {
+	Envelope
+	Type          string `json:"type"`
+	ReservationID string `json:"reservationId"`
+}
+
+// ReserveSlotResultMessage answers RESERVE_SLOT
+type ReserveSlotResultMessage struct {
+	Envelope
+	Type          string `json:"type"`
+	ReservationID string `json:"reservationId"`
+	Success       bool   `json:"success"`
+	ExpiresAt     string `json:"expiresAt,omitempty"` // RFC3339, when Success is true
+	Code          string `json:"code,omitempty"`      // One of the Reserve* codes when Success is false
+	Error         string `json:"error,omitempty"`
+}
+
+// ReservationExpiredMessage reports a reservation the runner dropped before an EXECUTE used it
+type ReservationExpiredMessage struct {
+	Envelope
+	Type          string `json:"type"`
+	ReservationID string `json:"reservationId"`
+	Reason        string `json:"reason"` // One of the Expired* reasons
+}
+
+// RESERVE_SLOT_RESULT codes
+const (
+	ReserveDraining     = "DRAINING"              // The runner is draining and takes no new work
+	ReserveInsufficient = "INSUFFICIENT_CAPACITY" // Fewer unreserved slots are free than the weight asked for
+)
+
+// RESERVATION_EXPIRED reasons
+const (
+	ExpiredTTL      = "TTL"      // No EXECUTE used the reservation within its ttlSeconds
+	ExpiredDraining = "DRAINING" // The runner started draining
+)
+
+// RUNNER_RESYNC task states
+const (
+	ResyncQueued     = "QUEUED"     // Accepted, waiting for a worker
+	ResyncRunning    = "RUNNING"    // Started by a worker
+	ResyncCancelling = "CANCELLING" // A cancel is in progress
+)
+
+// GOODBYE reasons
+const (
+	GoodbyeShutdown = "SHUTDOWN" // Graceful shutdown, after BYE
+	GoodbyeForced   = "FORCED"   // Forced shutdown, after BYE
+	GoodbyeClosed   = "CLOSED"   // The runner closed without a shutdown
+)
+
+// RESUME_LOGS_RESULT codes
+const (
+	ResumeNotRunning   = "NOT_RUNNING"   // The task is not running on this runner
+	ResumeNotPersisted = "NOT_PERSISTED" // The task's output is not kept locally (task logs are off or failed)
+	ResumeOutOfRange   = "OUT_OF_RANGE"  // lastLineIndex is past the task's output
+)
+
+// MESSAGE_ERROR codes
+const (
+	MessageErrorMalformed   = "MALFORMED"    // Frame is not valid JSON for its type
+	MessageErrorUnknownType = "UNKNOWN_TYPE" // Type field names a message the runner does not handle
+	MessageErrorInvalid     = "INVALID"      // Parsed, but failed validation
+
+	MessageErrorUnsupportedVersion = "UNSUPPORTED_VERSION" // Newer schemaVersion than the runner understands
+	MessageErrorTooLarge           = "TOO_LARGE"           // Frame over the runner's read limit; the connection was dropped
+)
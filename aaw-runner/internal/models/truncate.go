@@ -0,0 +1,77 @@
+package models
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// Default byte limits for free-text fields on outbound messages
+const (
+	DefaultMaxErrorBytes = 4 * 1024
+	DefaultMaxLineBytes  = 16 * 1024
+)
+
+// FieldLimits caps the size of free-text fields on outbound messages (0 disables a limit)
+type FieldLimits struct {
+	Error int // error strings (TASK_COMPLETED's error and errorDetail, CANCEL_ACK, TASK_TERMINATED, MESSAGE_ERROR)
+	Line  int // LOG lines
+}
+
+// DefaultFieldLimits returns the limits used when none are configured
+func DefaultFieldLimits() FieldLimits {
+	return FieldLimits{Error: DefaultMaxErrorBytes, Line: DefaultMaxLineBytes}
+}
+
+// Truncatable is implemented by outbound messages carrying free-text fields
+// TruncateFields shortens oversized fields in place and returns the names of the fields it cut
+type Truncatable interface {
+	TruncateFields(limits FieldLimits) []string
+}
+
+// TruncateText keeps at most max bytes of s, cutting at a rune boundary and appending
+// "…[truncated N bytes]" where N is the number of bytes dropped. Returns s unchanged when it fits
+func TruncateText(s string, max int) (string, bool) {
+	if max <= 0 || len(s) <= max {
+		return s, false
+	}
+	n := max
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + fmt.Sprintf("…[truncated %d bytes]", len(s)-n), true
+}
+
+// truncateField truncates *field in place, appending name to cut if it was shortened
+func truncateField(cut []string, name string, field *string, max int) []string {
+	if truncated, ok := TruncateText(*field, max); ok {
+		*field = truncated
+		return append(cut, name)
+	}
+	return cut
+}
+
+func (m *LogMessage) TruncateFields(limits FieldLimits) []string {
+	return truncateField(nil, "line", &m.Line, limits.Line)
+}
+
+func (m *TaskCompletedMessage) TruncateFields(limits FieldLimits) []string {
+	cut := truncateField(nil, "error", &m.Error, limits.Error)
+	return truncateField(cut, "errorDetail", &m.ErrorDetail, limits.Error)
+}
+
+func (m *ToolEventMessage) TruncateFields(limits FieldLimits) []string {
+	cut := truncateField(nil, "input", &m.Input, limits.Line)
+	return truncateField(cut, "output", &m.Output, limits.Line)
+}
+
+func (m *CancelAckMessage) TruncateFields(limits FieldLimits) []string {
+	return truncateField(nil, "error", &m.Error, limits.Error)
+}
+
+func (m *TaskTerminatedMessage) TruncateFields(limits FieldLimits) []string {
+	return truncateField(nil, "error", &m.Error, limits.Error)
+}
+
+func (m *MessageErrorMessage) TruncateFields(limits FieldLimits) []string {
+	return truncateField(nil, "error", &m.Error, limits.Error)
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestTruncateText_Marker verifies the exact marker format and byte count
+func TestTruncateText_Marker(t *testing.T) {
+	got, ok := TruncateText("abcdefghij", 4)
+
+	assert.True(t, ok)
+	assert.Equal(t, "abcd…[truncated 6 bytes]", got)
+}
+
+// TestTruncateText_FitsUnchanged verifies short text and disabled limits are left alone
+func TestTruncateText_FitsUnchanged(t *testing.T) {
+	got, ok := TruncateText("abcd", 4)
+	assert.False(t, ok)
+	assert.Equal(t, "abcd", got)
+
+	got, ok = TruncateText(strings.Repeat("x", 100), 0)
+	assert.False(t, ok)
+	assert.Len(t, got, 100)
+}
+
+// TestTruncateText_MultiByteBoundary verifies cuts never split a multi-byte character
+func TestTruncateText_MultiByteBoundary(t *testing.T) {
+	// "한" is 3 bytes, "😀" is 4 bytes
+	cases := []struct {
+		in   string
+		max  int
+		want string
+	}{
+		{"한글한글", 4, "한…[truncated 9 bytes]"},
+		{"한글한글", 5, "한…[truncated 9 bytes]"},
+		{"한글한글", 6, "한글…[truncated 6 bytes]"},
+		{"a😀b", 2, "a…[truncated 5 bytes]"},
+		{"a😀b", 5, "a😀…[truncated 1 bytes]"},
+		{"😀😀", 3, "…[truncated 8 bytes]"},
+	}
+
+	for _, tc := range cases {
+		got, ok := TruncateText(tc.in, tc.max)
+		assert.True(t, ok, tc.in)
+		assert.Equal(t, tc.want, got, "%q at %d", tc.in, tc.max)
+		assert.True(t, utf8.ValidString(got), "%q at %d", tc.in, tc.max)
+	}
+}
+
+// TestTruncateFields_Messages verifies each message truncates its free-text field and reports it
+func TestTruncateFields_Messages(t *testing.T) {
+	limits := FieldLimits{Error: 3, Line: 5}
+	long := strings.Repeat("x", 10)
+
+	logMsg := NewLogMessage(1, long, true)
+	assert.Equal(t, []string{"line"}, logMsg.TruncateFields(limits))
+	assert.Equal(t, "xxxxx…[truncated 5 bytes]", logMsg.Line)
+
+	completed := NewTaskCompleted(1, false)
+	completed.Error = long
+	assert.Equal(t, []string{"error"}, completed.TruncateFields(limits))
+	assert.Equal(t, "xxx…[truncated 7 bytes]", completed.Error)
+	completed.Error, completed.ErrorDetail = "", long
+	assert.Equal(t, []string{"errorDetail"}, completed.TruncateFields(limits))
+
+	ack := NewCancelAck(1, "CANCELLED", false, "ok")
+	assert.Empty(t, ack.TruncateFields(limits), "Short fields are not reported")
+	assert.Equal(t, "ok", ack.Error)
+}
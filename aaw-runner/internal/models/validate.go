@@ -0,0 +1,669 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/berno/aaw-runner/internal/cron"
+)
+
+// ErrInvalidMessage is returned by Validate when a parsed message violates the protocol
+var ErrInvalidMessage = errors.New("invalid message")
+
+// invalid builds a validation error for the given message type
+func invalid(msgType, format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s: %s", ErrInvalidMessage, msgType, fmt.Sprintf(format, args...))
+}
+
+// oneOf reports whether value is one of allowed
+func oneOf(value string, allowed ...string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return false
+}
+
+// checkHeader validates the fields shared by task-scoped messages
+func checkHeader(msgType, want string, taskID int64) error {
+	if msgType != want {
+		return invalid(want, "type is %q", msgType)
+	}
+	if taskID <= 0 {
+		return invalid(want, "taskId must be positive, got %d", taskID)
+	}
+	return nil
+}
+
+// Validate checks the HELO handshake
+func (m HeloMessage) Validate() error {
+	if m.Type != TypeHelo {
+		return invalid(TypeHelo, "type is %q", m.Type)
+	}
+	if m.Hostname == "" {
+		return invalid(TypeHelo, "hostname is required")
+	}
+	if m.ProtocolVersion < 0 {
+		return invalid(TypeHelo, "protocolVersion must not be negative, got %d", m.ProtocolVersion)
+	}
+	return nil
+}
+
+// Validate checks the HELO_ACK handshake reply
+func (m HeloAckMessage) Validate() error {
+	if m.Type != TypeHeloAck {
+		return invalid(TypeHeloAck, "type is %q", m.Type)
+	}
+	if m.ProtocolVersion <= 0 {
+		return invalid(TypeHeloAck, "protocolVersion must be positive, got %d", m.ProtocolVersion)
+	}
+	return nil
+}
+
+// Validate checks a LOG line
+func (m LogMessage) Validate() error {
+	if !m.System {
+		if err := checkHeader(m.Type, TypeLog, m.TaskID); err != nil {
+			return err
+		}
+	} else if m.Type != TypeLog {
+		return invalid(TypeLog, "type is %q", m.Type)
+	} else if m.TaskID != 0 {
+		return invalid(TypeLog, "a system line belongs to no task, got taskId %d", m.TaskID)
+	}
+	if m.Severity != "" && !oneOf(m.Severity, Severities...) {
+		return invalid(TypeLog, "unknown severity %q", m.Severity)
+	}
+	if m.Stream != "" && !oneOf(m.Stream, Streams...) {
+		return invalid(TypeLog, "unknown stream %q", m.Stream)
+	}
+	if m.IsError && m.Stream == StreamStdout {
+		return invalid(TypeLog, "a stdout line cannot be isError")
+	}
+	if m.LineIndex < 0 {
+		return invalid(TypeLog, "lineIndex must not be negative, got %d", m.LineIndex)
+	}
+	return nil
+}
+
+// Validate checks a STATUS_UPDATE and its optional detection metadata
+func (m StatusUpdateMessage) Validate() error {
+	if err := checkHeader(m.Type, TypeStatusUpdate, m.TaskID); err != nil {
+		return err
+	}
+	if !oneOf(m.Status, TaskStatuses...) {
+		return invalid(TypeStatusUpdate, "unknown status %q", m.Status)
+	}
+	if m.ResetAt != "" {
+		if _, err := time.Parse(time.RFC3339, m.ResetAt); err != nil {
+			return invalid(TypeStatusUpdate, "resetAt is not RFC3339: %q", m.ResetAt)
+		}
+	}
+	if d := m.Detection; d != nil {
+		if d.Pattern == "" || d.Category == "" {
+			return invalid(TypeStatusUpdate, "detection requires pattern and category")
+		}
+		if d.LineNumber <= 0 || d.Index < 0 {
+			return invalid(TypeStatusUpdate, "detection position out of range (line %d, index %d)", d.LineNumber, d.Index)
+		}
+	}
+	return nil
+}
+
+// Validate checks an EXECUTE command
+func (m ExecuteMessage) Validate() error {
+	if err := checkHeader(m.Type, TypeExecute, m.TaskID); err != nil {
+		return err
+	}
+	if m.Script == "" && m.ScriptContent == "" {
+		return invalid(TypeExecute, "script or scriptContent is required")
+	}
+	if m.SessionMode != "" && !oneOf(m.SessionMode, SessionModes...) {
+		return invalid(TypeExecute, "unknown sessionMode %q", m.SessionMode)
+	}
+	if m.Host != "" && m.ScriptContent == "" {
+		return invalid(TypeExecute, "host requires scriptContent; script paths are local to the runner")
+	}
+	if m.SessionKey != "" && m.SessionMode != SessionModePersist {
+		return invalid(TypeExecute, "sessionKey requires sessionMode %s", SessionModePersist)
+	}
+	if m.SessionKey != "" && (m.Host != "" || m.ScriptContent == "" || m.Interpreter != "" && m.Interpreter != InterpreterClaude) {
+		return invalid(TypeExecute, "sessionKey is for local claude tasks")
+	}
+	if m.Repo == "" && (m.Ref != "" || m.Depth != 0) {
+		return invalid(TypeExecute, "ref and depth require repo")
+	}
+	if m.Repo != "" && (m.ScriptContent == "" || m.Host != "") {
+		return invalid(TypeExecute, "repo requires scriptContent and is checked out on the runner, so not with host")
+	}
+	if m.Depth < 0 {
+		return invalid(TypeExecute, "depth is %d", m.Depth)
+	}
+	if m.Interpreter == InterpreterClaude && m.ScriptContent == "" {
+		return invalid(TypeExecute, "interpreter claude requires scriptContent")
+	}
+	if m.MaxOutputBytes < 0 {
+		return invalid(TypeExecute, "maxOutputBytes cannot be negative")
+	}
+	if m.StallThresholdSeconds < 0 {
+		return invalid(TypeExecute, "stallThresholdSeconds cannot be negative")
+	}
+	if m.StallAction != "" && !oneOf(m.StallAction, StallActions...) {
+		return invalid(TypeExecute, "unknown stallAction %q", m.StallAction)
+	}
+	if m.OutputFormat != "" && !oneOf(m.OutputFormat, OutputFormats...) {
+		return invalid(TypeExecute, "unknown outputFormat %q", m.OutputFormat)
+	}
+	if m.OutputFormat == OutputFormatJSON && (m.ScriptContent == "" || m.Interpreter != "" && m.Interpreter != InterpreterClaude) {
+		return invalid(TypeExecute, "outputFormat json is for claude tasks, which need scriptContent")
+	}
+	if m.Model != "" && (m.ScriptContent == "" || m.Interpreter != "" && m.Interpreter != InterpreterClaude) {
+		return invalid(TypeExecute, "model is for claude tasks, which need scriptContent")
+	}
+	if strings.HasPrefix(m.Model, "-") || strings.ContainsFunc(m.Model, unicode.IsSpace) {
+		return invalid(TypeExecute, "model %q is not a model name", m.Model)
+	}
+	if m.Interpreter != "" && m.Interpreter != InterpreterClaude && m.Host != "" {
+		return invalid(TypeExecute, "host tasks run claude, so not interpreter %q", m.Interpreter)
+	}
+	if m.Interactive && (m.Host != "" || m.StdinContent != "" || m.PTY) {
+		return invalid(TypeExecute, "interactive is for local tasks without stdinContent or pty")
+	}
+	if m.PTY && (m.Host != "" || m.StdinContent != "") {
+		return invalid(TypeExecute, "pty is for local tasks without stdinContent, which the terminal would echo")
+	}
+	if m.WorkingDir != "" && (m.Repo != "" || m.Host != "") {
+		return invalid(TypeExecute, "workingDir is a directory on the runner, so not with repo or host")
+	}
+	return nil
+}
+
+// Validate checks a RUNNER_STATUS update
+func (m RunnerStatusMessage) Validate() error {
+	if m.Type != TypeRunnerStatus {
+		return invalid(TypeRunnerStatus, "type is %q", m.Type)
+	}
+	if !oneOf(m.Status, RunnerStatuses...) {
+		return invalid(TypeRunnerStatus, "unknown status %q", m.Status)
+	}
+	return nil
+}
+
+// Validate checks a TASK_COMPLETED notification
+func (m TaskCompletedMessage) Validate() error {
+	if err := checkHeader(m.Type, TypeTaskCompleted, m.TaskID); err != nil {
+		return err
+	}
+	if m.Classification != "" && !oneOf(m.Classification, Classifications...) {
+		return invalid(TypeTaskCompleted, "unknown classification %q", m.Classification)
+	}
+	if m.ErrorCode != "" && !oneOf(m.ErrorCode, ErrorCodes...) {
+		return invalid(TypeTaskCompleted, "unknown errorCode %q", m.ErrorCode)
+	}
+	if m.Success && (m.Classification != "" || m.ErrorCode != "" || m.ErrorDetail != "") {
+		return invalid(TypeTaskCompleted, "successful task cannot carry a failure classification, code or detail")
+	}
+	if m.ExitCode < -1 || m.Success && m.ExitCode != 0 {
+		return invalid(TypeTaskCompleted, "exitCode %d out of range (success %v)", m.ExitCode, m.Success)
+	}
+	for _, v := range []*int64{m.UserCPUMs, m.SystemCPUMs, m.MaxRSSKb} {
+		if v != nil && *v < 0 {
+			return invalid(TypeTaskCompleted, "resource usage cannot be negative")
+		}
+	}
+	if m.OutputBytes < 0 || m.DiscardedOutputBytes < 0 {
+		return invalid(TypeTaskCompleted, "output byte counts cannot be negative")
+	}
+	if r := m.ClaudeResult; r != nil {
+		if r.Subtype == "" {
+			return invalid(TypeTaskCompleted, "claudeResult requires subtype")
+		}
+		if r.NumTurns < 0 || r.DurationMs < 0 || r.DurationAPIMs < 0 || r.TotalCostUSD < 0 {
+			return invalid(TypeTaskCompleted, "claudeResult figures cannot be negative")
+		}
+	}
+	return nil
+}
+
+// Validate checks a TASK_STARTED notification
+func (m TaskStartedMessage) Validate() error {
+	return checkHeader(m.Type, TypeTaskStarted, m.TaskID)
+}
+
+// Validate checks a CANCEL_TASK request
+func (m CancelTaskMessage) Validate() error {
+	return checkHeader(m.Type, TypeCancelTask, m.TaskID)
+}
+
+// Validate checks a KILL_TASK request
+func (m KillTaskMessage) Validate() error {
+	return checkHeader(m.Type, TypeKillTask, m.TaskID)
+}
+
+// Validate checks a RESUME_LOGS request
+func (m ResumeLogsMessage) Validate() error {
+	if err := checkHeader(m.Type, TypeResumeLogs, m.TaskID); err != nil {
+		return err
+	}
+	if m.LastLineIndex < -1 {
+		return invalid(TypeResumeLogs, "lastLineIndex must be -1 or more, got %d", m.LastLineIndex)
+	}
+	return nil
+}
+
+// Validate checks a RESUME_LOGS_RESULT
+func (m ResumeLogsResultMessage) Validate() error {
+	if err := checkHeader(m.Type, TypeResumeLogsResult, m.TaskID); err != nil {
+		return err
+	}
+	if m.Success != (m.Code == "") {
+		return invalid(TypeResumeLogsResult, "code must be set exactly when success is false")
+	}
+	if m.Code != "" && !oneOf(m.Code, ResumeCodes...) {
+		return invalid(TypeResumeLogsResult, "unknown code %q", m.Code)
+	}
+	return nil
+}
+
+// Validate checks a RECURRING_EXECUTE
+func (m RecurringExecuteMessage) Validate() error {
+	if m.Type != TypeRecurringExecute {
+		return invalid(TypeRecurringExecute, "type is %q", m.Type)
+	}
+	if m.RecurrenceID == "" {
+		return invalid(TypeRecurringExecute, "recurrenceId is required")
+	}
+	if (m.Cron == "") == (m.IntervalSeconds == 0) {
+		return invalid(TypeRecurringExecute, "exactly one of cron and intervalSeconds is required")
+	}
+	if m.IntervalSeconds < 0 {
+		return invalid(TypeRecurringExecute, "intervalSeconds is %d", m.IntervalSeconds)
+	}
+	if m.Cron != "" {
+		if _, err := cron.Parse(m.Cron); err != nil {
+			return invalid(TypeRecurringExecute, "%v", err)
+		}
+	}
+	task := m.Task
+	task.Type, task.TaskID = TypeExecute, 1 // Instances get their own
+	if err := task.Validate(); err != nil {
+		return invalid(TypeRecurringExecute, "task: %v", err)
+	}
+	return nil
+}
+
+// Validate checks a CANCEL_RECURRING
+func (m CancelRecurringMessage) Validate() error {
+	if m.Type != TypeCancelRecurring {
+		return invalid(TypeCancelRecurring, "type is %q", m.Type)
+	}
+	if m.RecurrenceID == "" {
+		return invalid(TypeCancelRecurring, "recurrenceId is required")
+	}
+	return nil
+}
+
+// Validate checks a RECURRENCE_FIRED
+func (m RecurrenceFiredMessage) Validate() error {
+	if err := checkHeader(m.Type, TypeRecurrenceFired, m.TaskID); err != nil {
+		return err
+	}
+	if m.RecurrenceID == "" || m.InstanceID == "" {
+		return invalid(TypeRecurrenceFired, "recurrenceId and instanceId are required")
+	}
+	if _, err := time.Parse(time.RFC3339, m.ScheduledAt); err != nil {
+		return invalid(TypeRecurrenceFired, "scheduledAt: %v", err)
+	}
+	return nil
+}
+
+// Validate checks a CANCEL_ACK
+func (m CancelAckMessage) Validate() error {
+	if err := checkHeader(m.Type, TypeCancelAck, m.TaskID); err != nil {
+		return err
+	}
+	if !oneOf(m.Status, CancelAckStatuses...) {
+		return invalid(TypeCancelAck, "unknown status %q", m.Status)
+	}
+	return nil
+}
+
+// Validate checks a TASK_TERMINATED acknowledgment
+func (m TaskTerminatedMessage) Validate() error {
+	if err := checkHeader(m.Type, TypeTaskTerminated, m.TaskID); err != nil {
+		return err
+	}
+	if m.Status != "KILLED" {
+		return invalid(TypeTaskTerminated, "unknown status %q", m.Status)
+	}
+	return nil
+}
+
+// Validate checks a RUNNER_CAPACITY report
+func (m RunnerCapacityMessage) Validate() error {
+	if m.Type != TypeRunnerCapacity {
+		return invalid(TypeRunnerCapacity, "type is %q", m.Type)
+	}
+	if m.MaxParallel <= 0 {
+		return invalid(TypeRunnerCapacity, "maxParallel must be positive, got %d", m.MaxParallel)
+	}
+	if m.RunningTasks < 0 || m.AvailableSlots < 0 || m.Reserved < 0 || m.AvailableSlots+m.Reserved > m.MaxParallel {
+		return invalid(TypeRunnerCapacity, "slot counts out of range (running %d, available %d, reserved %d, max %d)",
+			m.RunningTasks, m.AvailableSlots, m.Reserved, m.MaxParallel)
+	}
+	for _, r := range m.Reservations {
+		if r.ReservationID == "" || r.Weight <= 0 {
+			return invalid(TypeRunnerCapacity, "reservation %q has weight %d", r.ReservationID, r.Weight)
+		}
+		if _, err := time.Parse(time.RFC3339, r.ExpiresAt); err != nil {
+			return invalid(TypeRunnerCapacity, "reservation %q: expiresAt: %v", r.ReservationID, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks a RESERVE_SLOT
+func (m ReserveSlotMessage) Validate() error {
+	if m.Type != TypeReserveSlot {
+		return invalid(TypeReserveSlot, "type is %q", m.Type)
+	}
+	if m.ReservationID == "" {
+		return invalid(TypeReserveSlot, "reservationId is required")
+	}
+	if m.TTLSeconds <= 0 {
+		return invalid(TypeReserveSlot, "ttlSeconds must be positive, got %d", m.TTLSeconds)
+	}
+	if m.Weight < 0 {
+		return invalid(TypeReserveSlot, "weight is %d", m.Weight)
+	}
+	return nil
+}
+
+// Validate checks a RELEASE_SLOT
+func (m ReleaseSlotMessage) Validate() error {
+	if m.Type != TypeReleaseSlot {
+		return invalid(TypeReleaseSlot, "type is %q", m.Type)
+	}
+	if m.ReservationID == "" {
+		return invalid(TypeReleaseSlot, "reservationId is required")
+	}
+	return nil
+}
+
+// Validate checks a RESERVE_SLOT_RESULT
+func (m ReserveSlotResultMessage) Validate() error {
+	if m.Type != TypeReserveSlotResult {
+		return invalid(TypeReserveSlotResult, "type is %q", m.Type)
+	}
+	if m.ReservationID == "" {
+		return invalid(TypeReserveSlotResult, "reservationId is required")
+	}
+	if m.Success == (m.Code != "") {
+		return invalid(TypeReserveSlotResult, "code must be set exactly when success is false")
+	}
+	if m.Code != "" && !oneOf(m.Code, ReserveCodes...) {
+		return invalid(TypeReserveSlotResult, "unknown code %q", m.Code)
+	}
+	if m.Success {
+		if _, err := time.Parse(time.RFC3339, m.ExpiresAt); err != nil {
+			return invalid(TypeReserveSlotResult, "expiresAt: %v", err)
+		}
+	}
+	return nil
+}
+
+// Validate checks a RESERVATION_EXPIRED
+func (m ReservationExpiredMessage) Validate() error {
+	if m.Type != TypeReservationExpired {
+		return invalid(TypeReservationExpired, "type is %q", m.Type)
+	}
+	if m.ReservationID == "" {
+		return invalid(TypeReservationExpired, "reservationId is required")
+	}
+	if !oneOf(m.Reason, ExpiryReasons...) {
+		return invalid(TypeReservationExpired, "unknown reason %q", m.Reason)
+	}
+	return nil
+}
+
+// Validate checks a RUNNER_DRAINING announcement
+func (m RunnerDrainingMessage) Validate() error {
+	if m.Type != TypeRunnerDraining {
+		return invalid(TypeRunnerDraining, "type is %q", m.Type)
+	}
+	if m.RunningTasks < 0 || m.GraceSeconds < 0 {
+		return invalid(TypeRunnerDraining, "counts out of range (running %d, grace %ds)", m.RunningTasks, m.GraceSeconds)
+	}
+	return nil
+}
+
+// Validate checks a BYE
+func (m ByeMessage) Validate() error {
+	if m.Type != TypeBye {
+		return invalid(TypeBye, "type is %q", m.Type)
+	}
+	if m.CancelledTasks < 0 || (m.Drained && m.CancelledTasks > 0) {
+		return invalid(TypeBye, "cancelledTasks out of range (%d, drained %v)", m.CancelledTasks, m.Drained)
+	}
+	return nil
+}
+
+// Validate checks a GOODBYE
+func (m GoodbyeMessage) Validate() error {
+	if m.Type != TypeGoodbye {
+		return invalid(TypeGoodbye, "type is %q", m.Type)
+	}
+	if !oneOf(m.Reason, GoodbyeReasons...) {
+		return invalid(TypeGoodbye, "unknown reason %q", m.Reason)
+	}
+	for _, id := range m.UnfinishedTasks {
+		if id <= 0 {
+			return invalid(TypeGoodbye, "unfinishedTasks has task ID %d", id)
+		}
+	}
+	return nil
+}
+
+// Validate checks a RUNNER_RESYNC
+func (m RunnerResyncMessage) Validate() error {
+	if m.Type != TypeRunnerResync {
+		return invalid(TypeRunnerResync, "type is %q", m.Type)
+	}
+	if m.MaxParallel <= 0 {
+		return invalid(TypeRunnerResync, "maxParallel must be positive, got %d", m.MaxParallel)
+	}
+	if m.RunningTasks < 0 || m.AvailableSlots < 0 || m.AvailableSlots > m.MaxParallel {
+		return invalid(TypeRunnerResync, "slot counts out of range (running %d, available %d, max %d)",
+			m.RunningTasks, m.AvailableSlots, m.MaxParallel)
+	}
+	for _, task := range m.Tasks {
+		if task.TaskID <= 0 {
+			return invalid(TypeRunnerResync, "tasks has task ID %d", task.TaskID)
+		}
+		if !oneOf(task.State, ResyncStates...) {
+			return invalid(TypeRunnerResync, "task %d: unknown state %q", task.TaskID, task.State)
+		}
+		if task.StartedAt != "" {
+			if _, err := time.Parse(time.RFC3339, task.StartedAt); err != nil {
+				return invalid(TypeRunnerResync, "task %d: startedAt: %v", task.TaskID, err)
+			}
+		}
+		if task.ElapsedMs < 0 {
+			return invalid(TypeRunnerResync, "task %d: elapsedMs is negative", task.TaskID)
+		}
+	}
+	return nil
+}
+
+// Validate checks a RUNNER_HEARTBEAT
+func (m RunnerHeartbeatMessage) Validate() error {
+	if m.Type != TypeRunnerHeartbeat {
+		return invalid(TypeRunnerHeartbeat, "type is %q", m.Type)
+	}
+	if m.UptimeSeconds < 0 {
+		return invalid(TypeRunnerHeartbeat, "uptimeSeconds is negative")
+	}
+	if m.MaxParallel <= 0 {
+		return invalid(TypeRunnerHeartbeat, "maxParallel must be positive, got %d", m.MaxParallel)
+	}
+	if m.RunningTasks < 0 || m.AvailableSlots < 0 || m.AvailableSlots > m.MaxParallel || m.QueuedTasks < 0 {
+		return invalid(TypeRunnerHeartbeat, "task counts out of range (running %d, available %d, queued %d, max %d)",
+			m.RunningTasks, m.AvailableSlots, m.QueuedTasks, m.MaxParallel)
+	}
+	if m.LoadAverage1 < 0 {
+		return invalid(TypeRunnerHeartbeat, "loadAverage1 is negative")
+	}
+	if m.WriteP95Millis < 0 {
+		return invalid(TypeRunnerHeartbeat, "writeP95Ms is negative")
+	}
+	return nil
+}
+
+// Validate checks an ACK
+func (m AckMessage) Validate() error {
+	if m.Type != TypeAck {
+		return invalid(TypeAck, "type is %q", m.Type)
+	}
+	if m.MessageID == "" {
+		return invalid(TypeAck, "messageId is required")
+	}
+	return nil
+}
+
+// Validate checks an AUTH_CHALLENGE
+func (m AuthChallengeMessage) Validate() error {
+	if m.Type != TypeAuthChallenge {
+		return invalid(TypeAuthChallenge, "type is %q", m.Type)
+	}
+	if m.Nonce == "" {
+		return invalid(TypeAuthChallenge, "nonce is required")
+	}
+	return nil
+}
+
+// Validate checks an AUTH_RESPONSE
+func (m AuthResponseMessage) Validate() error {
+	if m.Type != TypeAuthResponse {
+		return invalid(TypeAuthResponse, "type is %q", m.Type)
+	}
+	if m.Nonce == "" {
+		return invalid(TypeAuthResponse, "nonce is required")
+	}
+	if sum, err := hex.DecodeString(m.HMAC); err != nil || len(sum) != sha256.Size {
+		return invalid(TypeAuthResponse, "hmac must be %d hex characters", 2*sha256.Size)
+	}
+	return nil
+}
+
+// Validate checks an AUTH_OK
+func (m AuthOKMessage) Validate() error {
+	if m.Type != TypeAuthOK {
+		return invalid(TypeAuthOK, "type is %q", m.Type)
+	}
+	return nil
+}
+
+// Validate checks a SHUTDOWN
+func (m ShutdownMessage) Validate() error {
+	if m.Type != TypeShutdown {
+		return invalid(TypeShutdown, "type is %q", m.Type)
+	}
+	if m.GraceSeconds < 0 {
+		return invalid(TypeShutdown, "graceSeconds must not be negative, got %d", m.GraceSeconds)
+	}
+	return nil
+}
+
+// Validate checks a STDIN_INPUT
+func (m StdinInputMessage) Validate() error {
+	if err := checkHeader(m.Type, TypeStdinInput, m.TaskID); err != nil {
+		return err
+	}
+	if m.Data == "" && !m.CloseAfter {
+		return invalid(TypeStdinInput, "data or closeAfter is required")
+	}
+	return nil
+}
+
+// Validate checks a STDIN_INPUT_RESULT
+func (m StdinInputResultMessage) Validate() error {
+	if err := checkHeader(m.Type, TypeStdinInputResult, m.TaskID); err != nil {
+		return err
+	}
+	if m.Success == (m.Error != "") {
+		return invalid(TypeStdinInputResult, "error must be set exactly when success is false")
+	}
+	if m.Bytes < 0 {
+		return invalid(TypeStdinInputResult, "bytes is %d", m.Bytes)
+	}
+	return nil
+}
+
+// Validate checks a TOOL_EVENT report
+func (m ToolEventMessage) Validate() error {
+	if err := checkHeader(m.Type, TypeToolEvent, m.TaskID); err != nil {
+		return err
+	}
+	if !oneOf(m.Phase, ToolPhases...) {
+		return invalid(TypeToolEvent, "unknown phase %q", m.Phase)
+	}
+	if m.Phase == ToolPhaseUse && m.Tool == "" {
+		return invalid(TypeToolEvent, "%s requires tool", ToolPhaseUse)
+	}
+	if m.Phase == ToolPhaseResult && m.ToolUseID == "" {
+		return invalid(TypeToolEvent, "%s requires toolUseId", ToolPhaseResult)
+	}
+	return nil
+}
+
+// Validate checks a MESSAGE_ERROR report
+func (m MessageErrorMessage) Validate() error {
+	if m.Type != TypeMessageError {
+		return invalid(TypeMessageError, "type is %q", m.Type)
+	}
+	if !oneOf(m.Code, MessageErrorCodes...) {
+		return invalid(TypeMessageError, "unknown code %q", m.Code)
+	}
+	if m.Error == "" {
+		return invalid(TypeMessageError, "error is required")
+	}
+	return nil
+}
+
+// NewMessageError builds the MESSAGE_ERROR reply for a frame DecodeIncoming rejected
+// The type and task ID are recovered from the frame on a best-effort basis; data is nil for a frame
+// that could not be read (ErrMessageTooLarge).
+func NewMessageError(data []byte, err error) MessageErrorMessage {
+	var header struct {
+		Type   string `json:"type"`
+		TaskID int64  `json:"taskId"`
+	}
+	_ = json.Unmarshal(data, &header)
+
+	code := MessageErrorMalformed
+	switch {
+	case errors.Is(err, ErrUnknownMessageType):
+		code = MessageErrorUnknownType
+	case errors.Is(err, ErrInvalidMessage):
+		code = MessageErrorInvalid
+	case errors.Is(err, ErrUnsupportedVersion):
+		code = MessageErrorUnsupportedVersion
+	case errors.Is(err, ErrMessageTooLarge):
+		code = MessageErrorTooLarge
+	}
+
+	return MessageErrorMessage{
+		Type:        TypeMessageError,
+		MessageType: header.Type,
+		TaskID:      header.TaskID,
+		Code:        code,
+		Error:       err.Error(),
+	}
+}
@@ -0,0 +1,411 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// validator is implemented by every protocol struct
+type validator interface {
+	Validate() error
+}
+
+// validationCase is a single Validate expectation
+type validationCase struct {
+	name    string
+	msg     validator
+	wantErr bool
+}
+
+// runValidationCases checks each case and that failures wrap ErrInvalidMessage
+func runValidationCases(t *testing.T, tests []validationCase) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.msg.Validate()
+			if tt.wantErr {
+				assert.True(t, errors.Is(err, ErrInvalidMessage), "got %v", err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+// TestHeloMessage_Validate verifies handshake validation
+func TestHeloMessage_Validate(t *testing.T) {
+	runValidationCases(t, []validationCase{
+		{name: "valid", msg: HeloMessage{Type: TypeHelo, Hostname: "host", Workdir: "/tmp"}},
+		{name: "missing hostname", msg: HeloMessage{Type: TypeHelo}, wantErr: true},
+		{name: "wrong type", msg: HeloMessage{Type: TypeLog, Hostname: "host"}, wantErr: true},
+		{name: "negative protocol version", msg: HeloMessage{Type: TypeHelo, Hostname: "host", ProtocolVersion: -1}, wantErr: true},
+	})
+}
+
+// TestLogMessage_Validate verifies LOG validation
+func TestLogMessage_Validate(t *testing.T) {
+	runValidationCases(t, []validationCase{
+		{name: "valid", msg: LogMessage{Type: TypeLog, TaskID: 1, Line: "hello", Severity: "warn"}},
+		{name: "empty line allowed", msg: LogMessage{Type: TypeLog, TaskID: 1}},
+		{name: "zero task", msg: LogMessage{Type: TypeLog, Line: "hello"}, wantErr: true},
+		{name: "unknown severity", msg: LogMessage{Type: TypeLog, TaskID: 1, Severity: "critical"}, wantErr: true},
+		{name: "negative line index", msg: LogMessage{Type: TypeLog, TaskID: 1, LineIndex: -1}, wantErr: true},
+		{name: "stderr", msg: NewOutputLog(1, "oops", true)},
+		{name: "system error", msg: NewLogMessage(1, "Command failed", true)},
+		{name: "unknown stream", msg: LogMessage{Type: TypeLog, TaskID: 1, Stream: "stdin"}, wantErr: true},
+		{name: "stdout error", msg: LogMessage{Type: TypeLog, TaskID: 1, Stream: StreamStdout, IsError: true}, wantErr: true},
+		{name: "system", msg: NewSystemLog("[runner] reconnected", "warn")},
+		{name: "system with task", msg: LogMessage{Type: TypeLog, TaskID: 1, Line: "hello", System: true}, wantErr: true},
+	})
+}
+
+// TestStatusUpdateMessage_Validate verifies STATUS_UPDATE validation
+func TestStatusUpdateMessage_Validate(t *testing.T) {
+	detection := &DetectionInfo{Pattern: "http_429", Category: "RATE_LIMIT", Matched: "Error: 429", LineNumber: 3}
+
+	runValidationCases(t, []validationCase{
+		{name: "valid", msg: StatusUpdateMessage{Type: TypeStatusUpdate, TaskID: 1, Status: StatusRunning}},
+		{name: "valid detection", msg: StatusUpdateMessage{Type: TypeStatusUpdate, TaskID: 1, Status: StatusRateLimited, Detection: detection}},
+		{name: "valid reset", msg: StatusUpdateMessage{Type: TypeStatusUpdate, TaskID: 1, Status: StatusUsageLimited, ResetAt: "2025-06-14T19:00:00Z"}},
+		{name: "empty status", msg: StatusUpdateMessage{Type: TypeStatusUpdate, TaskID: 1}, wantErr: true},
+		{name: "unknown status", msg: StatusUpdateMessage{Type: TypeStatusUpdate, TaskID: 1, Status: "DONE"}, wantErr: true},
+		{name: "zero task", msg: StatusUpdateMessage{Type: TypeStatusUpdate, Status: StatusRunning}, wantErr: true},
+		{name: "bad reset", msg: StatusUpdateMessage{Type: TypeStatusUpdate, TaskID: 1, Status: StatusUsageLimited, ResetAt: "7pm"}, wantErr: true},
+		{name: "detection without pattern", msg: StatusUpdateMessage{Type: TypeStatusUpdate, TaskID: 1, Status: StatusRateLimited, Detection: &DetectionInfo{Category: "RATE_LIMIT", LineNumber: 1}}, wantErr: true},
+		{name: "detection without line", msg: StatusUpdateMessage{Type: TypeStatusUpdate, TaskID: 1, Status: StatusRateLimited, Detection: &DetectionInfo{Pattern: "p", Category: "RATE_LIMIT"}}, wantErr: true},
+	})
+}
+
+// TestExecuteMessage_Validate verifies EXECUTE validation
+func TestExecuteMessage_Validate(t *testing.T) {
+	runValidationCases(t, []validationCase{
+		{name: "valid inline", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1, ScriptContent: "do it", SessionMode: SessionModePersist}},
+		{name: "valid legacy script", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1, Script: "/tmp/run.sh"}},
+		{name: "zero task", msg: ExecuteMessage{Type: TypeExecute, ScriptContent: "do it"}, wantErr: true},
+		{name: "negative task", msg: ExecuteMessage{Type: TypeExecute, TaskID: -4, ScriptContent: "do it"}, wantErr: true},
+		{name: "no script", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1}, wantErr: true},
+		{name: "unknown session mode", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1, ScriptContent: "do it", SessionMode: "RESUME"}, wantErr: true},
+		{name: "remote", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1, ScriptContent: "do it", Host: "gpu-box"}},
+		{name: "remote script path", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1, Script: "/tmp/run.sh", Host: "gpu-box"}, wantErr: true},
+		{name: "repo", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1, ScriptContent: "do it", Repo: "git@example.com:aaw.git", Ref: "main", Depth: 1}},
+		{name: "ref without repo", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1, ScriptContent: "do it", Ref: "main"}, wantErr: true},
+		{name: "remote repo", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1, ScriptContent: "do it", Repo: "git@example.com:aaw.git", Host: "gpu-box"}, wantErr: true},
+		{name: "negative depth", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1, ScriptContent: "do it", Repo: "git@example.com:aaw.git", Depth: -1}, wantErr: true},
+		{name: "interpreter", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1, Script: "/srv/job.py", Interpreter: "python3"}},
+		{name: "claude script path", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1, Script: "/srv/job.sh", Interpreter: InterpreterClaude}, wantErr: true},
+		{name: "claude on host", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1, ScriptContent: "do it", Host: "gpu-1", Interpreter: InterpreterClaude}},
+		{name: "interpreter on host", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1, ScriptContent: "echo hi", Host: "gpu-1", Interpreter: "sh"}, wantErr: true},
+		{name: "interactive", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1, ScriptContent: "do it", Interactive: true}},
+		{name: "interactive with stdin", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1, ScriptContent: "do it", StdinContent: "y", Interactive: true}, wantErr: true},
+		{name: "interactive pty", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1, ScriptContent: "do it", PTY: true, Interactive: true}, wantErr: true},
+		{name: "session key", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1, ScriptContent: "do it", SessionMode: SessionModePersist, SessionKey: "review"}},
+		{name: "session key without persist", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1, ScriptContent: "do it", SessionKey: "review"}, wantErr: true},
+		{name: "session key on host", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1, ScriptContent: "do it", SessionMode: SessionModePersist, SessionKey: "review", Host: "gpu"}, wantErr: true},
+		{name: "model", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1, ScriptContent: "do it", Model: "claude-sonnet-4-5"}},
+		{name: "model for script path", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1, Script: "/srv/job.sh", Model: "sonnet"}, wantErr: true},
+		{name: "model under bash", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1, ScriptContent: "echo", Interpreter: "bash", Model: "sonnet"}, wantErr: true},
+		{name: "model like a flag", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1, ScriptContent: "do it", Model: "--help"}, wantErr: true},
+		{name: "model with spaces", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1, ScriptContent: "do it", Model: "sonnet --verbose"}, wantErr: true},
+		{name: "output cap", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1, ScriptContent: "do it", MaxOutputBytes: 1 << 20}},
+		{name: "negative output cap", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1, ScriptContent: "do it", MaxOutputBytes: -1}, wantErr: true},
+		{name: "stall kill", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1, ScriptContent: "do it", StallThresholdSeconds: 300, StallAction: StallActionKill}},
+		{name: "negative stall threshold", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1, ScriptContent: "do it", StallThresholdSeconds: -1}, wantErr: true},
+		{name: "unknown stall action", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1, ScriptContent: "do it", StallAction: "restart"}, wantErr: true},
+		{name: "json output", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1, ScriptContent: "do it", OutputFormat: OutputFormatJSON}},
+		{name: "text output under bash", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1, ScriptContent: "echo", Interpreter: "bash", OutputFormat: OutputFormatText}},
+		{name: "json output under bash", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1, ScriptContent: "echo", Interpreter: "bash", OutputFormat: OutputFormatJSON}, wantErr: true},
+		{name: "unknown output format", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1, ScriptContent: "do it", OutputFormat: "xml"}, wantErr: true},
+		{name: "session key under bash", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1, ScriptContent: "echo", SessionMode: SessionModePersist, SessionKey: "review", Interpreter: "bash"}, wantErr: true},
+		{name: "pty", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1, ScriptContent: "do it", PTY: true}},
+		{name: "pty on host", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1, ScriptContent: "do it", Host: "gpu-1", PTY: true}, wantErr: true},
+		{name: "pty with stdin", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1, ScriptContent: "do it", StdinContent: "diff", PTY: true}, wantErr: true},
+		{name: "working dir", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1, ScriptContent: "do it", WorkingDir: "/srv/app"}},
+		{name: "working dir with repo", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1, ScriptContent: "do it", Repo: "git@example.com:aaw.git", WorkingDir: "/srv/app"}, wantErr: true},
+		{name: "working dir with host", msg: ExecuteMessage{Type: TypeExecute, TaskID: 1, ScriptContent: "do it", Host: "gpu-1", WorkingDir: "/srv/app"}, wantErr: true},
+	})
+}
+
+// TestRunnerStatusMessage_Validate verifies RUNNER_STATUS validation
+func TestRunnerStatusMessage_Validate(t *testing.T) {
+	runValidationCases(t, []validationCase{
+		{name: "idle", msg: RunnerStatusMessage{Type: TypeRunnerStatus, Status: "IDLE"}},
+		{name: "busy", msg: RunnerStatusMessage{Type: TypeRunnerStatus, Status: "BUSY"}},
+		{name: "unknown", msg: RunnerStatusMessage{Type: TypeRunnerStatus, Status: "UNKNOWN"}, wantErr: true},
+	})
+}
+
+// TestTaskCompletedMessage_Validate verifies TASK_COMPLETED validation
+func TestTaskCompletedMessage_Validate(t *testing.T) {
+	withUsage := func(user, system time.Duration, maxRSSKb int64) TaskCompletedMessage {
+		msg := TaskCompletedMessage{Type: TypeTaskCompleted, TaskID: 1, Success: true}
+		msg.SetUsage(user, system, maxRSSKb)
+		return msg
+	}
+	runValidationCases(t, []validationCase{
+		{name: "success", msg: TaskCompletedMessage{Type: TypeTaskCompleted, TaskID: 1, Success: true}},
+		{name: "oom failure", msg: TaskCompletedMessage{Type: TypeTaskCompleted, TaskID: 1, Error: "killed", Classification: ClassificationOOM}},
+		{name: "zero task", msg: TaskCompletedMessage{Type: TypeTaskCompleted, Success: true}, wantErr: true},
+		{name: "unknown classification", msg: TaskCompletedMessage{Type: TypeTaskCompleted, TaskID: 1, Classification: "DISK"}, wantErr: true},
+		{name: "success with classification", msg: TaskCompletedMessage{Type: TypeTaskCompleted, TaskID: 1, Success: true, Classification: ClassificationOOM}, wantErr: true},
+		{name: "failure with detail", msg: TaskCompletedMessage{Type: TypeTaskCompleted, TaskID: 1, Error: "exit status 1", ErrorDetail: "fatal: no such file"}},
+		{name: "success with detail", msg: TaskCompletedMessage{Type: TypeTaskCompleted, TaskID: 1, Success: true, ErrorDetail: "warning"}, wantErr: true},
+		{name: "with usage", msg: withUsage(1200*time.Millisecond, 0, 20480)},
+		{name: "negative usage", msg: withUsage(0, 0, -1), wantErr: true},
+		{name: "exit code", msg: TaskCompletedMessage{Type: TypeTaskCompleted, TaskID: 1, ExitCode: 137, ErrorCode: ErrorCodeCancelled}},
+		{name: "never ran", msg: TaskCompletedMessage{Type: TypeTaskCompleted, TaskID: 1, ExitCode: -1, ErrorCode: ErrorCodeStartFailed}},
+		{name: "success with exit code", msg: TaskCompletedMessage{Type: TypeTaskCompleted, TaskID: 1, Success: true, ExitCode: 1}, wantErr: true},
+		{name: "exit code out of range", msg: TaskCompletedMessage{Type: TypeTaskCompleted, TaskID: 1, ExitCode: -2}, wantErr: true},
+		{name: "output bytes", msg: TaskCompletedMessage{Type: TypeTaskCompleted, TaskID: 1, Success: true, OutputBytes: 1024, DiscardedOutputBytes: 4096}},
+		{name: "negative output bytes", msg: TaskCompletedMessage{Type: TypeTaskCompleted, TaskID: 1, Success: true, OutputBytes: -1}, wantErr: true},
+		{name: "claude result", msg: TaskCompletedMessage{Type: TypeTaskCompleted, TaskID: 1, Success: true, ClaudeResult: &ClaudeResult{Subtype: "success", NumTurns: 3, TotalCostUSD: 0.02}}},
+		{name: "claude result without subtype", msg: TaskCompletedMessage{Type: TypeTaskCompleted, TaskID: 1, Success: true, ClaudeResult: &ClaudeResult{NumTurns: 3}}, wantErr: true},
+		{name: "negative claude cost", msg: TaskCompletedMessage{Type: TypeTaskCompleted, TaskID: 1, Success: true, ClaudeResult: &ClaudeResult{Subtype: "success", TotalCostUSD: -1}}, wantErr: true},
+	})
+}
+
+// TestCancelAndKillMessages_Validate verifies CANCEL_TASK and KILL_TASK validation
+func TestCancelAndKillMessages_Validate(t *testing.T) {
+	runValidationCases(t, []validationCase{
+		{name: "cancel", msg: CancelTaskMessage{Type: TypeCancelTask, TaskID: 1}},
+		{name: "cancel zero task", msg: CancelTaskMessage{Type: TypeCancelTask}, wantErr: true},
+		{name: "kill", msg: KillTaskMessage{Type: TypeKillTask, TaskID: 1}},
+		{name: "kill wrong type", msg: KillTaskMessage{Type: TypeCancelTask, TaskID: 1}, wantErr: true},
+	})
+}
+
+// TestAckMessages_Validate verifies CANCEL_ACK and TASK_TERMINATED validation
+func TestAckMessages_Validate(t *testing.T) {
+	runValidationCases(t, []validationCase{
+		{name: "cancel ack", msg: CancelAckMessage{Type: TypeCancelAck, TaskID: 1, Status: StatusCancelled, Success: true}},
+		{name: "kill ack", msg: CancelAckMessage{Type: TypeCancelAck, TaskID: 1, Status: "KILLED", Success: true}},
+		{name: "ack empty status", msg: CancelAckMessage{Type: TypeCancelAck, TaskID: 1}, wantErr: true},
+		{name: "terminated", msg: TaskTerminatedMessage{Type: TypeTaskTerminated, TaskID: 1, Status: "KILLED", Success: true}},
+		{name: "terminated wrong status", msg: TaskTerminatedMessage{Type: TypeTaskTerminated, TaskID: 1, Status: StatusCancelled}, wantErr: true},
+	})
+}
+
+// TestRunnerCapacityMessage_Validate verifies RUNNER_CAPACITY validation
+func TestRunnerCapacityMessage_Validate(t *testing.T) {
+	runValidationCases(t, []validationCase{
+		{name: "valid", msg: RunnerCapacityMessage{Type: TypeRunnerCapacity, MaxParallel: 3, RunningTasks: 1, AvailableSlots: 2}},
+		{name: "zero max", msg: RunnerCapacityMessage{Type: TypeRunnerCapacity}, wantErr: true},
+		{name: "negative max", msg: RunnerCapacityMessage{Type: TypeRunnerCapacity, MaxParallel: -1}, wantErr: true},
+		{name: "negative running", msg: RunnerCapacityMessage{Type: TypeRunnerCapacity, MaxParallel: 3, RunningTasks: -1}, wantErr: true},
+		{name: "available over max", msg: RunnerCapacityMessage{Type: TypeRunnerCapacity, MaxParallel: 3, AvailableSlots: 4}, wantErr: true},
+		{name: "reserved", msg: RunnerCapacityMessage{Type: TypeRunnerCapacity, MaxParallel: 3, AvailableSlots: 1, Reserved: 2,
+			Reservations: []Reservation{{ReservationID: "deploy", Weight: 2, ExpiresAt: "2025-10-15T10:00:00Z"}}}},
+		{name: "reserved over max", msg: RunnerCapacityMessage{Type: TypeRunnerCapacity, MaxParallel: 3, AvailableSlots: 2, Reserved: 2}, wantErr: true},
+		{name: "reservation without weight", msg: RunnerCapacityMessage{Type: TypeRunnerCapacity, MaxParallel: 3,
+			Reservations: []Reservation{{ReservationID: "deploy", ExpiresAt: "2025-10-15T10:00:00Z"}}}, wantErr: true},
+	})
+}
+
+// TestShutdownMessages_Validate verifies RUNNER_DRAINING, BYE and GOODBYE validation
+func TestShutdownMessages_Validate(t *testing.T) {
+	runValidationCases(t, []validationCase{
+		{name: "draining", msg: RunnerDrainingMessage{Type: TypeRunnerDraining, RunningTasks: 2, GraceSeconds: 30}},
+		{name: "negative grace", msg: RunnerDrainingMessage{Type: TypeRunnerDraining, GraceSeconds: -1}, wantErr: true},
+		{name: "drained bye", msg: ByeMessage{Type: TypeBye, Drained: true}},
+		{name: "bye after cancelling", msg: ByeMessage{Type: TypeBye, CancelledTasks: 2}},
+		{name: "drained bye with cancellations", msg: ByeMessage{Type: TypeBye, Drained: true, CancelledTasks: 1}, wantErr: true},
+		{name: "goodbye", msg: NewGoodbye(GoodbyeForced, []int64{4, 9})},
+		{name: "goodbye unknown reason", msg: GoodbyeMessage{Type: TypeGoodbye, Reason: "BORED"}, wantErr: true},
+		{name: "goodbye bad task ID", msg: GoodbyeMessage{Type: TypeGoodbye, Reason: GoodbyeClosed, UnfinishedTasks: []int64{0}}, wantErr: true},
+	})
+}
+
+// TestRunnerResyncMessage_Validate verifies RUNNER_RESYNC validation
+func TestRunnerResyncMessage_Validate(t *testing.T) {
+	running := ResyncTask{TaskID: 3, State: ResyncRunning, StartedAt: "2026-01-02T15:04:05Z", ElapsedMs: 1500}
+	runValidationCases(t, []validationCase{
+		{name: "no tasks", msg: NewRunnerResync(nil, 5, 0, 5)},
+		{name: "running and queued", msg: NewRunnerResync([]ResyncTask{running, {TaskID: 4, State: ResyncQueued}}, 2, 2, 0)},
+		{name: "no capacity", msg: NewRunnerResync(nil, 0, 0, 0), wantErr: true},
+		{name: "too many slots", msg: NewRunnerResync(nil, 2, 0, 3), wantErr: true},
+		{name: "bad task ID", msg: NewRunnerResync([]ResyncTask{{TaskID: 0, State: ResyncQueued}}, 2, 1, 1), wantErr: true},
+		{name: "unknown state", msg: NewRunnerResync([]ResyncTask{{TaskID: 4, State: "PAUSED"}}, 2, 1, 1), wantErr: true},
+		{name: "bad startedAt", msg: NewRunnerResync([]ResyncTask{{TaskID: 4, State: ResyncRunning, StartedAt: "noon"}}, 2, 1, 1), wantErr: true},
+	})
+}
+
+// TestRunnerHeartbeatMessage_Validate verifies RUNNER_HEARTBEAT validation
+func TestRunnerHeartbeatMessage_Validate(t *testing.T) {
+	runValidationCases(t, []validationCase{
+		{name: "valid", msg: NewRunnerHeartbeat(time.Hour, 5, 2, 3, 4, 1.25)},
+		{name: "idle, load unknown", msg: NewRunnerHeartbeat(0, 5, 0, 5, 0, 0)},
+		{name: "no capacity", msg: NewRunnerHeartbeat(time.Hour, 0, 0, 0, 0, 0), wantErr: true},
+		{name: "too many slots", msg: NewRunnerHeartbeat(time.Hour, 2, 0, 3, 0, 0), wantErr: true},
+		{name: "negative queue", msg: NewRunnerHeartbeat(time.Hour, 2, 0, 2, -1, 0), wantErr: true},
+		{name: "negative load", msg: NewRunnerHeartbeat(time.Hour, 2, 0, 2, 0, -1), wantErr: true},
+		{name: "negative uptime", msg: NewRunnerHeartbeat(-time.Hour, 2, 0, 2, 0, 0), wantErr: true},
+		{name: "degraded", msg: RunnerHeartbeatMessage{Type: TypeRunnerHeartbeat, MaxParallel: 2, WriteP95Millis: 2500, Degraded: true}},
+		{name: "negative write latency", msg: RunnerHeartbeatMessage{Type: TypeRunnerHeartbeat, MaxParallel: 2, WriteP95Millis: -1}, wantErr: true},
+	})
+}
+
+// TestAckMessage_Validate verifies ACK validation
+func TestAckMessage_Validate(t *testing.T) {
+	runValidationCases(t, []validationCase{
+		{name: "valid", msg: AckMessage{Type: TypeAck, MessageID: "m-1"}},
+		{name: "missing id", msg: AckMessage{Type: TypeAck}, wantErr: true},
+		{name: "wrong type", msg: AckMessage{Type: TypeCancelAck, MessageID: "m-1"}, wantErr: true},
+	})
+}
+
+// TestAuthMessages_Validate verifies AUTH_CHALLENGE, AUTH_RESPONSE and AUTH_OK validation
+func TestAuthMessages_Validate(t *testing.T) {
+	runValidationCases(t, []validationCase{
+		{name: "challenge", msg: AuthChallengeMessage{Type: TypeAuthChallenge, Nonce: "n-1"}},
+		{name: "challenge without nonce", msg: AuthChallengeMessage{Type: TypeAuthChallenge}, wantErr: true},
+		{name: "response", msg: NewAuthResponse("n-1", []byte("secret"))},
+		{name: "response without nonce", msg: AuthResponseMessage{Type: TypeAuthResponse, HMAC: AuthHMAC([]byte("secret"), "n-1")}, wantErr: true},
+		{name: "response hmac not hex", msg: AuthResponseMessage{Type: TypeAuthResponse, Nonce: "n-1", HMAC: "sha256=abc"}, wantErr: true},
+		{name: "response hmac short", msg: AuthResponseMessage{Type: TypeAuthResponse, Nonce: "n-1", HMAC: "abcd"}, wantErr: true},
+		{name: "ok", msg: AuthOKMessage{Type: TypeAuthOK}},
+		{name: "ok wrong type", msg: AuthOKMessage{Type: TypeAck}, wantErr: true},
+	})
+}
+
+// TestShutdownMessage_Validate verifies SHUTDOWN validation
+func TestShutdownMessage_Validate(t *testing.T) {
+	runValidationCases(t, []validationCase{
+		{name: "valid", msg: ShutdownMessage{Type: TypeShutdown, GraceSeconds: 30, Reason: "maintenance"}},
+		{name: "no grace", msg: ShutdownMessage{Type: TypeShutdown}},
+		{name: "negative grace", msg: ShutdownMessage{Type: TypeShutdown, GraceSeconds: -1}, wantErr: true},
+		{name: "wrong type", msg: ShutdownMessage{Type: TypeBye}, wantErr: true},
+	})
+}
+
+// TestStdinInputMessages_Validate verifies STDIN_INPUT and STDIN_INPUT_RESULT validation
+func TestStdinInputMessages_Validate(t *testing.T) {
+	runValidationCases(t, []validationCase{
+		{name: "input", msg: StdinInputMessage{Type: TypeStdinInput, TaskID: 7, Data: "y\n"}},
+		{name: "close only", msg: StdinInputMessage{Type: TypeStdinInput, TaskID: 7, CloseAfter: true}},
+		{name: "nothing to do", msg: StdinInputMessage{Type: TypeStdinInput, TaskID: 7}, wantErr: true},
+		{name: "input without task", msg: StdinInputMessage{Type: TypeStdinInput, Data: "y\n"}, wantErr: true},
+		{name: "written", msg: NewStdinInputResult(7, 2, nil)},
+		{name: "refused", msg: NewStdinInputResult(7, 0, errors.New("task 7 is not running"))},
+		{name: "success with error", msg: StdinInputResultMessage{Type: TypeStdinInputResult, TaskID: 7, Success: true, Error: "x"}, wantErr: true},
+		{name: "negative bytes", msg: StdinInputResultMessage{Type: TypeStdinInputResult, TaskID: 7, Success: true, Bytes: -1}, wantErr: true},
+	})
+}
+
+// TestToolEventMessage_Validate verifies TOOL_EVENT validation
+func TestToolEventMessage_Validate(t *testing.T) {
+	runValidationCases(t, []validationCase{
+		{name: "use", msg: NewToolUse(7, "toolu_1", "Bash", `{"command":"ls"}`)},
+		{name: "result", msg: NewToolResult(7, "toolu_1", "main.go", false)},
+		{name: "use without tool", msg: ToolEventMessage{Type: TypeToolEvent, TaskID: 7, Phase: ToolPhaseUse, ToolUseID: "toolu_1"}, wantErr: true},
+		{name: "result without id", msg: ToolEventMessage{Type: TypeToolEvent, TaskID: 7, Phase: ToolPhaseResult, Output: "x"}, wantErr: true},
+		{name: "unknown phase", msg: ToolEventMessage{Type: TypeToolEvent, TaskID: 7, Phase: "START", Tool: "Bash"}, wantErr: true},
+		{name: "without task", msg: ToolEventMessage{Type: TypeToolEvent, Phase: ToolPhaseUse, Tool: "Bash"}, wantErr: true},
+	})
+}
+
+// TestMessageErrorMessage_Validate verifies MESSAGE_ERROR validation
+func TestMessageErrorMessage_Validate(t *testing.T) {
+	runValidationCases(t, []validationCase{
+		{name: "valid", msg: MessageErrorMessage{Type: TypeMessageError, Code: MessageErrorInvalid, Error: "bad"}},
+		{name: "unknown code", msg: MessageErrorMessage{Type: TypeMessageError, Code: "OOPS", Error: "bad"}, wantErr: true},
+		{name: "missing error", msg: MessageErrorMessage{Type: TypeMessageError, Code: MessageErrorInvalid}, wantErr: true},
+	})
+}
+
+// TestResumeLogsMessages_Validate verifies RESUME_LOGS and RESUME_LOGS_RESULT validation
+func TestResumeLogsMessages_Validate(t *testing.T) {
+	runValidationCases(t, []validationCase{
+		{name: "resume from start", msg: ResumeLogsMessage{Type: TypeResumeLogs, TaskID: 1, LastLineIndex: -1}},
+		{name: "resume before start", msg: ResumeLogsMessage{Type: TypeResumeLogs, TaskID: 1, LastLineIndex: -2}, wantErr: true},
+		{name: "result", msg: NewResumeLogsResult(1, 10, 20)},
+		{name: "refusal", msg: NewResumeLogsRefusal(1, ResumeOutOfRange, "past the end", 0, 20)},
+		{name: "unknown code", msg: ResumeLogsResultMessage{Type: TypeResumeLogsResult, TaskID: 1, Code: "GONE"}, wantErr: true},
+		{name: "success with code", msg: ResumeLogsResultMessage{Type: TypeResumeLogsResult, TaskID: 1, Success: true, Code: ResumeOutOfRange}, wantErr: true},
+	})
+}
+
+// TestRecurringMessages_Validate verifies RECURRING_EXECUTE, CANCEL_RECURRING and RECURRENCE_FIRED validation
+func TestRecurringMessages_Validate(t *testing.T) {
+	task := ExecuteMessage{ScriptContent: "check"}
+	runValidationCases(t, []validationCase{
+		{name: "cron", msg: RecurringExecuteMessage{Type: TypeRecurringExecute, RecurrenceID: "health", Cron: "*/30 * * * *", Task: task}},
+		{name: "interval", msg: RecurringExecuteMessage{Type: TypeRecurringExecute, RecurrenceID: "health", IntervalSeconds: 1800, Task: task}},
+		{name: "missing id", msg: RecurringExecuteMessage{Type: TypeRecurringExecute, IntervalSeconds: 1800, Task: task}, wantErr: true},
+		{name: "no schedule", msg: RecurringExecuteMessage{Type: TypeRecurringExecute, RecurrenceID: "health", Task: task}, wantErr: true},
+		{name: "both schedules", msg: RecurringExecuteMessage{Type: TypeRecurringExecute, RecurrenceID: "health", Cron: "* * * * *", IntervalSeconds: 60, Task: task}, wantErr: true},
+		{name: "negative interval", msg: RecurringExecuteMessage{Type: TypeRecurringExecute, RecurrenceID: "health", IntervalSeconds: -1, Task: task}, wantErr: true},
+		{name: "bad cron", msg: RecurringExecuteMessage{Type: TypeRecurringExecute, RecurrenceID: "health", Cron: "*/30 * * *", Task: task}, wantErr: true},
+		{name: "empty task", msg: RecurringExecuteMessage{Type: TypeRecurringExecute, RecurrenceID: "health", IntervalSeconds: 60}, wantErr: true},
+		{name: "cancel", msg: CancelRecurringMessage{Type: TypeCancelRecurring, RecurrenceID: "health"}},
+		{name: "cancel missing id", msg: CancelRecurringMessage{Type: TypeCancelRecurring}, wantErr: true},
+		{name: "fired", msg: NewRecurrenceFired("health", "health-3", 1<<52+3, time.Now(), false)},
+		{name: "fired missing instance", msg: NewRecurrenceFired("health", "", 1<<52+3, time.Now(), false), wantErr: true},
+	})
+}
+
+// TestReservationMessages_Validate verifies RESERVE_SLOT, RELEASE_SLOT, RESERVE_SLOT_RESULT and RESERVATION_EXPIRED validation
+func TestReservationMessages_Validate(t *testing.T) {
+	runValidationCases(t, []validationCase{
+		{name: "reserve", msg: ReserveSlotMessage{Type: TypeReserveSlot, ReservationID: "deploy", TTLSeconds: 60}},
+		{name: "reserve weighted", msg: ReserveSlotMessage{Type: TypeReserveSlot, ReservationID: "deploy", TTLSeconds: 60, Weight: 2}},
+		{name: "reserve missing id", msg: ReserveSlotMessage{Type: TypeReserveSlot, TTLSeconds: 60}, wantErr: true},
+		{name: "reserve without ttl", msg: ReserveSlotMessage{Type: TypeReserveSlot, ReservationID: "deploy"}, wantErr: true},
+		{name: "reserve negative weight", msg: ReserveSlotMessage{Type: TypeReserveSlot, ReservationID: "deploy", TTLSeconds: 60, Weight: -1}, wantErr: true},
+		{name: "release", msg: ReleaseSlotMessage{Type: TypeReleaseSlot, ReservationID: "deploy"}},
+		{name: "release missing id", msg: ReleaseSlotMessage{Type: TypeReleaseSlot}, wantErr: true},
+		{name: "result", msg: NewReserveSlotResult("deploy", time.Now())},
+		{name: "refusal", msg: NewReserveSlotRefusal("deploy", ReserveInsufficient, "not enough free slots")},
+		{name: "unknown code", msg: NewReserveSlotRefusal("deploy", "FULL", "full"), wantErr: true},
+		{name: "success with code", msg: ReserveSlotResultMessage{Type: TypeReserveSlotResult, ReservationID: "deploy", Success: true, Code: ReserveDraining}, wantErr: true},
+		{name: "expired", msg: NewReservationExpired("deploy", ExpiredTTL)},
+		{name: "unknown reason", msg: ReservationExpiredMessage{Type: TypeReservationExpired, ReservationID: "deploy", Reason: "BORED"}, wantErr: true},
+	})
+}
+
+// TestDecodeIncoming_RejectsInvalid verifies validation runs at the decode boundary
+func TestDecodeIncoming_RejectsInvalid(t *testing.T) {
+	msg, err := DecodeIncoming([]byte(`{"type":"EXECUTE","taskId":0,"scriptContent":"do it"}`))
+	assert.Nil(t, msg)
+	assert.True(t, errors.Is(err, ErrInvalidMessage), "got %v", err)
+}
+
+// TestNewMessageError verifies decode failures map to MESSAGE_ERROR codes
+func TestNewMessageError(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		code    string
+		msgType string
+		taskID  int64
+	}{
+		{name: "malformed", data: `{"type":"EXECUTE","taskId":5`, code: MessageErrorMalformed},
+		{name: "wrong field type", data: `{"type":"EXECUTE","taskId":5,"skipPermissions":"yes"}`, code: MessageErrorMalformed, msgType: TypeExecute, taskID: 5},
+		{name: "unknown type", data: `{"type":"PAUSE_TASK","taskId":6}`, code: MessageErrorUnknownType, msgType: "PAUSE_TASK", taskID: 6},
+		{name: "invalid", data: `{"type":"CANCEL_TASK","taskId":-1}`, code: MessageErrorInvalid, msgType: TypeCancelTask, taskID: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeIncoming([]byte(tt.data))
+			assert.Error(t, err)
+
+			msg := NewMessageError([]byte(tt.data), err)
+			assert.NoError(t, msg.Validate())
+			assert.Equal(t, tt.code, msg.Code)
+			assert.Equal(t, tt.msgType, msg.MessageType)
+			assert.Equal(t, tt.taskID, msg.TaskID)
+		})
+	}
+}
+
+// TestNewMessageError_TooLarge verifies a frame that could not be read is reported without a type or task
+func TestNewMessageError_TooLarge(t *testing.T) {
+	msg := NewMessageError(nil, fmt.Errorf("over the 1024-byte limit: %w", ErrMessageTooLarge))
+	assert.NoError(t, msg.Validate())
+	assert.Equal(t, MessageErrorTooLarge, msg.Code)
+	assert.Empty(t, msg.MessageType)
+	assert.Zero(t, msg.TaskID)
+}
@@ -0,0 +1,227 @@
+// Package orphan applies the orphan policy: what happens to running tasks while the backend is unreachable
+// and nobody is supervising them. With config.OrphanContinue they keep running; otherwise, once the
+// backend has been unreachable for the policy's delay, running tasks are cancelled (OrphanCancelAfter)
+// or stopped with SIGSTOP until the runner reconnects (OrphanPauseAfter). Either way queued tasks are
+// held until then.
+package orphan
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/config"
+	"github.com/berno/aaw-runner/internal/executor"
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/runner"
+)
+
+// Reason is given for the cancellations of OrphanCancelAfter
+const Reason = "backend unreachable"
+
+// unreachable attributes the policy's cancellations
+var unreachable = executor.Attribution{RequestedBy: models.RequestedByOrphanPolicy, Reason: Reason}
+
+// Pool is the part of the executor pool the policy acts on
+type Pool interface {
+	Tasks() []executor.TaskSnapshot
+	CancelTask(taskID int64, by executor.Attribution) error
+	SuspendTask(taskID int64) error
+	ResumeTask(taskID int64) error
+	Pause()
+	Resume()
+	Paused() bool
+}
+
+// timer is the part of *time.Timer the policy uses, so tests can substitute a fake clock
+type timer interface {
+	Stop() bool
+}
+
+// Policy follows the connection lifecycle and acts on the pool when the backend stays unreachable
+// A nil Policy (OrphanContinue) does nothing.
+type Policy struct {
+	mode  string
+	after time.Duration
+	pool  Pool
+
+	afterFunc func(d time.Duration, f func()) timer // time.AfterFunc, faked in tests
+
+	mu           sync.Mutex
+	timer        timer          // Running while disconnected, until the policy acts
+	generation   int            // Bumped on every lifecycle event so a stale timer does nothing
+	disconnected time.Time      // Zero while connected
+	cancelled    map[int64]bool // Tasks cancelled by the policy whose completions are held
+	suspended    []int64        // Tasks stopped by the policy
+	heldQueue    bool           // The policy paused the pool and must resume it
+}
+
+// New returns the policy for mode (a config.Orphan* value), or nil for config.OrphanContinue
+func New(mode string, after time.Duration, pool Pool) *Policy {
+	if mode == config.OrphanContinue || mode == "" {
+		return nil
+	}
+	return &Policy{
+		mode:      mode,
+		after:     after,
+		pool:      pool,
+		afterFunc: func(d time.Duration, f func()) timer { return time.AfterFunc(d, f) },
+		cancelled: make(map[int64]bool),
+	}
+}
+
+// Disconnected starts the countdown after the connection to the backend is lost
+func (p *Policy) Disconnected() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.disconnected.IsZero() {
+		return
+	}
+	p.disconnected = time.Now()
+	p.generation++
+	generation := p.generation
+	p.timer = p.afterFunc(p.after, func() { p.expire(generation) })
+	log.Printf("[ORPHAN] Backend unreachable; running tasks will be %s unless it is back within %s", p.action(), p.after)
+}
+
+// Connected stops the countdown and undoes a pause once the backend is reachable again
+// Completions held for cancelled tasks are the caller's to replay (see Holds).
+func (p *Policy) Connected() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.disconnected.IsZero() {
+		return
+	}
+	outage := time.Since(p.disconnected).Round(time.Second)
+	p.disconnected = time.Time{}
+	p.generation++
+	p.stopTimer()
+	p.cancelled = make(map[int64]bool)
+	if len(p.suspended) > 0 {
+		log.Printf("[ORPHAN] Backend reachable again after %s; resuming %d suspended tasks", outage, len(p.suspended))
+	} else {
+		log.Printf("[ORPHAN] Backend reachable again after %s", outage)
+	}
+	p.release()
+}
+
+// Holds reports whether the completion of taskID should be held for replay after reconnecting,
+// because the policy cancelled the task while the backend was unreachable
+func (p *Policy) Holds(taskID int64) bool {
+	if p == nil {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.cancelled[taskID]
+}
+
+// Close stops the countdown and continues suspended tasks, so a shutdown can cancel them
+func (p *Policy) Close() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.generation++
+	p.stopTimer()
+	if len(p.suspended) > 0 {
+		log.Printf("[ORPHAN] Resuming %d suspended tasks for shutdown", len(p.suspended))
+	}
+	p.release()
+}
+
+// expire applies the policy once the backend has been unreachable for the whole delay
+func (p *Policy) expire(generation int) {
+	p.mu.Lock()
+	if generation != p.generation {
+		p.mu.Unlock()
+		return
+	}
+	p.timer = nil
+	if !p.pool.Paused() {
+		p.pool.Pause()
+		p.heldQueue = true
+	}
+	var running []int64
+	for _, task := range p.pool.Tasks() {
+		if task.State == runner.TaskStateRunning {
+			running = append(running, task.TaskID)
+		}
+	}
+	log.Printf("[ORPHAN] Backend unreachable for %s; %s %d running tasks and holding queued ones", p.after, p.verb(), len(running))
+
+	if p.mode == config.OrphanPauseAfter {
+		defer p.mu.Unlock()
+		for _, taskID := range running {
+			if err := p.pool.SuspendTask(taskID); err != nil {
+				log.Printf("[ORPHAN] Failed to suspend task %d: %v", taskID, err)
+				continue
+			}
+			p.suspended = append(p.suspended, taskID)
+		}
+		return
+	}
+
+	// Mark the tasks before cancelling them so their completions are held; cancelling waits for
+	// each task to exit, so it happens outside the lock
+	for _, taskID := range running {
+		p.cancelled[taskID] = true
+	}
+	p.mu.Unlock()
+	var wg sync.WaitGroup
+	for _, taskID := range running {
+		wg.Add(1)
+		go func(taskID int64) {
+			defer wg.Done()
+			if err := p.pool.CancelTask(taskID, unreachable); err != nil {
+				log.Printf("[ORPHAN] Failed to cancel task %d: %v", taskID, err)
+			}
+		}(taskID)
+	}
+	wg.Wait()
+}
+
+// release continues suspended tasks and the queue held by the policy; callers hold mu
+func (p *Policy) release() {
+	for _, taskID := range p.suspended {
+		if err := p.pool.ResumeTask(taskID); err != nil {
+			log.Printf("[ORPHAN] Failed to resume task %d: %v", taskID, err)
+		}
+	}
+	p.suspended = nil
+	if p.heldQueue {
+		p.pool.Resume()
+		p.heldQueue = false
+	}
+}
+
+// stopTimer cancels a pending countdown; callers hold mu
+func (p *Policy) stopTimer() {
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+}
+
+// action describes what the policy will do, for the log
+func (p *Policy) action() string {
+	if p.mode == config.OrphanPauseAfter {
+		return "suspended"
+	}
+	return "cancelled"
+}
+
+// verb describes what the policy is doing, for the log
+func (p *Policy) verb() string {
+	if p.mode == config.OrphanPauseAfter {
+		return "suspending"
+	}
+	return "cancelling"
+}
@@ -0,0 +1,203 @@
+package orphan
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/config"
+	"github.com/berno/aaw-runner/internal/executor"
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/runner"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock fires AfterFunc callbacks when advanced past their deadline
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Duration
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	at      time.Duration
+	f       func()
+	stopped bool
+}
+
+func (t *fakeTimer) Stop() bool {
+	stopped := t.stopped
+	t.stopped = true
+	return !stopped
+}
+
+func (c *fakeClock) afterFunc(d time.Duration, f func()) timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{at: c.now + d, f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// advance moves the clock forward, running due callbacks synchronously
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	c.now += d
+	var due []*fakeTimer
+	for _, t := range c.timers {
+		if !t.stopped && t.at <= c.now {
+			t.stopped = true
+			due = append(due, t)
+		}
+	}
+	c.mu.Unlock()
+	for _, t := range due {
+		t.f()
+	}
+}
+
+// fakePool records what the policy does to its tasks
+type fakePool struct {
+	mu        sync.Mutex
+	tasks     []executor.TaskSnapshot
+	paused    bool
+	cancelled map[int64]executor.Attribution
+	suspended map[int64]bool
+}
+
+func newFakePool(running ...int64) *fakePool {
+	p := &fakePool{cancelled: make(map[int64]executor.Attribution), suspended: make(map[int64]bool)}
+	for _, id := range running {
+		p.tasks = append(p.tasks, executor.TaskSnapshot{TaskID: id, State: runner.TaskStateRunning})
+	}
+	p.tasks = append(p.tasks, executor.TaskSnapshot{TaskID: 99, State: runner.TaskStateQueued})
+	return p
+}
+
+func (p *fakePool) Tasks() []executor.TaskSnapshot { return p.tasks }
+func (p *fakePool) CancelTask(taskID int64, by executor.Attribution) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.cancelled[taskID] = by
+	return nil
+}
+func (p *fakePool) SuspendTask(taskID int64) error {
+	if taskID == 3 {
+		return fmt.Errorf("task %d is not running", taskID)
+	}
+	p.suspended[taskID] = true
+	return nil
+}
+func (p *fakePool) ResumeTask(taskID int64) error {
+	delete(p.suspended, taskID)
+	return nil
+}
+func (p *fakePool) Pause()       { p.paused = true }
+func (p *fakePool) Resume()      { p.paused = false }
+func (p *fakePool) Paused() bool { return p.paused }
+
+// newPolicy returns a policy on a fake clock
+func newPolicy(mode string, pool Pool) (*Policy, *fakeClock) {
+	clock := &fakeClock{}
+	p := New(mode, 10*time.Minute, pool)
+	p.afterFunc = clock.afterFunc
+	return p, clock
+}
+
+// TestCancelAfter_LongDisconnect verifies running tasks are cancelled once the outage outlasts the delay, and their completions held
+func TestCancelAfter_LongDisconnect(t *testing.T) {
+	pool := newFakePool(1, 2)
+	p, clock := newPolicy(config.OrphanCancelAfter, pool)
+
+	p.Disconnected()
+	clock.advance(9 * time.Minute)
+	assert.Empty(t, pool.cancelled, "Nothing happens within the delay")
+	assert.False(t, pool.paused)
+
+	clock.advance(time.Minute)
+	assert.Equal(t, map[int64]executor.Attribution{
+		1: {RequestedBy: models.RequestedByOrphanPolicy, Reason: "backend unreachable"},
+		2: {RequestedBy: models.RequestedByOrphanPolicy, Reason: "backend unreachable"},
+	}, pool.cancelled)
+	assert.True(t, pool.paused, "Queued tasks are held")
+	assert.True(t, p.Holds(1))
+	assert.True(t, p.Holds(2))
+	assert.False(t, p.Holds(99))
+
+	p.Connected()
+	assert.False(t, pool.paused)
+	assert.False(t, p.Holds(1), "Completions after reconnecting go out directly")
+}
+
+// TestCancelAfter_ShortDisconnect verifies an outage shorter than the delay leaves tasks alone, even across several outages
+func TestCancelAfter_ShortDisconnect(t *testing.T) {
+	pool := newFakePool(1)
+	p, clock := newPolicy(config.OrphanCancelAfter, pool)
+
+	for i := 0; i < 3; i++ {
+		p.Disconnected()
+		clock.advance(6 * time.Minute)
+		p.Connected()
+	}
+	clock.advance(time.Hour)
+	assert.Empty(t, pool.cancelled)
+	assert.False(t, pool.paused)
+}
+
+// TestPauseAfter_SuspendsUntilReconnected verifies running tasks are stopped after the delay and continued on reconnect
+func TestPauseAfter_SuspendsUntilReconnected(t *testing.T) {
+	pool := newFakePool(1, 2, 3)
+	p, clock := newPolicy(config.OrphanPauseAfter, pool)
+
+	p.Disconnected()
+	p.Disconnected() // Repeated notifications do not restart the countdown
+	clock.advance(10 * time.Minute)
+	assert.Equal(t, map[int64]bool{1: true, 2: true}, pool.suspended, "Task 3 failed to suspend")
+	assert.Empty(t, pool.cancelled)
+	assert.False(t, p.Holds(1))
+
+	p.Connected()
+	assert.Empty(t, pool.suspended)
+	assert.False(t, pool.paused)
+}
+
+// TestPauseAfter_OperatorPauseKept verifies a queue the operator paused stays paused after reconnecting
+func TestPauseAfter_OperatorPauseKept(t *testing.T) {
+	pool := newFakePool(1)
+	pool.paused = true
+	p, clock := newPolicy(config.OrphanPauseAfter, pool)
+
+	p.Disconnected()
+	clock.advance(time.Hour)
+	p.Connected()
+	assert.True(t, pool.paused)
+}
+
+// TestClose verifies Close cancels the countdown and continues suspended tasks so shutdown can proceed
+func TestClose(t *testing.T) {
+	pool := newFakePool(1)
+	p, clock := newPolicy(config.OrphanPauseAfter, pool)
+	p.Disconnected()
+	clock.advance(10 * time.Minute)
+	assert.True(t, pool.suspended[1])
+	p.Close()
+	assert.Empty(t, pool.suspended)
+
+	pool = newFakePool(1)
+	p, clock = newPolicy(config.OrphanCancelAfter, pool)
+	p.Disconnected()
+	p.Close()
+	clock.advance(time.Hour)
+	assert.Empty(t, pool.cancelled, "A stopped countdown never fires")
+}
+
+// TestContinue verifies the default policy is a nil no-op
+func TestContinue(t *testing.T) {
+	p := New(config.OrphanContinue, time.Minute, newFakePool(1))
+	assert.Nil(t, p)
+	p.Disconnected()
+	p.Connected()
+	assert.False(t, p.Holds(1))
+	p.Close()
+}
@@ -0,0 +1,24 @@
+// Package pty opens pseudo-terminals for tasks that behave differently on a pipe
+package pty
+
+import (
+	"errors"
+	"os"
+	"runtime"
+)
+
+// ErrUnsupported is returned by Open on platforms without pseudo-terminal support here (other than
+// Linux and macOS)
+var ErrUnsupported = errors.New("pseudo-terminals are not supported on " + runtime.GOOS)
+
+// Size is a terminal window size, in character cells
+type Size struct {
+	Cols, Rows uint16
+}
+
+// Open opens a pseudo-terminal of the given window size
+// The process run on it gets the terminal end as its stdin, stdout and stderr; the runner reads its
+// output, stdout and stderr interleaved, from the controlling end. Both are closed by the caller.
+func Open(size Size) (controller, terminal *os.File, err error) {
+	return open(size)
+}
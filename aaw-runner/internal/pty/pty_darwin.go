@@ -0,0 +1,46 @@
+package pty
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// open opens /dev/ptmx, grants and unlocks its terminal end and opens that by name
+// kqueue does not poll pseudo-terminals, so the controlling end stays blocking: closing it does not
+// end a read in progress, which ends once every process holding the terminal end has exited.
+func open(size Size) (*os.File, *os.File, error) {
+	fd, err := unix.Open("/dev/ptmx", unix.O_RDWR|unix.O_NOCTTY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open /dev/ptmx: %w", err)
+	}
+	controller := os.NewFile(uintptr(fd), "/dev/ptmx")
+	if err := unix.IoctlSetInt(fd, unix.TIOCPTYGRANT, 0); err != nil {
+		controller.Close()
+		return nil, nil, fmt.Errorf("grant pseudo-terminal: %w", err)
+	}
+	if err := unix.IoctlSetInt(fd, unix.TIOCPTYUNLK, 0); err != nil {
+		controller.Close()
+		return nil, nil, fmt.Errorf("unlock pseudo-terminal: %w", err)
+	}
+	var buf [128]byte // TIOCPTYGNAME fills in up to 128 bytes
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), unix.TIOCPTYGNAME, uintptr(unsafe.Pointer(&buf[0]))); errno != 0 {
+		controller.Close()
+		return nil, nil, fmt.Errorf("get pseudo-terminal name: %w", errno)
+	}
+	name, _, _ := bytes.Cut(buf[:], []byte{0})
+	terminal, err := os.OpenFile(string(name), os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		controller.Close()
+		return nil, nil, err
+	}
+	if err := setSize(terminal, size); err != nil {
+		controller.Close()
+		terminal.Close()
+		return nil, nil, err
+	}
+	return controller, terminal, nil
+}
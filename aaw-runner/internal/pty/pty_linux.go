@@ -0,0 +1,39 @@
+package pty
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// open opens /dev/ptmx, unlocks its terminal end and opens that by number
+// The controlling end is non-blocking, so closing it ends a read in progress.
+func open(size Size) (*os.File, *os.File, error) {
+	fd, err := unix.Open("/dev/ptmx", unix.O_RDWR|unix.O_NOCTTY|unix.O_CLOEXEC|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open /dev/ptmx: %w", err)
+	}
+	controller := os.NewFile(uintptr(fd), "/dev/ptmx")
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		controller.Close()
+		return nil, nil, fmt.Errorf("unlock pseudo-terminal: %w", err)
+	}
+	n, err := unix.IoctlGetInt(fd, unix.TIOCGPTN)
+	if err != nil {
+		controller.Close()
+		return nil, nil, fmt.Errorf("get pseudo-terminal number: %w", err)
+	}
+	name := fmt.Sprintf("/dev/pts/%d", n)
+	terminal, err := os.OpenFile(name, os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		controller.Close()
+		return nil, nil, err
+	}
+	if err := setSize(terminal, size); err != nil {
+		controller.Close()
+		terminal.Close()
+		return nil, nil, err
+	}
+	return controller, terminal, nil
+}
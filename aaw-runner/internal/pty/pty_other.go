@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package pty
+
+import "os"
+
+// open fails: pseudo-terminals are only opened on Linux and macOS
+func open(Size) (*os.File, *os.File, error) {
+	return nil, nil, ErrUnsupported
+}
@@ -0,0 +1,42 @@
+package pty
+
+import (
+	"errors"
+	"io"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+// TestOpen verifies the terminal end is a terminal of the requested size, whose writes are read from
+// the controlling end, and that other platforms get ErrUnsupported
+func TestOpen(t *testing.T) {
+	controller, terminal, err := Open(Size{Cols: 120, Rows: 40})
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		assert.ErrorIs(t, err, ErrUnsupported)
+		return
+	}
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer controller.Close()
+	defer terminal.Close()
+
+	ws, err := unix.IoctlGetWinsize(int(terminal.Fd()), unix.TIOCGWINSZ)
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(120), ws.Col)
+	assert.Equal(t, uint16(40), ws.Row)
+
+	_, err = terminal.Write([]byte("hello\n"))
+	assert.NoError(t, err)
+	buf := make([]byte, 64)
+	n, err := controller.Read(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello\r\n", string(buf[:n]), "The terminal turns \\n into \\r\\n")
+
+	terminal.Close()
+	_, err = controller.Read(buf)
+	assert.True(t, errors.Is(err, unix.EIO) || errors.Is(err, io.EOF), "Closed terminal end: %v", err)
+}
@@ -0,0 +1,19 @@
+//go:build linux || darwin
+
+package pty
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// setSize sets the window size of a terminal
+func setSize(terminal *os.File, size Size) error {
+	ws := &unix.Winsize{Row: size.Rows, Col: size.Cols}
+	if err := unix.IoctlSetWinsize(int(terminal.Fd()), unix.TIOCSWINSZ, ws); err != nil {
+		return fmt.Errorf("set pseudo-terminal size: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,384 @@
+// Package recurring runs tasks on schedules kept by the runner itself (RECURRING_EXECUTE), so the
+// backend does not have to tick every "run this every 30 minutes" task. Registered recurrences are
+// kept in recurring.json under the state dir and survive restarts.
+//
+// At each tick a fresh instance of the recurrence's task is announced with RECURRENCE_FIRED and
+// submitted like any EXECUTE, so the usual capacity limits apply. Ticks that fall while the runner
+// cannot take tasks (disconnected, draining or not running) are dropped or made up for by one late
+// instance, as the catch-up policy says (config.CatchUpSkip or config.CatchUpRunOnce).
+package recurring
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/config"
+	"github.com/berno/aaw-runner/internal/cron"
+	"github.com/berno/aaw-runner/internal/models"
+)
+
+// FileName is the registered recurrences' file inside the state dir
+const FileName = "recurring.json"
+
+// TaskIDBase is added to a runner-wide sequence number to make the task ID of each instance
+// It is far above the IDs the backend assigns, and keeps instance IDs exactly representable in JSON.
+const TaskIDBase int64 = 1 << 52
+
+// schedule says when a recurrence ticks next
+type schedule interface {
+	Next(after time.Time) time.Time
+}
+
+// localCron applies a cron expression in the runner's local time, whatever the zone of the times it is given
+type localCron struct {
+	*cron.Schedule
+}
+
+func (c localCron) Next(after time.Time) time.Time {
+	return c.Schedule.Next(after.In(time.Local))
+}
+
+// every ticks at a fixed interval
+type every time.Duration
+
+func (e every) Next(after time.Time) time.Time {
+	return after.Add(time.Duration(e))
+}
+
+// Recurrence is a registered recurring task, as kept in the state dir
+type Recurrence struct {
+	ID              string                `json:"recurrenceId"`
+	Cron            string                `json:"cron,omitempty"`
+	IntervalSeconds int64                 `json:"intervalSeconds,omitempty"`
+	Task            models.ExecuteMessage `json:"task"`
+
+	LastTick time.Time  `json:"lastTick"`         // Latest tick handled, or when the recurrence was registered
+	Missed   *time.Time `json:"missed,omitempty"` // Latest tick missed and not yet caught up on
+
+	schedule schedule
+}
+
+// file is the layout of recurring.json
+type file struct {
+	NextSeq     int64         `json:"nextSeq"`
+	Recurrences []*Recurrence `json:"recurrences"`
+}
+
+// Fire announces and submits an instance of a recurrence
+type Fire func(fired models.RecurrenceFiredMessage, task models.ExecuteMessage)
+
+// timer is the part of *time.Timer the scheduler uses, so tests can substitute a fake clock
+type timer interface {
+	Stop() bool
+}
+
+// Scheduler ticks the registered recurrences
+// A nil Scheduler has no recurrences and ignores every call.
+type Scheduler struct {
+	path    string
+	catchUp string
+
+	now       func() time.Time                      // time.Now, faked in tests
+	afterFunc func(d time.Duration, f func()) timer // time.AfterFunc, faked in tests
+
+	mu          sync.Mutex
+	recurrences map[string]*Recurrence
+	nextSeq     int64
+	ready       func() bool // Whether instances can run now; nil until Start
+	fire        Fire
+	timer       timer
+	closed      bool
+}
+
+// Open loads the recurrences registered in stateDir; catchUp is a config.CatchUp* policy
+func Open(stateDir, catchUp string) (*Scheduler, error) {
+	s := &Scheduler{
+		path:        filepath.Join(stateDir, FileName),
+		catchUp:     catchUp,
+		now:         time.Now,
+		afterFunc:   func(d time.Duration, f func()) timer { return time.AfterFunc(d, f) },
+		recurrences: make(map[string]*Recurrence),
+	}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", s.path, err)
+	}
+	s.nextSeq = f.NextSeq
+	for _, r := range f.Recurrences {
+		if r.schedule, err = parseSchedule(r.Cron, r.IntervalSeconds); err != nil {
+			return nil, fmt.Errorf("%s: recurrence %s: %w", s.path, r.ID, err)
+		}
+		s.recurrences[r.ID] = r
+	}
+	return s, nil
+}
+
+// parseSchedule returns the schedule of a cron expression or an interval, whichever is set
+func parseSchedule(expr string, intervalSeconds int64) (schedule, error) {
+	if expr == "" {
+		if intervalSeconds <= 0 {
+			return nil, errors.New("no cron expression or interval")
+		}
+		return every(time.Duration(intervalSeconds) * time.Second), nil
+	}
+	c, err := cron.Parse(expr)
+	if err != nil {
+		return nil, err
+	}
+	return localCron{c}, nil
+}
+
+// Start starts ticking: ready reports whether instances can run (connected and not draining), and
+// fire announces and submits each one. Ticks missed while the runner was stopped are recorded at
+// once, for CatchUp.
+func (s *Scheduler) Start(ready func() bool, fire Fire) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.ready, s.fire = ready, fire
+	s.mu.Unlock()
+	if n := len(s.List()); n > 0 {
+		log.Printf("[RECURRING] %d recurring tasks registered", n)
+	}
+	s.tick()
+}
+
+// Register adds a recurrence, or replaces the one with the same ID; its first tick is one period from now
+func (s *Scheduler) Register(msg models.RecurringExecuteMessage) error {
+	if s == nil {
+		return errors.New("recurring tasks are not enabled")
+	}
+	sched, err := parseSchedule(msg.Cron, msg.IntervalSeconds)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recurrences[msg.RecurrenceID] = &Recurrence{
+		ID:              msg.RecurrenceID,
+		Cron:            msg.Cron,
+		IntervalSeconds: msg.IntervalSeconds,
+		Task:            msg.Task,
+		LastTick:        s.now(),
+		schedule:        sched,
+	}
+	s.rearm()
+	return s.save()
+}
+
+// Cancel removes a recurrence, reporting whether it was registered
+func (s *Scheduler) Cancel(id string) (bool, error) {
+	if s == nil {
+		return false, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.recurrences[id]; !ok {
+		return false, nil
+	}
+	delete(s.recurrences, id)
+	s.rearm()
+	return true, s.save()
+}
+
+// List returns the registered recurrences, by ID
+func (s *Scheduler) List() []Recurrence {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]Recurrence, 0, len(s.recurrences))
+	for _, r := range s.recurrences {
+		list = append(list, *r)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// CatchUp applies the catch-up policy to the ticks missed so far; call it once the runner can take
+// tasks again (after connecting, or undraining)
+func (s *Scheduler) CatchUp() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.fire == nil {
+		s.mu.Unlock()
+		return
+	}
+	var fire []instance
+	changed := false
+	for _, r := range s.sorted() {
+		if r.Missed == nil {
+			continue
+		}
+		if s.catchUp == config.CatchUpRunOnce {
+			fire = append(fire, s.instance(r, *r.Missed, true))
+		} else {
+			log.Printf("[RECURRING] Skipping missed tick of %s at %s", r.ID, r.Missed.Format(time.RFC3339))
+		}
+		r.Missed = nil
+		changed = true
+	}
+	var err error
+	if changed {
+		err = s.save()
+	}
+	s.mu.Unlock()
+	if err != nil {
+		log.Printf("[RECURRING] Failed to save recurrences: %v", err)
+	}
+	s.run(fire)
+}
+
+// Close stops ticking
+func (s *Scheduler) Close() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+}
+
+// instance is one fired instance of a recurrence
+type instance struct {
+	fired models.RecurrenceFiredMessage
+	task  models.ExecuteMessage
+}
+
+// tick fires the recurrences that are due, or records their ticks as missed when the runner cannot
+// take tasks, then waits for the next one. However many ticks passed, each recurrence fires once.
+func (s *Scheduler) tick() {
+	s.mu.Lock()
+	if s.closed || s.fire == nil {
+		s.mu.Unlock()
+		return
+	}
+	now := s.now()
+	ready := s.ready()
+	var fire []instance
+	due := false
+	for _, r := range s.sorted() {
+		tick := r.schedule.Next(r.LastTick)
+		if tick.IsZero() || tick.After(now) {
+			continue
+		}
+		for next := r.schedule.Next(tick); !next.IsZero() && !next.After(now); next = r.schedule.Next(next) {
+			tick = next
+		}
+		r.LastTick = tick
+		due = true
+		if ready {
+			fire = append(fire, s.instance(r, tick, false))
+		} else {
+			missed := tick
+			r.Missed = &missed
+		}
+	}
+	var err error
+	if due {
+		err = s.save()
+	}
+	s.rearm()
+	s.mu.Unlock()
+	if err != nil {
+		log.Printf("[RECURRING] Failed to save recurrences: %v", err)
+	}
+	s.run(fire)
+}
+
+// instance builds the next instance of r for its tick at scheduledAt; callers hold mu and save afterwards
+func (s *Scheduler) instance(r *Recurrence, scheduledAt time.Time, catchUp bool) instance {
+	seq := s.nextSeq
+	s.nextSeq++
+	task := r.Task
+	task.Type = models.TypeExecute
+	task.TaskID = TaskIDBase + seq
+	return instance{
+		fired: models.NewRecurrenceFired(r.ID, fmt.Sprintf("%s-%d", r.ID, seq), task.TaskID, scheduledAt, catchUp),
+		task:  task,
+	}
+}
+
+// run fires instances; called without mu, since submitting a task reports to the backend
+func (s *Scheduler) run(instances []instance) {
+	for _, in := range instances {
+		log.Printf("[RECURRING] Firing %s as task %d", in.fired.InstanceID, in.task.TaskID)
+		s.fire(in.fired, in.task)
+	}
+}
+
+// rearm sets the timer for the earliest next tick; callers hold mu
+func (s *Scheduler) rearm() {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	if s.closed || s.fire == nil {
+		return
+	}
+	var next time.Time
+	for _, r := range s.recurrences {
+		if t := r.schedule.Next(r.LastTick); !t.IsZero() && (next.IsZero() || t.Before(next)) {
+			next = t
+		}
+	}
+	if !next.IsZero() {
+		s.timer = s.afterFunc(next.Sub(s.now()), s.tick)
+	}
+}
+
+// sorted returns the recurrences by ID, so instances fire in a stable order; callers hold mu
+func (s *Scheduler) sorted() []*Recurrence {
+	list := make([]*Recurrence, 0, len(s.recurrences))
+	for _, r := range s.recurrences {
+		list = append(list, r)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// save writes the recurrences to the state dir, atomically; callers hold mu
+func (s *Scheduler) save() error {
+	data, err := json.MarshalIndent(file{NextSeq: s.nextSeq, Recurrences: s.sorted()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), "."+FileName+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
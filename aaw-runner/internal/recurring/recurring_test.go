@@ -0,0 +1,193 @@
+package recurring
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/config"
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock stands in for time.Now and time.AfterFunc, keeping the last timer set
+type fakeClock struct {
+	now   time.Time
+	delay time.Duration // Of the last timer set
+	f     func()        // Fired by advance
+}
+
+type fakeTimer struct{}
+
+func (fakeTimer) Stop() bool { return true }
+
+func (c *fakeClock) afterFunc(d time.Duration, f func()) timer {
+	c.delay, c.f = d, f
+	return fakeTimer{}
+}
+
+// advance moves the clock on by d and runs the pending timer, as its expiry would
+func (c *fakeClock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+	if c.f != nil {
+		f := c.f
+		c.f = nil
+		f()
+	}
+}
+
+// firings records the instances a scheduler fires
+type firings struct {
+	mu    sync.Mutex
+	fired []models.RecurrenceFiredMessage
+	tasks []models.ExecuteMessage
+}
+
+func (f *firings) fire(fired models.RecurrenceFiredMessage, task models.ExecuteMessage) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fired = append(f.fired, fired)
+	f.tasks = append(f.tasks, task)
+}
+
+// open opens a scheduler in dir on a fake clock and starts it, ready until the returned flag is cleared
+func open(t *testing.T, dir, catchUp string, clock *fakeClock) (*Scheduler, *firings, *bool) {
+	return openReady(t, dir, catchUp, clock, true)
+}
+
+// openReady is open, starting ready or not
+func openReady(t *testing.T, dir, catchUp string, clock *fakeClock, isReady bool) (*Scheduler, *firings, *bool) {
+	t.Helper()
+	s, err := Open(dir, catchUp)
+	assert.NoError(t, err)
+	s.now = func() time.Time { return clock.now }
+	s.afterFunc = clock.afterFunc
+	rec := &firings{}
+	ready := isReady
+	s.Start(func() bool { return ready }, rec.fire)
+	t.Cleanup(s.Close)
+	return s, rec, &ready
+}
+
+// healthCheck registers every half hour
+var healthCheck = models.RecurringExecuteMessage{
+	Type:            models.TypeRecurringExecute,
+	RecurrenceID:    "health",
+	IntervalSeconds: 1800,
+	Task:            models.ExecuteMessage{ScriptContent: "check the service", Metadata: map[string]string{"team": "infra"}},
+}
+
+// TestScheduler_Interval verifies instances fire every interval with fresh task IDs, once however many ticks passed
+func TestScheduler_Interval(t *testing.T) {
+	start := time.Date(2025, 10, 15, 10, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: start}
+	s, rec, _ := open(t, t.TempDir(), config.CatchUpSkip, clock)
+	assert.NoError(t, s.Register(healthCheck))
+	assert.Equal(t, 30*time.Minute, clock.delay)
+
+	clock.advance(30 * time.Minute)
+	clock.advance(70 * time.Minute) // Two ticks at once, e.g. after the host slept
+	if !assert.Len(t, rec.fired, 2) {
+		return
+	}
+	assert.Equal(t, models.NewRecurrenceFired("health", "health-0", TaskIDBase, start.Add(30*time.Minute), false), rec.fired[0])
+	assert.Equal(t, models.NewRecurrenceFired("health", "health-1", TaskIDBase+1, start.Add(90*time.Minute), false), rec.fired[1])
+	assert.Equal(t, models.ExecuteMessage{Type: models.TypeExecute, TaskID: TaskIDBase, ScriptContent: "check the service",
+		Metadata: map[string]string{"team": "infra"}}, rec.tasks[0])
+	assert.Equal(t, 20*time.Minute, clock.delay, "The schedule stays aligned to the registration")
+}
+
+// TestScheduler_Cron verifies cron recurrences wait for their next matching minute
+func TestScheduler_Cron(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 10, 15, 10, 7, 30, 0, time.Local)}
+	s, rec, _ := open(t, t.TempDir(), config.CatchUpSkip, clock)
+	assert.NoError(t, s.Register(models.RecurringExecuteMessage{RecurrenceID: "report", Cron: "0 * * * *",
+		Task: models.ExecuteMessage{ScriptContent: "report"}}))
+	assert.Equal(t, 52*time.Minute+30*time.Second, clock.delay)
+
+	clock.advance(clock.delay)
+	if assert.Len(t, rec.fired, 1) {
+		assert.Equal(t, time.Date(2025, 10, 15, 11, 0, 0, 0, time.Local).Format(time.RFC3339), rec.fired[0].ScheduledAt)
+	}
+}
+
+// TestScheduler_Persistence verifies recurrences and the instance sequence survive a restart, and cancelling removes them
+func TestScheduler_Persistence(t *testing.T) {
+	dir := t.TempDir()
+	clock := &fakeClock{now: time.Date(2025, 10, 15, 10, 0, 0, 0, time.UTC)}
+	s, _, _ := open(t, dir, config.CatchUpSkip, clock)
+	assert.NoError(t, s.Register(healthCheck))
+	clock.advance(30 * time.Minute)
+	s.Close()
+
+	s, rec, _ := open(t, dir, config.CatchUpSkip, clock)
+	if list := s.List(); assert.Len(t, list, 1) {
+		assert.Equal(t, "health", list[0].ID)
+		assert.Equal(t, healthCheck.Task, list[0].Task)
+		assert.True(t, clock.now.Equal(list[0].LastTick))
+	}
+	clock.advance(30 * time.Minute)
+	if assert.Len(t, rec.fired, 1) {
+		assert.Equal(t, TaskIDBase+1, rec.fired[0].TaskID, "Task IDs are never reused")
+	}
+
+	removed, err := s.Cancel("health")
+	assert.NoError(t, err)
+	assert.True(t, removed)
+	removed, err = s.Cancel("health")
+	assert.NoError(t, err)
+	assert.False(t, removed)
+	s.Close()
+
+	s, _, _ = open(t, dir, config.CatchUpSkip, clock)
+	assert.Empty(t, s.List())
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, FileName), []byte("{"), 0o600))
+	_, err = Open(dir, config.CatchUpSkip)
+	assert.ErrorContains(t, err, "parsing")
+}
+
+// TestScheduler_CatchUp verifies ticks missed while unready or stopped are skipped or run once, as configured
+func TestScheduler_CatchUp(t *testing.T) {
+	start := time.Date(2025, 10, 15, 10, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		policy string
+		fires  bool
+	}{
+		{config.CatchUpSkip, false},
+		{config.CatchUpRunOnce, true},
+	} {
+		t.Run(tt.policy, func(t *testing.T) {
+			dir := t.TempDir()
+			clock := &fakeClock{now: start}
+			s, rec, ready := open(t, dir, tt.policy, clock)
+			assert.NoError(t, s.Register(healthCheck))
+
+			// Disconnected or draining across two ticks
+			*ready = false
+			clock.advance(30 * time.Minute)
+			clock.advance(30 * time.Minute)
+			assert.Empty(t, rec.fired)
+			*ready = true
+			s.CatchUp()
+			if tt.fires && assert.Len(t, rec.fired, 1) {
+				assert.Equal(t, models.NewRecurrenceFired("health", "health-0", TaskIDBase, start.Add(time.Hour), true), rec.fired[0])
+			} else {
+				assert.Empty(t, rec.fired)
+			}
+			s.CatchUp()
+			assert.Len(t, rec.fired, map[bool]int{false: 0, true: 1}[tt.fires], "Each missed tick is caught up on once")
+
+			// Stopped across a tick: the restarted scheduler finds it missed before connecting
+			s.Close()
+			clock.now = clock.now.Add(45 * time.Minute)
+			s, rec, ready = openReady(t, dir, tt.policy, clock, false)
+			assert.Empty(t, rec.fired)
+			*ready = true
+			s.CatchUp()
+			assert.Equal(t, tt.fires, len(rec.fired) == 1)
+		})
+	}
+}
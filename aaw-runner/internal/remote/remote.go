@@ -0,0 +1,297 @@
+// Package remote runs tasks on hosts without a runner of their own, over SSH
+// The hosts a task may name in EXECUTE's "host" are listed in a YAML file (--ssh-hosts-file):
+//
+//	knownHosts: /etc/aaw/known_hosts   # default ~/.ssh/known_hosts; host keys are always verified
+//	hosts:
+//	  gpu-box:
+//	    address: gpu.internal:22
+//	    user: aaw
+//	    identityFile: /etc/aaw/id_ed25519
+//	    workdir: /srv/aaw
+//	    claudePath: /usr/local/bin/claude
+//	    env:
+//	      CUDA_VISIBLE_DEVICES: "0"
+//
+// One connection per host is kept open and shared by every task running there.
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"gopkg.in/yaml.v3"
+)
+
+// dialTimeout bounds connecting and authenticating to a host
+const dialTimeout = 15 * time.Second
+
+// Host is one entry of the hosts file
+type Host struct {
+	Address      string            `yaml:"address"`      // host[:port], port 22 when omitted
+	User         string            `yaml:"user"`         // Login user
+	IdentityFile string            `yaml:"identityFile"` // Private key used for public key authentication
+	Workdir      string            `yaml:"workdir"`      // Directory tasks run in (empty: the user's home)
+	ClaudePath   string            `yaml:"claudePath"`   // claude binary on the host (default "claude")
+	Env          map[string]string `yaml:"env"`          // Environment variables set for every task
+}
+
+// file is the layout of the hosts file
+type file struct {
+	KnownHosts string          `yaml:"knownHosts"`
+	Hosts      map[string]Host `yaml:"hosts"`
+}
+
+// ConnectError reports that a host could not be reached or refused the runner's credentials
+type ConnectError struct {
+	Host string
+	Err  error
+}
+
+func (e *ConnectError) Error() string {
+	return fmt.Sprintf("connecting to %s: %v", e.Host, e.Err)
+}
+
+func (e *ConnectError) Unwrap() error {
+	return e.Err
+}
+
+// HostKeyError reports that a host's key is not in the known_hosts file or does not match it
+type HostKeyError struct {
+	Host string
+	Err  error
+}
+
+func (e *HostKeyError) Error() string {
+	return fmt.Sprintf("verifying the host key of %s: %v", e.Host, e.Err)
+}
+
+func (e *HostKeyError) Unwrap() error {
+	return e.Err
+}
+
+// Hosts holds the configured hosts and a pool of one SSH connection per host
+type Hosts struct {
+	hosts   map[string]Host
+	configs map[string]*ssh.ClientConfig
+
+	// dial connects to a host, ssh.Dial outside of tests
+	dial func(network, addr string, config *ssh.ClientConfig) (*ssh.Client, error)
+
+	mu      sync.Mutex
+	clients map[string]*ssh.Client
+}
+
+// Load reads the hosts file, their keys and the known_hosts file
+func Load(path string) (*Hosts, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading SSH hosts file: %w", err)
+	}
+	var f file
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parsing SSH hosts file %s: %w", path, err)
+	}
+	if f.KnownHosts == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("SSH hosts file %s: knownHosts is not set and there is no home directory: %w", path, err)
+		}
+		f.KnownHosts = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	hostKeys, err := knownhosts.New(f.KnownHosts)
+	if err != nil {
+		return nil, fmt.Errorf("reading known hosts: %w", err)
+	}
+
+	h := newHosts()
+	for name, host := range f.Hosts {
+		if host.Address == "" || host.User == "" || host.IdentityFile == "" {
+			return nil, fmt.Errorf("SSH host %s: address, user and identityFile are required", name)
+		}
+		key, err := os.ReadFile(host.IdentityFile)
+		if err != nil {
+			return nil, fmt.Errorf("SSH host %s: %w", name, err)
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("SSH host %s: parsing %s: %w", name, host.IdentityFile, err)
+		}
+		h.Add(name, host, &ssh.ClientConfig{
+			User:            host.User,
+			Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+			HostKeyCallback: hostKeys,
+			Timeout:         dialTimeout,
+		})
+	}
+	return h, nil
+}
+
+func newHosts() *Hosts {
+	return &Hosts{
+		hosts:   make(map[string]Host),
+		configs: make(map[string]*ssh.ClientConfig),
+		dial:    ssh.Dial,
+		clients: make(map[string]*ssh.Client),
+	}
+}
+
+// Add registers a host reached with config; Load uses it for every entry of the hosts file
+// Failures of config's host key callback are reported as HostKeyError.
+func (h *Hosts) Add(name string, host Host, config *ssh.ClientConfig) {
+	if host.ClaudePath == "" {
+		host.ClaudePath = "claude"
+	}
+	if _, _, err := net.SplitHostPort(host.Address); err != nil {
+		host.Address = net.JoinHostPort(host.Address, "22")
+	}
+	verify := config.HostKeyCallback
+	config.HostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if err := verify(hostname, remote, key); err != nil {
+			return &HostKeyError{Host: name, Err: err}
+		}
+		return nil
+	}
+	h.hosts[name] = host
+	h.configs[name] = config
+}
+
+// Lookup returns a configured host
+func (h *Hosts) Lookup(name string) (Host, bool) {
+	if h == nil {
+		return Host{}, false
+	}
+	host, ok := h.hosts[name]
+	return host, ok
+}
+
+// Names lists the configured hosts, sorted
+func (h *Hosts) Names() []string {
+	if h == nil {
+		return nil
+	}
+	names := make([]string, 0, len(h.hosts))
+	for name := range h.hosts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// client returns the pooled connection to a host, connecting if there is none
+func (h *Hosts) client(name string) (*ssh.Client, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if c := h.clients[name]; c != nil {
+		return c, nil
+	}
+	c, err := h.dial("tcp", h.hosts[name].Address, h.configs[name])
+	if err != nil {
+		var keyErr *HostKeyError
+		if errors.As(err, &keyErr) {
+			return nil, keyErr
+		}
+		return nil, &ConnectError{Host: name, Err: err}
+	}
+	h.clients[name] = c
+	return c, nil
+}
+
+// Forget closes and drops the pooled connection to a host if it is still c, after it broke
+func (h *Hosts) Forget(name string, c *ssh.Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.clients[name] == c {
+		delete(h.clients, name)
+	}
+	c.Close()
+}
+
+// Session opens a session on the pooled connection to a host, reconnecting once if that connection
+// turns out to be dead. The connection is returned so a caller can Forget it when it breaks.
+func (h *Hosts) Session(name string) (*ssh.Session, *ssh.Client, error) {
+	if _, ok := h.Lookup(name); !ok {
+		return nil, nil, fmt.Errorf("unknown SSH host %q", name)
+	}
+	for attempt := 0; ; attempt++ {
+		c, err := h.client(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		session, err := c.NewSession()
+		if err == nil {
+			return session, c, nil
+		}
+		h.Forget(name, c)
+		if attempt > 0 {
+			return nil, nil, &ConnectError{Host: name, Err: err}
+		}
+	}
+}
+
+// Run runs a short command on a host and waits for it
+func (h *Hosts) Run(name, command string) error {
+	session, _, err := h.Session(name)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	return session.Run(command)
+}
+
+// Close closes every pooled connection
+func (h *Hosts) Close() {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for name, c := range h.clients {
+		c.Close()
+		delete(h.clients, name)
+	}
+}
+
+// PgidPrefix starts the line a task's remote shell prints before anything else: its process group,
+// which the runner signals to cancel or kill the task
+const PgidPrefix = "AAW_PGID "
+
+// Command is the shell command line running argv on host in its workdir and environment
+// sshd makes the login shell a session leader, and exec keeps its PID, so the printed $$ is the
+// process group of the task and everything it starts. The login shell must be POSIX.
+func Command(host Host, argv []string) string {
+	var b strings.Builder
+	b.WriteString("printf '" + PgidPrefix + "%s\\n' $$ && ")
+	if host.Workdir != "" {
+		b.WriteString("cd " + Quote(host.Workdir) + " && ")
+	}
+	b.WriteString("exec")
+	if len(host.Env) > 0 {
+		b.WriteString(" env")
+		keys := make([]string, 0, len(host.Env))
+		for k := range host.Env {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			b.WriteString(" " + Quote(k+"="+host.Env[k]))
+		}
+	}
+	for _, arg := range argv {
+		b.WriteString(" " + Quote(arg))
+	}
+	return b.String()
+}
+
+// Quote quotes s for a POSIX shell
+func Quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
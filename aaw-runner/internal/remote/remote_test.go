@@ -0,0 +1,102 @@
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/berno/aaw-runner/internal/remote/sshtest"
+	"github.com/stretchr/testify/assert"
+)
+
+// hostsFile writes a hosts file with one host, "box", pointing at addr
+func hostsFile(t *testing.T, srv *sshtest.Server, addr, knownHosts string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "hosts.yaml")
+	content := fmt.Sprintf("knownHosts: %s\nhosts:\n  box:\n    address: %s\n    user: aaw\n    identityFile: %s\n    workdir: /tmp\n",
+		knownHosts, addr, srv.IdentityFile)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+// TestLoad verifies the hosts file is read with its defaults, and incomplete entries are rejected
+func TestLoad(t *testing.T) {
+	srv := sshtest.NewServer(t)
+	hosts, err := Load(hostsFile(t, srv, "127.0.0.1", srv.KnownHostsFile))
+	assert.NoError(t, err)
+	host, ok := hosts.Lookup("box")
+	assert.True(t, ok)
+	assert.Equal(t, Host{Address: "127.0.0.1:22", User: "aaw", IdentityFile: srv.IdentityFile, Workdir: "/tmp", ClaudePath: "claude"}, host)
+	assert.Equal(t, []string{"box"}, hosts.Names())
+
+	path := filepath.Join(t.TempDir(), "bad.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("knownHosts: "+srv.KnownHostsFile+"\nhosts:\n  box:\n    address: gpu\n"), 0o600))
+	_, err = Load(path)
+	assert.ErrorContains(t, err, "address, user and identityFile are required")
+
+	var none *Hosts
+	_, ok = none.Lookup("box")
+	assert.False(t, ok)
+}
+
+// TestSession_PoolsConnections verifies tasks share one connection per host, and a dead one is replaced
+func TestSession_PoolsConnections(t *testing.T) {
+	srv := sshtest.NewServer(t)
+	hosts, err := Load(hostsFile(t, srv, srv.Addr, srv.KnownHostsFile))
+	assert.NoError(t, err)
+	defer hosts.Close()
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, hosts.Run("box", "true"))
+	}
+	assert.Equal(t, int64(1), srv.Connections.Load())
+
+	srv.DropConnections()
+	assert.NoError(t, hosts.Run("box", "true"), "The broken connection is replaced")
+	assert.Equal(t, int64(2), srv.Connections.Load())
+}
+
+// TestSession_Errors verifies unknown hosts, unreachable hosts and host key mismatches are told apart
+func TestSession_Errors(t *testing.T) {
+	srv := sshtest.NewServer(t)
+	other := sshtest.NewServer(t) // Its known_hosts file trusts a different key
+
+	hosts, err := Load(hostsFile(t, srv, srv.Addr, other.KnownHostsFile))
+	assert.NoError(t, err)
+	_, _, err = hosts.Session("box")
+	var keyErr *HostKeyError
+	assert.True(t, errors.As(err, &keyErr), "got %v", err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	closed := listener.Addr().String()
+	listener.Close()
+	hosts, err = Load(hostsFile(t, srv, closed, srv.KnownHostsFile))
+	assert.NoError(t, err)
+	_, _, err = hosts.Session("box")
+	var connErr *ConnectError
+	assert.True(t, errors.As(err, &connErr), "got %v", err)
+
+	_, _, err = hosts.Session("gpu")
+	assert.EqualError(t, err, `unknown SSH host "gpu"`)
+}
+
+// TestCommand verifies the command line reports the process group, then runs argv quoted in the workdir and environment
+func TestCommand(t *testing.T) {
+	dir := t.TempDir()
+	host := Host{Workdir: dir, Env: map[string]string{"GREETING": "it's $HOME"}}
+	command := Command(host, []string{"sh", "-c", `printf '%s|%s|%s\n' "$GREETING" "$(pwd)" "$1"`, "sh", "a b; c"})
+
+	out, err := exec.Command("/bin/sh", "-c", command).Output()
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if assert.Len(t, lines, 2) {
+		assert.True(t, strings.HasPrefix(lines[0], PgidPrefix))
+		assert.Equal(t, "it's $HOME|"+dir+"|a b; c", lines[1])
+	}
+}
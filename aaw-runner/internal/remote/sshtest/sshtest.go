@@ -0,0 +1,208 @@
+// Package sshtest runs an in-process SSH server for tests of remote execution
+// Commands run locally with /bin/sh, each in a session of its own as sshd would start them.
+package sshtest
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Server is an SSH server accepting one client key
+type Server struct {
+	Addr string
+
+	// Files for the client: its private key and a known_hosts file trusting the server
+	IdentityFile   string
+	KnownHostsFile string
+
+	Connections atomic.Int64 // Connections accepted so far
+
+	listener net.Listener
+	config   *ssh.ServerConfig
+	mu       sync.Mutex
+	conns    []net.Conn
+}
+
+// NewServer starts a server on a loopback port, stopped when the test ends
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+	_, hostPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostKey, err := ssh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientPub, clientPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authorized, err := ssh.NewPublicKey(clientPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{Addr: listener.Addr().String(), listener: listener}
+	s.config = &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) != string(authorized.Marshal()) {
+				return nil, errors.New("unknown key")
+			}
+			return nil, nil
+		},
+	}
+	s.config.AddHostKey(hostKey)
+
+	dir := t.TempDir()
+	block, err := ssh.MarshalPrivateKey(clientPriv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.IdentityFile = filepath.Join(dir, "id_ed25519")
+	s.KnownHostsFile = filepath.Join(dir, "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(s.Addr)}, hostKey.PublicKey()) + "\n"
+	if err := os.WriteFile(s.IdentityFile, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(s.KnownHostsFile, []byte(line), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	go s.serve()
+	t.Cleanup(s.Close)
+	return s
+}
+
+// DropConnections cuts every open connection, as a network failure would
+func (s *Server) DropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.conns {
+		c.Close()
+	}
+	s.conns = nil
+}
+
+// Close stops accepting connections and cuts the open ones
+func (s *Server) Close() {
+	s.listener.Close()
+	s.DropConnections()
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	_, channels, requests, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	s.Connections.Add(1)
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "only sessions")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go session(channel, requests)
+	}
+}
+
+// session serves one session: a single exec request, and signals for it
+func session(channel ssh.Channel, requests <-chan *ssh.Request) {
+	var cmd *exec.Cmd
+	for req := range requests {
+		switch req.Type {
+		case "exec":
+			if cmd != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			var payload struct{ Command string }
+			ssh.Unmarshal(req.Payload, &payload)
+			cmd = exec.Command("/bin/sh", "-c", payload.Command)
+			cmd.Stdout = channel
+			cmd.Stderr = channel.Stderr()
+			cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+			if err := cmd.Start(); err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			req.Reply(true, nil)
+			go exit(channel, cmd)
+		case "signal":
+			var payload struct{ Signal string }
+			ssh.Unmarshal(req.Payload, &payload)
+			if cmd != nil && cmd.Process != nil {
+				cmd.Process.Signal(signals[payload.Signal])
+			}
+		default:
+			if req.WantReply {
+				req.Reply(false, nil)
+			}
+		}
+	}
+	// The client closed the session; the command keeps running, as it would under sshd
+	channel.Close()
+}
+
+var signals = map[string]syscall.Signal{"TERM": syscall.SIGTERM, "KILL": syscall.SIGKILL, "INT": syscall.SIGINT}
+
+// exit waits for the command, reports how it ended and closes the channel
+func exit(channel ssh.Channel, cmd *exec.Cmd) {
+	cmd.Wait()
+	status, _ := cmd.ProcessState.Sys().(syscall.WaitStatus)
+	if status.Signaled() {
+		name := ""
+		for n, sig := range signals {
+			if sig == status.Signal() {
+				name = n
+			}
+		}
+		channel.SendRequest("exit-signal", false, ssh.Marshal(struct {
+			Signal     string
+			CoreDumped bool
+			Error      string
+			Lang       string
+		}{Signal: name}))
+	} else {
+		payload := make([]byte, 4)
+		binary.BigEndian.PutUint32(payload, uint32(cmd.ProcessState.ExitCode()))
+		channel.SendRequest("exit-status", false, payload)
+	}
+	channel.Close()
+}
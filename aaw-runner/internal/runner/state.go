@@ -2,8 +2,6 @@ package runner
 
 import (
 	"log"
-	"os"
-	"strconv"
 	"sync"
 )
 
@@ -63,16 +61,6 @@ func (ts TaskState) String() string {
 // DefaultMaxParallel is the default number of concurrent tasks
 const DefaultMaxParallel = 5
 
-// GetMaxParallel returns the configured max parallel tasks from environment
-func GetMaxParallel() int {
-	if envVal := os.Getenv("AAW_MAX_PARALLEL_TASKS"); envVal != "" {
-		if val, err := strconv.Atoi(envVal); err == nil && val > 0 {
-			return val
-		}
-	}
-	return DefaultMaxParallel
-}
-
 // TaskStateEntry holds state info for a task
 type TaskStateEntry struct {
 	TaskID int64
@@ -90,7 +78,7 @@ type TaskStateManager struct {
 // NewTaskStateManager creates a new task state manager
 func NewTaskStateManager(maxParallel int, onChange func(int64, TaskState)) *TaskStateManager {
 	if maxParallel <= 0 {
-		maxParallel = GetMaxParallel()
+		maxParallel = DefaultMaxParallel
 	}
 
 	tsm := &TaskStateManager{
@@ -195,6 +183,21 @@ func (tsm *TaskStateManager) GetCapacity() (maxParallel, running, available int)
 	return tsm.maxParallel, running, tsm.maxParallel - running
 }
 
+// Snapshot returns the state of every tracked task along with the capacity, read under one lock so they agree
+func (tsm *TaskStateManager) Snapshot() (entries []TaskStateEntry, maxParallel, running int) {
+	tsm.mu.RLock()
+	defer tsm.mu.RUnlock()
+
+	entries = make([]TaskStateEntry, 0, len(tsm.states))
+	for taskID, state := range tsm.states {
+		entries = append(entries, TaskStateEntry{TaskID: taskID, State: state})
+		if state == TaskStateRunning || state == TaskStateCancelling {
+			running++
+		}
+	}
+	return entries, tsm.maxParallel, running
+}
+
 // StateMachine manages the runner's state transitions (legacy support)
 // This is kept for backward compatibility but delegates to TaskStateManager
 type StateMachine struct {
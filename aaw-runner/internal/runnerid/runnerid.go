@@ -0,0 +1,55 @@
+// Package runnerid gives the runner an ID that stays the same across reconnects and restarts, so the
+// backend can tell it apart from another runner on a host of the same name. The ID is a random UUID
+// generated on first start and kept in a file (config.Config.RunnerIDPath).
+package runnerid
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Load returns the ID kept at path, generating and saving one on first start. When the file cannot
+// be read or written it still returns a fresh ID, which only lasts until the runner exits, along with
+// the error saying why.
+func Load(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		if id, parseErr := uuid.Parse(strings.TrimSpace(string(data))); parseErr == nil {
+			return id.String(), nil
+		}
+		err = fmt.Errorf("%s does not hold a runner ID", path)
+	}
+	id := uuid.NewString()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return id, err
+	}
+	if err := save(path, id); err != nil {
+		return id, err
+	}
+	return id, nil
+}
+
+// save writes id to path, atomically
+func save(path, id string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+	if _, err := tmp.WriteString(id + "\n"); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,43 @@
+package runnerid
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestLoad_Persists verifies the ID generated on first start is saved and returned by every later load
+func TestLoad_Persists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "runner-id")
+	id, err := Load(path)
+	assert.NoError(t, err)
+	assert.NoError(t, uuid.Validate(id))
+
+	again, err := Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, id, again)
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, id+"\n", string(data))
+}
+
+// TestLoad_Ephemeral verifies an ID is still returned, with the reason, when the file cannot be written or holds no ID
+func TestLoad_Ephemeral(t *testing.T) {
+	dir := t.TempDir()
+	blocker := filepath.Join(dir, "file")
+	assert.NoError(t, os.WriteFile(blocker, nil, 0o600))
+	id, err := Load(filepath.Join(blocker, "runner-id")) // A file where the directory should be
+	assert.Error(t, err)
+	assert.NoError(t, uuid.Validate(id))
+
+	junk := filepath.Join(dir, "runner-id")
+	assert.NoError(t, os.WriteFile(junk, []byte("not an id\n"), 0o600))
+	id, err = Load(junk)
+	assert.ErrorContains(t, err, "does not hold a runner ID")
+	assert.NoError(t, uuid.Validate(id))
+	data, _ := os.ReadFile(junk)
+	assert.Equal(t, "not an id\n", string(data), "A file that is not understood is left alone")
+}
@@ -0,0 +1,188 @@
+// Package runonce runs a single task through the regular executor pipeline without a backend
+// ("aaw-runner run"), for debugging tasks locally and as a smoke test of the execution path
+package runonce
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/config"
+	"github.com/berno/aaw-runner/internal/executor"
+	"github.com/berno/aaw-runner/internal/models"
+)
+
+// EngineClaude runs --content as a claude prompt, the only engine so far
+const EngineClaude = "claude"
+
+// taskID identifies the single task in log lines and status output
+const taskID int64 = 1
+
+// Exit statuses for outcomes without a process exit status of their own
+const (
+	ExitFailed      = 1   // Failed before or without exiting normally (start failure, signal)
+	ExitInterrupted = 130 // Cancelled by Ctrl-C, as shells report SIGINT
+)
+
+// Options describes the task to run
+type Options struct {
+	Engine          string
+	Content         string // Prompt for the engine
+	Script          string // Script file run with bash (legacy execution), instead of Content
+	SkipPermissions bool
+	SessionMode     string
+}
+
+// ParseArgs parses "aaw-runner run" arguments: the task flags plus every runner setting
+// Returns flag.ErrHelp when --help was requested (usage has already been written to output)
+func ParseArgs(args []string, getenv func(string) string, output io.Writer) (Options, config.Config, error) {
+	opts := Options{Engine: EngineClaude}
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.StringVar(&opts.Engine, "engine", opts.Engine, `engine that runs --content (only "claude")`)
+	fs.StringVar(&opts.Content, "content", "", "prompt to run")
+	fs.StringVar(&opts.Script, "script", "", "script file to run with bash instead of a prompt")
+	fs.BoolVar(&opts.SkipPermissions, "skip-permissions", false, "pass --dangerously-skip-permissions to claude")
+	fs.StringVar(&opts.SessionMode, "session-mode", "", `"NEW" (default) or "PERSIST"`)
+
+	cfg, err := config.LoadWithFlags(args, getenv, output, fs)
+	if err != nil {
+		return Options{}, config.Config{}, err
+	}
+	return opts, cfg, opts.validate()
+}
+
+// validate checks the task flags, reusing EXECUTE validation for the task itself
+func (o Options) validate() error {
+	if o.Engine != EngineClaude {
+		return fmt.Errorf("unknown engine %q (supported: %s)", o.Engine, EngineClaude)
+	}
+	if (o.Content == "") == (o.Script == "") {
+		return errors.New("exactly one of --content or --script is required")
+	}
+	return o.message().Validate()
+}
+
+// message builds the EXECUTE the backend would have sent for this task
+func (o Options) message() models.ExecuteMessage {
+	return models.ExecuteMessage{
+		Type:            models.TypeExecute,
+		TaskID:          taskID,
+		Script:          o.Script,
+		ScriptContent:   o.Content,
+		SkipPermissions: o.SkipPermissions,
+		SessionMode:     o.SessionMode,
+	}
+}
+
+// Run executes the task and returns the process exit status to use: the task's own exit status when
+// it exited, ExitInterrupted when cancelled via interrupt, ExitFailed otherwise
+// LOG lines are printed to stdout and status changes to stderr. The first value on interrupt cancels
+// the task gracefully, the next one kills it.
+func Run(opts Options, cfg config.Config, stdout, stderr io.Writer, interrupt <-chan os.Signal) int {
+	cfg.MaxParallel = 1
+	// stdout and stderr streams are read concurrently; one lock keeps lines whole and in order
+	var mu sync.Mutex
+	stdout, stderr = &lockedWriter{mu: &mu, w: stdout}, &lockedWriter{mu: &mu, w: stderr}
+
+	te := executor.NewTaskExecutorWithConfig(cfg,
+		func(msg models.LogMessage) { fmt.Fprintln(stdout, msg.Line) },
+		func(msg models.StatusUpdateMessage) { printStatus(stderr, msg) },
+	)
+
+	results := make(chan executor.TaskResult, 1)
+	pool := executor.NewExecutorPoolWithConfig(te, cfg, nil, func(result executor.TaskResult) { results <- result })
+	pool.SetTaskStartHandler(func(int64, map[string]string) {
+		fmt.Fprintf(stderr, "[STATUS] %s\n", models.StatusRunning)
+	})
+	pool.Start()
+	defer pool.Stop()
+
+	if !pool.Submit(opts.message()) {
+		_, reason := pool.RejectReason()
+		fmt.Fprintf(stderr, "[STATUS] %s: %s\n", models.StatusFailed, reason)
+		return ExitFailed
+	}
+
+	interrupts := 0
+	for {
+		select {
+		case result := <-results:
+			return report(stderr, result)
+		case <-interrupt:
+			interrupts++
+			if interrupts == 1 {
+				log.Println("[CANCEL] Interrupted, cancelling task (interrupt again to kill)")
+				go cancel(pool, taskID)
+			} else {
+				log.Println("[KILL] Interrupted again, killing task")
+				go pool.ForceKillTask(taskID, interrupted)
+			}
+		}
+	}
+}
+
+// interrupted attributes the cancel and kill of an interrupted run
+var interrupted = executor.Attribution{RequestedBy: models.RequestedByInterrupt, Reason: "interrupted"}
+
+// cancel gracefully cancels the task, logging failures (e.g. a legacy script cannot be cancelled)
+func cancel(pool *executor.ExecutorPool, taskID int64) {
+	if err := pool.CancelTask(taskID, interrupted); err != nil {
+		log.Printf("[CANCEL] %v", err)
+	}
+}
+
+// printStatus writes a status change, with the detection that triggered it
+func printStatus(w io.Writer, msg models.StatusUpdateMessage) {
+	fmt.Fprintf(w, "[STATUS] %s", msg.Status)
+	if d := msg.Detection; d != nil {
+		fmt.Fprintf(w, " (%s: %q on line %d)", d.Category, d.Matched, d.LineNumber)
+	}
+	if msg.ResetAt != "" {
+		fmt.Fprintf(w, " until %s", msg.ResetAt)
+	}
+	fmt.Fprintln(w)
+}
+
+// report prints the final status and maps the result to an exit status
+func report(w io.Writer, result executor.TaskResult) int {
+	if usage := result.Usage; usage != nil {
+		fmt.Fprintf(w, "[USAGE] user %s, system %s, max RSS %d KB\n",
+			usage.UserCPU.Round(time.Millisecond), usage.SystemCPU.Round(time.Millisecond), usage.MaxRSSKb)
+	}
+	if result.Success {
+		fmt.Fprintf(w, "[STATUS] %s\n", models.StatusCompleted)
+		return 0
+	}
+
+	fmt.Fprintf(w, "[STATUS] %s: %s (%s)", result.Status(), result.Error, result.ErrorCode)
+	if result.Classification != "" {
+		fmt.Fprintf(w, " [%s: %s]", result.Classification, result.Evidence)
+	}
+	fmt.Fprintln(w)
+
+	switch {
+	case result.ErrorCode == models.ErrorCodeCancelled:
+		return ExitInterrupted
+	case result.ExitCode > 0:
+		return result.ExitCode
+	default:
+		return ExitFailed
+	}
+}
+
+// lockedWriter serializes writes to w
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
@@ -0,0 +1,99 @@
+package runonce
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/config"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// noEnv is a getenv with nothing set
+func noEnv(string) string { return "" }
+
+// TestParseArgs verifies task flags and runner settings are parsed together
+func TestParseArgs(t *testing.T) {
+	opts, cfg, err := ParseArgs([]string{"--content", "fix the tests", "--skip-permissions", "--workdir", "./proj",
+		"--secret-masking=false"}, noEnv, io.Discard)
+
+	assert.NoError(t, err)
+	assert.Equal(t, Options{Engine: EngineClaude, Content: "fix the tests", SkipPermissions: true}, opts)
+	assert.Equal(t, "./proj", cfg.Workdir)
+	assert.False(t, cfg.SecretMasking)
+}
+
+// TestParseArgs_Invalid verifies unusable task flags are rejected
+func TestParseArgs_Invalid(t *testing.T) {
+	for name, args := range map[string][]string{
+		"no task":        {},
+		"both":           {"--content", "x", "--script", "job.sh"},
+		"unknown engine": {"--engine", "gpt", "--content", "x"},
+		"bad session":    {"--content", "x", "--session-mode", "FOREVER"},
+		"unknown flag":   {"--content", "x", "--nope"},
+	} {
+		_, _, err := ParseArgs(args, noEnv, io.Discard)
+		assert.Error(t, err, name)
+	}
+}
+
+// TestParseArgs_Help verifies --help lists the task flags and the runner settings
+func TestParseArgs_Help(t *testing.T) {
+	var out bytes.Buffer
+	_, _, err := ParseArgs([]string{"--help"}, noEnv, &out)
+
+	assert.True(t, config.IsHelp(err))
+	assert.Contains(t, out.String(), "Usage: aaw-runner run [flags]")
+	assert.Contains(t, out.String(), "-content")
+	assert.Contains(t, out.String(), "--backend-url")
+}
+
+// run executes opts with default settings and returns the exit status and both outputs
+func run(t *testing.T, opts Options, interrupt <-chan os.Signal) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := Run(opts, config.Default(), &stdout, &stderr, interrupt)
+	return code, stdout.String(), stderr.String()
+}
+
+// TestRun_Script verifies the fixture goes through masking and detection and exits with its own status
+func TestRun_Script(t *testing.T) {
+	code, stdout, stderr := run(t, Options{Engine: EngineClaude, Script: "testdata/job.sh"}, nil)
+
+	assert.Equal(t, 4, code)
+	assert.Contains(t, stdout, "building with api_key=***\n")
+	assert.NotContains(t, stdout, "abc123456789")
+	assert.Contains(t, stdout, "warning: deprecated flag\n")
+	assert.Contains(t, stderr, "[STATUS] RUNNING\n")
+	assert.Contains(t, stderr, "[STATUS] RATE_LIMITED (RATE_LIMIT:")
+	assert.Contains(t, stderr, "[STATUS] FAILED: exit status 4 (EXIT_NONZERO)")
+}
+
+// TestRun_Content verifies a prompt is passed to claude and success exits 0
+func TestRun_Content(t *testing.T) {
+	testutil.FakeClaude(t, `echo "prompt: $1"`)
+
+	code, stdout, stderr := run(t, Options{Engine: EngineClaude, Content: "fix the tests"}, nil)
+
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "prompt: fix the tests\n")
+	assert.Contains(t, stderr, "[STATUS] COMPLETED\n")
+}
+
+// TestRun_Interrupt verifies an interrupt cancels the task through the regular cancel path
+func TestRun_Interrupt(t *testing.T) {
+	testutil.FakeClaude(t, "echo started; sleep 30")
+	interrupt := make(chan os.Signal, 1)
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		interrupt <- os.Interrupt
+	}()
+
+	code, _, stderr := run(t, Options{Engine: EngineClaude, Content: "hang"}, interrupt)
+
+	assert.Equal(t, ExitInterrupted, code)
+	assert.Contains(t, stderr, "[STATUS] CANCELLED: task cancelled (CANCELLED)")
+}
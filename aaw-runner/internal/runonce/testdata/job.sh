@@ -0,0 +1,5 @@
+# Fixture for TestRun_Script: output on both streams, a secret to mask, a detection, and a failing exit
+echo "building with api_key=abc123456789"
+echo "warning: deprecated flag" >&2
+echo "Error: 429 Too Many Requests"
+exit 4
@@ -0,0 +1,203 @@
+// Package schema generates JSON Schemas for the runner's protocol messages
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/berno/aaw-runner/internal/models"
+)
+
+// Draft is the JSON Schema dialect of the generated files
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Message describes one protocol message for schema generation
+type Message struct {
+	Type  string              // Value of the type discriminator
+	Value interface{}         // Zero value of the message struct
+	Enums map[string][]string // Allowed values by JSON field name (the type field is added automatically)
+}
+
+// Messages lists every protocol message, in both directions
+var Messages = []Message{
+	{Type: models.TypeHelo, Value: models.HeloMessage{}},
+	{Type: models.TypeHeloAck, Value: models.HeloAckMessage{}},
+	{Type: models.TypeLog, Value: models.LogMessage{}, Enums: map[string][]string{
+		"severity": models.Severities,
+		"stream":   models.Streams,
+	}},
+	{Type: models.TypeStatusUpdate, Value: models.StatusUpdateMessage{}, Enums: map[string][]string{"status": models.TaskStatuses}},
+	{Type: models.TypeExecute, Value: models.ExecuteMessage{}, Enums: map[string][]string{
+		"sessionMode":  models.SessionModes,
+		"outputFormat": models.OutputFormats,
+		"stallAction":  models.StallActions,
+	}},
+	{Type: models.TypeRunnerStatus, Value: models.RunnerStatusMessage{}, Enums: map[string][]string{"status": models.RunnerStatuses}},
+	{Type: models.TypeTaskStarted, Value: models.TaskStartedMessage{}},
+	{Type: models.TypeTaskCompleted, Value: models.TaskCompletedMessage{}, Enums: map[string][]string{
+		"errorCode":      models.ErrorCodes,
+		"classification": models.Classifications,
+	}},
+	{Type: models.TypeCancelTask, Value: models.CancelTaskMessage{}},
+	{Type: models.TypeKillTask, Value: models.KillTaskMessage{}},
+	{Type: models.TypeCancelAck, Value: models.CancelAckMessage{}, Enums: map[string][]string{"status": models.CancelAckStatuses}},
+	{Type: models.TypeTaskTerminated, Value: models.TaskTerminatedMessage{}, Enums: map[string][]string{"status": {"KILLED"}}},
+	{Type: models.TypeRunnerCapacity, Value: models.RunnerCapacityMessage{}},
+	{Type: models.TypeRunnerDraining, Value: models.RunnerDrainingMessage{}},
+	{Type: models.TypeBye, Value: models.ByeMessage{}},
+	{Type: models.TypeGoodbye, Value: models.GoodbyeMessage{}, Enums: map[string][]string{"reason": models.GoodbyeReasons}},
+	{Type: models.TypeRunnerResync, Value: models.RunnerResyncMessage{}},
+	{Type: models.TypeAck, Value: models.AckMessage{}},
+	{Type: models.TypeRunnerHeartbeat, Value: models.RunnerHeartbeatMessage{}},
+	{Type: models.TypeAuthChallenge, Value: models.AuthChallengeMessage{}},
+	{Type: models.TypeAuthResponse, Value: models.AuthResponseMessage{}},
+	{Type: models.TypeAuthOK, Value: models.AuthOKMessage{}},
+	{Type: models.TypeShutdown, Value: models.ShutdownMessage{}},
+	{Type: models.TypeStdinInput, Value: models.StdinInputMessage{}},
+	{Type: models.TypeStdinInputResult, Value: models.StdinInputResultMessage{}},
+	{Type: models.TypeToolEvent, Value: models.ToolEventMessage{}, Enums: map[string][]string{"phase": models.ToolPhases}},
+	{Type: models.TypeMessageError, Value: models.MessageErrorMessage{}, Enums: map[string][]string{"code": models.MessageErrorCodes}},
+	{Type: models.TypeResumeLogs, Value: models.ResumeLogsMessage{}},
+	{Type: models.TypeResumeLogsResult, Value: models.ResumeLogsResultMessage{}, Enums: map[string][]string{"code": models.ResumeCodes}},
+	{Type: models.TypeRecurringExecute, Value: models.RecurringExecuteMessage{}},
+	{Type: models.TypeCancelRecurring, Value: models.CancelRecurringMessage{}},
+	{Type: models.TypeRecurrenceFired, Value: models.RecurrenceFiredMessage{}},
+	{Type: models.TypeReserveSlot, Value: models.ReserveSlotMessage{}},
+	{Type: models.TypeReleaseSlot, Value: models.ReleaseSlotMessage{}},
+	{Type: models.TypeReserveSlotResult, Value: models.ReserveSlotResultMessage{}, Enums: map[string][]string{"code": models.ReserveCodes}},
+	{Type: models.TypeReservationExpired, Value: models.ReservationExpiredMessage{}, Enums: map[string][]string{"reason": models.ExpiryReasons}},
+}
+
+// FileName returns the schema file name for a message type (e.g. "status_update.schema.json")
+func FileName(msgType string) string {
+	return strings.ToLower(msgType) + ".schema.json"
+}
+
+// Generate returns the schema for a message as indented JSON
+// Object keys are sorted by encoding/json, so the output is byte-for-byte stable
+func Generate(msg Message) ([]byte, error) {
+	root := objectSchema(reflect.TypeOf(msg.Value), msg.Enums)
+	root["$schema"] = Draft
+	root["$id"] = FileName(msg.Type)
+	root["title"] = msg.Type
+	root["x-schemaVersion"] = models.SchemaVersion
+
+	props := root["properties"].(map[string]interface{})
+	props["type"] = map[string]interface{}{"type": "string", "const": msg.Type}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(root); err != nil {
+		return nil, fmt.Errorf("failed to encode schema for %s: %w", msg.Type, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteAll writes one schema file per message into dir, creating it if needed
+func WriteAll(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create schema directory: %w", err)
+	}
+	for _, msg := range Messages {
+		data, err := Generate(msg)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, FileName(msg.Type)), data, 0o644); err != nil {
+			return fmt.Errorf("failed to write schema for %s: %w", msg.Type, err)
+		}
+	}
+	return nil
+}
+
+// objectSchema describes a struct; fields without omitempty are always serialized and so are required
+func objectSchema(t reflect.Type, enums map[string][]string) map[string]interface{} {
+	props := make(map[string]interface{})
+	required := []string{}
+	addFields(t, enums, props, &required)
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": props,
+	}
+	if len(required) > 0 {
+		schema["required"] = sortedCopy(required)
+	}
+	return schema
+}
+
+// addFields collects the JSON fields of t, flattening embedded structs like encoding/json does
+func addFields(t reflect.Type, enums map[string][]string, props map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			addFields(field.Type, enums, props, required)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		prop := typeSchema(field.Type)
+		if values, ok := enums[name]; ok {
+			prop["enum"] = values
+		}
+		if name == "schemaVersion" {
+			prop["minimum"] = models.MinSchemaVersion
+			prop["maximum"] = models.SchemaVersion
+		}
+		props[name] = prop
+
+		if !strings.Contains(opts, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// typeSchema maps a Go type to its JSON Schema
+func typeSchema(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(t.Elem())
+	case reflect.Struct:
+		return objectSchema(t, nil)
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// sortedCopy returns values sorted, leaving the input untouched
+func sortedCopy(values []string) []string {
+	sorted := append([]string(nil), values...)
+	sort.Strings(sorted)
+	return sorted
+}
@@ -0,0 +1,72 @@
+package schema
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// goldenDir holds the committed schemas, regenerated with "go generate" from the module root
+const goldenDir = "../../schemas"
+
+// TestSchemas_MatchGolden verifies the committed schemas are up to date with the message structs
+func TestSchemas_MatchGolden(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, WriteAll(dir))
+
+	generated, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	golden, err := os.ReadDir(goldenDir)
+	assert.NoError(t, err)
+	assert.Equal(t, len(generated), len(golden), "Schema files were added or removed; run go generate")
+
+	for _, entry := range generated {
+		want, err := os.ReadFile(filepath.Join(goldenDir, entry.Name()))
+		if !assert.NoError(t, err, "Missing golden schema; run go generate") {
+			continue
+		}
+		got, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		assert.NoError(t, err)
+		assert.Equal(t, string(want), string(got), "%s is out of date; run go generate", entry.Name())
+	}
+}
+
+// TestGenerate_Deterministic verifies repeated generation is byte-for-byte identical
+func TestGenerate_Deterministic(t *testing.T) {
+	for _, msg := range Messages {
+		first, err := Generate(msg)
+		assert.NoError(t, err)
+		second, err := Generate(msg)
+		assert.NoError(t, err)
+		assert.Equal(t, first, second, msg.Type)
+	}
+}
+
+// TestGenerate_RequiredAndEnums verifies required fields, enums and the embedded schemaVersion
+func TestGenerate_RequiredAndEnums(t *testing.T) {
+	data, err := Generate(Message{
+		Type:  models.TypeTaskCompleted,
+		Value: models.TaskCompletedMessage{},
+		Enums: map[string][]string{"errorCode": models.ErrorCodes},
+	})
+	assert.NoError(t, err)
+
+	var schema struct {
+		Required   []string `json:"required"`
+		Properties map[string]struct {
+			Const   string   `json:"const"`
+			Enum    []string `json:"enum"`
+			Maximum int      `json:"maximum"`
+		} `json:"properties"`
+	}
+	assert.NoError(t, json.Unmarshal(data, &schema))
+
+	assert.Equal(t, []string{"exitCode", "success", "taskId", "type"}, schema.Required, "omitempty fields are optional")
+	assert.Equal(t, models.TypeTaskCompleted, schema.Properties["type"].Const)
+	assert.Equal(t, models.ErrorCodes, schema.Properties["errorCode"].Enum)
+	assert.Equal(t, models.SchemaVersion, schema.Properties["schemaVersion"].Maximum)
+}
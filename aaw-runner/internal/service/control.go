@@ -0,0 +1,73 @@
+package service
+
+import (
+	"os"
+	"time"
+)
+
+// Control is a request from the service manager
+type Control int
+
+const (
+	ControlInterrogate Control = iota // Report the current state again
+	ControlStop                       // Operator stop (sc stop, services.msc)
+	ControlShutdown                   // The host is shutting down; the window is much shorter
+)
+
+// State is what the runner reports back to the service manager
+type State int
+
+const (
+	StateRunning State = iota
+	StateStopPending
+	StateStopped
+)
+
+// controlWindows is how long the service manager waits after each stop request
+var controlWindows = map[Control]time.Duration{
+	ControlStop:     StopWindow,
+	ControlShutdown: ShutdownWindow,
+}
+
+// serve answers the service manager until done is closed, then reports the service stopped
+// The first stop or shutdown request becomes an os.Interrupt on signals, taking the runner's graceful
+// shutdown path; if the runner is still stopping when the request's window is about to run out, a second
+// os.Interrupt escalates to the forced shutdown so tasks are killed and reported before the manager
+// gives up on the process. report receives each state with how long the manager should wait for the next.
+func serve(requests <-chan Control, report func(state State, waitHint time.Duration), signals chan<- os.Signal, done <-chan struct{}) {
+	state := StateRunning
+	report(state, 0)
+
+	var force <-chan time.Time
+	var deadline time.Time // When the current stop window closes
+	for {
+		select {
+		case req := <-requests:
+			switch req {
+			case ControlInterrogate:
+				report(state, time.Until(deadline))
+			case ControlStop, ControlShutdown:
+				window := controlWindows[req]
+				if state == StateRunning {
+					state = StateStopPending
+					deadline = time.Now().Add(window)
+					force = time.After(ForceAfter(window))
+					signals <- os.Interrupt
+				} else if until := time.Now().Add(window); until.Before(deadline) {
+					// A host shutdown during an operator stop leaves less time
+					deadline = until
+					if force != nil {
+						force = time.After(ForceAfter(window))
+					}
+				}
+				report(state, time.Until(deadline))
+			}
+		case <-force:
+			force = nil
+			signals <- os.Interrupt
+		case <-done:
+			report(StateStopped, 0)
+			return
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// launchdDomain is where system daemons live (loaded at boot, run as root unless UserName is set)
+const (
+	launchdDomain = "system"
+	launchdDir    = "/Library/LaunchDaemons"
+)
+
+// Label returns the launchd label of a service name ("aaw-runner" becomes "com.aaw.aaw-runner")
+func Label(name string) string {
+	return "com.aaw." + name
+}
+
+var plistTemplate = template.Must(template.New("plist").Funcs(template.FuncMap{"xml": xmlEscape}).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>{{xml .Label}}</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>{{xml .Executable}}</string>
+{{- range .Args}}
+		<string>{{xml .}}</string>
+{{- end}}
+	</array>
+	<key>EnvironmentVariables</key>
+	<dict>
+		<key>` + EnvManaged + `</key>
+		<string>launchd</string>
+	</dict>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<dict>
+		<key>SuccessfulExit</key>
+		<false/>
+	</dict>
+	<key>ExitTimeOut</key>
+	<integer>{{.ExitTimeOut}}</integer>
+	<key>StandardOutPath</key>
+	<string>{{xml .LogFile}}</string>
+	<key>StandardErrorPath</key>
+	<string>{{xml .LogFile}}</string>
+</dict>
+</plist>
+`))
+
+// xmlEscape escapes s for use as XML character data
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// Plist renders the launchd property list of a service
+// launchd starts the runner at boot, restarts it if it exits with an error, waits StopWindow for it to
+// stop, and appends its output (the runner log) to <LogDir>/<name>.log, since unified logging does not
+// capture a daemon's stderr.
+func Plist(spec Spec) ([]byte, error) {
+	if !filepath.IsAbs(spec.Executable) {
+		return nil, fmt.Errorf("executable %q is not an absolute path", spec.Executable)
+	}
+	var buf bytes.Buffer
+	err := plistTemplate.Execute(&buf, map[string]interface{}{
+		"Label":       Label(spec.Name),
+		"Executable":  spec.Executable,
+		"Args":        spec.Args,
+		"ExitTimeOut": int(StopWindow.Seconds()),
+		"LogFile":     filepath.Join(spec.LogDir, spec.Name+".log"),
+	})
+	return buf.Bytes(), err
+}
+
+// launchd is a Manager driving launchctl
+type launchd struct {
+	dir string                                  // Directory holding the plists
+	run func(name string, args ...string) error // Runs a command (launchctl), faked in tests
+}
+
+// newLaunchd returns the Manager for macOS system daemons
+func newLaunchd() *launchd {
+	return &launchd{dir: launchdDir, run: runCommand}
+}
+
+// runCommand runs a command, returning its output in the error when it fails
+func runCommand(name string, args ...string) error {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s: %v: %s", name, strings.Join(args, " "), err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+func (l *launchd) path(name string) string {
+	return filepath.Join(l.dir, Label(name)+".plist")
+}
+
+// Install writes the plist and loads it, which starts the runner (RunAtLoad)
+func (l *launchd) Install(spec Spec) error {
+	plist, err := Plist(spec)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(spec.LogDir, 0o755); err != nil {
+		return err
+	}
+	if _, err := os.Stat(l.path(spec.Name)); err == nil {
+		return fmt.Errorf("service %s is already installed (%s)", spec.Name, l.path(spec.Name))
+	}
+	if err := os.WriteFile(l.path(spec.Name), plist, 0o644); err != nil {
+		return err
+	}
+	return l.Start(spec.Name)
+}
+
+// Uninstall unloads the service, stopping it, and removes its plist
+func (l *launchd) Uninstall(name string) error {
+	if _, err := os.Stat(l.path(name)); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("service %s is not installed", name)
+	}
+	l.Stop(name) // Fails if already stopped, which is fine
+	return os.Remove(l.path(name))
+}
+
+// Start loads the service, which starts the runner (RunAtLoad)
+func (l *launchd) Start(name string) error {
+	return l.run("launchctl", "bootstrap", launchdDomain, l.path(name))
+}
+
+// Stop unloads the service: launchd sends the runner SIGTERM and SIGKILL after ExitTimeOut
+// Unloading rather than signalling keeps KeepAlive from restarting a runner that exits non-zero
+// because it had to cancel tasks.
+func (l *launchd) Stop(name string) error {
+	return l.run("launchctl", "bootout", launchdDomain+"/"+Label(name))
+}
@@ -0,0 +1,6 @@
+package service
+
+// NewManager returns the host's service manager: launchd
+func NewManager() (Manager, error) {
+	return newLaunchd(), nil
+}
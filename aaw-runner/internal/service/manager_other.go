@@ -0,0 +1,11 @@
+//go:build !darwin && !windows
+
+package service
+
+import "fmt"
+
+// NewManager returns the host's service manager
+// Linux hosts run the runner from a systemd unit (Type=notify) instead.
+func NewManager() (Manager, error) {
+	return nil, fmt.Errorf("%w: use a systemd unit on Linux", ErrUnsupported)
+}
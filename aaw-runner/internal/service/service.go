@@ -0,0 +1,136 @@
+// Package service registers the runner with the host's service manager on non-Linux hosts
+// (the Windows Service Control Manager, launchd on macOS; Linux uses the systemd unit) and adapts
+// the manager's stop requests to the runner's signal-driven shutdown.
+package service
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// DefaultName is the service name (launchd label suffix on macOS) used unless --name is given
+const DefaultName = "aaw-runner"
+
+// EnvManaged is set in the environment of a runner started by launchd, naming the manager
+const EnvManaged = "AAW_SERVICE"
+
+// Stop windows: how long the service manager waits for the runner to exit before killing it, and how
+// long the runner waits for a graceful shutdown before escalating to a forced one inside that window
+const (
+	StopWindow     = 20 * time.Second // SCM stop timeout and launchd's default ExitTimeOut
+	ShutdownWindow = 5 * time.Second  // Windows system shutdown (WaitToKillServiceTimeout)
+)
+
+// forceMargin is left at the end of a stop window for the forced shutdown to kill tasks and report them
+var forceMargin = 3 * time.Second
+
+// Spec describes the service to install
+type Spec struct {
+	Name       string   // Service name, or launchd label suffix
+	Executable string   // Absolute path of the runner binary
+	Args       []string // Runner flags, e.g. --config
+	LogDir     string   // Where the manager writes the runner's output (launchd only; the SCM logs to the Event Log)
+}
+
+// Manager installs and drives a service through the host's service manager
+type Manager interface {
+	Install(spec Spec) error
+	Uninstall(name string) error
+	Start(name string) error
+	Stop(name string) error
+}
+
+// ErrUnsupported is returned by NewManager on hosts without a supported service manager
+var ErrUnsupported = errors.New("service management is not supported on this platform")
+
+// Command implements "aaw-runner service install|uninstall|start|stop [--name NAME] [-- runner flags]"
+// Runner flags after "--" are stored with the installed service and passed to it on every start.
+func Command(args []string, mgr Manager, executable string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("usage: aaw-runner service install|uninstall|start|stop [--name NAME] [-- runner flags]")
+	}
+	action := args[0]
+
+	fs := flag.NewFlagSet("service "+action, flag.ContinueOnError)
+	name := fs.String("name", DefaultName, "service name")
+	logDir := fs.String("log-dir", "/Library/Logs/"+DefaultName, "directory for the runner's output under launchd")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	runnerArgs := fs.Args()
+	if len(runnerArgs) > 0 && action != "install" {
+		return fmt.Errorf("service %s takes no runner flags", action)
+	}
+
+	switch action {
+	case "install":
+		spec := Spec{Name: *name, Executable: executable, Args: runnerArgs, LogDir: *logDir}
+		if err := mgr.Install(spec); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Installed service %s (%s)\n", *name, executable)
+	case "uninstall":
+		if err := mgr.Uninstall(*name); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Uninstalled service %s\n", *name)
+	case "start":
+		if err := mgr.Start(*name); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Started service %s\n", *name)
+	case "stop":
+		if err := mgr.Stop(*name); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Stopped service %s\n", *name)
+	default:
+		return fmt.Errorf("unknown service action %q (want install, uninstall, start or stop)", action)
+	}
+	return nil
+}
+
+// Managed reports whether the runner was started by launchd (see EnvManaged)
+func Managed(getenv func(string) string) bool {
+	return getenv(EnvManaged) != ""
+}
+
+// Escalate forwards signals from in, and once one has arrived adds a second os.Interrupt after forceAfter
+// unless another signal beat it. Service managers send one stop request and then kill the process, so
+// the repeated signal that escalates a graceful shutdown to a forced one has to come from here.
+func Escalate(in <-chan os.Signal, forceAfter time.Duration) <-chan os.Signal {
+	out := make(chan os.Signal, cap(in)+1)
+	go func() {
+		var force <-chan time.Time
+		received := 0
+		for {
+			select {
+			case sig := <-in:
+				received++
+				out <- sig
+				if received == 1 {
+					force = time.After(forceAfter)
+				} else {
+					force = nil // Escalated by a real signal
+				}
+			case <-force:
+				force = nil
+				received++
+				out <- os.Interrupt
+			}
+		}
+	}()
+	return out
+}
+
+// ForceAfter is how long a graceful shutdown may take within a stop window before it must be forced
+func ForceAfter(window time.Duration) time.Duration {
+	if window <= forceMargin {
+		return 0
+	}
+	return window - forceMargin
+}
@@ -0,0 +1,224 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeManager records the calls made through it
+type fakeManager struct {
+	calls []string
+	spec  Spec
+	err   error
+}
+
+func (f *fakeManager) Install(spec Spec) error {
+	f.calls = append(f.calls, "install "+spec.Name)
+	f.spec = spec
+	return f.err
+}
+func (f *fakeManager) Uninstall(name string) error {
+	f.calls = append(f.calls, "uninstall "+name)
+	return f.err
+}
+func (f *fakeManager) Start(name string) error {
+	f.calls = append(f.calls, "start "+name)
+	return f.err
+}
+func (f *fakeManager) Stop(name string) error {
+	f.calls = append(f.calls, "stop "+name)
+	return f.err
+}
+
+// TestCommand verifies the actions, --name and runner flags reach the manager
+func TestCommand(t *testing.T) {
+	m := &fakeManager{}
+	var out bytes.Buffer
+	err := Command([]string{"install", "--name", "aaw-2", "--log-dir", "/var/log/aaw", "--", "--config", "/etc/aaw/runner.yaml"}, m, "/usr/local/bin/aaw-runner", &out)
+	assert.NoError(t, err)
+	assert.Equal(t, Spec{Name: "aaw-2", Executable: "/usr/local/bin/aaw-runner", Args: []string{"--config", "/etc/aaw/runner.yaml"}, LogDir: "/var/log/aaw"}, m.spec)
+	assert.Contains(t, out.String(), "Installed service aaw-2")
+
+	for _, action := range []string{"start", "stop", "uninstall"} {
+		assert.NoError(t, Command([]string{action}, m, "/usr/local/bin/aaw-runner", &out))
+	}
+	assert.Equal(t, []string{"install aaw-2", "start aaw-runner", "stop aaw-runner", "uninstall aaw-runner"}, m.calls)
+
+	assert.Error(t, Command(nil, m, "", &out))
+	assert.Error(t, Command([]string{"restart"}, m, "", &out))
+	assert.Error(t, Command([]string{"start", "--", "--config", "x"}, m, "", &out), "Only install stores runner flags")
+
+	m.err = errors.New("access denied")
+	assert.EqualError(t, Command([]string{"stop"}, m, "", &out), "access denied")
+}
+
+// TestPlist verifies the property list runs the runner with its flags, escapes them, and logs to the log dir
+func TestPlist(t *testing.T) {
+	plist, err := Plist(Spec{Name: "aaw-runner", Executable: "/usr/local/bin/aaw-runner", Args: []string{"--config", "/etc/a&b.yaml"}, LogDir: "/Library/Logs/aaw-runner"})
+	assert.NoError(t, err)
+	s := string(plist)
+	assert.Contains(t, s, "<string>com.aaw.aaw-runner</string>")
+	assert.Contains(t, s, "<string>/usr/local/bin/aaw-runner</string>\n\t\t<string>--config</string>\n\t\t<string>/etc/a&amp;b.yaml</string>")
+	assert.Contains(t, s, "<key>AAW_SERVICE</key>")
+	assert.Contains(t, s, "<integer>20</integer>")
+	assert.Contains(t, s, "<string>/Library/Logs/aaw-runner/aaw-runner.log</string>")
+
+	_, err = Plist(Spec{Name: "aaw-runner", Executable: "aaw-runner"})
+	assert.Error(t, err, "launchd needs an absolute path")
+}
+
+// TestLaunchd verifies install writes the plist and bootstraps it, and uninstall boots it out and removes it
+func TestLaunchd(t *testing.T) {
+	var commands []string
+	dir := t.TempDir()
+	l := &launchd{dir: dir, run: func(name string, args ...string) error {
+		commands = append(commands, name+" "+strings.Join(args, " "))
+		return nil
+	}}
+	plist := filepath.Join(dir, "com.aaw.aaw-runner.plist")
+
+	spec := Spec{Name: "aaw-runner", Executable: "/usr/local/bin/aaw-runner", LogDir: filepath.Join(dir, "logs")}
+	assert.NoError(t, l.Install(spec))
+	assert.FileExists(t, plist)
+	assert.DirExists(t, spec.LogDir)
+	assert.Error(t, l.Install(spec), "Already installed")
+
+	assert.NoError(t, l.Uninstall("aaw-runner"))
+	assert.NoFileExists(t, plist)
+	assert.Error(t, l.Uninstall("aaw-runner"), "Not installed")
+
+	assert.Equal(t, []string{
+		"launchctl bootstrap system " + plist,
+		"launchctl bootout system/com.aaw.aaw-runner",
+	}, commands)
+}
+
+// recorder collects the states serve reports
+type recorder struct {
+	mu     sync.Mutex
+	states []State
+}
+
+func (r *recorder) report(state State, _ time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.states = append(r.states, state)
+}
+
+func (r *recorder) get() []State {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]State(nil), r.states...)
+}
+
+// shortWindows shrinks the stop windows for the duration of a test
+func shortWindows(t *testing.T, stop, shutdown, margin time.Duration) {
+	oldWindows, oldMargin := controlWindows, forceMargin
+	controlWindows = map[Control]time.Duration{ControlStop: stop, ControlShutdown: shutdown}
+	forceMargin = margin
+	t.Cleanup(func() { controlWindows, forceMargin = oldWindows, oldMargin })
+}
+
+// TestServe_StopEscalates verifies a stop is a graceful interrupt, forced before the window closes, then Stopped
+func TestServe_StopEscalates(t *testing.T) {
+	shortWindows(t, 100*time.Millisecond, 50*time.Millisecond, 50*time.Millisecond)
+	requests := make(chan Control)
+	signals := make(chan os.Signal, 3)
+	done := make(chan struct{})
+	r := &recorder{}
+	finished := make(chan struct{})
+	go func() {
+		serve(requests, r.report, signals, done)
+		close(finished)
+	}()
+
+	requests <- ControlStop
+	assert.Equal(t, os.Interrupt, <-signals)
+	requests <- ControlStop // A repeated stop does not signal again
+	select {
+	case sig := <-signals:
+		assert.Equal(t, os.Interrupt, sig, "Forced after the graceful part of the window")
+	case <-time.After(time.Second):
+		t.Fatal("No forced shutdown")
+	}
+	assert.Len(t, signals, 0)
+
+	close(done)
+	<-finished
+	assert.Equal(t, []State{StateRunning, StateStopPending, StateStopPending, StateStopped}, r.get())
+}
+
+// TestServe_ShutdownShortensStop verifies a host shutdown during a stop brings the forced shutdown forward
+func TestServe_ShutdownShortensStop(t *testing.T) {
+	shortWindows(t, time.Hour, 100*time.Millisecond, 50*time.Millisecond)
+	requests := make(chan Control)
+	signals := make(chan os.Signal, 3)
+	done := make(chan struct{})
+	go serve(requests, (&recorder{}).report, signals, done)
+	defer close(done)
+
+	requests <- ControlStop
+	<-signals
+	requests <- ControlShutdown
+	select {
+	case <-signals:
+	case <-time.After(time.Second):
+		t.Fatal("Shutdown did not shorten the stop window")
+	}
+}
+
+// TestServe_StoppedWithoutRequest verifies a runner exiting on its own reports Stopped without being signalled
+func TestServe_StoppedWithoutRequest(t *testing.T) {
+	r := &recorder{}
+	signals := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	close(done)
+	serve(make(chan Control), r.report, signals, done)
+	assert.Equal(t, []State{StateRunning, StateStopped}, r.get())
+	assert.Len(t, signals, 0)
+}
+
+// TestEscalate verifies one signal is followed by a forced one, but a real second signal cancels the timer
+func TestEscalate(t *testing.T) {
+	in := make(chan os.Signal, 2)
+	out := Escalate(in, 20*time.Millisecond)
+	in <- os.Interrupt
+	assert.Equal(t, os.Interrupt, <-out)
+	select {
+	case sig := <-out:
+		assert.Equal(t, os.Interrupt, sig)
+	case <-time.After(time.Second):
+		t.Fatal("No forced shutdown")
+	}
+
+	in2 := make(chan os.Signal, 2)
+	out2 := Escalate(in2, 50*time.Millisecond)
+	in2 <- os.Interrupt
+	in2 <- os.Interrupt
+	<-out2
+	<-out2
+	select {
+	case <-out2:
+		t.Fatal("Escalated twice")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// TestForceAfter verifies the graceful part of a window leaves the margin, and never goes negative
+func TestForceAfter(t *testing.T) {
+	assert.Equal(t, 17*time.Second, ForceAfter(StopWindow))
+	assert.Equal(t, 2*time.Second, ForceAfter(ShutdownWindow))
+	assert.Equal(t, time.Duration(0), ForceAfter(time.Second))
+	assert.True(t, Managed(func(string) string { return "launchd" }))
+	assert.False(t, Managed(func(string) string { return "" }))
+	var s *Session
+	s.Stopped()
+}
@@ -0,0 +1,25 @@
+package service
+
+import (
+	"io"
+	"os"
+)
+
+// Session is the runner's side of a run under a service manager that delivers stop requests as
+// control events (the Windows SCM) rather than signals
+type Session struct {
+	Signals <-chan os.Signal // Stop requests as signals, escalated like a repeated Ctrl-C
+	Log     io.Writer        // The platform's log sink (the Event Log), standing in for the absent console
+
+	done    chan struct{} // Closed by Stopped
+	stopped chan struct{} // Closed once the manager has been told the service stopped
+}
+
+// Stopped tells the service manager the runner has stopped; call it just before exiting
+func (s *Session) Stopped() {
+	if s == nil {
+		return
+	}
+	close(s.done)
+	<-s.stopped
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+
+package service
+
+// Start joins the service manager if the runner was started by one that uses control events
+// Only the Windows SCM does; elsewhere stop requests arrive as signals and Start returns nil.
+func Start(name string) (*Session, error) {
+	return nil, nil
+}
@@ -0,0 +1,209 @@
+//go:build windows
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// eventID is the Event Log id of every runner message (they are written with the generic EventCreate source)
+const eventID = 1
+
+// scm is a Manager driving the Windows Service Control Manager
+type scm struct{}
+
+// NewManager returns the host's service manager: the SCM
+func NewManager() (Manager, error) {
+	return scm{}, nil
+}
+
+// Install registers the runner as an automatically started service that logs to the Event Log
+// The SCM restarts it if it exits with an error.
+func (scm) Install(spec Spec) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	s, err := m.CreateService(spec.Name, spec.Executable, mgr.Config{
+		DisplayName: "AAW Runner",
+		Description: "Runs AAW tasks dispatched by the backend",
+		StartType:   mgr.StartAutomatic,
+	}, spec.Args...)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	s.SetRecoveryActions([]mgr.RecoveryAction{
+		{Type: mgr.ServiceRestart, Delay: 5 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: 30 * time.Second},
+	}, uint32((24 * time.Hour).Seconds()))
+
+	if err := eventlog.InstallAsEventCreate(spec.Name, eventlog.Error|eventlog.Warning|eventlog.Info); err != nil {
+		s.Delete()
+		return fmt.Errorf("registering the Event Log source: %w", err)
+	}
+	return nil
+}
+
+// Uninstall stops the service if it is running and removes it and its Event Log source
+func (w scm) Uninstall(name string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(name)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed", name)
+	}
+	defer s.Close()
+	if status, err := s.Query(); err == nil && status.State != svc.Stopped {
+		if err := w.Stop(name); err != nil {
+			return err
+		}
+	}
+	if err := s.Delete(); err != nil {
+		return err
+	}
+	eventlog.Remove(name)
+	return nil
+}
+
+// Start starts the installed service
+func (scm) Start(name string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(name)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed", name)
+	}
+	defer s.Close()
+	return s.Start()
+}
+
+// Stop asks the service to stop and waits for it, up to the stop window
+func (scm) Stop(name string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(name)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed", name)
+	}
+	defer s.Close()
+	status, err := s.Control(svc.Stop)
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(StopWindow)
+	for status.State != svc.Stopped {
+		if time.Now().After(deadline) {
+			return errors.New("timed out waiting for the service to stop")
+		}
+		time.Sleep(300 * time.Millisecond)
+		if status, err = s.Query(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Start joins the SCM when the runner was started as a Windows service, returning nil otherwise
+func Start(name string) (*Session, error) {
+	isService, err := svc.IsWindowsService()
+	if err != nil || !isService {
+		return nil, err
+	}
+	events, err := eventlog.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("opening the Event Log: %w", err)
+	}
+
+	signals := make(chan os.Signal, 3)
+	s := &Session{
+		Signals: signals,
+		Log:     eventLogWriter{events},
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go func() {
+		defer close(s.stopped)
+		defer events.Close()
+		if err := svc.Run(name, handler{signals: signals, done: s.done}); err != nil {
+			events.Error(eventID, fmt.Sprintf("Service failed: %v", err))
+		}
+	}()
+	return s, nil
+}
+
+// handler adapts SCM change requests to serve
+type handler struct {
+	signals chan<- os.Signal
+	done    <-chan struct{}
+}
+
+// Execute implements svc.Handler
+func (h handler) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	controls := make(chan Control)
+	go func() {
+		for req := range requests {
+			switch req.Cmd {
+			case svc.Interrogate:
+				controls <- ControlInterrogate
+			case svc.Stop:
+				controls <- ControlStop
+			case svc.Shutdown:
+				controls <- ControlShutdown
+			}
+		}
+	}()
+
+	checkpoint := uint32(0)
+	report := func(state State, waitHint time.Duration) {
+		switch state {
+		case StateRunning:
+			status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+		case StateStopPending:
+			checkpoint++
+			status <- svc.Status{State: svc.StopPending, CheckPoint: checkpoint, WaitHint: uint32(waitHint.Milliseconds())}
+		case StateStopped:
+			status <- svc.Status{State: svc.Stopped}
+		}
+	}
+	serve(controls, report, h.signals, h.done)
+	return false, 0
+}
+
+// eventLogWriter writes each log line to the Event Log, as an error if it reports a failure
+type eventLogWriter struct {
+	events *eventlog.Log
+}
+
+func (w eventLogWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimRight(string(p), "\n")
+	var err error
+	if strings.Contains(msg, "Failed") || strings.Contains(msg, "error") {
+		err = w.events.Error(eventID, msg)
+	} else {
+		err = w.events.Info(eventID, msg)
+	}
+	return len(p), err
+}
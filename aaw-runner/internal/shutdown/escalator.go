@@ -0,0 +1,43 @@
+// Package shutdown escalates the runner's shutdown as the operator repeats the signal
+package shutdown
+
+import (
+	"log"
+	"os"
+)
+
+// Escalator runs the shutdown stages, moving to the next one each time another signal arrives:
+// the first signal starts Graceful, a second runs Force alongside it, and a third calls Immediate
+type Escalator struct {
+	Graceful  func() int // Drain and wait out the grace period; returns the exit status
+	Force     func() int // Kill all tasks and send what can be sent quickly; returns the exit status
+	Immediate func()     // Exit right away, skipping cleanup (os.Exit in main)
+}
+
+// Run handles a shutdown whose first signal has already been received and returns the exit status
+// of the graceful stage, or of the forced one once escalated (-1 if Immediate returns). Further
+// signals are read from signals.
+func (e Escalator) Run(signals <-chan os.Signal) int {
+	log.Println("[SHUTDOWN] Shutting down gracefully; press Ctrl-C again to force")
+	graceful := make(chan int, 1)
+	go func() { graceful <- e.Graceful() }()
+
+	select {
+	case code := <-graceful:
+		return code
+	case <-signals:
+	}
+
+	log.Println("[SHUTDOWN] Forcing shutdown: killing running tasks; press Ctrl-C again to exit immediately")
+	forced := make(chan int, 1)
+	go func() { forced <- e.Force() }()
+
+	select {
+	case code := <-forced:
+		return code
+	case <-signals:
+		log.Println("[SHUTDOWN] Exiting immediately")
+		e.Immediate()
+		return -1
+	}
+}
@@ -0,0 +1,100 @@
+package shutdown
+
+import (
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stages records which stages ran and lets the test decide when each one finishes
+// Graceful exits with 3 and Force with 4
+type stages struct {
+	graceful, forced atomic.Bool
+	finishGraceful   chan struct{}
+	finishForce      chan struct{}
+	immediate        chan struct{}
+}
+
+func newStages() *stages {
+	return &stages{finishGraceful: make(chan struct{}), finishForce: make(chan struct{}), immediate: make(chan struct{}, 1)}
+}
+
+func (s *stages) escalator() Escalator {
+	return Escalator{
+		Graceful: func() int {
+			s.graceful.Store(true)
+			<-s.finishGraceful
+			return 3
+		},
+		Force: func() int {
+			s.forced.Store(true)
+			<-s.finishForce
+			return 4
+		},
+		Immediate: func() { s.immediate <- struct{}{} },
+	}
+}
+
+// run starts the escalator in the background and returns its exit status channel
+func run(e Escalator, signals chan os.Signal) chan int {
+	result := make(chan int, 1)
+	go func() { result <- e.Run(signals) }()
+	return result
+}
+
+// TestRun_GracefulCompletes verifies a single signal runs only the graceful stage
+func TestRun_GracefulCompletes(t *testing.T) {
+	s := newStages()
+	signals := make(chan os.Signal, 3)
+	result := run(s.escalator(), signals)
+
+	assert.Eventually(t, s.graceful.Load, time.Second, 5*time.Millisecond)
+	close(s.finishGraceful)
+
+	assert.Equal(t, 3, <-result)
+	assert.False(t, s.forced.Load())
+}
+
+// TestRun_SecondSignalForces verifies a second signal runs the forced stage and returns its status
+func TestRun_SecondSignalForces(t *testing.T) {
+	s := newStages()
+	signals := make(chan os.Signal, 3)
+	result := run(s.escalator(), signals)
+
+	assert.Eventually(t, s.graceful.Load, time.Second, 5*time.Millisecond)
+	signals <- syscall.SIGINT
+	assert.Eventually(t, s.forced.Load, time.Second, 5*time.Millisecond)
+
+	// The graceful stage finishing (its tasks were just killed) does not end a forced shutdown
+	close(s.finishGraceful)
+	select {
+	case code := <-result:
+		t.Fatalf("Run returned %d before the forced stage finished", code)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(s.finishForce)
+	assert.Equal(t, 4, <-result)
+}
+
+// TestRun_ThirdSignalExits verifies a third signal exits without waiting for the forced stage
+func TestRun_ThirdSignalExits(t *testing.T) {
+	s := newStages()
+	signals := make(chan os.Signal, 3)
+	result := run(s.escalator(), signals)
+
+	signals <- syscall.SIGINT
+	assert.Eventually(t, s.forced.Load, time.Second, 5*time.Millisecond)
+	signals <- syscall.SIGTERM
+
+	select {
+	case <-s.immediate:
+	case <-time.After(time.Second):
+		t.Fatal("third signal did not exit")
+	}
+	assert.Equal(t, -1, <-result)
+}
@@ -0,0 +1,203 @@
+// Package statsd pushes runner metrics to a StatsD or DogStatsD agent over UDP
+// Metrics are queued and sent in batches by a background goroutine, so recording one never blocks:
+// when the queue is full the metric is dropped and counted instead.
+package statsd
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Lines are packed into datagrams of at most maxPacketSize bytes (safe for common MTUs); up to
+// queueSize lines wait for the sender before new ones are dropped
+const (
+	maxPacketSize = 1432
+	queueSize     = 4096
+)
+
+// flushInterval is how long a partly filled datagram waits for more lines
+var flushInterval = time.Second
+
+// Client emits metrics in DogStatsD format ("name:value|type|#tag:value,...")
+// A nil *Client is valid and discards everything, so callers need not check whether StatsD is configured.
+type Client struct {
+	conn    net.Conn
+	prefix  string
+	tags    string // Global tags, already formatted and comma-joined
+	queue   chan string
+	done    chan struct{}
+	mu      sync.RWMutex // Guards closed against sends racing Close
+	closed  bool
+	dropped atomic.Int64
+}
+
+// New connects to the agent at addr ("host:port"), prefixing metric names with prefix and adding
+// tags ("key:value,..." in DogStatsD format) to every metric
+// Returns a nil client when addr is empty.
+func New(addr, prefix, tags string) (*Client, error) {
+	if addr == "" {
+		return nil, nil
+	}
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid StatsD address %q: %w", addr, err)
+	}
+
+	c := &Client{
+		conn:   conn,
+		prefix: strings.TrimSuffix(prefix, "."),
+		tags:   strings.Join(splitTags(tags), ","),
+		queue:  make(chan string, queueSize),
+		done:   make(chan struct{}),
+	}
+	go c.run()
+	return c, nil
+}
+
+// Count adds value to a counter
+func (c *Client) Count(name string, value int64, tags ...string) {
+	c.emit(name, strconv.FormatInt(value, 10), "c", tags)
+}
+
+// Incr adds one to a counter
+func (c *Client) Incr(name string, tags ...string) {
+	c.Count(name, 1, tags...)
+}
+
+// Gauge sets a gauge to value
+func (c *Client) Gauge(name string, value float64, tags ...string) {
+	c.emit(name, strconv.FormatFloat(value, 'f', -1, 64), "g", tags)
+}
+
+// Timing records a duration in milliseconds
+func (c *Client) Timing(name string, d time.Duration, tags ...string) {
+	c.emit(name, strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', -1, 64), "ms", tags)
+}
+
+// Dropped returns how many metrics were discarded because the queue was full
+func (c *Client) Dropped() int64 {
+	if c == nil {
+		return 0
+	}
+	return c.dropped.Load()
+}
+
+// Close sends what is queued and closes the connection
+func (c *Client) Close() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+	c.closed = true
+	close(c.queue)
+	c.mu.Unlock()
+
+	<-c.done
+	c.conn.Close()
+}
+
+// emit formats a metric line and queues it without blocking
+func (c *Client) emit(name, value, kind string, tags []string) {
+	if c == nil {
+		return
+	}
+	line := c.format(name, value, kind, tags)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.closed {
+		return
+	}
+	select {
+	case c.queue <- line:
+	default:
+		if c.dropped.Add(1) == 1 {
+			log.Printf("[STATSD] Queue full, dropping metrics")
+		}
+	}
+}
+
+// format builds one DogStatsD line
+func (c *Client) format(name, value, kind string, tags []string) string {
+	var b strings.Builder
+	if c.prefix != "" {
+		b.WriteString(c.prefix)
+		b.WriteByte('.')
+	}
+	b.WriteString(name)
+	b.WriteByte(':')
+	b.WriteString(value)
+	b.WriteByte('|')
+	b.WriteString(kind)
+
+	all := c.tags
+	if len(tags) > 0 {
+		if all != "" {
+			all += ","
+		}
+		all += strings.Join(tags, ",")
+	}
+	if all != "" {
+		b.WriteString("|#")
+		b.WriteString(all)
+	}
+	return b.String()
+}
+
+// run packs queued lines into datagrams until the queue is closed
+func (c *Client) run() {
+	defer close(c.done)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	var packet bytes.Buffer
+	flush := func() {
+		if packet.Len() == 0 {
+			return
+		}
+		// Fire and forget: an absent agent must not disturb the runner
+		c.conn.Write(packet.Bytes())
+		packet.Reset()
+	}
+
+	for {
+		select {
+		case line, ok := <-c.queue:
+			if !ok {
+				flush()
+				return
+			}
+			if packet.Len() > 0 && packet.Len()+1+len(line) > maxPacketSize {
+				flush()
+			}
+			if packet.Len() > 0 {
+				packet.WriteByte('\n')
+			}
+			packet.WriteString(line)
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// splitTags parses "key:value, key2:value2" into trimmed, non-empty tags
+func splitTags(s string) []string {
+	var tags []string
+	for _, tag := range strings.Split(s, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
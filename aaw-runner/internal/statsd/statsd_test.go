@@ -0,0 +1,126 @@
+package statsd
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// listen starts a local UDP listener standing in for the agent
+func listen(t *testing.T) *net.UDPConn {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	assert.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// readLines reads one datagram and splits it into metric lines
+func readLines(t *testing.T, conn *net.UDPConn) []string {
+	t.Helper()
+	buf := make([]byte, 65536)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := conn.Read(buf)
+	if !assert.NoError(t, err) {
+		return nil
+	}
+	return strings.Split(string(buf[:n]), "\n")
+}
+
+// TestClient_EmitsDogStatsDLines verifies every metric type is formatted with prefix and tags and batched
+func TestClient_EmitsDogStatsDLines(t *testing.T) {
+	agent := listen(t)
+	c, err := New(agent.LocalAddr().String(), "aaw.runner.", "env:test, host:ci")
+	assert.NoError(t, err)
+
+	c.Incr("tasks.started")
+	c.Count("tasks.finished", 2, "status:failed")
+	c.Gauge("pool.running", 3)
+	c.Timing("task.duration", 1500*time.Millisecond, "engine:claude")
+	c.Close()
+
+	assert.Equal(t, []string{
+		"aaw.runner.tasks.started:1|c|#env:test,host:ci",
+		"aaw.runner.tasks.finished:2|c|#env:test,host:ci,status:failed",
+		"aaw.runner.pool.running:3|g|#env:test,host:ci",
+		"aaw.runner.task.duration:1500|ms|#env:test,host:ci,engine:claude",
+	}, readLines(t, agent))
+}
+
+// TestClient_NoTags verifies lines without any tags omit the tag section
+func TestClient_NoTags(t *testing.T) {
+	agent := listen(t)
+	c, err := New(agent.LocalAddr().String(), "", "")
+	assert.NoError(t, err)
+
+	c.Gauge("pool.available", 0.5)
+	c.Close()
+
+	assert.Equal(t, []string{"pool.available:0.5|g"}, readLines(t, agent))
+}
+
+// TestClient_FlushesPartialBatch verifies a partly filled datagram is sent after the flush interval
+func TestClient_FlushesPartialBatch(t *testing.T) {
+	orig := flushInterval
+	flushInterval = 20 * time.Millisecond
+	defer func() { flushInterval = orig }()
+
+	agent := listen(t)
+	c, err := New(agent.LocalAddr().String(), "aaw", "")
+	assert.NoError(t, err)
+	defer c.Close()
+
+	c.Incr("ws.connects")
+	assert.Equal(t, []string{"aaw.ws.connects:1|c"}, readLines(t, agent))
+}
+
+// TestClient_SplitsLargeBatches verifies datagrams never exceed the packet size
+func TestClient_SplitsLargeBatches(t *testing.T) {
+	agent := listen(t)
+	c, err := New(agent.LocalAddr().String(), "aaw.runner", "")
+	assert.NoError(t, err)
+
+	for i := 0; i < 200; i++ {
+		c.Incr("tasks.submitted")
+	}
+	c.Close()
+
+	total := 0
+	buf := make([]byte, 65536)
+	for total < 200 {
+		agent.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, err := agent.Read(buf)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.LessOrEqual(t, n, maxPacketSize)
+		total += len(strings.Split(string(buf[:n]), "\n"))
+	}
+	assert.Equal(t, 200, total)
+}
+
+// TestClient_DisabledAndClosed verifies an unconfigured client and a closed one discard metrics
+func TestClient_DisabledAndClosed(t *testing.T) {
+	c, err := New("", "aaw.runner", "")
+	assert.NoError(t, err)
+	assert.Nil(t, c)
+	c.Incr("tasks.started")
+	c.Close()
+	assert.Zero(t, c.Dropped())
+
+	agent := listen(t)
+	c, err = New(agent.LocalAddr().String(), "aaw.runner", "")
+	assert.NoError(t, err)
+	c.Close()
+	c.Incr("tasks.started")
+	c.Close()
+}
+
+// TestNew_RejectsInvalidAddress verifies a malformed address is an error
+func TestNew_RejectsInvalidAddress(t *testing.T) {
+	_, err := New("no-port", "aaw.runner", "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,201 @@
+// Package statusfile keeps a JSON document describing the runner on disk for monitors that can read
+// a file but not speak HTTP. The file is replaced atomically, so readers never see a partial document.
+package statusfile
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/admin"
+)
+
+// States of the runner in the document
+const (
+	StateRunning = "RUNNING"
+	StateStopped = "STOPPED" // Written on clean shutdown; a RUNNING document with an old updatedAt means the runner died
+)
+
+// DefaultInterval is how often the document is rewritten when nothing happens
+const DefaultInterval = 5 * time.Second
+
+// settleDelay batches a burst of notifications into one rewrite, and lets the change being notified
+// (e.g. a completed task leaving the pool) take effect before the document is built
+var settleDelay = 200 * time.Millisecond
+
+// Runner is the part of the runner the document describes
+type Runner interface {
+	admin.Runner
+	LastError() (msg string, at time.Time) // Most recent connection or protocol error ("" if none)
+}
+
+// Document is the content of the status file
+type Document struct {
+	State     string    `json:"state"` // StateRunning or StateStopped
+	UpdatedAt time.Time `json:"updatedAt"`
+	PID       int       `json:"pid"`
+
+	BackendURL string `json:"backendUrl"`
+	admin.Status
+	QueuedTasks int          `json:"queuedTasks"`
+	Tasks       []admin.Task `json:"tasks"` // Running and queued tasks
+
+	LastError   string     `json:"lastError,omitempty"`
+	LastErrorAt *time.Time `json:"lastErrorAt,omitempty"`
+
+	StartedAt     time.Time `json:"startedAt"`
+	UptimeSeconds float64   `json:"uptimeSeconds"`
+}
+
+// Writer rewrites the status file periodically and whenever Notify is called
+// If the file cannot be written (e.g. a read-only filesystem) the failure is logged once and the
+// writer disables itself. A nil *Writer writes nothing.
+type Writer struct {
+	path       string
+	runner     Runner
+	backendURL string
+	startedAt  time.Time
+	now        func() time.Time
+
+	notify  chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+	started bool
+
+	mu       sync.Mutex // Serializes writes
+	disabled bool
+}
+
+// New creates a writer for path describing runner; nothing is written until Start
+func New(path string, runner Runner, backendURL string) *Writer {
+	return &Writer{
+		path:       path,
+		runner:     runner,
+		backendURL: backendURL,
+		startedAt:  time.Now(),
+		now:        time.Now,
+		notify:     make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// Start writes the document now, then every interval and after each Notify, until Close
+func (w *Writer) Start(interval time.Duration) {
+	if w == nil {
+		return
+	}
+	w.started = true
+	w.write(StateRunning)
+	go w.run(interval)
+}
+
+// Notify asks for the document to be rewritten soon (a task started or finished, the connection changed)
+// Never blocks; notifications arriving while a rewrite is pending are merged.
+func (w *Writer) Notify() {
+	if w == nil {
+		return
+	}
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Close stops rewriting and leaves a final document marked StateStopped
+// Must not be called more than once.
+func (w *Writer) Close() {
+	if w == nil || !w.started {
+		return
+	}
+	close(w.stop)
+	<-w.done
+	w.write(StateStopped)
+}
+
+// run rewrites the document until Close
+func (w *Writer) run(interval time.Duration) {
+	defer close(w.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+		case <-w.notify:
+			select {
+			case <-w.stop:
+				return
+			case <-time.After(settleDelay):
+			}
+		}
+		w.write(StateRunning)
+	}
+}
+
+// snapshot builds the document as of now
+func (w *Writer) snapshot(state string) Document {
+	now := w.now()
+	doc := Document{
+		State:         state,
+		UpdatedAt:     now.UTC(),
+		PID:           os.Getpid(),
+		BackendURL:    w.backendURL,
+		Status:        admin.CurrentStatus(w.runner),
+		Tasks:         admin.ListTasks(w.runner, now),
+		StartedAt:     w.startedAt.UTC(),
+		UptimeSeconds: now.Sub(w.startedAt).Round(time.Second).Seconds(),
+	}
+	for _, task := range doc.Tasks {
+		if task.StartedAt == nil {
+			doc.QueuedTasks++
+		}
+	}
+	if msg, at := w.runner.LastError(); msg != "" {
+		at = at.UTC()
+		doc.LastError, doc.LastErrorAt = msg, &at
+	}
+	return doc
+}
+
+// write replaces the file with the current document, disabling the writer on failure
+func (w *Writer) write(state string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.disabled {
+		return
+	}
+	if err := writeAtomic(w.path, w.snapshot(state)); err != nil {
+		w.disabled = true
+		log.Printf("[STATUSFILE] Cannot write status file, no longer updating it: %v", err)
+	}
+}
+
+// writeAtomic writes doc to a temporary file next to path and renames it over path
+func writeAtomic(path string, doc Document) error {
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
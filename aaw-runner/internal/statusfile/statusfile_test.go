@@ -0,0 +1,156 @@
+package statusfile
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/executor"
+	"github.com/berno/aaw-runner/internal/runner"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRunner is a Runner with fixed tasks and an optional last error
+type fakeRunner struct {
+	mu    sync.Mutex
+	tasks []executor.TaskSnapshot
+	err   string
+	errAt time.Time
+}
+
+func (f *fakeRunner) Connected() bool           { return true }
+func (f *fakeRunner) Capacity() (int, int, int) { return 2, 1, 1 }
+func (f *fakeRunner) Draining() bool            { return false }
+func (f *fakeRunner) CancelTask(int64) error    { return nil }
+func (f *fakeRunner) KillTask(int64) error      { return nil }
+func (f *fakeRunner) Drain()                    {}
+func (f *fakeRunner) Undrain() error            { return nil }
+func (f *fakeRunner) DroppedLogLines() int64    { return 0 }
+func (f *fakeRunner) Tasks() []executor.TaskSnapshot {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.tasks
+}
+func (f *fakeRunner) LastError() (string, time.Time) { return f.err, f.errAt }
+
+// read decodes the status file at path into a generic map, so field names are checked as written
+func read(t *testing.T, path string) map[string]interface{} {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	var doc map[string]interface{}
+	assert.NoError(t, json.Unmarshal(data, &doc), "Partial or invalid document: %q", data)
+	return doc
+}
+
+// TestWriter_Schema verifies the document carries connection, capacity, tasks, last error, uptime and version
+func TestWriter_Schema(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	f := &fakeRunner{
+		tasks: []executor.TaskSnapshot{
+			{TaskID: 1, State: runner.TaskStateRunning, SubmittedAt: now.Add(-time.Minute), StartedAt: now.Add(-30 * time.Second)},
+			{TaskID: 2, State: runner.TaskStateQueued, SubmittedAt: now.Add(-time.Second)},
+		},
+		err:   "websocket: close 1006",
+		errAt: now.Add(-time.Hour),
+	}
+	path := filepath.Join(t.TempDir(), "status.json")
+	w := New(path, f, "ws://backend/ws/logs")
+	w.startedAt = now.Add(-90 * time.Minute)
+	w.now = func() time.Time { return now }
+
+	w.Start(time.Hour)
+	doc := read(t, path)
+	w.Close()
+
+	assert.Equal(t, StateRunning, doc["state"])
+	assert.Equal(t, "2026-10-15T12:00:00Z", doc["updatedAt"])
+	assert.Equal(t, float64(os.Getpid()), doc["pid"])
+	assert.Equal(t, "ws://backend/ws/logs", doc["backendUrl"])
+	assert.Equal(t, true, doc["connected"])
+	assert.Equal(t, float64(2), doc["maxParallel"])
+	assert.Equal(t, float64(1), doc["runningTasks"])
+	assert.Equal(t, float64(1), doc["availableSlots"])
+	assert.Equal(t, float64(1), doc["queuedTasks"])
+	assert.Len(t, doc["tasks"], 2)
+	assert.Equal(t, "websocket: close 1006", doc["lastError"])
+	assert.Equal(t, "2026-10-15T11:00:00Z", doc["lastErrorAt"])
+	assert.Equal(t, float64(5400), doc["uptimeSeconds"])
+	assert.Contains(t, doc, "version")
+	assert.Contains(t, doc, "startedAt")
+}
+
+// TestWriter_AtomicRewrites verifies readers never see a partial document while it is rewritten
+func TestWriter_AtomicRewrites(t *testing.T) {
+	f := &fakeRunner{}
+	for i := int64(1); i <= 200; i++ {
+		f.tasks = append(f.tasks, executor.TaskSnapshot{TaskID: i, State: runner.TaskStateQueued, Metadata: map[string]string{"job": "padding the document"}})
+	}
+	path := filepath.Join(t.TempDir(), "status.json")
+	w := New(path, f, "ws://backend")
+	w.Start(time.Hour)
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				w.write(StateRunning)
+			}
+		}
+	}()
+	for i := 0; i < 300; i++ {
+		read(t, path)
+	}
+	close(stop)
+	wg.Wait()
+	w.Close()
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	assert.Len(t, entries, 1, "No temporary files are left behind")
+}
+
+// TestWriter_NotifyAndShutdownMarker verifies Notify triggers a rewrite and Close leaves a STOPPED document
+func TestWriter_NotifyAndShutdownMarker(t *testing.T) {
+	old := settleDelay
+	settleDelay = time.Millisecond
+	defer func() { settleDelay = old }()
+
+	f := &fakeRunner{}
+	path := filepath.Join(t.TempDir(), "status.json")
+	w := New(path, f, "ws://backend")
+	w.Start(time.Hour)
+	assert.Equal(t, float64(0), read(t, path)["queuedTasks"])
+
+	f.mu.Lock()
+	f.tasks = []executor.TaskSnapshot{{TaskID: 9, State: runner.TaskStateQueued}}
+	f.mu.Unlock()
+	w.Notify()
+	assert.Eventually(t, func() bool { return read(t, path)["queuedTasks"] == float64(1) }, time.Second, 5*time.Millisecond)
+
+	w.Close()
+	assert.Equal(t, StateStopped, read(t, path)["state"])
+}
+
+// TestWriter_DisablesOnFailure verifies an unwritable path disables the writer instead of retrying, and nil writers are no-ops
+func TestWriter_DisablesOnFailure(t *testing.T) {
+	w := New(filepath.Join(t.TempDir(), "missing", "status.json"), &fakeRunner{}, "ws://backend")
+	w.Start(time.Millisecond)
+	assert.True(t, w.disabled)
+	w.Notify()
+	w.Close()
+
+	var disabled *Writer
+	disabled.Start(time.Second)
+	disabled.Notify()
+	disabled.Close()
+	New("/nonexistent/status.json", &fakeRunner{}, "").Close() // Never started
+}
@@ -0,0 +1,159 @@
+// Package streamjson parses the events claude prints with --output-format stream-json, one JSON
+// object per line
+package streamjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/berno/aaw-runner/internal/models"
+)
+
+// Tool call phases, as TOOL_EVENT reports them
+const (
+	PhaseUse    = models.ToolPhaseUse    // Claude asked for a tool to run
+	PhaseResult = models.ToolPhaseResult // The tool's output went back to claude
+)
+
+// Event is what one line of stream-json output carries for the runner
+type Event struct {
+	Type      string               // The event's type ("system", "assistant", "user" or "result")
+	Text      []string             // Assistant text blocks, in order
+	Tools     []ToolCall           // Tool uses and results, in order
+	Result    *models.ClaudeResult // The run's final result and token usage (result events only)
+	SessionID string               // Session the event belongs to, when it names one
+}
+
+// ToolCall is a tool use claude asked for, or the result of one
+type ToolCall struct {
+	Phase   string          // PhaseUse or PhaseResult
+	ID      string          // Pairs a result with its use
+	Name    string          // Tool name (uses only)
+	Input   json.RawMessage // Tool input as claude sent it (uses only)
+	Output  string          // Text of the tool's output (results only)
+	IsError bool            // The tool failed (results only)
+}
+
+// event is a stream-json line as claude prints it
+type event struct {
+	Type      string   `json:"type"`
+	SessionID string   `json:"session_id"`
+	Message   *message `json:"message"`
+
+	// Result events
+	Subtype       string  `json:"subtype"`
+	IsError       bool    `json:"is_error"`
+	Result        string  `json:"result"`
+	NumTurns      int     `json:"num_turns"`
+	DurationMs    int64   `json:"duration_ms"`
+	DurationAPIMs int64   `json:"duration_api_ms"`
+	TotalCostUSD  float64 `json:"total_cost_usd"`
+	Usage         *usage  `json:"usage"`
+}
+
+type message struct {
+	Content content `json:"content"`
+}
+
+type usage struct {
+	InputTokens              int64 `json:"input_tokens"`
+	OutputTokens             int64 `json:"output_tokens"`
+	CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
+}
+
+// content is a message's content: a list of blocks, or plain text (a single text block)
+type content []block
+
+type block struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text"`
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Input     json.RawMessage `json:"input"`
+	ToolUseID string          `json:"tool_use_id"`
+	Content   content         `json:"content"`
+	IsError   bool            `json:"is_error"`
+}
+
+// UnmarshalJSON accepts both forms of content
+func (c *content) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*c = content{{Type: "text", Text: text}}
+		return nil
+	}
+	var blocks []block
+	if err := json.Unmarshal(data, &blocks); err != nil {
+		return err
+	}
+	*c = blocks
+	return nil
+}
+
+// text joins the text blocks of a tool result's content
+func (c content) text() string {
+	var parts []string
+	for _, b := range c {
+		if b.Type == "text" {
+			parts = append(parts, b.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// Parse decodes one line of claude's output. ok is false when the line is not a stream-json event the
+// runner knows (not JSON, or of an unknown type), which is then forwarded as it is.
+func Parse(line []byte) (ev Event, ok bool) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 || line[0] != '{' {
+		return Event{}, false
+	}
+	var e event
+	if err := json.Unmarshal(line, &e); err != nil {
+		return Event{}, false
+	}
+	ev = Event{Type: e.Type, SessionID: e.SessionID}
+	switch e.Type {
+	case "system":
+	case "assistant", "user":
+		if e.Message == nil {
+			return Event{}, false
+		}
+		for _, b := range e.Message.Content {
+			switch b.Type {
+			case "text":
+				if e.Type == "assistant" && b.Text != "" {
+					ev.Text = append(ev.Text, b.Text)
+				}
+			case "tool_use":
+				ev.Tools = append(ev.Tools, ToolCall{Phase: PhaseUse, ID: b.ID, Name: b.Name, Input: b.Input})
+			case "tool_result":
+				ev.Tools = append(ev.Tools, ToolCall{Phase: PhaseResult, ID: b.ToolUseID, Output: b.Content.text(), IsError: b.IsError})
+			}
+		}
+	case "result":
+		ev.Result = &models.ClaudeResult{
+			Subtype:       e.Subtype,
+			IsError:       e.IsError,
+			Result:        e.Result,
+			NumTurns:      e.NumTurns,
+			DurationMs:    e.DurationMs,
+			DurationAPIMs: e.DurationAPIMs,
+			TotalCostUSD:  e.TotalCostUSD,
+			SessionID:     e.SessionID,
+		}
+		if u := e.Usage; u != nil {
+			ev.Result.Usage = &models.ClaudeUsage{
+				InputTokens:              u.InputTokens,
+				OutputTokens:             u.OutputTokens,
+				CacheCreationInputTokens: u.CacheCreationInputTokens,
+				CacheReadInputTokens:     u.CacheReadInputTokens,
+			}
+		}
+	default:
+		return Event{}, false
+	}
+	return ev, true
+}
@@ -0,0 +1,81 @@
+package streamjson
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+const sessionID = "8f3c2a1e-5b7d-4c9a-a1e2-3f4b5c6d7e8f"
+
+// sample returns the lines of a captured claude output in testdata
+func sample(t *testing.T, name string) []string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	assert.NoError(t, err)
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+// TestParse_Session verifies each event of a captured claude run decodes to its text, tool calls and result
+func TestParse_Session(t *testing.T) {
+	lines := sample(t, "session.jsonl")
+	tests := []struct {
+		name string
+		line int
+		want Event
+	}{
+		{"system init", 0, Event{Type: "system", SessionID: sessionID}},
+		{"assistant text", 1, Event{Type: "assistant", SessionID: sessionID,
+			Text: []string{"I'll check the failing test first.\nThen fix it."}}},
+		{"tool use", 2, Event{Type: "assistant", SessionID: sessionID, Tools: []ToolCall{{
+			Phase: PhaseUse, ID: "toolu_01A", Name: "Bash",
+			Input: json.RawMessage(`{"command":"go test ./...","description":"Run the tests"}`),
+		}}}},
+		{"tool result as a string", 3, Event{Type: "user", SessionID: sessionID, Tools: []ToolCall{{
+			Phase: PhaseResult, ID: "toolu_01A", Output: "--- FAIL: TestParse (0.00s)\nFAIL", IsError: true,
+		}}}},
+		{"tool result as blocks", 4, Event{Type: "user", SessionID: sessionID, Tools: []ToolCall{{
+			Phase: PhaseResult, ID: "toolu_01B", Output: "package parse\nfunc Parse() {}",
+		}}}},
+		{"result", 5, Event{Type: "result", SessionID: sessionID, Result: &models.ClaudeResult{
+			Subtype: "success", Result: "Fixed the failing test.", NumTurns: 4, DurationMs: 12840, DurationAPIMs: 11020,
+			TotalCostUSD: 0.0421, SessionID: sessionID,
+			Usage: &models.ClaudeUsage{InputTokens: 1830, OutputTokens: 412, CacheCreationInputTokens: 5120, CacheReadInputTokens: 20480},
+		}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Parse([]byte(lines[tt.line]))
+			assert.True(t, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// TestParse_NotAnEvent verifies lines that are not a known stream-json event are left to be forwarded
+// as they are
+func TestParse_NotAnEvent(t *testing.T) {
+	lines := sample(t, "malformed.jsonl")
+	tests := []struct {
+		name string
+		line int
+	}{
+		{"truncated JSON", 0},
+		{"plain text", 1},
+		{"unknown type", 2},
+		{"message missing", 3},
+		{"empty line", 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Parse([]byte(lines[tt.line]))
+			assert.False(t, ok)
+			assert.Zero(t, got)
+		})
+	}
+}
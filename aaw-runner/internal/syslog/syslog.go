@@ -0,0 +1,133 @@
+// Package syslog sends the runner's log to the local syslog daemon (or journald's syslog socket)
+// Writer is an io.Writer like logfile.Writer, so it can replace the console or be combined with a
+// log file. Each line becomes one syslog message whose priority is derived from its content.
+package syslog
+
+import (
+	"bytes"
+	"fmt"
+	gosyslog "log/syslog"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Tag identifies the runner's messages in the system log
+const Tag = "aaw-runner"
+
+// DefaultFacility is used unless --syslog-facility says otherwise
+const DefaultFacility = "daemon"
+
+// facilities maps --syslog-facility names to syslog facilities
+var facilities = map[string]gosyslog.Priority{
+	"kern": gosyslog.LOG_KERN, "user": gosyslog.LOG_USER, "mail": gosyslog.LOG_MAIL,
+	"daemon": gosyslog.LOG_DAEMON, "auth": gosyslog.LOG_AUTH, "syslog": gosyslog.LOG_SYSLOG,
+	"lpr": gosyslog.LOG_LPR, "news": gosyslog.LOG_NEWS, "uucp": gosyslog.LOG_UUCP,
+	"cron": gosyslog.LOG_CRON, "authpriv": gosyslog.LOG_AUTHPRIV, "ftp": gosyslog.LOG_FTP,
+	"local0": gosyslog.LOG_LOCAL0, "local1": gosyslog.LOG_LOCAL1, "local2": gosyslog.LOG_LOCAL2,
+	"local3": gosyslog.LOG_LOCAL3, "local4": gosyslog.LOG_LOCAL4, "local5": gosyslog.LOG_LOCAL5,
+	"local6": gosyslog.LOG_LOCAL6, "local7": gosyslog.LOG_LOCAL7,
+}
+
+var (
+	// timestampPattern matches the date and time the standard logger puts before every line;
+	// syslog stamps messages itself
+	timestampPattern = regexp.MustCompile(`^\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}(\.\d+)? `)
+
+	// taskOutputPattern matches lines that echo task output: LOG messages sent to the backend and
+	// per-line debug stream traces
+	taskOutputPattern = regexp.MustCompile(`^(\[WS\] Sending LOG: |\[DEBUG\] Task \d+ \w+ line \d+)`)
+
+	// errorPattern and warningPattern pick the priority of lines that are not debug traces
+	errorPattern   = regexp.MustCompile(`(?i)\b(error|failed|fatal|panic)\b`)
+	warningPattern = regexp.MustCompile(`(?i)\b(warning|timed out|still alive|still has live|dropping|giving up)\b|^\[KILL\]`)
+)
+
+// Writer writes log lines to syslog
+type Writer struct {
+	w          *gosyslog.Writer
+	taskOutput bool // Forward lines echoing task output (dropped by default)
+}
+
+// Dial connects to the syslog socket at path ("" tries the usual local sockets such as /dev/log)
+// facility is a name accepted by ParseFacility. Lines echoing task output are only forwarded when
+// taskOutput is set: that output already reaches the backend.
+func Dial(path, facility string, taskOutput bool) (*Writer, error) {
+	fac, err := ParseFacility(facility)
+	if err != nil {
+		return nil, err
+	}
+
+	var w *gosyslog.Writer
+	if path == "" {
+		w, err = gosyslog.New(fac|gosyslog.LOG_INFO, Tag)
+	} else {
+		w, err = gosyslog.Dial("unixgram", path, fac|gosyslog.LOG_INFO, Tag)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to syslog: %w", err)
+	}
+	return &Writer{w: w, taskOutput: taskOutput}, nil
+}
+
+// ParseFacility returns the syslog facility called name (e.g. "daemon", "local3")
+func ParseFacility(name string) (gosyslog.Priority, error) {
+	fac, ok := facilities[strings.ToLower(name)]
+	if !ok {
+		names := make([]string, 0, len(facilities))
+		for n := range facilities {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return 0, fmt.Errorf("unknown syslog facility %q (want one of %s)", name, strings.Join(names, ", "))
+	}
+	return fac, nil
+}
+
+// Write sends each line of p as a syslog message
+func (w *Writer) Write(p []byte) (int, error) {
+	for _, line := range bytes.Split(bytes.TrimRight(p, "\n"), []byte("\n")) {
+		msg := timestampPattern.ReplaceAllString(string(line), "")
+		if msg == "" || (!w.taskOutput && taskOutputPattern.MatchString(msg)) {
+			continue
+		}
+		if err := w.send(msg); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// send writes one message at the priority its content suggests
+func (w *Writer) send(msg string) error {
+	switch Severity(msg) {
+	case gosyslog.LOG_DEBUG:
+		return w.w.Debug(msg)
+	case gosyslog.LOG_ERR:
+		return w.w.Err(msg)
+	case gosyslog.LOG_WARNING:
+		return w.w.Warning(msg)
+	default:
+		return w.w.Info(msg)
+	}
+}
+
+// Close closes the connection to syslog
+func (w *Writer) Close() error {
+	return w.w.Close()
+}
+
+// Severity derives a syslog severity from a log line: debug traces are LOG_DEBUG, failures
+// LOG_ERR, warnings and kills LOG_WARNING and everything else LOG_INFO
+func Severity(msg string) gosyslog.Priority {
+	switch {
+	case strings.HasPrefix(msg, "[DEBUG]"):
+		return gosyslog.LOG_DEBUG
+	case errorPattern.MatchString(msg):
+		return gosyslog.LOG_ERR
+	case warningPattern.MatchString(msg):
+		return gosyslog.LOG_WARNING
+	default:
+		return gosyslog.LOG_INFO
+	}
+}
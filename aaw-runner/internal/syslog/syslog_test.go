@@ -0,0 +1,120 @@
+package syslog
+
+import (
+	"fmt"
+	gosyslog "log/syslog"
+	"net"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// message is a datagram received by the fake syslog daemon
+type message struct {
+	priority gosyslog.Priority
+	text     string
+}
+
+// messagePattern parses the local syslog format: "<PRI>Mmm dd hh:mm:ss TAG[PID]: MSG"
+var messagePattern = regexp.MustCompile(`^<(\d+)>\w{3} [ \d]\d \d{2}:\d{2}:\d{2} ` + Tag + `\[\d+\]: (.*)\n?$`)
+
+// fakeSyslog listens on a unixgram socket like /dev/log
+func fakeSyslog(t *testing.T) (string, *net.UnixConn) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "log.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	assert.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return path, conn
+}
+
+// receive reads the next message from the fake daemon
+func receive(t *testing.T, conn *net.UnixConn) message {
+	t.Helper()
+	buf := make([]byte, 8192)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := conn.Read(buf)
+	if !assert.NoError(t, err) {
+		return message{}
+	}
+	m := messagePattern.FindStringSubmatch(string(buf[:n]))
+	if !assert.NotNil(t, m, "unexpected datagram %q", buf[:n]) {
+		return message{}
+	}
+	pri, _ := strconv.Atoi(m[1])
+	return message{priority: gosyslog.Priority(pri), text: m[2]}
+}
+
+// TestWriter_PrioritiesAndTimestamps verifies each line is sent with its facility and severity, without the log timestamp
+func TestWriter_PrioritiesAndTimestamps(t *testing.T) {
+	path, conn := fakeSyslog(t)
+	w, err := Dial(path, "local3", false)
+	assert.NoError(t, err)
+	defer w.Close()
+
+	_, err = fmt.Fprint(w, "2026/10/15 05:31:26 [POOL] Worker 0 started task 1\n"+
+		"2026/10/15 05:31:27 Failed to send log message: broken pipe\n")
+	assert.NoError(t, err)
+	fmt.Fprintln(w, "[KILL] Task 1 force-killed")
+	fmt.Fprintln(w, "[DEBUG] Finished stdout stream for task 1 (read 3 lines)")
+
+	assert.Equal(t, message{gosyslog.LOG_LOCAL3 | gosyslog.LOG_INFO, "[POOL] Worker 0 started task 1"}, receive(t, conn))
+	assert.Equal(t, message{gosyslog.LOG_LOCAL3 | gosyslog.LOG_ERR, "Failed to send log message: broken pipe"}, receive(t, conn))
+	assert.Equal(t, message{gosyslog.LOG_LOCAL3 | gosyslog.LOG_WARNING, "[KILL] Task 1 force-killed"}, receive(t, conn))
+	assert.Equal(t, message{gosyslog.LOG_LOCAL3 | gosyslog.LOG_DEBUG, "[DEBUG] Finished stdout stream for task 1 (read 3 lines)"}, receive(t, conn))
+}
+
+// TestWriter_TaskOutputExcludedByDefault verifies lines echoing task output only go to syslog when asked for
+func TestWriter_TaskOutputExcludedByDefault(t *testing.T) {
+	output := []string{
+		"[WS] Sending LOG: task=3, line=secret build output",
+		"[DEBUG] Task 3 stdout line 1: secret build output",
+	}
+
+	path, conn := fakeSyslog(t)
+	w, err := Dial(path, DefaultFacility, false)
+	assert.NoError(t, err)
+	for _, line := range output {
+		fmt.Fprintln(w, line)
+	}
+	fmt.Fprintln(w, "[POOL] Worker 0 completed task 3")
+	w.Close()
+	assert.Equal(t, "[POOL] Worker 0 completed task 3", receive(t, conn).text, "Task output should have been skipped")
+
+	w, err = Dial(path, DefaultFacility, true)
+	assert.NoError(t, err)
+	defer w.Close()
+	for _, line := range output {
+		fmt.Fprintln(w, line)
+		assert.Equal(t, line, receive(t, conn).text)
+	}
+}
+
+// TestDial_Errors verifies unknown facilities and missing sockets are reported
+func TestDial_Errors(t *testing.T) {
+	_, err := Dial(filepath.Join(t.TempDir(), "missing.sock"), DefaultFacility, false)
+	assert.Error(t, err)
+
+	_, err = Dial("", "local9", false)
+	assert.ErrorContains(t, err, "unknown syslog facility")
+}
+
+// TestSeverity verifies priorities derived from log lines
+func TestSeverity(t *testing.T) {
+	cases := map[string]gosyslog.Priority{
+		"[POOL] Executor pool started with 5 workers":                   gosyslog.LOG_INFO,
+		"WebSocket error: unexpected EOF":                               gosyslog.LOG_ERR,
+		"[CONFIG] Warning: unknown key \"foo\" in runner.yaml":          gosyslog.LOG_WARNING,
+		"[WEBHOOK] Giving up on task 4 after 3 attempt(s)":              gosyslog.LOG_WARNING,
+		"[DEBUG] Starting stdout stream for task 2":                     gosyslog.LOG_DEBUG,
+		"Task 7 rejected: Runner at capacity":                           gosyslog.LOG_INFO,
+		"[KILL] Task 5 process group 100 still has live processes: [1]": gosyslog.LOG_WARNING,
+	}
+	for line, want := range cases {
+		assert.Equal(t, want, Severity(line), line)
+	}
+}
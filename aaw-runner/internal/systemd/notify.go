@@ -0,0 +1,138 @@
+// Package systemd implements the sd_notify protocol for Type=notify units
+// Everything no-ops when the runner is not started by systemd (NOTIFY_SOCKET unset)
+package systemd
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/health"
+)
+
+// Notifier sends state updates to the systemd notify socket
+type Notifier struct {
+	addr     *net.UnixAddr // nil when not under systemd
+	watchdog time.Duration // WatchdogSec, zero when the watchdog is disabled
+}
+
+// NewNotifier reads NOTIFY_SOCKET, WATCHDOG_USEC and WATCHDOG_PID through getenv
+func NewNotifier(getenv func(string) string) *Notifier {
+	n := &Notifier{}
+
+	socket := getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return n
+	}
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:] // Abstract namespace socket
+	}
+	n.addr = &net.UnixAddr{Name: socket, Net: "unixgram"}
+
+	if usec, err := strconv.ParseInt(getenv("WATCHDOG_USEC"), 10, 64); err == nil && usec > 0 {
+		// WATCHDOG_PID, when set, names the process the watchdog applies to
+		if pid := getenv("WATCHDOG_PID"); pid == "" || pid == strconv.Itoa(os.Getpid()) {
+			n.watchdog = time.Duration(usec) * time.Microsecond
+		}
+	}
+	return n
+}
+
+// Enabled reports whether the runner is running under a Type=notify unit
+func (n *Notifier) Enabled() bool {
+	return n.addr != nil
+}
+
+// WatchdogInterval returns WatchdogSec, or zero when the watchdog is disabled
+func (n *Notifier) WatchdogInterval() time.Duration {
+	return n.watchdog
+}
+
+// Notify sends newline-separated assignments such as "READY=1" or "STATUS=..."
+func (n *Notifier) Notify(state ...string) error {
+	if n.addr == nil {
+		return nil
+	}
+	conn, err := net.DialUnix("unixgram", nil, n.addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to notify socket: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(strings.Join(state, "\n"))); err != nil {
+		return fmt.Errorf("failed to notify systemd: %w", err)
+	}
+	return nil
+}
+
+// Ready reports that startup finished
+func (n *Notifier) Ready(status string) {
+	n.send("READY=1", "STATUS="+status)
+}
+
+// Stopping reports that graceful shutdown has begun
+func (n *Notifier) Stopping() {
+	n.send("STOPPING=1", "STATUS=Shutting down")
+}
+
+// send notifies systemd, logging failures instead of returning them
+func (n *Notifier) send(state ...string) {
+	if err := n.Notify(state...); err != nil {
+		log.Printf("[SYSTEMD] %v", err)
+	}
+}
+
+// Probe exposes the runner state reported to systemd
+type Probe interface {
+	Ping()             // Returns once the runner can make progress
+	Connected() bool   // WebSocket connected to the backend
+	RunningTasks() int // Tasks currently executing
+}
+
+// Status describes the runner for systemctl status
+func Status(p Probe) string {
+	state := "Disconnected from backend"
+	if p.Connected() {
+		state = "Connected to backend"
+	}
+	return fmt.Sprintf("%s, %d task(s) running", state, p.RunningTasks())
+}
+
+// statusInterval is how often STATUS is refreshed when the watchdog is disabled
+var statusInterval = 15 * time.Second
+
+// Run keeps systemd informed until stop is closed: STATUS is refreshed periodically and, when the
+// watchdog is enabled, WATCHDOG=1 is sent at half WatchdogSec. A ping is withheld when the runner
+// does not respond, so a deadlocked runner gets restarted by systemd
+func (n *Notifier) Run(p Probe, stop <-chan struct{}) {
+	if n.addr == nil {
+		return
+	}
+	interval := statusInterval
+	if n.watchdog > 0 {
+		interval = n.watchdog / 2
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if n.watchdog <= 0 {
+				n.send("STATUS=" + Status(p))
+				continue
+			}
+			if !health.PingWithin(p, interval) {
+				log.Printf("[SYSTEMD] Runner unresponsive, withholding watchdog ping")
+				continue
+			}
+			n.send("WATCHDOG=1", "STATUS="+Status(p))
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package systemd
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSocket listens on a unixgram socket like systemd's notify socket
+func fakeSocket(t *testing.T) (string, *net.UnixConn) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	assert.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return path, conn
+}
+
+// receive reads one datagram from the fake socket
+func receive(t *testing.T, conn *net.UnixConn) string {
+	t.Helper()
+	buf := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := conn.Read(buf)
+	assert.NoError(t, err)
+	return string(buf[:n])
+}
+
+// envOf returns a getenv function backed by a map
+func envOf(env map[string]string) func(string) string {
+	return func(key string) string { return env[key] }
+}
+
+// fakeProbe is a Probe with fixed answers
+type fakeProbe struct {
+	connected bool
+	running   int
+	block     chan struct{}
+}
+
+func (p *fakeProbe) Ping() {
+	if p.block != nil {
+		<-p.block
+	}
+}
+func (p *fakeProbe) Connected() bool   { return p.connected }
+func (p *fakeProbe) RunningTasks() int { return p.running }
+
+// TestNotifier_NoopWithoutSystemd verifies nothing is sent and nothing fails outside systemd
+func TestNotifier_NoopWithoutSystemd(t *testing.T) {
+	n := NewNotifier(envOf(nil))
+
+	assert.False(t, n.Enabled())
+	assert.Zero(t, n.WatchdogInterval())
+	assert.NoError(t, n.Notify("READY=1"))
+	n.Ready("ok")
+	n.Stopping()
+
+	stop := make(chan struct{})
+	close(stop)
+	n.Run(&fakeProbe{}, stop) // Returns immediately
+}
+
+// TestNotifier_ReadyAndStopping verifies the READY and STOPPING datagrams
+func TestNotifier_ReadyAndStopping(t *testing.T) {
+	path, conn := fakeSocket(t)
+	n := NewNotifier(envOf(map[string]string{"NOTIFY_SOCKET": path}))
+
+	n.Ready(Status(&fakeProbe{connected: true, running: 2}))
+	assert.Equal(t, "READY=1\nSTATUS=Connected to backend, 2 task(s) running", receive(t, conn))
+
+	n.Stopping()
+	assert.Equal(t, "STOPPING=1\nSTATUS=Shutting down", receive(t, conn))
+}
+
+// TestNotifier_WatchdogInterval verifies WATCHDOG_USEC and WATCHDOG_PID handling
+func TestNotifier_WatchdogInterval(t *testing.T) {
+	path, _ := fakeSocket(t)
+
+	n := NewNotifier(envOf(map[string]string{"NOTIFY_SOCKET": path, "WATCHDOG_USEC": "4000000"}))
+	assert.Equal(t, 4*time.Second, n.WatchdogInterval())
+
+	n = NewNotifier(envOf(map[string]string{"NOTIFY_SOCKET": path, "WATCHDOG_USEC": "4000000",
+		"WATCHDOG_PID": strconv.Itoa(os.Getpid())}))
+	assert.Equal(t, 4*time.Second, n.WatchdogInterval())
+
+	n = NewNotifier(envOf(map[string]string{"NOTIFY_SOCKET": path, "WATCHDOG_USEC": "4000000", "WATCHDOG_PID": "1"}))
+	assert.Zero(t, n.WatchdogInterval(), "Watchdog meant for another process")
+}
+
+// TestNotifier_WatchdogPings verifies pings at half the interval, withheld while the runner is stuck
+func TestNotifier_WatchdogPings(t *testing.T) {
+	path, conn := fakeSocket(t)
+	n := NewNotifier(envOf(map[string]string{"NOTIFY_SOCKET": path, "WATCHDOG_USEC": "100000"}))
+
+	probe := &fakeProbe{running: 1}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		n.Run(probe, stop)
+		close(done)
+	}()
+
+	assert.Equal(t, "WATCHDOG=1\nSTATUS=Disconnected from backend, 1 task(s) running", receive(t, conn))
+
+	close(stop)
+	<-done
+}
+
+// TestNotifier_WatchdogWithheld verifies no ping is sent while Ping blocks
+func TestNotifier_WatchdogWithheld(t *testing.T) {
+	path, conn := fakeSocket(t)
+	n := NewNotifier(envOf(map[string]string{"NOTIFY_SOCKET": path, "WATCHDOG_USEC": "40000"}))
+
+	probe := &fakeProbe{block: make(chan struct{})}
+	stop := make(chan struct{})
+	go n.Run(probe, stop)
+	defer close(stop)
+	defer close(probe.block)
+
+	buf := make([]byte, 64)
+	conn.SetReadDeadline(time.Now().Add(150 * time.Millisecond))
+	_, err := conn.Read(buf)
+	assert.Error(t, err, "No watchdog ping while the runner is unresponsive")
+}
@@ -0,0 +1,65 @@
+package tasklog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Store is a Store backed by an S3-compatible bucket
+type S3Store struct {
+	client *minio.Client
+	base   string // Endpoint URL of the bucket, without a trailing slash
+	bucket string
+}
+
+// NewS3Store connects to bucket at endpoint ("https://s3.amazonaws.com", "http://minio:9000" or a bare host for HTTPS)
+// Credentials come from AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY (or MINIO_ROOT_USER/MINIO_ROOT_PASSWORD),
+// falling back to the instance or task role.
+func NewS3Store(endpoint, bucket string) (*S3Store, error) {
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", endpoint)
+	}
+
+	creds := credentials.NewChainCredentials([]credentials.Provider{
+		&credentials.EnvAWS{},
+		&credentials.EnvMinio{},
+		&credentials.IAM{},
+	})
+	client, err := minio.New(u.Host, &minio.Options{Creds: creds, Secure: u.Scheme == "https"})
+	if err != nil {
+		return nil, err
+	}
+	return &S3Store{client: client, base: u.Scheme + "://" + u.Host, bucket: bucket}, nil
+}
+
+// Put implements Store
+func (s *S3Store) Put(ctx context.Context, key string, body io.Reader, size int64, contentType, contentEncoding string) error {
+	_, err := s.client.PutObject(ctx, s.bucket, key, body, size, minio.PutObjectOptions{
+		ContentType:     contentType,
+		ContentEncoding: contentEncoding,
+	})
+	if err == nil {
+		return nil
+	}
+	// Client errors other than throttling will fail the same way next time
+	if status := minio.ToErrorResponse(err).StatusCode; status >= 400 && status < 500 && status != http.StatusTooManyRequests && status != http.StatusRequestTimeout {
+		return fmt.Errorf("%w: %v", ErrPermanent, err)
+	}
+	return err
+}
+
+// URL implements Store, in path style: endpoint/bucket/key
+func (s *S3Store) URL(key string) string {
+	return s.base + "/" + s.bucket + "/" + (&url.URL{Path: key}).EscapedPath()
+}
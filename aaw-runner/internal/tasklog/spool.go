@@ -0,0 +1,137 @@
+// Package tasklog keeps each task's output in a local file and ships finished files to S3-compatible storage
+package tasklog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// DirName is the spool's directory inside the state dir
+const DirName = "task-logs"
+
+// Spool writes each running task's output lines to its own file
+// A nil *Spool writes nothing, so callers need not check whether task logs are enabled.
+type Spool struct {
+	dir string
+
+	mu     sync.Mutex
+	files  map[int64]*os.File
+	failed map[int64]bool // Tasks whose file could not be written; their output is not kept
+}
+
+// NewSpool creates the spool directory (if missing) and returns a spool writing into it
+func NewSpool(dir string) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &Spool{dir: dir, files: make(map[int64]*os.File), failed: make(map[int64]bool)}, nil
+}
+
+// Path returns the local file of a task
+func (s *Spool) Path(taskID int64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%d.log", taskID))
+}
+
+// Append writes one output line of a task, creating its file on the first line
+func (s *Spool) Append(taskID int64, line string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failed[taskID] {
+		return
+	}
+	file, ok := s.files[taskID]
+	if !ok {
+		var err error
+		file, err = os.OpenFile(s.Path(taskID), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+		if err != nil {
+			s.fail(taskID, err)
+			return
+		}
+		s.files[taskID] = file
+	}
+	if _, err := file.WriteString(line + "\n"); err != nil {
+		s.fail(taskID, err)
+	}
+}
+
+// Finish closes a task's file and returns its path ("" if the task wrote no output or its file failed)
+func (s *Spool) Finish(taskID int64) string {
+	if s == nil {
+		return ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failed[taskID] {
+		delete(s.failed, taskID)
+		return ""
+	}
+	file, ok := s.files[taskID]
+	if !ok {
+		return ""
+	}
+	delete(s.files, taskID)
+	if err := file.Close(); err != nil {
+		log.Printf("[TASKLOG] Failed to close log of task %d: %v", taskID, err)
+		return ""
+	}
+	return file.Name()
+}
+
+// Keeps reports whether a running task's output is being kept, so it can be read back with Lines
+func (s *Spool) Keeps(taskID int64) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.failed[taskID]
+}
+
+// Lines calls fn with lines from to end-1 of a task's file, stopping early if fn returns false
+// The file may still be growing; lines at end and beyond are not read.
+func (s *Spool) Lines(taskID, from, end int64, fn func(index int64, line string) bool) error {
+	if from >= end {
+		return nil
+	}
+	file, err := os.Open(s.Path(taskID))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+	for index := int64(0); index < end; index++ {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				return fmt.Errorf("log of task %d ends at line %d", taskID, index)
+			}
+			return err
+		}
+		if index >= from && !fn(index, line[:len(line)-1]) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// fail stops keeping a task's output after a write error (the disk may be full); called with mu held
+func (s *Spool) fail(taskID int64, err error) {
+	log.Printf("[TASKLOG] Not keeping output of task %d: %v", taskID, err)
+	s.failed[taskID] = true
+	if file, ok := s.files[taskID]; ok {
+		file.Close()
+		os.Remove(file.Name())
+		delete(s.files, taskID)
+	}
+}
@@ -0,0 +1,234 @@
+package tasklog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeStore is a Store that keeps objects in memory and fails the first failures puts
+type fakeStore struct {
+	mu       sync.Mutex
+	objects  map[string][]byte
+	encoding map[string]string
+	attempts int
+	failures int
+	err      error
+}
+
+func (f *fakeStore) Put(ctx context.Context, key string, body io.Reader, size int64, contentType, contentEncoding string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.attempts++
+	if f.attempts <= f.failures {
+		return f.err
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) != size {
+		return errors.New("size mismatch")
+	}
+	if f.objects == nil {
+		f.objects, f.encoding = make(map[string][]byte), make(map[string]string)
+	}
+	f.objects[key] = data
+	f.encoding[key] = contentEncoding
+	return nil
+}
+
+func (f *fakeStore) URL(key string) string { return "https://s3.test/logs/" + key }
+
+func (f *fakeStore) object(key string) ([]byte, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, ok := f.objects[key]
+	return data, ok
+}
+
+// fastRetries shortens the retry backoff for the duration of a test
+func fastRetries(t *testing.T) {
+	old := retryBackoff
+	retryBackoff = time.Millisecond
+	t.Cleanup(func() { retryBackoff = old })
+}
+
+// writeLog spools a task's output and returns its finished file
+func writeLog(t *testing.T, taskID int64, lines ...string) string {
+	t.Helper()
+	spool, err := NewSpool(filepath.Join(t.TempDir(), DirName))
+	assert.NoError(t, err)
+	for _, line := range lines {
+		spool.Append(taskID, line)
+	}
+	return spool.Finish(taskID)
+}
+
+var finishedAt = time.Date(2026, 10, 15, 23, 30, 0, 0, time.FixedZone("KST", 9*3600))
+
+// TestSpool_WritesPerTaskFiles verifies each task's lines go to its own file, closed by Finish
+func TestSpool_WritesPerTaskFiles(t *testing.T) {
+	spool, err := NewSpool(filepath.Join(t.TempDir(), DirName))
+	assert.NoError(t, err)
+
+	spool.Append(1, "one")
+	spool.Append(2, "two")
+	spool.Append(1, "uno")
+
+	path := spool.Finish(1)
+	assert.Equal(t, spool.Path(1), path)
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "one\nuno\n", string(data))
+	assert.Empty(t, spool.Finish(1), "Already finished")
+	assert.Empty(t, spool.Finish(3), "No output")
+	assert.NotEmpty(t, spool.Finish(2))
+
+	var disabled *Spool
+	disabled.Append(1, "ignored")
+	assert.Empty(t, disabled.Finish(1))
+}
+
+// TestUploader_UploadsAndRemoves verifies a finished log is stored under its dated key and deleted locally
+func TestUploader_UploadsAndRemoves(t *testing.T) {
+	store := &fakeStore{}
+	u := NewUploader(store, Options{Prefix: "/runners/ci-1/"})
+	path := writeLog(t, 42, "hello", "world")
+
+	url := u.Enqueue(42, path, finishedAt)
+	assert.Equal(t, "https://s3.test/logs/runners/ci-1/2026-10-15/42.log", url, "Dated in UTC")
+	assert.True(t, u.Close(5*time.Second))
+
+	data, ok := store.object("runners/ci-1/2026-10-15/42.log")
+	assert.True(t, ok)
+	assert.Equal(t, "hello\nworld\n", string(data))
+	_, err := os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "Uploaded log should be removed")
+}
+
+// TestUploader_Gzip verifies compressed uploads get a .gz key and a gzip content encoding
+func TestUploader_Gzip(t *testing.T) {
+	store := &fakeStore{}
+	u := NewUploader(store, Options{Gzip: true})
+	path := writeLog(t, 7, "compress me")
+
+	assert.Equal(t, "https://s3.test/logs/2026-10-15/7.log.gz", u.Enqueue(7, path, finishedAt))
+	assert.True(t, u.Close(5*time.Second))
+
+	data, ok := store.object("2026-10-15/7.log.gz")
+	if assert.True(t, ok) {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		assert.NoError(t, err)
+		plain, _ := io.ReadAll(zr)
+		assert.Equal(t, "compress me\n", string(plain))
+		assert.Equal(t, "gzip", store.encoding["2026-10-15/7.log.gz"])
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	assert.Empty(t, entries, "Neither the log nor its compressed copy should be left behind")
+}
+
+// TestUploader_RetriesTransientFailures verifies failed uploads are retried until they succeed
+func TestUploader_RetriesTransientFailures(t *testing.T) {
+	fastRetries(t)
+	store := &fakeStore{failures: 2, err: errors.New("connection reset")}
+	u := NewUploader(store, Options{})
+
+	u.Enqueue(5, writeLog(t, 5, "x"), finishedAt)
+	assert.True(t, u.Close(5*time.Second))
+	assert.Equal(t, 3, store.attempts)
+	_, ok := store.object("2026-10-15/5.log")
+	assert.True(t, ok)
+}
+
+// TestUploader_KeepsFileOnFailure verifies permanent and exhausted failures leave the local file in place
+func TestUploader_KeepsFileOnFailure(t *testing.T) {
+	fastRetries(t)
+
+	store := &fakeStore{failures: 100, err: errors.Join(ErrPermanent, errors.New("AccessDenied"))}
+	u := NewUploader(store, Options{})
+	path := writeLog(t, 8, "x")
+	u.Enqueue(8, path, finishedAt)
+	assert.True(t, u.Close(5*time.Second))
+	assert.Equal(t, 1, store.attempts, "Permanent failures are not retried")
+	assert.FileExists(t, path)
+
+	store = &fakeStore{failures: 100, err: errors.New("503 Slow Down")}
+	u = NewUploader(store, Options{})
+	path = writeLog(t, 9, "x")
+	u.Enqueue(9, path, finishedAt)
+	assert.True(t, u.Close(5*time.Second))
+	assert.Equal(t, maxAttempts, store.attempts)
+	assert.FileExists(t, path)
+}
+
+// TestUploader_NeverBlocks verifies Enqueue returns at once even when uploads are stuck, and nil/empty inputs are ignored
+func TestUploader_NeverBlocks(t *testing.T) {
+	u := &Uploader{store: &fakeStore{}, queue: make(chan job, 1)} // No worker drains the queue
+	assert.NotEmpty(t, u.Enqueue(1, "/tmp/1.log", finishedAt))
+	assert.Empty(t, u.Enqueue(2, "/tmp/2.log", finishedAt), "Full queue")
+	assert.Empty(t, u.Enqueue(3, "", finishedAt), "No output")
+
+	var disabled *Uploader
+	assert.Empty(t, disabled.Enqueue(1, "/tmp/1.log", finishedAt))
+	assert.True(t, disabled.Close(time.Second))
+}
+
+// TestS3Store_Put verifies objects are PUT to the bucket with their headers and permanent errors are flagged
+func TestS3Store_Put(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIAEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	var mu sync.Mutex
+	var gotPath, gotEncoding, gotAuth string
+	var gotBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.URL.Query()["location"]; ok {
+			io.WriteString(w, `<LocationConstraint xmlns="http://s3.amazonaws.com/doc/2006-03-01/">us-east-1</LocationConstraint>`)
+			return
+		}
+		if r.URL.Path == "/denied/x.log" {
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, `<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		gotPath, gotEncoding, gotAuth = r.URL.Path, r.Header.Get("Content-Encoding"), r.Header.Get("Authorization")
+		gotBody, _ = io.ReadAll(r.Body)
+		w.Header().Set("ETag", `"abc"`)
+	}))
+	defer server.Close()
+
+	store, err := NewS3Store(server.URL, "logs")
+	assert.NoError(t, err)
+	assert.Equal(t, server.URL+"/logs/ci/2026-10-15/1.log", store.URL("ci/2026-10-15/1.log"))
+
+	body := []byte("line\n")
+	assert.NoError(t, store.Put(context.Background(), "ci/2026-10-15/1.log", bytes.NewReader(body), int64(len(body)), "text/plain", "gzip"))
+	mu.Lock()
+	assert.Equal(t, "/logs/ci/2026-10-15/1.log", gotPath)
+	assert.Equal(t, "gzip", gotEncoding)
+	assert.Contains(t, gotAuth, "Credential=AKIAEXAMPLE/")
+	assert.Contains(t, string(gotBody), "line\n", "Over plain HTTP the body is sent in signed chunks")
+	mu.Unlock()
+
+	denied, err := NewS3Store(server.URL, "denied")
+	assert.NoError(t, err)
+	err = denied.Put(context.Background(), "x.log", bytes.NewReader(body), int64(len(body)), "text/plain", "")
+	assert.ErrorIs(t, err, ErrPermanent)
+
+	_, err = NewS3Store("ftp://example.com", "logs")
+	assert.Error(t, err)
+}
@@ -0,0 +1,211 @@
+package tasklog
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrPermanent marks a Store error that retrying cannot fix (access denied, missing bucket)
+var ErrPermanent = errors.New("permanent upload failure")
+
+// Store puts objects into a bucket
+type Store interface {
+	// Put uploads size bytes of body under key
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType, contentEncoding string) error
+	// URL returns where the object stored under key can be found
+	URL(key string) string
+}
+
+// Options configure an Uploader
+type Options struct {
+	Prefix string // Key prefix ("" uploads to the bucket root)
+	Gzip   bool   // Compress files before uploading (keys get a .gz suffix)
+}
+
+const (
+	queueSize     = 1024
+	maxAttempts   = 5
+	uploadTimeout = 5 * time.Minute // Per attempt
+)
+
+// retryBackoff is the wait before the second attempt, doubled for each further one (a var for tests)
+var retryBackoff = 2 * time.Second
+
+// job is a finished task's file waiting to be uploaded
+type job struct {
+	taskID int64
+	path   string
+	key    string
+}
+
+// Uploader ships finished task logs to a Store from a background goroutine
+// Uploading never delays the caller: files are queued, retried with backoff, and deleted once stored.
+// Files that cannot be stored stay on disk. A nil *Uploader uploads nothing.
+type Uploader struct {
+	store Store
+	opts  Options
+	queue chan job
+	wg    sync.WaitGroup
+
+	mu     sync.RWMutex // Guards closed against concurrent sends on queue
+	closed bool
+}
+
+// NewUploader starts an uploader putting files into store
+func NewUploader(store Store, opts Options) *Uploader {
+	u := &Uploader{store: store, opts: opts, queue: make(chan job, queueSize)}
+	u.wg.Add(1)
+	go u.run()
+	return u
+}
+
+// Key returns the object key of a task's log: prefix/<date>/<taskID>.log[.gz]
+func (u *Uploader) Key(taskID int64, finishedAt time.Time) string {
+	key := fmt.Sprintf("%s/%d.log", finishedAt.UTC().Format("2006-01-02"), taskID)
+	if u.opts.Gzip {
+		key += ".gz"
+	}
+	if prefix := strings.Trim(u.opts.Prefix, "/"); prefix != "" {
+		key = prefix + "/" + key
+	}
+	return key
+}
+
+// Enqueue schedules the upload of a task's finished log file and returns the URL it will be stored at
+// Returns "" if nothing will be uploaded (no file, or the queue is full).
+func (u *Uploader) Enqueue(taskID int64, path string, finishedAt time.Time) string {
+	if u == nil || path == "" {
+		return ""
+	}
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	if u.closed {
+		return ""
+	}
+
+	key := u.Key(taskID, finishedAt)
+	select {
+	case u.queue <- job{taskID: taskID, path: path, key: key}:
+		return u.store.URL(key)
+	default:
+		log.Printf("[TASKLOG] Upload queue full; log of task %d stays at %s", taskID, path)
+		return ""
+	}
+}
+
+// Close stops accepting files and waits up to timeout for queued uploads
+// Reports whether every queued upload finished; unfinished files stay on disk.
+func (u *Uploader) Close(timeout time.Duration) bool {
+	if u == nil {
+		return true
+	}
+	u.mu.Lock()
+	if !u.closed {
+		u.closed = true
+		close(u.queue)
+	}
+	u.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		u.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// run uploads queued files one at a time until Close
+func (u *Uploader) run() {
+	defer u.wg.Done()
+	for j := range u.queue {
+		u.upload(j)
+	}
+}
+
+// upload stores one file, retrying transient failures, and removes it once stored
+func (u *Uploader) upload(j job) {
+	backoff := retryBackoff
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if err = u.put(j); err == nil {
+			os.Remove(j.path)
+			log.Printf("[TASKLOG] Uploaded log of task %d to %s", j.taskID, u.store.URL(j.key))
+			return
+		}
+		if errors.Is(err, ErrPermanent) || errors.Is(err, os.ErrNotExist) || attempt == maxAttempts {
+			break
+		}
+		log.Printf("[TASKLOG] Upload of task %d log failed (attempt %d/%d), retrying in %s: %v", j.taskID, attempt, maxAttempts, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	log.Printf("[TASKLOG] Giving up on uploading the log of task %d; it stays at %s: %v", j.taskID, j.path, err)
+}
+
+// put makes one upload attempt
+func (u *Uploader) put(j job) error {
+	ctx, cancel := context.WithTimeout(context.Background(), uploadTimeout)
+	defer cancel()
+
+	path, encoding := j.path, ""
+	if u.opts.Gzip {
+		gzPath, err := compress(j.path)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(gzPath)
+		path, encoding = gzPath, "gzip"
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	return u.store.Put(ctx, j.key, file, info.Size(), "text/plain; charset=utf-8", encoding)
+}
+
+// compress writes a gzip copy of path next to it and returns the copy's path
+func compress(path string) (string, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	gzPath := path + ".gz"
+	out, err := os.OpenFile(gzPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return "", err
+	}
+	zw := gzip.NewWriter(out)
+	_, err = io.Copy(zw, in)
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(gzPath)
+		return "", err
+	}
+	return gzPath, nil
+}
@@ -0,0 +1,77 @@
+// Package tracing exports spans of the task lifecycle over OTLP/HTTP when an endpoint is configured
+// Without an endpoint nothing is installed: the global tracer provider stays OpenTelemetry's no-op,
+// so instrumented code costs next to nothing.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"github.com/berno/aaw-runner/internal/version"
+)
+
+// TracerName identifies the runner's instrumentation
+const TracerName = "github.com/berno/aaw-runner"
+
+// tracesPath is appended to an endpoint given without a path, as with OTEL_EXPORTER_OTLP_ENDPOINT
+const tracesPath = "/v1/traces"
+
+// propagator reads the W3C trace context ("traceparent" and "tracestate")
+var propagator = propagation.TraceContext{}
+
+// Setup installs a global tracer provider exporting to the OTLP/HTTP collector at endpoint
+// (e.g. "http://otel-collector:4318"). With an empty endpoint it installs nothing. The returned
+// function flushes pending spans and shuts the exporter down.
+func Setup(endpoint string) (func(context.Context) error, error) {
+	if endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := newExporter(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, _ := os.Hostname()
+	res := resource.NewSchemaless(
+		attribute.String("service.name", "aaw-runner"),
+		attribute.String("service.version", version.Version),
+		attribute.String("host.name", hostname),
+	)
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagator)
+	return provider.Shutdown, nil
+}
+
+// newExporter creates the OTLP/HTTP exporter for endpoint
+func newExporter(endpoint string) (*otlptrace.Exporter, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q: want an http:// or https:// URL", endpoint)
+	}
+	if strings.TrimSuffix(u.Path, "/") == "" {
+		u.Path = tracesPath
+	}
+	return otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(u.String()))
+}
+
+// ContextFromMetadata returns a context carrying the remote trace context found in a task's
+// EXECUTE metadata ("traceparent", optionally "tracestate"), or a context without one
+func ContextFromMetadata(metadata map[string]string) context.Context {
+	if metadata["traceparent"] == "" {
+		return context.Background()
+	}
+	return propagator.Extract(context.Background(), propagation.MapCarrier(metadata))
+}
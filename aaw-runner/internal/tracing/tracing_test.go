@@ -0,0 +1,52 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TestSetup_NoEndpointIsNoop verifies tracing stays disabled without an endpoint
+func TestSetup_NoEndpointIsNoop(t *testing.T) {
+	shutdown, err := Setup("")
+
+	assert.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+}
+
+// TestSetup_RejectsInvalidEndpoint verifies endpoints must be http(s) URLs
+func TestSetup_RejectsInvalidEndpoint(t *testing.T) {
+	for _, endpoint := range []string{"collector:4318", "grpc://collector:4317", "http://"} {
+		_, err := Setup(endpoint)
+		assert.Error(t, err, endpoint)
+	}
+}
+
+// TestNewExporter_AcceptsCollectorURL verifies endpoints with and without a path are accepted
+func TestNewExporter_AcceptsCollectorURL(t *testing.T) {
+	for _, endpoint := range []string{"http://collector:4318", "https://collector:4318/custom/traces"} {
+		exporter, err := newExporter(endpoint)
+		if assert.NoError(t, err, endpoint) {
+			assert.NoError(t, exporter.Shutdown(context.Background()))
+		}
+	}
+}
+
+// TestContextFromMetadata verifies the traceparent of EXECUTE metadata becomes the remote parent
+func TestContextFromMetadata(t *testing.T) {
+	ctx := ContextFromMetadata(map[string]string{
+		"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"tracestate":  "vendor=value",
+	})
+	parent := trace.SpanContextFromContext(ctx)
+	assert.True(t, parent.IsRemote())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", parent.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", parent.SpanID().String())
+	assert.Equal(t, "vendor=value", parent.TraceState().String())
+
+	for _, metadata := range []map[string]string{nil, {"traceparent": "garbage"}} {
+		assert.False(t, trace.SpanContextFromContext(ContextFromMetadata(metadata)).IsValid())
+	}
+}
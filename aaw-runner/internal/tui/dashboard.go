@@ -0,0 +1,257 @@
+// Package tui renders a live status dashboard of the runner in the terminal (--tui)
+// It reads the runner only through Source, the same introspection the admin API uses, and does
+// nothing unless started. Without a terminal it prints a plain status line periodically instead.
+package tui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/executor"
+	"github.com/berno/aaw-runner/internal/websocket"
+)
+
+// Refresh intervals of the dashboard and of the plain fallback
+var (
+	refreshInterval = 500 * time.Millisecond
+	plainInterval   = 10 * time.Second
+)
+
+// Source is the runner state the dashboard shows and the one action it takes
+type Source interface {
+	Connected() bool
+	Draining() bool
+	Capacity() (maxParallel, running, available int)
+	Tasks() []executor.TaskSnapshot
+	TaskOutput(taskID int64) []string
+	RecentEvents() []websocket.Event
+	TruncationStats() map[string]int64
+	CancelTask(taskID int64) error // Same path as CANCEL_TASK, acks included
+}
+
+// Dashboard draws the runner's state until stopped
+type Dashboard struct {
+	src         Source
+	backendURL  string
+	logTarget   string
+	out         io.Writer
+	outFd       int
+	interactive bool
+	now         func() time.Time
+
+	mu       sync.Mutex
+	selected int64 // Task ID under the cursor
+	follow   bool  // Show the selected task's output
+	notice   string
+	stopped  bool // Set by Stop; nothing is drawn afterwards
+
+	restore  func() // Puts the terminal back (interactive only)
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// Start shows the dashboard for src on out, reading keys from in
+// The full-screen dashboard needs both to be terminals and TERM not "dumb"; otherwise a plain
+// status line is printed every plainInterval. logTarget says where the log goes meanwhile.
+func Start(src Source, backendURL, logTarget string, in, out *os.File, getenv func(string) string) *Dashboard {
+	d := &Dashboard{
+		src:        src,
+		backendURL: backendURL,
+		logTarget:  logTarget,
+		out:        out,
+		outFd:      int(out.Fd()),
+		now:        time.Now,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+
+	if getenv("TERM") != "dumb" && isTerminal(int(in.Fd())) && isTerminal(int(out.Fd())) {
+		if restore, err := cbreak(int(in.Fd())); err == nil {
+			d.interactive = true
+			d.restore = restore
+			fmt.Fprint(out, "\x1b[?1049h\x1b[?25l") // Alternate screen, hidden cursor
+			go d.readKeys(in)
+			go d.loop(refreshInterval, d.draw)
+			return d
+		}
+	}
+
+	go d.loop(plainInterval, func() { fmt.Fprintln(d.out, statusLine(d.snapshot())) })
+	return d
+}
+
+// Interactive reports whether the dashboard owns the terminal (the log should then go elsewhere)
+func (d *Dashboard) Interactive() bool {
+	return d.interactive
+}
+
+// Stop ends the dashboard and gives the terminal back
+func (d *Dashboard) Stop() {
+	d.stopOnce.Do(func() {
+		close(d.stop)
+		<-d.done
+		d.mu.Lock()
+		d.stopped = true
+		d.mu.Unlock()
+		if d.interactive {
+			fmt.Fprint(d.out, "\x1b[?25h\x1b[?1049l")
+			d.restore()
+		}
+	})
+}
+
+// loop runs paint immediately and then every interval until Stop
+func (d *Dashboard) loop(interval time.Duration, paint func()) {
+	defer close(d.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		paint()
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// draw repaints the whole screen
+func (d *Dashboard) draw() {
+	width, height, err := size(d.outFd)
+	if err != nil || width <= 0 || height <= 0 {
+		width, height = 80, 24
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock() // Keeps a key's redraw and the ticker's from interleaving
+	if d.stopped {
+		return
+	}
+
+	fmt.Fprint(d.out, "\x1b[H")
+	for _, line := range render(d.snapshotLocked(), width, height) {
+		fmt.Fprint(d.out, line, "\x1b[K\r\n")
+	}
+	fmt.Fprint(d.out, "\x1b[J")
+}
+
+// snapshot reads the current state from the source
+func (d *Dashboard) snapshot() frame {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.snapshotLocked()
+}
+
+// snapshotLocked reads the current state; d.mu must be held
+func (d *Dashboard) snapshotLocked() frame {
+	f := frame{
+		BackendURL: d.backendURL,
+		LogTarget:  d.logTarget,
+		Connected:  d.src.Connected(),
+		Draining:   d.src.Draining(),
+		Tasks:      d.src.Tasks(),
+		LastLines:  make(map[int64]string),
+		Events:     d.src.RecentEvents(),
+		Notice:     d.notice,
+		Now:        d.now(),
+	}
+	f.MaxParallel, f.Running, f.Available = d.src.Capacity()
+	for _, n := range d.src.TruncationStats() {
+		f.Truncated += n
+	}
+
+	for _, task := range f.Tasks {
+		if output := d.src.TaskOutput(task.TaskID); len(output) > 0 {
+			f.LastLines[task.TaskID] = output[len(output)-1]
+		}
+	}
+
+	d.selected = selectValid(f.Tasks, d.selected)
+	f.Selected = d.selected
+	if d.follow && d.selected != 0 {
+		f.Follow = d.src.TaskOutput(d.selected)
+		if f.Follow == nil {
+			f.Follow = []string{}
+		}
+	}
+	return f
+}
+
+// selectValid keeps the selection on a listed task, moving it to the first one when its task is gone
+func selectValid(tasks []executor.TaskSnapshot, selected int64) int64 {
+	for _, task := range tasks {
+		if task.TaskID == selected {
+			return selected
+		}
+	}
+	if len(tasks) > 0 {
+		return tasks[0].TaskID
+	}
+	return 0
+}
+
+// readKeys handles key presses until stdin closes
+func (d *Dashboard) readKeys(in io.Reader) {
+	buf := make([]byte, 16)
+	for {
+		n, err := in.Read(buf)
+		if err != nil {
+			return
+		}
+		d.handleKey(buf[:n])
+		d.draw()
+	}
+}
+
+// handleKey applies one key press (arrow keys arrive as escape sequences)
+func (d *Dashboard) handleKey(key []byte) {
+	tasks := d.src.Tasks()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.selected = selectValid(tasks, d.selected)
+
+	switch string(key) {
+	case "k", "\x1b[A":
+		d.moveSelection(tasks, -1)
+	case "j", "\x1b[B":
+		d.moveSelection(tasks, 1)
+	case "f":
+		d.follow = !d.follow
+		d.notice = ""
+	case "c":
+		if d.selected == 0 {
+			d.notice = "No task selected"
+			return
+		}
+		// CancelTask waits for the task to exit, so it must not hold up the screen
+		taskID := d.selected
+		d.notice = fmt.Sprintf("Cancelling task %d...", taskID)
+		go d.cancel(taskID)
+	}
+}
+
+// cancel cancels a task and reports the outcome in the footer
+func (d *Dashboard) cancel(taskID int64) {
+	notice := fmt.Sprintf("Cancelled task %d", taskID)
+	if err := d.src.CancelTask(taskID); err != nil {
+		notice = fmt.Sprintf("Cancel task %d failed: %v", taskID, err)
+	}
+	d.mu.Lock()
+	d.notice = notice
+	d.mu.Unlock()
+}
+
+// moveSelection moves the cursor by delta rows, stopping at either end; d.mu must be held
+func (d *Dashboard) moveSelection(tasks []executor.TaskSnapshot, delta int) {
+	for i, task := range tasks {
+		if task.TaskID == d.selected {
+			j := min(max(i+delta, 0), len(tasks)-1)
+			d.selected = tasks[j].TaskID
+			return
+		}
+	}
+}
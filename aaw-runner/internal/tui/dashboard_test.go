@@ -0,0 +1,151 @@
+package tui
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/executor"
+	"github.com/berno/aaw-runner/internal/runner"
+	"github.com/berno/aaw-runner/internal/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSource is a Source with fixed state that records cancellations
+type fakeSource struct {
+	tasks     []executor.TaskSnapshot
+	output    map[int64][]string
+	cancelErr error
+
+	mu        sync.Mutex
+	cancelled []int64
+}
+
+func (s *fakeSource) Connected() bool                                 { return true }
+func (s *fakeSource) Draining() bool                                  { return false }
+func (s *fakeSource) Capacity() (maxParallel, running, available int) { return 4, 1, 3 }
+func (s *fakeSource) Tasks() []executor.TaskSnapshot                  { return s.tasks }
+func (s *fakeSource) TaskOutput(taskID int64) []string                { return s.output[taskID] }
+func (s *fakeSource) TruncationStats() map[string]int64               { return map[string]int64{"line": 2} }
+func (s *fakeSource) RecentEvents() []websocket.Event {
+	return []websocket.Event{{At: time.Date(2026, 1, 2, 15, 4, 5, 0, time.Local), Text: "Task 7 started"}}
+}
+
+func (s *fakeSource) CancelTask(taskID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cancelled = append(s.cancelled, taskID)
+	return s.cancelErr
+}
+
+func (s *fakeSource) getCancelled() []int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]int64(nil), s.cancelled...)
+}
+
+// newFakeSource returns a source with a running task 7 and a queued task 8
+func newFakeSource() *fakeSource {
+	now := time.Date(2026, 1, 2, 15, 5, 0, 0, time.Local)
+	return &fakeSource{
+		tasks: []executor.TaskSnapshot{
+			{TaskID: 7, Engine: executor.EngineClaude, State: runner.TaskStateRunning, SubmittedAt: now.Add(-2 * time.Minute), StartedAt: now.Add(-65 * time.Second)},
+			{TaskID: 8, Engine: executor.EngineScript, State: runner.TaskStateQueued, SubmittedAt: now.Add(-3 * time.Second)},
+		},
+		output: map[int64][]string{7: {"step 1", "step 2\x1b[31m"}},
+	}
+}
+
+// newTestDashboard creates a dashboard over src without starting it
+func newTestDashboard(src Source) *Dashboard {
+	return &Dashboard{src: src, backendURL: "ws://backend/ws", logTarget: "/var/log/aaw.log",
+		now: func() time.Time { return time.Date(2026, 1, 2, 15, 5, 0, 0, time.Local) }}
+}
+
+// TestRender_ShowsRunnerState verifies the frame shows connection, capacity, tasks and events
+func TestRender_ShowsRunnerState(t *testing.T) {
+	d := newTestDashboard(newFakeSource())
+
+	screen := strings.Join(render(d.snapshot(), 120, 30), "\n")
+
+	assert.Contains(t, screen, "ws://backend/ws  [CONNECTED]")
+	assert.Contains(t, screen, "[#####...............] 1/4 running, 3 free  Queue: 1  Truncated fields: 2  Log: /var/log/aaw.log")
+	assert.Contains(t, screen, "> 7        claude  RUNNING    1m5s      step 2 [31m", "Control characters are blanked")
+	assert.Contains(t, screen, "  8        script  QUEUED     3s")
+	assert.Contains(t, screen, "15:04:05 Task 7 started")
+	assert.NotContains(t, screen, "Output of task")
+}
+
+// TestRender_FitsTheScreen verifies lines are clipped to the width and the footer survives a short screen
+func TestRender_FitsTheScreen(t *testing.T) {
+	d := newTestDashboard(newFakeSource())
+
+	lines := render(d.snapshot(), 30, 5)
+
+	assert.Len(t, lines, 5)
+	for _, line := range lines {
+		assert.LessOrEqual(t, len([]rune(line)), 30)
+	}
+	assert.True(t, strings.HasPrefix(lines[4], "↑/↓ select"))
+}
+
+// TestHandleKey_SelectsAndFollows verifies the cursor moves between tasks and f shows the selected task's output
+func TestHandleKey_SelectsAndFollows(t *testing.T) {
+	d := newTestDashboard(newFakeSource())
+
+	d.handleKey([]byte("\x1b[B"))
+	assert.Equal(t, int64(8), d.snapshot().Selected)
+	d.handleKey([]byte("j"))
+	assert.Equal(t, int64(8), d.snapshot().Selected, "The cursor stops at the last task")
+	d.handleKey([]byte("k"))
+
+	d.handleKey([]byte("f"))
+	f := d.snapshot()
+	assert.Equal(t, []string{"step 1", "step 2\x1b[31m"}, f.Follow)
+	assert.Contains(t, strings.Join(render(f, 120, 30), "\n"), "Output of task 7 (f to stop following):\n  step 1\n  step 2")
+
+	d.handleKey([]byte("f"))
+	assert.Nil(t, d.snapshot().Follow)
+}
+
+// TestHandleKey_CancelsSelectedTask verifies c cancels the task under the cursor and reports the outcome
+func TestHandleKey_CancelsSelectedTask(t *testing.T) {
+	src := newFakeSource()
+	d := newTestDashboard(src)
+
+	d.handleKey([]byte("c"))
+	assert.Eventually(t, func() bool { return d.snapshot().Notice == "Cancelled task 7" }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []int64{7}, src.getCancelled())
+
+	src.cancelErr = errors.New("task 7 is not running")
+	d.handleKey([]byte("c"))
+	assert.Eventually(t, func() bool { return d.snapshot().Notice == "Cancel task 7 failed: task 7 is not running" },
+		time.Second, 5*time.Millisecond)
+}
+
+// TestStatusLine verifies the plain fallback summarizes the runner on one line
+func TestStatusLine(t *testing.T) {
+	d := newTestDashboard(newFakeSource())
+
+	assert.Equal(t, "[STATUS] connected running=1/4 queued=1 tasks=[7:RUNNING 8:QUEUED]", statusLine(d.snapshot()))
+}
+
+// TestStart_PlainWithoutTerminal verifies the dashboard falls back to status lines when output is not a terminal
+func TestStart_PlainWithoutTerminal(t *testing.T) {
+	r, w, err := os.Pipe()
+	assert.NoError(t, err)
+	defer r.Close()
+
+	d := Start(newFakeSource(), "ws://backend/ws", "stderr", r, w, func(string) string { return "xterm" })
+	line, err := bufio.NewReader(r).ReadString('\n')
+	d.Stop()
+	w.Close()
+
+	assert.NoError(t, err)
+	assert.False(t, d.Interactive())
+	assert.Equal(t, "[STATUS] connected running=1/4 queued=1 tasks=[7:RUNNING 8:QUEUED]\n", line)
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package tui
+
+import "errors"
+
+// errNoTerminal makes the dashboard fall back to plain status lines where terminal control is not implemented
+var errNoTerminal = errors.New("terminal control not supported on this platform")
+
+func isTerminal(fd int) bool { return false }
+
+func cbreak(fd int) (func(), error) { return nil, errNoTerminal }
+
+func size(fd int) (cols, rows int, err error) { return 0, 0, errNoTerminal }
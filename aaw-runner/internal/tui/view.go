@@ -0,0 +1,171 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/executor"
+	"github.com/berno/aaw-runner/internal/runner"
+	"github.com/berno/aaw-runner/internal/version"
+	"github.com/berno/aaw-runner/internal/websocket"
+)
+
+// Frame sections that only get the rows left over
+const (
+	maxEventRows  = 8
+	maxFollowRows = 12
+	capacityWidth = 20 // Cells in the capacity bar
+)
+
+// frame is everything one screen shows, read from the Source in one go
+type frame struct {
+	BackendURL  string
+	LogTarget   string // Where the log goes while the dashboard owns the terminal
+	Connected   bool
+	Draining    bool
+	MaxParallel int
+	Running     int
+	Available   int
+	Truncated   int64
+	Tasks       []executor.TaskSnapshot
+	LastLines   map[int64]string
+	Events      []websocket.Event
+	Selected    int64    // Task ID under the cursor (0 for none)
+	Follow      []string // Output of the selected task while following (nil when off)
+	Notice      string   // Result of the last key action
+	Now         time.Time
+}
+
+// queued counts the tasks waiting for a worker
+func (f frame) queued() int {
+	n := 0
+	for _, task := range f.Tasks {
+		if task.State == runner.TaskStateQueued {
+			n++
+		}
+	}
+	return n
+}
+
+// render lays the frame out as at most height lines of at most width characters
+func render(f frame, width, height int) []string {
+	var lines []string
+	add := func(format string, args ...interface{}) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}
+
+	conn := "DISCONNECTED"
+	if f.Connected {
+		conn = "CONNECTED"
+	}
+	if f.Draining {
+		conn += ", DRAINING"
+	}
+	add("AAW Runner %s  %s  [%s]", version.Version, f.BackendURL, conn)
+	add("Capacity %s %d/%d running, %d free  Queue: %d  Truncated fields: %d  Log: %s",
+		bar(f.Running, f.MaxParallel, capacityWidth), f.Running, f.MaxParallel, f.Available, f.queued(), f.Truncated, f.LogTarget)
+	add("")
+
+	add("  %-8s %-7s %-10s %-9s %s", "ID", "ENGINE", "STATE", "ELAPSED", "LAST LINE")
+	if len(f.Tasks) == 0 {
+		add("  (no tasks)")
+	}
+	for _, task := range f.Tasks {
+		cursor := " "
+		if task.TaskID == f.Selected {
+			cursor = ">"
+		}
+		since := task.SubmittedAt
+		if !task.StartedAt.IsZero() {
+			since = task.StartedAt
+		}
+		elapsed := f.Now.Sub(since).Round(time.Second)
+		add("%s %-8d %-7s %-10s %-9s %s", cursor, task.TaskID, task.Engine, task.State, elapsed, f.LastLines[task.TaskID])
+	}
+
+	footer := "↑/↓ select  c cancel  f follow output  Ctrl-C shut down (again to force)"
+	if f.Notice != "" {
+		footer = f.Notice + "  |  " + footer
+	}
+
+	// Following and events share what is left above the footer
+	room := height - len(lines) - 2
+	if f.Follow != nil && room > 2 {
+		follow := tail(f.Follow, min(maxFollowRows, room-2))
+		add("")
+		add("Output of task %d (f to stop following):", f.Selected)
+		for _, line := range follow {
+			add("  %s", line)
+		}
+		room -= len(follow) + 2
+	}
+	if room > 2 {
+		events := tail(f.Events, min(maxEventRows, room-2))
+		add("")
+		add("Recent events:")
+		for _, event := range events {
+			add("  %s %s", event.At.Format("15:04:05"), event.Text)
+		}
+	}
+
+	if len(lines) > height-1 {
+		lines = lines[:max(height-1, 0)]
+	}
+	lines = append(lines, footer)
+	for i, line := range lines {
+		lines[i] = clip(line, width)
+	}
+	return lines
+}
+
+// bar draws used/total as a bar of width cells
+func bar(used, total, width int) string {
+	filled := 0
+	if total > 0 {
+		filled = min(used*width/total, width)
+	}
+	return "[" + strings.Repeat("#", filled) + strings.Repeat(".", width-filled) + "]"
+}
+
+// tail returns the last n elements of items
+func tail[T any](items []T, n int) []T {
+	if n <= 0 {
+		return nil
+	}
+	if len(items) > n {
+		return items[len(items)-n:]
+	}
+	return items
+}
+
+// clip cuts line to width characters, replacing control characters that would break the layout
+func clip(line string, width int) string {
+	runes := []rune(strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f {
+			return ' '
+		}
+		return r
+	}, line))
+	if width > 0 && len(runes) > width {
+		runes = runes[:width]
+	}
+	return string(runes)
+}
+
+// statusLine summarizes the frame in one line for the plain (non-terminal) fallback
+func statusLine(f frame) string {
+	var tasks []string
+	for _, task := range f.Tasks {
+		tasks = append(tasks, fmt.Sprintf("%d:%s", task.TaskID, task.State))
+	}
+	conn := "disconnected"
+	if f.Connected {
+		conn = "connected"
+	}
+	if f.Draining {
+		conn += ",draining"
+	}
+	return fmt.Sprintf("[STATUS] %s running=%d/%d queued=%d tasks=[%s]",
+		conn, f.Running, f.MaxParallel, f.queued(), strings.Join(tasks, " "))
+}
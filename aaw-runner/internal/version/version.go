@@ -0,0 +1,20 @@
+// Package version holds the runner's build information, stamped at link time:
+//
+//	go build -ldflags "-X github.com/berno/aaw-runner/internal/version.Version=1.4.0 \
+//	  -X github.com/berno/aaw-runner/internal/version.Commit=$(git rev-parse --short HEAD) \
+//	  -X github.com/berno/aaw-runner/internal/version.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+package version
+
+import "fmt"
+
+// Build information; the fallbacks apply to plain "go build" / "go run"
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
+
+// String formats the build information for logs and --version
+func String() string {
+	return fmt.Sprintf("aaw-runner %s (commit %s, built %s)", Version, Commit, BuildDate)
+}
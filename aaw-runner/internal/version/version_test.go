@@ -0,0 +1,15 @@
+package version
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestFallbacks verifies unstamped builds report "dev" and "unknown"
+func TestFallbacks(t *testing.T) {
+	assert.Equal(t, "dev", Version)
+	assert.Equal(t, "unknown", Commit)
+	assert.Equal(t, "unknown", BuildDate)
+	assert.Equal(t, "aaw-runner dev (commit unknown, built unknown)", String())
+}
@@ -0,0 +1,169 @@
+// Package webhook notifies host-local tooling of finished tasks with an HTTP POST
+// Deliveries run in the background and are best effort: a failing endpoint is logged and counted
+// but never delays or alters what the backend is told about the task.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/version"
+)
+
+// SignatureHeader carries "sha256=<hex HMAC-SHA256 of the body>" when a secret is configured
+const SignatureHeader = "X-AAW-Signature"
+
+// Delivery policy: each attempt is bounded by requestTimeout, and a failed attempt is retried
+// after retryBackoff, doubling each time, until maxAttempts have been made
+var (
+	requestTimeout = 5 * time.Second
+	retryBackoff   = time.Second
+	maxAttempts    = 3
+)
+
+// Event is the JSON body POSTed for every task that reached a terminal state
+type Event struct {
+	TaskID     int64             `json:"taskId"`
+	Status     string            `json:"status"`   // COMPLETED, FAILED or CANCELLED
+	ExitCode   int               `json:"exitCode"` // 128+N when signal N killed the process, -1 when it never ran
+	DurationMs int64             `json:"durationMs"`
+	Error      string            `json:"error,omitempty"`
+	ErrorCode  string            `json:"errorCode,omitempty"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	FinishedAt time.Time         `json:"finishedAt"`
+}
+
+// Notifier POSTs events to a webhook URL
+// A nil *Notifier is valid and does nothing, so callers need not check whether a webhook is configured.
+type Notifier struct {
+	url    string
+	secret []byte
+	client *http.Client
+
+	pending   sync.WaitGroup
+	delivered atomic.Int64
+	failed    atomic.Int64
+}
+
+// New returns a notifier for url, signing bodies with secret when it is set
+// Returns nil when url is empty
+func New(url, secret string) *Notifier {
+	if url == "" {
+		return nil
+	}
+	n := &Notifier{url: url, client: &http.Client{Timeout: requestTimeout}}
+	if secret != "" {
+		n.secret = []byte(secret)
+	}
+	return n
+}
+
+// Notify delivers event in the background
+func (n *Notifier) Notify(event Event) {
+	if n == nil {
+		return
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		n.failed.Add(1)
+		log.Printf("[WEBHOOK] Failed to encode event for task %d: %v", event.TaskID, err)
+		return
+	}
+
+	n.pending.Add(1)
+	go func() {
+		defer n.pending.Done()
+		n.deliver(event.TaskID, body)
+	}()
+}
+
+// Flush waits up to timeout for deliveries in flight, reporting whether they all finished
+func (n *Notifier) Flush(timeout time.Duration) bool {
+	if n == nil {
+		return true
+	}
+	done := make(chan struct{})
+	go func() {
+		n.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Stats returns how many events were delivered and how many were given up on
+func (n *Notifier) Stats() (delivered, failed int64) {
+	if n == nil {
+		return 0, 0
+	}
+	return n.delivered.Load(), n.failed.Load()
+}
+
+// deliver POSTs body, retrying failures that may be transient
+func (n *Notifier) deliver(taskID int64, body []byte) {
+	backoff := retryBackoff
+	for attempt := 1; ; attempt++ {
+		retry, err := n.post(body)
+		if err == nil {
+			n.delivered.Add(1)
+			return
+		}
+		if !retry || attempt >= maxAttempts {
+			failed := n.failed.Add(1)
+			log.Printf("[WEBHOOK] Giving up on task %d after %d attempt(s): %v (%d failed so far)", taskID, attempt, err, failed)
+			return
+		}
+		log.Printf("[WEBHOOK] Attempt %d for task %d failed, retrying in %s: %v", attempt, taskID, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// post makes one delivery attempt, reporting whether a failure is worth retrying
+// Connection errors, 429 and 5xx responses are retried; other non-2xx responses are not.
+func (n *Notifier) post(body []byte) (retry bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "aaw-runner/"+version.Version)
+	if n.secret != nil {
+		req.Header.Set(SignatureHeader, Sign(n.secret, body))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("webhook returned %s", resp.Status)
+	default:
+		return false, fmt.Errorf("webhook returned %s", resp.Status)
+	}
+}
+
+// Sign returns the SignatureHeader value for body: "sha256=" and the hex HMAC-SHA256 keyed by secret
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,138 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fastRetries shortens the retry backoff for the duration of a test
+func fastRetries(t *testing.T) {
+	t.Helper()
+	orig := retryBackoff
+	retryBackoff = 10 * time.Millisecond
+	t.Cleanup(func() { retryBackoff = orig })
+}
+
+// TestNotify_DeliversSignedEvent verifies the event is POSTed as JSON with an HMAC signature
+func TestNotify_DeliversSignedEvent(t *testing.T) {
+	var got Event
+	var signature string
+	var valid bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		signature = r.Header.Get(SignatureHeader)
+		valid = signature == Sign([]byte("s3cret"), body)
+		json.Unmarshal(body, &got)
+	}))
+	defer server.Close()
+
+	n := New(server.URL, "s3cret")
+	n.Notify(Event{TaskID: 7, Status: "FAILED", ExitCode: 2, DurationMs: 1500, Error: "exit status 2",
+		ErrorCode: "EXIT_NONZERO", Metadata: map[string]string{"job": "nightly"}})
+	assert.True(t, n.Flush(time.Second))
+
+	assert.Equal(t, int64(7), got.TaskID)
+	assert.Equal(t, "FAILED", got.Status)
+	assert.Equal(t, 2, got.ExitCode)
+	assert.Equal(t, int64(1500), got.DurationMs)
+	assert.Equal(t, "nightly", got.Metadata["job"])
+	assert.Contains(t, signature, "sha256=")
+	assert.True(t, valid, "Signature should be the HMAC of the body")
+
+	delivered, failed := n.Stats()
+	assert.Equal(t, int64(1), delivered)
+	assert.Equal(t, int64(0), failed)
+}
+
+// TestNotify_NoSecretNoSignature verifies unsigned deliveries omit the signature header
+func TestNotify_NoSecretNoSignature(t *testing.T) {
+	var signed atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signed.Store(r.Header.Get(SignatureHeader) != "")
+	}))
+	defer server.Close()
+
+	n := New(server.URL, "")
+	n.Notify(Event{TaskID: 1, Status: "COMPLETED"})
+	assert.True(t, n.Flush(time.Second))
+	assert.False(t, signed.Load())
+}
+
+// TestNotify_RetriesThenSucceeds verifies transient failures are retried
+func TestNotify_RetriesThenSucceeds(t *testing.T) {
+	fastRetries(t)
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	n := New(server.URL, "")
+	n.Notify(Event{TaskID: 2, Status: "COMPLETED"})
+	assert.True(t, n.Flush(time.Second))
+
+	assert.Equal(t, int32(3), attempts.Load())
+	delivered, failed := n.Stats()
+	assert.Equal(t, int64(1), delivered)
+	assert.Equal(t, int64(0), failed)
+}
+
+// TestNotify_PermanentFailure verifies delivery is abandoned after the last attempt and counted
+func TestNotify_PermanentFailure(t *testing.T) {
+	fastRetries(t)
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	n := New(server.URL, "")
+	n.Notify(Event{TaskID: 3, Status: "COMPLETED"})
+	assert.True(t, n.Flush(time.Second))
+
+	assert.Equal(t, int32(maxAttempts), attempts.Load())
+	delivered, failed := n.Stats()
+	assert.Equal(t, int64(0), delivered)
+	assert.Equal(t, int64(1), failed)
+}
+
+// TestNotify_ClientErrorNotRetried verifies a 4xx response is not retried
+func TestNotify_ClientErrorNotRetried(t *testing.T) {
+	fastRetries(t)
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	n := New(server.URL, "")
+	n.Notify(Event{TaskID: 4, Status: "COMPLETED"})
+	assert.True(t, n.Flush(time.Second))
+
+	assert.Equal(t, int32(1), attempts.Load())
+	_, failed := n.Stats()
+	assert.Equal(t, int64(1), failed)
+}
+
+// TestNotifier_NilIsNoop verifies an unconfigured notifier does nothing
+func TestNotifier_NilIsNoop(t *testing.T) {
+	n := New("", "secret")
+	assert.Nil(t, n)
+
+	n.Notify(Event{TaskID: 5})
+	assert.True(t, n.Flush(time.Millisecond))
+	delivered, failed := n.Stats()
+	assert.Zero(t, delivered)
+	assert.Zero(t, failed)
+}
@@ -0,0 +1,85 @@
+package websocket
+
+import (
+	"errors"
+
+	"github.com/berno/aaw-runner/internal/executor"
+	"github.com/berno/aaw-runner/internal/models"
+)
+
+// Operator actions for the admin API (internal/admin)
+// Cancel and kill go through the same handlers as CANCEL_TASK and KILL_TASK, so the backend
+// receives the usual acknowledgments whichever side asked
+
+// Capacity returns the pool's current capacity
+func (c *Client) Capacity() (maxParallel, running, available int) {
+	return c.pool.GetCapacity()
+}
+
+// Draining reports whether the runner is refusing new tasks
+func (c *Client) Draining() bool {
+	return c.pool.Draining()
+}
+
+// Tasks lists the running and queued tasks
+func (c *Client) Tasks() []executor.TaskSnapshot {
+	return c.pool.Tasks()
+}
+
+// DroppedLogLines returns how many task output lines were dropped since startup because sending fell
+// behind (see config.LogBackpressure)
+func (c *Client) DroppedLogLines() int64 {
+	return c.executor.DroppedLines()
+}
+
+// CancelTask gracefully cancels a task as if the backend had sent CANCEL_TASK, attributed to the operator
+func (c *Client) CancelTask(taskID int64) error {
+	return c.handleCancelTask(models.CancelTaskMessage{Type: models.TypeCancelTask, TaskID: taskID, RequestedBy: models.RequestedByOperator})
+}
+
+// KillTask force-kills a task as if the backend had sent KILL_TASK, returning once it is verified dead
+// The kill is attributed to the operator.
+func (c *Client) KillTask(taskID int64) error {
+	term := c.handleKillTask(models.KillTaskMessage{Type: models.TypeKillTask, TaskID: taskID, RequestedBy: models.RequestedByOperator})
+	if !term.Success() {
+		return errors.New(term.ErrorString())
+	}
+	return nil
+}
+
+// Drain stops accepting new tasks; running tasks are left alone
+// The backend sees the pool advertise no free slots until Undrain
+func (c *Client) Drain() {
+	c.pool.Drain()
+	c.statusFile.Notify()
+}
+
+// Undrain accepts new tasks again after Drain
+func (c *Client) Undrain() error {
+	if c.shuttingDown.Load() {
+		return errors.New("runner is shutting down")
+	}
+	c.pool.Undrain()
+	c.statusFile.Notify()
+	c.recurring.CatchUp()
+	return nil
+}
+
+// Pause holds queued tasks until Resume; running tasks carry on
+func (c *Client) Pause() {
+	c.pool.Pause()
+	c.history.record("Paused")
+	c.statusFile.Notify()
+}
+
+// Resume starts queued tasks again after Pause
+func (c *Client) Resume() {
+	c.pool.Resume()
+	c.history.record("Resumed")
+	c.statusFile.Notify()
+}
+
+// Paused reports whether queued tasks are being held
+func (c *Client) Paused() bool {
+	return c.pool.Paused()
+}
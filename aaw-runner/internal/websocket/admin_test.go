@@ -0,0 +1,34 @@
+package websocket
+
+import (
+	"testing"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestKillTask_AcksBackend verifies an operator kill sends the same acknowledgments as KILL_TASK
+func TestKillTask_AcksBackend(t *testing.T) {
+	client, frames := startTaskClient(t, "sleep 30")
+
+	assert.NoError(t, client.KillTask(31))
+
+	got := receiveUntil(t, frames, models.TypeTaskTerminated)
+	assert.GreaterOrEqual(t, indexOf(got, models.TypeCancelAck, 31), 0)
+	assert.Equal(t, int64(31), got[len(got)-1].TaskID)
+	assert.Error(t, client.CancelTask(31), "Task is no longer running")
+}
+
+// TestUndrain_RefusedDuringShutdown verifies operators cannot reopen a runner that is shutting down
+func TestUndrain_RefusedDuringShutdown(t *testing.T) {
+	client, _ := startTaskClient(t, "true")
+
+	client.Drain()
+	assert.True(t, client.Draining())
+	assert.NoError(t, client.Undrain())
+	assert.False(t, client.Draining())
+
+	client.Shutdown(0)
+	assert.Error(t, client.Undrain())
+	assert.True(t, client.Draining())
+}
@@ -0,0 +1,72 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/audit"
+	"github.com/berno/aaw-runner/internal/config"
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestAudit_RecordsProtocolTraffic verifies frames in both directions end up in the audit log
+func TestAudit_RecordsProtocolTraffic(t *testing.T) {
+	upgrader := websocket.Upgrader{Subprotocols: testSubprotocols}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.ReadMessage() // HELO
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"HELO_ACK","protocolVersion":1}`))
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	cfg := config.Default()
+	cfg.BackendURL = "ws" + strings.TrimPrefix(server.URL, "http")
+	dir := t.TempDir()
+	auditLog, err := audit.Open(dir, 1, 1, nil)
+	assert.NoError(t, err)
+
+	client := NewClient(cfg)
+	client.SetAudit(auditLog)
+	assert.NoError(t, client.Connect())
+	done := make(chan error, 1)
+	go func() { done <- client.Listen() }()
+
+	path := filepath.Join(dir, audit.FileName)
+	assert.Eventually(t, func() bool {
+		data, _ := os.ReadFile(path)
+		return strings.Contains(string(data), models.TypeHeloAck)
+	}, 5*time.Second, 10*time.Millisecond)
+	client.Close()
+	<-done
+	assert.NoError(t, auditLog.Close())
+
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	defer f.Close()
+	records, err := audit.Read(f)
+	assert.NoError(t, err)
+
+	seen := make(map[string]string)
+	for _, rec := range records {
+		seen[rec.Type] = rec.Direction
+	}
+	assert.Equal(t, audit.Outbound, seen[models.TypeHelo])
+	assert.Equal(t, audit.Outbound, seen[models.TypeRunnerCapacity])
+	assert.Equal(t, audit.Inbound, seen[models.TypeHeloAck])
+}
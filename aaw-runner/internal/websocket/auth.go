@@ -0,0 +1,89 @@
+package websocket
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/config"
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/gorilla/websocket"
+)
+
+// errAuthRejected is returned by Connect when the backend does not accept the runner's AUTH_RESPONSE
+var errAuthRejected = errors.New("backend rejected the runner's AUTH_RESPONSE")
+
+// runnerSecret returns the shared secret cfg sets, read from RunnerSecretFile when that is set, or nil for none
+func runnerSecret(cfg config.Config) ([]byte, error) {
+	if cfg.RunnerSecretFile == "" {
+		if cfg.RunnerSecret == "" {
+			return nil, nil
+		}
+		return []byte(cfg.RunnerSecret), nil
+	}
+	if cfg.RunnerSecret != "" {
+		return nil, errors.New("AAW_RUNNER_SECRET and AAW_RUNNER_SECRET_FILE cannot both be set")
+	}
+	data, err := os.ReadFile(cfg.RunnerSecretFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read runner secret file: %w", err)
+	}
+	secret := bytes.TrimSpace(data)
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("runner secret file %s is empty", cfg.RunnerSecretFile)
+	}
+	return secret, nil
+}
+
+// authenticate answers the backend's AUTH_CHALLENGE on conn, read by r, and waits for its AUTH_OK; connect
+// calls it before the connection may carry anything else. early is a message already read (see awaitHeloAck).
+// Without a secret there is nothing to answer with. With one, a backend whose first message is not
+// AUTH_CHALLENGE, or that sends nothing within AuthChallengeTimeout, does not challenge runners and
+// the connection is used as before; the message it sent instead is returned for listen to handle.
+func (c *Client) authenticate(conn *websocket.Conn, r *reader, schema int, early []byte) ([]byte, error) {
+	if c.secret == nil {
+		return early, nil
+	}
+	message := early
+	if message == nil {
+		select {
+		case m, ok := <-r.messages:
+			if !ok {
+				return nil, fmt.Errorf("connection lost before AUTH_CHALLENGE: %w", r.err)
+			}
+			message = m
+		case <-time.After(c.cfg.AuthChallengeTimeout):
+			log.Printf("[WS] No AUTH_CHALLENGE within %s, continuing unauthenticated", c.cfg.AuthChallengeTimeout)
+			return nil, nil
+		}
+	}
+	msg, err := models.DecodeIncoming(message)
+	challenge, ok := msg.(*models.AuthChallengeMessage)
+	if err != nil || !ok {
+		log.Printf("[WS] Backend did not send AUTH_CHALLENGE, continuing unauthenticated")
+		return message, nil
+	}
+
+	response := models.NewAuthResponse(challenge.Nonce, c.secret)
+	response.RunnerID = c.runnerID
+	response.SetSchemaVersion(schema)
+	if err := c.write(conn, &response); err != nil {
+		return nil, fmt.Errorf("failed to send AUTH_RESPONSE: %w", err)
+	}
+	select {
+	case message, ok := <-r.messages:
+		if !ok {
+			return nil, fmt.Errorf("%w: connection closed: %v", errAuthRejected, r.err)
+		}
+		if got, err := models.PeekType(message); err != nil || got != models.TypeAuthOK {
+			return nil, fmt.Errorf("%w: got %q instead of AUTH_OK", errAuthRejected, got)
+		}
+	case <-time.After(c.cfg.AuthChallengeTimeout):
+		return nil, fmt.Errorf("%w: no AUTH_OK within %s", errAuthRejected, c.cfg.AuthChallengeTimeout)
+	}
+	log.Printf("[WS] Authenticated with the backend")
+	return nil, nil
+}
@@ -0,0 +1,120 @@
+package websocket
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/config"
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// startAuthBackend starts a backend that challenges the runner after HELO_ACK and sends AUTH_OK for
+// a response signed with secret, closing the connection for any other
+func startAuthBackend(t *testing.T, secret string) (config.Config, chan []byte) {
+	t.Helper()
+	frames := make(chan []byte, 64)
+	const nonce = "5d41402abc4b2a76"
+	cfg, _ := startBackendWith(t, backendHooks{frames: frames, onFrame: func(conn *websocket.Conn, _ int, data []byte) bool {
+		msgType, _ := models.PeekType(data)
+		switch msgType {
+		case models.TypeHelo: // Already acknowledged
+			conn.WriteJSON(models.AuthChallengeMessage{Type: models.TypeAuthChallenge, Nonce: nonce})
+		case models.TypeAuthResponse:
+			var response models.AuthResponseMessage
+			if json.Unmarshal(data, &response) != nil || !models.VerifyAuthResponse(response, nonce, []byte(secret)) {
+				return false
+			}
+			conn.WriteJSON(models.AuthOKMessage{Type: models.TypeAuthOK})
+		}
+		return true
+	}})
+	return cfg, frames
+}
+
+// TestAuth_Accepted verifies the runner answers AUTH_CHALLENGE with its secret, read from a file, and
+// only reports status and capacity once the backend has sent AUTH_OK
+func TestAuth_Accepted(t *testing.T) {
+	cfg, frames := startAuthBackend(t, "tenant-secret")
+	cfg.RunnerSecretFile = filepath.Join(t.TempDir(), "secret")
+	assert.NoError(t, os.WriteFile(cfg.RunnerSecretFile, []byte("tenant-secret\n"), 0o600))
+	client := NewClient(cfg)
+	client.SetRunnerID("runner-3")
+	t.Cleanup(func() { client.Close() })
+	assert.NoError(t, client.Connect())
+
+	var types []string
+	for _, f := range receiveUntil(t, frames, models.TypeRunnerCapacity) {
+		types = append(types, f.Type)
+	}
+	assert.Equal(t, []string{models.TypeHelo, models.TypeAuthResponse, models.TypeRunnerStatus, models.TypeRunnerCapacity}, types)
+	assert.True(t, client.pool.Running())
+}
+
+// TestAuth_BadSecretRejected verifies Connect fails, without starting the pool, when the backend refuses
+// the response
+func TestAuth_BadSecretRejected(t *testing.T) {
+	cfg, frames := startAuthBackend(t, "tenant-secret")
+	cfg.RunnerSecret = "wrong-secret"
+	client := NewClient(cfg)
+	t.Cleanup(func() { client.Close() })
+
+	err := client.Connect()
+	assert.ErrorIs(t, err, errAuthRejected)
+	assert.False(t, client.Connected())
+	assert.False(t, client.pool.Running())
+	got := receiveUntil(t, frames, models.TypeAuthResponse)
+	assert.Len(t, got, 2)
+}
+
+// TestAuth_NoChallenge verifies a runner with a secret still connects to a backend that never challenges,
+// after AuthChallengeTimeout
+func TestAuth_NoChallenge(t *testing.T) {
+	cfg, frames := startBackend(t)
+	cfg.RunnerSecret = "tenant-secret"
+	cfg.AuthChallengeTimeout = 50 * time.Millisecond
+	client := NewClient(cfg)
+	t.Cleanup(func() { client.Close() })
+
+	assert.NoError(t, client.Connect())
+	for _, f := range receiveUntil(t, frames, models.TypeRunnerCapacity) {
+		assert.NotEqual(t, models.TypeAuthResponse, f.Type)
+	}
+}
+
+// TestRunnerSecret verifies where the secret is read from, and that conflicting or empty settings are refused
+func TestRunnerSecret(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "secret")
+	empty := filepath.Join(dir, "empty")
+	assert.NoError(t, os.WriteFile(file, []byte("  from-file \n"), 0o600))
+	assert.NoError(t, os.WriteFile(empty, []byte("\n"), 0o600))
+
+	tests := []struct {
+		name    string
+		secret  string
+		file    string
+		want    []byte
+		wantErr bool
+	}{
+		{name: "none"},
+		{name: "value", secret: "s3cret", want: []byte("s3cret")},
+		{name: "file", file: file, want: []byte("from-file")},
+		{name: "both", secret: "s3cret", file: file, wantErr: true},
+		{name: "missing file", file: filepath.Join(dir, "missing"), wantErr: true},
+		{name: "empty file", file: empty, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.RunnerSecret, cfg.RunnerSecretFile = tt.secret, tt.file
+			secret, err := runnerSecret(cfg)
+			assert.Equal(t, tt.wantErr, err != nil, "got %v", err)
+			assert.Equal(t, tt.want, secret)
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package websocket
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestHandleExecute_RefusedWhileClaudeMissing verifies HELO reports the probe and dynamic tasks
+// are refused until a re-probe finds the binary
+func TestHandleExecute_RefusedWhileClaudeMissing(t *testing.T) {
+	claudePath := filepath.Join(t.TempDir(), "claude")
+	cfg, frames := startBackend(t)
+	cfg.ClaudePath = claudePath
+	client := NewClient(cfg)
+	client.Claude().Probe()
+	assert.NoError(t, client.Connect())
+	defer client.Close()
+
+	var helo models.HeloMessage
+	assert.NoError(t, json.Unmarshal(<-frames, &helo))
+	assert.Equal(t, models.TypeHelo, helo.Type)
+	if assert.NotNil(t, helo.Claude) {
+		assert.False(t, helo.Claude.Present)
+	}
+
+	client.handleExecute(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 50, ScriptContent: "hello"})
+	got := receiveUntil(t, frames, models.TypeTaskCompleted)
+	assert.Equal(t, models.ErrorCodeEnvironment, got[len(got)-1].ErrorCode)
+	assert.Equal(t, -1, indexOf(got, models.TypeTaskStarted, 50), "Refused task must not start")
+
+	// Installing the binary and re-probing lets tasks through without a restart
+	assert.NoError(t, os.WriteFile(claudePath, []byte("#!/bin/sh\necho \"1.0.35 (Claude Code)\"\n"), 0o755))
+	assert.True(t, client.Claude().Probe().Present)
+
+	client.handleExecute(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 51, ScriptContent: "hello"})
+	got = receiveUntil(t, frames, models.TypeTaskCompleted)
+	assert.GreaterOrEqual(t, indexOf(got, models.TypeTaskStarted, 51), 0)
+	assert.Empty(t, got[len(got)-1].ErrorCode)
+}
@@ -1,195 +1,524 @@
 package websocket
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"os"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/berno/aaw-runner/internal/audit"
+	"github.com/berno/aaw-runner/internal/claudecli"
+	"github.com/berno/aaw-runner/internal/config"
 	"github.com/berno/aaw-runner/internal/executor"
+	"github.com/berno/aaw-runner/internal/journal"
+	"github.com/berno/aaw-runner/internal/livelog"
 	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/orphan"
+	"github.com/berno/aaw-runner/internal/recurring"
 	"github.com/berno/aaw-runner/internal/runner"
+	"github.com/berno/aaw-runner/internal/statsd"
+	"github.com/berno/aaw-runner/internal/statusfile"
+	"github.com/berno/aaw-runner/internal/tasklog"
+	"github.com/berno/aaw-runner/internal/webhook"
 	"github.com/gorilla/websocket"
 )
 
 // Client represents a WebSocket client connection
 type Client struct {
 	serverURL    string
+	cfg          config.Config
 	conn         *websocket.Conn
-	connMutex    sync.Mutex // Mutex to prevent concurrent writes to WebSocket
+	connMutex    sync.Mutex       // Guards conn, schema, generation and truncated; writes happen on the writer goroutine without it
+	schema       int              // Schema version stamped on outgoing messages (guarded by connMutex)
+	subprotocol  string           // Subprotocol the backend selected for conn (guarded by connMutex)
+	generation   uint64           // Bumped for every new connection; messages queued for an older one are not written to it (guarded by connMutex)
+	downAt       time.Time        // When the read loop of the last connection ended (guarded by connMutex)
+	connected    atomic.Bool      // Set once HELO is sent, cleared when the read loop ends
+	shuttingDown atomic.Bool      // Set by Shutdown; the pool can no longer be undrained
+	forced       atomic.Bool      // Set by ForceShutdown; writes get short deadlines
+	byeSent      atomic.Bool      // BYE goes out at most once
+	closed       atomic.Bool      // Set by Close once the writer is stopped: sendJSON discards everything after
+	discarded    atomic.Int64     // Messages discarded because they were sent after Close (executor callbacks of stopping tasks)
+	tooLarge     atomic.Bool      // Set when the backend overran MaxMessageBytes; reported after the next connect
+	truncated    map[string]int64 // Outbound fields truncated so far, by field name (guarded by connMutex)
 	executor     *executor.TaskExecutor
 	pool         *executor.ExecutorPool
 	stateMachine *runner.StateMachine
+	claude       *claudecli.Prober     // Availability of the claude CLI, reported in HELO and checked before dynamic tasks
+	runnerID     string                // Identifies the runner in HELO, RUNNER_STATUS and RUNNER_CAPACITY (empty unless SetRunnerID)
+	history      *history              // Recent output and events for the terminal UI (nil unless KeepHistory)
+	webhook      *webhook.Notifier     // Host-local completion webhook (nil when not configured)
+	metrics      *statsd.Client        // StatsD emitter (nil unless SetMetrics)
+	audit        *audit.Writer         // Protocol audit log (nil unless SetAudit)
+	terminations *audit.TerminationLog // Cancel and kill audit trail (nil unless SetTerminationLog)
+	taskLogs     *tasklog.Spool        // Local copy of each task's output (nil unless SetTaskLogs)
+	logUploader  *tasklog.Uploader     // Ships finished task logs to S3 (nil unless SetTaskLogs)
+	liveLogs     *livelog.Broadcaster  // Running tasks' output for local viewers (nil unless SetLiveLogs)
+	statusFile   *statusfile.Writer    // Status file for external monitors (nil unless SetStatusFile)
+	orphans      *orphan.Policy        // Acts on running tasks while the backend is unreachable (nil with OrphanContinue)
+	recurring    *recurring.Scheduler  // Recurrences registered with RECURRING_EXECUTE (nil unless SetRecurring)
+	journal      *journal.Journal      // Completions awaiting the backend's ACK (nil unless SetJournal)
+
+	linesMu  sync.Mutex
+	nextLine map[int64]int64         // Index the next output line of each running task gets
+	sampled  map[int64]*sampledLines // Lines of each task left out while the backend is slow (see sampleLine)
+	replays  map[int64]chan struct{} // RESUME_LOGS replays in progress, closed to stop one
+
+	stdinMu     sync.Mutex
+	stdinQueues map[int64]*stdinQueue // STDIN_INPUT messages of each task not yet written, in arrival order
+
+	heldMu sync.Mutex
+	held   []models.TaskCompletedMessage // Completions of tasks cancelled by the orphan policy, replayed on connect
+
+	lastErrMu sync.Mutex
+	lastErr   string // Most recent connection or send error, for the status file
+	lastErrAt time.Time
+
+	closing   chan struct{} // Closed by Close: a lost connection is no longer redialled
+	closeOnce sync.Once
+	done      chan struct{} // Closed once a SHUTDOWN from the backend has been carried out (see Done)
+	doneOnce  sync.Once
+	doneWhy   string    // The SHUTDOWN's reason, set before done is closed
+	startPool sync.Once // The first Connect starts the pool, which keeps running across reconnects
+	heartbeat sync.Once // The first Connect starts heartbeatLoop, which runs until Close
+	startedAt time.Time // When the client was created, for the heartbeat's uptime
+
+	dialer    *websocket.Dialer // Dials the backend with the configured TLS settings
+	dialErr   error             // Why the dialer could not be built (a bad TLS CA file); returned by Connect
+	secret    []byte            // Signs the backend's AUTH_CHALLENGE (nil without AAW_RUNNER_SECRET)
+	secretErr error             // Why the secret could not be read; returned by Connect
+	resolver  resolver          // Looks up the backend's addresses on every dial (see dialAddresses)
+
+	outbox     chan outgoing // Messages waiting for the writer goroutine (see writeLoop)
+	stopWriter chan struct{} // Closed by Close: the writer sends what is queued and exits
+	stopOnce   sync.Once
+	writerDone chan struct{}  // Closed when the writer has exited
+	offline    *offlineBuffer // Task messages the writer could not send, sent first once reconnected
+
+	latency writeLatency // Recent write latencies; task output is sampled while they are high (see noteWriteLatency)
+
+	offlineHeld    atomic.Int64 // Messages in the offline buffer since the last outage report, replayed on reconnect
+	offlineDropped atomic.Int64 // Messages dropped while disconnected since the last outage report
+
+	reader *reader // Reads conn (guarded by connMutex)
+	early  []byte  // A message read while waiting for HELO_ACK, handled first by listen (guarded by connMutex)
+
+	hooks    hooks                     // Lifecycle callbacks set with NewClient's options
+	handlers map[string]MessageHandler // By message type (see RegisterHandler)
 }
 
-// NewClient creates a new WebSocket client
-func NewClient(serverURL string) *Client {
+// NewClient creates a new WebSocket client for the backend in cfg, customised by opts
+func NewClient(cfg config.Config, opts ...Option) *Client {
 	client := &Client{
-		serverURL: serverURL,
+		serverURL: cfg.BackendURL,
+		cfg:       cfg,
+		schema:    models.SchemaVersion,
+		truncated: make(map[string]int64),
+		nextLine:  make(map[int64]int64),
+		sampled:   make(map[int64]*sampledLines),
+		replays:   make(map[int64]chan struct{}),
+		closing:   make(chan struct{}),
+		done:      make(chan struct{}),
+		startedAt: time.Now(),
+		claude:    claudecli.NewProber(cfg.ClaudePath, os.Getenv),
+		webhook:   webhook.New(cfg.CompletionWebhookURL, cfg.CompletionWebhookSecret),
+	}
+	for _, opt := range opts {
+		opt(client)
 	}
 
 	// Create state machine with callback (for backward compatibility)
 	client.stateMachine = runner.NewStateMachine(client.sendRunnerStatus)
 
 	// Create executor with callbacks
-	client.executor = executor.NewTaskExecutor(
+	client.executor = executor.NewTaskExecutorWithConfig(
+		cfg,
 		client.sendLogMessage,
 		client.sendStatusUpdate,
 	)
 
 	// Create executor pool for concurrent task execution
-	maxParallel := runner.GetMaxParallel()
-	client.pool = executor.NewExecutorPool(
+	client.pool = executor.NewExecutorPoolWithConfig(
 		client.executor,
-		maxParallel,
+		cfg,
 		client.sendCapacityUpdate,
 		client.onTaskComplete,
 	)
+	client.executor.SetToolEventHandler(client.sendToolEvent)
+	client.pool.SetTaskStartHandler(client.onTaskStart)
+	client.pool.SetDetectionObserver(client.onDetected)
+	client.pool.SetTerminationObserver(client.onTermination)
+	client.pool.SetReservationExpiryHandler(client.sendReservationExpired)
+	client.handlers = make(map[string]MessageHandler)
+	client.registerBuiltins()
+	client.orphans = orphan.New(cfg.OrphanPolicy, cfg.OrphanAfter, client.pool)
+	client.dialer, client.dialErr = newDialer(cfg)
+	client.secret, client.secretErr = runnerSecret(cfg)
+	client.resolver = net.DefaultResolver
+	if client.dialer != nil {
+		client.dialer.NetDialContext = client.dialAddresses
+	}
+
+	client.outbox = make(chan outgoing, outboxSize)
+	client.offline = newOfflineBuffer(cfg.OfflineBufferLines)
+	client.stopWriter = make(chan struct{})
+	client.writerDone = make(chan struct{})
+	go client.writeLoop()
 
 	return client
 }
 
 // Connect establishes WebSocket connection and sends HELO
+// Listen calls it again to redial after the connection drops.
 func (c *Client) Connect() error {
-	var err error
-	c.conn, _, err = websocket.DefaultDialer.Dial(c.serverURL, nil)
+	return c.connect(context.Background())
+}
+
+// connect is Connect, giving up on the dial when parent is done
+func (c *Client) connect(parent context.Context) error {
+	if c.dialErr != nil {
+		return c.dialErr
+	}
+	if c.secretErr != nil {
+		return c.secretErr
+	}
+	// Bounds the whole attempt, the TCP dial included, like the dialer's HandshakeTimeout does the handshake
+	ctx, cancel := context.WithTimeout(parent, c.cfg.ConnectTimeout)
+	defer cancel()
+	conn, resp, err := c.dialer.DialContext(ctx, c.serverURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to connect to server: %w", c.describeDialError(err))
+	}
+	c.setCompression(conn, resp)
+	subprotocol, err := c.checkSubprotocol(conn)
 	if err != nil {
-		return fmt.Errorf("failed to connect to server: %w", err)
+		conn.Close()
+		return err
 	}
 
 	// Send HELO handshake
 	hostname, _ := os.Hostname()
 	workdir, _ := os.Getwd()
 
-	heloMsg := models.HeloMessage{
-		Type:     models.TypeHelo,
-		Hostname: hostname,
-		Workdir:  workdir,
+	// HELO goes out before negotiation, so it carries the newest version alongside the oldest supported
+	heloMsg := models.NewHelo(hostname, workdir)
+	heloMsg.RunnerID = c.runnerID
+	if status := c.claude.Status(); status.Probed() {
+		heloMsg.Claude = &models.ClaudeInfo{Present: status.Present, Version: status.Version, AuthOK: status.AuthOK}
 	}
+	heloMsg.SetSchemaVersion(models.SchemaVersion)
 
-	if err := c.sendJSON(heloMsg); err != nil {
+	// HELO is written before the writer can see the connection, so nothing queued overtakes it
+	if err := c.write(conn, &heloMsg); err != nil {
+		conn.Close() // No reader owns it yet
 		return fmt.Errorf("failed to send HELO: %w", err)
 	}
 
+	// The backend's HELO_ACK picks the schema version before anything else is sent
+	conn.SetReadLimit(int64(c.cfg.MaxMessageBytes))
+	r := c.startReader(conn)
+	schema, early, err := c.awaitHeloAck(r)
+	if err == nil {
+		// A backend that challenges runners takes nothing else until it has accepted this one
+		early, err = c.authenticate(conn, r, schema, early)
+	}
+	if err != nil {
+		r.close()
+		return err
+	}
+
+	c.connMutex.Lock()
+	c.conn = conn
+	c.subprotocol = subprotocol
+	c.schema = schema
+	c.generation++
+	c.reader, c.early = r, early
+	c.connMutex.Unlock()
+
 	log.Printf("Connected to server at %s (hostname: %s, workdir: %s)", c.serverURL, hostname, workdir)
+	c.connected.Store(true)
+	c.orphans.Connected()
+	c.history.record("Connected to %s", c.serverURL)
+	c.statusFile.Notify()
+	c.metrics.Incr(metricConnects)
 
 	// Start the executor pool
-	c.pool.Start()
+	c.startPool.Do(c.pool.Start)
+	c.heartbeat.Do(func() { go c.heartbeatLoop() })
 
 	// Send initial IDLE status (for backward compatibility)
 	c.sendRunnerStatus(runner.StateIdle)
 
-	// Send initial capacity
+	// Send initial capacity, listing the reservations still held from before a reconnect
 	max, running, available := c.pool.GetCapacity()
-	c.sendCapacityUpdate(max, running, available)
+	c.sendCapacity(max, running, available, c.pool.Reservations())
 
+	c.resendUnacked()
+	c.replayHeld()
+	c.reportTooLarge()
+	c.recurring.CatchUp()
+	c.hooks.onConnected()
 	return nil
 }
 
-// Listen starts listening for messages from the server
-func (c *Client) Listen() error {
-	defer c.conn.Close()
+// errNotConnected is returned by listen when no connection has been made yet
+var errNotConnected = errors.New("not connected")
 
+// Listen handles messages from the server until the client is closed or shut down, redialling with
+// backoff whenever the connection drops (see reconnect). It returns the error that ended the last
+// connection once the client stops or MaxReconnectAttempts redials in a row have failed.
+func (c *Client) Listen() error {
 	for {
-		_, message, err := c.conn.ReadMessage()
-		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("WebSocket error: %v", err)
+		err := c.listen()
+		if c.stopping() {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				return nil // The backend answered Close's close frame
 			}
 			return err
 		}
-
-		// Parse message type
-		var baseMsg struct {
-			Type string `json:"type"`
-		}
-		if err := json.Unmarshal(message, &baseMsg); err != nil {
-			log.Printf("Failed to parse message: %v", err)
-			continue
+		if err := c.reconnect(err); err != nil {
+			return err
 		}
+	}
+}
 
-		// Handle different message types
-		switch baseMsg.Type {
-		case models.TypeExecute:
-			var execMsg models.ExecuteMessage
-			if err := json.Unmarshal(message, &execMsg); err != nil {
-				log.Printf("Failed to parse EXECUTE message: %v", err)
-				continue
-			}
-			go c.handleExecute(execMsg)
-
-		case models.TypeCancelTask:
-			var cancelMsg models.CancelTaskMessage
-			if err := json.Unmarshal(message, &cancelMsg); err != nil {
-				log.Printf("Failed to parse CANCEL_TASK message: %v", err)
-				continue
-			}
-			go c.handleCancelTask(cancelMsg)
+// listen handles messages from the current connection until reading from it fails
+func (c *Client) listen() error {
+	c.connMutex.Lock()
+	r, early := c.reader, c.early
+	c.early = nil
+	c.connMutex.Unlock()
+	if r == nil {
+		return errNotConnected
+	}
+	defer r.close()
+	defer c.connected.Store(false)
 
-		case models.TypeKillTask:
-			var killMsg models.KillTaskMessage
-			if err := json.Unmarshal(message, &killMsg); err != nil {
-				log.Printf("Failed to parse KILL_TASK message: %v", err)
-				continue
-			}
-			go c.handleKillTask(killMsg)
+	if early != nil {
+		c.handleMessage(early)
+	}
+	for message := range r.messages {
+		c.handleMessage(message)
+	}
 
-		default:
-			log.Printf("Unknown message type: %s", baseMsg.Type)
-		}
+	c.connMutex.Lock()
+	c.downAt = time.Now()
+	c.connMutex.Unlock()
+
+	err := r.err
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		err = fmt.Errorf("no pong from the backend for %s: %w", c.pongWait(), err)
+		log.Printf("[WS] %v", err)
+	}
+	if errors.Is(err, websocket.ErrReadLimit) {
+		// The frame is never read, so its size is unknown; gorilla has already sent close 1009
+		log.Printf("[WS] Backend sent a message over the %d-byte limit (AAW_MAX_MESSAGE_BYTES), dropping the connection", c.cfg.MaxMessageBytes)
+		c.tooLarge.Store(true)
 	}
+	if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+		log.Printf("WebSocket error: %v", err)
+	}
+	c.history.record("Disconnected: %v", err)
+	c.orphans.Disconnected()
+	c.noteError(err)
+	c.statusFile.Notify()
+	c.metrics.Incr(metricDisconnects)
+	c.hooks.onDisconnected(err)
+	return err
 }
 
 // handleExecute processes an EXECUTE command from the server
 func (c *Client) handleExecute(msg models.ExecuteMessage) {
+	// Dynamic tasks run claude unless they name another interpreter; fail them up front rather than
+	// with "executable file not found"
+	if msg.ScriptContent != "" && (msg.Interpreter == "" || msg.Interpreter == models.InterpreterClaude) {
+		if status := c.claude.Status(); status.Probed() && !status.Present {
+			c.rejectTask(msg, models.ErrorCodeEnvironment, fmt.Sprintf("claude CLI unavailable on runner (%v)", status.Err))
+			return
+		}
+	}
+
 	// Submit task to the executor pool for concurrent execution
 	if !c.pool.Submit(msg) {
-		// Pool rejected the task (at capacity or queue full)
-		log.Printf("Task %d rejected: pool at capacity", msg.TaskID)
-
-		// Send failure status update
-		c.sendStatusUpdate(models.StatusUpdateMessage{
-			Type:   models.TypeStatusUpdate,
-			TaskID: msg.TaskID,
-			Status: models.StatusFailed,
-		})
-
-		// Send TASK_COMPLETED with failure
-		c.sendTaskCompleted(models.TaskCompletedMessage{
-			Type:    models.TypeTaskCompleted,
-			TaskID:  msg.TaskID,
-			Success: false,
-			Error:   "Runner at capacity - task rejected",
-		})
+		// Pool rejected the task (at capacity, queue full or circuit breaker open)
+		code, reason := c.pool.RejectReason()
+		c.rejectTask(msg, code, reason)
+		return
 	}
+	c.metrics.Incr(metricSubmitted)
+	c.statusFile.Notify()
 	// Note: Actual execution and completion handling is done by the pool's callbacks
 }
 
+// rejectTask reports a task that will not run as failed
+func (c *Client) rejectTask(msg models.ExecuteMessage, code, reason string) {
+	log.Printf("Task %d rejected: %s", msg.TaskID, reason)
+	c.history.record("Task %d rejected: %s", msg.TaskID, reason)
+	c.metrics.Incr(metricRejected, "error_code:"+code)
+
+	metadata := executor.LimitMetadata(msg.TaskID, msg.Metadata)
+
+	// Send failure status update
+	status := models.NewStatusUpdate(msg.TaskID, models.StatusFailed)
+	status.Metadata = metadata
+	c.sendStatusUpdate(status)
+
+	// Send TASK_COMPLETED with failure
+	completed := models.NewTaskCompleted(msg.TaskID, false)
+	completed.ExitCode = -1
+	completed.Error = reason + " - task rejected"
+	completed.ErrorCode = code
+	completed.Metadata = metadata
+	c.sendTaskCompleted(completed)
+
+	c.webhook.Notify(webhook.Event{
+		TaskID:     msg.TaskID,
+		Status:     models.StatusFailed,
+		ExitCode:   -1,
+		Error:      completed.Error,
+		ErrorCode:  code,
+		Metadata:   metadata,
+		FinishedAt: time.Now(),
+	})
+}
+
+// Claude returns the prober tracking the claude CLI; main probes it at startup and on SIGHUP
+func (c *Client) Claude() *claudecli.Prober {
+	return c.claude
+}
+
+// SetTaskLogs keeps each task's output in spool and hands finished logs to uploader; without it nothing is kept
+// Must be called before Connect.
+func (c *Client) SetTaskLogs(spool *tasklog.Spool, uploader *tasklog.Uploader) {
+	c.taskLogs = spool
+	c.logUploader = uploader
+}
+
+// SetTerminationLog records every cancel and kill attempt in l; without it (or with nil) they are only logged
+// Must be called before Connect.
+func (c *Client) SetTerminationLog(l *audit.TerminationLog) {
+	c.terminations = l
+}
+
+// onTermination is called by the executor pool once a cancel or kill attempt has an outcome
+func (c *Client) onTermination(attempt executor.TerminationAttempt) {
+	record := audit.Termination{
+		TaskID:      attempt.TaskID,
+		Type:        attempt.Kind,
+		RequestedBy: attempt.By.RequestedBy,
+		Reason:      attempt.By.Reason,
+		Outcome:     audit.OutcomeOK,
+		RequestedAt: attempt.RequestedAt,
+		CompletedAt: attempt.CompletedAt,
+	}
+	if attempt.Err != nil {
+		record.Outcome, record.Error = audit.OutcomeFailed, attempt.Err.Error()
+	}
+	if err := c.terminations.Record(record); err != nil {
+		log.Printf("[AUDIT] Failed to record %s of task %d: %v", strings.ToLower(attempt.Kind), attempt.TaskID, err)
+	}
+
+	// Requests are recorded as they arrive; only failures need recording here
+	if attempt.Err != nil {
+		c.history.record("%s of task %d by %s failed: %v", strings.ToLower(attempt.Kind), attempt.TaskID, describe(attempt.By), attempt.Err)
+	}
+}
+
+// SetLiveLogs tees every task's output into b for the admin API's log viewer
+// Must be called before Connect.
+func (c *Client) SetLiveLogs(b *livelog.Broadcaster) {
+	c.liveLogs = b
+}
+
+// SetStatusFile keeps w up to date with connection and task changes as they happen
+// Must be called before Connect.
+func (c *Client) SetStatusFile(w *statusfile.Writer) {
+	c.statusFile = w
+}
+
+// SetRunnerID sets the ID the runner identifies itself with (see internal/runnerid)
+// Must be called before Connect.
+func (c *Client) SetRunnerID(id string) {
+	c.runnerID = id
+}
+
+// SetAudit records every frame exchanged with the backend in w; without it (or with nil) nothing is recorded
+// Must be called before Connect.
+func (c *Client) SetAudit(w *audit.Writer) {
+	c.audit = w
+}
+
+// onTaskStart is called by the executor pool when a worker begins a task
+func (c *Client) onTaskStart(taskID int64, metadata map[string]string) {
+	msg := models.NewTaskStarted(taskID, metadata)
+	c.history.record("Task %d started", taskID)
+	c.statusFile.Notify()
+	c.liveLogs.Start(taskID)
+	c.metrics.Incr(metricStarted)
+
+	log.Printf("[WS] Sending TASK_STARTED: task=%d", taskID)
+	if err := c.sendJSON(&msg); err != nil && !errors.Is(err, ErrClientClosed) {
+		log.Printf("Failed to send task started: %v", err)
+	}
+}
+
 // onTaskComplete is called by the executor pool when a task completes
-func (c *Client) onTaskComplete(taskID int64, success bool, errorMsg string) {
+func (c *Client) onTaskComplete(result executor.TaskResult) {
 	// Send status update
-	status := models.StatusCompleted
-	if !success {
-		status = models.StatusFailed
-		if errorMsg == "task cancelled" {
-			status = models.StatusCancelled
-		}
+	status := result.Status()
+
+	c.history.forget(result.TaskID)
+	c.reportSampled(result.TaskID, result.Metadata)
+	c.forgetLines(result.TaskID)
+	c.liveLogs.Finish(result.TaskID)
+	c.statusFile.Notify()
+	if result.Success {
+		c.history.record("Task %d completed", result.TaskID)
+	} else {
+		c.history.record("Task %d %s (%s)", result.TaskID, strings.ToLower(status), result.ErrorCode)
 	}
 
-	c.sendStatusUpdate(models.StatusUpdateMessage{
-		Type:   models.TypeStatusUpdate,
-		TaskID: taskID,
-		Status: status,
-	})
+	statusMsg := models.NewStatusUpdate(result.TaskID, status)
+	statusMsg.Metadata = result.Metadata
+	c.sendStatusUpdate(statusMsg)
 
 	// Send TASK_COMPLETED message
-	c.sendTaskCompleted(models.TaskCompletedMessage{
-		Type:    models.TypeTaskCompleted,
-		TaskID:  taskID,
-		Success: success,
-		Error:   errorMsg,
+	completed := models.NewTaskCompleted(result.TaskID, result.Success)
+	completed.ExitCode = result.ExitCode
+	completed.Error = result.Error
+	completed.ErrorCode = result.ErrorCode
+	completed.ErrorDetail = result.ErrorDetail
+	completed.Classification = result.Classification
+	completed.Evidence = result.Evidence
+	completed.Metadata = result.Metadata
+	completed.RequestedBy, completed.Reason = result.TerminatedBy.RequestedBy, result.TerminatedBy.Reason
+	if result.Usage != nil {
+		completed.SetUsage(result.Usage.UserCPU, result.Usage.SystemCPU, result.Usage.MaxRSSKb)
+	}
+	completed.ClaudeResult = result.ClaudeResult
+	completed.OutputBytes, completed.DiscardedOutputBytes = result.OutputBytes, result.DiscardedOutputBytes
+	// The upload runs in the background; the URL is known up front so the report need not wait for it
+	completed.LogURL = c.logUploader.Enqueue(result.TaskID, c.taskLogs.Finish(result.TaskID), time.Now())
+	c.sendTaskCompleted(completed)
+	c.recordFinished(result)
+
+	// Host-local tooling hears about the task after the backend; delivery happens in the background
+	c.webhook.Notify(webhook.Event{
+		TaskID:     result.TaskID,
+		Status:     status,
+		ExitCode:   result.ExitCode,
+		DurationMs: result.Duration.Milliseconds(),
+		Error:      result.Error,
+		ErrorCode:  result.ErrorCode,
+		Metadata:   result.Metadata,
+		FinishedAt: time.Now(),
 	})
 
 	// Update legacy state machine based on pool capacity
@@ -199,144 +528,358 @@ func (c *Client) onTaskComplete(taskID int64, success bool, errorMsg string) {
 	} else {
 		c.stateMachine.SetState(runner.StateBusy)
 	}
+	c.hooks.onTaskComplete(result.TaskID, result.Success)
 }
 
 // sendLogMessage sends a log message to the server
 func (c *Client) sendLogMessage(msg models.LogMessage) {
+	msg.Metadata = c.pool.TaskMetadata(msg.TaskID)
+	// Backends reading only isError still see stderr lines as errors
+	msg.IsError = msg.IsError || msg.Stream == models.StreamStderr
+	c.history.recordLine(msg.TaskID, msg.Line)
+	// Numbered and kept under one lock so line N of the local log is the line sent with index N
+	c.linesMu.Lock()
+	msg.LineIndex = c.nextLine[msg.TaskID]
+	c.nextLine[msg.TaskID]++
+	c.taskLogs.Append(msg.TaskID, msg.Line)
+	send, summary := c.sampleLine(msg)
+	c.linesMu.Unlock()
+	c.liveLogs.Publish(msg.TaskID, msg.Line)
+	if summary != nil {
+		if err := c.sendJSON(summary); err != nil && !errors.Is(err, ErrClientClosed) {
+			log.Printf("Failed to send log message: %v", err)
+		}
+	}
+	if !send {
+		return
+	}
 	log.Printf("[WS] Sending LOG: task=%d, line=%s", msg.TaskID, msg.Line)
-	if err := c.sendJSON(msg); err != nil {
+	if err := c.sendJSON(&msg); err != nil && !errors.Is(err, ErrClientClosed) {
 		log.Printf("Failed to send log message: %v", err)
 	}
 }
 
 // sendStatusUpdate sends a status update to the server
 func (c *Client) sendStatusUpdate(msg models.StatusUpdateMessage) {
-	if err := c.sendJSON(msg); err != nil {
+	if msg.Metadata == nil {
+		msg.Metadata = c.pool.TaskMetadata(msg.TaskID)
+	}
+	if finalStatus(msg.Status) {
+		c.journalMessage(&msg.MessageID, models.TypeStatusUpdate, msg.TaskID, &msg)
+	}
+	if err := c.sendJSON(&msg); err != nil && !errors.Is(err, ErrClientClosed) {
 		log.Printf("Failed to send status update: %v", err)
 	}
 }
 
+// sendToolEvent sends a tool use or result of a task running with JSON output to the server
+func (c *Client) sendToolEvent(msg models.ToolEventMessage) {
+	msg.Metadata = c.pool.TaskMetadata(msg.TaskID)
+	log.Printf("[WS] Sending TOOL_EVENT: task=%d, phase=%s, tool=%s, id=%s", msg.TaskID, msg.Phase, msg.Tool, msg.ToolUseID)
+	if err := c.sendJSON(&msg); err != nil && !errors.Is(err, ErrClientClosed) {
+		log.Printf("Failed to send tool event: %v", err)
+	}
+}
+
 // sendRunnerStatus sends runner state to the server
 func (c *Client) sendRunnerStatus(state runner.RunnerState) {
-	msg := models.RunnerStatusMessage{
-		Type:   models.TypeRunnerStatus,
-		Status: state.String(),
-	}
+	msg := models.NewRunnerStatus(state.String())
+	msg.RunnerID = c.runnerID
 
 	log.Printf("[WS] Sending RUNNER_STATUS: %s", state.String())
-	if err := c.sendJSON(msg); err != nil {
+	if err := c.sendJSON(&msg); err != nil {
 		log.Printf("Failed to send runner status: %v", err)
 	}
 }
 
 // sendCapacityUpdate sends current capacity to the server
 func (c *Client) sendCapacityUpdate(maxParallel, running, available int) {
-	msg := models.RunnerCapacityMessage{
-		Type:           models.TypeRunnerCapacity,
-		MaxParallel:    maxParallel,
-		RunningTasks:   running,
-		AvailableSlots: available,
-	}
+	c.sendCapacity(maxParallel, running, available, nil)
+}
+
+// sendCapacity sends capacity with the slots held by reservations, listing reservations when given
+func (c *Client) sendCapacity(maxParallel, running, available int, reservations []models.Reservation) {
+	msg := models.NewRunnerCapacity(maxParallel, running, available, c.pool.Reserved())
+	msg.Reservations = reservations
+	msg.RunnerID = c.runnerID
+	c.reportPool(maxParallel, running, available)
 
-	log.Printf("[WS] Sending RUNNER_CAPACITY: max=%d, running=%d, available=%d", maxParallel, running, available)
-	if err := c.sendJSON(msg); err != nil {
+	log.Printf("[WS] Sending RUNNER_CAPACITY: max=%d, running=%d, available=%d, reserved=%d", maxParallel, running, available, msg.Reserved)
+	if err := c.sendJSON(&msg); err != nil && !errors.Is(err, ErrClientClosed) {
 		log.Printf("Failed to send runner capacity: %v", err)
 	}
 }
 
 // sendTaskCompleted sends task completion notification to the server
+// Completions of tasks the orphan policy cancelled while the backend was unreachable are held instead
 func (c *Client) sendTaskCompleted(msg models.TaskCompletedMessage) {
+	if c.orphans.Holds(msg.TaskID) {
+		c.heldMu.Lock()
+		c.held = append(c.held, msg)
+		c.heldMu.Unlock()
+		log.Printf("[ORPHAN] Holding TASK_COMPLETED for task %d until the backend is reachable", msg.TaskID)
+		return
+	}
+	c.journalMessage(&msg.MessageID, models.TypeTaskCompleted, msg.TaskID, &msg)
 	log.Printf("[WS] Sending TASK_COMPLETED: task=%d, success=%v", msg.TaskID, msg.Success)
-	if err := c.sendJSON(msg); err != nil {
+	if err := c.sendJSON(&msg); err != nil && !errors.Is(err, ErrClientClosed) {
 		log.Printf("Failed to send task completed: %v", err)
 	}
 }
 
-// sendJSON sends a JSON message to the server
+// replayHeld sends the completions held while the backend was unreachable
+func (c *Client) replayHeld() {
+	c.heldMu.Lock()
+	held := c.held
+	c.held = nil
+	c.heldMu.Unlock()
+	if len(held) > 0 {
+		log.Printf("[ORPHAN] Replaying %d held task completions", len(held))
+	}
+	for _, msg := range held {
+		c.sendTaskCompleted(msg)
+	}
+}
+
+// handleHeloAck applies the schema version negotiated by the server
+func (c *Client) handleHeloAck(msg models.HeloAckMessage) {
+	version := negotiatedVersion(msg)
+	c.connMutex.Lock()
+	c.schema = version
+	c.connMutex.Unlock()
+}
+
+// SchemaVersion returns the schema version negotiated with the backend, which newer messages can be gated on
+func (c *Client) SchemaVersion() int {
+	c.connMutex.Lock()
+	defer c.connMutex.Unlock()
+	return c.schema
+}
+
+// reportTooLarge tells the backend, once reconnected, that the last connection was dropped over a message
+// larger than MaxMessageBytes; it could not say so on the connection itself
+func (c *Client) reportTooLarge() {
+	if !c.tooLarge.Swap(false) {
+		return
+	}
+	err := fmt.Errorf("previous connection dropped: a message exceeded the %d-byte limit: %w", c.cfg.MaxMessageBytes, models.ErrMessageTooLarge)
+	c.sendMessageError(models.NewMessageError(nil, err))
+}
+
+// sendMessageError tells the server an incoming message was rejected
+func (c *Client) sendMessageError(msg models.MessageErrorMessage) {
+	log.Printf("[WS] Sending MESSAGE_ERROR: type=%s, task=%d, code=%s", msg.MessageType, msg.TaskID, msg.Code)
+	if err := c.sendJSON(&msg); err != nil {
+		log.Printf("Failed to send message error: %v", err)
+	}
+}
+
+// sendJSON queues a JSON message for the server without waiting for the network (see writeLoop)
+// Oversized free-text fields are truncated here so no producer can emit a frame the backend refuses.
+// It only fails once the client is closed, with ErrClientClosed; write errors are logged by the writer.
 func (c *Client) sendJSON(v interface{}) error {
+	if c.closed.Load() {
+		c.discard(v)
+		return ErrClientClosed
+	}
+	var cut []string
+	if tm, ok := v.(models.Truncatable); ok {
+		cut = tm.TruncateFields(c.cfg.FieldLimits())
+	}
+
+	if c.cfg.ValidateOutgoing {
+		if vm, ok := v.(interface{ Validate() error }); ok {
+			if err := vm.Validate(); err != nil {
+				log.Printf("[WS] Outgoing message failed validation: %v (%+v)", err, v)
+			}
+		}
+	}
+
+	c.connMutex.Lock()
+	for _, field := range cut {
+		c.truncated[field]++
+		log.Printf("[WS] Truncated oversized %s field on outbound %T", field, v)
+	}
+	gen := c.generation
+	c.connMutex.Unlock()
+	err := c.enqueue(outgoing{msg: v, gen: gen})
+	if errors.Is(err, ErrClientClosed) {
+		c.discard(v)
+	}
+	return err
+}
+
+// discard counts a message sent after Close, logging only the first: tasks stopping as the client
+// closes keep calling back with output and status that can no longer go anywhere
+func (c *Client) discard(v interface{}) {
+	if c.discarded.Add(1) == 1 {
+		log.Printf("[WS] Client closed: discarding %T and any later outbound messages", v)
+	}
+}
+
+// noteError remembers err as the most recent error
+func (c *Client) noteError(err error) {
+	c.lastErrMu.Lock()
+	defer c.lastErrMu.Unlock()
+	c.lastErr, c.lastErrAt = err.Error(), time.Now()
+}
+
+// LastError returns the most recent connection or send error and when it happened ("" if none)
+func (c *Client) LastError() (string, time.Time) {
+	c.lastErrMu.Lock()
+	defer c.lastErrMu.Unlock()
+	return c.lastErr, c.lastErrAt
+}
+
+// TruncationStats returns how many outbound fields were truncated, by field name
+func (c *Client) TruncationStats() map[string]int64 {
 	c.connMutex.Lock()
 	defer c.connMutex.Unlock()
-	c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-	return c.conn.WriteJSON(v)
+	stats := make(map[string]int64, len(c.truncated))
+	for field, n := range c.truncated {
+		stats[field] = n
+	}
+	return stats
+}
+
+// Connected reports whether the WebSocket is currently connected to the backend
+func (c *Client) Connected() bool {
+	return c.connected.Load()
+}
+
+// PoolRunning reports whether the executor pool's workers are running
+func (c *Client) PoolRunning() bool {
+	return c.pool.Running()
+}
+
+// RunningTasks returns the number of tasks currently executing
+func (c *Client) RunningTasks() int {
+	_, running, _ := c.pool.GetCapacity()
+	return running
 }
 
+// Ping returns once the messages queued so far have been written; it blocks for as long as a write is stuck
+func (c *Client) Ping() {
+	c.flush()
+}
+
+// webhookFlushTimeout bounds how long Close waits for completion webhooks still being delivered
+var webhookFlushTimeout = 3 * time.Second
+
+// taskLogFlushTimeout bounds how long Close waits for task log uploads; unfinished logs stay on disk
+var taskLogFlushTimeout = 10 * time.Second
+
 // Close closes the WebSocket connection and stops the executor pool
 func (c *Client) Close() error {
+	c.closeOnce.Do(func() { close(c.closing) })
+	// Suspended tasks have to run again to finish or be cancelled
+	c.orphans.Close()
 	// Stop the executor pool
 	if c.pool != nil {
 		c.pool.Stop()
 	}
-	return c.conn.Close()
+	if !c.webhook.Flush(webhookFlushTimeout) {
+		log.Printf("[WEBHOOK] Completion webhooks still pending at exit were dropped")
+	}
+	if !c.logUploader.Close(taskLogFlushTimeout) {
+		log.Printf("[TASKLOG] Task log uploads still pending at exit were left in %s", tasklog.DirName)
+	}
+	// Completions of the tasks stopped above are queued by now; GOODBYE goes out after them
+	c.sendGoodbye()
+	c.stopWriting()
+	c.connMutex.Lock()
+	conn, r := c.conn, c.reader
+	c.connMutex.Unlock()
+	if conn == nil { // Never connected
+		c.connected.Store(false)
+		return nil
+	}
+	err := c.closeConnection(conn, r)
+	c.connected.Store(false)
+	return err
 }
 
-// handleCancelTask processes a CANCEL_TASK command from the server
-func (c *Client) handleCancelTask(msg models.CancelTaskMessage) {
-	log.Printf("[WS] Received CANCEL_TASK for task %d", msg.TaskID)
+// handleCancelTask processes a CANCEL_TASK command from the server (or the admin API)
+func (c *Client) handleCancelTask(msg models.CancelTaskMessage) error {
+	by := attribution(msg.RequestedBy, msg.Reason)
+	log.Printf("[WS] Received CANCEL_TASK for task %d from %s", msg.TaskID, by.RequestedBy)
+	c.history.record("Cancel of task %d requested by %s", msg.TaskID, describe(by))
 
-	err := c.pool.CancelTask(msg.TaskID)
-	c.sendCancelAck(msg.TaskID, models.StatusCancelled, err == nil, errorToString(err))
+	err := c.pool.CancelTask(msg.TaskID, by)
+	c.sendCancelAck(msg.TaskID, models.StatusCancelled, err == nil, errorToString(err), by)
 
 	// Send status update if cancellation was successful
 	if err == nil {
-		c.sendStatusUpdate(models.StatusUpdateMessage{
-			Type:   models.TypeStatusUpdate,
-			TaskID: msg.TaskID,
-			Status: models.StatusCancelled,
-		})
+		c.sendStatusUpdate(models.NewStatusUpdate(msg.TaskID, models.StatusCancelled))
 	}
+	return err
 }
 
-// handleKillTask processes a KILL_TASK command from the server
-func (c *Client) handleKillTask(msg models.KillTaskMessage) {
-	log.Printf("[WS] Received KILL_TASK for task %d", msg.TaskID)
-
-	err := c.pool.ForceKillTask(msg.TaskID)
-
-	// Send legacy CANCEL_ACK for backward compatibility
-	c.sendCancelAck(msg.TaskID, "KILLED", err == nil, errorToString(err))
+// handleKillTask processes a KILL_TASK command from the server (or the admin API)
+// Messages for a kill are sent in a fixed order:
+//  1. CANCEL_ACK (legacy) as soon as the kill signal is delivered
+//  2. STATUS_UPDATE CANCELLED, if the signal was delivered
+//  3. TASK_TERMINATED once the task has reported completion and its process group is verified dead
+//
+// A duplicate KILL for an already terminated task gets steps 1-2 only, never a second TASK_TERMINATED
+func (c *Client) handleKillTask(msg models.KillTaskMessage) executor.Termination {
+	by := attribution(msg.RequestedBy, msg.Reason)
+	log.Printf("[WS] Received KILL_TASK for task %d from %s", msg.TaskID, by.RequestedBy)
+	c.history.record("Kill of task %d requested by %s", msg.TaskID, describe(by))
+
+	term := c.pool.TerminateTask(msg.TaskID, by, func(err error) {
+		// Send legacy CANCEL_ACK for backward compatibility
+		c.sendCancelAck(msg.TaskID, "KILLED", err == nil, errorToString(err), by)
+
+		// Send status update if kill was successful
+		if err == nil {
+			c.sendStatusUpdate(models.NewStatusUpdate(msg.TaskID, models.StatusCancelled))
+		}
+	})
+	if term.Duplicate {
+		return term
+	}
 
-	// Send new TASK_TERMINATED ACK for safe deletion protocol
-	c.sendTaskTerminated(msg.TaskID, err == nil, errorToString(err))
+	// Send TASK_TERMINATED ACK for safe deletion protocol
+	c.sendTaskTerminated(msg.TaskID, term.Success(), term.ErrorString(), by)
+	return term
+}
 
-	// Send status update if kill was successful
-	if err == nil {
-		c.sendStatusUpdate(models.StatusUpdateMessage{
-			Type:   models.TypeStatusUpdate,
-			TaskID: msg.TaskID,
-			Status: models.StatusCancelled,
-		})
+// attribution says who asked for a cancel or kill; requests without a requestedBy come from the backend itself
+func attribution(requestedBy, reason string) executor.Attribution {
+	if requestedBy == "" {
+		requestedBy = models.RequestedByBackend
 	}
+	return executor.Attribution{RequestedBy: requestedBy, Reason: reason}
 }
 
-// sendCancelAck sends acknowledgment of cancel/kill request
-func (c *Client) sendCancelAck(taskID int64, status string, success bool, errMsg string) {
-	ack := models.CancelAckMessage{
-		Type:    models.TypeCancelAck,
-		TaskID:  taskID,
-		Status:  status,
-		Success: success,
-		Error:   errMsg,
+// describe formats an attribution for the event history: "alice (wrong branch)"
+func describe(by executor.Attribution) string {
+	if by.Reason == "" {
+		return by.RequestedBy
 	}
+	return fmt.Sprintf("%s (%s)", by.RequestedBy, by.Reason)
+}
+
+// sendCancelAck sends acknowledgment of cancel/kill request, echoing who asked for it
+func (c *Client) sendCancelAck(taskID int64, status string, success bool, errMsg string, by executor.Attribution) {
+	ack := models.NewCancelAck(taskID, status, success, errMsg)
+	ack.RequestedBy, ack.Reason = by.RequestedBy, by.Reason
 
 	log.Printf("[WS] Sending CANCEL_ACK: task=%d, status=%s, success=%v", taskID, status, success)
-	if err := c.sendJSON(ack); err != nil {
+	if err := c.sendJSON(&ack); err != nil {
 		log.Printf("Failed to send cancel ack: %v", err)
 	}
 }
 
 // sendTaskTerminated sends TASK_TERMINATED acknowledgment for safe deletion protocol
 // Backend waits for this ACK before soft-deleting the task record
-func (c *Client) sendTaskTerminated(taskID int64, success bool, errMsg string) {
-	ack := models.TaskTerminatedMessage{
-		Type:    models.TypeTaskTerminated,
-		TaskID:  taskID,
-		Status:  "KILLED",
-		Success: success,
-		Error:   errMsg,
-	}
+func (c *Client) sendTaskTerminated(taskID int64, success bool, errMsg string, by executor.Attribution) {
+	ack := models.NewTaskTerminated(taskID, success, errMsg)
+	ack.RequestedBy, ack.Reason = by.RequestedBy, by.Reason
 
 	log.Printf("[WS] Sending TASK_TERMINATED ACK: task=%d, success=%v", taskID, success)
-	if err := c.sendJSON(ack); err != nil {
+	if err := c.sendJSON(&ack); err != nil {
 		log.Printf("Failed to send task terminated ack: %v", err)
 	}
 }
@@ -2,12 +2,20 @@ package websocket
 
 import (
 	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/berno/aaw-runner/internal/config"
+	"github.com/berno/aaw-runner/internal/health"
 	"github.com/berno/aaw-runner/internal/models"
 	"github.com/berno/aaw-runner/internal/runner"
+	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -180,6 +188,20 @@ func TestSendTaskCompleted_IncludesAllFields(t *testing.T) {
 	}
 }
 
+// TestTaskCompleted_ExitCode verifies TASK_COMPLETED carries the exit code of the task's process, and -1 for
+// a task rejected before it ran
+func TestTaskCompleted_ExitCode(t *testing.T) {
+	client, frames := startTaskClient(t, "exit 3")
+	got := receiveUntil(t, frames, models.TypeTaskCompleted)
+	assert.Equal(t, 3, got[len(got)-1].ExitCode)
+	assert.Equal(t, models.ErrorCodeExitNonzero, got[len(got)-1].ErrorCode)
+
+	client.pool.Drain()
+	client.handleExecute(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 32, ScriptContent: "hello"})
+	got = receiveUntil(t, frames, models.TypeTaskCompleted)
+	assert.Equal(t, -1, got[len(got)-1].ExitCode)
+}
+
 // TestHandleExecute_TransitionsToIdleAfterCompletion verifies state transitions during task execution
 func TestHandleExecute_TransitionsToIdleAfterCompletion(t *testing.T) {
 	// This test verifies the state machine behavior during handleExecute
@@ -335,6 +357,37 @@ func TestSendLogMessage_FormatsCorrectly(t *testing.T) {
 	}
 }
 
+// TestSendLogMessage_DerivesIsError verifies a stderr line goes out with isError set even when the sender
+// only gave its stream, so backends reading isError alone keep working
+func TestSendLogMessage_DerivesIsError(t *testing.T) {
+	cfg, frames := startBackend(t)
+	client := NewClient(cfg)
+	assert.NoError(t, client.Connect())
+	defer client.Close()
+	receiveUntil(t, frames, models.TypeRunnerCapacity)
+
+	client.sendLogMessage(models.LogMessage{Type: models.TypeLog, TaskID: 3, Line: "warning", Stream: models.StreamStderr})
+	client.sendLogMessage(models.NewOutputLog(3, "fine", false))
+
+	var lines []models.LogMessage
+	for len(lines) < 2 {
+		select {
+		case data := <-frames:
+			var msg models.LogMessage
+			assert.NoError(t, json.Unmarshal(data, &msg))
+			if msg.Type == models.TypeLog {
+				lines = append(lines, msg)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only %d of 2 lines arrived", len(lines))
+		}
+	}
+	assert.Equal(t, models.StreamStderr, lines[0].Stream)
+	assert.True(t, lines[0].IsError)
+	assert.Equal(t, models.StreamStdout, lines[1].Stream)
+	assert.False(t, lines[1].IsError)
+}
+
 // TestSendStatusUpdate_FormatsCorrectly verifies status update message formatting
 func TestSendStatusUpdate_FormatsCorrectly(t *testing.T) {
 	tests := []struct {
@@ -386,7 +439,9 @@ func TestSendStatusUpdate_FormatsCorrectly(t *testing.T) {
 
 // TestNewClient_InitializesStateMachine verifies client initialization
 func TestNewClient_InitializesStateMachine(t *testing.T) {
-	client := NewClient("ws://localhost:8080/ws")
+	cfg := config.Default()
+	cfg.BackendURL = "ws://localhost:8080/ws"
+	client := NewClient(cfg)
 
 	assert.NotNil(t, client, "Client should not be nil")
 	assert.NotNil(t, client.stateMachine, "State machine should be initialized")
@@ -489,3 +544,175 @@ func TestStateMachineCallback_Integration(t *testing.T) {
 	messages := mockConn.getSentMessages()
 	assert.Equal(t, 2, len(messages), "Should send 2 RUNNER_STATUS messages")
 }
+
+// testSubprotocols makes the test backends select the subprotocol the runner requests by default
+var testSubprotocols = []string{config.DefaultWSSubprotocols}
+
+// startBackend runs a WebSocket server that records every frame it receives
+// and returns a config pointing the client at it
+func startBackend(t *testing.T) (config.Config, chan []byte) {
+	t.Helper()
+	frames := make(chan []byte, 64)
+	cfg, _ := startBackendWith(t, backendHooks{frames: frames})
+	return cfg, frames
+}
+
+// backendHooks adapt the backend startBackendWith runs to a test; the zero value acknowledges HELO and
+// ignores every other frame
+type backendHooks struct {
+	upgrade  func(w http.ResponseWriter, r *http.Request) (*websocket.Conn, error) // Replaces an Upgrader selecting testSubprotocols
+	listener func(net.Listener) net.Listener                                       // Wraps the server's listener
+
+	noHeloAck bool                                                // Leaves HELO unanswered, for onFrame to answer or not
+	frames    chan<- []byte                                       // Receives every frame, when set
+	onConnect func(conn *websocket.Conn) bool                     // Sees each connection before it is read; false drops it
+	onFrame   func(conn *websocket.Conn, n int, data []byte) bool // Sees every frame of connection n (1 for the first); false drops it
+	onClose   func(n int, err error)                              // Gets the error that ended reading connection n
+}
+
+// startBackendWith runs a WebSocket server that passes every frame it receives to hooks, and returns a
+// config pointing the client at it
+func startBackendWith(tb testing.TB, hooks backendHooks) (config.Config, *httptest.Server) {
+	tb.Helper()
+	var conns atomic.Int32
+	upgrade := hooks.upgrade
+	if upgrade == nil {
+		upgrader := websocket.Upgrader{Subprotocols: testSubprotocols}
+		upgrade = func(w http.ResponseWriter, r *http.Request) (*websocket.Conn, error) {
+			return upgrader.Upgrade(w, r, nil)
+		}
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if hooks.onConnect != nil && !hooks.onConnect(conn) {
+			return
+		}
+		n := int(conns.Add(1))
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				if hooks.onClose != nil {
+					hooks.onClose(n, err)
+				}
+				return
+			}
+			if !hooks.noHeloAck {
+				ackHelo(conn, data)
+			}
+			if hooks.frames != nil {
+				hooks.frames <- data
+			}
+			if hooks.onFrame != nil && !hooks.onFrame(conn, n, data) {
+				return
+			}
+		}
+	}))
+	if hooks.listener != nil {
+		server.Listener = hooks.listener(server.Listener)
+	}
+	server.Start()
+	tb.Cleanup(server.Close)
+
+	cfg := config.Default()
+	cfg.BackendURL = "ws" + strings.TrimPrefix(server.URL, "http")
+	return cfg, server
+}
+
+// ackHelo answers a HELO with the HELO_ACK of a backend on the runner's newest schema version
+func ackHelo(conn *websocket.Conn, data []byte) {
+	var header struct {
+		Type string `json:"type"`
+	}
+	if json.Unmarshal(data, &header) == nil && header.Type == models.TypeHelo {
+		conn.WriteJSON(models.HeloAckMessage{Type: models.TypeHeloAck, ProtocolVersion: models.SchemaVersion})
+	}
+}
+
+// TestSendJSON_TruncatesOversizedFields verifies oversized free-text fields are cut in the send path and counted
+func TestSendJSON_TruncatesOversizedFields(t *testing.T) {
+	cfg, frames := startBackend(t)
+	client := NewClient(cfg)
+	assert.NoError(t, client.Connect())
+	defer client.Close()
+
+	completed := models.NewTaskCompleted(9, false)
+	completed.Error = strings.Repeat("e", models.DefaultMaxErrorBytes+100)
+	client.sendTaskCompleted(completed)
+
+	var got models.TaskCompletedMessage
+	for got.Type != models.TypeTaskCompleted {
+		select {
+		case data := <-frames:
+			assert.NoError(t, json.Unmarshal(data, &got))
+		case <-time.After(2 * time.Second):
+			t.Fatal("TASK_COMPLETED was not sent")
+		}
+	}
+	assert.True(t, strings.HasSuffix(got.Error, "e…[truncated 100 bytes]"), "Marker should follow the kept text")
+	assert.Len(t, got.Error, models.DefaultMaxErrorBytes+len("…[truncated 100 bytes]"))
+	assert.Equal(t, map[string]int64{"error": 1}, client.TruncationStats())
+}
+
+// TestReadiness_TracksConnection verifies /readyz follows the client's connection and pool state
+func TestReadiness_TracksConnection(t *testing.T) {
+	cfg, _ := startBackend(t)
+	cfg.ReconnectBaseBackoff = time.Hour // Stay disconnected
+	client := NewClient(cfg)
+	probes := health.NewServer("127.0.0.1:0", "claude", client).Handler()
+
+	readyz := func() health.Report {
+		rec := httptest.NewRecorder()
+		probes.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		var report health.Report
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+		return report
+	}
+
+	assert.Contains(t, readyz().Failures, "websocket not connected")
+	assert.Contains(t, readyz().Failures, "executor pool not running")
+
+	assert.NoError(t, client.Connect())
+	listenDone := make(chan error, 1)
+	go func() { listenDone <- client.Listen() }()
+
+	assert.True(t, client.Connected())
+	assert.True(t, client.PoolRunning())
+	assert.NotContains(t, readyz().Failures, "websocket not connected")
+	assert.NotContains(t, readyz().Failures, "executor pool not running")
+
+	// Drop the connection: the read loop ends and the runner is not ready until it reconnects
+	client.conn.Close()
+	assert.Eventually(t, func() bool { return !client.Connected() }, 5*time.Second, 10*time.Millisecond)
+	assert.Contains(t, readyz().Failures, "websocket not connected")
+	assert.True(t, client.PoolRunning(), "The pool keeps running while the runner reconnects")
+
+	client.Close()
+	<-listenDone
+	assert.False(t, client.PoolRunning())
+}
+
+// TestConnect_SendsRunnerID verifies the runner ID is in HELO, RUNNER_STATUS and RUNNER_CAPACITY on every connect
+func TestConnect_SendsRunnerID(t *testing.T) {
+	cfg, frames := startBackend(t)
+	client := NewClient(cfg)
+	client.SetRunnerID("0b5c6a8e-4b8e-4f4c-9a59-3f2d1c0e7a21")
+	t.Cleanup(func() { client.Close() })
+
+	for connect := 1; connect <= 2; connect++ {
+		assert.NoError(t, client.Connect())
+		for _, typ := range []string{models.TypeHelo, models.TypeRunnerStatus, models.TypeRunnerCapacity} {
+			var msg struct {
+				Type     string `json:"type"`
+				RunnerID string `json:"runnerId"`
+			}
+			assert.NoError(t, json.Unmarshal(<-frames, &msg))
+			assert.Equal(t, typ, msg.Type, "connect %d", connect)
+			assert.Equal(t, "0b5c6a8e-4b8e-4f4c-9a59-3f2d1c0e7a21", msg.RunnerID, "%s on connect %d", typ, connect)
+		}
+		client.conn.Close()
+	}
+}
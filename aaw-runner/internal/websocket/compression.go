@@ -0,0 +1,26 @@
+package websocket
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// setCompression sets conn's compression level when the backend accepted the permessage-deflate
+// offered with WSCompression (see newDialer); a backend that declines it is sent uncompressed frames
+func (c *Client) setCompression(conn *websocket.Conn, resp *http.Response) {
+	if !c.cfg.WSCompression {
+		return
+	}
+	if resp == nil || !strings.Contains(resp.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate") {
+		log.Printf("[WS] Backend declined permessage-deflate, sending uncompressed")
+		return
+	}
+	if err := conn.SetCompressionLevel(c.cfg.WSCompressionLevel); err != nil {
+		log.Printf("[WS] Failed to set compression level %d: %v", c.cfg.WSCompressionLevel, err)
+		return
+	}
+	log.Printf("[WS] permessage-deflate negotiated (level %d)", c.cfg.WSCompressionLevel)
+}
@@ -0,0 +1,106 @@
+package websocket
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/config"
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingListener counts the bytes read from the connections it accepts
+type countingListener struct {
+	net.Listener
+	read *atomic.Int64
+}
+
+func (l countingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	return countingConn{conn, l.read}, err
+}
+
+type countingConn struct {
+	net.Conn
+	read *atomic.Int64
+}
+
+func (c countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.read.Add(int64(n))
+	return n, err
+}
+
+// startCompressingBackend is startBackend with permessage-deflate enabled as given, also reporting the
+// extensions the runner offered and counting the bytes it sent
+func startCompressingBackend(t *testing.T, enable bool) (config.Config, chan []byte, chan string, *atomic.Int64) {
+	t.Helper()
+	frames := make(chan []byte, 64)
+	offers := make(chan string, 1)
+	read := &atomic.Int64{}
+	upgrader := websocket.Upgrader{EnableCompression: enable, Subprotocols: testSubprotocols}
+	cfg, _ := startBackendWith(t, backendHooks{
+		upgrade: func(w http.ResponseWriter, r *http.Request) (*websocket.Conn, error) {
+			offers <- r.Header.Get("Sec-WebSocket-Extensions")
+			return upgrader.Upgrade(w, r, nil)
+		},
+		listener: func(l net.Listener) net.Listener { return countingListener{l, read} },
+		frames:   frames,
+	})
+	cfg.WSCompression = true
+	return cfg, frames, offers, read
+}
+
+// TestConnect_Compression verifies WSCompression offers permessage-deflate and compresses what is sent once
+// the backend accepts it, while a backend that declines still gets every message, uncompressed
+func TestConnect_Compression(t *testing.T) {
+	for name, accept := range map[string]bool{"accepted": true, "declined": false} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			cfg, frames, offers, read := startCompressingBackend(t, accept)
+			cfg.WSCompressionLevel = 9
+			client := NewClient(cfg)
+			t.Cleanup(func() { client.Close() })
+			assert.NoError(t, client.Connect())
+			assert.Contains(t, <-offers, "permessage-deflate")
+
+			// Wait for the connect sequence, so only the message below is counted
+			receiveUntil(t, frames, models.TypeRunnerCapacity)
+			before := read.Load()
+			completed := models.NewTaskCompleted(3, false)
+			completed.Error = strings.Repeat("error: something went wrong\n", 100)
+			client.sendTaskCompleted(completed)
+
+			var got models.TaskCompletedMessage
+			select {
+			case data := <-frames:
+				assert.NoError(t, json.Unmarshal(data, &got))
+			case <-time.After(2 * time.Second):
+				t.Fatal("TASK_COMPLETED was not sent")
+			}
+			assert.Equal(t, completed.Error, got.Error)
+			sent := read.Load() - before
+			if accept {
+				assert.Less(t, sent, int64(len(completed.Error)/10), "Compressed on the wire")
+			} else {
+				assert.Greater(t, sent, int64(len(completed.Error)), "Sent as is")
+			}
+		})
+	}
+}
+
+// TestConnect_CompressionOff verifies permessage-deflate is not offered unless WSCompression is set
+func TestConnect_CompressionOff(t *testing.T) {
+	cfg, _, offers, _ := startCompressingBackend(t, true)
+	cfg.WSCompression = false
+	client := NewClient(cfg)
+	t.Cleanup(func() { client.Close() })
+	assert.NoError(t, client.Connect())
+	assert.Empty(t, <-offers)
+}
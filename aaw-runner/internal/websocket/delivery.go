@@ -0,0 +1,95 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/berno/aaw-runner/internal/journal"
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/google/uuid"
+)
+
+// SetJournal keeps TASK_COMPLETED and final STATUS_UPDATE messages in j until the backend ACKs them,
+// re-sending them on every connect; without it (or with nil) they are sent once
+// Must be called before Connect.
+func (c *Client) SetJournal(j *journal.Journal) {
+	c.journal = j
+}
+
+// finalStatus reports whether a STATUS_UPDATE ends its task, and so must reach the backend
+func finalStatus(status string) bool {
+	switch status {
+	case models.StatusCompleted, models.StatusFailed, models.StatusCancelled:
+		return true
+	}
+	return false
+}
+
+// journalMessage gives msg (a pointer to a message whose MessageID field is id) a message ID and
+// writes it to the journal before it is sent. Without a journal, or when the write fails, msg goes
+// out without an ID and is sent once.
+func (c *Client) journalMessage(id *string, msgType string, taskID int64, msg interface{}) {
+	if c.journal == nil {
+		return
+	}
+	*id = uuid.NewString()
+	payload, err := json.Marshal(msg)
+	if err == nil {
+		err = c.journal.Append(journal.Entry{ID: *id, Type: msgType, TaskID: taskID, Payload: payload})
+	}
+	if err != nil {
+		log.Printf("[JOURNAL] Failed to journal %s of task %d, sending it once: %v", msgType, taskID, err)
+		*id = ""
+	}
+}
+
+// journaled reports whether msg is in the journal, which re-sends it on connect
+func journaled(msg interface{}) bool {
+	switch msg := msg.(type) {
+	case *models.TaskCompletedMessage:
+		return msg.MessageID != ""
+	case *models.StatusUpdateMessage:
+		return msg.MessageID != ""
+	}
+	return false
+}
+
+// resendUnacked sends again the journaled messages the backend has not acknowledged, oldest first
+func (c *Client) resendUnacked() {
+	pending := c.journal.Pending()
+	if len(pending) == 0 {
+		return
+	}
+	log.Printf("[JOURNAL] Re-sending %d unacknowledged messages", len(pending))
+	for _, e := range pending {
+		var msg interface{}
+		switch e.Type {
+		case models.TypeTaskCompleted:
+			msg = &models.TaskCompletedMessage{}
+		case models.TypeStatusUpdate:
+			msg = &models.StatusUpdateMessage{}
+		default:
+			log.Printf("[JOURNAL] Skipping %s (%s): not a journaled type", e.Type, e.ID)
+			continue
+		}
+		if err := json.Unmarshal(e.Payload, msg); err != nil {
+			log.Printf("[JOURNAL] Skipping unreadable %s of task %d (%s): %v", e.Type, e.TaskID, e.ID, err)
+			continue
+		}
+		if err := c.sendJSON(msg); err != nil {
+			log.Printf("[JOURNAL] Failed to re-send %s of task %d: %v", e.Type, e.TaskID, err)
+			return
+		}
+	}
+}
+
+// handleAck drops the acknowledged message from the journal
+func (c *Client) handleAck(msg models.AckMessage) {
+	acked, err := c.journal.Ack(msg.MessageID)
+	if err != nil {
+		log.Printf("[JOURNAL] Failed to record ACK of %s: %v", msg.MessageID, err)
+	}
+	if !acked {
+		log.Printf("[JOURNAL] ACK for unknown message %s (already acknowledged, or not journaled)", msg.MessageID)
+	}
+}
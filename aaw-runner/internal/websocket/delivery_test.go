@@ -0,0 +1,131 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/config"
+	"github.com/berno/aaw-runner/internal/journal"
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// startAckingBackend is startBackend, answering every message that carries a messageId with an ACK when ack is set
+func startAckingBackend(t *testing.T, ack bool) (config.Config, chan []byte) {
+	t.Helper()
+	frames := make(chan []byte, 64)
+	cfg, _ := startBackendWith(t, backendHooks{onFrame: func(conn *websocket.Conn, _ int, data []byte) bool {
+		var f frame
+		if ack && json.Unmarshal(data, &f) == nil && f.MessageID != "" {
+			conn.WriteJSON(models.AckMessage{Type: models.TypeAck, MessageID: f.MessageID})
+		}
+		frames <- data
+		return true
+	}})
+	return cfg, frames
+}
+
+// connectJournaled connects a client to cfg's backend, journaling to the journal in dir
+func connectJournaled(t *testing.T, cfg config.Config, dir string) (*Client, *journal.Journal) {
+	t.Helper()
+	j, err := journal.Open(dir, 10)
+	assert.NoError(t, err)
+	client := NewClient(cfg)
+	client.SetJournal(j)
+	listenDone := make(chan error, 1)
+	assert.NoError(t, client.Connect())
+	go func() { listenDone <- client.Listen() }()
+	t.Cleanup(func() {
+		client.Close()
+		<-listenDone
+		j.Close()
+	})
+	return client, j
+}
+
+// TestJournal_ResentAfterCrash verifies completions the backend never acknowledged are re-sent, with the
+// same messageId, by a runner restarted on the same state dir, and not again once acknowledged
+func TestJournal_ResentAfterCrash(t *testing.T) {
+	dir := t.TempDir()
+	cfg, frames := startAckingBackend(t, false)
+	client, _ := connectJournaled(t, cfg, dir)
+	receiveUntil(t, frames, models.TypeRunnerCapacity)
+
+	client.sendStatusUpdate(models.NewStatusUpdate(6, models.StatusRunning))
+	client.sendStatusUpdate(models.NewStatusUpdate(6, models.StatusFailed))
+	client.sendTaskCompleted(models.NewTaskCompleted(6, false))
+	sent := receiveUntil(t, frames, models.TypeTaskCompleted)
+	assert.Len(t, sent, 3)
+	assert.Empty(t, sent[0].MessageID, "Only final statuses are journaled")
+	assert.NotEmpty(t, sent[1].MessageID)
+	assert.NotEmpty(t, sent[2].MessageID)
+
+	// The runner dies before any ACK; the next one finds both messages in the journal
+	cfg, frames = startAckingBackend(t, true)
+	_, j := connectJournaled(t, cfg, dir)
+	resent := receiveUntil(t, frames, models.TypeTaskCompleted)
+	assert.Equal(t, models.TypeStatusUpdate, resent[len(resent)-2].Type)
+	assert.Equal(t, sent[1].MessageID, resent[len(resent)-2].MessageID)
+	assert.Equal(t, sent[2].MessageID, resent[len(resent)-1].MessageID)
+	assert.Eventually(t, func() bool { return len(j.Pending()) == 0 }, 2*time.Second, 10*time.Millisecond)
+
+	// Acknowledged: a later restart has nothing to re-send
+	cfg, frames = startAckingBackend(t, true)
+	client, _ = connectJournaled(t, cfg, dir)
+	receiveUntil(t, frames, models.TypeRunnerCapacity)
+	client.sendRunnerStatus(client.stateMachine.GetState())
+	for _, f := range receiveUntil(t, frames, models.TypeRunnerStatus) {
+		assert.NotEqual(t, models.TypeTaskCompleted, f.Type)
+	}
+}
+
+// TestJournal_ResentAfterReconnect verifies a completion sent while the backend is unreachable is
+// re-sent from the journal once reconnected, not also from the offline buffer
+func TestJournal_ResentAfterReconnect(t *testing.T) {
+	drop := make(chan struct{})
+	cfg, frames, _ := startDroppingBackend(t, drop)
+	cfg.ReconnectBaseBackoff = 300 * time.Millisecond // Time to send while disconnected
+	cfg.ReconnectMaxBackoff = 300 * time.Millisecond
+	j, err := journal.Open(t.TempDir(), 10)
+	assert.NoError(t, err)
+	defer j.Close()
+	client := NewClient(cfg)
+	client.SetJournal(j)
+	assert.NoError(t, client.Connect())
+	listenDone := make(chan error, 1)
+	go func() { listenDone <- client.Listen() }()
+	t.Cleanup(func() {
+		client.Close()
+		<-listenDone
+	})
+
+	close(drop)
+	assert.Eventually(t, func() bool { return !client.Connected() }, 5*time.Second, time.Millisecond)
+	client.sendTaskCompleted(models.NewTaskCompleted(8, true))
+
+	var completions []frame
+	deadline := time.After(5 * time.Second)
+	for len(completions) == 0 {
+		select {
+		case cf := <-frames:
+			if cf.Type == models.TypeTaskCompleted {
+				assert.Equal(t, 2, cf.conn)
+				completions = append(completions, cf.frame)
+			}
+		case <-deadline:
+			t.Fatal("TASK_COMPLETED was not re-sent")
+		}
+	}
+	client.flush()
+	for drained := false; !drained; {
+		select {
+		case cf := <-frames:
+			assert.NotEqual(t, models.TypeTaskCompleted, cf.Type, "Sent once")
+		case <-time.After(200 * time.Millisecond):
+			drained = true
+		}
+	}
+	assert.Equal(t, j.Pending()[0].ID, completions[0].MessageID)
+}
@@ -0,0 +1,57 @@
+package websocket
+
+import (
+	"log"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/gorilla/websocket"
+)
+
+// closeHandshakeTimeout bounds how long Close waits for the backend to answer its close frame
+// A variable so tests can shorten it.
+var closeHandshakeTimeout = 2 * time.Second
+
+// sendGoodbye queues the GOODBYE that ends the connection, listing the tasks the pool still holds
+func (c *Client) sendGoodbye() {
+	if !c.connected.Load() {
+		return
+	}
+	reason := models.GoodbyeClosed
+	switch {
+	case c.forced.Load():
+		reason = models.GoodbyeForced
+	case c.shuttingDown.Load():
+		reason = models.GoodbyeShutdown
+	}
+	var unfinished []int64
+	if c.pool != nil {
+		for _, task := range c.pool.Tasks() {
+			unfinished = append(unfinished, task.TaskID)
+		}
+	}
+	log.Printf("[WS] Sending GOODBYE (%s, %d tasks unfinished)", reason, len(unfinished))
+	if err := c.sendJSON(models.NewGoodbye(reason, unfinished)); err != nil {
+		log.Printf("[WS] Failed to send GOODBYE: %v", err)
+	}
+}
+
+// closeConnection ends conn, read by r, with a close handshake: it sends a normal closure close frame and
+// waits up to closeHandshakeTimeout for the backend's, so the backend sees a clean close rather than a reset
+func (c *Client) closeConnection(conn *websocket.Conn, r *reader) error {
+	deadline := time.Now().Add(closeHandshakeTimeout)
+	frame := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "runner closing")
+	if err := conn.WriteControl(websocket.CloseMessage, frame, deadline); err != nil {
+		log.Printf("[WS] Failed to send close frame: %v", err)
+		return r.close()
+	}
+
+	// The reader gets the backend's close frame and closes conn
+	select {
+	case <-r.done:
+		return nil
+	case <-time.After(time.Until(deadline)):
+		log.Printf("[WS] Backend did not answer the close frame within %s", closeHandshakeTimeout)
+		return r.close()
+	}
+}
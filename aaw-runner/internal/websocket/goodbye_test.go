@@ -0,0 +1,103 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/config"
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// goodbyeFrame holds the fields of a GOODBYE
+type goodbyeFrame struct {
+	Type            string  `json:"type"`
+	Reason          string  `json:"reason"`
+	UnfinishedTasks []int64 `json:"unfinishedTasks"`
+}
+
+// startClosingBackend is startBackend, also reporting the code of the close frame the runner sends
+func startClosingBackend(t *testing.T) (config.Config, chan []byte, chan int) {
+	t.Helper()
+	frames := make(chan []byte, 64)
+	closeCodes := make(chan int, 1)
+	cfg, _ := startBackendWith(t, backendHooks{frames: frames, onClose: func(_ int, err error) {
+		var closeErr *websocket.CloseError
+		if errors.As(err, &closeErr) {
+			closeCodes <- closeErr.Code // The default close handler has answered it
+		}
+	}})
+	return cfg, frames, closeCodes
+}
+
+// TestClose_Handshake verifies Close sends GOODBYE and a normal closure close frame, and Listen returns nil
+// once the backend has answered it
+func TestClose_Handshake(t *testing.T) {
+	cfg, frames, closeCodes := startClosingBackend(t)
+	client := NewClient(cfg)
+	assert.NoError(t, client.Connect())
+	listenDone := make(chan error, 1)
+	go func() { listenDone <- client.Listen() }()
+
+	assert.NoError(t, client.Close())
+	goodbye := nextGoodbye(t, frames)
+	assert.Equal(t, models.GoodbyeClosed, goodbye.Reason)
+	assert.Empty(t, goodbye.UnfinishedTasks)
+	select {
+	case data := <-frames:
+		t.Fatalf("frame after GOODBYE: %s", data)
+	default:
+	}
+
+	select {
+	case code := <-closeCodes:
+		assert.Equal(t, websocket.CloseNormalClosure, code)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no close frame received")
+	}
+	select {
+	case err := <-listenDone:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Listen did not return")
+	}
+}
+
+// TestGoodbye_ListsUnfinishedTasks verifies GOODBYE names the tasks the pool still holds, with the shutdown reason
+func TestGoodbye_ListsUnfinishedTasks(t *testing.T) {
+	cfg, frames, _ := startClosingBackend(t)
+	client := NewClient(cfg)
+	assert.NoError(t, client.Connect())
+	t.Cleanup(func() { client.Close() })
+
+	client.Pause()
+	client.handleExecute(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 41, ScriptContent: "hello"})
+	client.handleExecute(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 42, ScriptContent: "hello"})
+	client.shuttingDown.Store(true)
+	client.sendGoodbye()
+
+	goodbye := nextGoodbye(t, frames)
+	assert.Equal(t, models.GoodbyeShutdown, goodbye.Reason)
+	assert.Equal(t, []int64{41, 42}, goodbye.UnfinishedTasks)
+}
+
+// nextGoodbye skips frames up to the next GOODBYE
+func nextGoodbye(t *testing.T, frames chan []byte) goodbyeFrame {
+	t.Helper()
+	for {
+		select {
+		case data := <-frames:
+			var f goodbyeFrame
+			assert.NoError(t, json.Unmarshal(data, &f))
+			if f.Type == models.TypeGoodbye {
+				return f
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("no GOODBYE received")
+			return goodbyeFrame{}
+		}
+	}
+}
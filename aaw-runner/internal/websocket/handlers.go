@@ -0,0 +1,99 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/berno/aaw-runner/internal/models"
+)
+
+// MessageHandler handles one message from the backend, given the raw frame to decode itself
+// An error is logged with the message type; the read loop carries on either way.
+type MessageHandler func(raw json.RawMessage) error
+
+// RegisterHandler makes fn handle every message of type msgType, replacing the handler registered
+// for it before, the built-in ones included. Types without a handler get a MESSAGE_ERROR.
+// Must be called before Connect.
+func (c *Client) RegisterHandler(msgType string, fn MessageHandler) {
+	c.handlers[msgType] = fn
+}
+
+// registerBuiltins registers the handlers of the message types the runner knows (see models.DecodeIncoming)
+// Task work runs on its own goroutine so the read loop is never held up by it.
+func (c *Client) registerBuiltins() {
+	c.RegisterHandler(models.TypeHeloAck, decoded(c, func(msg *models.HeloAckMessage) { c.handleHeloAck(*msg) }))
+	c.RegisterHandler(models.TypeExecute, decoded(c, func(msg *models.ExecuteMessage) { go c.handleExecute(*msg) }))
+	c.RegisterHandler(models.TypeCancelTask, decoded(c, func(msg *models.CancelTaskMessage) { go c.handleCancelTask(*msg) }))
+	c.RegisterHandler(models.TypeKillTask, decoded(c, func(msg *models.KillTaskMessage) { go c.handleKillTask(*msg) }))
+	c.RegisterHandler(models.TypeResumeLogs, decoded(c, func(msg *models.ResumeLogsMessage) { go c.handleResumeLogs(*msg) }))
+	c.RegisterHandler(models.TypeRecurringExecute, decoded(c, func(msg *models.RecurringExecuteMessage) { go c.handleRecurringExecute(*msg) }))
+	c.RegisterHandler(models.TypeCancelRecurring, decoded(c, func(msg *models.CancelRecurringMessage) { go c.handleCancelRecurring(*msg) }))
+	c.RegisterHandler(models.TypeReserveSlot, decoded(c, func(msg *models.ReserveSlotMessage) { go c.handleReserveSlot(*msg) }))
+	c.RegisterHandler(models.TypeReleaseSlot, decoded(c, func(msg *models.ReleaseSlotMessage) { go c.handleReleaseSlot(*msg) }))
+	c.RegisterHandler(models.TypeAck, decoded(c, func(msg *models.AckMessage) { c.handleAck(*msg) }))
+	c.RegisterHandler(models.TypeShutdown, decoded(c, func(msg *models.ShutdownMessage) { go c.handleShutdown(*msg) }))
+	c.RegisterHandler(models.TypeStdinInput, decoded(c, func(msg *models.StdinInputMessage) { c.queueStdinInput(*msg) }))
+	// Answered during the handshake (see authenticate); any that reach listen came too late
+	c.RegisterHandler(models.TypeAuthChallenge, func(json.RawMessage) error {
+		return errors.New("only answered right after HELO, by a runner with AAW_RUNNER_SECRET or AAW_RUNNER_SECRET_FILE set")
+	})
+	c.RegisterHandler(models.TypeAuthOK, func(json.RawMessage) error { return nil })
+}
+
+// decoded adapts fn, which takes a message the runner knows, into a MessageHandler: the frame is decoded
+// and validated by models.DecodeAs and its schema version checked first, and a frame that fails either is
+// answered with a MESSAGE_ERROR and never reaches fn. The handler is only called for frames of T's type
+// (see handleMessage), so that type is not read from the frame again.
+func decoded[T models.Incoming](c *Client, fn func(T)) MessageHandler {
+	var zero T
+	msgType := zero.MessageType()
+	return func(raw json.RawMessage) error {
+		msg, err := models.DecodeAs(msgType, raw)
+		if err != nil {
+			c.sendMessageError(models.NewMessageError(raw, err))
+			return err
+		}
+		if err := models.CheckVersion(msg); err != nil {
+			if c.cfg.RejectNewerSchema {
+				c.sendMessageError(models.NewMessageError(raw, err))
+				return fmt.Errorf("rejected: %w", err)
+			}
+			log.Printf("[WS] Warning: %v; handling best-effort", err)
+		}
+		typed, ok := msg.(T)
+		if !ok {
+			return fmt.Errorf("decoded as %T, not %T", msg, typed)
+		}
+		fn(typed)
+		return nil
+	}
+}
+
+// handleMessage passes one message from the server to the handler registered for its type
+// Only here is the type read from the frame; the handler decodes the rest.
+func (c *Client) handleMessage(message []byte) {
+	msgType, err := models.PeekType(message)
+	if err != nil {
+		log.Printf("Failed to parse message: %v", err)
+		c.sendMessageError(models.NewMessageError(message, err))
+		return
+	}
+	handler, ok := c.handlers[msgType]
+	if !ok {
+		c.handleUnknown(msgType, message)
+		return
+	}
+	if err := handler(message); err != nil {
+		log.Printf("[WS] Handling %s failed: %v", msgType, err)
+	}
+}
+
+// handleUnknown is the default handler, for types nothing is registered for: they are ignored, and
+// reported to the backend with a MESSAGE_ERROR
+func (c *Client) handleUnknown(msgType string, message []byte) {
+	err := fmt.Errorf("%w: %s", models.ErrUnknownMessageType, msgType)
+	log.Printf("Ignoring message: %v", err)
+	c.sendMessageError(models.NewMessageError(message, err))
+}
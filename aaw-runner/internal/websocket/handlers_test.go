@@ -0,0 +1,57 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/berno/aaw-runner/internal/config"
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRegisterHandler_CustomType verifies a registered handler gets the raw frame of its type, and an error
+// it returns does not stop later messages from being handled
+func TestRegisterHandler_CustomType(t *testing.T) {
+	cfg, frames := startBackend(t)
+	client := NewClient(cfg)
+	var got []json.RawMessage
+	client.RegisterHandler("PLUGIN_PING", func(raw json.RawMessage) error {
+		got = append(got, raw)
+		return errors.New("plugin unavailable")
+	})
+	assert.NoError(t, client.Connect())
+	t.Cleanup(func() { client.Close() })
+	receiveUntil(t, frames, models.TypeRunnerCapacity)
+
+	client.handleMessage([]byte(`{"type":"PLUGIN_PING","seq":1}`))
+	client.handleMessage([]byte(`{"type":"PLUGIN_PING","seq":2}`))
+	assert.Len(t, got, 2)
+	var ping struct {
+		Seq int `json:"seq"`
+	}
+	assert.NoError(t, json.Unmarshal(got[1], &ping))
+	assert.Equal(t, 2, ping.Seq)
+
+	// Unregistered types still fall through to MESSAGE_ERROR
+	client.handleMessage([]byte(`{"type":"PLUGIN_PONG"}`))
+	errs := receiveUntil(t, frames, models.TypeMessageError)
+	assert.Equal(t, models.MessageErrorUnknownType, errs[len(errs)-1].Code)
+}
+
+// TestRegisterHandler_ReplacesBuiltin verifies a built-in type can be taken over by a registered handler
+func TestRegisterHandler_ReplacesBuiltin(t *testing.T) {
+	client := NewClient(config.Default())
+	t.Cleanup(func() { client.Close() })
+	var cancelled int64
+	client.RegisterHandler(models.TypeCancelTask, func(raw json.RawMessage) error {
+		var msg models.CancelTaskMessage
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			return err
+		}
+		cancelled = msg.TaskID
+		return nil
+	})
+	client.handleMessage([]byte(`{"type":"CANCEL_TASK","taskId":12}`))
+	assert.Equal(t, int64(12), cancelled)
+}
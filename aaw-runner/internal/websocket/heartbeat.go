@@ -0,0 +1,50 @@
+package websocket
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/models"
+)
+
+// heartbeatLoop sends RUNNER_HEARTBEAT every HeartbeatInterval until Close. Ticks that find the
+// runner disconnected are skipped rather than held: a heartbeat is only worth anything when fresh.
+func (c *Client) heartbeatLoop() {
+	ticker := time.NewTicker(c.cfg.HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.closing:
+			return
+		case <-ticker.C:
+			select {
+			case <-c.closing:
+				return
+			default:
+			}
+			if c.connected.Load() {
+				c.sendHeartbeat()
+			}
+		}
+	}
+}
+
+// sendHeartbeat reports the runner's uptime, capacity, queue length and host load, and how slow writes
+// to the backend are
+func (c *Client) sendHeartbeat() {
+	maxParallel, running, available := c.pool.GetCapacity()
+	msg := models.NewRunnerHeartbeat(time.Since(c.startedAt), maxParallel, running, available,
+		c.queuedTasks(running), loadAverage())
+	p95, degraded := c.latency.state()
+	msg.WriteP95Millis, msg.Degraded = p95.Milliseconds(), degraded
+	msg.RunnerID = c.runnerID
+	if err := c.sendJSON(&msg); err != nil && !errors.Is(err, ErrClientClosed) {
+		log.Printf("[WS] Failed to send heartbeat: %v", err)
+	}
+}
+
+// queuedTasks is how many tasks the pool has accepted that are still waiting for a worker
+func (c *Client) queuedTasks(running int) int {
+	return max(len(c.pool.Tasks())-running, 0)
+}
@@ -0,0 +1,93 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestHeartbeat_Payload verifies RUNNER_HEARTBEAT goes out on the interval with the pool's capacity
+func TestHeartbeat_Payload(t *testing.T) {
+	cfg, frames := startBackend(t)
+	cfg.HeartbeatInterval = 20 * time.Millisecond
+	client := NewClient(cfg)
+	client.SetRunnerID("runner-7")
+	t.Cleanup(func() { client.Close() })
+	assert.NoError(t, client.Connect())
+
+	var data []byte
+	for data == nil {
+		select {
+		case d := <-frames:
+			var f frame
+			if json.Unmarshal(d, &f) == nil && f.Type == models.TypeRunnerHeartbeat {
+				data = d
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("No RUNNER_HEARTBEAT sent")
+		}
+	}
+	var msg models.RunnerHeartbeatMessage
+	assert.NoError(t, json.Unmarshal(data, &msg))
+	assert.NoError(t, msg.Validate())
+	assert.Equal(t, cfg.MaxParallel, msg.MaxParallel)
+	assert.Equal(t, 0, msg.RunningTasks)
+	assert.Equal(t, cfg.MaxParallel, msg.AvailableSlots)
+	assert.Equal(t, 0, msg.QueuedTasks)
+	assert.GreaterOrEqual(t, msg.LoadAverage1, 0.0)
+	assert.GreaterOrEqual(t, msg.UptimeSeconds, int64(0))
+	assert.Equal(t, "runner-7", msg.RunnerID)
+}
+
+// TestHeartbeat_StopsAfterClose verifies no heartbeat is sent once the client is closed, nor while it is
+// disconnected
+func TestHeartbeat_StopsAfterClose(t *testing.T) {
+	cfg, frames := startBackend(t)
+	cfg.HeartbeatInterval = 10 * time.Millisecond
+	client := NewClient(cfg)
+	assert.NoError(t, client.Connect())
+	receiveUntil(t, frames, models.TypeRunnerHeartbeat)
+
+	client.connected.Store(false)
+	client.flush()
+	time.Sleep(20 * time.Millisecond) // Let a heartbeat already written arrive
+	drain(frames)
+	time.Sleep(50 * time.Millisecond)
+	assert.Zero(t, heartbeats(drain(frames)), "Skipped while disconnected")
+
+	client.connected.Store(true)
+	receiveUntil(t, frames, models.TypeRunnerHeartbeat)
+	client.Close()
+	time.Sleep(20 * time.Millisecond)
+	drain(frames)
+	time.Sleep(50 * time.Millisecond)
+	assert.Zero(t, heartbeats(drain(frames)))
+}
+
+// drain takes the frames already received
+func drain(frames chan []byte) [][]byte {
+	var got [][]byte
+	for {
+		select {
+		case d := <-frames:
+			got = append(got, d)
+		default:
+			return got
+		}
+	}
+}
+
+// heartbeats counts the RUNNER_HEARTBEAT frames in got
+func heartbeats(got [][]byte) int {
+	n := 0
+	for _, d := range got {
+		var f frame
+		if json.Unmarshal(d, &f) == nil && f.Type == models.TypeRunnerHeartbeat {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,88 @@
+package websocket
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Event is one entry of the client's recent activity (task lifecycle, connection, shutdown)
+type Event struct {
+	At   time.Time
+	Text string
+}
+
+// history retains recent task output and events for the terminal UI
+// A nil *history records nothing, which is the default
+type history struct {
+	mu       sync.Mutex
+	maxLines int
+	maxEvent int
+	output   map[int64][]string // Last lines of each pending task's output
+	events   []Event            // Oldest first
+}
+
+// KeepHistory makes the client retain the last lines of each task's output and its most recent events
+// for the terminal UI (--tui). Off by default so nothing is retained; call it before Connect.
+func (c *Client) KeepHistory(lines, events int) {
+	c.history = &history{maxLines: lines, maxEvent: events, output: make(map[int64][]string)}
+}
+
+// TaskOutput returns the retained output of a pending task, oldest line first
+func (c *Client) TaskOutput(taskID int64) []string {
+	h := c.history
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.output[taskID]...)
+}
+
+// RecentEvents returns the retained events, oldest first
+func (c *Client) RecentEvents() []Event {
+	h := c.history
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]Event(nil), h.events...)
+}
+
+// recordLine retains a line of a task's output
+func (h *history) recordLine(taskID int64, line string) {
+	if h == nil || h.maxLines <= 0 {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	lines := append(h.output[taskID], line)
+	if len(lines) > h.maxLines {
+		lines = lines[len(lines)-h.maxLines:]
+	}
+	h.output[taskID] = lines
+}
+
+// forget drops a completed task's output
+func (h *history) forget(taskID int64) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.output, taskID)
+}
+
+// record retains an event
+func (h *history) record(format string, args ...interface{}) {
+	if h == nil || h.maxEvent <= 0 {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, Event{At: time.Now(), Text: fmt.Sprintf(format, args...)})
+	if len(h.events) > h.maxEvent {
+		h.events = h.events[len(h.events)-h.maxEvent:]
+	}
+}
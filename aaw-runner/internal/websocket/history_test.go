@@ -0,0 +1,76 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/livelog"
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestHistory_OffByDefault verifies nothing is retained unless KeepHistory was called
+func TestHistory_OffByDefault(t *testing.T) {
+	client, frames := startTaskClient(t, "echo working; sleep 0.2")
+	receiveUntil(t, frames, models.TypeTaskCompleted)
+
+	assert.Nil(t, client.history)
+	assert.Empty(t, client.RecentEvents())
+	assert.Empty(t, client.TaskOutput(31))
+}
+
+// TestHistory_RetainsOutputAndEvents verifies the last output lines of a pending task and recent events are kept
+func TestHistory_RetainsOutputAndEvents(t *testing.T) {
+	testutil.FakeClaude(t, "echo one; echo two; echo three; sleep 30")
+	cfg, frames := startBackend(t)
+	client := NewClient(cfg)
+	client.KeepHistory(2, 3)
+	assert.NoError(t, client.Connect())
+	defer client.Close()
+
+	client.handleExecute(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 33, ScriptContent: "hello"})
+	assert.Eventually(t, func() bool {
+		output := client.TaskOutput(33)
+		return len(output) == 2 && output[1] == "three"
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"two", "three"}, client.TaskOutput(33))
+
+	assert.NoError(t, client.CancelTask(33))
+	receiveUntil(t, frames, models.TypeTaskCompleted)
+	assert.Eventually(t, func() bool { return len(client.RecentEvents()) == 3 }, time.Second, 10*time.Millisecond)
+
+	var texts []string
+	for _, event := range client.RecentEvents() {
+		texts = append(texts, event.Text)
+	}
+	assert.Equal(t, []string{"Task 33 started", "Cancel of task 33 requested by runner:operator", "Task 33 cancelled (CANCELLED)"}, texts,
+		"Only the newest events are kept")
+	assert.Empty(t, client.TaskOutput(33), "Output is dropped once the task completes")
+}
+
+// TestLiveLogs_TeesTaskOutput verifies a viewer following a task gets the lines sent to the backend until it completes
+func TestLiveLogs_TeesTaskOutput(t *testing.T) {
+	testutil.FakeClaude(t, "sleep 0.2; echo first; echo second")
+	logs := livelog.New(100)
+	cfg, frames := startBackend(t)
+	client := NewClient(cfg)
+	client.SetLiveLogs(logs)
+	assert.NoError(t, client.Connect())
+	defer client.Close()
+
+	client.handleExecute(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 34, ScriptContent: "hello"})
+	receiveUntil(t, frames, models.TypeTaskStarted)
+	sub, ok := logs.Subscribe(34)
+	assert.True(t, ok)
+
+	var lines []string
+	for line := range sub.Lines() {
+		lines = append(lines, line)
+	}
+	assert.Contains(t, lines, "first")
+	assert.Contains(t, lines, "second")
+	assert.False(t, sub.Dropped())
+	_, ok = logs.Subscribe(34)
+	assert.False(t, ok, "The stream closes when the task completes")
+}
@@ -0,0 +1,47 @@
+package websocket
+
+// Option customises a Client built by NewClient
+type Option func(*Client)
+
+// hooks are the lifecycle callbacks of a program embedding the client. Each is called on the
+// goroutine that noticed the change, with none of the client's locks held, so it may call back
+// into the client; a slow hook delays that goroutine (a nil one is skipped).
+type hooks struct {
+	connected    func()
+	disconnected func(err error)
+	taskComplete func(taskID int64, success bool)
+}
+
+// WithOnConnected calls fn once a connection to the backend is up and its HELO exchange is done:
+// after Connect and after every redial
+func WithOnConnected(fn func()) Option {
+	return func(c *Client) { c.hooks.connected = fn }
+}
+
+// WithOnDisconnected calls fn with the error that ended a connection, including the one Close ends
+func WithOnDisconnected(fn func(err error)) Option {
+	return func(c *Client) { c.hooks.disconnected = fn }
+}
+
+// WithOnTaskComplete calls fn for every finished task, after its TASK_COMPLETED has been queued
+func WithOnTaskComplete(fn func(taskID int64, success bool)) Option {
+	return func(c *Client) { c.hooks.taskComplete = fn }
+}
+
+func (h hooks) onConnected() {
+	if h.connected != nil {
+		h.connected()
+	}
+}
+
+func (h hooks) onDisconnected(err error) {
+	if h.disconnected != nil {
+		h.disconnected(err)
+	}
+}
+
+func (h hooks) onTaskComplete(taskID int64, success bool) {
+	if h.taskComplete != nil {
+		h.taskComplete(taskID, success)
+	}
+}
@@ -0,0 +1,71 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/executor"
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestHooks verifies the lifecycle hooks follow a connection that drops, is redialled and runs a task
+func TestHooks(t *testing.T) {
+	testutil.FakeClaude(t, "echo done")
+	drop := make(chan struct{})
+	cfg, _, _ := startDroppingBackend(t, drop)
+	events := make(chan string, 16)
+	disconnects := make(chan error, 16)
+	completions := make(chan int64, 16)
+	var client *Client
+	client = NewClient(cfg,
+		WithOnConnected(func() {
+			assert.True(t, client.Connected(), "Hooks may call back into the client")
+			events <- "connected"
+		}),
+		WithOnDisconnected(func(err error) {
+			disconnects <- err
+			events <- "disconnected"
+		}),
+		WithOnTaskComplete(func(taskID int64, success bool) {
+			assert.True(t, success)
+			completions <- taskID
+		}),
+	)
+	assert.NoError(t, client.Connect())
+	listenDone := make(chan error, 1)
+	go func() { listenDone <- client.Listen() }()
+	t.Cleanup(func() {
+		client.Close()
+		<-listenDone
+	})
+
+	close(drop)
+	for _, want := range []string{"connected", "disconnected", "connected"} {
+		select {
+		case got := <-events:
+			assert.Equal(t, want, got)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no %s hook", want)
+		}
+	}
+	assert.Error(t, <-disconnects)
+
+	client.handleExecute(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 17, ScriptContent: "hello"})
+	select {
+	case taskID := <-completions:
+		assert.Equal(t, int64(17), taskID)
+	case <-time.After(10 * time.Second):
+		t.Fatal("no task complete hook")
+	}
+}
+
+// TestHooks_Nil verifies nil hooks are skipped
+func TestHooks_Nil(t *testing.T) {
+	cfg, _ := startBackend(t)
+	client := NewClient(cfg, WithOnConnected(nil), WithOnDisconnected(nil), WithOnTaskComplete(nil))
+	assert.NoError(t, client.Connect())
+	client.onTaskComplete(executor.TaskResult{TaskID: 4, Success: true})
+	assert.NoError(t, client.Close())
+}
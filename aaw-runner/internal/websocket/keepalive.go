@@ -0,0 +1,44 @@
+package websocket
+
+import (
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// keepAlive arms r's connection's read deadline, pushed out by every pong, and pings the backend every
+// PingInterval until stop is closed. A connection that has silently died (host asleep, NAT timeout)
+// then fails its read at most PingInterval+PongTimeout after the last pong, and listen handles it
+// like any other drop.
+func (c *Client) keepAlive(r *reader, stop <-chan struct{}) {
+	conn := r.conn
+	wait := c.pongWait()
+	conn.SetReadDeadline(time.Now().Add(wait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wait))
+	})
+
+	go func() {
+		ticker := time.NewTicker(c.cfg.PingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				// Control frames may be written alongside sendJSON, so the send path is not needed
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.cfg.PongTimeout)); err != nil {
+					log.Printf("[WS] Ping failed: %v", err)
+					r.close()
+					return
+				}
+			}
+		}
+	}()
+}
+
+// pongWait is how long a connection may go without a pong before it is treated as dead
+func (c *Client) pongWait() time.Duration {
+	return c.cfg.PingInterval + c.cfg.PongTimeout
+}
@@ -0,0 +1,55 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/config"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// startPingBackend starts a backend that reads frames and answers pings only if pong is set
+func startPingBackend(t *testing.T, pong bool) config.Config {
+	t.Helper()
+	cfg, _ := startBackendWith(t, backendHooks{onConnect: func(conn *websocket.Conn) bool {
+		if !pong {
+			conn.SetPingHandler(func(string) error { return nil })
+		}
+		return true
+	}})
+	cfg.PingInterval = 50 * time.Millisecond
+	cfg.PongTimeout = 50 * time.Millisecond
+	return cfg
+}
+
+// TestKeepAlive_DeadBackend verifies a connection whose pongs stop fails the read within PingInterval+PongTimeout
+func TestKeepAlive_DeadBackend(t *testing.T) {
+	client := NewClient(startPingBackend(t, false))
+	assert.NoError(t, client.Connect())
+	t.Cleanup(func() { client.Close() })
+
+	start := time.Now()
+	err := client.listen()
+	assert.ErrorContains(t, err, "no pong from the backend for 100ms")
+	assert.Less(t, time.Since(start), time.Second)
+	assert.False(t, client.Connected())
+}
+
+// TestKeepAlive_Responsive verifies a backend answering pings keeps the connection well past the pong window
+func TestKeepAlive_Responsive(t *testing.T) {
+	client := NewClient(startPingBackend(t, true))
+	assert.NoError(t, client.Connect())
+	listenDone := make(chan error, 1)
+	go func() { listenDone <- client.listen() }()
+
+	time.Sleep(500 * time.Millisecond)
+	assert.True(t, client.Connected())
+	select {
+	case err := <-listenDone:
+		t.Fatalf("listen returned: %v", err)
+	default:
+	}
+	client.Close()
+	<-listenDone
+}
@@ -0,0 +1,26 @@
+//go:build linux
+
+package websocket
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// loadAverage is the host's 1-minute load average from /proc/loadavg, or 0 when it cannot be read
+func loadAverage() float64 {
+	data, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return 0
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0
+	}
+	load, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0
+	}
+	return load
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package websocket
+
+// loadAverage is always 0: only Linux exposes the load average without cgo
+func loadAverage() float64 {
+	return 0
+}
@@ -0,0 +1,65 @@
+package websocket
+
+import (
+	"strings"
+
+	"github.com/berno/aaw-runner/internal/executor"
+	"github.com/berno/aaw-runner/internal/matcher"
+	"github.com/berno/aaw-runner/internal/statsd"
+)
+
+// StatsD metric names (prefixed by --statsd-prefix)
+const (
+	metricSubmitted   = "tasks.submitted"   // Counter: tasks accepted by the pool
+	metricRejected    = "tasks.rejected"    // Counter tagged error_code: tasks refused before running
+	metricStarted     = "tasks.started"     // Counter: tasks a worker began executing
+	metricFinished    = "tasks.finished"    // Counter tagged status and error_code
+	metricDuration    = "task.duration"     // Timer: execution time of a finished task
+	metricQueueWait   = "task.queue_wait"   // Timer: time a task spent queued before starting
+	metricRunning     = "pool.running"      // Gauge
+	metricAvailable   = "pool.available"    // Gauge: free slots (0 while draining)
+	metricMaxParallel = "pool.max_parallel" // Gauge
+	metricQueued      = "pool.queued"       // Gauge: tasks waiting for a worker
+	metricConnects    = "ws.connects"       // Counter: successful handshakes with the backend
+	metricDisconnects = "ws.disconnects"    // Counter
+	metricDetections  = "detections"        // Counter tagged category: rate limit, usage limit and auth detections
+	metricLogsDropped = "logs.dropped"      // Counter: task output lines dropped because sending fell behind
+)
+
+// SetMetrics sends the client's metrics to a StatsD agent; without it (or with nil) nothing is emitted
+// Must be called before Connect.
+func (c *Client) SetMetrics(metrics *statsd.Client) {
+	c.metrics = metrics
+}
+
+// recordFinished counts a finished task and times it
+func (c *Client) recordFinished(result executor.TaskResult) {
+	tags := []string{"status:" + strings.ToLower(result.Status())}
+	if result.ErrorCode != "" {
+		tags = append(tags, "error_code:"+result.ErrorCode)
+	}
+	c.metrics.Incr(metricFinished, tags...)
+	if result.DroppedOutput > 0 {
+		c.metrics.Count(metricLogsDropped, result.DroppedOutput)
+	}
+	if result.Duration > 0 {
+		c.metrics.Timing(metricDuration, result.Duration)
+		c.metrics.Timing(metricQueueWait, result.QueueWait)
+	}
+}
+
+// reportPool sets the pool gauges
+func (c *Client) reportPool(maxParallel, running, available int) {
+	if c.metrics == nil {
+		return
+	}
+	c.metrics.Gauge(metricMaxParallel, float64(maxParallel))
+	c.metrics.Gauge(metricRunning, float64(running))
+	c.metrics.Gauge(metricAvailable, float64(available))
+	c.metrics.Gauge(metricQueued, float64(c.queuedTasks(running)))
+}
+
+// onDetected counts a limit or auth detection reported by the pool
+func (c *Client) onDetected(taskID int64, category matcher.Category) {
+	c.metrics.Incr(metricDetections, "category:"+string(category))
+}
@@ -0,0 +1,56 @@
+package websocket
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/statsd"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMetrics_TaskLifecycle verifies a task's lifecycle is pushed to StatsD
+func TestMetrics_TaskLifecycle(t *testing.T) {
+	testutil.FakeClaude(t, "echo done")
+	agent, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	assert.NoError(t, err)
+	defer agent.Close()
+
+	metrics, err := statsd.New(agent.LocalAddr().String(), "aaw", "env:test")
+	assert.NoError(t, err)
+
+	cfg, frames := startBackend(t)
+	client := NewClient(cfg)
+	client.SetMetrics(metrics)
+	assert.NoError(t, client.Connect())
+	defer client.Close()
+
+	client.handleExecute(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 70, ScriptContent: "hello"})
+	receiveUntil(t, frames, models.TypeTaskCompleted)
+	metrics.Close()
+
+	var lines []string
+	buf := make([]byte, 65536)
+	for {
+		agent.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+		n, err := agent.Read(buf)
+		if err != nil {
+			break
+		}
+		lines = append(lines, strings.Split(string(buf[:n]), "\n")...)
+	}
+
+	assert.Contains(t, lines, "aaw.ws.connects:1|c|#env:test")
+	assert.Contains(t, lines, "aaw.tasks.submitted:1|c|#env:test")
+	assert.Contains(t, lines, "aaw.tasks.started:1|c|#env:test")
+	assert.Contains(t, lines, "aaw.tasks.finished:1|c|#env:test,status:completed")
+	assert.Contains(t, lines, "aaw.pool.running:1|g|#env:test")
+	var timed bool
+	for _, line := range lines {
+		timed = timed || strings.HasPrefix(line, "aaw.task.duration:") && strings.HasSuffix(line, "|ms|#env:test")
+	}
+	assert.True(t, timed, "Task duration should be reported as a timer: %v", lines)
+}
@@ -0,0 +1,128 @@
+package websocket
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/berno/aaw-runner/internal/models"
+)
+
+// offlineBuffer keeps the task messages the writer could not send while the backend was unreachable,
+// in order, so they go out ahead of anything else once it is back. Only LOG messages count against the
+// limit and give way when it is reached, oldest first: a summary line stands in for a task's dropped
+// lines. TASK_STARTED, STATUS_UPDATE and TASK_COMPLETED are never dropped.
+// Only the writer goroutine uses it.
+type offlineBuffer struct {
+	limit   int           // LOG messages kept
+	entries []interface{} // Buffered messages, oldest first
+	lines   int           // LOG messages among entries, summaries excluded
+	dropped map[int64]*droppedLines
+}
+
+// droppedLines is the summary standing in for the lines of one task that gave way
+type droppedLines struct {
+	summary     *models.LogMessage
+	first, last int64 // Line indexes
+	n           int
+}
+
+func newOfflineBuffer(limit int) *offlineBuffer {
+	return &offlineBuffer{limit: limit, dropped: make(map[int64]*droppedLines)}
+}
+
+// buffers reports whether a message is kept while offline; others (runner status, capacity and the
+// like) are sent afresh on reconnect
+func buffers(msg interface{}) bool {
+	switch msg.(type) {
+	case *models.LogMessage, *models.TaskStartedMessage, *models.StatusUpdateMessage, *models.TaskCompletedMessage:
+		return true
+	}
+	return false
+}
+
+// add appends a message, dropping the oldest LOG message when the limit is exceeded, and returns
+// how many lines were dropped (0 or 1)
+func (b *offlineBuffer) add(msg interface{}) int {
+	line, isLine := msg.(*models.LogMessage)
+	if !isLine {
+		b.entries = append(b.entries, msg)
+		return 0
+	}
+	if b.limit == 0 {
+		b.drop(line, -1)
+		return 1
+	}
+	b.entries = append(b.entries, msg)
+	b.lines++
+	if b.lines > b.limit {
+		b.dropOldestLine()
+		return 1
+	}
+	return 0
+}
+
+// dropOldestLine drops the first LOG message that is not a summary
+func (b *offlineBuffer) dropOldestLine() {
+	for i, entry := range b.entries {
+		if line, ok := entry.(*models.LogMessage); ok && !b.isSummary(line) {
+			b.lines--
+			b.drop(line, i)
+			return
+		}
+	}
+}
+
+// drop counts a line that gave way, at position i of entries (-1 when it was never added)
+// A task's first dropped line is replaced by its summary; later ones are removed.
+func (b *offlineBuffer) drop(line *models.LogMessage, i int) {
+	d := b.dropped[line.TaskID]
+	if d == nil {
+		summary := models.NewLogMessage(line.TaskID, "", true)
+		summary.LineIndex, summary.Metadata = line.LineIndex, line.Metadata
+		d = &droppedLines{summary: &summary, first: line.LineIndex}
+		b.dropped[line.TaskID] = d
+		if i >= 0 {
+			b.entries[i] = d.summary
+		} else {
+			b.entries = append(b.entries, d.summary)
+		}
+	} else if i >= 0 {
+		b.entries = slices.Delete(b.entries, i, i+1)
+	}
+	d.last = line.LineIndex
+	d.n++
+	d.summary.Skipped = int64(d.n)
+	d.summary.Line = fmt.Sprintf("[runner] %d output lines (%d-%d) dropped while the backend was unreachable; RESUME_LOGS can resend them",
+		d.n, d.first, d.last)
+}
+
+func (b *offlineBuffer) isSummary(line *models.LogMessage) bool {
+	d := b.dropped[line.TaskID]
+	return d != nil && d.summary == line
+}
+
+// empty reports whether nothing is waiting
+func (b *offlineBuffer) empty() bool {
+	return len(b.entries) == 0
+}
+
+// sendAll sends the buffered messages in order with send, stopping at the first failure
+// Messages sent are removed; the one that failed and those after it stay for the next attempt.
+func (b *offlineBuffer) sendAll(send func(msg interface{}) error) error {
+	for len(b.entries) > 0 {
+		if err := send(b.entries[0]); err != nil {
+			return err
+		}
+		if line, ok := b.entries[0].(*models.LogMessage); ok {
+			if b.isSummary(line) {
+				delete(b.dropped, line.TaskID) // Lines dropped from now on get a summary of their own
+			} else {
+				b.lines--
+			}
+		}
+		b.entries[0] = nil
+		b.entries = b.entries[1:]
+	}
+	b.entries = nil
+	return nil
+}
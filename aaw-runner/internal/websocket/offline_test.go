@@ -0,0 +1,131 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// logLine builds the LOG message for line index of a task
+func logLine(taskID, index int64) *models.LogMessage {
+	msg := models.NewLogMessage(taskID, "line", false)
+	msg.LineIndex = index
+	return &msg
+}
+
+// buffered lists buffered messages for comparison, lines as "LOG <first word> task/index"
+func buffered(entries []interface{}) []string {
+	var got []string
+	for _, entry := range entries {
+		switch msg := entry.(type) {
+		case *models.LogMessage:
+			got = append(got, fmt.Sprintf("LOG %s %d/%d", strings.Fields(msg.Line)[0], msg.TaskID, msg.LineIndex))
+		case *models.StatusUpdateMessage:
+			got = append(got, "STATUS_UPDATE "+msg.Status)
+		case *models.TaskCompletedMessage:
+			got = append(got, "TASK_COMPLETED")
+		}
+	}
+	return got
+}
+
+// TestOfflineBuffer_DropsOldestLines verifies lines give way oldest first to a summary, and status updates and completions never do
+func TestOfflineBuffer_DropsOldestLines(t *testing.T) {
+	b := newOfflineBuffer(2)
+	status := models.NewStatusUpdate(1, models.StatusRunning)
+	completed := models.NewTaskCompleted(1, true)
+	b.add(&status)
+	b.add(logLine(1, 0))
+	b.add(logLine(1, 1))
+	b.add(logLine(2, 0))
+	b.add(logLine(1, 2))
+	b.add(&completed)
+
+	assert.Equal(t, []string{"STATUS_UPDATE RUNNING", "LOG [runner] 1/0", "LOG line 2/0", "LOG line 1/2", "TASK_COMPLETED"}, buffered(b.entries))
+	assert.Equal(t, "[runner] 2 output lines (0-1) dropped while the backend was unreachable; RESUME_LOGS can resend them", b.entries[1].(*models.LogMessage).Line)
+	assert.True(t, b.entries[1].(*models.LogMessage).IsError)
+
+	// A failed send keeps what was not sent, summary included
+	sent := 0
+	err := b.sendAll(func(interface{}) error {
+		if sent == 1 {
+			return errors.New("broken pipe")
+		}
+		sent++
+		return nil
+	})
+	assert.Error(t, err)
+	assert.Len(t, b.entries, 4)
+	b.add(logLine(1, 3))
+	assert.Equal(t, []string{"LOG [runner] 1/0", "LOG [runner] 2/0", "LOG line 1/2", "TASK_COMPLETED", "LOG line 1/3"}, buffered(b.entries), "The oldest line gives way, whichever task it is from")
+
+	assert.NoError(t, b.sendAll(func(interface{}) error { return nil }))
+	assert.True(t, b.empty())
+	b.add(logLine(1, 4))
+	b.add(logLine(1, 5))
+	b.add(logLine(1, 6))
+	assert.Equal(t, "[runner] 1 output lines (4-4) dropped while the backend was unreachable; RESUME_LOGS can resend them", b.entries[0].(*models.LogMessage).Line, "A new outage gets a new summary")
+}
+
+// TestOfflineBuffer_Replay verifies task messages produced while disconnected are sent after HELO on reconnect, ahead of newer messages
+func TestOfflineBuffer_Replay(t *testing.T) {
+	cfg, frames := startBackend(t)
+	cfg.OfflineBufferLines = 3
+	client := NewClient(cfg)
+	assert.NoError(t, client.Connect())
+	t.Cleanup(func() { client.Close() })
+	receiveUntil(t, frames, models.TypeRunnerCapacity)
+
+	// The backend goes away without the read loop having noticed yet: writes fail and are kept
+	client.conn.Close()
+	for i := int64(0); i < 5; i++ {
+		assert.NoError(t, client.sendJSON(logLine(51, i)))
+	}
+	completed := models.NewTaskCompleted(51, true)
+	assert.NoError(t, client.sendJSON(&completed))
+	capacity := models.NewRunnerCapacity(5, 0, 5, 0)
+	assert.NoError(t, client.sendJSON(&capacity))
+	client.Ping()
+
+	assert.NoError(t, client.Connect())
+	var got []string
+	for _, f := range receiveUntil(t, frames, models.TypeRunnerCapacity) {
+		got = append(got, f.Type)
+	}
+	assert.Equal(t, []string{models.TypeHelo, models.TypeLog, models.TypeLog, models.TypeLog, models.TypeLog, models.TypeTaskCompleted, models.TypeRunnerStatus, models.TypeRunnerCapacity}, got)
+	assert.True(t, client.offline.empty())
+}
+
+// TestOfflineBuffer_SummaryLine verifies the line standing in for dropped output reaches the backend with the first dropped index
+func TestOfflineBuffer_SummaryLine(t *testing.T) {
+	cfg, frames := startBackend(t)
+	cfg.OfflineBufferLines = 1
+	client := NewClient(cfg)
+	assert.NoError(t, client.Connect())
+	t.Cleanup(func() { client.Close() })
+
+	client.conn.Close()
+	for i := int64(0); i < 3; i++ {
+		assert.NoError(t, client.sendJSON(logLine(52, i)))
+	}
+	client.Ping()
+	assert.NoError(t, client.Connect())
+
+	var lines []models.LogMessage
+	for len(lines) < 2 {
+		var msg models.LogMessage
+		assert.NoError(t, json.Unmarshal(<-frames, &msg))
+		if msg.Type == models.TypeLog {
+			lines = append(lines, msg)
+		}
+	}
+	assert.Equal(t, int64(0), lines[0].LineIndex)
+	assert.Contains(t, lines[0].Line, "2 output lines (0-1) dropped")
+	assert.Equal(t, int64(2), lines[1].LineIndex)
+	assert.Equal(t, "line", lines[1].Line)
+}
@@ -0,0 +1,50 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/config"
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestOrphanPolicy_HoldsCancelledCompletions verifies a task cancelled during an outage is reported only after reconnecting
+func TestOrphanPolicy_HoldsCancelledCompletions(t *testing.T) {
+	testutil.FakeClaude(t, "sleep 30")
+	cfg, frames := startBackend(t)
+	cfg.OrphanPolicy = config.OrphanCancelAfter
+	cfg.OrphanAfter = 50 * time.Millisecond
+	client := NewClient(cfg)
+	assert.NoError(t, client.Connect())
+	defer client.Close()
+	client.handleExecute(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 71, ScriptContent: "hello"})
+	receiveUntil(t, frames, models.TypeTaskStarted)
+	waitForProcess(t, client, 71)
+
+	// What Listen does when the read loop fails; the test backend stays up to show nothing is sent
+	client.connected.Store(false)
+	client.orphans.Disconnected()
+	assert.Eventually(t, func() bool {
+		client.heldMu.Lock()
+		defer client.heldMu.Unlock()
+		return len(client.held) == 1
+	}, 15*time.Second, 10*time.Millisecond)
+	for len(frames) > 0 {
+		var f frame
+		assert.NoError(t, json.Unmarshal(<-frames, &f))
+		assert.NotEqual(t, models.TypeTaskCompleted, f.Type, "Held completions are not sent while disconnected")
+	}
+
+	client.connected.Store(true)
+	client.orphans.Connected()
+	client.replayHeld()
+	got := receiveUntil(t, frames, models.TypeTaskCompleted)
+	completed := got[len(got)-1]
+	assert.Equal(t, int64(71), completed.TaskID)
+	assert.Equal(t, models.RequestedByOrphanPolicy, completed.RequestedBy)
+	assert.Equal(t, "backend unreachable", completed.Reason)
+	assert.False(t, client.Paused(), "The held queue is released on reconnect")
+}
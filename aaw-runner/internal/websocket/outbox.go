@@ -0,0 +1,207 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// outboxSize is how many messages may wait for the writer
+// A variable so tests can shrink it.
+var outboxSize = 1024
+
+// outboxFlushTimeout bounds how long Close lets the writer send what is still queued
+var outboxFlushTimeout = 5 * time.Second
+
+// ErrClientClosed is returned for messages sent once Close has stopped the writer; they are counted
+// and dropped without touching the connection
+var ErrClientClosed = errors.New("client is closed")
+
+// errUnencodable is returned by write for a message that cannot be marshalled; nothing was written,
+// so the connection is still usable
+var errUnencodable = errors.New("cannot encode message")
+
+// outgoing is one entry of the outbox: a message to write, or a flush barrier
+type outgoing struct {
+	msg     interface{}
+	gen     uint64        // Generation of the connection the message was queued for (see Client.generation)
+	flushed chan struct{} // Closed by the writer once everything queued before it was written
+}
+
+// writeLoop is the only goroutine writing messages to the connection: it takes them from the outbox in
+// order until Close, then writes whatever is still queued and exits
+func (c *Client) writeLoop() {
+	defer close(c.writerDone)
+	for {
+		select {
+		case out := <-c.outbox:
+			c.writeOutgoing(out)
+		case <-c.stopWriter:
+			for {
+				select {
+				case out := <-c.outbox:
+					c.writeOutgoing(out)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// writeOutgoing writes one outbox entry to the current connection, stamped with its schema version
+// Task messages that cannot be written are kept in the offline buffer, which is sent ahead of the
+// first message written once the connection is back.
+func (c *Client) writeOutgoing(out outgoing) {
+	if out.flushed != nil {
+		close(out.flushed)
+		return
+	}
+	c.connMutex.Lock()
+	conn, schema, gen := c.conn, c.schema, c.generation
+	c.connMutex.Unlock()
+
+	if conn == nil || !c.connected.Load() {
+		c.holdOffline(out.msg)
+		return
+	}
+	if out.gen != gen {
+		// Queued for a connection that has since been replaced: task messages join the offline buffer,
+		// as they would have had the writer got to them before the redial; anything else (capacity,
+		// answers to the old connection's requests) is stale and never reaches the new one
+		if !buffers(out.msg) {
+			log.Printf("[WS] Dropped outbound %T: queued for an earlier connection", out.msg)
+			c.offlineDropped.Add(1)
+			return
+		}
+		c.holdOffline(out.msg)
+		return
+	}
+	send := func(msg interface{}) error {
+		if env, ok := msg.(interface{ SetSchemaVersion(int) }); ok {
+			env.SetSchemaVersion(schema)
+		}
+		err := c.write(conn, msg)
+		if errors.Is(err, errUnencodable) {
+			log.Printf("[WS] Dropped outbound %T: %v", msg, err)
+			return nil // It can never be sent, and the connection is fine
+		}
+		return err
+	}
+	if !c.offline.empty() {
+		log.Printf("[WS] Sending %d messages kept while the backend was unreachable", len(c.offline.entries))
+		if err := c.offline.sendAll(send); err != nil {
+			c.holdOffline(out.msg)
+			return
+		}
+	}
+	if err := send(out.msg); err != nil {
+		log.Printf("[WS] Failed to send %T: %v", out.msg, err)
+		c.holdOffline(out.msg)
+	}
+}
+
+// holdOffline keeps a message that cannot be sent now in the offline buffer, when it is a task message
+func (c *Client) holdOffline(msg interface{}) {
+	if !buffers(msg) {
+		log.Printf("[WS] Dropped outbound %T: not connected", msg)
+		c.offlineDropped.Add(1)
+		return
+	}
+	if journaled(msg) {
+		log.Printf("[WS] Not buffering %T: the delivery journal re-sends it on connect", msg)
+		return
+	}
+	if c.offline.empty() {
+		log.Printf("[WS] Backend unreachable: keeping task messages until it is back")
+	}
+	dropped := c.offline.add(msg)
+	c.offlineHeld.Add(int64(1 - dropped))
+	c.offlineDropped.Add(int64(dropped))
+}
+
+// write writes one message to conn, dropping the connection when the write fails (see dropConnection)
+// A message that cannot be marshalled is not written at all and leaves the connection alone.
+func (c *Client) write(conn *websocket.Conn, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errUnencodable, err)
+	}
+	conn.SetWriteDeadline(time.Now().Add(c.writeTimeout()))
+	start := time.Now()
+	if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		c.noteError(err)
+		c.dropConnection(conn, err)
+		return err
+	}
+	c.noteWriteLatency(time.Since(start))
+	c.audit.Sent(v)
+	return nil
+}
+
+// dropConnection closes conn after a failed write. A connection is unusable once a write has failed,
+// however healthy its read side looks, so it is marked down at once, sending what follows to the
+// offline buffer rather than into the same failure, and closed by its reader, which ends the read
+// loop and makes Listen redial: the next connection gets a new read loop and generation together.
+// A conn that is not current is left to its owner: connect while sending HELO, or otherwise the
+// reader of a replaced connection, which has closed it already.
+func (c *Client) dropConnection(conn *websocket.Conn, err error) {
+	c.connMutex.Lock()
+	current, r := c.conn == conn, c.reader
+	c.connMutex.Unlock()
+	if !current {
+		return
+	}
+	if c.connected.CompareAndSwap(true, false) {
+		log.Printf("[WS] Write failed, dropping the connection to reconnect: %v", err)
+	}
+	r.close()
+}
+
+// enqueue hands an entry to the writer, waiting only while the outbox is full (which a stalled
+// connection clears within the write timeout, as the failed write closes it)
+func (c *Client) enqueue(out outgoing) error {
+	select {
+	case <-c.stopWriter:
+		return ErrClientClosed
+	default:
+	}
+	select {
+	case c.outbox <- out:
+		return nil
+	case <-c.writerDone:
+		return ErrClientClosed
+	}
+}
+
+// flush waits until every message queued before it has been written (or failed to be), or the writer has stopped
+func (c *Client) flush() {
+	flushed := make(chan struct{})
+	if c.enqueue(outgoing{flushed: flushed}) != nil {
+		return
+	}
+	select {
+	case <-flushed:
+	case <-c.writerDone:
+	}
+}
+
+// stopWriting makes the writer send what is still queued and exit, waiting for it up to outboxFlushTimeout
+func (c *Client) stopWriting() {
+	c.stopOnce.Do(func() {
+		c.closed.Store(true)
+		close(c.stopWriter)
+	})
+	select {
+	case <-c.writerDone:
+		if !c.offline.empty() {
+			log.Printf("[WS] %d messages kept while the backend was unreachable were dropped at exit", len(c.offline.entries))
+		}
+	case <-time.After(outboxFlushTimeout):
+		log.Printf("[WS] Outbound messages still queued after %s were dropped", outboxFlushTimeout)
+	}
+}
@@ -0,0 +1,150 @@
+package websocket
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/config"
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// startStalledBackend starts a backend that accepts the connection and never reads from it, so writes
+// block once the socket buffers are full
+func startStalledBackend(tb testing.TB) config.Config {
+	tb.Helper()
+	release := make(chan struct{})
+	cfg, _ := startBackendWith(tb, backendHooks{onConnect: func(*websocket.Conn) bool {
+		<-release
+		return false
+	}})
+	tb.Cleanup(func() { close(release) })
+	cfg.HeloAckTimeout = 10 * time.Millisecond // Nothing is read, so nothing is answered
+	return cfg
+}
+
+// bigLine is a log line at the default size limit, so few of them fill the socket buffers
+var bigLine = strings.Repeat("x", models.DefaultMaxLineBytes)
+
+// TestClose_WritesQueuedMessages verifies messages still queued when the client is closed are written in order
+func TestClose_WritesQueuedMessages(t *testing.T) {
+	cfg, frames := startBackend(t)
+	client := NewClient(cfg)
+	assert.NoError(t, client.Connect())
+	receiveUntil(t, frames, models.TypeRunnerCapacity)
+
+	for i := 0; i < 50; i++ {
+		msg := models.NewLogMessage(41, "line", false)
+		msg.LineIndex = int64(i)
+		assert.NoError(t, client.sendJSON(&msg))
+	}
+	client.Close()
+
+	var indexes []int64
+	for len(indexes) < 50 {
+		var f struct {
+			Type      string `json:"type"`
+			LineIndex int64  `json:"lineIndex"`
+		}
+		select {
+		case data := <-frames:
+			assert.NoError(t, json.Unmarshal(data, &f))
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only %d of 50 queued lines arrived", len(indexes))
+		}
+		if f.Type == models.TypeLog {
+			indexes = append(indexes, f.LineIndex)
+		}
+	}
+	for i, index := range indexes {
+		assert.Equal(t, int64(i), index)
+	}
+	msg := models.NewLogMessage(41, "late", false)
+	assert.ErrorIs(t, client.sendJSON(&msg), ErrClientClosed)
+}
+
+// TestClose_StalledBackend verifies sends do not wait for a backend that stopped reading, and Close gives up on
+// what is still queued after outboxFlushTimeout and on the close handshake after closeHandshakeTimeout
+func TestClose_StalledBackend(t *testing.T) {
+	defer func(flush, handshake time.Duration) {
+		outboxFlushTimeout, closeHandshakeTimeout = flush, handshake
+	}(outboxFlushTimeout, closeHandshakeTimeout)
+	outboxFlushTimeout = 200 * time.Millisecond
+	closeHandshakeTimeout = 200 * time.Millisecond
+	client := NewClient(startStalledBackend(t))
+	assert.NoError(t, client.Connect())
+
+	start := time.Now()
+	for i := 0; i < outboxSize/2; i++ {
+		msg := models.NewLogMessage(42, bigLine, false)
+		assert.NoError(t, client.sendJSON(&msg))
+	}
+	assert.Less(t, time.Since(start), time.Second, "Queueing does not wait for the network")
+
+	start = time.Now()
+	client.Close()
+	assert.Less(t, time.Since(start), 2*time.Second)
+	msg := models.NewLogMessage(42, "late", false)
+	assert.ErrorIs(t, client.sendJSON(&msg), ErrClientClosed)
+	client.Ping() // Returns at once rather than waiting for a writer that has gone
+}
+
+// BenchmarkSendLogMessage_StalledBackend measures the executor's log callback while the backend is not reading
+// Before the outbox each call held the send path for a write that could block for the full write timeout.
+func BenchmarkSendLogMessage_StalledBackend(b *testing.B) {
+	defer func(n int, d time.Duration) { outboxSize, outboxFlushTimeout = n, d }(outboxSize, outboxFlushTimeout)
+	outboxSize, outboxFlushTimeout = b.N+16, 100*time.Millisecond
+	client := NewClient(startStalledBackend(b))
+	if err := client.Connect(); err != nil {
+		b.Fatal(err)
+	}
+	defer client.Close()
+
+	msg := models.NewLogMessage(43, bigLine, false)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		client.sendLogMessage(msg)
+	}
+}
+
+// TestClose_WhileStreaming verifies closing the client while tasks are streaming output, with Listen
+// running, neither panics nor races: output that arrives after Close is counted and discarded
+func TestClose_WhileStreaming(t *testing.T) {
+	testutil.FakeClaude(t, "i=0; while [ $i -lt 10000 ]; do echo line $i; i=$((i+1)); done")
+	for round := 0; round < 3; round++ {
+		cfg, frames := startBackend(t)
+		stop := make(chan struct{})
+		go func() {
+			for {
+				select {
+				case <-frames:
+				case <-stop:
+					return
+				}
+			}
+		}()
+		client := NewClient(cfg)
+		assert.NoError(t, client.Connect())
+		listenDone := make(chan error, 1)
+		go func() { listenDone <- client.Listen() }()
+		for id := int64(1); id <= 3; id++ {
+			client.handleExecute(models.ExecuteMessage{Type: models.TypeExecute, TaskID: id, ScriptContent: "stream"})
+		}
+		time.Sleep(50 * time.Millisecond)
+
+		client.Close()
+		select {
+		case <-listenDone:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Listen did not return after Close")
+		}
+		client.sendLogMessage(models.NewLogMessage(1, "late", false))
+		assert.Positive(t, client.discarded.Load())
+		close(stop)
+	}
+}
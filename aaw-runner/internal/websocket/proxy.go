@@ -0,0 +1,78 @@
+package websocket
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/berno/aaw-runner/internal/config"
+)
+
+// proxyFunc picks the proxy the backend is dialled through: AAW_PROXY_URL when set, otherwise the
+// standard HTTPS_PROXY, HTTP_PROXY and NO_PROXY variables. User and password in the URL authenticate
+// to the proxy (Basic auth for HTTP CONNECT, username/password for SOCKS5).
+func proxyFunc(cfg config.Config) (func(*http.Request) (*url.URL, error), error) {
+	if cfg.ProxyURL == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+	proxy, err := url.Parse(cfg.ProxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid AAW_PROXY_URL: %w", err)
+	}
+	// The websocket library speaks HTTP CONNECT to http:// proxies and SOCKS5 to socks5:// ones, nothing else
+	if proxy.Scheme != "http" && proxy.Scheme != "socks5" {
+		return nil, fmt.Errorf("unsupported AAW_PROXY_URL scheme %q (http or socks5)", proxy.Scheme)
+	}
+	if proxy.Host == "" {
+		return nil, fmt.Errorf("AAW_PROXY_URL %s has no host", proxy.Redacted())
+	}
+	return http.ProxyURL(proxy), nil
+}
+
+// proxyFor returns the proxy the backend URL is dialled through, or nil when it is dialled directly
+func (c *Client) proxyFor() *url.URL {
+	u, err := url.Parse(c.serverURL)
+	if err != nil || c.dialer == nil || c.dialer.Proxy == nil {
+		return nil
+	}
+	// The proxy is picked for the HTTP request the handshake starts with
+	u.Scheme = strings.Replace(u.Scheme, "ws", "http", 1)
+	proxy, _ := c.dialer.Proxy(&http.Request{URL: u})
+	return proxy
+}
+
+// describeProxyError says whether the proxy or the backend behind it refused a connection made through
+// a proxy; other errors, and all errors without a proxy, are returned as they are
+func (c *Client) describeProxyError(err error) error {
+	proxy := c.proxyFor()
+	if proxy == nil {
+		return err
+	}
+
+	// Through a proxy the only connection dialled directly is the one to the proxy
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return fmt.Errorf("proxy %s refused the connection: %w", proxy.Redacted(), err)
+	}
+
+	// SOCKS5 failures are prefixed "proxy:"; the proxy reports its own failure to reach the backend
+	msg := err.Error()
+	if strings.HasPrefix(msg, "proxy: ") {
+		if strings.Contains(msg, "failed to connect") {
+			return fmt.Errorf("backend refused the connection through proxy %s: %w", proxy.Redacted(), err)
+		}
+		return fmt.Errorf("proxy %s refused the connection: %w", proxy.Redacted(), err)
+	}
+
+	// A CONNECT answered with anything but 200 comes back as the bare reason phrase
+	switch msg {
+	case http.StatusText(http.StatusProxyAuthRequired), http.StatusText(http.StatusForbidden):
+		return fmt.Errorf("proxy %s refused the connection: %w", proxy.Redacted(), err)
+	case http.StatusText(http.StatusBadGateway), http.StatusText(http.StatusServiceUnavailable), http.StatusText(http.StatusGatewayTimeout):
+		return fmt.Errorf("backend refused the connection through proxy %s: %w", proxy.Redacted(), err)
+	}
+	return err
+}
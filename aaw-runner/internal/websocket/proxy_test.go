@@ -0,0 +1,87 @@
+package websocket
+
+import (
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/berno/aaw-runner/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// startConnectProxy starts an HTTP CONNECT proxy that requires user:password and counts the tunnels it opens
+func startConnectProxy(t *testing.T, user, password string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var tunnels atomic.Int32
+	want := "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "CONNECT only", http.StatusMethodNotAllowed)
+			return
+		}
+		if r.Header.Get("Proxy-Authorization") != want {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		backend, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer backend.Close()
+		w.WriteHeader(http.StatusOK)
+		client, _, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			return
+		}
+		defer client.Close()
+		tunnels.Add(1)
+		go io.Copy(backend, client)
+		io.Copy(client, backend)
+	}))
+	t.Cleanup(proxy.Close)
+	return proxy, &tunnels
+}
+
+// TestConnect_Proxy verifies the backend is dialled through an authenticating CONNECT proxy, and a failed dial
+// says whether the proxy or the backend refused
+func TestConnect_Proxy(t *testing.T) {
+	backendCfg, _ := startBackend(t)
+	proxy, tunnels := startConnectProxy(t, "runner", "s3cret")
+	proxyURL := func(password string) string {
+		return "http://runner:" + password + "@" + strings.TrimPrefix(proxy.URL, "http://")
+	}
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	connect := func(backendURL, proxyURL string) error {
+		cfg := config.Default()
+		cfg.BackendURL = backendURL
+		cfg.ProxyURL = proxyURL
+		client := NewClient(cfg)
+		t.Cleanup(func() { client.Close() })
+		return client.Connect()
+	}
+
+	assert.NoError(t, connect(backendCfg.BackendURL, proxyURL("s3cret")))
+	assert.Equal(t, int32(1), tunnels.Load(), "The backend is reached through the proxy")
+
+	err := connect(backendCfg.BackendURL, proxyURL("wrong"))
+	assert.ErrorContains(t, err, "proxy http://runner:xxxxx@")
+	assert.ErrorContains(t, err, "refused the connection: Proxy Authentication Required")
+	assert.NotContains(t, err.Error(), "wrong", "The proxy password is not logged")
+
+	err = connect("ws"+strings.TrimPrefix(closed.URL, "http"), proxyURL("s3cret"))
+	assert.ErrorContains(t, err, "backend refused the connection through proxy")
+
+	err = connect(backendCfg.BackendURL, "http://"+strings.TrimPrefix(closed.URL, "http://"))
+	assert.ErrorContains(t, err, "proxy http://127.0.0.1")
+	assert.ErrorContains(t, err, "refused the connection: dial tcp")
+
+	assert.ErrorContains(t, connect(backendCfg.BackendURL, "https://proxy.corp:3128"), `unsupported AAW_PROXY_URL scheme "https"`)
+}
@@ -0,0 +1,89 @@
+package websocket
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/gorilla/websocket"
+)
+
+// reader is the goroutine reading one connection, and the only owner of closing it
+// Connect starts it so it can wait for HELO_ACK; listen then handles everything else it reads.
+type reader struct {
+	conn      *websocket.Conn
+	messages  chan []byte   // Closed once reading fails
+	err       error         // Why reading failed, set before messages is closed
+	done      chan struct{} // Closed once the connection is closed
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// close closes the connection, which ends the read loop. Only the first call closes it; later ones
+// get the same result rather than "use of closed network connection".
+func (r *reader) close() error {
+	r.closeOnce.Do(func() { r.closeErr = r.conn.Close() })
+	return r.closeErr
+}
+
+// startReader reads conn until it fails, with keepAlive pinging the backend meanwhile
+func (c *Client) startReader(conn *websocket.Conn) *reader {
+	r := &reader{conn: conn, messages: make(chan []byte), done: make(chan struct{})}
+	stop := make(chan struct{})
+	c.keepAlive(r, stop)
+	go func() {
+		defer close(r.done)
+		defer r.close()
+		defer close(stop)
+		defer close(r.messages)
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				r.err = err
+				return
+			}
+			c.audit.Received(message)
+			select {
+			case r.messages <- message:
+			case <-c.closing:
+				// Nothing is handled once the client is closed; keep reading until the close frame
+			}
+		}
+	}()
+	return r
+}
+
+// awaitHeloAck waits for the backend's HELO_ACK and returns the schema version it picks. A backend that
+// sends something else first, or nothing within HeloAckTimeout, predates negotiation: it gets the legacy
+// format, and the message it sent is returned for listen to handle.
+func (c *Client) awaitHeloAck(r *reader) (int, []byte, error) {
+	select {
+	case message, ok := <-r.messages:
+		if !ok {
+			return 0, nil, fmt.Errorf("connection lost before HELO_ACK: %w", r.err)
+		}
+		if msg, err := models.DecodeIncoming(message); err == nil {
+			if ack, ok := msg.(*models.HeloAckMessage); ok {
+				return negotiatedVersion(*ack), nil, nil
+			}
+		}
+		log.Printf("[WS] Backend did not start with HELO_ACK, using schema version %d", models.SchemaVersionLegacy)
+		return models.SchemaVersionLegacy, message, nil
+	case <-time.After(c.cfg.HeloAckTimeout):
+		log.Printf("[WS] No HELO_ACK within %s, using schema version %d", c.cfg.HeloAckTimeout, models.SchemaVersionLegacy)
+		return models.SchemaVersionLegacy, nil, nil
+	}
+}
+
+// negotiatedVersion returns the schema version to emit for the backend's HELO_ACK
+func negotiatedVersion(msg models.HeloAckMessage) int {
+	version := models.NegotiateSchemaVersion(msg.ProtocolVersion)
+	if version != msg.ProtocolVersion {
+		log.Printf("[WS] Server protocol version %d unsupported (supported %d-%d), using %d",
+			msg.ProtocolVersion, models.MinSchemaVersion, models.SchemaVersion, version)
+	}
+	log.Printf("[WS] HELO_ACK received: schemaVersion=%d", version)
+	return version
+}
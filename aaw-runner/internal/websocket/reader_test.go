@@ -0,0 +1,105 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/config"
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// startReplyingBackend starts a backend that answers HELO with reply (nothing when empty) and records
+// the schemaVersion of every frame after it
+func startReplyingBackend(t *testing.T, reply string) (config.Config, chan frameVersion) {
+	t.Helper()
+	frames := make(chan frameVersion, 64)
+	cfg, _ := startBackendWith(t, backendHooks{noHeloAck: true, onFrame: func(conn *websocket.Conn, _ int, data []byte) bool {
+		var f frameVersion
+		json.Unmarshal(data, &f)
+		if f.Type == models.TypeHelo && reply != "" {
+			conn.WriteMessage(websocket.TextMessage, []byte(reply))
+		}
+		frames <- f
+		return true
+	}})
+	cfg.HeloAckTimeout = 100 * time.Millisecond
+	return cfg, frames
+}
+
+// frameVersion holds the fields of a received frame the negotiation tests look at
+type frameVersion struct {
+	Type            string `json:"type"`
+	TaskID          int64  `json:"taskId"`
+	SchemaVersion   int    `json:"schemaVersion"`
+	ProtocolVersion int    `json:"protocolVersion"`
+}
+
+// TestConnect_NegotiatesVersion verifies Connect waits for HELO_ACK and emits the version it picks, and
+// falls back to the legacy format for a backend that does not answer
+func TestConnect_NegotiatesVersion(t *testing.T) {
+	for name, tc := range map[string]struct {
+		reply string
+		want  int
+	}{
+		"current":     {`{"type":"HELO_ACK","protocolVersion":2}`, models.SchemaVersion},
+		"legacy":      {`{"type":"HELO_ACK","protocolVersion":1}`, models.SchemaVersionLegacy},
+		"newer":       {`{"type":"HELO_ACK","protocolVersion":9}`, models.SchemaVersion},
+		"no HELO_ACK": {"", models.SchemaVersionLegacy},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			cfg, frames := startReplyingBackend(t, tc.reply)
+			client := NewClient(cfg)
+			t.Cleanup(func() { client.Close() })
+			assert.NoError(t, client.Connect())
+			assert.Equal(t, tc.want, client.SchemaVersion())
+
+			helo := <-frames
+			assert.Equal(t, models.TypeHelo, helo.Type)
+			assert.Equal(t, models.SchemaVersion, helo.ProtocolVersion)
+			next := <-frames
+			assert.Equal(t, models.TypeRunnerStatus, next.Type, "Nothing is sent before negotiation")
+			if tc.want == models.SchemaVersionLegacy {
+				assert.Zero(t, next.SchemaVersion)
+			} else {
+				assert.Equal(t, tc.want, next.SchemaVersion)
+			}
+		})
+	}
+}
+
+// TestConnect_LegacyBackendMessageFirst verifies a backend that sends a task instead of HELO_ACK gets the
+// legacy format at once, and the task still runs
+func TestConnect_LegacyBackendMessageFirst(t *testing.T) {
+	testutil.FakeClaude(t, "echo done")
+	cfg, frames := startReplyingBackend(t, `{"type":"EXECUTE","taskId":61,"scriptContent":"hello"}`)
+	cfg.HeloAckTimeout = time.Minute
+	client := NewClient(cfg)
+	listenDone := make(chan error, 1)
+	t.Cleanup(func() {
+		client.Close()
+		<-listenDone
+	})
+
+	start := time.Now()
+	assert.NoError(t, client.Connect())
+	assert.Less(t, time.Since(start), 5*time.Second, "Connect does not wait out the timeout")
+	assert.Equal(t, models.SchemaVersionLegacy, client.SchemaVersion())
+	go func() { listenDone <- client.Listen() }()
+
+	for {
+		select {
+		case f := <-frames:
+			if f.Type == models.TypeTaskCompleted {
+				assert.Equal(t, int64(61), f.TaskID)
+				return
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("task 61 did not complete")
+		}
+	}
+}
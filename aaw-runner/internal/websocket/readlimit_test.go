@@ -0,0 +1,78 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/config"
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// startOversizedBackend starts a backend that answers the first HELO with a frame of size bytes, and reports
+// every frame it receives along with the code of the close frame that ends the first connection
+func startOversizedBackend(t *testing.T, size int) (config.Config, chan connFrame, chan int) {
+	t.Helper()
+	frames := make(chan connFrame, 64)
+	closeCodes := make(chan int, 1)
+	cfg, _ := startBackendWith(t, backendHooks{
+		onFrame: func(conn *websocket.Conn, n int, data []byte) bool {
+			var f frame
+			json.Unmarshal(data, &f)
+			if n == 1 && f.Type == models.TypeHelo {
+				conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"EXECUTE","taskId":1,"scriptContent":"`+strings.Repeat("x", size)+`"}`))
+			}
+			frames <- connFrame{n, f}
+			return true
+		},
+		onClose: func(n int, err error) {
+			var closeErr *websocket.CloseError
+			if n == 1 && errors.As(err, &closeErr) {
+				closeCodes <- closeErr.Code
+			}
+		},
+	})
+	cfg.ReconnectBaseBackoff = 10 * time.Millisecond
+	return cfg, frames, closeCodes
+}
+
+// TestListen_OversizedMessage verifies a message over MaxMessageBytes drops the connection with close 1009,
+// and the runner reports it with a TOO_LARGE MESSAGE_ERROR once it has reconnected
+func TestListen_OversizedMessage(t *testing.T) {
+	cfg, frames, closeCodes := startOversizedBackend(t, 4096)
+	cfg.MaxMessageBytes = 1024
+	client := NewClient(cfg)
+	listenDone := make(chan error, 1)
+	t.Cleanup(func() {
+		client.Close()
+		<-listenDone
+	})
+	assert.NoError(t, client.Connect())
+	go func() { listenDone <- client.Listen() }()
+
+	select {
+	case code := <-closeCodes:
+		assert.Equal(t, websocket.CloseMessageTooBig, code)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no close frame received")
+	}
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case f := <-frames:
+			if f.Type != models.TypeMessageError {
+				continue
+			}
+			assert.Equal(t, 2, f.conn, "Reported on the new connection")
+			assert.Equal(t, models.MessageErrorTooLarge, f.Code)
+			return
+		case <-timeout:
+			t.Fatal("no MESSAGE_ERROR after reconnecting")
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/models"
+)
+
+// ConnectWithRetry makes the first connection to the backend, retrying with the reconnect backoff while
+// it cannot be reached, as when the runner starts before the backend. It gives up once ConnectMaxRetries
+// retries have failed (never when 0), at once for a TLS setup that cannot work, or when ctx is done or
+// the client closes.
+func (c *Client) ConnectWithRetry(ctx context.Context) error {
+	for attempt := 1; ; attempt++ {
+		err := c.connect(ctx)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("connecting to the backend interrupted: %w", ctx.Err())
+		}
+		if err == c.dialErr {
+			return err
+		}
+		log.Printf("[WS] Connect attempt %d failed: %v", attempt, err)
+		c.noteError(err)
+		c.statusFile.Notify()
+		if c.cfg.ConnectMaxRetries > 0 && attempt > c.cfg.ConnectMaxRetries {
+			return fmt.Errorf("giving up after %d connect retries: %w", c.cfg.ConnectMaxRetries, err)
+		}
+
+		wait := backoff(c.cfg.ReconnectBaseBackoff, c.cfg.ReconnectMaxBackoff, attempt)
+		log.Printf("[WS] Retrying the connect in %s", wait.Round(time.Millisecond))
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("connecting to the backend interrupted: %w", ctx.Err())
+		case <-c.closing:
+			return err
+		case <-time.After(wait):
+		}
+	}
+}
+
+// reconnect redials the backend after the connection ended with cause, waiting longer after each
+// failed attempt. Connect re-sends HELO and RUNNER_CAPACITY; the pool keeps running throughout.
+// It gives up once MaxReconnectAttempts attempts in a row have failed (never when 0), or the client
+// stops, and returns the error to exit with.
+func (c *Client) reconnect(cause error) error {
+	log.Printf("[WS] Connection lost: %v", cause)
+	err := cause
+	for attempt := 1; c.cfg.MaxReconnectAttempts == 0 || attempt <= c.cfg.MaxReconnectAttempts; attempt++ {
+		wait := backoff(c.cfg.ReconnectBaseBackoff, c.cfg.ReconnectMaxBackoff, attempt)
+		log.Printf("[WS] Reconnecting in %s (attempt %d)", wait.Round(time.Millisecond), attempt)
+		c.history.record("Reconnecting in %s (attempt %d)", wait.Round(time.Second), attempt)
+		select {
+		case <-c.closing:
+			return cause
+		case <-time.After(wait):
+		}
+		if c.stopping() {
+			return cause
+		}
+
+		if err = c.Connect(); err == nil {
+			c.sendResync()
+			c.reportOutage()
+			return nil
+		}
+		log.Printf("[WS] Reconnect attempt %d failed: %v", attempt, err)
+		c.noteError(err)
+		c.statusFile.Notify()
+	}
+	return fmt.Errorf("giving up after %d reconnect attempts: %w", c.cfg.MaxReconnectAttempts, err)
+}
+
+// sendResync follows a successful redial with RUNNER_RESYNC, listing the tasks the pool holds so the
+// backend can reconcile what it recorded while the connection was down
+func (c *Client) sendResync() {
+	snap := c.pool.Snapshot()
+	tasks := make([]models.ResyncTask, len(snap.Tasks))
+	for i, task := range snap.Tasks {
+		tasks[i] = models.ResyncTask{TaskID: task.TaskID, State: task.State.String()}
+		if !task.StartedAt.IsZero() {
+			tasks[i].StartedAt = task.StartedAt.UTC().Format(time.RFC3339)
+			tasks[i].ElapsedMs = snap.TakenAt.Sub(task.StartedAt).Milliseconds()
+		}
+	}
+	msg := models.NewRunnerResync(tasks, snap.MaxParallel, snap.Running, snap.Available)
+	msg.RunnerID = c.runnerID
+
+	log.Printf("[WS] Sending RUNNER_RESYNC: %d tasks, running=%d, available=%d", len(tasks), snap.Running, snap.Available)
+	if err := c.sendJSON(&msg); err != nil {
+		log.Printf("Failed to send runner resync: %v", err)
+	}
+}
+
+// reportOutage follows a successful redial with a system LOG telling the backend when the connection
+// was lost and restored, and what became of the messages sent meanwhile, so a gap in a task's output
+// can be told apart from a stalled task. The messages kept are sent ahead of it.
+func (c *Client) reportOutage() {
+	c.connMutex.Lock()
+	down := c.downAt
+	c.connMutex.Unlock()
+	up := time.Now()
+	held, dropped := c.offlineHeld.Swap(0), c.offlineDropped.Swap(0)
+
+	line := fmt.Sprintf("[runner] Connection to the backend lost at %s and restored at %s (down %s); %d messages sent meanwhile were replayed, %d dropped",
+		down.UTC().Format(time.RFC3339), up.UTC().Format(time.RFC3339), up.Sub(down).Round(time.Second), held, dropped)
+	severity := "info"
+	if dropped > 0 {
+		severity = "warn"
+	}
+	msg := models.NewSystemLog(line, severity)
+	log.Printf("[WS] %s", line)
+	if err := c.sendJSON(&msg); err != nil {
+		log.Printf("Failed to send outage report: %v", err)
+	}
+}
+
+// stopping reports whether the client is closed or shutting down, so a lost connection is not redialled
+func (c *Client) stopping() bool {
+	select {
+	case <-c.closing:
+		return true
+	default:
+		return c.shuttingDown.Load()
+	}
+}
+
+// backoff returns the wait before redial attempt n (from 1): base doubled for each earlier attempt and
+// capped at limit, of which up to half is random so runners dropped together do not redial in step
+func backoff(base, limit time.Duration, attempt int) time.Duration {
+	d := limit
+	if shift := attempt - 1; shift < 32 && base<<shift > 0 && base<<shift < limit {
+		d = base << shift
+	}
+	return d/2 + rand.N(d/2+1)
+}
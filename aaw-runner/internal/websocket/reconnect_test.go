@@ -0,0 +1,292 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/config"
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// connFrame is a frame received by a backend that drops connections, with the connection it came on
+type connFrame struct {
+	conn int // 1 for the first connection
+	frame
+}
+
+// startDroppingBackend is startBackend, except that the first connection is dropped once its
+// RUNNER_CAPACITY has arrived and drop is closed
+func startDroppingBackend(t *testing.T, drop chan struct{}) (config.Config, chan connFrame, *httptest.Server) {
+	t.Helper()
+	frames := make(chan connFrame, 64)
+	cfg, server := startBackendWith(t, backendHooks{onFrame: func(_ *websocket.Conn, n int, data []byte) bool {
+		var f frame
+		json.Unmarshal(data, &f)
+		frames <- connFrame{n, f}
+		if n == 1 && f.Type == models.TypeRunnerCapacity {
+			<-drop
+			return false
+		}
+		return true
+	}})
+	cfg.ReconnectBaseBackoff = 10 * time.Millisecond
+	cfg.ReconnectMaxBackoff = 50 * time.Millisecond
+	return cfg, frames, server
+}
+
+// TestListen_Reconnects verifies a dropped connection is redialled with HELO and RUNNER_CAPACITY while tasks keep running
+func TestListen_Reconnects(t *testing.T) {
+	testutil.FakeClaude(t, "sleep 0.5; echo done")
+	drop := make(chan struct{})
+	cfg, frames, _ := startDroppingBackend(t, drop)
+	client := NewClient(cfg)
+	assert.NoError(t, client.Connect())
+	listenDone := make(chan error, 1)
+	go func() { listenDone <- client.Listen() }()
+	t.Cleanup(func() {
+		client.Close()
+		<-listenDone
+	})
+
+	client.handleExecute(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 91, ScriptContent: "hello"})
+	close(drop) // Mid-task
+
+	var second []string
+	for f := range frames {
+		if f.conn == 1 {
+			continue
+		}
+		second = append(second, f.Type)
+		if f.Type == models.TypeTaskCompleted {
+			assert.Equal(t, int64(91), f.TaskID)
+			break
+		}
+	}
+	assert.Equal(t, models.TypeHelo, second[0], "The new connection starts with HELO")
+	assert.Contains(t, second, models.TypeRunnerCapacity)
+	assert.True(t, client.Connected())
+	assert.True(t, client.PoolRunning())
+}
+
+// TestListen_Resyncs verifies the new connection gets a RUNNER_RESYNC listing a task that kept running across
+// the drop, while the first connection does not
+func TestListen_Resyncs(t *testing.T) {
+	testutil.FakeClaude(t, "sleep 1; echo done")
+	drop := make(chan struct{})
+	cfg, frames, _ := startDroppingBackend(t, drop)
+	client := NewClient(cfg)
+	assert.NoError(t, client.Connect())
+	listenDone := make(chan error, 1)
+	go func() { listenDone <- client.Listen() }()
+	t.Cleanup(func() {
+		client.Close()
+		<-listenDone
+	})
+
+	client.handleExecute(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 92, ScriptContent: "hello"})
+	assert.Eventually(t, func() bool {
+		tasks := client.pool.Tasks()
+		return len(tasks) == 1 && !tasks[0].StartedAt.IsZero()
+	}, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	close(drop) // Mid-task
+
+	for f := range frames {
+		assert.NotEqual(t, models.TypeTaskCompleted, f.Type, "Task finished before the resync")
+		if f.Type != models.TypeRunnerResync {
+			continue
+		}
+		assert.Equal(t, 2, f.conn, "Only sent after a reconnect")
+		if assert.Len(t, f.Tasks, 1) {
+			task := f.Tasks[0]
+			assert.Equal(t, int64(92), task.TaskID)
+			assert.Equal(t, models.ResyncRunning, task.State)
+			assert.NotEmpty(t, task.StartedAt)
+			assert.GreaterOrEqual(t, task.ElapsedMs, int64(100))
+		}
+		assert.Equal(t, 1, f.RunningTasks)
+		return
+	}
+}
+
+// TestListen_GivesUp verifies Listen returns once MaxReconnectAttempts redials in a row have failed
+func TestListen_GivesUp(t *testing.T) {
+	drop := make(chan struct{})
+	cfg, _, server := startDroppingBackend(t, drop)
+	cfg.MaxReconnectAttempts = 3
+	client := NewClient(cfg)
+	assert.NoError(t, client.Connect())
+	t.Cleanup(func() { client.Close() })
+
+	close(drop)
+	server.Close()
+	err := client.Listen()
+	assert.ErrorContains(t, err, "giving up after 3 reconnect attempts")
+	lastErr, _ := client.LastError()
+	assert.Contains(t, lastErr, "connection refused")
+}
+
+// TestBackoff verifies redial waits double from the base up to the cap, each with up to half of it random
+func TestBackoff(t *testing.T) {
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 7: time.Minute, 100: time.Minute} {
+		for i := 0; i < 20; i++ {
+			got := backoff(time.Second, time.Minute, attempt)
+			assert.GreaterOrEqual(t, got, want/2, "attempt %d", attempt)
+			assert.LessOrEqual(t, got, want, "attempt %d", attempt)
+		}
+	}
+	assert.LessOrEqual(t, backoff(time.Hour, time.Minute, 1), time.Minute, "The cap wins over a larger base")
+}
+
+// TestConnect_Timeout verifies connecting to a backend that never answers gives up at the connect timeout,
+// on the first connect and on each redial, naming the limit
+func TestConnect_Timeout(t *testing.T) {
+	// Accepts connections but never answers the handshake
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+
+	cfg := config.Default()
+	cfg.BackendURL = "ws://" + listener.Addr().String()
+	cfg.ConnectTimeout = 100 * time.Millisecond
+	cfg.ReconnectBaseBackoff = time.Millisecond
+	cfg.MaxReconnectAttempts = 2
+	client := NewClient(cfg)
+	t.Cleanup(func() { client.Close() })
+
+	start := time.Now()
+	err = client.Connect()
+	assert.ErrorContains(t, err, "backend did not answer within the connect timeout of 100ms")
+	assert.Less(t, time.Since(start), time.Second)
+
+	start = time.Now()
+	err = client.reconnect(err)
+	assert.ErrorContains(t, err, "giving up after 2 reconnect attempts")
+	assert.ErrorContains(t, err, "connect timeout of 100ms")
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+// failingDials makes the client's dialer fail its first n dials, counting every dial
+func failingDials(client *Client, n int32) *atomic.Int32 {
+	var dials atomic.Int32
+	var d net.Dialer
+	client.dialer.NetDialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if dials.Add(1) <= n {
+			return nil, errors.New("connection refused")
+		}
+		return d.DialContext(ctx, network, addr)
+	}
+	return &dials
+}
+
+// TestConnectWithRetry verifies the first connect is retried until the backend answers, or given up
+// after ConnectMaxRetries retries
+func TestConnectWithRetry(t *testing.T) {
+	cfg, frames := startBackend(t)
+	cfg.ReconnectBaseBackoff = time.Millisecond
+	cfg.ReconnectMaxBackoff = 10 * time.Millisecond
+
+	client := NewClient(cfg)
+	t.Cleanup(func() { client.Close() })
+	dials := failingDials(client, 3)
+	assert.NoError(t, client.ConnectWithRetry(context.Background()))
+	assert.Equal(t, int32(4), dials.Load())
+	assert.True(t, client.Connected())
+	assert.Equal(t, models.TypeHelo, receiveUntil(t, frames, models.TypeHelo)[0].Type)
+
+	cfg.ConnectMaxRetries = 2
+	limited := NewClient(cfg)
+	t.Cleanup(func() { limited.Close() })
+	dials = failingDials(limited, 5)
+	err := limited.ConnectWithRetry(context.Background())
+	assert.ErrorContains(t, err, "giving up after 2 connect retries")
+	assert.ErrorContains(t, err, "connection refused")
+	assert.Equal(t, int32(3), dials.Load())
+}
+
+// TestConnectWithRetry_Cancelled verifies cancelling the context stops the retries at once
+func TestConnectWithRetry_Cancelled(t *testing.T) {
+	cfg, _ := startBackend(t)
+	cfg.ReconnectBaseBackoff = time.Minute
+	client := NewClient(cfg)
+	t.Cleanup(func() { client.Close() })
+	failingDials(client, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- client.ConnectWithRetry(ctx) }()
+	time.Sleep(50 * time.Millisecond) // In the backoff wait
+	cancel()
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("ConnectWithRetry did not return")
+	}
+	assert.False(t, client.Connected())
+}
+
+// TestReconnect_ReportsOutage verifies a reconnect is followed by a system LOG giving when the connection
+// was down and what became of the messages sent meanwhile, after the messages it replays
+func TestReconnect_ReportsOutage(t *testing.T) {
+	drop := make(chan struct{})
+	cfg, frames, _ := startDroppingBackend(t, drop)
+	cfg.ReconnectBaseBackoff = 300 * time.Millisecond // Time to send while disconnected
+	cfg.ReconnectMaxBackoff = 300 * time.Millisecond
+	client := NewClient(cfg)
+	assert.NoError(t, client.Connect())
+	listenDone := make(chan error, 1)
+	go func() { listenDone <- client.Listen() }()
+	t.Cleanup(func() {
+		client.Close()
+		<-listenDone
+	})
+
+	close(drop)
+	assert.Eventually(t, func() bool { return !client.Connected() }, 5*time.Second, time.Millisecond)
+	client.sendStatusUpdate(models.NewStatusUpdate(5, models.StatusRunning)) // Kept
+	client.sendRunnerStatus(client.stateMachine.GetState())                  // Dropped: sent afresh on connect
+
+	var second []frame
+	deadline := time.After(5 * time.Second)
+	for len(second) == 0 || !second[len(second)-1].System {
+		select {
+		case cf := <-frames:
+			if cf.conn == 2 {
+				second = append(second, cf.frame)
+			}
+		case <-deadline:
+			t.Fatalf("No outage report after reconnecting (got %v)", second)
+		}
+	}
+	report := second[len(second)-1]
+	assert.Equal(t, models.TypeLog, report.Type)
+	assert.Zero(t, report.TaskID)
+	assert.Contains(t, report.Line, "Connection to the backend lost at ")
+	assert.Contains(t, report.Line, "1 messages sent meanwhile were replayed, 1 dropped")
+	var updates int
+	for _, f := range second {
+		if f.Type == models.TypeStatusUpdate {
+			updates++
+		}
+	}
+	assert.Equal(t, 1, updates, "The kept STATUS_UPDATE is replayed ahead of the report")
+}
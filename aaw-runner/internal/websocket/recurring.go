@@ -0,0 +1,53 @@
+package websocket
+
+import (
+	"log"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/recurring"
+)
+
+// SetRecurring runs the recurrences registered with RECURRING_EXECUTE on s; without it they are refused
+// Must be called before Connect.
+func (c *Client) SetRecurring(s *recurring.Scheduler) {
+	c.recurring = s
+	s.Start(c.recurrenceReady, c.fireRecurrence)
+}
+
+// recurrenceReady reports whether a recurrence's tick can run now rather than being missed
+func (c *Client) recurrenceReady() bool {
+	return c.connected.Load() && !c.pool.Draining()
+}
+
+// fireRecurrence announces an instance of a recurrence and submits it like an EXECUTE
+func (c *Client) fireRecurrence(fired models.RecurrenceFiredMessage, task models.ExecuteMessage) {
+	c.history.record("Recurrence %s fired as task %d", fired.RecurrenceID, fired.TaskID)
+	if err := c.sendJSON(&fired); err != nil {
+		log.Printf("Failed to send recurrence fired: %v", err)
+	}
+	c.handleExecute(task)
+}
+
+// handleRecurringExecute registers a recurrence, replacing any with the same ID
+func (c *Client) handleRecurringExecute(msg models.RecurringExecuteMessage) {
+	if err := c.recurring.Register(msg); err != nil {
+		log.Printf("[RECURRING] Failed to register %s: %v", msg.RecurrenceID, err)
+		return
+	}
+	log.Printf("[RECURRING] Registered %s", msg.RecurrenceID)
+	c.history.record("Recurrence %s registered", msg.RecurrenceID)
+}
+
+// handleCancelRecurring removes a recurrence; instances already fired carry on
+func (c *Client) handleCancelRecurring(msg models.CancelRecurringMessage) {
+	removed, err := c.recurring.Cancel(msg.RecurrenceID)
+	switch {
+	case err != nil:
+		log.Printf("[RECURRING] Failed to cancel %s: %v", msg.RecurrenceID, err)
+	case !removed:
+		log.Printf("[RECURRING] Ignoring cancel of unknown recurrence %s", msg.RecurrenceID)
+	default:
+		log.Printf("[RECURRING] Cancelled %s", msg.RecurrenceID)
+		c.history.record("Recurrence %s cancelled", msg.RecurrenceID)
+	}
+}
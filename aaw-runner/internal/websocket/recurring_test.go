@@ -0,0 +1,40 @@
+package websocket
+
+import (
+	"testing"
+
+	"github.com/berno/aaw-runner/internal/config"
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/recurring"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRecurringExecute_FiresInstances verifies each tick is announced with RECURRENCE_FIRED and runs as a task
+func TestRecurringExecute_FiresInstances(t *testing.T) {
+	testutil.FakeClaude(t, "echo checked")
+	cfg, frames := startBackend(t)
+	client := NewClient(cfg)
+	s, err := recurring.Open(t.TempDir(), config.CatchUpSkip)
+	assert.NoError(t, err)
+	client.SetRecurring(s)
+	assert.NoError(t, client.Connect())
+	t.Cleanup(func() {
+		s.Close()
+		client.pool.KillAll()
+		client.Close()
+	})
+
+	client.handleRecurringExecute(models.RecurringExecuteMessage{Type: models.TypeRecurringExecute, RecurrenceID: "health",
+		IntervalSeconds: 1, Task: models.ExecuteMessage{ScriptContent: "check"}})
+	got := receiveUntil(t, frames, models.TypeTaskCompleted)
+	fired := indexOf(got, models.TypeRecurrenceFired, recurring.TaskIDBase)
+	started := indexOf(got, models.TypeTaskStarted, recurring.TaskIDBase)
+	if assert.NotEqual(t, -1, fired) && assert.NotEqual(t, -1, started) {
+		assert.Less(t, fired, started, "The instance is announced before it runs")
+	}
+	assert.Equal(t, recurring.TaskIDBase, got[len(got)-1].TaskID)
+
+	client.handleCancelRecurring(models.CancelRecurringMessage{Type: models.TypeCancelRecurring, RecurrenceID: "health"})
+	assert.Empty(t, s.List())
+}
@@ -0,0 +1,48 @@
+package websocket
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/executor"
+	"github.com/berno/aaw-runner/internal/models"
+)
+
+// handleReserveSlot holds slots for a task the backend is about to send and answers with RESERVE_SLOT_RESULT
+func (c *Client) handleReserveSlot(msg models.ReserveSlotMessage) {
+	var result models.ReserveSlotResultMessage
+	expiresAt, err := c.pool.Reserve(msg.ReservationID, msg.Weight, time.Duration(msg.TTLSeconds)*time.Second)
+	switch {
+	case err == nil:
+		result = models.NewReserveSlotResult(msg.ReservationID, expiresAt)
+		c.history.record("Reservation %s held until %s", msg.ReservationID, expiresAt.Format(time.TimeOnly))
+	case errors.Is(err, executor.ErrReserveDraining):
+		result = models.NewReserveSlotRefusal(msg.ReservationID, models.ReserveDraining, err.Error())
+	default:
+		result = models.NewReserveSlotRefusal(msg.ReservationID, models.ReserveInsufficient, err.Error())
+	}
+
+	log.Printf("[WS] Sending RESERVE_SLOT_RESULT: reservation=%s, success=%v, code=%s", msg.ReservationID, result.Success, result.Code)
+	if err := c.sendJSON(&result); err != nil {
+		log.Printf("Failed to send reserve slot result: %v", err)
+	}
+}
+
+// handleReleaseSlot gives back a reservation the backend will not use
+func (c *Client) handleReleaseSlot(msg models.ReleaseSlotMessage) {
+	if !c.pool.Release(msg.ReservationID) {
+		log.Printf("[WS] Ignoring release of unknown reservation %s", msg.ReservationID)
+	}
+}
+
+// sendReservationExpired tells the server a reservation was dropped before an EXECUTE used it
+func (c *Client) sendReservationExpired(reservationID, reason string) {
+	msg := models.NewReservationExpired(reservationID, reason)
+	c.history.record("Reservation %s dropped (%s)", reservationID, reason)
+
+	log.Printf("[WS] Sending RESERVATION_EXPIRED: reservation=%s, reason=%s", reservationID, reason)
+	if err := c.sendJSON(&msg); err != nil {
+		log.Printf("Failed to send reservation expired: %v", err)
+	}
+}
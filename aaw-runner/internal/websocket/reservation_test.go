@@ -0,0 +1,95 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// reservationFrame holds the fields of the frames the reservation tests look at
+type reservationFrame struct {
+	Type           string               `json:"type"`
+	ReservationID  string               `json:"reservationId"`
+	Success        bool                 `json:"success"`
+	Code           string               `json:"code"`
+	Reason         string               `json:"reason"`
+	AvailableSlots int                  `json:"availableSlots"`
+	Reserved       int                  `json:"reserved"`
+	Reservations   []models.Reservation `json:"reservations"`
+}
+
+// nextOfType skips frames up to the next one of type typ
+func nextOfType(t *testing.T, frames chan []byte, typ string) reservationFrame {
+	t.Helper()
+	for {
+		select {
+		case data := <-frames:
+			var f reservationFrame
+			assert.NoError(t, json.Unmarshal(data, &f))
+			if f.Type == typ {
+				return f
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("no %s received", typ)
+			return reservationFrame{}
+		}
+	}
+}
+
+// TestReserveSlot_SurvivesReconnect verifies a reservation is confirmed, counted in capacity and listed again on reconnect
+func TestReserveSlot_SurvivesReconnect(t *testing.T) {
+	cfg, frames := startBackend(t)
+	cfg.MaxParallel = 3
+	client := NewClient(cfg)
+	assert.NoError(t, client.Connect())
+	t.Cleanup(func() { client.Close() })
+	nextOfType(t, frames, models.TypeRunnerCapacity)
+
+	client.handleReserveSlot(models.ReserveSlotMessage{Type: models.TypeReserveSlot, ReservationID: "deploy", TTLSeconds: 60, Weight: 2})
+	capacity := nextOfType(t, frames, models.TypeRunnerCapacity)
+	assert.Equal(t, 1, capacity.AvailableSlots)
+	assert.Equal(t, 2, capacity.Reserved)
+	assert.Empty(t, capacity.Reservations, "Reservations are only listed on connect")
+	result := nextOfType(t, frames, models.TypeReserveSlotResult)
+	assert.True(t, result.Success)
+	assert.Equal(t, "deploy", result.ReservationID)
+
+	client.handleReserveSlot(models.ReserveSlotMessage{Type: models.TypeReserveSlot, ReservationID: "build", TTLSeconds: 60, Weight: 2})
+	result = nextOfType(t, frames, models.TypeReserveSlotResult)
+	assert.False(t, result.Success)
+	assert.Equal(t, models.ReserveInsufficient, result.Code)
+
+	// The backend drops the connection and the runner reconnects
+	client.conn.Close()
+	assert.NoError(t, client.Connect())
+	capacity = nextOfType(t, frames, models.TypeRunnerCapacity)
+	assert.Equal(t, 2, capacity.Reserved)
+	if assert.Len(t, capacity.Reservations, 1) {
+		assert.Equal(t, "deploy", capacity.Reservations[0].ReservationID)
+		assert.Equal(t, 2, capacity.Reservations[0].Weight)
+	}
+}
+
+// TestReserveSlot_Drain verifies draining drops reservations with RESERVATION_EXPIRED and refuses new ones
+func TestReserveSlot_Drain(t *testing.T) {
+	cfg, frames := startBackend(t)
+	client := NewClient(cfg)
+	assert.NoError(t, client.Connect())
+	t.Cleanup(func() { client.Close() })
+
+	client.handleReserveSlot(models.ReserveSlotMessage{Type: models.TypeReserveSlot, ReservationID: "deploy", TTLSeconds: 60})
+	assert.True(t, nextOfType(t, frames, models.TypeReserveSlotResult).Success)
+
+	client.Drain()
+	expired := nextOfType(t, frames, models.TypeReservationExpired)
+	assert.Equal(t, "deploy", expired.ReservationID)
+	assert.Equal(t, models.ExpiredDraining, expired.Reason)
+
+	client.handleReserveSlot(models.ReserveSlotMessage{Type: models.TypeReserveSlot, ReservationID: "deploy", TTLSeconds: 60})
+	result := nextOfType(t, frames, models.TypeReserveSlotResult)
+	assert.False(t, result.Success)
+	assert.Equal(t, models.ReserveDraining, result.Code)
+}
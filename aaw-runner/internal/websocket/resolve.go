@@ -0,0 +1,85 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+)
+
+// resolver looks up the addresses of the backend's host: net.DefaultResolver, or a fake in tests
+type resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// resolveError is a failure to look up the backend's host name, as opposed to one to reach it
+type resolveError struct {
+	host string
+	err  error
+}
+
+func (e *resolveError) Error() string { return fmt.Sprintf("could not resolve %s: %v", e.host, e.err) }
+func (e *resolveError) Unwrap() error { return e.err }
+
+// addressesError collects why each address of a host could not be dialled
+type addressesError struct {
+	host string
+	errs []error
+}
+
+func (e *addressesError) Error() string {
+	msgs := make([]string, len(e.errs))
+	for i, err := range e.errs {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("none of the %d addresses of %s answered: %s", len(e.errs), e.host, strings.Join(msgs, "; "))
+}
+
+func (e *addressesError) Unwrap() []error { return e.errs }
+
+// dialAddresses is the dialer's NetDialContext. It resolves the host again on every dial, so a backend
+// that moved to a new address is found on the next reconnect rather than after a restart, and tries
+// the addresses in the order the resolver returned them until one answers. Each address gets an equal
+// share of what is left of the connect timeout, so one that drops packets does not use it all up.
+func (c *Client) dialAddresses(ctx context.Context, network, addr string) (net.Conn, error) {
+	var d net.Dialer
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || net.ParseIP(host) != nil {
+		return d.DialContext(ctx, network, addr)
+	}
+
+	ips, err := c.resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, &resolveError{host: host, err: err}
+	}
+	if len(ips) == 0 {
+		return nil, &resolveError{host: host, err: errors.New("no addresses")}
+	}
+
+	var errs []error
+	for i, ip := range ips {
+		target := net.JoinHostPort(ip.String(), port)
+		attempt := ctx
+		if deadline, ok := ctx.Deadline(); ok {
+			var cancel context.CancelFunc
+			attempt, cancel = context.WithTimeout(ctx, time.Until(deadline)/time.Duration(len(ips)-i))
+			defer cancel()
+		}
+		conn, err := d.DialContext(attempt, network, target)
+		if err == nil {
+			log.Printf("[WS] Reached %s at %s", host, target)
+			return conn, nil
+		}
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if len(errs) == 1 {
+		return nil, errs[0]
+	}
+	return nil, &addressesError{host: host, errs: errs}
+}
@@ -0,0 +1,77 @@
+package websocket
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeResolver answers lookups from a list of results, one per lookup, repeating the last
+type fakeResolver struct {
+	mu      sync.Mutex
+	results [][]string // Addresses of each lookup in turn; nil fails it
+	lookups []string   // Hosts looked up
+}
+
+func (r *fakeResolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	result := r.results[min(len(r.lookups), len(r.results)-1)]
+	r.lookups = append(r.lookups, host)
+	if result == nil {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	addrs := make([]net.IPAddr, len(result))
+	for i, ip := range result {
+		addrs[i] = net.IPAddr{IP: net.ParseIP(ip)}
+	}
+	return addrs, nil
+}
+
+// TestConnect_ResolvesEveryDial verifies the backend's name is looked up again for each connect, every
+// address is tried in order, and a lookup failure is reported apart from a refused connection
+func TestConnect_ResolvesEveryDial(t *testing.T) {
+	cfg, _ := startBackend(t)
+	backend, err := url.Parse(cfg.BackendURL)
+	assert.NoError(t, err)
+	cfg.BackendURL = "ws://backend.test:" + backend.Port()
+	// The backend listens on 127.0.0.1 only, so other loopback addresses refuse
+	const moved, current = "127.0.0.2", "127.0.0.1"
+
+	for name, tc := range map[string]struct {
+		results [][]string
+		errs    []string // Of each connect, empty for success
+	}{
+		"moved":         {[][]string{{moved}, {current}}, []string{"connection refused", ""}},
+		"fallback":      {[][]string{{moved, current}}, []string{""}},
+		"all refused":   {[][]string{{moved, "127.0.0.3"}}, []string{"none of the 2 addresses of backend.test answered"}},
+		"lookup failed": {[][]string{nil, {current}}, []string{"backend host name did not resolve", ""}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			client := NewClient(cfg)
+			t.Cleanup(func() { client.Close() })
+			resolver := &fakeResolver{results: tc.results}
+			client.resolver = resolver
+
+			for i, want := range tc.errs {
+				err := client.Connect()
+				if want == "" {
+					assert.NoError(t, err, "connect %d", i+1)
+					continue
+				}
+				assert.ErrorContains(t, err, want, "connect %d", i+1)
+				if strings.Contains(want, "resolve") {
+					assert.NotContains(t, err.Error(), "refused")
+				}
+			}
+			assert.Len(t, resolver.lookups, len(tc.errs), "One lookup per connect")
+			assert.Equal(t, tc.errs[len(tc.errs)-1] == "", client.Connected())
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package websocket
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/models"
+)
+
+// replayInterval spaces replayed lines (at most 200 a second per task) so live output keeps flowing
+var replayInterval = 5 * time.Millisecond
+
+// handleResumeLogs answers a RESUME_LOGS request and replays the missing lines from the task's local log
+// Replayed lines are sent one at a time through the normal send path, so live output interleaves with them.
+// A new request for the same task replaces a replay still in progress.
+func (c *Client) handleResumeLogs(msg models.ResumeLogsMessage) {
+	from := msg.LastLineIndex + 1
+
+	c.linesMu.Lock()
+	next := c.nextLine[msg.TaskID]
+	c.linesMu.Unlock()
+
+	var result models.ResumeLogsResultMessage
+	switch {
+	case !c.pool.IsTaskRunning(msg.TaskID):
+		result = models.NewResumeLogsRefusal(msg.TaskID, models.ResumeNotRunning, fmt.Sprintf("task %d is not running", msg.TaskID), 0, 0)
+	case !c.taskLogs.Keeps(msg.TaskID):
+		// Only lines still to come can be sent
+		result = models.NewResumeLogsRefusal(msg.TaskID, models.ResumeNotPersisted, "task output is not kept on the runner", next, next)
+	case from > next:
+		result = models.NewResumeLogsRefusal(msg.TaskID, models.ResumeOutOfRange,
+			fmt.Sprintf("lastLineIndex %d is past the last line (%d)", msg.LastLineIndex, next-1), 0, next)
+	default:
+		result = models.NewResumeLogsResult(msg.TaskID, from, next)
+	}
+
+	log.Printf("[WS] Sending RESUME_LOGS_RESULT: task=%d, success=%v, from=%d, next=%d, code=%s",
+		msg.TaskID, result.Success, from, next, result.Code)
+	if err := c.sendJSON(&result); err != nil {
+		log.Printf("Failed to send resume logs result: %v", err)
+		return
+	}
+	if result.Success && from < next {
+		c.replay(msg.TaskID, from, next)
+	}
+}
+
+// replay resends lines from to end-1 of a task's local log, marked replayed
+func (c *Client) replay(taskID, from, end int64) {
+	stop := make(chan struct{})
+	c.linesMu.Lock()
+	if previous, ok := c.replays[taskID]; ok {
+		close(previous)
+	}
+	c.replays[taskID] = stop
+	c.linesMu.Unlock()
+	defer func() {
+		c.linesMu.Lock()
+		if c.replays[taskID] == stop {
+			delete(c.replays, taskID)
+		}
+		c.linesMu.Unlock()
+	}()
+
+	metadata := c.pool.TaskMetadata(taskID)
+	ticker := time.NewTicker(replayInterval)
+	defer ticker.Stop()
+	sent := int64(0)
+	err := c.taskLogs.Lines(taskID, from, end, func(index int64, line string) bool {
+		select {
+		case <-stop:
+			return false
+		case <-ticker.C:
+		}
+		msg := models.NewLogMessage(taskID, line, false)
+		msg.LineIndex, msg.Replayed, msg.Metadata = index, true, metadata
+		msg.Stream = "" // Not kept in the task log
+		if err := c.sendJSON(&msg); err != nil {
+			log.Printf("Failed to send replayed log line: %v", err)
+			return false
+		}
+		sent++
+		return true
+	})
+	if err != nil {
+		log.Printf("[WS] Failed to replay log of task %d: %v", taskID, err)
+	}
+	log.Printf("[WS] Replayed %d of %d lines of task %d", sent, end-from, taskID)
+}
+
+// forgetLines drops the line count of a finished task
+func (c *Client) forgetLines(taskID int64) {
+	c.linesMu.Lock()
+	defer c.linesMu.Unlock()
+	delete(c.nextLine, taskID)
+}
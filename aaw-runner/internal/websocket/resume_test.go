@@ -0,0 +1,171 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/tasklog"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// resumeFrame holds the fields of LOG and RESUME_LOGS_RESULT frames
+type resumeFrame struct {
+	Type                string `json:"type"`
+	TaskID              int64  `json:"taskId"`
+	Line                string `json:"line"`
+	LineIndex           int64  `json:"lineIndex"`
+	Replayed            bool   `json:"replayed"`
+	Success             bool   `json:"success"`
+	Code                string `json:"code"`
+	FromIndex           int64  `json:"fromIndex"`
+	NextIndex           int64  `json:"nextIndex"`
+	FirstAvailableIndex int64  `json:"firstAvailableIndex"`
+}
+
+// collect receives frames until done returns true for one of them
+func collect(t *testing.T, frames chan []byte, done func(f resumeFrame) bool) []resumeFrame {
+	t.Helper()
+	var got []resumeFrame
+	for {
+		select {
+		case data := <-frames:
+			var f resumeFrame
+			assert.NoError(t, json.Unmarshal(data, &f))
+			got = append(got, f)
+			if done(f) {
+				return got
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("timed out after %d frames", len(got))
+			return got
+		}
+	}
+}
+
+// isLine reports whether f is the live LOG line with the given index
+func isLine(f resumeFrame, index int64) bool {
+	return f.Type == models.TypeLog && !f.Replayed && f.LineIndex == index
+}
+
+// startLoggingTask connects a client keeping task logs (unless spool is false) and runs claude printing lines
+// The executor's own "Starting dynamic execution" line comes first, so "line N" has index N+1.
+func startLoggingTask(t *testing.T, claude string, spool bool) (*Client, chan []byte) {
+	testutil.FakeClaude(t, claude)
+	cfg, frames := startBackend(t)
+	client := NewClient(cfg)
+	if spool {
+		s, err := tasklog.NewSpool(filepath.Join(t.TempDir(), tasklog.DirName))
+		assert.NoError(t, err)
+		client.SetTaskLogs(s, nil)
+	}
+	assert.NoError(t, client.Connect())
+	t.Cleanup(func() {
+		client.pool.KillAll()
+		client.Close()
+	})
+	client.handleExecute(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 81, ScriptContent: "hello"})
+	return client, frames
+}
+
+// TestResumeLogs_FillsGap verifies the lines after lastLineIndex are resent, marked replayed, with their original indexes
+func TestResumeLogs_FillsGap(t *testing.T) {
+	client, frames := startLoggingTask(t, "for i in $(seq 0 19); do echo line $i; done; sleep 30", true)
+	live := collect(t, frames, func(f resumeFrame) bool { return isLine(f, 20) })
+	for _, f := range live {
+		if f.Type == models.TypeLog && f.LineIndex > 0 {
+			assert.Equal(t, fmt.Sprintf("line %d", f.LineIndex-1), f.Line, "Live lines are numbered in order")
+			assert.False(t, f.Replayed)
+		}
+	}
+
+	// The backend lost lines 11-20 in an outage
+	go client.handleResumeLogs(models.ResumeLogsMessage{Type: models.TypeResumeLogs, TaskID: 81, LastLineIndex: 10})
+	got := collect(t, frames, func(f resumeFrame) bool { return f.Replayed && f.LineIndex == 20 })
+	result := got[0]
+	assert.Equal(t, models.TypeResumeLogsResult, result.Type)
+	assert.True(t, result.Success)
+	assert.Equal(t, int64(11), result.FromIndex)
+	assert.Equal(t, int64(21), result.NextIndex)
+
+	var replayed []int64
+	for _, f := range got[1:] {
+		if f.Type == models.TypeLog {
+			assert.True(t, f.Replayed)
+			assert.Equal(t, fmt.Sprintf("line %d", f.LineIndex-1), f.Line)
+			replayed = append(replayed, f.LineIndex)
+		}
+	}
+	assert.Equal(t, []int64{11, 12, 13, 14, 15, 16, 17, 18, 19, 20}, replayed)
+}
+
+// TestResumeLogs_Refusals verifies out-of-range indexes, unknown tasks and unkept output get a structured refusal
+func TestResumeLogs_Refusals(t *testing.T) {
+	client, frames := startLoggingTask(t, "for i in $(seq 0 4); do echo line $i; done; sleep 30", true)
+	collect(t, frames, func(f resumeFrame) bool { return isLine(f, 5) })
+
+	client.handleResumeLogs(models.ResumeLogsMessage{Type: models.TypeResumeLogs, TaskID: 81, LastLineIndex: 50})
+	result := collect(t, frames, func(f resumeFrame) bool { return f.Type == models.TypeResumeLogsResult })
+	refusal := result[len(result)-1]
+	assert.False(t, refusal.Success)
+	assert.Equal(t, models.ResumeOutOfRange, refusal.Code)
+	assert.Equal(t, int64(0), refusal.FirstAvailableIndex)
+	assert.Equal(t, int64(6), refusal.NextIndex)
+
+	client.handleResumeLogs(models.ResumeLogsMessage{Type: models.TypeResumeLogs, TaskID: 999, LastLineIndex: -1})
+	result = collect(t, frames, func(f resumeFrame) bool { return f.Type == models.TypeResumeLogsResult })
+	assert.Equal(t, models.ResumeNotRunning, result[len(result)-1].Code)
+
+	// Nothing after the last line is a successful resume with nothing to replay
+	client.handleResumeLogs(models.ResumeLogsMessage{Type: models.TypeResumeLogs, TaskID: 81, LastLineIndex: 5})
+	result = collect(t, frames, func(f resumeFrame) bool { return f.Type == models.TypeResumeLogsResult })
+	assert.True(t, result[len(result)-1].Success)
+	assert.Equal(t, int64(6), result[len(result)-1].FromIndex)
+}
+
+// TestResumeLogs_NotPersisted verifies a runner without task logs reports the first line it can still deliver
+func TestResumeLogs_NotPersisted(t *testing.T) {
+	client, frames := startLoggingTask(t, "for i in $(seq 0 2); do echo line $i; done; sleep 30", false)
+	collect(t, frames, func(f resumeFrame) bool { return isLine(f, 3) })
+
+	client.handleResumeLogs(models.ResumeLogsMessage{Type: models.TypeResumeLogs, TaskID: 81, LastLineIndex: 0})
+	result := collect(t, frames, func(f resumeFrame) bool { return f.Type == models.TypeResumeLogsResult })
+	refusal := result[len(result)-1]
+	assert.False(t, refusal.Success)
+	assert.Equal(t, models.ResumeNotPersisted, refusal.Code)
+	assert.Equal(t, int64(4), refusal.FirstAvailableIndex)
+}
+
+// TestResumeLogs_InterleavesWithLiveOutput verifies live lines keep flowing while a replay is in progress
+func TestResumeLogs_InterleavesWithLiveOutput(t *testing.T) {
+	old := replayInterval
+	replayInterval = 10 * time.Millisecond
+	defer func() { replayInterval = old }()
+
+	client, frames := startLoggingTask(t, "i=0; while [ $i -lt 1000 ]; do echo line $i; i=$((i+1)); sleep 0.005; done", true)
+	collect(t, frames, func(f resumeFrame) bool { return isLine(f, 39) })
+
+	go client.handleResumeLogs(models.ResumeLogsMessage{Type: models.TypeResumeLogs, TaskID: 81, LastLineIndex: -1})
+	got := collect(t, frames, func(f resumeFrame) bool { return f.Replayed && f.LineIndex == 39 })
+
+	first, last := -1, -1
+	for i, f := range got {
+		if f.Replayed {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	liveDuring := 0
+	for _, f := range got[first:last] {
+		if f.Type == models.TypeLog && !f.Replayed {
+			liveDuring++
+		}
+	}
+	assert.Greater(t, liveDuring, 0, "Live output is not held back behind the replay")
+}
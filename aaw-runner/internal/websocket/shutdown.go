@@ -0,0 +1,150 @@
+package websocket
+
+import (
+	"log"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/models"
+)
+
+// shutdownCancelTimeout bounds how long Shutdown waits for cancelled tasks to report completion
+var shutdownCancelTimeout = 15 * time.Second
+
+// Forced shutdown bounds: killed tasks get this long to report, and each message this long to be written
+var (
+	forcedCompleteTimeout = 2 * time.Second
+	forcedWriteTimeout    = 500 * time.Millisecond
+)
+
+// Shutdown drains the runner for a graceful exit and reports whether every task finished within grace
+// The sequence is: stop accepting tasks, announce RUNNER_DRAINING, let running tasks finish (their
+// completions stream as usual), cancel whatever is left once grace expires, then send BYE after every
+// task has been reported. The connection stays open; Close it afterwards.
+func (c *Client) Shutdown(grace time.Duration) bool {
+	c.shuttingDown.Store(true)
+	c.pool.Drain()
+	c.sendRunnerDraining(c.RunningTasks(), grace)
+	log.Printf("[SHUTDOWN] Draining, waiting up to %s for running tasks", grace)
+	c.history.record("Shutting down, waiting up to %s for running tasks", grace)
+	c.statusFile.Notify()
+
+	drained := c.pool.WaitIdle(grace)
+	if c.forced.Load() {
+		// ForceShutdown took over while we waited and reports the outcome itself
+		return false
+	}
+	cancelled := 0
+	if !drained {
+		cancelled = c.pool.CancelAll()
+		log.Printf("[SHUTDOWN] Grace period of %s expired, cancelled %d task(s)", grace, cancelled)
+		if !c.pool.WaitIdle(shutdownCancelTimeout) {
+			log.Printf("[SHUTDOWN] Cancelled tasks did not report completion within %s", shutdownCancelTimeout)
+		}
+	}
+
+	// The outbox keeps its order, so BYE follows every completion; return once it has been written
+	c.sendBye(drained, cancelled)
+	c.flush()
+	return drained
+}
+
+// ForceShutdown kills every task without waiting and sends the terminal messages best-effort
+// It is the operator's escalation of a Shutdown in progress: task process groups are SIGKILLed, and
+// their completions and BYE are written with short deadlines so a wedged connection cannot hold up
+// the exit. Returns how many tasks were still pending.
+func (c *Client) ForceShutdown() int {
+	c.shuttingDown.Store(true)
+	c.forced.Store(true)
+	c.pool.Drain()
+
+	killed := c.pool.KillAll()
+	log.Printf("[SHUTDOWN] Forced: killed %d task(s)", killed)
+	c.history.record("Shutdown forced, killed %d task(s)", killed)
+	if !c.pool.WaitIdle(forcedCompleteTimeout) {
+		log.Printf("[SHUTDOWN] Killed tasks did not report completion within %s", forcedCompleteTimeout)
+	}
+
+	// A write already stuck on the connection holds up the outbox, so give up on BYE rather than wait for it
+	sent := make(chan struct{})
+	go func() {
+		c.sendBye(false, killed)
+		c.flush()
+		close(sent)
+	}()
+	select {
+	case <-sent:
+	case <-time.After(forcedCompleteTimeout):
+		log.Printf("[SHUTDOWN] Could not send BYE within %s", forcedCompleteTimeout)
+	}
+	return killed
+}
+
+// Done is closed once the backend has asked the runner to exit with SHUTDOWN and the drain it asked
+// for is over (BYE sent). The caller should then Close the client, which sends GOODBYE, and exit
+// cleanly; DoneReason says why.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// DoneReason is the reason the backend gave in its SHUTDOWN, once Done is closed
+func (c *Client) DoneReason() string {
+	select {
+	case <-c.done:
+		return c.doneWhy
+	default:
+		return ""
+	}
+}
+
+// handleShutdown carries out a SHUTDOWN: a Shutdown with the backend's grace period, then Done is closed
+// A SHUTDOWN that arrives while the runner is already shutting down changes nothing.
+func (c *Client) handleShutdown(msg models.ShutdownMessage) {
+	if c.shuttingDown.Load() {
+		log.Printf("[SHUTDOWN] Backend asked for a shutdown, already in progress")
+		return
+	}
+	reason := msg.Reason
+	if reason == "" {
+		reason = "requested by the backend"
+	}
+	log.Printf("[SHUTDOWN] Backend asked the runner to exit (%s)", reason)
+	c.history.record("Backend requested shutdown: %s", reason)
+
+	c.Shutdown(time.Duration(msg.GraceSeconds) * time.Second)
+	c.doneOnce.Do(func() {
+		c.doneWhy = reason
+		close(c.done)
+	})
+}
+
+// writeTimeout is the deadline for writing one message: short once a shutdown has been forced
+func (c *Client) writeTimeout() time.Duration {
+	if c.forced.Load() {
+		return forcedWriteTimeout
+	}
+	return 10 * time.Second
+}
+
+// sendRunnerDraining tells the server the runner is shutting down
+func (c *Client) sendRunnerDraining(running int, grace time.Duration) {
+	msg := models.NewRunnerDraining(running, grace)
+
+	log.Printf("[WS] Sending RUNNER_DRAINING: running=%d, grace=%ds", msg.RunningTasks, msg.GraceSeconds)
+	if err := c.sendJSON(&msg); err != nil {
+		log.Printf("Failed to send runner draining: %v", err)
+	}
+}
+
+// sendBye sends the last message of a shutdown; only the first call sends
+// (a forced shutdown can overtake the graceful one it escalates)
+func (c *Client) sendBye(drained bool, cancelled int) {
+	if c.byeSent.Swap(true) {
+		return
+	}
+	msg := models.NewBye(drained, cancelled)
+
+	log.Printf("[WS] Sending BYE: drained=%v, cancelled=%d", drained, cancelled)
+	if err := c.sendJSON(&msg); err != nil {
+		log.Printf("Failed to send bye: %v", err)
+	}
+}
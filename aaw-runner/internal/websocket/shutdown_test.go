@@ -0,0 +1,186 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// frame holds the fields of a received message the shutdown tests look at
+type frame struct {
+	Type           string `json:"type"`
+	TaskID         int64  `json:"taskId"`
+	ErrorCode      string `json:"errorCode"`
+	ExitCode       int    `json:"exitCode"`
+	AvailableSlots int    `json:"availableSlots"`
+	RunningTasks   int    `json:"runningTasks"`
+	Drained        bool   `json:"drained"`
+	CancelledTasks int    `json:"cancelledTasks"`
+	RequestedBy    string `json:"requestedBy"`
+	Reason         string `json:"reason"`
+	Code           string `json:"code"`
+	MessageID      string `json:"messageId"`
+	Line           string `json:"line"`
+	LineIndex      int64  `json:"lineIndex"`
+	Skipped        int64  `json:"skipped"`
+	System         bool   `json:"system"`
+	Degraded       bool   `json:"degraded"`
+	Success        bool   `json:"success"`
+	Bytes          int    `json:"bytes"`
+	Error          string `json:"error"`
+
+	Tasks []models.ResyncTask `json:"tasks"`
+}
+
+// receiveUntil collects frames up to and including the first one of type last
+func receiveUntil(t *testing.T, frames chan []byte, last string) []frame {
+	t.Helper()
+	var got []frame
+	for {
+		select {
+		case data := <-frames:
+			var f frame
+			assert.NoError(t, json.Unmarshal(data, &f))
+			got = append(got, f)
+			if f.Type == last {
+				return got
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("no %s received", last)
+			return got
+		}
+	}
+}
+
+// indexOf returns the position of the first frame of the given type (and task, when non-zero)
+func indexOf(frames []frame, typ string, taskID int64) int {
+	for i, f := range frames {
+		if f.Type == typ && (taskID == 0 || f.TaskID == taskID) {
+			return i
+		}
+	}
+	return -1
+}
+
+// startTaskClient connects a client and submits one task running the fake claude
+func startTaskClient(t *testing.T, claude string) (*Client, chan []byte) {
+	testutil.FakeClaude(t, claude)
+	cfg, frames := startBackend(t)
+	client := NewClient(cfg)
+	assert.NoError(t, client.Connect())
+	t.Cleanup(func() { client.Close() })
+
+	client.handleExecute(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 31, ScriptContent: "hello"})
+	receiveUntil(t, frames, models.TypeTaskStarted)
+	waitForProcess(t, client, 31)
+	return client, frames
+}
+
+// waitForProcess waits until a started task's process can be signalled (or the task has already finished)
+// TASK_STARTED goes out when a worker picks the task up, slightly before its process exists.
+func waitForProcess(t *testing.T, client *Client, taskID int64) {
+	t.Helper()
+	assert.Eventually(t, func() bool {
+		return client.executor.IsTaskRunning(taskID) || !client.pool.IsTaskRunning(taskID)
+	}, 5*time.Second, 5*time.Millisecond)
+}
+
+// TestShutdown_WaitsForRunningTasks verifies a task finishing within the grace period completes before BYE
+func TestShutdown_WaitsForRunningTasks(t *testing.T) {
+	client, frames := startTaskClient(t, "sleep 0.3; echo done")
+
+	assert.True(t, client.Shutdown(5*time.Second))
+
+	got := receiveUntil(t, frames, models.TypeBye)
+	draining := indexOf(got, models.TypeRunnerDraining, 0)
+	completed := indexOf(got, models.TypeTaskCompleted, 31)
+	assert.GreaterOrEqual(t, draining, 0)
+	assert.Greater(t, completed, draining, "Completion streams after the draining announcement")
+	assert.Empty(t, got[completed].ErrorCode)
+	assert.True(t, got[len(got)-1].Drained)
+	assert.Zero(t, got[len(got)-1].CancelledTasks)
+}
+
+// TestShutdown_CancelsAfterGrace verifies tasks still running when the grace period expires are cancelled
+func TestShutdown_CancelsAfterGrace(t *testing.T) {
+	client, frames := startTaskClient(t, "sleep 30")
+
+	assert.False(t, client.Shutdown(200*time.Millisecond))
+
+	got := receiveUntil(t, frames, models.TypeBye)
+	completed := indexOf(got, models.TypeTaskCompleted, 31)
+	assert.GreaterOrEqual(t, completed, 0, "Cancelled task must be reported before BYE")
+	assert.Equal(t, models.ErrorCodeCancelled, got[completed].ErrorCode)
+	assert.False(t, got[len(got)-1].Drained)
+	assert.Equal(t, 1, got[len(got)-1].CancelledTasks)
+}
+
+// TestShutdown_RejectsNewTasks verifies EXECUTE is refused once draining has begun
+func TestShutdown_RejectsNewTasks(t *testing.T) {
+	client, frames := startTaskClient(t, "sleep 0.3")
+	client.pool.Drain()
+
+	client.handleExecute(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 32, ScriptContent: "hello"})
+
+	got := receiveUntil(t, frames, models.TypeTaskCompleted)
+	rejected := got[len(got)-1]
+	assert.Equal(t, int64(32), rejected.TaskID)
+	assert.Equal(t, models.ErrorCodeRunnerShutdown, rejected.ErrorCode)
+	_, _, available := client.pool.GetCapacity()
+	assert.Zero(t, available, "No slots are advertised while draining")
+}
+
+// TestForceShutdown_KillsDuringGrace verifies forcing a graceful shutdown kills the task and sends a single BYE
+func TestForceShutdown_KillsDuringGrace(t *testing.T) {
+	client, frames := startTaskClient(t, "sleep 30")
+	graceful := make(chan bool, 1)
+	go func() { graceful <- client.Shutdown(time.Minute) }()
+	receiveUntil(t, frames, models.TypeRunnerDraining)
+
+	start := time.Now()
+	assert.Equal(t, 1, client.ForceShutdown())
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	got := receiveUntil(t, frames, models.TypeBye)
+	completed := indexOf(got, models.TypeTaskCompleted, 31)
+	assert.GreaterOrEqual(t, completed, 0, "Killed task must be reported before BYE")
+	assert.Equal(t, models.ErrorCodeCancelled, got[completed].ErrorCode)
+	assert.False(t, got[len(got)-1].Drained)
+	assert.Equal(t, 1, got[len(got)-1].CancelledTasks)
+
+	assert.False(t, <-graceful, "The overtaken graceful shutdown reports failure")
+	select {
+	case data := <-frames:
+		t.Fatalf("unexpected frame after BYE: %s", data)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// TestShutdownMessage_DrainsAndSignalsDone verifies a SHUTDOWN from the backend drains the runner with the
+// grace period it names, refuses new tasks, and closes Done once BYE is out
+func TestShutdownMessage_DrainsAndSignalsDone(t *testing.T) {
+	client, frames := startTaskClient(t, "sleep 30")
+
+	client.handleMessage([]byte(`{"type":"SHUTDOWN","graceSeconds":0,"reason":"maintenance"}`))
+
+	got := receiveUntil(t, frames, models.TypeBye)
+	assert.GreaterOrEqual(t, indexOf(got, models.TypeRunnerDraining, 0), 0)
+	completed := indexOf(got, models.TypeTaskCompleted, 31)
+	assert.GreaterOrEqual(t, completed, 0, "Cancelled task must be reported before BYE")
+	assert.Equal(t, models.ErrorCodeCancelled, got[completed].ErrorCode)
+	assert.Equal(t, 1, got[len(got)-1].CancelledTasks)
+	select {
+	case <-client.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Done was not closed")
+	}
+	assert.Equal(t, "maintenance", client.DoneReason())
+
+	client.handleExecute(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 32, ScriptContent: "hello"})
+	got = receiveUntil(t, frames, models.TypeTaskCompleted)
+	assert.Equal(t, models.ErrorCodeRunnerShutdown, got[len(got)-1].ErrorCode)
+}
@@ -0,0 +1,147 @@
+package websocket
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/models"
+)
+
+// writeLatencyWindow is how many of the most recent writes the p95 write latency is taken over
+// A variable so tests can shrink it.
+var writeLatencyWindow = 100
+
+// minLatencySamples is how many writes must have been timed before the backend is judged slow
+const minLatencySamples = 20
+
+// writeLatency keeps the latencies of the most recent writes, and whether they make the backend slow
+// The backend turns slow once the p95 reaches the threshold and recovers once it falls below half of
+// it, so a p95 hovering around the threshold does not flip the state on every write.
+type writeLatency struct {
+	mu       sync.Mutex
+	samples  []time.Duration // The last writeLatencyWindow latencies, a ring once full
+	next     int             // Where the next sample goes once samples is full
+	p95      time.Duration
+	degraded bool
+}
+
+// observe records the latency of one write and reports the p95, and whether the backend is now slow
+// and that changed with this write
+func (w *writeLatency) observe(d, threshold time.Duration) (p95 time.Duration, degraded, changed bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.samples) < writeLatencyWindow {
+		w.samples = append(w.samples, d)
+	} else {
+		w.samples[w.next] = d
+		w.next = (w.next + 1) % len(w.samples)
+	}
+	sorted := slices.Clone(w.samples)
+	slices.Sort(sorted)
+	w.p95 = sorted[(len(sorted)*95+99)/100-1]
+
+	if len(w.samples) >= minLatencySamples {
+		switch {
+		case !w.degraded && w.p95 >= threshold:
+			w.degraded, changed = true, true
+		case w.degraded && w.p95 < threshold/2:
+			w.degraded, changed = false, true
+		}
+	}
+	return w.p95, w.degraded, changed
+}
+
+// state returns the current p95 write latency and whether the backend is slow
+func (w *writeLatency) state() (time.Duration, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.p95, w.degraded
+}
+
+// Degraded reports whether writes to the backend are slow enough that task output is being sampled
+func (c *Client) Degraded() bool {
+	_, degraded := c.latency.state()
+	return degraded
+}
+
+// noteWriteLatency records how long a successful write took, switching task output to sampling when
+// the backend turns slow and back when it recovers
+func (c *Client) noteWriteLatency(d time.Duration) {
+	p95, degraded, changed := c.latency.observe(d, c.cfg.SlowWriteThreshold)
+	if !changed {
+		return
+	}
+	if degraded {
+		log.Printf("[WS] Warning: backend is slow, p95 write latency %s is over %s; sending 1 task output line in %d until it recovers",
+			p95.Round(time.Millisecond), c.cfg.SlowWriteThreshold, c.cfg.SlowLogSampleEvery)
+		c.history.record("Backend slow (p95 write latency %s), sampling task output", p95.Round(time.Millisecond))
+	} else {
+		log.Printf("[WS] Backend recovered, p95 write latency %s; sending all task output again", p95.Round(time.Millisecond))
+		c.history.record("Backend recovered (p95 write latency %s)", p95.Round(time.Millisecond))
+	}
+}
+
+// sampledLines counts the output lines of a task not sent while the backend was slow
+type sampledLines struct {
+	seen        int   // Lines of the task since the backend turned slow
+	skipped     int64 // Lines not sent since the last summary
+	first, last int64 // Indexes of the first and last of them
+}
+
+// sampleLine decides whether a numbered LOG line is sent: all of them are while the backend keeps up,
+// one in SlowLogSampleEvery of each task while it is slow. The lines left out are counted, and the
+// summary of those not yet reported is returned ahead of the next line sent. Callers hold linesMu.
+func (c *Client) sampleLine(msg models.LogMessage) (send bool, summary *models.LogMessage) {
+	s := c.sampled[msg.TaskID]
+	if !c.Degraded() || msg.Skipped > 0 { // A summary of dropped lines is worth more than any one line
+		delete(c.sampled, msg.TaskID)
+		return true, s.summary(msg)
+	}
+	if s == nil {
+		s = &sampledLines{}
+		c.sampled[msg.TaskID] = s
+	}
+	s.seen++
+	if (s.seen-1)%c.cfg.SlowLogSampleEvery == 0 {
+		summary := s.summary(msg)
+		s.skipped = 0
+		return true, summary
+	}
+	if s.skipped == 0 {
+		s.first = msg.LineIndex
+	}
+	s.skipped++
+	s.last = msg.LineIndex
+	return false, nil
+}
+
+// summary is the LOG line standing in for the lines s left out, nil when there are none
+func (s *sampledLines) summary(next models.LogMessage) *models.LogMessage {
+	if s == nil || s.skipped == 0 {
+		return nil
+	}
+	msg := models.NewLogMessage(next.TaskID, "", true)
+	msg.LineIndex, msg.Metadata = s.first, next.Metadata
+	msg.Skipped = s.skipped
+	msg.Line = fmt.Sprintf("[runner] %d output lines (%d-%d) not sent while the backend was slow; RESUME_LOGS can resend them",
+		s.skipped, s.first, s.last)
+	return &msg
+}
+
+// reportSampled sends the summary of a finished task's lines not yet reported, ahead of its completion
+func (c *Client) reportSampled(taskID int64, metadata map[string]string) {
+	c.linesMu.Lock()
+	summary := c.sampled[taskID].summary(models.LogMessage{TaskID: taskID, Metadata: metadata})
+	delete(c.sampled, taskID)
+	c.linesMu.Unlock()
+	if summary == nil {
+		return
+	}
+	if err := c.sendJSON(summary); err != nil && !errors.Is(err, ErrClientClosed) {
+		log.Printf("Failed to send log message: %v", err)
+	}
+}
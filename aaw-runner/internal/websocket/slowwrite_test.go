@@ -0,0 +1,90 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWriteLatency_DegradesAndRecovers verifies the backend turns slow once the p95 write latency reaches
+// the threshold, and recovers only once it falls below half of it
+func TestWriteLatency_DegradesAndRecovers(t *testing.T) {
+	var w writeLatency
+	for i := 0; i < minLatencySamples; i++ {
+		_, degraded, _ := w.observe(10*time.Millisecond, time.Second)
+		assert.False(t, degraded)
+	}
+
+	changes := 0
+	for i := 0; i < 10; i++ {
+		_, _, changed := w.observe(2*time.Second, time.Second)
+		if changed {
+			changes++
+		}
+	}
+	p95, degraded := w.state()
+	assert.True(t, degraded)
+	assert.Equal(t, 2*time.Second, p95)
+	assert.Equal(t, 1, changes)
+
+	// 700ms is under the threshold but not under half of it
+	for i := 0; i < writeLatencyWindow; i++ {
+		w.observe(700*time.Millisecond, time.Second)
+	}
+	_, degraded = w.state()
+	assert.True(t, degraded, "Still slow above half the threshold")
+	for i := 0; i < writeLatencyWindow; i++ {
+		w.observe(10*time.Millisecond, time.Second)
+	}
+	p95, degraded = w.state()
+	assert.False(t, degraded)
+	assert.Equal(t, 10*time.Millisecond, p95)
+}
+
+// TestSendLogMessage_SampledWhileSlow verifies only one line in SlowLogSampleEvery is sent while the backend
+// is slow, the others being summarized ahead of the next line sent and when the task ends, and that the
+// heartbeat says so
+func TestSendLogMessage_SampledWhileSlow(t *testing.T) {
+	cfg, frames := startBackend(t)
+	cfg.SlowLogSampleEvery = 3
+	client := NewClient(cfg)
+	t.Cleanup(func() { client.Close() })
+	assert.NoError(t, client.Connect())
+	receiveUntil(t, frames, models.TypeRunnerCapacity)
+
+	client.latency.mu.Lock()
+	client.latency.degraded = true
+	client.latency.mu.Unlock()
+	for i := 0; i < 8; i++ {
+		client.sendLogMessage(models.NewLogMessage(5, "line", false))
+	}
+	client.reportSampled(5, nil)
+	client.sendHeartbeat()
+
+	got := receiveUntil(t, frames, models.TypeRunnerHeartbeat)
+	var sent []int64
+	var summaries [][2]int64
+	for _, f := range got {
+		switch {
+		case f.Type == models.TypeLog && f.Skipped > 0:
+			summaries = append(summaries, [2]int64{f.LineIndex, f.Skipped})
+		case f.Type == models.TypeLog:
+			sent = append(sent, f.LineIndex)
+		}
+	}
+	assert.Equal(t, []int64{0, 3, 6}, sent)
+	assert.Equal(t, [][2]int64{{1, 2}, {4, 2}, {7, 1}}, summaries)
+	assert.True(t, got[len(got)-1].Degraded)
+
+	client.latency.mu.Lock()
+	client.latency.degraded = false
+	client.latency.mu.Unlock()
+	client.sendLogMessage(models.NewLogMessage(5, "line", false))
+	client.sendHeartbeat()
+	got = receiveUntil(t, frames, models.TypeRunnerHeartbeat)
+	assert.Len(t, got, 2, "Every line is sent again, with no summary")
+	assert.Equal(t, int64(8), got[0].LineIndex)
+	assert.False(t, got[1].Degraded)
+}
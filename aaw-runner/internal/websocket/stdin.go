@@ -0,0 +1,67 @@
+package websocket
+
+import (
+	"log"
+
+	"github.com/berno/aaw-runner/internal/models"
+)
+
+// stdinQueue holds the STDIN_INPUT messages of one task waiting to be written
+type stdinQueue struct {
+	msgs    []models.StdinInputMessage
+	running bool // A goroutine is draining msgs (see drainStdinInput)
+}
+
+// queueStdinInput queues msg behind the task's earlier STDIN_INPUT messages
+// A write can block on a task that does not read its stdin, so writes happen off the read loop,
+// one goroutine per task at a time, which keeps each task's input in the order it arrived.
+func (c *Client) queueStdinInput(msg models.StdinInputMessage) {
+	c.stdinMu.Lock()
+	defer c.stdinMu.Unlock()
+	if c.stdinQueues == nil {
+		c.stdinQueues = make(map[int64]*stdinQueue)
+	}
+	queue, ok := c.stdinQueues[msg.TaskID]
+	if !ok {
+		queue = &stdinQueue{}
+		c.stdinQueues[msg.TaskID] = queue
+	}
+	queue.msgs = append(queue.msgs, msg)
+	if !queue.running {
+		queue.running = true
+		go c.drainStdinInput(msg.TaskID, queue)
+	}
+}
+
+// drainStdinInput handles a task's queued STDIN_INPUT messages until none are left
+func (c *Client) drainStdinInput(taskID int64, queue *stdinQueue) {
+	for {
+		c.stdinMu.Lock()
+		if len(queue.msgs) == 0 {
+			queue.running = false
+			delete(c.stdinQueues, taskID)
+			c.stdinMu.Unlock()
+			return
+		}
+		msg := queue.msgs[0]
+		queue.msgs = queue.msgs[1:]
+		c.stdinMu.Unlock()
+
+		c.handleStdinInput(msg)
+	}
+}
+
+// handleStdinInput writes to the stdin of a running interactive task and answers with STDIN_INPUT_RESULT
+// The data itself is never logged: it may well answer a prompt for a secret.
+func (c *Client) handleStdinInput(msg models.StdinInputMessage) {
+	written, err := c.pool.WriteStdin(msg.TaskID, msg.Data, msg.CloseAfter)
+	if err != nil {
+		log.Printf("[WS] STDIN_INPUT for task %d failed: %v", msg.TaskID, err)
+	}
+	result := models.NewStdinInputResult(msg.TaskID, written, err)
+
+	log.Printf("[WS] Sending STDIN_INPUT_RESULT: task=%d, success=%v, bytes=%d", msg.TaskID, result.Success, result.Bytes)
+	if err := c.sendJSON(&result); err != nil {
+		log.Printf("Failed to send stdin input result: %v", err)
+	}
+}
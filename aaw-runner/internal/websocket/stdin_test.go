@@ -0,0 +1,71 @@
+package websocket
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestStdinInput_ForwardedInOrder verifies STDIN_INPUT messages reach an interactive task's stdin in the
+// order they arrived, each answered with a STDIN_INPUT_RESULT
+func TestStdinInput_ForwardedInOrder(t *testing.T) {
+	testutil.FakeClaude(t, `while read -r line; do echo "got $line"; done; echo eof`)
+	cfg, frames := startBackend(t)
+	client := NewClient(cfg)
+	assert.NoError(t, client.Connect())
+	t.Cleanup(func() { client.Close() })
+
+	client.handleExecute(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 41, ScriptContent: "ask", Interactive: true})
+	receiveUntil(t, frames, models.TypeTaskStarted)
+	waitForProcess(t, client, 41)
+
+	client.handleMessage([]byte(`{"type":"STDIN_INPUT","taskId":41,"data":"one\n"}`))
+	client.handleMessage([]byte(`{"type":"STDIN_INPUT","taskId":41,"data":"two\n"}`))
+	client.handleMessage([]byte(`{"type":"STDIN_INPUT","taskId":41,"closeAfter":true}`))
+
+	// The last result may go out after TASK_COMPLETED, as closing stdin lets the task exit
+	got := receiveUntil(t, frames, models.TypeTaskCompleted)
+	var results []frame
+	var lines []string
+	for {
+		for _, f := range got {
+			switch f.Type {
+			case models.TypeStdinInputResult:
+				results = append(results, f)
+			case models.TypeLog:
+				lines = append(lines, f.Line)
+			}
+		}
+		if len(results) >= 3 {
+			break
+		}
+		got = receiveUntil(t, frames, models.TypeStdinInputResult)
+	}
+	if assert.Len(t, results, 3) {
+		assert.True(t, results[0].Success, results[0].Error)
+		assert.Equal(t, 4, results[0].Bytes)
+		assert.True(t, results[2].Success, results[2].Error)
+		assert.Zero(t, results[2].Bytes)
+	}
+	assert.Less(t, slices.Index(lines, "got one"), slices.Index(lines, "got two"))
+	assert.Less(t, slices.Index(lines, "got two"), slices.Index(lines, "eof"))
+	assert.GreaterOrEqual(t, slices.Index(lines, "got one"), 0)
+}
+
+// TestStdinInput_UnknownTask verifies STDIN_INPUT for a task that is not running is answered with a failure
+func TestStdinInput_UnknownTask(t *testing.T) {
+	cfg, frames := startBackend(t)
+	client := NewClient(cfg)
+	assert.NoError(t, client.Connect())
+	t.Cleanup(func() { client.Close() })
+
+	client.handleMessage([]byte(`{"type":"STDIN_INPUT","taskId":42,"data":"yes\n"}`))
+	got := receiveUntil(t, frames, models.TypeStdinInputResult)
+	result := got[len(got)-1]
+	assert.Equal(t, int64(42), result.TaskID)
+	assert.False(t, result.Success)
+	assert.Contains(t, result.Error, "not running")
+}
@@ -0,0 +1,43 @@
+package websocket
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// checkSubprotocol returns the subprotocol the backend selected on conn's upgrade, refusing one the runner
+// did not request, and no selection at all unless AllowNoSubprotocol is set; a gateway routing on the
+// header would otherwise have sent the runner somewhere it does not belong
+func (c *Client) checkSubprotocol(conn *websocket.Conn) (string, error) {
+	requested := c.cfg.Subprotocols()
+	if len(requested) == 0 {
+		return "", nil
+	}
+	selected := conn.Subprotocol()
+	switch {
+	case slices.Contains(requested, selected):
+		log.Printf("[WS] Negotiated subprotocol %s", selected)
+		return selected, nil
+	case selected != "":
+		return "", fmt.Errorf("backend selected subprotocol %q, which the runner did not request (AAW_WS_SUBPROTOCOLS=%s)",
+			selected, strings.Join(requested, ","))
+	case c.cfg.AllowNoSubprotocol:
+		log.Printf("[WS] Backend selected no subprotocol; continuing as AAW_ALLOW_NO_SUBPROTOCOL is set")
+		return "", nil
+	default:
+		return "", fmt.Errorf("backend selected none of the requested subprotocols (AAW_WS_SUBPROTOCOLS=%s); "+
+			"set AAW_ALLOW_NO_SUBPROTOCOL=true for a backend that does not negotiate one", strings.Join(requested, ","))
+	}
+}
+
+// Subprotocol returns the subprotocol the backend selected for the current connection (empty when none
+// was, or before Connect)
+func (c *Client) Subprotocol() string {
+	c.connMutex.Lock()
+	defer c.connMutex.Unlock()
+	return c.subprotocol
+}
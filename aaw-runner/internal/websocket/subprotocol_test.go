@@ -0,0 +1,65 @@
+package websocket
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/berno/aaw-runner/internal/config"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// startSubprotocolBackend starts a backend answering the upgrade with selected as its subprotocol (none when
+// empty), whatever the runner requested, and HELO with HELO_ACK
+func startSubprotocolBackend(t *testing.T, selected string) config.Config {
+	t.Helper()
+	var upgrader websocket.Upgrader
+	cfg, _ := startBackendWith(t, backendHooks{upgrade: func(w http.ResponseWriter, r *http.Request) (*websocket.Conn, error) {
+		header := http.Header{}
+		if selected != "" {
+			header.Set("Sec-WebSocket-Protocol", selected)
+		}
+		return upgrader.Upgrade(w, r, header)
+	}})
+	return cfg
+}
+
+// TestConnect_Subprotocol verifies the subprotocol the backend selects is checked against the requested ones
+func TestConnect_Subprotocol(t *testing.T) {
+	tests := []struct {
+		name      string
+		requested string
+		selected  string
+		allowNone bool
+		want      string
+		wantErr   string
+	}{
+		{name: "default", selected: "aaw.v1", want: "aaw.v1"},
+		{name: "second choice", requested: "aaw.v2, aaw.v1", selected: "aaw.v1", want: "aaw.v1"},
+		{name: "none selected", wantErr: "AAW_ALLOW_NO_SUBPROTOCOL"},
+		{name: "none selected, allowed", allowNone: true},
+		{name: "unexpected", selected: "chat", wantErr: `"chat"`},
+		{name: "unexpected, none allowed", selected: "chat", allowNone: true, wantErr: `"chat"`},
+		{name: "none requested", requested: " ", selected: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := startSubprotocolBackend(t, tt.selected)
+			if tt.requested != "" {
+				cfg.WSSubprotocols = tt.requested
+			}
+			cfg.AllowNoSubprotocol = tt.allowNone
+			client := NewClient(cfg)
+			defer client.Close()
+
+			err := client.Connect()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				assert.False(t, client.Connected())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, client.Subprotocol())
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/tasklog"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryStore is a tasklog.Store keeping objects in memory
+type memoryStore struct {
+	mu      sync.Mutex
+	objects map[string]string
+}
+
+func (m *memoryStore) Put(ctx context.Context, key string, body io.Reader, size int64, contentType, contentEncoding string) error {
+	data, err := io.ReadAll(body)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = string(data)
+	return err
+}
+
+func (m *memoryStore) URL(key string) string { return "https://s3.test/aaw/" + key }
+
+// TestTaskLogs_UploadedWithURL verifies a task's output is uploaded and TASK_COMPLETED says where
+func TestTaskLogs_UploadedWithURL(t *testing.T) {
+	testutil.FakeClaude(t, "echo first; echo second")
+	store := &memoryStore{objects: make(map[string]string)}
+	spool, err := tasklog.NewSpool(filepath.Join(t.TempDir(), tasklog.DirName))
+	assert.NoError(t, err)
+
+	cfg, frames := startBackend(t)
+	client := NewClient(cfg)
+	client.SetTaskLogs(spool, tasklog.NewUploader(store, tasklog.Options{Prefix: "ci"}))
+	assert.NoError(t, client.Connect())
+
+	client.handleExecute(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 81, ScriptContent: "hello"})
+	var completed models.TaskCompletedMessage
+	for completed.Type != models.TypeTaskCompleted {
+		select {
+		case data := <-frames:
+			json.Unmarshal(data, &completed)
+		case <-time.After(10 * time.Second):
+			t.Fatal("no TASK_COMPLETED received")
+		}
+	}
+	client.Close() // Waits for the upload
+
+	key := "ci/" + time.Now().UTC().Format("2006-01-02") + "/81.log"
+	assert.Equal(t, "https://s3.test/aaw/"+key, completed.LogURL)
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	assert.Contains(t, store.objects[key], "first\nsecond\n", "The log holds the same lines as the LOG stream")
+}
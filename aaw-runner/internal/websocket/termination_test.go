@@ -0,0 +1,92 @@
+package websocket
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/berno/aaw-runner/internal/audit"
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestKillTask_RecordsRequester verifies a backend kill is echoed with its requester and written to the audit trail
+func TestKillTask_RecordsRequester(t *testing.T) {
+	testutil.FakeClaude(t, "sleep 30")
+	dir := t.TempDir()
+	terminations, err := audit.OpenTerminationLog(dir)
+	assert.NoError(t, err)
+	defer terminations.Close()
+
+	cfg, frames := startBackend(t)
+	client := NewClient(cfg)
+	client.SetTerminationLog(terminations)
+	assert.NoError(t, client.Connect())
+	defer client.Close()
+	client.handleExecute(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 61, ScriptContent: "hello"})
+	receiveUntil(t, frames, models.TypeTaskStarted)
+	waitForProcess(t, client, 61)
+
+	client.handleKillTask(models.KillTaskMessage{Type: models.TypeKillTask, TaskID: 61, RequestedBy: "alice@example.com", Reason: "runaway"})
+	got := receiveUntil(t, frames, models.TypeTaskTerminated)
+	for _, typ := range []string{models.TypeCancelAck, models.TypeTaskCompleted, models.TypeTaskTerminated} {
+		if i := indexOf(got, typ, 61); assert.GreaterOrEqual(t, i, 0, "Missing %s", typ) {
+			assert.Equal(t, "alice@example.com", got[i].RequestedBy, typ)
+			assert.Equal(t, "runaway", got[i].Reason, typ)
+		}
+	}
+
+	records := readTerminations(t, dir)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, int64(61), records[0].TaskID)
+		assert.Equal(t, "KILL", records[0].Type)
+		assert.Equal(t, "alice@example.com", records[0].RequestedBy)
+		assert.Equal(t, "runaway", records[0].Reason)
+		assert.Equal(t, audit.OutcomeOK, records[0].Outcome)
+	}
+}
+
+// TestCancelTask_DefaultsToBackend verifies a CANCEL_TASK without requestedBy, and a failed one, are attributed to the backend
+func TestCancelTask_DefaultsToBackend(t *testing.T) {
+	dir := t.TempDir()
+	terminations, err := audit.OpenTerminationLog(dir)
+	assert.NoError(t, err)
+	defer terminations.Close()
+
+	cfg, frames := startBackend(t)
+	client := NewClient(cfg)
+	client.SetTerminationLog(terminations)
+	assert.NoError(t, client.Connect())
+	defer client.Close()
+
+	assert.Error(t, client.handleCancelTask(models.CancelTaskMessage{Type: models.TypeCancelTask, TaskID: 62}))
+	got := receiveUntil(t, frames, models.TypeCancelAck)
+	assert.Equal(t, models.RequestedByBackend, got[len(got)-1].RequestedBy)
+
+	records := readTerminations(t, dir)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "CANCEL", records[0].Type)
+		assert.Equal(t, models.RequestedByBackend, records[0].RequestedBy)
+		assert.Equal(t, audit.OutcomeFailed, records[0].Outcome)
+		assert.NotEmpty(t, records[0].Error)
+	}
+}
+
+// readTerminations decodes the termination audit trail in dir
+func readTerminations(t *testing.T, dir string) []audit.Termination {
+	t.Helper()
+	file, err := os.Open(filepath.Join(dir, audit.TerminationFileName))
+	assert.NoError(t, err)
+	defer file.Close()
+	var records []audit.Termination
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record audit.Termination
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	return records
+}
@@ -0,0 +1,113 @@
+package websocket
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/config"
+	"github.com/gorilla/websocket"
+)
+
+// newDialer builds the dialer for the backend in cfg, with the TLS settings that wss:// URLs need
+// behind an internal CA or with mTLS. Each client gets its own, so websocket.DefaultDialer is never
+// changed. The client certificate is read again for every handshake, so a reconnect picks up rotated files.
+func newDialer(cfg config.Config) (*websocket.Dialer, error) {
+	proxy, err := proxyFunc(cfg)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &websocket.Dialer{
+		Proxy:             proxy,
+		HandshakeTimeout:  cfg.ConnectTimeout,
+		EnableCompression: cfg.WSCompression,
+		Subprotocols:      cfg.Subprotocols(),
+	}
+	if cfg.TLSCAFile == "" && !cfg.TLSInsecure && cfg.TLSServerName == "" && cfg.TLSClientCert == "" && cfg.TLSClientKey == "" {
+		return dialer, nil
+	}
+
+	tlsConfig := &tls.Config{ServerName: cfg.TLSServerName}
+	if cfg.TLSCAFile != "" {
+		pem, err := os.ReadFile(cfg.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read TLS CA file: %w", err)
+		}
+		// The CA is trusted in addition to the system roots, so public backends keep working
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates in TLS CA file %s", cfg.TLSCAFile)
+		}
+		tlsConfig.RootCAs = roots
+	}
+	if cfg.TLSInsecure {
+		log.Printf("[WS] WARNING: TLS certificate verification is DISABLED (AAW_TLS_INSECURE); the backend connection can be intercepted")
+		tlsConfig.InsecureSkipVerify = true
+	}
+	if cfg.TLSClientCert != "" || cfg.TLSClientKey != "" {
+		if cfg.TLSClientCert == "" || cfg.TLSClientKey == "" {
+			return nil, errors.New("AAW_TLS_CLIENT_CERT and AAW_TLS_CLIENT_KEY must be set together")
+		}
+		// Fail at startup rather than on the first handshake; later loads happen per connection
+		if _, err := tls.LoadX509KeyPair(cfg.TLSClientCert, cfg.TLSClientKey); err != nil {
+			return nil, fmt.Errorf("failed to load TLS client certificate: %w", err)
+		}
+		tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, err := tls.LoadX509KeyPair(cfg.TLSClientCert, cfg.TLSClientKey)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load TLS client certificate: %w", err)
+			}
+			return &cert, nil
+		}
+	}
+	dialer.TLSClientConfig = tlsConfig
+	return dialer, nil
+}
+
+// describeDialError tells apart why dialling the backend failed: its name did not resolve, it timed out, a
+// proxy in between refused (see describeProxyError), the backend's certificate was not trusted, or the
+// backend refused the runner's client certificate (or the lack of one)
+func (c *Client) describeDialError(err error) error {
+	var resolveErr *resolveError
+	if errors.As(err, &resolveErr) {
+		// Through a proxy the only name resolved is the proxy's
+		if proxy := c.proxyFor(); proxy != nil {
+			return fmt.Errorf("proxy %s host name did not resolve (check the proxy settings and DNS): %w", proxy.Redacted(), err)
+		}
+		return fmt.Errorf("backend host name did not resolve (check AAW_BACKEND_URL and DNS): %w", err)
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout() {
+		return fmt.Errorf("backend did not answer within the connect timeout of %s (AAW_CONNECT_TIMEOUT): %w", c.cfg.ConnectTimeout, err)
+	}
+	if described := c.describeProxyError(err); described != err {
+		return described
+	}
+	var verifyErr *tls.CertificateVerificationError
+	if errors.As(err, &verifyErr) {
+		return fmt.Errorf("backend's TLS certificate is not trusted: %w", err)
+	}
+
+	// Alerts the backend sent come back as "remote error" and only say what went wrong in their text
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) || opErr.Op != "remote error" || !strings.Contains(opErr.Err.Error(), "certificate") {
+		return err
+	}
+	if c.cfg.TLSClientCert == "" {
+		return fmt.Errorf("backend requires a client certificate (set AAW_TLS_CLIENT_CERT and AAW_TLS_CLIENT_KEY): %w", err)
+	}
+	if cert, loadErr := tls.LoadX509KeyPair(c.cfg.TLSClientCert, c.cfg.TLSClientKey); loadErr == nil && cert.Leaf != nil && time.Now().After(cert.Leaf.NotAfter) {
+		return fmt.Errorf("runner's client certificate %s expired at %s: %w", c.cfg.TLSClientCert, cert.Leaf.NotAfter.Format(time.RFC3339), err)
+	}
+	return fmt.Errorf("backend rejected the runner's client certificate %s: %w", c.cfg.TLSClientCert, err)
+}
@@ -0,0 +1,201 @@
+package websocket
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/config"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestConnect_TLS verifies wss:// backends are dialled with each client's own CA, server name and skip-verify settings
+func TestConnect_TLS(t *testing.T) {
+	upgrader := websocket.Upgrader{Subprotocols: testSubprotocols}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			ackHelo(conn, data)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	// The test server's certificate is self-signed for 127.0.0.1 and example.com
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	assert.NoError(t, os.WriteFile(caFile, certPEM, 0600))
+	junkFile := filepath.Join(t.TempDir(), "junk.pem")
+	assert.NoError(t, os.WriteFile(junkFile, []byte("not a certificate"), 0600))
+
+	for name, tc := range map[string]struct {
+		set     func(cfg *config.Config)
+		wantErr string
+	}{
+		"untrusted":          {func(cfg *config.Config) {}, "certificate"},
+		"custom CA":          {func(cfg *config.Config) { cfg.TLSCAFile = caFile }, ""},
+		"server name":        {func(cfg *config.Config) { cfg.TLSCAFile, cfg.TLSServerName = caFile, "example.com" }, ""},
+		"wrong server name":  {func(cfg *config.Config) { cfg.TLSCAFile, cfg.TLSServerName = caFile, "aaw.internal" }, "aaw.internal"},
+		"insecure":           {func(cfg *config.Config) { cfg.TLSInsecure = true }, ""},
+		"missing CA file":    {func(cfg *config.Config) { cfg.TLSCAFile = filepath.Join(t.TempDir(), "missing.pem") }, "failed to read TLS CA file"},
+		"CA file without CA": {func(cfg *config.Config) { cfg.TLSCAFile = junkFile }, "no PEM certificates"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			cfg := config.Default()
+			cfg.BackendURL = "wss" + strings.TrimPrefix(server.URL, "https")
+			tc.set(&cfg)
+			client := NewClient(cfg)
+			t.Cleanup(func() { client.Close() })
+
+			err := client.Connect()
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+				assert.True(t, client.Connected())
+			} else {
+				assert.ErrorContains(t, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+// testCA is a certificate authority for minting client certificates
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	return testCA{cert, key}
+}
+
+// writeClientCert mints a client certificate valid until notAfter into dir, returning the cert and key paths
+func (ca testCA) writeClientCert(t *testing.T, dir string, notAfter time.Time) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "runner"},
+		NotBefore:    notAfter.Add(-2 * time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+
+	certFile, keyFile := filepath.Join(dir, "runner.crt"), filepath.Join(dir, "runner.key")
+	assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile
+}
+
+// TestConnect_ClientCert verifies the runner presents its client certificate, re-reads it on reconnect and
+// reports a rejected or expired one apart from an untrusted backend
+func TestConnect_ClientCert(t *testing.T) {
+	ca := newTestCA(t)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	upgrader := websocket.Upgrader{Subprotocols: testSubprotocols}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			ackHelo(conn, data)
+		}
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: roots}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	assert.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600))
+	connect := func(certFile, keyFile string) (*Client, error) {
+		cfg := config.Default()
+		cfg.BackendURL = "wss" + strings.TrimPrefix(server.URL, "https")
+		cfg.TLSCAFile, cfg.TLSClientCert, cfg.TLSClientKey = caFile, certFile, keyFile
+		client := NewClient(cfg)
+		t.Cleanup(func() { client.Close() })
+		return client, client.Connect()
+	}
+
+	t.Run("accepted", func(t *testing.T) {
+		_, err := connect(ca.writeClientCert(t, t.TempDir(), time.Now().Add(time.Hour)))
+		assert.NoError(t, err)
+	})
+	t.Run("missing", func(t *testing.T) {
+		_, err := connect("", "")
+		assert.ErrorContains(t, err, "backend requires a client certificate")
+	})
+	t.Run("expired", func(t *testing.T) {
+		_, err := connect(ca.writeClientCert(t, t.TempDir(), time.Now().Add(-time.Hour)))
+		assert.ErrorContains(t, err, "client certificate")
+		assert.ErrorContains(t, err, "expired at")
+	})
+	t.Run("rotated", func(t *testing.T) {
+		dir := t.TempDir()
+		client, err := connect(newTestCA(t).writeClientCert(t, dir, time.Now().Add(time.Hour)))
+		assert.ErrorContains(t, err, "backend rejected the runner's client certificate")
+
+		ca.writeClientCert(t, dir, time.Now().Add(time.Hour))
+		assert.NoError(t, client.Connect(), "The rotated certificate is used on the next connect")
+	})
+	t.Run("untrusted backend", func(t *testing.T) {
+		client, _ := connect(ca.writeClientCert(t, t.TempDir(), time.Now().Add(time.Hour)))
+		client.cfg.TLSCAFile = ""
+		client.dialer, client.dialErr = newDialer(client.cfg)
+		err := client.Connect()
+		assert.ErrorContains(t, err, "backend's TLS certificate is not trusted")
+		assert.NotContains(t, err.Error(), "client certificate")
+	})
+	t.Run("key without cert", func(t *testing.T) {
+		_, err := connect("", "runner.key")
+		assert.ErrorContains(t, err, "must be set together")
+	})
+}
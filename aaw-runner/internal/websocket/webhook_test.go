@@ -0,0 +1,72 @@
+package websocket
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/berno/aaw-runner/internal/testutil"
+	"github.com/berno/aaw-runner/internal/webhook"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestOnTaskComplete_NotifiesWebhook verifies finished tasks are POSTed to the completion webhook
+func TestOnTaskComplete_NotifiesWebhook(t *testing.T) {
+	testutil.FakeClaude(t, "echo done")
+	events := make(chan webhook.Event, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event webhook.Event
+		json.NewDecoder(r.Body).Decode(&event)
+		events <- event
+	}))
+	defer hook.Close()
+
+	cfg, frames := startBackend(t)
+	cfg.CompletionWebhookURL = hook.URL
+	client := NewClient(cfg)
+	assert.NoError(t, client.Connect())
+	defer client.Close()
+
+	client.handleExecute(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 60, ScriptContent: "hello",
+		Metadata: map[string]string{"job": "warm-cache"}})
+	receiveUntil(t, frames, models.TypeTaskCompleted)
+
+	select {
+	case event := <-events:
+		assert.Equal(t, int64(60), event.TaskID)
+		assert.Equal(t, models.StatusCompleted, event.Status)
+		assert.Equal(t, 0, event.ExitCode)
+		assert.Equal(t, "warm-cache", event.Metadata["job"])
+		assert.False(t, event.FinishedAt.IsZero())
+	case <-time.After(5 * time.Second):
+		t.Fatal("Webhook was not called")
+	}
+}
+
+// TestOnTaskComplete_WebhookFailureDoesNotAffectBackend verifies a broken webhook is only counted
+func TestOnTaskComplete_WebhookFailureDoesNotAffectBackend(t *testing.T) {
+	testutil.FakeClaude(t, "exit 4")
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer hook.Close()
+
+	cfg, frames := startBackend(t)
+	cfg.CompletionWebhookURL = hook.URL
+	client := NewClient(cfg)
+	assert.NoError(t, client.Connect())
+	defer client.Close()
+
+	client.handleExecute(models.ExecuteMessage{Type: models.TypeExecute, TaskID: 61, ScriptContent: "hello"})
+	got := receiveUntil(t, frames, models.TypeTaskCompleted)
+	assert.Equal(t, models.ErrorCodeExitNonzero, got[len(got)-1].ErrorCode)
+
+	// The webhook is notified after TASK_COMPLETED is sent
+	assert.Eventually(t, func() bool {
+		_, failed := client.webhook.Stats()
+		return failed == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,121 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/berno/aaw-runner/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// halfDeadConn reads as usual but fails every write once broken is set, like a connection whose
+// outbound path died while the backend still has it open
+type halfDeadConn struct {
+	net.Conn
+	broken *atomic.Bool
+}
+
+func (c halfDeadConn) Write(p []byte) (int, error) {
+	if c.broken.Load() {
+		return 0, errors.New("broken pipe")
+	}
+	return c.Conn.Write(p)
+}
+
+// TestWrite_FailureReconnects verifies a failed write drops the connection and redials at once, instead of
+// waiting for the read side to notice, and the task message that failed goes out on the new connection
+func TestWrite_FailureReconnects(t *testing.T) {
+	drop := make(chan struct{})
+	t.Cleanup(func() { close(drop) })
+	cfg, frames, _ := startDroppingBackend(t, drop)
+	cfg.PingInterval = time.Hour
+	client := NewClient(cfg)
+
+	broken := &atomic.Bool{}
+	var dials atomic.Int32
+	dial := client.dialer.NetDialContext
+	client.dialer.NetDialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil || dials.Add(1) > 1 {
+			return conn, err
+		}
+		return halfDeadConn{conn, broken}, nil
+	}
+
+	assert.NoError(t, client.Connect())
+	listenDone := make(chan error, 1)
+	go func() { listenDone <- client.Listen() }()
+	t.Cleanup(func() {
+		client.Close()
+		<-listenDone
+	})
+
+	for f := range frames {
+		if f.Type == models.TypeRunnerCapacity {
+			break
+		}
+	}
+	broken.Store(true)
+	client.sendStatusUpdate(models.NewStatusUpdate(4, models.StatusRunning))
+
+	var second []string
+	deadline := time.After(5 * time.Second)
+	for len(second) == 0 || second[len(second)-1] != models.TypeStatusUpdate {
+		select {
+		case f := <-frames:
+			assert.Equal(t, 2, f.conn, "Nothing more arrives on the broken connection")
+			second = append(second, f.Type)
+		case <-deadline:
+			t.Fatalf("STATUS_UPDATE not sent on a new connection (got %v)", second)
+		}
+	}
+	assert.Equal(t, models.TypeHelo, second[0])
+	errMsg, _ := client.LastError()
+	assert.NotEmpty(t, errMsg)
+}
+
+// TestWrite_UnencodableMessage verifies a message that cannot be marshalled is dropped without touching the
+// connection
+func TestWrite_UnencodableMessage(t *testing.T) {
+	cfg, frames := startBackend(t)
+	client := NewClient(cfg)
+	t.Cleanup(func() { client.Close() })
+	assert.NoError(t, client.Connect())
+	receiveUntil(t, frames, models.TypeRunnerCapacity)
+
+	assert.NoError(t, client.sendJSON(map[string]interface{}{"type": "BROKEN", "value": make(chan int)}))
+	client.sendRunnerStatus(client.stateMachine.GetState())
+	got := receiveUntil(t, frames, models.TypeRunnerStatus)
+	assert.Len(t, got, 1, "Nothing was written for the broken message")
+	assert.True(t, client.Connected())
+}
+
+// TestWriteOutgoing_EarlierGeneration verifies messages queued for a replaced connection are not written
+// to the new one: task messages are held in the offline buffer and sent ahead of the next message, anything
+// else is dropped
+func TestWriteOutgoing_EarlierGeneration(t *testing.T) {
+	cfg, frames := startBackend(t)
+	client := NewClient(cfg)
+	t.Cleanup(func() { client.Close() })
+	assert.NoError(t, client.Connect())
+	receiveUntil(t, frames, models.TypeRunnerCapacity)
+	client.flush()
+
+	client.connMutex.Lock()
+	stale := client.generation - 1
+	client.connMutex.Unlock()
+	capacity := models.NewRunnerCapacity(5, 0, 5, 0)
+	update := models.NewStatusUpdate(9, models.StatusRunning)
+	assert.NoError(t, client.enqueue(outgoing{msg: &capacity, gen: stale}))
+	assert.NoError(t, client.enqueue(outgoing{msg: &update, gen: stale}))
+	client.sendRunnerStatus(client.stateMachine.GetState())
+
+	got := receiveUntil(t, frames, models.TypeRunnerStatus)
+	assert.Len(t, got, 2)
+	assert.Equal(t, models.TypeStatusUpdate, got[0].Type)
+	assert.Equal(t, int64(9), got[0].TaskID)
+}
@@ -1,40 +1,394 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
+	"time"
 
+	"github.com/berno/aaw-runner/internal/admin"
+	"github.com/berno/aaw-runner/internal/audit"
+	"github.com/berno/aaw-runner/internal/config"
+	"github.com/berno/aaw-runner/internal/control"
+	"github.com/berno/aaw-runner/internal/doctor"
+	"github.com/berno/aaw-runner/internal/health"
+	"github.com/berno/aaw-runner/internal/journal"
+	"github.com/berno/aaw-runner/internal/livelog"
+	"github.com/berno/aaw-runner/internal/logfile"
+	"github.com/berno/aaw-runner/internal/matcher"
+	"github.com/berno/aaw-runner/internal/recurring"
+	"github.com/berno/aaw-runner/internal/runnerid"
+	"github.com/berno/aaw-runner/internal/runonce"
+	"github.com/berno/aaw-runner/internal/schema"
+	"github.com/berno/aaw-runner/internal/service"
+	"github.com/berno/aaw-runner/internal/shutdown"
+	"github.com/berno/aaw-runner/internal/statsd"
+	"github.com/berno/aaw-runner/internal/statusfile"
+	"github.com/berno/aaw-runner/internal/syslog"
+	"github.com/berno/aaw-runner/internal/systemd"
+	"github.com/berno/aaw-runner/internal/tasklog"
+	"github.com/berno/aaw-runner/internal/tracing"
+	"github.com/berno/aaw-runner/internal/tui"
+	"github.com/berno/aaw-runner/internal/version"
 	"github.com/berno/aaw-runner/internal/websocket"
 )
 
+//go:generate go run . schema --out schemas
+
+// Exit statuses of a shutdown that did not finish cleanly
+const (
+	exitTasksCancelled = 3 // The grace period ran out and tasks were cancelled
+	exitForced         = 4 // A second signal killed the tasks
+	exitImmediate      = 5 // A third signal exited without cleanup
+)
+
+// claudeWatchInterval is how often a missing claude binary is probed for again
+const claudeWatchInterval = time.Minute
+
+// History the client keeps for --tui: output lines per task and recent events
+const (
+	tuiOutputLines = 200
+	tuiEvents      = 50
+)
+
+// liveLogBacklog is how many recent lines of a running task the admin log viewer replays on opening it
+const liveLogBacklog = 500
+
 func main() {
-	log.Println("Starting AAW Runner...")
+	os.Exit(run())
+}
 
-	// WebSocket server URL
-	// Try AAW_BACKEND_URL first (new standard), fallback to AAW_SERVER_URL (legacy)
-	serverURL := os.Getenv("AAW_BACKEND_URL")
-	if serverURL == "" {
-		serverURL = os.Getenv("AAW_SERVER_URL")
+// run starts the runner and returns the process exit status once it stops
+func run() int {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "schema":
+			if err := runSchema(os.Args[2:]); err != nil {
+				log.Fatalf("Failed to generate schemas: %v", err)
+			}
+			return 0
+		case "run":
+			return runOnce(os.Args[2:])
+		case "doctor":
+			return runDoctor(os.Args[2:])
+		case "service":
+			return runService(os.Args[2:])
+		case "version", "--version", "-version":
+			fmt.Println(version.String())
+			return 0
+		}
+	}
+
+	// Flags override env vars (AAW_BACKEND_URL, falling back to the legacy AAW_SERVER_URL, etc.)
+	cfg, err := config.Load(os.Args[1:], os.Getenv, os.Stderr)
+	if config.IsHelp(err) {
+		return 0
 	}
-	if serverURL == "" {
-		serverURL = "ws://localhost:8080/ws/logs"
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
 	}
 
-	log.Printf("Connecting to backend at: %s", serverURL)
+	// Under the Windows SCM stop requests arrive as control events and there is no console: the session
+	// turns the events into signals and writes the console log to the Event Log
+	session, err := service.Start(service.DefaultName)
+	if err != nil {
+		log.Fatalf("Failed to start as a service: %v", err)
+	}
+	defer session.Stopped()
 
-	// Create and connect WebSocket client
-	client := websocket.NewClient(serverURL)
+	// The console log goes to stderr (the Event Log under the SCM) or, with --log-target=syslog, to the local syslog socket
+	var console io.Writer = os.Stderr
+	if session != nil {
+		console = session.Log
+		log.SetOutput(console)
+	}
+	if cfg.LogTarget == config.LogTargetSyslog {
+		sysLog, err := syslog.Dial("", cfg.SyslogFacility, cfg.SyslogTaskOutput)
+		if err != nil {
+			log.Printf("[LOG] Warning: %v; logging to stderr instead", err)
+		} else {
+			defer sysLog.Close()
+			console = sysLog
+			log.SetOutput(console)
+		}
+	}
 
-	if err := client.Connect(); err != nil {
-		log.Fatalf("Failed to connect: %v", err)
+	var logFile *logfile.Writer
+	if cfg.LogFile != "" {
+		logFile, err = logfile.Open(cfg.LogFile, cfg.LogMaxSize, cfg.LogBackups)
+		if err != nil {
+			log.Printf("Failed to open log file: %v", err)
+			return 1
+		}
+		defer logFile.Close()
+		if cfg.LogStdout {
+			log.SetOutput(io.MultiWriter(console, logFile))
+		} else {
+			log.SetOutput(logFile)
+		}
 	}
-	defer client.Close()
 
-	// Handle graceful shutdown
-	sigChan := make(chan os.Signal, 1)
+	log.Printf("Starting %s...", version.String())
+
+	if cfg.Workdir != "" {
+		if err := os.Chdir(cfg.Workdir); err != nil {
+			log.Printf("Failed to change to workdir: %v", err)
+			return 1
+		}
+	}
+
+	// Task lifecycle tracing; without an endpoint the spans are no-ops
+	stopTracing, err := tracing.Setup(cfg.OTelEndpoint)
+	if err != nil {
+		log.Printf("Failed to set up tracing: %v", err)
+		return 1
+	}
+	if cfg.OTelEndpoint != "" {
+		log.Printf("Exporting task traces to %s", cfg.OTelEndpoint)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		stopTracing(ctx)
+	}()
+
+	log.Printf("Connecting to backend at: %s", cfg.BackendURL)
+
+	// Create and connect WebSocket client; the hooks log every transition, redials included
+	client := websocket.NewClient(cfg,
+		websocket.WithOnConnected(func() { log.Println("Backend connection up") }),
+		websocket.WithOnDisconnected(func(err error) { log.Printf("Backend connection down: %v", err) }),
+	)
+
+	runnerID, err := runnerid.Load(cfg.RunnerIDPath())
+	if err != nil {
+		log.Printf("Warning: could not keep the runner ID in %s (%v); using %s until the runner exits, so the backend will see a new runner after a restart",
+			cfg.RunnerIDPath(), err, runnerID)
+	} else {
+		log.Printf("Runner ID: %s", runnerID)
+	}
+	client.SetRunnerID(runnerID)
+
+	metrics, err := statsd.New(cfg.StatsdAddr, cfg.StatsdPrefix, cfg.StatsdTags)
+	if err != nil {
+		log.Printf("Failed to set up StatsD: %v", err)
+		return 1
+	}
+	if metrics != nil {
+		log.Printf("Pushing metrics to StatsD at %s", cfg.StatsdAddr)
+		defer metrics.Close()
+		client.SetMetrics(metrics)
+	}
+
+	var auditLog *audit.Writer
+	if cfg.AuditLog {
+		var masker *matcher.SecretMasker
+		if cfg.SecretMasking {
+			masker = matcher.NewSecretMasker()
+		}
+		auditLog, err = audit.Open(cfg.StateDir, cfg.AuditMaxSize, cfg.AuditMaxBackups, masker)
+		if err != nil {
+			log.Printf("Failed to open audit log: %v", err)
+			return 1
+		}
+		log.Printf("[AUDIT] Recording protocol traffic in %s", filepath.Join(cfg.StateDir, audit.FileName))
+		defer auditLog.Close()
+		client.SetAudit(auditLog)
+	}
+
+	if cfg.TerminationAudit {
+		terminations, err := audit.OpenTerminationLog(cfg.StateDir)
+		if err != nil {
+			log.Printf("Failed to open termination audit trail: %v", err)
+			return 1
+		}
+		defer terminations.Close()
+		client.SetTerminationLog(terminations)
+	}
+
+	if cfg.DeliveryJournal {
+		deliveries, err := journal.Open(cfg.StateDir, cfg.DeliveryJournalMax)
+		if err != nil {
+			log.Printf("Failed to open delivery journal: %v", err)
+			return 1
+		}
+		if n := len(deliveries.Pending()); n > 0 {
+			log.Printf("[JOURNAL] %d messages from before the restart are unacknowledged; re-sending them on connect", n)
+		}
+		defer deliveries.Close()
+		client.SetJournal(deliveries)
+	}
+
+	if cfg.StatusFile != "" {
+		statusFile := statusfile.New(cfg.StatusFile, client, cfg.BackendURL)
+		client.SetStatusFile(statusFile)
+		statusFile.Start(statusfile.DefaultInterval)
+		defer statusFile.Close()
+	}
+
+	if cfg.LogS3Bucket != "" {
+		store, err := tasklog.NewS3Store(cfg.LogS3Endpoint, cfg.LogS3Bucket)
+		if err != nil {
+			log.Printf("Failed to set up task log uploads: %v", err)
+			return 1
+		}
+		spool, err := tasklog.NewSpool(filepath.Join(cfg.StateDir, tasklog.DirName))
+		if err != nil {
+			log.Printf("Failed to create task log directory: %v", err)
+			return 1
+		}
+		log.Printf("[TASKLOG] Uploading task logs to %s", store.URL(cfg.LogS3Prefix))
+		client.SetTaskLogs(spool, tasklog.NewUploader(store, tasklog.Options{Prefix: cfg.LogS3Prefix, Gzip: cfg.LogS3Gzip}))
+	}
+
+	recurrences, err := recurring.Open(cfg.StateDir, cfg.RecurringCatchUp)
+	if err != nil {
+		log.Printf("Failed to load recurring tasks: %v", err)
+		return 1
+	}
+	defer recurrences.Close()
+	client.SetRecurring(recurrences)
+
+	// Probe claude before connecting so HELO reports it; a missing binary is probed for again until it appears
+	claude := client.Claude()
+	claude.Probe()
+	stopWatch := make(chan struct{})
+	defer close(stopWatch)
+	go claude.Watch(claudeWatchInterval, stopWatch)
+
+	// SIGHUP (or aawctl reload) re-probes claude and, for logrotate compatibility, reopens the log and audit files
+	reload := func() error {
+		var logErr error
+		if logFile != nil {
+			if logErr = logFile.Reopen(); logErr == nil {
+				log.Printf("[LOG] Reopened %s", cfg.LogFile)
+			}
+		}
+		auditErr := auditLog.Reopen()
+		claude.Probe()
+		return errors.Join(logErr, auditErr)
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			reload()
+		}
+	}()
+
+	if cfg.HealthAddr != "" {
+		probes := health.NewServer(cfg.HealthAddr, cfg.ClaudePath, client)
+		if err := probes.Start(); err != nil {
+			log.Printf("Failed to start health endpoint: %v", err)
+			return 1
+		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			probes.Shutdown(ctx)
+		}()
+	}
+
+	if cfg.AdminAddr != "" {
+		adminAPI := admin.NewServer(cfg.AdminAddr, cfg.AdminToken, client)
+		liveLogs := livelog.New(liveLogBacklog)
+		client.SetLiveLogs(liveLogs)
+		adminAPI.SetLiveLogs(liveLogs)
+		if err := adminAPI.Start(); err != nil {
+			log.Printf("Failed to start admin API: %v", err)
+			return 1
+		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			adminAPI.Shutdown(ctx)
+		}()
+	}
+
+	if cfg.ControlSocket != "" {
+		controlSocket := control.NewServer(cfg.ControlSocket, cfg.ControlSocketMode, client, reload)
+		if err := controlSocket.Start(); err != nil {
+			log.Printf("Failed to start control socket: %v", err)
+			return 1
+		}
+		defer controlSocket.Close()
+	}
+
+	if cfg.TUI {
+		client.KeepHistory(tuiOutputLines, tuiEvents)
+	}
+
+	// Handle graceful shutdown; repeating the signal escalates it
+	sigChan := make(chan os.Signal, 3)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	if session != nil {
+		go func() {
+			for sig := range session.Signals {
+				sigChan <- sig
+			}
+		}()
+	}
+	// launchd sends a single SIGTERM and kills the runner after ExitTimeOut, so escalate on our own in time
+	var signals <-chan os.Signal = sigChan
+	if service.Managed(os.Getenv) {
+		signals = service.Escalate(sigChan, service.ForceAfter(service.StopWindow))
+	}
+
+	// The backend may not be up yet (systemd, docker-compose): keep trying, but let a signal stop that
+	defer client.Close()
+	ctx, cancelConnect := context.WithCancel(context.Background())
+	connected := make(chan error, 1)
+	go func() { connected <- client.ConnectWithRetry(ctx) }()
+	select {
+	case err := <-connected:
+		cancelConnect()
+		if err != nil {
+			log.Printf("Failed to connect: %v", err)
+			return 1
+		}
+	case <-signals:
+		cancelConnect()
+		<-connected
+		log.Println("Shutdown signal received while connecting, exiting")
+		return 0
+	}
+
+	// While the dashboard owns the terminal the log only goes to the log file or syslog
+	stopUI := func() {}
+	if cfg.TUI {
+		logTarget := "hidden (set --log-file to keep it)"
+		switch {
+		case logFile != nil:
+			logTarget = cfg.LogFile
+		case console != os.Stderr:
+			logTarget = config.LogTargetSyslog
+		}
+		dashboard := tui.Start(client, cfg.BackendURL, logTarget, os.Stdin, os.Stdout, os.Getenv)
+		// Logging to syslog leaves the terminal alone already
+		if dashboard.Interactive() && console == os.Stderr {
+			if logFile != nil {
+				log.SetOutput(logFile)
+			} else {
+				log.SetOutput(io.Discard)
+			}
+		}
+		stopUI = dashboard.Stop
+	}
+	defer func() { stopUI() }()
+
+	// Type=notify units: report readiness and keep the watchdog fed (no-op outside systemd)
+	notifier := systemd.NewNotifier(os.Getenv)
+	notifier.Ready(systemd.Status(client))
+	stopNotify := make(chan struct{})
+	defer close(stopNotify)
+	go notifier.Run(client, stopNotify)
 
 	// Start listening in a goroutine
 	errChan := make(chan error, 1)
@@ -43,14 +397,117 @@ func main() {
 	}()
 
 	// Wait for shutdown signal or error
+	code := 0
 	select {
-	case <-sigChan:
-		log.Println("Shutdown signal received, closing connection...")
+	case <-signals:
+		log.Println("Shutdown signal received, draining...")
+		notifier.Stopping()
+		code = shutdown.Escalator{
+			Graceful: func() int {
+				if !client.Shutdown(cfg.ShutdownGrace()) {
+					return exitTasksCancelled
+				}
+				return 0
+			},
+			Force: func() int {
+				client.ForceShutdown()
+				return exitForced
+			},
+			Immediate: func() {
+				stopUI()
+				os.Exit(exitImmediate)
+			},
+		}.Run(signals)
+	case <-client.Done():
+		// Drained already; the deferred Close sends GOODBYE
+		log.Printf("Backend asked the runner to exit (%s)", client.DoneReason())
+		notifier.Stopping()
 	case err := <-errChan:
+		notifier.Stopping()
 		if err != nil {
 			log.Printf("Connection error: %v", err)
 		}
 	}
 
 	log.Println("AAW Runner stopped")
+	return code
+}
+
+// runSchema implements "aaw-runner schema --out dir/": one JSON Schema file per protocol message
+func runSchema(args []string) error {
+	fs := flag.NewFlagSet("schema", flag.ExitOnError)
+	out := fs.String("out", "schemas", "directory to write the schema files to")
+	fs.Parse(args)
+
+	if err := schema.WriteAll(*out); err != nil {
+		return err
+	}
+	log.Printf("Wrote %d schemas to %s", len(schema.Messages), *out)
+	return nil
+}
+
+// runDoctor implements "aaw-runner doctor [flags]": diagnose this host with the runner's configuration
+// Exits nonzero if any check fails
+func runDoctor(args []string) int {
+	cfg, err := config.Load(args, os.Getenv, os.Stderr)
+	if config.IsHelp(err) {
+		return 0
+	}
+	if err != nil {
+		cfg = config.Default()
+	}
+
+	fmt.Printf("Diagnosing %s\n\n", version.String())
+	if doctor.Print(os.Stdout, doctor.Run(cfg, err, doctor.SystemEnv())) {
+		return 1
+	}
+	return 0
+}
+
+// runService implements "aaw-runner service install|uninstall|start|stop": manage the runner as a Windows
+// service or a launchd daemon
+func runService(args []string) int {
+	mgr, err := service.NewManager()
+	if err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		log.Printf("Failed to locate the runner binary: %v", err)
+		return 1
+	}
+	if err := service.Command(args, mgr, exe, os.Stdout); err != nil {
+		log.Printf("%v", err)
+		return 1
+	}
+	return 0
+}
+
+// runOnce implements "aaw-runner run": execute a single task locally through the regular executor, without a backend
+// Exits with the task's exit status
+func runOnce(args []string) int {
+	opts, cfg, err := runonce.ParseArgs(args, os.Getenv, os.Stderr)
+	if config.IsHelp(err) {
+		return 0
+	}
+	if err != nil {
+		log.Fatalf("Invalid run options: %v", err)
+	}
+
+	// A relative --script names a file relative to where the command was typed, not to --workdir
+	if opts.Script != "" {
+		if opts.Script, err = filepath.Abs(opts.Script); err != nil {
+			log.Fatalf("Invalid script path: %v", err)
+		}
+	}
+	if cfg.Workdir != "" {
+		if err := os.Chdir(cfg.Workdir); err != nil {
+			log.Fatalf("Failed to change to workdir: %v", err)
+		}
+	}
+
+	interrupt := make(chan os.Signal, 2)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	return runonce.Run(opts, cfg, os.Stdout, os.Stderr, interrupt)
 }